@@ -4,17 +4,17 @@ go 1.25.0
 
 require (
 	filippo.io/age v1.2.1
+	github.com/atotto/clipboard v0.1.4
 	github.com/google/uuid v1.6.0
 	github.com/urfave/cli/v2 v2.27.7
+	golang.org/x/crypto v0.24.0
+	golang.org/x/sys v0.39.0
 	golang.org/x/term v0.38.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/atotto/clipboard v0.1.4 // indirect
 	github.com/cpuguy83/go-md2man/v2 v2.0.7 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/xrash/smetrics v0.0.0-20240521201337-686a1a2994c1 // indirect
-	golang.org/x/crypto v0.24.0 // indirect
-	golang.org/x/sys v0.39.0 // indirect
 )
@@ -1,12 +1,20 @@
 package action
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/urfave/cli/v2"
 
+	"passbook/internal/audit"
+	"passbook/internal/backend/crypto/age"
 	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/pkg/ui"
 )
 
 // CredAccessList lists who has access to a credential
@@ -27,8 +35,7 @@ func (a *Action) CredAccessList(c *cli.Context) error {
 		return fmt.Errorf("failed to load credential: %w", err)
 	}
 
-	fmt.Printf("Access for credential: %s/%s\n", website, name)
-	fmt.Println("========================================")
+	ui.Heading(fmt.Sprintf("Access for credential: %s/%s", website, name))
 	fmt.Println()
 
 	// Check if using per-secret permissions
@@ -45,14 +52,16 @@ func (a *Action) CredAccessList(c *cli.Context) error {
 		fmt.Printf("%-35s %-10s\n", "EMAIL", "ACCESS")
 		fmt.Printf("%-35s %-10s\n", "-----", "------")
 
+		engine := a.policy()
 		for _, user := range userList.Users {
-			// Check if user can write credentials
+			// Check if user can write credentials; service accounts and
+			// members without a key aren't recipients at all
 			access := "read"
-			for _, role := range user.Roles {
-				if role.CanWriteCredentials() {
-					access = "write"
-					break
-				}
+			switch {
+			case user.IsServiceAccount() || user.PublicKey == "":
+				access = "none"
+			case engine.CanWriteCredentials(&user):
+				access = "write"
 			}
 
 			email := user.Email
@@ -65,17 +74,144 @@ func (a *Action) CredAccessList(c *cli.Context) error {
 		fmt.Println("Using per-secret access control")
 		fmt.Println()
 
-		fmt.Printf("%-35s %-10s\n", "EMAIL", "ACCESS")
-		fmt.Printf("%-35s %-10s\n", "-----", "------")
+		printRecipientPermissions(cred.Permissions.Recipients)
+		if err := a.printImplicitRecipients(cred.Permissions); err != nil {
+			return err
+		}
+	}
+
+	if c.Bool("effective") {
+		s, err := a.openStore()
+		if err != nil {
+			return err
+		}
+		keys, err := s.CredentialRecipients(cred)
+		if err != nil {
+			return err
+		}
+		return a.printEffectiveRecipients(cred.FullPath(), keys)
+	}
+
+	return nil
+}
+
+// printImplicitRecipients shows who can read a secret under per-secret
+// access without being listed: whoever saves it, since the store always
+// encrypts for the local identity. It also shows which admins are listed,
+// as their role doesn't let them read it otherwise.
+func (a *Action) printImplicitRecipients(perms *models.SecretPermissions) error {
+	userList, err := a.loadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
 
-		for _, perm := range cred.Permissions.Recipients {
-			fmt.Printf("%-35s %-10s\n", perm.Email, perm.Access)
+	self := a.cfg.Identity.PublicKey
+	if _, ok := perms.GetAccessByKey(self); self != "" && !ok {
+		email := a.cfg.Identity.Email
+		for _, u := range userList.Users {
+			if u.PublicKey == self {
+				email = u.Email
+			}
 		}
+		fmt.Println()
+		fmt.Printf("Saving it also encrypts it for %s (you), and records that in the audit log\n", email)
 	}
 
+	var listed, unlisted []string
+	for _, u := range userList.Users {
+		if !u.IsAdmin() {
+			continue
+		}
+		if _, ok := perms.GetAccess(u.Email); ok {
+			listed = append(listed, u.Email)
+		} else {
+			unlisted = append(unlisted, u.Email)
+		}
+	}
+	if len(listed) > 0 {
+		fmt.Printf("Admins who can read it: %s\n", strings.Join(listed, ", "))
+	}
+	if len(unlisted) > 0 {
+		fmt.Printf("Admins who can't: %s\n", strings.Join(unlisted, ", "))
+	}
 	return nil
 }
 
+// printEffectiveRecipients lists the keys a secret is encrypted for on its
+// next save and checks them against the recipient stanzas in the file's age
+// header. The header doesn't name whose keys its stanzas are for, but a
+// count that differs means the file is readable by someone not listed, or
+// no longer by someone who is.
+func (a *Action) printEffectiveRecipients(rel string, keys []string) error {
+	userList, err := a.loadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+	byKey := make(map[string]string, len(userList.Users))
+	for _, u := range userList.Users {
+		byKey[u.PublicKey] = u.Email
+	}
+
+	fmt.Println()
+	ui.Heading("Effective recipients")
+	for _, key := range keys {
+		who, ok := byKey[key]
+		if !ok {
+			who = key + " (not a member)"
+		}
+		if key == a.cfg.Identity.PublicKey {
+			who += " (you)"
+		}
+		fmt.Printf("  %s\n", who)
+	}
+
+	data, err := os.ReadFile(filepath.Join(a.cfg.StorePath, filepath.FromSlash(rel)))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", rel, err)
+	}
+	n, err := age.CountRecipients(data)
+	if err != nil {
+		return fmt.Errorf("failed to read the age header of %s: %w", rel, err)
+	}
+	fmt.Println()
+	if n == len(keys) {
+		ui.Successf("The file's age header has %d recipient(s), matching the list", n)
+		return nil
+	}
+	ui.Warningf("the file's age header has %d recipient(s), not %d; it was last saved for a different set. Re-encrypt it to match with 'passbook reencrypt'", n, len(keys))
+	return nil
+}
+
+// printRecipientPermissions lists per-secret grants with when they end
+func printRecipientPermissions(perms []models.RecipientPermission) {
+	fmt.Printf("%-35s %-10s %s\n", "EMAIL", "ACCESS", "EXPIRES")
+	fmt.Printf("%-35s %-10s %s\n", "-----", "------", "-------")
+
+	var expired int
+	for _, perm := range perms {
+		expires := "never"
+		switch {
+		case perm.IsExpired():
+			expires = ui.Warn("expired " + perm.ExpiresAt.Format("2006-01-02"))
+			expired++
+		case !perm.ExpiresAt.IsZero():
+			expires = perm.ExpiresAt.Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%-35s %-10s %s\n", perm.Email, perm.Access, expires)
+	}
+	if expired > 0 {
+		fmt.Printf("\n%d expired grant(s) no longer count; remove them with 'passbook access expire'\n", expired)
+	}
+}
+
+// grantExpiry parses a grant's --expires flag, zero if it isn't set
+func grantExpiry(c *cli.Context) (time.Time, error) {
+	if c.String("expires") == "" {
+		return time.Time{}, nil
+	}
+	return parseExpiry(c.String("expires"))
+}
+
 // CredAccessGrant grants access to a credential
 func (a *Action) CredAccessGrant(c *cli.Context) error {
 	if c.NArg() < 2 {
@@ -96,22 +232,15 @@ func (a *Action) CredAccessGrant(c *cli.Context) error {
 	if !access.IsValid() {
 		return fmt.Errorf("invalid access level: %s (use 'read' or 'write')", level)
 	}
-
-	// Check permission - must have write access to grant access
-	currentUser, err := a.getCurrentUser()
+	expires, err := grantExpiry(c)
 	if err != nil {
-		return fmt.Errorf("failed to get current user: %w", err)
+		return err
 	}
 
-	canWrite := false
-	for _, role := range currentUser.Roles {
-		if role.CanWriteCredentials() {
-			canWrite = true
-			break
-		}
-	}
-	if !canWrite {
-		return fmt.Errorf("permission denied: you need write access to grant access")
+	// Check permission - must have write access to grant access
+	currentUser, err := a.authorize(rbac.PermCredentialsWrite)
+	if err != nil {
+		return err
 	}
 
 	// Find the target user
@@ -128,7 +257,7 @@ func (a *Action) CredAccessGrant(c *cli.Context) error {
 		}
 	}
 	if targetUser == nil {
-		return fmt.Errorf("user not found: %s", email)
+		return fmt.Errorf("user %s %w", email, ErrNotFound)
 	}
 
 	if targetUser.PublicKey == "" {
@@ -140,6 +269,9 @@ func (a *Action) CredAccessGrant(c *cli.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to load credential: %w", err)
 	}
+	if err := a.requireWrite(cred.CanUserWrite, website+"/"+name); err != nil {
+		return err
+	}
 
 	// Initialize permissions if needed
 	if cred.Permissions == nil {
@@ -148,7 +280,7 @@ func (a *Action) CredAccessGrant(c *cli.Context) error {
 	cred.Permissions.UseRoleBasedAccess = false
 
 	// Grant access
-	cred.Permissions.AddRecipient(email, targetUser.PublicKey, access)
+	cred.Permissions.AddRecipientUntil(email, targetUser.PublicKey, access, expires)
 
 	// Make sure current user has access too
 	if !cred.Permissions.HasRecipient(currentUser.Email) {
@@ -156,20 +288,39 @@ func (a *Action) CredAccessGrant(c *cli.Context) error {
 	}
 
 	// Save credential
-	if err := a.saveCredentialWithPermissions(c.Context, cred); err != nil {
+	if err := a.saveCredential(c.Context, cred); err != nil {
 		return fmt.Errorf("failed to save credential: %w", err)
 	}
 
+	a.logAudit(audit.EventAccessGranted, website+"/"+name, grantDetails(email, access, expires)...)
+
 	// Git commit
-	if err := a.GitCommitAndSync(fmt.Sprintf("Grant %s access to %s for %s/%s", access, email, website, name)); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Grant %s access to %s for %s/%s", access, email, website, name)); err != nil {
+		ui.Warningf("%v", err)
 	}
 
-	fmt.Printf("✓ Granted %s access to %s for %s/%s\n", access, email, website, name)
+	ui.Successf("Granted %s access to %s for %s/%s%s", access, email, website, name, grantUntil(expires))
 
 	return nil
 }
 
+// grantDetails are the audit details of a grant
+func grantDetails(email string, access models.AccessLevel, expires time.Time) []string {
+	details := []string{"user", email, "access", string(access)}
+	if !expires.IsZero() {
+		details = append(details, "expires", expires.Format(time.RFC3339))
+	}
+	return details
+}
+
+// grantUntil describes when a grant ends, for success messages
+func grantUntil(expires time.Time) string {
+	if expires.IsZero() {
+		return ""
+	}
+	return " until " + expires.Format("2006-01-02 15:04")
+}
+
 // CredAccessRevoke revokes access from a credential
 func (a *Action) CredAccessRevoke(c *cli.Context) error {
 	if c.NArg() < 2 {
@@ -185,20 +336,9 @@ func (a *Action) CredAccessRevoke(c *cli.Context) error {
 	}
 
 	// Check permission
-	currentUser, err := a.getCurrentUser()
+	currentUser, err := a.authorize(rbac.PermCredentialsWrite)
 	if err != nil {
-		return fmt.Errorf("failed to get current user: %w", err)
-	}
-
-	canWrite := false
-	for _, role := range currentUser.Roles {
-		if role.CanWriteCredentials() {
-			canWrite = true
-			break
-		}
-	}
-	if !canWrite {
-		return fmt.Errorf("permission denied: you need write access to revoke access")
+		return err
 	}
 
 	// Can't revoke your own access
@@ -211,6 +351,9 @@ func (a *Action) CredAccessRevoke(c *cli.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to load credential: %w", err)
 	}
+	if err := a.requireWrite(cred.CanUserWrite, website+"/"+name); err != nil {
+		return err
+	}
 
 	// Check if using per-secret permissions
 	if cred.Permissions == nil || cred.Permissions.UseRoleBasedAccess {
@@ -223,16 +366,18 @@ func (a *Action) CredAccessRevoke(c *cli.Context) error {
 	}
 
 	// Save credential
-	if err := a.saveCredentialWithPermissions(c.Context, cred); err != nil {
+	if err := a.saveCredential(c.Context, cred); err != nil {
 		return fmt.Errorf("failed to save credential: %w", err)
 	}
 
+	a.logAudit(audit.EventAccessRevoked, website+"/"+name, "user", email)
+
 	// Git commit
-	if err := a.GitCommitAndSync(fmt.Sprintf("Revoke access from %s for %s/%s", email, website, name)); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Revoke access from %s for %s/%s", email, website, name)); err != nil {
+		ui.Warningf("%v", err)
 	}
 
-	fmt.Printf("✓ Revoked access from %s for %s/%s\n", email, website, name)
+	ui.Successf("Revoked access from %s for %s/%s", email, website, name)
 
 	return nil
 }
@@ -250,8 +395,7 @@ func (a *Action) EnvAccessList(c *cli.Context) error {
 		return fmt.Errorf("invalid stage: %s (use dev, staging, or prod)", stage)
 	}
 
-	fmt.Printf("Access for environment: %s/%s\n", project, stage)
-	fmt.Println("========================================")
+	ui.Heading(fmt.Sprintf("Access for environment: %s/%s", project, stage))
 	fmt.Println()
 
 	// Try to load env file
@@ -276,18 +420,13 @@ func (a *Action) EnvAccessList(c *cli.Context) error {
 		fmt.Printf("%-35s %-15s %-10s\n", "EMAIL", "ROLE", "ACCESS")
 		fmt.Printf("%-35s %-15s %-10s\n", "-----", "----", "------")
 
+		engine := a.policy()
 		for _, user := range userList.Users {
 			// Check if user can access this stage
-			canAccess := false
-			highestRole := ""
-			for _, role := range user.Roles {
-				if role.CanAccessStage(stage) {
-					canAccess = true
-					highestRole = string(role)
-				}
-			}
+			d := engine.Explain(&user, rbac.GetStagePermission(stage, false))
+			highestRole := string(d.Role)
 
-			if canAccess {
+			if d.Allowed {
 				email := user.Email
 				if user.PublicKey == a.cfg.Identity.PublicKey {
 					email += " (you)"
@@ -299,12 +438,25 @@ func (a *Action) EnvAccessList(c *cli.Context) error {
 		fmt.Println("Using per-secret access control")
 		fmt.Println()
 
-		fmt.Printf("%-35s %-10s\n", "EMAIL", "ACCESS")
-		fmt.Printf("%-35s %-10s\n", "-----", "------")
+		printRecipientPermissions(envFile.Permissions.Recipients)
+		if err := a.printImplicitRecipients(envFile.Permissions); err != nil {
+			return err
+		}
+	}
 
-		for _, perm := range envFile.Permissions.Recipients {
-			fmt.Printf("%-35s %-10s\n", perm.Email, perm.Access)
+	if c.Bool("effective") {
+		if envFile == nil {
+			return fmt.Errorf("environment %s/%s %w", project, stage, ErrNotFound)
 		}
+		s, err := a.openStore()
+		if err != nil {
+			return err
+		}
+		keys, err := s.EnvRecipients(envFile)
+		if err != nil {
+			return err
+		}
+		return a.printEffectiveRecipients(envFile.FullPath(), keys)
 	}
 
 	return nil
@@ -330,22 +482,15 @@ func (a *Action) EnvAccessGrant(c *cli.Context) error {
 	if !access.IsValid() {
 		return fmt.Errorf("invalid access level: %s (use 'read' or 'write')", level)
 	}
-
-	// Check permission - must have access to this stage
-	currentUser, err := a.getCurrentUser()
+	expires, err := grantExpiry(c)
 	if err != nil {
-		return fmt.Errorf("failed to get current user: %w", err)
+		return err
 	}
 
-	hasAccess := false
-	for _, role := range currentUser.Roles {
-		if role.CanAccessStage(stage) {
-			hasAccess = true
-			break
-		}
-	}
-	if !hasAccess {
-		return fmt.Errorf("permission denied: you don't have access to %s stage", stage)
+	// Check permission - must have access to this stage or own the project
+	currentUser, err := a.authorizeEnvAccess(project, stage)
+	if err != nil {
+		return err
 	}
 
 	// Find the target user
@@ -362,7 +507,7 @@ func (a *Action) EnvAccessGrant(c *cli.Context) error {
 		}
 	}
 	if targetUser == nil {
-		return fmt.Errorf("user not found: %s", email)
+		return fmt.Errorf("user %s %w", email, ErrNotFound)
 	}
 
 	if targetUser.PublicKey == "" {
@@ -381,6 +526,9 @@ func (a *Action) EnvAccessGrant(c *cli.Context) error {
 			UpdatedBy: currentUser.Email,
 		}
 	}
+	if err := a.requireWrite(envFile.CanUserWrite, fmt.Sprintf("%s/%s", project, stage)); err != nil {
+		return err
+	}
 
 	// Initialize permissions if needed
 	if envFile.Permissions == nil {
@@ -389,7 +537,7 @@ func (a *Action) EnvAccessGrant(c *cli.Context) error {
 	envFile.Permissions.UseRoleBasedAccess = false
 
 	// Grant access
-	envFile.Permissions.AddRecipient(email, targetUser.PublicKey, access)
+	envFile.Permissions.AddRecipientUntil(email, targetUser.PublicKey, access, expires)
 
 	// Make sure current user has access too
 	if !envFile.Permissions.HasRecipient(currentUser.Email) {
@@ -397,16 +545,18 @@ func (a *Action) EnvAccessGrant(c *cli.Context) error {
 	}
 
 	// Save env file
-	if err := a.saveEnvFileWithPermissions(c.Context, envFile); err != nil {
+	if err := a.saveEnvFile(c.Context, envFile); err != nil {
 		return fmt.Errorf("failed to save environment: %w", err)
 	}
 
+	a.logAudit(audit.EventAccessGranted, fmt.Sprintf("%s/%s", project, stage), grantDetails(email, access, expires)...)
+
 	// Git commit
-	if err := a.GitCommitAndSync(fmt.Sprintf("Grant %s access to %s for %s/%s", access, email, project, stage)); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Grant %s access to %s for %s/%s", access, email, project, stage)); err != nil {
+		ui.Warningf("%v", err)
 	}
 
-	fmt.Printf("✓ Granted %s access to %s for %s/%s\n", access, email, project, stage)
+	ui.Successf("Granted %s access to %s for %s/%s%s", access, email, project, stage, grantUntil(expires))
 
 	return nil
 }
@@ -425,21 +575,10 @@ func (a *Action) EnvAccessRevoke(c *cli.Context) error {
 		return fmt.Errorf("invalid stage: %s (use dev, staging, or prod)", stage)
 	}
 
-	// Check permission
-	currentUser, err := a.getCurrentUser()
+	// Check permission - must have access to this stage or own the project
+	currentUser, err := a.authorizeEnvAccess(project, stage)
 	if err != nil {
-		return fmt.Errorf("failed to get current user: %w", err)
-	}
-
-	hasAccess := false
-	for _, role := range currentUser.Roles {
-		if role.CanAccessStage(stage) {
-			hasAccess = true
-			break
-		}
-	}
-	if !hasAccess {
-		return fmt.Errorf("permission denied: you don't have access to %s stage", stage)
+		return err
 	}
 
 	// Can't revoke your own access
@@ -452,6 +591,9 @@ func (a *Action) EnvAccessRevoke(c *cli.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to load environment: %w", err)
 	}
+	if err := a.requireWrite(envFile.CanUserWrite, fmt.Sprintf("%s/%s", project, stage)); err != nil {
+		return err
+	}
 
 	// Check if using per-secret permissions
 	if envFile.Permissions == nil || envFile.Permissions.UseRoleBasedAccess {
@@ -464,25 +606,142 @@ func (a *Action) EnvAccessRevoke(c *cli.Context) error {
 	}
 
 	// Save env file
-	if err := a.saveEnvFileWithPermissions(c.Context, envFile); err != nil {
+	if err := a.saveEnvFile(c.Context, envFile); err != nil {
 		return fmt.Errorf("failed to save environment: %w", err)
 	}
 
+	a.logAudit(audit.EventAccessRevoked, fmt.Sprintf("%s/%s", project, stage), "user", email)
+
 	// Git commit
-	if err := a.GitCommitAndSync(fmt.Sprintf("Revoke access from %s for %s/%s", email, project, stage)); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Revoke access from %s for %s/%s", email, project, stage)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Revoked access from %s for %s/%s", email, project, stage)
+
+	return nil
+}
+
+// AccessExpire removes per-secret grants that have run out and re-encrypts
+// the credentials and environments they were on, so the people they were
+// for can't read later changes. Meant to run on a schedule, e.g. from cron.
+func (a *Action) AccessExpire(c *cli.Context) error {
+	dryRun := c.Bool("dry-run")
+	if _, err := a.authorize(rbac.PermStoreReencrypt); err != nil {
+		return err
+	}
+
+	s, err := a.openStore()
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("✓ Revoked access from %s for %s/%s\n", email, project, stage)
+	var changed, held, readOnly []string
+	expire := func(target, rel string, perms *models.SecretPermissions, save func() error) error {
+		if perms == nil {
+			return nil
+		}
+		var expired []string
+		for _, perm := range perms.Recipients {
+			if perm.IsExpired() {
+				expired = append(expired, perm.Email)
+			}
+		}
+		if len(expired) == 0 {
+			return nil
+		}
+		fmt.Printf("  %s: %s\n", target, strings.Join(expired, ", "))
+		if dryRun {
+			changed = append(changed, target)
+			return nil
+		}
+		if err := a.checkHold(rel, "re-encrypt"); err != nil {
+			held = append(held, target)
+			return nil
+		}
+		perms.RemoveExpired()
+		if err := save(); errors.Is(err, ErrAccessDenied) {
+			readOnly = append(readOnly, target)
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to save %s: %w", target, err)
+		}
+		for _, email := range expired {
+			a.logAudit(audit.EventAccessExpired, target, "user", email)
+		}
+		changed = append(changed, target)
+		return nil
+	}
 
+	creds, err := a.loadAllCredentials(c.Context)
+	if err != nil {
+		return err
+	}
+	for _, cred := range creds {
+		err := expire(cred.Website+"/"+cred.Name, cred.FullPath(), cred.Permissions, func() error {
+			return a.saveCredential(c.Context, cred)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	projects, err := s.ListProjects(c.Context)
+	if err != nil {
+		return fmt.Errorf("failed to list projects: %w", err)
+	}
+	for _, p := range projects {
+		stages, err := s.ListEnvStages(c.Context, p.Name)
+		if err != nil {
+			continue
+		}
+		for _, stage := range stages {
+			envFile, err := s.GetEnvFile(c.Context, p.Name, stage)
+			if err != nil {
+				continue // Not a recipient; whoever is can expire it
+			}
+			err = expire(fmt.Sprintf("%s/%s", p.Name, stage), envFile.FullPath(), envFile.Permissions, func() error {
+				return a.saveEnvFile(c.Context, envFile)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, target := range held {
+		ui.Warningf("%s is under legal hold; its expired grants were left in place", target)
+	}
+	for _, target := range readOnly {
+		ui.Warningf("you have read-only access to %s; someone who can write it must expire its grants", target)
+	}
+	if len(changed) == 0 {
+		fmt.Println("No expired grants")
+		return nil
+	}
+	if dryRun {
+		fmt.Printf("\n%d secret(s) have expired grants; run without --dry-run to remove them\n", len(changed))
+		return nil
+	}
+
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Expire access grants on %d secret(s)", len(changed))); err != nil {
+		ui.Warningf("%v", err)
+	}
+	ui.Successf("Removed expired grants and re-encrypted %d secret(s)", len(changed))
 	return nil
 }
 
-// parseCredentialPath parses "website/name" into separate parts
+// parseCredentialPath parses "website/name" into separate parts. Backslashes
+// are accepted on Windows, where they are the natural separator.
 func parseCredentialPath(path string) (website, name string, err error) {
-	parts := strings.SplitN(path, "/", 2)
-	if len(parts) != 2 {
+	parts := strings.SplitN(filepath.ToSlash(path), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
 		return "", "", fmt.Errorf("invalid path format, expected WEBSITE/NAME")
 	}
+	for _, part := range parts {
+		if part == "." || part == ".." || strings.ContainsAny(part, "/\\") {
+			return "", "", fmt.Errorf("invalid path format, expected WEBSITE/NAME")
+		}
+	}
 	return parts[0], parts[1], nil
 }
@@ -1,18 +1,22 @@
 package action
 
 import (
+	"fmt"
+
+	"passbook/internal/audit"
+	"passbook/internal/backend/crypto/age"
 	"passbook/internal/config"
+	"passbook/internal/store"
+	"passbook/pkg/ui"
 )
 
 // Action provides CLI command handlers
 type Action struct {
 	cfg   *config.Config
-	store Store
-}
+	store *store.Store
 
-// Store interface for data operations
-type Store interface {
-	// Will be implemented by store package
+	// ticket is the ticket ID given for this run's commits, once asked for
+	ticket *string
 }
 
 // New creates a new Action handler with full initialization
@@ -20,6 +24,7 @@ func New(cfg *config.Config) (*Action, error) {
 	a := &Action{
 		cfg: cfg,
 	}
+	ui.SetColor(cfg.Preferences.Color)
 
 	if !cfg.IsInitialized() {
 		return nil, ErrNotInitialized
@@ -28,13 +33,53 @@ func New(cfg *config.Config) (*Action, error) {
 	return a, nil
 }
 
+// NewWithStore creates an Action handler backed by an already opened store,
+// for callers that embed passbook or need their own storage or crypto setup
+func NewWithStore(cfg *config.Config, s *store.Store) (*Action, error) {
+	a, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	a.store = s
+	return a, nil
+}
+
 // NewBasic creates a basic Action handler for setup commands
 func NewBasic(cfg *config.Config) *Action {
+	ui.SetColor(cfg.Preferences.Color)
 	return &Action{
 		cfg: cfg,
 	}
 }
 
+// openStore returns the store, opening it on first use so commands that
+// never touch it don't load the identity
+func (a *Action) openStore() (*store.Store, error) {
+	if a.store == nil {
+		s, err := store.New(a.cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open store: %w", err)
+		}
+		// Keep what we save readable to us, but record when that's more
+		// than the secret's recipients allow
+		s.IncludeSelf(func(path string) {
+			ui.Warningf("%s is also encrypted for you, which its permissions don't cover", path)
+			a.logAudit(audit.EventSelfAdded, path)
+		})
+		a.store = s
+	}
+	return a.store, nil
+}
+
+// crypto returns the crypto backend of the store
+func (a *Action) crypto() (*age.Age, error) {
+	s, err := a.openStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.Crypto(), nil
+}
+
 // Config returns the current configuration
 func (a *Action) Config() *config.Config {
 	return a.cfg
@@ -0,0 +1,165 @@
+package action
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"passbook/internal/audit"
+)
+
+const (
+	// spikeFactor is how many times an actor's usual daily reads makes a
+	// day unusual
+	spikeFactor = 3
+	// spikeMinimum is the fewest reads in a day that can be unusual, so
+	// light users aren't flagged for reading a handful of secrets
+	spikeMinimum = 10
+	// preRevocationWindow is how long before losing access reads are
+	// flagged, since people leaving sometimes take secrets with them
+	preRevocationWindow = 72 * time.Hour
+)
+
+// anomaly is a pattern in the audit log worth a closer look. They're
+// hints, not proof of misuse.
+type anomaly struct {
+	kind   string
+	actor  string
+	when   time.Time
+	detail string
+}
+
+// findAnomalies looks for unusually many reads in a day, prod reads
+// outside working hours, and reads shortly before a member was removed
+func findAnomalies(events []audit.Event, workStart, workEnd int) []anomaly {
+	var found []anomaly
+
+	// Reads per actor per day
+	daily := make(map[string]map[string]int)
+	firstRead := make(map[string]map[string]time.Time)
+	for _, e := range events {
+		if eventKind(e.Type) != "read" {
+			continue
+		}
+		day := e.Timestamp.Local().Format("2006-01-02")
+		if daily[e.Actor] == nil {
+			daily[e.Actor] = make(map[string]int)
+			firstRead[e.Actor] = make(map[string]time.Time)
+		}
+		daily[e.Actor][day]++
+		if _, ok := firstRead[e.Actor][day]; !ok {
+			firstRead[e.Actor][day] = e.Timestamp
+		}
+
+		if isProdTarget(e.Target) {
+			if hour := e.Timestamp.Local().Hour(); hour < workStart || hour >= workEnd {
+				found = append(found, anomaly{
+					kind:   "off-hours prod read",
+					actor:  e.Actor,
+					when:   e.Timestamp,
+					detail: fmt.Sprintf("%s at %s", e.Target, e.Timestamp.Local().Format("15:04")),
+				})
+			}
+		}
+	}
+
+	for actor, days := range daily {
+		total := 0
+		for _, n := range days {
+			total += n
+		}
+		for day, n := range days {
+			// Compare with the actor's other days, so one huge day doesn't
+			// raise its own bar
+			others := len(days) - 1
+			usual := 0.0
+			if others > 0 {
+				usual = float64(total-n) / float64(others)
+			}
+			if n >= spikeMinimum && float64(n) > spikeFactor*usual {
+				detail := fmt.Sprintf("%d reads on %s", n, day)
+				if others > 0 {
+					detail += fmt.Sprintf(", usually %.0f a day", usual)
+				}
+				found = append(found, anomaly{kind: "read spike", actor: actor, when: firstRead[actor][day], detail: detail})
+			}
+		}
+	}
+
+	// Reads by members shortly before they were removed
+	for _, removal := range events {
+		if removal.Type != audit.EventUserRemoved {
+			continue
+		}
+		var targets []string
+		var first time.Time
+		for _, e := range events {
+			if actorEmail(e.Actor) != removal.Target || eventKind(e.Type) != "read" {
+				continue
+			}
+			if e.Timestamp.Before(removal.Timestamp.Add(-preRevocationWindow)) || e.Timestamp.After(removal.Timestamp) {
+				continue
+			}
+			if first.IsZero() {
+				first = e.Timestamp
+			}
+			targets = append(targets, e.Target)
+		}
+		if len(targets) == 0 {
+			continue
+		}
+		found = append(found, anomaly{
+			kind:   "read before removal",
+			actor:  removal.Target,
+			when:   first,
+			detail: fmt.Sprintf("%d read(s) in the %d days before removal on %s: %s", len(targets), int(preRevocationWindow.Hours()/24), removal.Timestamp.Local().Format("2006-01-02"), summarizeTargets(targets)),
+		})
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].when.After(found[j].when)
+	})
+	return found
+}
+
+// actorEmail returns the member behind an audit actor, which is prefixed
+// for externals and service accounts and names the token a service account
+// used
+func actorEmail(actor string) string {
+	actor, _, _ = strings.Cut(actor, " (")
+	for _, prefix := range []string{"external:", "service-account:"} {
+		actor = strings.TrimPrefix(actor, prefix)
+	}
+	return actor
+}
+
+// isProdTarget reports whether an audit target is a prod environment
+func isProdTarget(target string) bool {
+	return strings.HasSuffix(target, "/prod")
+}
+
+// summarizeTargets lists distinct targets, shortening long lists
+func summarizeTargets(targets []string) string {
+	seen := make(map[string]bool)
+	var distinct []string
+	for _, t := range targets {
+		if !seen[t] {
+			seen[t] = true
+			distinct = append(distinct, t)
+		}
+	}
+	if len(distinct) > 3 {
+		return fmt.Sprintf("%s and %d more", strings.Join(distinct[:3], ", "), len(distinct)-3)
+	}
+	return strings.Join(distinct, ", ")
+}
+
+// parseWorkHours parses working hours such as "7-20"
+func parseWorkHours(s string) (int, int, error) {
+	var start, end int
+	if _, err := fmt.Sscanf(s, "%d-%d", &start, &end); err != nil || start < 0 || end > 24 || start >= end {
+		return 0, 0, fmt.Errorf("%w: %q is not working hours like 7-20", ErrInvalidInput, s)
+	}
+	return start, end, nil
+}
@@ -1,12 +1,18 @@
 package action
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/urfave/cli/v2"
 
 	"passbook/internal/audit"
+	"passbook/internal/notify"
+	"passbook/pkg/ui"
 )
 
 // AuditLog shows audit log entries
@@ -36,22 +42,53 @@ func (a *Action) AuditLog(c *cli.Context) error {
 	}
 
 	if since := c.String("since"); since != "" {
-		if d, err := time.ParseDuration(since); err == nil {
-			filter.StartTime = time.Now().Add(-d)
-		} else if t, err := time.Parse("2006-01-02", since); err == nil {
-			filter.StartTime = t
+		if filter.StartTime, err = parseTimeBound(since, false); err != nil {
+			return err
 		}
 	}
+	if until := c.String("until"); until != "" {
+		if filter.EndTime, err = parseTimeBound(until, true); err != nil {
+			return err
+		}
+	}
+	if !filter.StartTime.IsZero() && !filter.EndTime.IsZero() && filter.EndTime.Before(filter.StartTime) {
+		return fmt.Errorf("%w: --until is before --since", ErrInvalidInput)
+	}
+
+	limit := c.Int("limit")
+	if limit < 1 {
+		return fmt.Errorf("%w: --limit must be at least 1", ErrInvalidInput)
+	}
+	page := c.Int("page")
+	if page < 1 {
+		return fmt.Errorf("%w: --page must be at least 1", ErrInvalidInput)
+	}
 
-	if limit := c.Int("limit"); limit > 0 {
-		filter.Limit = limit
-	} else {
-		filter.Limit = 50 // Default
+	// A cursor is where the previous page stopped; pages are found by
+	// reading past the ones before
+	var before int64
+	if cursor := c.String("cursor"); cursor != "" {
+		if c.IsSet("page") {
+			return fmt.Errorf("%w: use --page or --cursor, not both", ErrInvalidInput)
+		}
+		if before, err = strconv.ParseInt(cursor, 36, 64); err != nil || before <= 0 {
+			return fmt.Errorf("%w: invalid cursor %q", ErrInvalidInput, cursor)
+		}
+	}
+	for i := 1; i < page; i++ {
+		if _, before, err = logger.ReadBackward(filter, before, limit); err != nil {
+			return err
+		}
+		if before == 0 {
+			fmt.Printf("No audit events on page %d.\n", page)
+			return nil
+		}
 	}
 
-	events, err := logger.GetEvents(filter)
+	// Most recent first
+	events, next, err := logger.ReadBackward(filter, before, limit)
 	if err != nil {
-		return fmt.Errorf("failed to read audit log: %w", err)
+		return err
 	}
 
 	if len(events) == 0 {
@@ -59,29 +96,70 @@ func (a *Action) AuditLog(c *cli.Context) error {
 		return nil
 	}
 
-	fmt.Println("Audit Log")
-	fmt.Println("=========")
+	ui.Heading("Audit Log")
 	fmt.Println()
 
-	// Show most recent first, but respect limit
-	start := 0
-	if len(events) > filter.Limit {
-		start = len(events) - filter.Limit
+	for _, e := range events {
+		line := audit.FormatEvent(e)
+		if e.Type == audit.EventSensitiveAccess {
+			line = ui.Warn(line)
+		}
+		fmt.Println(line)
 	}
 
-	for i := len(events) - 1; i >= start; i-- {
-		fmt.Println(audit.FormatEvent(events[i]))
+	if next != 0 {
+		fmt.Printf("\n(Older events: add --cursor %s, or --page %d)\n", strconv.FormatInt(next, 36), page+1)
 	}
 
-	if len(events) > filter.Limit {
-		fmt.Printf("\n(Showing %d of %d events. Use --limit to see more)\n", filter.Limit, len(events))
+	return nil
+}
+
+// parseTimeBound parses one end of a time range: a window back from now
+// such as 30d, 2w or 12h, a date, an RFC 3339 time, or today, yesterday or
+// now. A date as the end of a range includes that whole day.
+func parseTimeBound(s string, end bool) (time.Time, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	var day time.Time
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "now":
+		return now, nil
+	case "today":
+		day = today
+	case "yesterday":
+		day = today.AddDate(0, 0, -1)
+	default:
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t, nil
+		}
+		if t, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local); err == nil {
+			return t, nil
+		}
+		if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+			day = t
+			break
+		}
+		d, err := parseWindow(s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: %q is not a time like 30d, 2w, 2026-01-31, 2026-01-31T09:00:00Z or yesterday", ErrInvalidInput, s)
+		}
+		return now.Add(-d), nil
 	}
 
-	return nil
+	if end {
+		return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	return day, nil
 }
 
 // AuditStats shows audit statistics
 func (a *Action) AuditStats(c *cli.Context) error {
+	workStart, workEnd, err := parseWorkHours(c.String("work-hours"))
+	if err != nil {
+		return err
+	}
+
 	currentUser, err := a.getCurrentUser()
 	actorEmail := ""
 	if err == nil {
@@ -117,8 +195,7 @@ func (a *Action) AuditStats(c *cli.Context) error {
 		}
 	}
 
-	fmt.Println("Audit Statistics")
-	fmt.Println("================")
+	ui.Heading("Audit Statistics")
 	fmt.Println()
 	fmt.Printf("Total events: %d\n", len(events))
 	fmt.Printf("Time range:   %s to %s\n",
@@ -127,27 +204,165 @@ func (a *Action) AuditStats(c *cli.Context) error {
 	fmt.Println()
 
 	fmt.Println("Events by type:")
+	byType := ui.NewTable()
 	for eventType, count := range eventCounts {
-		fmt.Printf("  %-25s %d\n", eventType, count)
+		byType.Row(string(eventType), fmt.Sprintf("%d", count))
 	}
+	byType.Print()
 	fmt.Println()
 
 	fmt.Println("Events by actor:")
+	byActor := ui.NewTable()
 	for actor, count := range actorCounts {
-		fmt.Printf("  %-30s %d\n", actor, count)
+		byActor.Row(actor, fmt.Sprintf("%d", count))
+	}
+	byActor.Print()
+
+	if !c.Bool("anomalies") {
+		return nil
+	}
+	fmt.Println()
+	anomalies := findAnomalies(events, workStart, workEnd)
+	if len(anomalies) == 0 {
+		ui.Successf("No anomalies found")
+		return nil
+	}
+	fmt.Printf("Anomalies (hints worth a look, not proof of misuse):\n")
+	table := ui.NewTable("WHEN", "KIND", "ACTOR", "DETAIL")
+	for _, an := range anomalies {
+		table.Row(an.when.Local().Format("2006-01-02 15:04"), ui.Warn(an.kind), an.actor, an.detail)
+	}
+	table.Print()
+
+	return nil
+}
+
+// AuditStale flags credentials and env files that haven't been updated
+// within a window. Ones nobody has read in that time are likely unused and
+// can be cleaned up; ones still read should be rotated.
+func (a *Action) AuditStale(c *cli.Context) error {
+	days := c.Int("days")
+	if days < 1 {
+		return fmt.Errorf("usage: --days must be at least 1")
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	// Last update of each secret, from git history
+	files, updated, err := gitLastChanged(a.cfg.StorePath, "credentials", "projects")
+	if err != nil {
+		return fmt.Errorf("failed to read git history: %w", err)
+	}
+
+	// Last read of each secret, from the audit log
+	events, err := a.getAuditLogger().GetEvents(&audit.EventFilter{
+		Types: []audit.EventType{audit.EventCredentialAccess, audit.EventSensitiveAccess, audit.EventEnvAccess},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	lastRead := make(map[string]time.Time)
+	for _, e := range events {
+		if e.Timestamp.After(lastRead[e.Target]) {
+			lastRead[e.Target] = e.Timestamp
+		}
+	}
+
+	type staleSecret struct {
+		name    string
+		updated time.Time
+		read    time.Time
+	}
+	var stale []staleSecret
+	for _, file := range files {
+		name, ok := secretName(file)
+		if !ok {
+			continue
+		}
+		s := staleSecret{name: name, updated: time.Unix(updated[file], 0), read: lastRead[name]}
+		if s.updated.Before(cutoff) {
+			stale = append(stale, s)
+		}
+	}
+
+	if len(stale) == 0 {
+		ui.Successf("Every secret was updated in the last %d days", days)
+		return nil
+	}
+	sort.Slice(stale, func(i, j int) bool {
+		return stale[i].updated.Before(stale[j].updated)
+	})
+
+	ui.Heading(fmt.Sprintf("Secrets not updated in %d days", days))
+	fmt.Println()
+
+	unused := 0
+	table := ui.NewTable("SECRET", "LAST UPDATED", "LAST READ", "RECOMMENDATION")
+	for _, s := range stale {
+		read := ui.Muted("never recorded")
+		if !s.read.IsZero() {
+			read = s.read.Format("2006-01-02")
+		}
+		recommendation := "rotate"
+		if s.read.Before(cutoff) {
+			recommendation = ui.Warn("remove if unused")
+			unused++
+		}
+		table.Row(s.name, s.updated.Format("2006-01-02"), read, recommendation)
+	}
+	table.Print()
+
+	fmt.Println()
+	fmt.Printf("%d stale, %d not read in %d days\n", len(stale), unused, days)
+	if len(lastRead) == 0 {
+		fmt.Println("Note: the audit log has no reads recorded, so read times are unknown.")
 	}
 
 	return nil
 }
 
+// secretName maps a store file to the name audit events use for it:
+// credentials/WEBSITE/NAME.age to WEBSITE/NAME, projects/PROJECT/STAGE.env.age
+// to PROJECT/STAGE
+func secretName(file string) (string, bool) {
+	parts := strings.Split(file, "/")
+	if len(parts) != 3 {
+		return "", false
+	}
+	switch parts[0] {
+	case "credentials":
+		return parts[1] + "/" + strings.TrimSuffix(parts[2], ".age"), true
+	case "projects":
+		return parts[1] + "/" + strings.TrimSuffix(parts[2], ".env.age"), true
+	}
+	return "", false
+}
+
 // getAuditLogger creates an audit logger for the current user
 func (a *Action) getAuditLogger() *audit.Logger {
 	currentUser, err := a.getCurrentUser()
 	actorEmail := "unknown"
 	if err == nil {
-		actorEmail = currentUser.Email
+		actorEmail = currentUser.AuditName()
+	}
+	logger := audit.NewLogger(a.cfg.StorePath, actorEmail)
+	logger.OnEvent(a.emitEvent)
+	return logger
+}
+
+// emitEvent sends a change to the store to the configured event sinks, so
+// integrations can react to it. Delivery failures only warn.
+func (a *Action) emitEvent(e audit.Event) {
+	if !e.Type.IsMutation() {
+		return
+	}
+	sinks := notify.Sinks{URL: a.cfg.Notify.EventsURL, Command: a.cfg.Events.Command, File: a.cfg.EventsFilePath()}
+	if sinks.Empty() {
+		return
+	}
+
+	if err := sinks.Deliver(context.Background(), notify.EventMessage(e)); err != nil {
+		ui.Warningf("event %s not delivered: %v", e.Type, err)
 	}
-	return audit.NewLogger(a.cfg.StorePath, actorEmail)
 }
 
 // logAudit is a helper to log audit events
@@ -155,6 +370,6 @@ func (a *Action) logAudit(eventType audit.EventType, target string, details ...s
 	logger := a.getAuditLogger()
 	if err := logger.LogWithDetails(eventType, target, details...); err != nil {
 		// Log errors silently - don't fail operations due to audit logging
-		fmt.Printf("Warning: failed to log audit event: %v\n", err)
+		ui.Warningf("failed to log audit event: %v", err)
 	}
 }
@@ -1,73 +1,372 @@
 package action
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
 
+	"passbook/internal/audit"
 	"passbook/internal/auth"
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/pkg/ui"
 )
 
-// WhoAmI shows the current user
+// whoamiReport is the effective access of the current user, as printed by
+// 'passbook whoami' and 'passbook whoami --json'
+type whoamiReport struct {
+	Email          string            `json:"email,omitempty"`
+	GitHub         string            `json:"github,omitempty"`
+	GitHubBound    string            `json:"github_bound,omitempty"` // Account bound to the team record
+	InTeam         bool              `json:"in_team"`
+	Roles          []models.Role     `json:"roles"`
+	Admin          bool              `json:"admin"`
+	ServiceAccount bool              `json:"service_account"`
+	External       bool              `json:"external,omitempty"`
+	ExpiresAt      *time.Time        `json:"expires_at,omitempty"` // When an external collaborator's access ends
+	ReadOnly       bool              `json:"read_only"`
+	Permissions    []rbac.Permission `json:"permissions"`
+	Stages         []stageAccess     `json:"stages"`
+	Projects       []projectAccess   `json:"projects,omitempty"`
+	Grants         []secretGrant     `json:"grants,omitempty"`
+	Key            keyStatus         `json:"key"`
+}
+
+type stageAccess struct {
+	Stage models.Stage `json:"stage"`
+	Read  bool         `json:"read"`
+	Write bool         `json:"write"`
+}
+
+type projectAccess struct {
+	Name        string         `json:"name"`
+	Decryptable []models.Stage `json:"decryptable"`
+	Denied      []models.Stage `json:"denied,omitempty"`
+}
+
+type secretGrant struct {
+	Secret string             `json:"secret"`
+	Access models.AccessLevel `json:"access"`
+}
+
+type keyStatus struct {
+	PublicKey   string `json:"public_key,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Path        string `json:"path"`
+	Present     bool   `json:"present"`
+	Encrypted   bool   `json:"encrypted"`
+	Registered  bool   `json:"registered"` // Team lists this exact key
+	Pending     bool   `json:"pending_verification"`
+}
+
+// WhoAmI shows the current user and their effective access
 func (a *Action) WhoAmI(c *cli.Context) error {
-	fmt.Println("Current User")
-	fmt.Println("============")
+	report := a.buildWhoami(c.Context, !c.Bool("no-decrypt"))
 
-	// Check GitHub auth status
-	githubAuth := auth.NewGitHubAuth(a.cfg.ConfigDir, a.cfg.Org.AllowedDomain)
-	if session, err := githubAuth.LoadSession(); err == nil && session != nil {
-		fmt.Printf("GitHub:     @%s (%s)\n", session.GitHubLogin, session.Email)
+	if c.Bool("json") {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
 	}
 
-	// Try to get user from team list (by public key)
-	user, err := a.getCurrentUser()
-	if err == nil {
-		fmt.Printf("Email:      %s\n", user.Email)
-
-		// Show roles
-		roles := ""
-		for i, r := range user.Roles {
-			if i > 0 {
-				roles += ", "
+	ui.Heading("Current User")
+
+	switch {
+	case report.GitHub != "" && report.GitHubBound == "@"+report.GitHub:
+		fmt.Printf("GitHub:     @%s (bound)\n", report.GitHub)
+	case report.GitHub != "":
+		fmt.Printf("GitHub:     @%s (logged in)\n", report.GitHub)
+		if report.GitHubBound != "" {
+			fmt.Printf("Bound to:   %s\n", report.GitHubBound)
+		}
+	case report.GitHubBound != "":
+		fmt.Printf("GitHub:     %s (bound, not logged in)\n", report.GitHubBound)
+	}
+
+	if !report.InTeam {
+		if report.Key.PublicKey != "" {
+			fmt.Printf("Public Key: %s\n", truncateKey(report.Key.PublicKey))
+			fmt.Println("\nYour key is not in the team yet.")
+			fmt.Println("Ask an admin to invite you, or run 'passbook init' to start a new store.")
+			return nil
+		}
+		fmt.Println("Not configured")
+		fmt.Println("\nRun 'passbook init' or 'passbook clone' to get started")
+		return nil
+	}
+
+	fmt.Printf("Email:      %s\n", report.Email)
+	fmt.Printf("Roles:      %s\n", formatRoles(report.Roles))
+	switch {
+	case report.Admin:
+		fmt.Printf("Status:     Admin\n")
+	case report.ServiceAccount:
+		fmt.Printf("Status:     Service account\n")
+	case report.External:
+		expiry := "until " + report.ExpiresAt.Format("2006-01-02")
+		if time.Now().After(*report.ExpiresAt) {
+			expiry = "expired " + report.ExpiresAt.Format("2006-01-02")
+		}
+		fmt.Printf("Status:     External collaborator (%s)\n", expiry)
+	case report.ReadOnly:
+		fmt.Printf("Status:     Read-only\n")
+	}
+	fmt.Printf("Public Key: %s\n", truncateKey(report.Key.PublicKey))
+	fmt.Println()
+
+	// Key status
+	fmt.Println("Key:")
+	keyState := "missing"
+	if report.Key.Present {
+		keyState = "unencrypted"
+		if report.Key.Encrypted {
+			keyState = "passphrase-protected"
+		}
+	}
+	fmt.Printf("  File:        %s (%s)\n", report.Key.Path, keyState)
+	if report.Key.Fingerprint != "" {
+		fmt.Printf("  Fingerprint: %s\n", report.Key.Fingerprint)
+	}
+	switch {
+	case report.Key.Pending:
+		fmt.Println("  Team:        pending verification (you can't decrypt anything yet)")
+	case report.Key.Registered:
+		fmt.Println("  Team:        registered")
+	default:
+		fmt.Println("  Team:        MISMATCH - the team lists a different key for you")
+	}
+	fmt.Println()
+
+	// Permissions and stages
+	fmt.Println("Permissions:")
+	for _, p := range report.Permissions {
+		fmt.Printf("  %s\n", p)
+	}
+	if len(report.Permissions) == 0 {
+		fmt.Println("  (none)")
+	}
+	fmt.Println()
+
+	fmt.Println("Stages:")
+	stages := ui.NewTable()
+	for _, s := range report.Stages {
+		access := "-"
+		switch {
+		case s.Write:
+			access = "read, write"
+		case s.Read:
+			access = "read"
+		}
+		stages.Row(string(s.Stage), access)
+	}
+	stages.Print()
+	fmt.Println()
+
+	if len(report.Projects) > 0 {
+		fmt.Println("Projects:")
+		projects := ui.NewTable()
+		for _, p := range report.Projects {
+			decryptable := joinStages(p.Decryptable)
+			if len(p.Decryptable) == 0 {
+				decryptable = "-"
 			}
-			roles += string(r)
+			if len(p.Denied) > 0 {
+				decryptable += ui.Muted(fmt.Sprintf("  (cannot decrypt: %s)", joinStages(p.Denied)))
+			}
+			projects.Row(p.Name, decryptable)
+		}
+		projects.Print()
+		fmt.Println()
+	}
+
+	if len(report.Grants) > 0 {
+		fmt.Println("Per-secret grants:")
+		grants := ui.NewTable()
+		for _, g := range report.Grants {
+			grants.Row(g.Secret, string(g.Access))
 		}
-		fmt.Printf("Roles:      %s\n", roles)
+		grants.Print()
+		fmt.Println()
+	}
+
+	fmt.Println("Run 'passbook can PERMISSION [TARGET]' to see why an action is allowed or denied.")
+	return nil
+}
+
+// buildWhoami gathers identity, role and decryption information. When
+// decrypt is set, every secret is test-decrypted to find real access and
+// per-secret grants.
+func (a *Action) buildWhoami(ctx context.Context, decrypt bool) *whoamiReport {
+	report := &whoamiReport{}
+
+	githubAuth := a.githubAuth()
+	if session, err := githubAuth.LoadSession(); err == nil && session != nil {
+		report.GitHub = session.GitHubLogin
+	}
+
+	// Local key
+	identityPath := a.cfg.IdentityPath()
+	report.Key.Path = identityPath
+	report.Key.PublicKey, _ = a.ownPublicKey()
+	if _, err := os.Stat(identityPath); err == nil {
+		report.Key.Present = true
+		report.Key.Encrypted, _ = age.IsKeyEncrypted(identityPath)
+	}
+	if report.Key.PublicKey != "" {
+		report.Key.Fingerprint, _ = age.Fingerprint(report.Key.PublicKey)
+	}
+
+	user, err := a.getCurrentUser()
+	if err != nil {
+		return report
+	}
+
+	report.InTeam = true
+	report.Email = user.Email
+	report.Roles = user.Roles
+	if user.GitHubID != 0 {
+		report.GitHubBound = formatGitHub(user.GitHubLogin, user.GitHubID)
+	}
+	report.Admin = user.IsAdmin()
+	report.ServiceAccount = user.IsServiceAccount()
+	if user.External {
+		report.External = true
+		report.ExpiresAt = &user.ExpiresAt
+	}
+	report.ReadOnly = user.IsReadOnly()
+	report.Key.Registered = user.PublicKey == report.Key.PublicKey
+	report.Key.Pending = user.IsPendingVerification()
 
-		if user.IsAdmin() {
-			fmt.Printf("Status:     Admin\n")
+	engine := a.policy()
+	for _, p := range rbac.AllPermissions() {
+		if engine.Can(user, p) {
+			report.Permissions = append(report.Permissions, p)
 		}
+	}
+	for _, stage := range models.AllStages() {
+		report.Stages = append(report.Stages, stageAccess{
+			Stage: stage,
+			Read:  engine.CanAccessStage(user, stage, false),
+			Write: engine.CanAccessStage(user, stage, true),
+		})
+	}
+
+	if !decrypt || !report.Key.Present {
+		return report
+	}
 
-		// Show public key
-		key := user.PublicKey
-		if len(key) > 30 {
-			key = key[:30] + "..."
+	ageBackend, err := age.New(identityPath)
+	if err != nil {
+		return report
+	}
+
+	// Env files
+	projectsDir := filepath.Join(a.cfg.StorePath, "projects")
+	projects, _ := os.ReadDir(projectsDir)
+	for _, p := range projects {
+		if !p.IsDir() {
+			continue
 		}
-		fmt.Printf("Public Key: %s\n", key)
+		pa := projectAccess{Name: p.Name()}
+		for _, stage := range models.AllStages() {
+			path := filepath.Join(projectsDir, p.Name(), string(stage)+".env.age")
+			encrypted, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			plaintext, err := ageBackend.Decrypt(ctx, encrypted)
+			if err != nil {
+				pa.Denied = append(pa.Denied, stage)
+				continue
+			}
+			pa.Decryptable = append(pa.Decryptable, stage)
 
-		return nil
+			var envFile models.EnvFile
+			if yaml.Unmarshal(plaintext, &envFile) == nil {
+				if grant, ok := explicitGrant(envFile.Permissions, user.Email); ok {
+					report.Grants = append(report.Grants, secretGrant{Secret: "env " + p.Name() + "/" + string(stage), Access: grant})
+				}
+			}
+			age.ZeroBytes(plaintext)
+		}
+		report.Projects = append(report.Projects, pa)
 	}
 
-	// Just show identity info
-	if a.cfg.Identity.PublicKey != "" {
-		key := a.cfg.Identity.PublicKey
-		if len(key) > 30 {
-			key = key[:30] + "..."
+	// Credentials
+	credentialsDir := filepath.Join(a.cfg.StorePath, "credentials")
+	_ = filepath.Walk(credentialsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(info.Name(), age.Ext) {
+			return nil
+		}
+		encrypted, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		plaintext, err := ageBackend.Decrypt(ctx, encrypted)
+		if err != nil {
+			return nil
+		}
+		defer age.ZeroBytes(plaintext)
+
+		var cred models.Credential
+		if yaml.Unmarshal(plaintext, &cred) == nil {
+			if grant, ok := explicitGrant(cred.Permissions, user.Email); ok {
+				rel, _ := filepath.Rel(credentialsDir, path)
+				report.Grants = append(report.Grants, secretGrant{Secret: "cred " + strings.TrimSuffix(filepath.ToSlash(rel), age.Ext), Access: grant})
+			}
 		}
-		fmt.Printf("Public Key: %s\n", key)
-		fmt.Println("\nYour key is not in the team yet.")
-		fmt.Println("Ask an admin to invite you, or run 'passbook init' to start a new store.")
 		return nil
+	})
+
+	return report
+}
+
+// explicitGrant returns the user's access if a secret uses per-secret permissions
+func explicitGrant(perms *models.SecretPermissions, email string) (models.AccessLevel, bool) {
+	if perms == nil || perms.UseRoleBasedAccess || perms.Count() == 0 {
+		return "", false
 	}
+	return perms.GetAccess(email)
+}
 
-	fmt.Println("Not configured")
-	fmt.Println("\nRun 'passbook init' or 'passbook clone' to get started")
-	return nil
+// truncateKey shortens a public key for display
+func truncateKey(key string) string {
+	if len(key) > 30 {
+		return key[:30] + "..."
+	}
+	return key
 }
 
-// Login authenticates with GitHub
+// Login authenticates with GitHub. With --check it only reports whether
+// the saved session is still valid, failing when a new login is needed.
 func (a *Action) Login(c *cli.Context) error {
-	githubAuth := auth.NewGitHubAuth(a.cfg.ConfigDir, a.cfg.Org.AllowedDomain)
+	githubAuth := a.githubAuth()
+
+	if c.Bool("check") {
+		session, err := githubAuth.Session()
+		if errors.Is(err, auth.ErrNoSession) {
+			return ErrNotLoggedIn
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrNotLoggedIn, err)
+		}
+		if !c.Bool("quiet") {
+			fmt.Printf("Logged in as @%s until %s\n", session.GitHubLogin, session.ExpiresAt.Format("2006-01-02 15:04"))
+		}
+		return nil
+	}
 
 	session, err := githubAuth.Authenticate()
 	if err != nil {
@@ -75,7 +374,7 @@ func (a *Action) Login(c *cli.Context) error {
 		case auth.ErrEmailNotVerified:
 			return fmt.Errorf("your GitHub email is not verified. Please verify your email at github.com")
 		case auth.ErrEmailDomainMismatch:
-			return fmt.Errorf("no verified email matching domain @%s found in your GitHub account", a.cfg.Org.AllowedDomain)
+			return fmt.Errorf("no verified email matching %s found in your GitHub account", a.cfg.Org.DescribeDomains())
 		case auth.ErrAccessDenied:
 			return fmt.Errorf("authentication was denied")
 		case auth.ErrExpiredToken:
@@ -85,6 +384,11 @@ func (a *Action) Login(c *cli.Context) error {
 		}
 	}
 
+	if err := a.checkGitHubBinding(c.Context, session); err != nil {
+		_ = githubAuth.ClearSession()
+		return err
+	}
+
 	fmt.Println("Logged in successfully!")
 	fmt.Println()
 	fmt.Print(session.PrettyPrint())
@@ -93,16 +397,96 @@ func (a *Action) Login(c *cli.Context) error {
 	if a.cfg.Identity.Email == "" {
 		a.cfg.Identity.Email = session.Email
 		if err := a.cfg.Save(); err != nil {
-			fmt.Printf("Warning: failed to save email to config: %v\n", err)
+			ui.Warningf("failed to save email to config: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// checkGitHubBinding checks the GitHub account against the one bound to the
+// local identity's team record. An admin's unbound record is bound on first
+// login; other members are bound by an admin with 'team bind-github'.
+func (a *Action) checkGitHubBinding(ctx context.Context, session *auth.GitHubSession) error {
+	userList, err := a.loadUsers()
+	if err != nil || !a.cfg.IsInitialized() {
+		// Not in a store yet; nothing to bind to
+		return nil
+	}
+
+	var user *models.User
+	for i := range userList.Users {
+		u := &userList.Users[i]
+		if u.PublicKey == a.cfg.Identity.PublicKey {
+			user = u
+		} else if u.GitHubID == session.GitHubID {
+			a.logAudit(audit.EventLoginFailed, u.Email, "github", session.GitHubLogin, "reason", "bound to another member")
+			return fmt.Errorf("%w: GitHub account @%s is bound to %s, not to this identity", ErrAccessDenied, session.GitHubLogin, u.Email)
 		}
 	}
+	if user == nil {
+		return nil
+	}
+
+	switch {
+	case user.GitHubID == session.GitHubID:
+		a.logAudit(audit.EventLoginSuccess, user.Email, "github", session.GitHubLogin)
+		return nil
+	case user.GitHubID != 0:
+		a.logAudit(audit.EventLoginFailed, user.Email, "github", session.GitHubLogin, "reason", "github account mismatch")
+		return fmt.Errorf("%w: %s is bound to GitHub account @%s, not @%s", ErrAccessDenied, user.Email, user.GitHubLogin, session.GitHubLogin)
+	case !strings.EqualFold(user.Email, session.Email):
+		a.logAudit(audit.EventLoginFailed, user.Email, "github", session.GitHubLogin, "reason", "email mismatch")
+		return fmt.Errorf("%w: GitHub account @%s is verified for %s, not %s", ErrAccessDenied, session.GitHubLogin, session.Email, user.Email)
+	}
+
+	// Unbound record with a matching email
+	if _, err := a.authorize(rbac.PermTeamInvite); err != nil {
+		ui.Warningf("your GitHub account isn't bound to your team record yet")
+		fmt.Printf("Ask an admin to run: passbook team bind-github --github-id %d --github %s %s\n", session.GitHubID, session.GitHubLogin, user.Email)
+		return nil
+	}
 
+	user.BindGitHub(session.GitHubID, session.GitHubLogin)
+	if err := a.saveUsers(userList); err != nil {
+		return fmt.Errorf("failed to save users: %w", err)
+	}
+	a.logAudit(audit.EventUserGitHubBound, user.Email, "github", session.GitHubLogin)
+
+	// Git commit
+	if err := a.GitCommitAndSync(ctx, fmt.Sprintf("Bind GitHub account for %s", user.Email)); err != nil {
+		ui.Warningf("%v", err)
+	}
+	return nil
+}
+
+// githubAuth returns the GitHub authenticator with the store's session policy
+func (a *Action) githubAuth() *auth.GitHubAuth {
+	githubAuth := auth.NewGitHubAuth(a.cfg.ConfigDir, a.cfg.Org.Domains())
+	githubAuth.SetSessionTTL(a.cfg.Auth.SessionTTL())
+	return githubAuth
+}
+
+// requireRecentLogin checks that the GitHub session is no older than the
+// store's auth.admin_session_hours, when set
+func (a *Action) requireRecentLogin() error {
+	hours := a.cfg.Auth.AdminSessionHours
+	if hours == 0 {
+		return nil
+	}
+	session, err := a.githubAuth().Session()
+	if err != nil {
+		return fmt.Errorf("%w: admin operations need a GitHub login (%v)", ErrNotLoggedIn, err)
+	}
+	if time.Since(session.AuthenticatedAt) > time.Duration(hours)*time.Hour {
+		return fmt.Errorf("%w: admin operations need a GitHub login within the last %d hours; run 'passbook logout' and 'passbook login'", ErrNotLoggedIn, hours)
+	}
 	return nil
 }
 
 // Logout clears the GitHub session
 func (a *Action) Logout(c *cli.Context) error {
-	githubAuth := auth.NewGitHubAuth(a.cfg.ConfigDir, a.cfg.Org.AllowedDomain)
+	githubAuth := a.githubAuth()
 
 	if err := githubAuth.ClearSession(); err != nil {
 		return fmt.Errorf("failed to logout: %w", err)
@@ -114,19 +498,17 @@ func (a *Action) Logout(c *cli.Context) error {
 
 // AuthStatus shows authentication status
 func (a *Action) AuthStatus(c *cli.Context) error {
-	githubAuth := auth.NewGitHubAuth(a.cfg.ConfigDir, a.cfg.Org.AllowedDomain)
+	githubAuth := a.githubAuth()
 
-	session, err := githubAuth.LoadSession()
-	if err != nil {
+	session, err := githubAuth.Session()
+	if errors.Is(err, auth.ErrNoSession) {
 		fmt.Println("Not authenticated")
 		fmt.Println()
 		fmt.Println("Run 'passbook login' to authenticate with GitHub")
 		return nil
 	}
-
-	// Verify session is still valid
-	if !githubAuth.IsAuthenticated() {
-		fmt.Println("Session expired")
+	if err != nil {
+		fmt.Printf("Session invalid: %v\n", err)
 		fmt.Println()
 		fmt.Println("Run 'passbook login' to re-authenticate")
 		return nil
@@ -0,0 +1,110 @@
+package action
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// autoTyper types into the focused window through the platform's input
+// tools. Secrets are always passed on stdin so they never appear in a
+// process listing.
+type autoTyper struct {
+	name string
+	text func(s string) error
+	key  func(k typedKey) error
+}
+
+// typedKey is a special key typed between fields
+type typedKey int
+
+const (
+	keyTab typedKey = iota
+	keyEnter
+)
+
+// newAutoTyper returns the typer for this platform, or an error naming the
+// tool to install
+func newAutoTyper() (*autoTyper, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return &autoTyper{
+			name: "osascript",
+			text: func(s string) error {
+				return runTyper("osascript", s, `tell application "System Events" to keystroke "`+appleScriptEscape(s)+`"`, "-")
+			},
+			key: func(k typedKey) error {
+				code := map[typedKey]string{keyTab: "48", keyEnter: "36"}[k]
+				return runTyper("osascript", "", `tell application "System Events" to key code `+code, "-")
+			},
+		}, nil
+	case "windows":
+		sendKeys := func(keys string) error {
+			script := "Add-Type -AssemblyName System.Windows.Forms; [System.Windows.Forms.SendKeys]::SendWait('" + strings.ReplaceAll(keys, "'", "''") + "')"
+			return runTyper("powershell", keys, script, "-NoProfile", "-NonInteractive", "-Command", "-")
+		}
+		return &autoTyper{
+			name: "powershell",
+			text: func(s string) error { return sendKeys(sendKeysEscape(s)) },
+			key: func(k typedKey) error {
+				return sendKeys(map[typedKey]string{keyTab: "{TAB}", keyEnter: "{ENTER}"}[k])
+			},
+		}, nil
+	}
+
+	keyName := map[typedKey]string{keyTab: "Tab", keyEnter: "Return"}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if _, err := exec.LookPath("wtype"); err != nil {
+			return nil, fmt.Errorf("auto-type on Wayland needs wtype; install it and try again")
+		}
+		return &autoTyper{
+			name: "wtype",
+			text: func(s string) error { return runTyper("wtype", s, s, "-") },
+			key:  func(k typedKey) error { return runTyper("wtype", "", "", "-k", keyName[k]) },
+		}, nil
+	}
+	if _, err := exec.LookPath("xdotool"); err != nil {
+		return nil, fmt.Errorf("auto-type on X11 needs xdotool; install it and try again")
+	}
+	return &autoTyper{
+		name: "xdotool",
+		text: func(s string) error { return runTyper("xdotool", s, s, "type", "--clearmodifiers", "--file", "-") },
+		key:  func(k typedKey) error { return runTyper("xdotool", "", "", "key", "--clearmodifiers", keyName[k]) },
+	}, nil
+}
+
+// runTyper runs an input tool with stdin on its standard input. Its output
+// is scrubbed of the secret before being shown in an error.
+func runTyper(tool, secret, stdin string, args ...string) error {
+	cmd := exec.Command(tool, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(output))
+		if secret != "" {
+			msg = strings.ReplaceAll(msg, secret, "********")
+		}
+		return fmt.Errorf("%s failed: %v %s", tool, err, msg)
+	}
+	return nil
+}
+
+// appleScriptEscape quotes s for an AppleScript string literal
+func appleScriptEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+// sendKeysEscape wraps the characters SendKeys treats as commands in braces
+func sendKeysEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("+^%~(){}[]", r) {
+			b.WriteString("{" + string(r) + "}")
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,146 @@
+package action
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/pkg/ui"
+)
+
+// pendingCommitFile queues messages for changes made with --no-commit. It
+// lives inside .git so it is never committed itself.
+const pendingCommitFile = "passbook-pending-commit"
+
+// commitOrDefer commits and syncs a change, or with --no-commit leaves it in
+// the working tree and queues the message for 'passbook commit'
+func (a *Action) commitOrDefer(c *cli.Context, message string) {
+	if c.Bool("no-commit") {
+		if err := a.queueCommitMessage(message); err != nil {
+			ui.Warningf("failed to queue commit message: %v", err)
+		}
+		fmt.Println("Not committed; run 'passbook commit' when done")
+		return
+	}
+
+	if err := a.GitCommitAndSync(c.Context, message); err != nil {
+		ui.Warningf("%v", err)
+	}
+}
+
+// Commit commits all changes made with --no-commit as a single commit and syncs
+func (a *Action) Commit(c *cli.Context) error {
+	storePath := a.cfg.StorePath
+
+	changes, err := gitUncommittedChanges(storePath)
+	if err != nil {
+		return fmt.Errorf("failed to read git status: %w", err)
+	}
+	if len(changes) == 0 {
+		fmt.Println("Nothing to commit")
+		return a.clearCommitQueue()
+	}
+
+	queued, err := a.loadCommitQueue()
+	if err != nil {
+		return fmt.Errorf("failed to read queued changes: %w", err)
+	}
+
+	message := c.String("message")
+	if message == "" {
+		switch len(queued) {
+		case 0:
+			message = fmt.Sprintf("Update %d file(s)", len(changes))
+		case 1:
+			message = queued[0]
+		default:
+			message = fmt.Sprintf("Batch of %d changes", len(queued))
+		}
+	}
+	if len(queued) > 1 || (len(queued) == 1 && message != queued[0]) {
+		message += "\n\n- " + strings.Join(queued, "\n- ")
+	}
+
+	if err := a.commitAndSync(c.Context, message); err != nil {
+		return err
+	}
+	if err := a.clearCommitQueue(); err != nil {
+		ui.Warningf("failed to clear queued changes: %v", err)
+	}
+
+	fmt.Printf("%s Committed %d file(s)", ui.Success("✓"), len(changes))
+	if len(queued) > 0 {
+		fmt.Printf(" from %d change(s)", len(queued))
+	}
+	fmt.Println()
+	return nil
+}
+
+// commitQueuePath returns the path of the queued message file in the git dir
+func (a *Action) commitQueuePath() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--git-dir")
+	cmd.Dir = a.cfg.StorePath
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("not a git repository: %w", err)
+	}
+	gitDir := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(a.cfg.StorePath, gitDir)
+	}
+	return filepath.Join(gitDir, pendingCommitFile), nil
+}
+
+// queueCommitMessage appends a message to the queue
+func (a *Action) queueCommitMessage(message string) error {
+	path, err := a.commitQueuePath()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, message)
+	return err
+}
+
+// loadCommitQueue returns the queued messages in order
+func (a *Action) loadCommitQueue() ([]string, error) {
+	path, err := a.commitQueuePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var messages []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			messages = append(messages, line)
+		}
+	}
+	return messages, nil
+}
+
+// clearCommitQueue removes the queue after a commit
+func (a *Action) clearCommitQueue() error {
+	path, err := a.commitQueuePath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
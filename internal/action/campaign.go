@@ -0,0 +1,474 @@
+package action
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+
+	"passbook/internal/audit"
+	"passbook/internal/rbac"
+	reencrypt_pkg "passbook/internal/reencrypt"
+	"passbook/pkg/ui"
+)
+
+// campaignsDir holds one file per re-encryption campaign
+const campaignsDir = ".passbook-campaigns"
+
+// campaign tracks an organization-wide re-encryption that several admins
+// work through over time. Each file is identified by a hash of its
+// ciphertext when the campaign started: once that ciphertext is gone from
+// the store the file has been re-encrypted, whether by the campaign or by a
+// later write, and renames or archiving don't lose track of it.
+type campaign struct {
+	Name        string         `yaml:"name"`
+	StartedBy   string         `yaml:"started_by"`
+	StartedAt   time.Time      `yaml:"started_at"`
+	CompletedBy string         `yaml:"completed_by,omitempty"`
+	CompletedAt time.Time      `yaml:"completed_at,omitempty"`
+	Files       []campaignFile `yaml:"files"`
+}
+
+// campaignFile is one secret the campaign must re-encrypt
+type campaignFile struct {
+	Path   string    `yaml:"path"`
+	Hash   string    `yaml:"hash"`
+	DoneBy string    `yaml:"done_by,omitempty"`
+	DoneAt time.Time `yaml:"done_at,omitempty"`
+}
+
+// campaignProgress is a campaign's state against the current store
+type campaignProgress struct {
+	pending map[int]string // file index to its current path
+	done    int
+}
+
+// CampaignStart snapshots every secret in the store as needing re-encryption
+func (a *Action) CampaignStart(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook campaign start NAME")
+	}
+
+	name := c.Args().First()
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("%w: campaign name %q must not contain slashes or start with a dot", ErrInvalidInput, name)
+	}
+
+	currentUser, err := a.authorize(rbac.PermStoreReencrypt)
+	if err != nil {
+		return err
+	}
+
+	path := a.campaignPath(name)
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("campaign %s %w", name, ErrConflict)
+	}
+
+	hashes, err := a.secretHashes()
+	if err != nil {
+		return err
+	}
+
+	camp := &campaign{
+		Name:      name,
+		StartedBy: currentUser.Email,
+		StartedAt: time.Now().UTC(),
+	}
+	for hash, file := range hashes {
+		camp.Files = append(camp.Files, campaignFile{Path: file, Hash: hash})
+	}
+	sort.Slice(camp.Files, func(i, j int) bool {
+		return camp.Files[i].Path < camp.Files[j].Path
+	})
+
+	if err := a.saveCampaign(camp); err != nil {
+		return err
+	}
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Start re-encryption campaign: %s", name)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Started campaign %s with %d files", name, len(camp.Files))
+	fmt.Printf("\nAdmins can now work through it with: passbook campaign run %s\n", name)
+
+	return nil
+}
+
+// CampaignRun re-encrypts the campaign's pending files that the current
+// admin can decrypt. Files they can't decrypt are left for another admin.
+func (a *Action) CampaignRun(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook campaign run NAME [--limit N]")
+	}
+
+	name := c.Args().First()
+	limit := c.Int("limit")
+
+	currentUser, err := a.authorize(rbac.PermStoreReencrypt)
+	if err != nil {
+		return err
+	}
+
+	camp, err := a.loadCampaign(name)
+	if err != nil {
+		return err
+	}
+	if !camp.CompletedAt.IsZero() {
+		return fmt.Errorf("campaign %s is already complete", name)
+	}
+
+	progress, err := a.campaignProgress(camp)
+	if err != nil {
+		return err
+	}
+	if len(progress.pending) == 0 {
+		ui.Successf("Nothing left to re-encrypt; finish with: passbook campaign complete %s", name)
+		return nil
+	}
+
+	// Current recipients (verified users with public keys)
+	userList, err := a.loadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+	var recipients []string
+	for _, u := range userList.Users {
+		if u.CanReceiveSecrets() {
+			recipients = append(recipients, u.PublicKey)
+		}
+	}
+	if len(recipients) == 0 {
+		return fmt.Errorf("no verified recipients found")
+	}
+
+	crypto, err := a.crypto()
+	if err != nil {
+		return err
+	}
+	reencryptor := reencrypt_pkg.NewReEncryptor(a.cfg.StorePath, crypto)
+
+	indexes := make([]int, 0, len(progress.pending))
+	for i := range progress.pending {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	if limit > 0 && len(indexes) > limit {
+		indexes = indexes[:limit]
+	}
+
+	bar := ui.NewProgress("Re-encrypting", len(indexes))
+	var done, skipped int
+	var failures, held []string
+	for _, i := range indexes {
+		if err := c.Context.Err(); err != nil {
+			break
+		}
+		file := progress.pending[i]
+		if err := a.checkHold(file, "re-encrypt"); err != nil {
+			held = append(held, file)
+			bar.Add(1)
+			continue
+		}
+		err := reencryptor.ReEncryptFile(c.Context, filepath.Join(a.cfg.StorePath, filepath.FromSlash(file)), a.store)
+		bar.Add(1)
+		if errors.Is(err, context.Canceled) {
+			break
+		}
+		if err != nil {
+			if strings.HasPrefix(err.Error(), "failed to decrypt") {
+				skipped++
+			} else {
+				failures = append(failures, fmt.Sprintf("%s: %v", file, err))
+			}
+			continue
+		}
+		camp.Files[i].DoneBy = currentUser.Email
+		camp.Files[i].DoneAt = time.Now().UTC()
+		done++
+	}
+	bar.Finish()
+
+	// Record progress even if interrupted part way
+	if done > 0 {
+		if err := a.saveCampaign(camp); err != nil {
+			return err
+		}
+		a.logAudit(audit.EventReEncrypt, "campaign:"+name, "files", fmt.Sprintf("%d", done))
+		if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Re-encrypt %d files for campaign: %s", done, name)); err != nil {
+			ui.Warningf("%v", err)
+		}
+	}
+
+	fmt.Printf("Re-encrypted %d files", done)
+	if skipped > 0 {
+		fmt.Printf(", skipped %d you can't decrypt", skipped)
+	}
+	fmt.Println()
+	for _, f := range failures {
+		ui.Warningf("%s", f)
+	}
+	warnHeldFiles(held)
+	if err := c.Context.Err(); err != nil {
+		return err
+	}
+
+	remaining := len(progress.pending) - done
+	if remaining > 0 {
+		fmt.Printf("%d files remain; see: passbook campaign status %s\n", remaining, name)
+	} else {
+		fmt.Printf("All files done; finish with: passbook campaign complete %s\n", name)
+	}
+
+	return nil
+}
+
+// CampaignStatus shows how far a campaign has got
+func (a *Action) CampaignStatus(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook campaign status NAME")
+	}
+
+	camp, err := a.loadCampaign(c.Args().First())
+	if err != nil {
+		return err
+	}
+	progress, err := a.campaignProgress(camp)
+	if err != nil {
+		return err
+	}
+
+	ui.Heading(fmt.Sprintf("Campaign: %s", camp.Name))
+	fmt.Println()
+	fmt.Printf("Started:   %s by %s\n", camp.StartedAt.Local().Format("2006-01-02 15:04"), camp.StartedBy)
+	if !camp.CompletedAt.IsZero() {
+		fmt.Printf("Completed: %s by %s\n", camp.CompletedAt.Local().Format("2006-01-02 15:04"), camp.CompletedBy)
+	}
+	total := len(camp.Files)
+	percent := 100
+	if total > 0 {
+		percent = progress.done * 100 / total
+	}
+	fmt.Printf("Progress:  %d/%d files (%d%%)\n", progress.done, total, percent)
+
+	// Who did what
+	byAdmin := make(map[string]int)
+	for _, f := range camp.Files {
+		if f.DoneBy != "" {
+			byAdmin[f.DoneBy]++
+		}
+	}
+	if others := progress.done - sumCounts(byAdmin); others > 0 {
+		byAdmin[ui.Muted("(changed since start)")] = others
+	}
+	if len(byAdmin) > 0 {
+		fmt.Println()
+		fmt.Println("Done by:")
+		admins := make([]string, 0, len(byAdmin))
+		for admin := range byAdmin {
+			admins = append(admins, admin)
+		}
+		sort.Strings(admins)
+		table := ui.NewTable()
+		for _, admin := range admins {
+			table.Row(admin, fmt.Sprintf("%d", byAdmin[admin]))
+		}
+		table.Print()
+	}
+
+	if len(progress.pending) > 0 {
+		fmt.Println()
+		fmt.Println("Pending:")
+		var files []string
+		for _, file := range progress.pending {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			fmt.Printf("  %s\n", file)
+		}
+	}
+
+	return nil
+}
+
+// CampaignList lists campaigns and their progress
+func (a *Action) CampaignList(c *cli.Context) error {
+	entries, _ := os.ReadDir(filepath.Join(a.cfg.StorePath, campaignsDir))
+
+	table := ui.NewTable("CAMPAIGN", "STARTED", "PROGRESS", "STATUS")
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".yaml")
+		if !ok {
+			continue
+		}
+		camp, err := a.loadCampaign(name)
+		if err != nil {
+			ui.Warningf("%v", err)
+			continue
+		}
+		progress, err := a.campaignProgress(camp)
+		if err != nil {
+			return err
+		}
+		status := ui.Warn("in progress")
+		if !camp.CompletedAt.IsZero() {
+			status = ui.Success("complete")
+		}
+		table.Row(camp.Name, camp.StartedAt.Local().Format("2006-01-02"),
+			fmt.Sprintf("%d/%d", progress.done, len(camp.Files)), status)
+	}
+
+	if table.Len() == 0 {
+		fmt.Println("No campaigns.")
+		fmt.Println("\nStart one with: passbook campaign start NAME")
+		return nil
+	}
+	table.Print()
+
+	return nil
+}
+
+// CampaignComplete marks a campaign finished once every file is re-encrypted
+func (a *Action) CampaignComplete(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook campaign complete NAME")
+	}
+
+	name := c.Args().First()
+
+	currentUser, err := a.authorize(rbac.PermStoreReencrypt)
+	if err != nil {
+		return err
+	}
+
+	camp, err := a.loadCampaign(name)
+	if err != nil {
+		return err
+	}
+	if !camp.CompletedAt.IsZero() {
+		return fmt.Errorf("campaign %s is already complete", name)
+	}
+
+	progress, err := a.campaignProgress(camp)
+	if err != nil {
+		return err
+	}
+	if n := len(progress.pending); n > 0 {
+		return fmt.Errorf("campaign %s has %d files left to re-encrypt; run 'passbook campaign status %s' to see them", name, n, name)
+	}
+
+	camp.CompletedBy = currentUser.Email
+	camp.CompletedAt = time.Now().UTC()
+	if err := a.saveCampaign(camp); err != nil {
+		return err
+	}
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Complete re-encryption campaign: %s", name)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Completed campaign %s (%d files)", name, len(camp.Files))
+
+	return nil
+}
+
+// campaignProgress finds which of a campaign's files still have the
+// ciphertext they had when it started
+func (a *Action) campaignProgress(camp *campaign) (*campaignProgress, error) {
+	hashes, err := a.secretHashes()
+	if err != nil {
+		return nil, err
+	}
+
+	progress := &campaignProgress{pending: make(map[int]string)}
+	for i, f := range camp.Files {
+		if file, ok := hashes[f.Hash]; ok && f.DoneBy == "" {
+			progress.pending[i] = file
+			continue
+		}
+		progress.done++
+	}
+	return progress, nil
+}
+
+// secretHashes maps the SHA-256 of every secret's ciphertext to its path
+// relative to the store
+func (a *Action) secretHashes() (map[string]string, error) {
+	files, err := reencrypt_pkg.NewReEncryptor(a.cfg.StorePath, nil).GetAllAgeFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	hashes := make(map[string]string, len(files))
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		rel, err := filepath.Rel(a.cfg.StorePath, path)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		hashes[hex.EncodeToString(sum[:])] = filepath.ToSlash(rel)
+	}
+	return hashes, nil
+}
+
+// campaignPath returns the file a campaign is stored in
+func (a *Action) campaignPath(name string) string {
+	return filepath.Join(a.cfg.StorePath, campaignsDir, name+".yaml")
+}
+
+// loadCampaign reads a campaign by name
+func (a *Action) loadCampaign(name string) (*campaign, error) {
+	data, err := os.ReadFile(a.campaignPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("campaign %s %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read campaign: %w", err)
+	}
+
+	var camp campaign
+	if err := yaml.Unmarshal(data, &camp); err != nil {
+		return nil, fmt.Errorf("failed to parse campaign %s: %w", name, err)
+	}
+	return &camp, nil
+}
+
+// saveCampaign writes a campaign
+func (a *Action) saveCampaign(camp *campaign) error {
+	data, err := yaml.Marshal(camp)
+	if err != nil {
+		return fmt.Errorf("failed to marshal campaign: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Join(a.cfg.StorePath, campaignsDir), 0700); err != nil {
+		return fmt.Errorf("failed to create campaigns directory: %w", err)
+	}
+	if err := os.WriteFile(a.campaignPath(camp.Name), data, 0600); err != nil {
+		return fmt.Errorf("failed to write campaign: %w", err)
+	}
+	return nil
+}
+
+// sumCounts adds up the values of a map
+func sumCounts(counts map[string]int) int {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	return total
+}
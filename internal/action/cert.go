@@ -0,0 +1,314 @@
+package action
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/audit"
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/internal/store"
+	"passbook/pkg/ui"
+)
+
+// certWarningWindow is how far ahead status warns about expiring certificates
+const certWarningWindow = 30 * 24 * time.Hour
+
+// CertList lists certificates by expiry, optionally only those expiring
+// within --expiring
+func (a *Action) CertList(c *cli.Context) error {
+	var window time.Duration
+	if expiring := c.String("expiring"); expiring != "" {
+		var err error
+		if window, err = parseWindow(expiring); err != nil {
+			return err
+		}
+	}
+
+	if _, err := a.authorize(rbac.PermCredentialsRead); err != nil {
+		return err
+	}
+	certs, err := a.listCertificates(c)
+	if err != nil {
+		return err
+	}
+	if window > 0 {
+		var due []*models.Certificate
+		for _, cert := range certs {
+			if cert.ExpiresWithin(window) {
+				due = append(due, cert)
+			}
+		}
+		certs = due
+	}
+
+	if len(certs) == 0 {
+		if window > 0 {
+			fmt.Printf("No certificates expire within %s.\n", c.String("expiring"))
+			return nil
+		}
+		fmt.Println("No certificates found.")
+		fmt.Println("\nAdd one with: passbook cert add --file cert.pem NAME")
+		return nil
+	}
+
+	ui.Heading("Certificates")
+	fmt.Println()
+	table := ui.NewTable("NAME", "SUBJECT", "EXPIRES", "")
+	for _, cert := range certs {
+		table.Row(cert.Name, cert.Subject, cert.NotAfter.Format("2006-01-02"), certExpiryState(cert))
+	}
+	table.Print()
+	fmt.Printf("\nTotal: %d certificate(s)\n", len(certs))
+	return nil
+}
+
+// CertShow shows a certificate's details, or its PEM with --pem
+func (a *Action) CertShow(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook cert show NAME")
+	}
+	if _, err := a.authorize(rbac.PermCredentialsRead); err != nil {
+		return err
+	}
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+
+	name := c.Args().First()
+	cert, err := s.GetCertificate(c.Context, name)
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("certificate %s %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	switch {
+	case c.Bool("pem"):
+		fmt.Print(cert.Chain)
+		return nil
+	case c.Bool("key"):
+		if cert.PrivateKey == "" {
+			return fmt.Errorf("certificate %s has no private key stored", name)
+		}
+		fmt.Print(cert.PrivateKey)
+		return nil
+	}
+
+	ui.Heading("Certificate: " + cert.Name)
+	fmt.Printf("Subject:     %s\n", cert.Subject)
+	fmt.Printf("Issuer:      %s\n", cert.Issuer)
+	if len(cert.DNSNames) > 0 {
+		fmt.Printf("Names:       %s\n", strings.Join(cert.DNSNames, ", "))
+	}
+	fmt.Printf("Serial:      %s\n", cert.Serial)
+	fmt.Printf("Fingerprint: %s\n", cert.Fingerprint)
+	fmt.Printf("Valid:       %s to %s\n", cert.NotBefore.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02"))
+	if state := certExpiryState(cert); state != "" {
+		fmt.Printf("Status:      %s\n", state)
+	}
+	if cert.PrivateKey != "" {
+		fmt.Println("Private key: stored")
+	}
+	return nil
+}
+
+// CertAdd imports a PEM certificate chain, and optionally its private key
+func (a *Action) CertAdd(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook cert add [--file CERT] [--key KEY] NAME")
+	}
+	name := c.Args().First()
+	if name == "." || name == ".." || strings.ContainsAny(name, "/\\") {
+		return fmt.Errorf("%w: invalid certificate name %q", ErrInvalidInput, name)
+	}
+
+	currentUser, err := a.authorize(rbac.PermCredentialsWrite)
+	if err != nil {
+		return err
+	}
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if file := c.String("file"); file != "" && file != "-" {
+		data, err = os.ReadFile(file)
+	} else {
+		data, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read certificate: %w", err)
+	}
+	cert, err := parseCertificateChain(data)
+	if err != nil {
+		return err
+	}
+	cert.Name = name
+
+	if keyFile := c.String("key"); keyFile != "" {
+		key, err := os.ReadFile(keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read private key: %w", err)
+		}
+		if block, _ := pem.Decode(key); block == nil || !strings.Contains(block.Type, "PRIVATE KEY") {
+			return fmt.Errorf("%w: %s is not a PEM private key", ErrInvalidInput, keyFile)
+		}
+		cert.PrivateKey = string(key)
+	}
+
+	err = s.CreateCertificate(c.Context, cert, currentUser.Email)
+	if errors.Is(err, store.ErrAlreadyExists) {
+		return fmt.Errorf("certificate %s %w", name, ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save certificate: %w", err)
+	}
+
+	a.logAudit(audit.EventCertificateCreated, name, "subject", cert.Subject, "not_after", cert.NotAfter.Format(time.RFC3339))
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Add certificate: %s", name)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Added certificate %s (%s, expires %s)", name, cert.Subject, cert.NotAfter.Format("2006-01-02"))
+	if cert.ExpiresWithin(certWarningWindow) {
+		ui.Warningf("%s", certExpiryState(cert))
+	}
+	return nil
+}
+
+// CertRemove deletes a certificate
+func (a *Action) CertRemove(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook cert rm NAME")
+	}
+	name := c.Args().First()
+
+	if _, err := a.authorize(rbac.PermCredentialsWrite); err != nil {
+		return err
+	}
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+
+	err = s.DeleteCertificate(c.Context, name)
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("certificate %s %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete certificate: %w", err)
+	}
+
+	a.logAudit(audit.EventCertificateDeleted, name)
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Delete certificate: %s", name)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Deleted certificate: %s", name)
+	return nil
+}
+
+// listCertificates returns the decryptable certificates, soonest expiry first
+func (a *Action) listCertificates(c *cli.Context) ([]*models.Certificate, error) {
+	s, err := a.openStore()
+	if err != nil {
+		return nil, err
+	}
+	certs, err := s.ListCertificates(c.Context)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list certificates: %w", err)
+	}
+	sort.Slice(certs, func(i, j int) bool { return certs[i].NotAfter.Before(certs[j].NotAfter) })
+	return certs, nil
+}
+
+// parseCertificateChain reads a PEM chain and fills in the leaf's details
+func parseCertificateChain(data []byte) (*models.Certificate, error) {
+	var (
+		leaf  *x509.Certificate
+		chain []byte
+	)
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		parsed, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid certificate: %v", ErrInvalidInput, err)
+		}
+		if leaf == nil {
+			leaf = parsed
+		}
+		chain = append(chain, pem.EncodeToMemory(block)...)
+	}
+	if leaf == nil {
+		return nil, fmt.Errorf("%w: no PEM certificate found", ErrInvalidInput)
+	}
+
+	sum := sha256.Sum256(leaf.Raw)
+	return &models.Certificate{
+		Subject:     leaf.Subject.String(),
+		Issuer:      leaf.Issuer.String(),
+		DNSNames:    leaf.DNSNames,
+		Serial:      leaf.SerialNumber.Text(16),
+		Fingerprint: hex.EncodeToString(sum[:]),
+		NotBefore:   leaf.NotBefore,
+		NotAfter:    leaf.NotAfter,
+		Chain:       string(chain),
+	}, nil
+}
+
+// certExpiryState describes an expired or soon-expiring certificate
+func certExpiryState(cert *models.Certificate) string {
+	days := int(time.Until(cert.NotAfter).Hours() / 24)
+	switch {
+	case time.Now().After(cert.NotAfter):
+		return "EXPIRED"
+	case cert.ExpiresWithin(certWarningWindow):
+		return fmt.Sprintf("expires in %d day(s)", days)
+	}
+	return ""
+}
+
+// parseWindow parses a window such as "30d" or "2w", or a Go duration such
+// as "12h"
+func parseWindow(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err == nil && count >= 0 {
+				return time.Duration(count) * unit, nil
+			}
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%w: %q is not a window like 30d, 2w or 12h", ErrInvalidInput, s)
+	}
+	return d, nil
+}
@@ -0,0 +1,130 @@
+package action
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/atotto/clipboard"
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/models"
+	"passbook/pkg/termio"
+	"passbook/pkg/ui"
+)
+
+// clipboardHashEnv passes the hash of the copied secret to the clearing
+// process so the secret itself never appears in a process listing
+const clipboardHashEnv = "PASSBOOK_CLIPBOARD_HASH"
+
+// copyToClipboard copies a secret and schedules it to be cleared. The
+// clearing runs in a detached process because passbook exits right away.
+func (a *Action) copyToClipboard(secret string) error {
+	if err := clipboard.WriteAll(secret); err != nil {
+		return fmt.Errorf("failed to copy to clipboard: %w", err)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to schedule clipboard clear: %w", err)
+	}
+
+	timeout := strconv.Itoa(a.cfg.Preferences.ClipboardTimeout)
+	cmd := exec.Command(exe, "clipboard-clear", "--after", timeout)
+	cmd.Env = append(os.Environ(), clipboardHashEnv+"="+clipboardHash(secret))
+	detachProcess(cmd)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to schedule clipboard clear: %w", err)
+	}
+	return cmd.Process.Release()
+}
+
+// ClipboardClear waits and then clears the clipboard, unless something else
+// was copied in the meantime. It is started by copyToClipboard.
+func (a *Action) ClipboardClear(c *cli.Context) error {
+	time.Sleep(time.Duration(c.Int("after")) * time.Second)
+
+	current, err := clipboard.ReadAll()
+	if err != nil {
+		return err
+	}
+	if want := os.Getenv(clipboardHashEnv); want != "" && clipboardHash(current) != want {
+		return nil
+	}
+	return clipboard.WriteAll("")
+}
+
+// clipboardHash identifies clipboard contents without keeping them around
+func clipboardHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// clipboardSequenceFile, in the user's config directory, records a
+// username copied by cred copy --username-then-password so the next
+// invocation copies the password
+const clipboardSequenceFile = "clipboard-sequence"
+
+// clipboardSequence is a pending username-then-password copy
+type clipboardSequence struct {
+	Credential string    `json:"credential"`
+	Expires    time.Time `json:"expires"`
+}
+
+// copyUsernameThenPassword copies a credential's username, then its
+// password once the user presses Enter. Without a terminal to wait on, it
+// copies the username and leaves the password for the next invocation.
+func (a *Action) copyUsernameThenPassword(cred *models.Credential) error {
+	target := cred.Website + "/" + cred.Name
+	statePath := filepath.Join(a.cfg.ConfigDir, clipboardSequenceFile)
+
+	// The second invocation of a pending sequence copies the password
+	var pending clipboardSequence
+	if data, err := os.ReadFile(statePath); err == nil && json.Unmarshal(data, &pending) == nil {
+		os.Remove(statePath)
+		if pending.Credential == target && time.Now().Before(pending.Expires) {
+			return a.copyPassword(cred)
+		}
+	}
+
+	if cred.Username == "" {
+		return a.copyPassword(cred)
+	}
+	if err := clipboard.WriteAll(cred.Username); err != nil {
+		return fmt.Errorf("failed to copy to clipboard: %w", err)
+	}
+
+	if !termio.IsTerminal() {
+		window := time.Duration(a.cfg.Preferences.ClipboardTimeout) * time.Second
+		data, err := json.Marshal(clipboardSequence{Credential: target, Expires: time.Now().Add(window)})
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(statePath, data, 0600); err != nil {
+			return fmt.Errorf("failed to save clipboard sequence: %w", err)
+		}
+		ui.Successf("Username copied; run the same command within %d seconds to copy the password", a.cfg.Preferences.ClipboardTimeout)
+		return nil
+	}
+
+	ui.Successf("Username copied to clipboard")
+	if _, err := termio.Prompt("Paste it, then press Enter to copy the password..."); err != nil {
+		return err
+	}
+	return a.copyPassword(cred)
+}
+
+// copyPassword copies a credential's password and starts the clear timer
+func (a *Action) copyPassword(cred *models.Credential) error {
+	if err := a.copyToClipboard(cred.Password); err != nil {
+		return err
+	}
+	ui.Successf("Password copied to clipboard (clears in %d seconds)", a.cfg.Preferences.ClipboardTimeout)
+	return nil
+}
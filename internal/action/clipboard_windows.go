@@ -0,0 +1,20 @@
+//go:build windows
+
+package action
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachedProcess is DETACHED_PROCESS, which syscall doesn't define
+const detachedProcess = 0x00000008
+
+// detachProcess starts cmd without a console so closing the terminal
+// doesn't kill it
+func detachProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess,
+		HideWindow:    true,
+	}
+}
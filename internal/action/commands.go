@@ -1,12 +1,16 @@
 package action
 
 import (
+	"time"
+
 	"github.com/urfave/cli/v2"
+
+	"passbook/internal/envformat"
 )
 
 // GetCommands returns all CLI commands
 func (a *Action) GetCommands() []*cli.Command {
-	return []*cli.Command{
+	commands := []*cli.Command{
 		// Setup and initialization
 		{
 			Name:   "init",
@@ -23,23 +27,159 @@ func (a *Action) GetCommands() []*cli.Command {
 			Usage:     "Clone an existing passbook store",
 			ArgsUsage: "GIT_URL",
 			Action:    a.Clone,
+			Flags: []cli.Flag{
+				&cli.IntFlag{Name: "depth", Usage: "Create a shallow clone with this many commits"},
+				&cli.StringFlag{Name: "filter", Usage: "Partial clone filter (e.g. blob:none)"},
+				&cli.BoolFlag{Name: "sparse", Usage: "Only check out stages you can access"},
+			},
 		},
 		{
 			Name:   "setup",
 			Usage:  "Interactive setup wizard",
 			Action: a.Setup,
 		},
+		{
+			Name:   "join",
+			Usage:  "Join a team using an invite from an admin",
+			Action: a.Join,
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "invite", Aliases: []string{"i"}, Usage: "Invite token or file containing one"},
+			},
+		},
+
+		{
+			Name:   "status",
+			Usage:  "Show store health overview",
+			Action: a.Status,
+		},
+
+		{
+			Name:   "provision",
+			Usage:  "Set up this server to read a project stage with its own identity",
+			Action: a.Provision,
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "identity-file", Value: "/etc/passbook/identity", Usage: "Where to install the machine identity, readable only by root"},
+				&cli.StringFlag{Name: "project", Aliases: []string{"p"}, Usage: "Project the server reads"},
+				&cli.StringFlag{Name: "stage", Aliases: []string{"s"}, Usage: "Stage the server reads"},
+				&cli.StringFlag{Name: "name", Usage: "Service account name to register (default: HOST-PROJECT-STAGE)"},
+				&cli.BoolFlag{Name: "systemd", Usage: "Render the env into --service's runtime directory at each start, via LoadCredential"},
+				&cli.StringFlag{Name: "service", Usage: "systemd service that gets the env"},
+				&cli.StringFlag{Name: "unit-dir", Value: "/etc/systemd/system", Usage: "Where to write the systemd drop-in"},
+			},
+		},
+
+		{
+			Name:   "doctor",
+			Usage:  "Check the local setup for problems",
+			Action: a.Doctor,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{Name: "fix", Usage: "Repair what can be repaired, such as loose file permissions"},
+			},
+		},
+
+		{
+			Name:  "metrics",
+			Usage: "Local command counts and durations (opt-in, never sent anywhere)",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "show",
+					Usage:  "Show how often commands ran and how long they took",
+					Action: a.MetricsShow,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "sort", Value: "total", Usage: "Order by total, runs, avg or max time"},
+						&cli.BoolFlag{Name: "json", Usage: "Output as JSON"},
+					},
+				},
+				{
+					Name:   "reset",
+					Usage:  "Delete the recorded metrics",
+					Action: a.MetricsReset,
+				},
+			},
+		},
+
+		{
+			Name:   "stats",
+			Usage:  "Summarize projects, credentials, team and store size",
+			Action: a.Stats,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{Name: "json", Usage: "Output as JSON"},
+			},
+		},
+
+		{
+			Name:   "verify",
+			Usage:  "Check the store against its signed integrity manifest",
+			Action: a.Verify,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{Name: "update", Usage: "Re-sign the manifest for the current store (admin)"},
+			},
+		},
+
+		{
+			Name:      "can",
+			Usage:     "Explain whether a permission would be allowed",
+			ArgsUsage: "PERMISSION [TARGET]",
+			Action:    a.Can,
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "user", Aliases: []string{"u"}, Usage: "Evaluate for another team member"},
+			},
+		},
+
+		{
+			Name:  "config",
+			Usage: "View and change user and store settings",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "list",
+					Usage:  "List all settings with their current values",
+					Action: a.ConfigList,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "user", Usage: "Only show user settings"},
+						&cli.BoolFlag{Name: "store", Usage: "Only show store settings"},
+					},
+				},
+				{
+					Name:      "get",
+					Usage:     "Print the value of a setting",
+					ArgsUsage: "KEY",
+					Action:    a.ConfigGet,
+				},
+				{
+					Name:      "set",
+					Usage:     "Change a setting (store settings require admin)",
+					ArgsUsage: "KEY VALUE",
+					Action:    a.ConfigSet,
+				},
+				{
+					Name:   "edit",
+					Usage:  "Edit the config file in your editor",
+					Action: a.ConfigEdit,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "store", Usage: "Edit the shared store config (requires admin)"},
+					},
+				},
+			},
+		},
 
 		// Auth commands
 		{
 			Name:   "whoami",
-			Usage:  "Show current user",
+			Usage:  "Show current user and effective access",
 			Action: a.WhoAmI,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{Name: "json", Usage: "Output as JSON"},
+				&cli.BoolFlag{Name: "no-decrypt", Usage: "Skip test-decrypting secrets (roles only, no passphrase prompt)"},
+			},
 		},
 		{
 			Name:   "login",
 			Usage:  "Authenticate with GitHub",
 			Action: a.Login,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{Name: "check", Usage: "Only check the saved session; exit nonzero when a new login is needed"},
+				&cli.BoolFlag{Name: "quiet", Aliases: []string{"q"}, Usage: "With --check, print nothing"},
+			},
 		},
 		{
 			Name:   "logout",
@@ -75,6 +215,34 @@ func (a *Action) GetCommands() []*cli.Command {
 					Flags: []cli.Flag{
 						&cli.BoolFlag{Name: "clip", Aliases: []string{"c"}, Usage: "Copy password to clipboard"},
 						&cli.BoolFlag{Name: "password", Aliases: []string{"p"}, Usage: "Show only password"},
+						&cli.BoolFlag{Name: "history", Usage: "Also list previous passwords"},
+					},
+				},
+				{
+					Name:   "dedupe",
+					Usage:  "Find credentials saved more than once and merge or delete them",
+					Action: a.CredDedupe,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "dry-run", Aliases: []string{"n"}, Usage: "Only list the duplicates"},
+					},
+				},
+				{
+					Name:      "find-for",
+					Usage:     "List the credentials for a URL, closest match first",
+					ArgsUsage: "URL",
+					Action:    a.CredFindFor,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "json", Usage: "Output as JSON"},
+					},
+				},
+				{
+					Name:      "log",
+					Usage:     "Show who changed, read and re-encrypted a credential, from git and the audit log",
+					ArgsUsage: "WEBSITE/NAME",
+					Action:    a.CredLog,
+					Flags: []cli.Flag{
+						&cli.IntFlag{Name: "limit", Aliases: []string{"n"}, Usage: "Show only the most recent N entries"},
+						&cli.BoolFlag{Name: "json", Usage: "Output as JSON"},
 					},
 				},
 				{
@@ -88,6 +256,7 @@ func (a *Action) GetCommands() []*cli.Command {
 						&cli.StringFlag{Name: "password", Aliases: []string{"p"}, Usage: "Password (or use --generate)"},
 						&cli.BoolFlag{Name: "generate", Aliases: []string{"g"}, Usage: "Generate password"},
 						&cli.IntFlag{Name: "length", Aliases: []string{"l"}, Value: 24, Usage: "Generated password length"},
+						&cli.BoolFlag{Name: "sensitive", Usage: "Audit and report every read to the store's webhook"},
 					},
 				},
 				{
@@ -95,6 +264,18 @@ func (a *Action) GetCommands() []*cli.Command {
 					Usage:     "Edit a credential",
 					ArgsUsage: "WEBSITE/NAME",
 					Action:    a.CredEdit,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "editor", Aliases: []string{"e"}, Usage: "Edit all fields in your editor instead of prompting"},
+					},
+				},
+				{
+					Name:      "sensitive",
+					Usage:     "Mark a credential as sensitive, so every read is audited and reported",
+					ArgsUsage: "WEBSITE/NAME",
+					Action:    a.CredSensitive,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "off", Usage: "Clear the mark"},
+					},
 				},
 				{
 					Name:      "rm",
@@ -112,6 +293,21 @@ func (a *Action) GetCommands() []*cli.Command {
 					Usage:     "Copy password to clipboard",
 					ArgsUsage: "WEBSITE/NAME",
 					Action:    a.CredCopy,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "username-then-password", Aliases: []string{"u"}, Usage: "Copy the username, then the password after Enter or a second invocation"},
+						&cli.BoolFlag{Name: "password-only", Usage: "Copy only the password, ignoring preferences.copy_username_first"},
+					},
+				},
+				{
+					Name:      "type",
+					Usage:     "Type username and password into the focused window instead of using the clipboard",
+					ArgsUsage: "WEBSITE/NAME|URL",
+					Action:    a.CredType,
+					Flags: []cli.Flag{
+						&cli.IntFlag{Name: "delay", Value: 2, Usage: "Seconds to wait before typing, to focus the field"},
+						&cli.BoolFlag{Name: "password-only", Usage: "Type only the password"},
+						&cli.BoolFlag{Name: "enter", Usage: "Press Enter after the password"},
+					},
 				},
 				// Access management
 				{
@@ -123,6 +319,9 @@ func (a *Action) GetCommands() []*cli.Command {
 							Usage:     "List who has access to a credential",
 							ArgsUsage: "WEBSITE/NAME",
 							Action:    a.CredAccessList,
+							Flags: []cli.Flag{
+								&cli.BoolFlag{Name: "effective", Usage: "Also list the keys it's encrypted for and check them against the file's age header"},
+							},
 						},
 						{
 							Name:      "grant",
@@ -131,6 +330,7 @@ func (a *Action) GetCommands() []*cli.Command {
 							Action:    a.CredAccessGrant,
 							Flags: []cli.Flag{
 								&cli.StringFlag{Name: "level", Aliases: []string{"l"}, Value: "read", Usage: "Access level: read or write"},
+								&cli.StringFlag{Name: "expires", Usage: "When the grant ends: a date (2026-12-31) or duration (30d)"},
 							},
 						},
 						{
@@ -166,6 +366,29 @@ func (a *Action) GetCommands() []*cli.Command {
 					Flags: []cli.Flag{
 						&cli.BoolFlag{Name: "export", Usage: "Format as export statements"},
 						&cli.BoolFlag{Name: "dotenv", Usage: "Format as .env file"},
+						&cli.BoolFlag{Name: "reveal", Usage: "Show secret values (requires write access)"},
+						&cli.BoolFlag{Name: "long", Aliases: []string{"l"}, Usage: "Show owners, descriptions and when each variable last changed"},
+						&cli.BoolFlag{Name: "json", Usage: "Output as JSON, including metadata"},
+					},
+				},
+				{
+					Name:      "log",
+					Usage:     "Show who changed, read and re-encrypted an environment, from git and the audit log",
+					ArgsUsage: "PROJECT STAGE",
+					Action:    a.EnvLog,
+					Flags: []cli.Flag{
+						&cli.IntFlag{Name: "limit", Aliases: []string{"n"}, Usage: "Show only the most recent N entries"},
+						&cli.BoolFlag{Name: "json", Usage: "Output as JSON"},
+					},
+				},
+				{
+					Name:      "describe",
+					Usage:     "Set the description or owner of a variable",
+					ArgsUsage: "PROJECT STAGE KEY [DESCRIPTION]",
+					Action:    a.EnvDescribe,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "owner", Usage: "Team member responsible for the variable (empty to clear)"},
+						&cli.BoolFlag{Name: "no-commit", Usage: "Leave the change uncommitted; finish with 'passbook commit'"},
 					},
 				},
 				{
@@ -175,6 +398,8 @@ func (a *Action) GetCommands() []*cli.Command {
 					Action:    a.EnvSet,
 					Flags: []cli.Flag{
 						&cli.BoolFlag{Name: "secret", Aliases: []string{"s"}, Value: true, Usage: "Mark as secret"},
+						&cli.BoolFlag{Name: "public", Usage: "Mark as non-secret config, shown unmasked and included in --public-only exports"},
+						&cli.BoolFlag{Name: "no-commit", Usage: "Leave the change uncommitted; finish with 'passbook commit'"},
 					},
 				},
 				{
@@ -183,28 +408,96 @@ func (a *Action) GetCommands() []*cli.Command {
 					Usage:     "Remove an environment variable",
 					ArgsUsage: "PROJECT STAGE KEY",
 					Action:    a.EnvRemove,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "no-commit", Usage: "Leave the change uncommitted; finish with 'passbook commit'"},
+					},
+				},
+				{
+					Name:      "edit",
+					Usage:     "Edit all variables of an environment in your editor",
+					ArgsUsage: "PROJECT STAGE",
+					Action:    a.EnvEdit,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "no-commit", Usage: "Leave the change uncommitted; finish with 'passbook commit'"},
+					},
 				},
 				{
 					Name:      "export",
-					Usage:     "Export as .env file",
+					Usage:     "Export as .env, JSON, YAML or TOML",
 					ArgsUsage: "PROJECT STAGE",
 					Action:    a.EnvExport,
 					Flags: []cli.Flag{
 						&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "Output file (default: stdout)"},
-						&cli.StringFlag{Name: "format", Aliases: []string{"f"}, Value: "dotenv", Usage: "Format: dotenv, export, json"},
+						&cli.StringFlag{Name: "format", Aliases: []string{"f"}, Value: "dotenv", Usage: "Format: dotenv, export, json, yaml, toml, or systemd-creds for a dotenv encrypted with systemd-creds"},
+						&cli.StringFlag{Name: "separator", Value: envformat.DefaultSeparator, Usage: "Nest json/yaml/toml keys at this separator (empty keeps them flat)"},
+						&cli.StringFlag{Name: "token", EnvVars: []string{"PASSBOOK_TOKEN"}, Usage: "Redeem a token instead of using your identity"},
+						&cli.BoolFlag{Name: "public-only", Usage: "Leave out secrets, for sharing safe config"},
+						&cli.BoolFlag{Name: "tmpfs", Usage: "Write only to a ramdisk, refusing paths on disk (default output: a file in /run/user/UID or /dev/shm)"},
+						&cli.StringFlag{Name: "owner", Usage: "Give the written file to USER[:GROUP], for a service running as another user"},
+						&cli.StringFlag{Name: "credential-name", Usage: "Name of the systemd credential (default: passbook-PROJECT-STAGE)"},
 					},
 				},
 				{
 					Name:      "import",
-					Usage:     "Import from .env file",
+					Usage:     "Import from a .env, JSON, YAML or TOML file",
 					ArgsUsage: "PROJECT STAGE FILE",
 					Action:    a.EnvImport,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "public", Usage: "Import the variables as non-secret config"},
+						&cli.StringFlag{Name: "format", Aliases: []string{"f"}, Usage: "Format: dotenv, json, yaml, toml (default: from the file extension)"},
+						&cli.StringFlag{Name: "separator", Value: envformat.DefaultSeparator, Usage: "Join nested keys with this separator"},
+						&cli.BoolFlag{Name: "no-commit", Usage: "Leave the change uncommitted; finish with 'passbook commit'"},
+					},
 				},
 				{
 					Name:      "exec",
 					Usage:     "Run command with environment variables",
 					ArgsUsage: "PROJECT STAGE -- COMMAND [ARGS...]",
 					Action:    a.EnvExec,
+					Flags: []cli.Flag{
+						&cli.StringSliceFlag{Name: "only", Usage: "Pass only these variables (comma-separated or repeated)"},
+						&cli.BoolFlag{Name: "mask", Usage: "Mask secret values in the command's output (output is no longer a terminal)"},
+						&cli.BoolFlag{Name: "via-fd", Usage: "Pass variables as a .env file on an inherited pipe, named by $PASSBOOK_ENV_FD, instead of the environment"},
+					},
+				},
+				{
+					Name:      "check",
+					Usage:     "Check an environment against the project schema",
+					ArgsUsage: "PROJECT STAGE",
+					Action:    a.EnvCheck,
+				},
+				{
+					Name:      "shellenv",
+					Aliases:   []string{"print-eval"},
+					Usage:     "Print statements that load variables into the current shell",
+					ArgsUsage: "PROJECT STAGE",
+					Action:    a.EnvShellenv,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "shell", Usage: "Shell to format for: bash, zsh, sh, fish, powershell (default: detected)"},
+					},
+				},
+				{
+					Name:      "serve",
+					Usage:     "Serve variables to local processes over a token-protected localhost endpoint for a dev session",
+					ArgsUsage: "PROJECT STAGE",
+					Action:    a.EnvServe,
+					Flags: []cli.Flag{
+						&cli.IntFlag{Name: "port", Value: 9999, Usage: "Port on 127.0.0.1 to listen on (0 picks a free one)"},
+						&cli.DurationFlag{Name: "ttl", Usage: "Stop serving after this long (default: until Ctrl-C)"},
+						&cli.BoolFlag{Name: "allow-prod", Usage: "Serve a prod environment"},
+					},
+				},
+				{
+					Name:      "watch",
+					Usage:     "Keep an exported file or running process in sync with the store",
+					ArgsUsage: "PROJECT STAGE",
+					Action:    a.EnvWatch,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "File to rewrite when variables change"},
+						&cli.StringFlag{Name: "format", Aliases: []string{"f"}, Value: "dotenv", Usage: "Format: dotenv, export, json, yaml, toml"},
+						&cli.StringFlag{Name: "exec", Usage: "Command to restart with the variables when they change"},
+						&cli.DurationFlag{Name: "interval", Aliases: []string{"i"}, Value: 30 * time.Second, Usage: "How often to pull from the remote"},
+					},
 				},
 				// Access management
 				{
@@ -216,6 +509,9 @@ func (a *Action) GetCommands() []*cli.Command {
 							Usage:     "List who has access to an environment",
 							ArgsUsage: "PROJECT STAGE",
 							Action:    a.EnvAccessList,
+							Flags: []cli.Flag{
+								&cli.BoolFlag{Name: "effective", Usage: "Also list the keys it's encrypted for and check them against the file's age header"},
+							},
 						},
 						{
 							Name:      "grant",
@@ -224,6 +520,7 @@ func (a *Action) GetCommands() []*cli.Command {
 							Action:    a.EnvAccessGrant,
 							Flags: []cli.Flag{
 								&cli.StringFlag{Name: "level", Aliases: []string{"l"}, Value: "read", Usage: "Access level: read or write"},
+								&cli.StringFlag{Name: "expires", Usage: "When the grant ends: a date (2026-12-31) or duration (30d)"},
 							},
 						},
 						{
@@ -237,6 +534,19 @@ func (a *Action) GetCommands() []*cli.Command {
 			},
 		},
 
+		// Templates
+		{
+			Name:      "render",
+			Usage:     "Render a config file template with secrets filled in",
+			ArgsUsage: "TEMPLATE",
+			Action:    a.Render,
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "project", Aliases: []string{"p"}, Usage: "Project for {{ env \"KEY\" }}"},
+				&cli.StringFlag{Name: "stage", Aliases: []string{"s"}, Usage: "Stage for {{ env \"KEY\" }}"},
+				&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "Output file (default: stdout)"},
+			},
+		},
+
 		// Project commands
 		{
 			Name:  "project",
@@ -246,6 +556,69 @@ func (a *Action) GetCommands() []*cli.Command {
 					Name:   "list",
 					Usage:  "List all projects",
 					Action: a.ProjectList,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "archived", Usage: "List archived projects instead"},
+						&cli.BoolFlag{Name: "long", Aliases: []string{"l"}, Usage: "Show stages, owners and descriptions in a table"},
+					},
+				},
+				{
+					Name:  "owner",
+					Usage: "Manage who administers a project without being an admin",
+					Subcommands: []*cli.Command{
+						{
+							Name:      "add",
+							Usage:     "Make a team member an owner of a project",
+							ArgsUsage: "PROJECT EMAIL",
+							Action:    a.ProjectOwnerAdd,
+						},
+						{
+							Name:      "rm",
+							Aliases:   []string{"remove"},
+							Usage:     "Remove an owner from a project",
+							ArgsUsage: "PROJECT EMAIL",
+							Action:    a.ProjectOwnerRemove,
+						},
+					},
+				},
+				{
+					Name:  "stage",
+					Usage: "Manage a project's stages",
+					Subcommands: []*cli.Command{
+						{
+							Name:      "add",
+							Usage:     "Add a stage to a project",
+							ArgsUsage: "PROJECT STAGE",
+							Action:    a.ProjectStageAdd,
+						},
+						{
+							Name:      "rm",
+							Aliases:   []string{"remove"},
+							Usage:     "Remove a stage from a project",
+							ArgsUsage: "PROJECT STAGE",
+							Action:    a.ProjectStageRemove,
+							Flags: []cli.Flag{
+								&cli.BoolFlag{Name: "force", Aliases: []string{"f"}, Usage: "Also delete the stage's variables"},
+							},
+						},
+					},
+				},
+				{
+					Name:  "schema",
+					Usage: "Manage the variables a project expects",
+					Subcommands: []*cli.Command{
+						{
+							Name:      "show",
+							Usage:     "Show a project's schema",
+							ArgsUsage: "NAME",
+							Action:    a.ProjectSchemaShow,
+						},
+						{
+							Name:      "edit",
+							Usage:     "Edit a project's schema in your editor",
+							ArgsUsage: "NAME",
+							Action:    a.ProjectSchemaEdit,
+						},
+					},
 				},
 				{
 					Name:      "create",
@@ -254,19 +627,35 @@ func (a *Action) GetCommands() []*cli.Command {
 					Action:    a.ProjectCreate,
 					Flags: []cli.Flag{
 						&cli.StringFlag{Name: "description", Aliases: []string{"d"}, Usage: "Project description"},
-						&cli.StringSliceFlag{Name: "stage", Aliases: []string{"s"}, Usage: "Stages (default: dev,staging,prod)"},
+						&cli.StringSliceFlag{Name: "stage", Aliases: []string{"s"}, Usage: "Stages (default: dev,staging,prod, or the template's)"},
+						&cli.StringFlag{Name: "from-template", Aliases: []string{"t"}, Usage: "Copy stages, variable keys, schema and permissions from a project or archived project"},
+						&cli.BoolFlag{Name: "prompt", Usage: "With --from-template, prompt for each variable's value"},
+						&cli.StringSliceFlag{Name: "owner", Usage: "Team member who manages the project (repeatable)"},
 					},
 				},
 				{
 					Name:      "rm",
 					Aliases:   []string{"remove", "delete"},
-					Usage:     "Remove a project",
+					Usage:     "Archive a project, or delete it permanently with --purge",
 					ArgsUsage: "NAME",
 					Action:    a.ProjectRemove,
 					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "purge", Usage: "Delete permanently instead of archiving"},
 						&cli.BoolFlag{Name: "force", Aliases: []string{"f"}, Usage: "Skip confirmation"},
 					},
 				},
+				{
+					Name:      "archive",
+					Usage:     "Hide a project from listings, keeping it recoverable",
+					ArgsUsage: "NAME",
+					Action:    a.ProjectArchive,
+				},
+				{
+					Name:      "unarchive",
+					Usage:     "Restore an archived project",
+					ArgsUsage: "NAME",
+					Action:    a.ProjectUnarchive,
+				},
 			},
 		},
 
@@ -287,8 +676,25 @@ func (a *Action) GetCommands() []*cli.Command {
 					ArgsUsage: "EMAIL",
 					Action:    a.TeamInvite,
 					Flags: []cli.Flag{
-						&cli.StringSliceFlag{Name: "role", Aliases: []string{"r"}, Usage: "Roles to assign (dev, staging-access, prod-access, admin)"},
+						&cli.StringSliceFlag{Name: "role", Aliases: []string{"r"}, Usage: "Roles to assign (dev, staging-access, prod-access, admin, viewer)"},
 						&cli.BoolFlag{Name: "skip-verify", Usage: "Skip key ownership verification"},
+						&cli.StringFlag{Name: "fingerprint", Usage: "Expected fingerprint of the entered public key"},
+						&cli.StringFlag{Name: "key-out", Usage: "Where to write a generated key bundle (default: ./EMAIL.passbook-key)"},
+						&cli.BoolFlag{Name: "show-passphrase", Usage: "Print a generated key bundle's passphrase instead of copying it"},
+						&cli.BoolFlag{Name: "link", Usage: "Create a sealed invite token for 'passbook join --invite'"},
+						&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "Write the invite token to a file"},
+						&cli.StringFlag{Name: "repo-url", Usage: "Repository URL to embed in the invite (default: store remote)"},
+						&cli.BoolFlag{Name: "external", Usage: "Add a collaborator outside the org's email domain (admin only; default role viewer)"},
+						&cli.StringFlag{Name: "expires", Usage: "When an external collaborator's access ends: a date (2026-12-31) or duration (90d)"},
+					},
+				},
+				{
+					Name:      "accept",
+					Usage:     "Add a member who joined with an invite",
+					ArgsUsage: "EMAIL",
+					Action:    a.TeamAccept,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "response", Usage: "Invite response token sent by the invitee"},
 					},
 				},
 				{
@@ -325,6 +731,20 @@ func (a *Action) GetCommands() []*cli.Command {
 					ArgsUsage: "EMAIL RESPONSE",
 					Action:    a.TeamVerify,
 				},
+				{
+					Name:      "rechallenge",
+					Usage:     "Issue a new verification challenge for a pending member",
+					ArgsUsage: "EMAIL",
+					Action:    a.TeamRechallenge,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "force", Aliases: []string{"f"}, Usage: "Replace a challenge that is still valid"},
+					},
+				},
+				{
+					Name:   "admins",
+					Usage:  "Show admins and admin quorum health",
+					Action: a.TeamAdmins,
+				},
 				{
 					Name:   "pending",
 					Usage:  "List pending verifications",
@@ -341,62 +761,546 @@ func (a *Action) GetCommands() []*cli.Command {
 					ArgsUsage: "EMAIL PUBLIC_KEY",
 					Action:    a.TeamAddVerified,
 					Flags: []cli.Flag{
-						&cli.StringSliceFlag{Name: "role", Aliases: []string{"r"}, Usage: "Roles to assign (dev, staging-access, prod-access, admin)"},
+						&cli.StringSliceFlag{Name: "role", Aliases: []string{"r"}, Usage: "Roles to assign (dev, staging-access, prod-access, admin, viewer)"},
+						&cli.Int64Flag{Name: "github-id", Usage: "GitHub account ID shown by 'passbook team join'"},
+						&cli.StringFlag{Name: "github", Usage: "GitHub login shown by 'passbook team join'"},
+						&cli.BoolFlag{Name: "external", Usage: "Add a collaborator outside the org's email domain (default role viewer)"},
+						&cli.StringFlag{Name: "expires", Usage: "When an external collaborator's access ends: a date (2026-12-31) or duration (90d)"},
+					},
+				},
+				{
+					Name:      "extend",
+					Usage:     "Change when an external collaborator's access ends (admin only)",
+					ArgsUsage: "EMAIL",
+					Action:    a.TeamExtend,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "expires", Usage: "New end of access: a date (2026-12-31) or duration (90d)"},
+					},
+				},
+				{
+					Name:      "bind-github",
+					Usage:     "Bind a member's GitHub account to their record (admin only)",
+					ArgsUsage: "EMAIL",
+					Action:    a.TeamBindGitHub,
+					Flags: []cli.Flag{
+						&cli.Int64Flag{Name: "github-id", Usage: "GitHub account ID"},
+						&cli.StringFlag{Name: "github", Usage: "GitHub login"},
 					},
 				},
 			},
 		},
 
-		// Key management commands
+		// Note commands
 		{
-			Name:  "key",
-			Usage: "Manage encryption keys",
+			Name:    "note",
+			Aliases: []string{"notes"},
+			Usage:   "Manage encrypted notes such as runbooks and recovery codes",
 			Subcommands: []*cli.Command{
 				{
-					Name:   "show",
-					Usage:  "Show your public key",
-					Action: a.KeyShow,
+					Name:    "list",
+					Aliases: []string{"ls"},
+					Usage:   "List notes",
+					Action:  a.NoteList,
 				},
 				{
-					Name:   "encrypt",
-					Usage:  "Encrypt your private key with a passphrase",
-					Action: a.KeyEncrypt,
+					Name:      "show",
+					Usage:     "Show a note",
+					ArgsUsage: "NAME",
+					Action:    a.NoteShow,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "raw", Usage: "Print only the note's content"},
+					},
 				},
 				{
-					Name:   "decrypt",
-					Usage:  "Remove passphrase from your private key",
-					Action: a.KeyDecrypt,
+					Name:      "add",
+					Usage:     "Add a note from --file, stdin or your editor",
+					ArgsUsage: "NAME",
+					Action:    a.NoteAdd,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "file", Aliases: []string{"f"}, Usage: "Read the content from a file (- for stdin)"},
+						&cli.StringSliceFlag{Name: "tag", Aliases: []string{"t"}, Usage: "Tags"},
+					},
 				},
 				{
-					Name:   "change-passphrase",
-					Usage:  "Change passphrase on your private key",
-					Action: a.KeyChangePassphrase,
+					Name:      "edit",
+					Usage:     "Edit a note in your editor, or replace it from --file",
+					ArgsUsage: "NAME",
+					Action:    a.NoteEdit,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "file", Aliases: []string{"f"}, Usage: "Read the new content from a file (- for stdin)"},
+						&cli.StringSliceFlag{Name: "tag", Aliases: []string{"t"}, Usage: "Replace the tags"},
+					},
+				},
+				{
+					Name:      "rm",
+					Usage:     "Delete a note",
+					ArgsUsage: "NAME",
+					Action:    a.NoteRemove,
 				},
 			},
 		},
 
-		// Verify key ownership (for new users)
-		{
-			Name:   "verify-key",
-			Usage:  "Prove ownership of your private key (for new users)",
-			Action: a.VerifyKey,
-			Flags: []cli.Flag{
-				&cli.StringFlag{Name: "challenge-file", Usage: "File containing the encrypted challenge"},
-				&cli.StringFlag{Name: "challenge", Usage: "Base64 encoded encrypted challenge"},
-			},
-		},
-
-		// Re-encryption commands
+		// SSH key commands
 		{
-			Name:   "reencrypt",
-			Usage:  "Re-encrypt secrets with current recipients",
-			Action: a.ReEncryptAll,
-			Flags: []cli.Flag{
-				&cli.BoolFlag{Name: "force", Aliases: []string{"f"}, Usage: "Skip confirmation"},
-			},
-		},
-
-		// Audit commands
+			Name:  "ssh",
+			Usage: "Manage SSH private keys and load them into ssh-agent",
+			Subcommands: []*cli.Command{
+				{
+					Name:    "list",
+					Aliases: []string{"ls"},
+					Usage:   "List SSH keys with their fingerprints",
+					Action:  a.SSHList,
+				},
+				{
+					Name:      "add",
+					Usage:     "Store an SSH private key from --file or stdin",
+					ArgsUsage: "NAME",
+					Action:    a.SSHAdd,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "file", Aliases: []string{"f"}, Usage: "Private key file (default: stdin)"},
+						&cli.StringFlag{Name: "comment", Usage: "Comment shown by ssh-add -l (default: NAME)"},
+					},
+				},
+				{
+					Name:      "load",
+					Usage:     "Add a stored key to the running ssh-agent",
+					ArgsUsage: "NAME",
+					Action:    a.SSHLoad,
+					Flags: []cli.Flag{
+						&cli.DurationFlag{Name: "lifetime", Aliases: []string{"t"}, Value: time.Hour, Usage: "How long the agent keeps the key"},
+						&cli.BoolFlag{Name: "confirm", Aliases: []string{"c"}, Usage: "Have the agent confirm each use of the key"},
+					},
+				},
+				{
+					Name:      "rm",
+					Usage:     "Delete a stored SSH key",
+					ArgsUsage: "NAME",
+					Action:    a.SSHRemove,
+				},
+			},
+		},
+
+		// Certificate commands
+		{
+			Name:    "cert",
+			Aliases: []string{"certs"},
+			Usage:   "Track X.509 certificates and their expiry",
+			Subcommands: []*cli.Command{
+				{
+					Name:    "list",
+					Aliases: []string{"ls"},
+					Usage:   "List certificates, soonest expiry first",
+					Action:  a.CertList,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "expiring", Aliases: []string{"e"}, Usage: "Only those expiring within a window, e.g. 30d"},
+					},
+				},
+				{
+					Name:      "show",
+					Usage:     "Show a certificate's details",
+					ArgsUsage: "NAME",
+					Action:    a.CertShow,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "pem", Usage: "Print the PEM certificate chain"},
+						&cli.BoolFlag{Name: "key", Usage: "Print the PEM private key"},
+					},
+				},
+				{
+					Name:      "add",
+					Usage:     "Import a PEM certificate chain from --file or stdin",
+					ArgsUsage: "NAME",
+					Action:    a.CertAdd,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "file", Aliases: []string{"f"}, Usage: "PEM certificate chain, leaf first (default: stdin)"},
+						&cli.StringFlag{Name: "key", Aliases: []string{"k"}, Usage: "PEM private key to store with it"},
+					},
+				},
+				{
+					Name:      "rm",
+					Usage:     "Delete a certificate",
+					ArgsUsage: "NAME",
+					Action:    a.CertRemove,
+				},
+			},
+		},
+
+		// Store commands
+		{
+			Name:  "store",
+			Usage: "Manage the store as a whole",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "migrate",
+					Usage:  "Move the store to a new remote, optionally renaming the org and its email domain (admin)",
+					Action: a.StoreMigrate,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "new-remote", Usage: "Git remote to move the store to"},
+						&cli.StringFlag{Name: "new-domain", Usage: "Move members' emails to this domain"},
+						&cli.StringFlag{Name: "old-domain", Usage: "Domain members are moving from (default: the allowed domain)"},
+						&cli.StringFlag{Name: "org", Usage: "New organization name"},
+						&cli.BoolFlag{Name: "dry-run", Usage: "Show what would change without changing it"},
+					},
+				},
+				{
+					Name:   "split",
+					Usage:  "Move projects, their members and audit history into a new store (admin)",
+					Action: a.StoreSplit,
+					Flags: []cli.Flag{
+						&cli.StringSliceFlag{Name: "projects", Usage: "Projects to move (comma-separated or repeatable)"},
+						&cli.StringFlag{Name: "to", Usage: "Git remote of the new store"},
+						&cli.BoolFlag{Name: "keep", Usage: "Keep the projects in this store too"},
+					},
+				},
+				{
+					Name:      "merge",
+					Usage:     "Bring another store's secrets, members and audit history into this one (admin)",
+					ArgsUsage: "OTHER_REPO",
+					Action:    a.StoreMerge,
+				},
+			},
+		},
+
+		// Publish commands
+		{
+			Name:      "publish",
+			Usage:     "Publish a filtered, re-encrypted mirror of the store to another repo",
+			ArgsUsage: "[NAME...]",
+			Action:    a.Publish,
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "to", Usage: "Git remote of the mirror"},
+				&cli.StringFlag{Name: "filter", Usage: "Secrets to publish as key=glob terms over kind, project, stage, website, name and tag, e.g. 'project=website stage=dev'"},
+				&cli.StringSliceFlag{Name: "recipient", Usage: "age public key to encrypt for (repeatable)"},
+				&cli.StringFlag{Name: "save", Usage: "Save the target under this name"},
+				&cli.BoolFlag{Name: "auto", Usage: "Republish the saved target after every change to the store"},
+				&cli.BoolFlag{Name: "all", Usage: "Republish every saved target"},
+			},
+			Subcommands: []*cli.Command{
+				{
+					Name:   "list",
+					Usage:  "List saved publish targets",
+					Action: a.PublishList,
+				},
+				{
+					Name:      "rm",
+					Usage:     "Forget a saved publish target",
+					ArgsUsage: "NAME",
+					Action:    a.PublishRemove,
+				},
+			},
+		},
+
+		// Export commands
+		{
+			Name:  "export",
+			Usage: "Export secrets for other tools",
+			Subcommands: []*cli.Command{
+				{
+					Name:      "pass",
+					Usage:     "Write a read-only password-store mirror encrypted for GPG keys",
+					ArgsUsage: "DIR",
+					Action:    a.ExportPass,
+					Flags: []cli.Flag{
+						&cli.StringSliceFlag{Name: "gpg-id", Usage: "GPG key to encrypt for (repeatable)"},
+						&cli.BoolFlag{Name: "env", Usage: "Include the env stages you can read"},
+					},
+				},
+			},
+		},
+
+		// Personal credentials
+		{
+			Name:  "personal",
+			Usage: "Manage personal credentials, encrypted only for you",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "list",
+					Usage:  "List your personal credentials",
+					Action: a.PersonalList,
+				},
+				{
+					Name:      "show",
+					Usage:     "Show a personal credential",
+					ArgsUsage: "WEBSITE/NAME",
+					Action:    a.PersonalShow,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "clip", Aliases: []string{"c"}, Usage: "Copy password to clipboard"},
+						&cli.BoolFlag{Name: "password", Aliases: []string{"p"}, Usage: "Show only password"},
+					},
+				},
+				{
+					Name:      "add",
+					Usage:     "Add a personal credential",
+					ArgsUsage: "WEBSITE",
+					Action:    a.PersonalAdd,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "name", Aliases: []string{"n"}, Usage: "Account name"},
+						&cli.StringFlag{Name: "username", Aliases: []string{"u"}, Usage: "Username"},
+						&cli.StringFlag{Name: "password", Aliases: []string{"p"}, Usage: "Password (or use --generate)"},
+						&cli.BoolFlag{Name: "generate", Aliases: []string{"g"}, Usage: "Generate password"},
+						&cli.IntFlag{Name: "length", Aliases: []string{"l"}, Value: 24, Usage: "Generated password length"},
+					},
+				},
+				{
+					Name:      "rm",
+					Usage:     "Delete a personal credential",
+					ArgsUsage: "WEBSITE/NAME",
+					Action:    a.PersonalRemove,
+				},
+			},
+		},
+
+		// Service account commands
+		{
+			Name:    "service-account",
+			Aliases: []string{"sa"},
+			Usage:   "Manage machine identities for CI and servers",
+			Subcommands: []*cli.Command{
+				{
+					Name:    "list",
+					Aliases: []string{"ls"},
+					Usage:   "List service accounts",
+					Action:  a.ServiceAccountList,
+				},
+				{
+					Name:      "create",
+					Usage:     "Create a service account with a dedicated key",
+					ArgsUsage: "NAME",
+					Action:    a.ServiceAccountCreate,
+					Flags: []cli.Flag{
+						&cli.StringSliceFlag{Name: "role", Aliases: []string{"r"}, Usage: "Roles to assign (dev, staging-access, prod-access)"},
+						&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "Where to write the private key (default: NAME.key)"},
+						&cli.StringFlag{Name: "public-key", Usage: "Register a key the machine generated itself, such as with 'passbook provision', instead of generating one"},
+					},
+				},
+				{
+					Name:      "rm",
+					Usage:     "Remove a service account",
+					ArgsUsage: "NAME",
+					Action:    a.ServiceAccountRemove,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "force", Aliases: []string{"f"}, Usage: "Skip confirmation"},
+					},
+				},
+			},
+		},
+
+		// Token commands
+		{
+			Name:  "token",
+			Usage: "Manage short-lived scoped tokens",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "create",
+					Usage:  "Create a time-limited token for one project stage",
+					Action: a.TokenCreate,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "project", Aliases: []string{"p"}, Usage: "Project name"},
+						&cli.StringFlag{Name: "stage", Aliases: []string{"s"}, Usage: "Stage (dev, staging, prod)"},
+						&cli.DurationFlag{Name: "ttl", Value: time.Hour, Usage: "Token lifetime"},
+						&cli.StringFlag{Name: "service-account", Usage: "Service account the token is issued for"},
+					},
+				},
+				{
+					Name:    "list",
+					Aliases: []string{"ls"},
+					Usage:   "List issued tokens",
+					Action:  a.TokenList,
+				},
+				{
+					Name:      "revoke",
+					Usage:     "Revoke a token",
+					ArgsUsage: "ID",
+					Action:    a.TokenRevoke,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "expired", Usage: "Remove all expired tokens"},
+					},
+				},
+			},
+		},
+
+		// Key management commands
+		{
+			Name:  "key",
+			Usage: "Manage encryption keys",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "show",
+					Usage:  "Show your public key",
+					Action: a.KeyShow,
+				},
+				{
+					Name:      "fingerprint",
+					Usage:     "Show a short fingerprint of a public key for comparison",
+					ArgsUsage: "[PUBLIC_KEY]",
+					Action:    a.KeyFingerprint,
+				},
+				{
+					Name:   "qr",
+					Usage:  "Show your public key as a QR code",
+					Action: a.KeyQR,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "invert", Usage: "Invert colors for light terminal backgrounds"},
+					},
+				},
+				{
+					Name:      "import",
+					Usage:     "Import your private key from a sealed key bundle",
+					ArgsUsage: "BUNDLE",
+					Action:    a.KeyImport,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "force", Aliases: []string{"f"}, Usage: "Replace an existing identity"},
+					},
+				},
+				{
+					Name:   "encrypt",
+					Usage:  "Encrypt your private key with a passphrase",
+					Action: a.KeyEncrypt,
+				},
+				{
+					Name:   "decrypt",
+					Usage:  "Remove passphrase from your private key",
+					Action: a.KeyDecrypt,
+				},
+				{
+					Name:   "change-passphrase",
+					Usage:  "Change passphrase on your private key",
+					Action: a.KeyChangePassphrase,
+				},
+				{
+					Name:      "wrap",
+					Usage:     "Encrypt your private key with an AWS or Cloud KMS key, unlocked with IAM instead of (or as well as) a passphrase",
+					ArgsUsage: "aws-kms://ARN | gcp-kms://projects/P/locations/L/keyRings/R/cryptoKeys/K",
+					Action:    a.KeyWrap,
+				},
+				{
+					Name:   "unwrap",
+					Usage:  "Remove KMS wrapping from your private key",
+					Action: a.KeyUnwrap,
+				},
+				{
+					Name:   "hsm",
+					Usage:  "Use an X25519 key on a PKCS#11 token or HSM as your identity (needs OpenSC's pkcs11-tool)",
+					Action: a.KeyHSM,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "module", Usage: "Path to the token's PKCS#11 module, such as /usr/lib/softhsm/libsofthsm2.so"},
+						&cli.StringFlag{Name: "id", Usage: "Hex id of the key on the token"},
+						&cli.StringFlag{Name: "token", Usage: "Token label, when more than one is present"},
+					},
+				},
+			},
+		},
+
+		// Verify key ownership (for new users)
+		{
+			Name:   "verify-key",
+			Usage:  "Prove ownership of your private key (for new users)",
+			Action: a.VerifyKey,
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "challenge-file", Usage: "File containing the encrypted challenge"},
+				&cli.StringFlag{Name: "challenge", Usage: "Base64 encoded encrypted challenge"},
+			},
+		},
+
+		// Store migrations
+		{
+			Name:   "migrate",
+			Usage:  "Upgrade the store to the current format and fix issues left by older versions",
+			Action: a.Migrate,
+			Subcommands: []*cli.Command{
+				{
+					Name:   "purge-pending-keys",
+					Usage:  "Remove private keys generated into .pending-keys/ from history",
+					Action: a.MigratePurgePendingKeys,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "force", Aliases: []string{"f"}, Usage: "Skip confirmation"},
+					},
+				},
+				{
+					Name:   "users",
+					Usage:  "Encrypt the plaintext .passbook-users file of older stores",
+					Action: a.MigrateUsers,
+				},
+			},
+		},
+
+		// Git hooks
+		{
+			Name:  "hooks",
+			Usage: "Manage git hooks that prevent secret leaks",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "install",
+					Usage:  "Install a pre-commit hook that blocks plaintext secrets",
+					Action: a.HooksInstall,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "repo", Usage: "Repository to install into (default: the store)"},
+						&cli.BoolFlag{Name: "force", Aliases: []string{"f"}, Usage: "Replace an existing pre-commit hook"},
+					},
+				},
+				{
+					Name:   "check",
+					Usage:  "Scan staged files for plaintext secrets (run by the hook)",
+					Action: a.HooksCheck,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "repo", Value: ".", Usage: "Repository to check"},
+					},
+				},
+			},
+		},
+
+		// Plugins and local hooks
+		{
+			Name:  "plugins",
+			Usage: "Extend passbook with passbook-NAME commands on PATH and local pre/post hooks",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "list",
+					Usage:  "List installed plugins and hooks",
+					Action: a.PluginsList,
+				},
+			},
+		},
+
+		// Secret scanning
+		{
+			Name:      "scan",
+			Usage:     "Scan a codebase for hard-coded secrets",
+			ArgsUsage: "[PATH]",
+			Action:    a.Scan,
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "format", Aliases: []string{"f"}, Value: "text", Usage: "Output format: text, sarif"},
+				&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "Output file (default: stdout)"},
+				&cli.BoolFlag{Name: "no-store", Usage: "Only use generic patterns, don't compare against store values"},
+			},
+		},
+
+		// Per-secret access commands
+		{
+			Name:  "access",
+			Usage: "Manage per-secret access grants across the store",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "expire",
+					Usage:  "Remove expired grants and re-encrypt the secrets they were on; run it on a schedule",
+					Action: a.AccessExpire,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "dry-run", Aliases: []string{"n"}, Usage: "Only list the expired grants"},
+					},
+				},
+			},
+		},
+
+		// Re-encryption commands
+		{
+			Name:   "reencrypt",
+			Usage:  "Re-encrypt secrets with current recipients",
+			Action: a.ReEncryptAll,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{Name: "force", Aliases: []string{"f"}, Usage: "Skip confirmation"},
+			},
+		},
+
+		// Audit commands
 		{
 			Name:  "audit",
 			Usage: "View audit logs",
@@ -409,18 +1313,190 @@ func (a *Action) GetCommands() []*cli.Command {
 						&cli.StringFlag{Name: "actor", Usage: "Filter by actor email"},
 						&cli.StringFlag{Name: "target", Usage: "Filter by target"},
 						&cli.StringFlag{Name: "type", Usage: "Filter by event type"},
-						&cli.StringFlag{Name: "since", Usage: "Show events since (duration or date)"},
+						&cli.StringFlag{Name: "since", Usage: "Show events since a time: 30d, 2w, 12h, 2026-01-31, an RFC 3339 time, today or yesterday"},
+						&cli.StringFlag{Name: "until", Usage: "Show events up to a time, in the same forms as --since; a date includes the whole day"},
 						&cli.IntFlag{Name: "limit", Aliases: []string{"n"}, Value: 50, Usage: "Max events to show"},
+						&cli.IntFlag{Name: "page", Value: 1, Usage: "Page of --limit events to show, counting back from the newest"},
+						&cli.StringFlag{Name: "cursor", Usage: "Continue from where a previous page stopped"},
 					},
 				},
 				{
 					Name:   "stats",
 					Usage:  "Show audit statistics",
 					Action: a.AuditStats,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "anomalies", Usage: "Flag read spikes, prod reads outside working hours and reads shortly before a removal"},
+						&cli.StringFlag{Name: "work-hours", Value: "7-20", Usage: "Local working hours for --anomalies, as START-END"},
+					},
+				},
+				{
+					Name:   "evidence",
+					Usage:  "Write a zip of compliance evidence for a quarter, with a manifest of hashes",
+					Action: a.AuditEvidence,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "quarter", Aliases: []string{"q"}, Usage: "Quarter to cover, like 2024Q3"},
+						&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "Zip file to write (default passbook-evidence-QUARTER.zip)"},
+					},
+				},
+				{
+					Name:   "stale",
+					Usage:  "Flag secrets not read or updated recently",
+					Action: a.AuditStale,
+					Flags: []cli.Flag{
+						&cli.IntFlag{Name: "days", Value: 180, Usage: "Window in days"},
+					},
+				},
+			},
+		},
+
+		// Re-encryption campaigns
+		{
+			Name:  "campaign",
+			Usage: "Re-encrypt every secret over time, shared between admins",
+			Subcommands: []*cli.Command{
+				{
+					Name:      "start",
+					Usage:     "Start a campaign covering every secret in the store",
+					ArgsUsage: "NAME",
+					Action:    a.CampaignStart,
+				},
+				{
+					Name:      "run",
+					Usage:     "Re-encrypt the campaign's remaining files you can decrypt",
+					ArgsUsage: "NAME",
+					Action:    a.CampaignRun,
+					Flags: []cli.Flag{
+						&cli.IntFlag{Name: "limit", Aliases: []string{"n"}, Usage: "Re-encrypt at most N files"},
+					},
+				},
+				{
+					Name:      "status",
+					Usage:     "Show a campaign's progress and remaining files",
+					ArgsUsage: "NAME",
+					Action:    a.CampaignStatus,
+				},
+				{
+					Name:   "list",
+					Usage:  "List campaigns",
+					Action: a.CampaignList,
+				},
+				{
+					Name:      "complete",
+					Usage:     "Mark a campaign finished once every file is re-encrypted",
+					ArgsUsage: "NAME",
+					Action:    a.CampaignComplete,
+				},
+			},
+		},
+
+		{
+			Name:  "hold",
+			Usage: "Protect paths from deletion and re-encryption without a recorded approval",
+			Subcommands: []*cli.Command{
+				{
+					Name:      "add",
+					Usage:     "Place a path, such as credentials/github.com/team or projects/api, under legal hold",
+					ArgsUsage: "PATH",
+					Action:    a.HoldAdd,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "reason", Aliases: []string{"r"}, Usage: "Why the path is held, e.g. an incident or retention reference"},
+					},
+				},
+				{
+					Name:   "list",
+					Usage:  "List paths under legal hold",
+					Action: a.HoldList,
+				},
+				{
+					Name:      "approve",
+					Usage:     "Allow one deletion or re-encryption under a hold",
+					ArgsUsage: "PATH",
+					Action:    a.HoldApprove,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "reason", Aliases: []string{"r"}, Usage: "Why the change is approved"},
+					},
+				},
+				{
+					Name:      "release",
+					Usage:     "Lift a legal hold",
+					ArgsUsage: "PATH",
+					Action:    a.HoldRelease,
+				},
+			},
+		},
+
+		// Layout commands
+		{
+			Name:   "layout",
+			Usage:  "Show or change which branch each environment's secrets live on",
+			Action: a.Layout,
+			Subcommands: []*cli.Command{
+				{
+					Name:      "prod-branch",
+					Usage:     "Move prod secrets to their own branch, which can be protected separately",
+					ArgsUsage: "BRANCH",
+					Action:    a.LayoutProdBranch,
+				},
+				{
+					Name:   "single",
+					Usage:  "Move prod secrets back to the main branch",
+					Action: a.LayoutSingle,
 				},
 			},
 		},
 
+		// Review commands
+		{
+			Name:  "review",
+			Usage: "Review proposed changes to prod secrets (see 'passbook config set review.prod true')",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "list",
+					Usage:  "List proposals waiting for review",
+					Action: a.ReviewList,
+				},
+				{
+					Name:      "show",
+					Usage:     "Show a proposal's change with secret values masked",
+					ArgsUsage: "ID",
+					Action:    a.ReviewShow,
+				},
+				{
+					Name:      "approve",
+					Usage:     "Merge another member's proposal",
+					ArgsUsage: "ID",
+					Action:    a.ReviewApprove,
+				},
+				{
+					Name:      "reject",
+					Usage:     "Discard a proposal, or withdraw your own",
+					ArgsUsage: "ID",
+					Action:    a.ReviewReject,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "reason", Aliases: []string{"r"}, Usage: "Why the proposal is rejected"},
+					},
+				},
+			},
+		},
+
+		// Password generation
+		{
+			Name:   "generate",
+			Usage:  "Generate a random password without storing it",
+			Action: a.Generate,
+			Flags: []cli.Flag{
+				&cli.IntFlag{Name: "length", Aliases: []string{"l"}, Value: 24, Usage: "Password length"},
+				&cli.Float64Flag{Name: "entropy", Aliases: []string{"e"}, Usage: "Lengthen the password until it has at least this many bits"},
+				&cli.BoolFlag{Name: "no-symbols", Usage: "Only use letters and digits"},
+				&cli.StringFlag{Name: "exclude", Usage: "Characters to leave out"},
+				&cli.BoolFlag{Name: "memorable", Aliases: []string{"m"}, Usage: "Generate words instead of characters"},
+				&cli.IntFlag{Name: "words", Usage: "Number of words in a memorable password (default: 6)"},
+				&cli.StringFlag{Name: "separator", Value: "-", Usage: "Between the words of a memorable password"},
+				&cli.StringFlag{Name: "site", Usage: "Follow the generator profile for this site"},
+				&cli.BoolFlag{Name: "copy", Aliases: []string{"c"}, Usage: "Copy to the clipboard instead of printing"},
+			},
+		},
+
 		// Secret rotation commands
 		{
 			Name:  "rotate",
@@ -432,8 +1508,10 @@ func (a *Action) GetCommands() []*cli.Command {
 					Action: a.RotateSecrets,
 					Flags: []cli.Flag{
 						&cli.BoolFlag{Name: "after-revoke", Usage: "Show checklist after revoking a user"},
-						&cli.StringFlag{Name: "user", Usage: "Email of revoked user"},
+						&cli.StringFlag{Name: "user", Usage: "Email of revoked user; with --clean-history, only remove history they could decrypt"},
+						&cli.StringSliceFlag{Name: "key", Usage: "Revoked user's public key, if the store never listed it (repeatable)"},
 						&cli.BoolFlag{Name: "clean-history", Usage: "Clean git history (dangerous)"},
+						&cli.BoolFlag{Name: "force", Aliases: []string{"f"}, Usage: "Skip confirmation"},
 					},
 				},
 				{
@@ -446,6 +1524,14 @@ func (a *Action) GetCommands() []*cli.Command {
 		},
 
 		// Sync commands
+		{
+			Name:   "commit",
+			Usage:  "Commit changes made with --no-commit as one commit and sync",
+			Action: a.Commit,
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "message", Aliases: []string{"m"}, Usage: "Commit message (default: summary of queued changes)"},
+			},
+		},
 		{
 			Name:   "sync",
 			Usage:  "Sync with git remote",
@@ -453,7 +1539,69 @@ func (a *Action) GetCommands() []*cli.Command {
 			Flags: []cli.Flag{
 				&cli.BoolFlag{Name: "push", Usage: "Only push"},
 				&cli.BoolFlag{Name: "pull", Usage: "Only pull"},
+				&cli.BoolFlag{Name: "repair", Usage: "Bring a store that failed to sync back in step with its remote"},
+				&cli.BoolFlag{Name: "ours", Usage: "With --repair, keep your version of secrets changed on both sides"},
+				&cli.BoolFlag{Name: "theirs", Usage: "With --repair, reset to the remote, keeping your commits on a backup branch"},
+			},
+		},
+		{
+			Name:   "reclone",
+			Usage:  "Replace the local store with a fresh clone after its history was rewritten",
+			Action: a.Reclone,
+		},
+		{
+			Name:   "gc",
+			Usage:  "Report the largest blobs in the store and compact it",
+			Action: a.Gc,
+			Flags: []cli.Flag{
+				&cli.IntFlag{Name: "limit", Aliases: []string{"n"}, Value: 10, Usage: "Number of blobs to show"},
+				&cli.BoolFlag{Name: "prune", Usage: "Run git gc after reporting"},
+			},
+		},
+		{
+			Name:   "fetch",
+			Usage:  "Fetch from git remote without merging",
+			Action: a.Fetch,
+			Flags: []cli.Flag{
+				&cli.IntFlag{Name: "deepen", Usage: "Extend shallow history by this many commits"},
+				&cli.BoolFlag{Name: "unshallow", Usage: "Fetch the complete history"},
+				&cli.BoolFlag{Name: "sparse", Usage: "Refresh sparse checkout to match your current access"},
 			},
 		},
+		{
+			Name:   "clipboard-clear",
+			Usage:  "Clear a copied secret from the clipboard (started automatically)",
+			Hidden: true,
+			Action: a.ClipboardClear,
+			Flags: []cli.Flag{
+				&cli.IntFlag{Name: "after", Value: 45, Usage: "Seconds to wait before clearing"},
+			},
+		},
+	}
+
+	// Time each command on its own, inside every other wrapper
+	a.recordMetrics(commands, "")
+
+	// Run local hooks around each command, after the write guard
+	a.runHooks(commands, "")
+
+	// Ask for a recent re-auth before sensitive commands
+	a.requireStepUp(commands, "")
+
+	// Reject writes in read-only mode and for viewers
+	a.guardWrites(commands, "")
+	handleInterrupts(commands, "")
+	structureErrors(commands)
+
+	return commands
+}
+
+// GlobalFlags returns flags that apply to every command
+func (a *Action) GlobalFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{Name: "read-only", EnvVars: []string{"PASSBOOK_READ_ONLY"}, Usage: "Reject any command that modifies the store"},
+		&cli.StringFlag{Name: "error-format", EnvVars: []string{"PASSBOOK_ERROR_FORMAT"}, Value: "text", Usage: "Print errors as text or json"},
+		&cli.BoolFlag{Name: "redact", EnvVars: []string{"PASSBOOK_REDACT"}, Value: true, Usage: "Mask decrypted values in errors, warnings and quoted git or parser output"},
+		&cli.BoolFlag{Name: "show-secrets", Usage: "Don't mask decrypted values in errors and warnings, for debugging"},
 	}
 }
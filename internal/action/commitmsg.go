@@ -0,0 +1,157 @@
+package action
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"passbook/internal/config"
+	"passbook/pkg/termio"
+)
+
+// maxPathTrailers is how many changed files a commit's Paths trailer lists
+// before it gives only a count of the rest
+const maxPathTrailers = 50
+
+// commitMessage turns what a command did into the message of the commit
+// recording it, following the store's commit settings. The message ends
+// with trailers saying who made the change, what it was and which files it
+// touched, for tooling to read with 'git interpret-trailers'.
+func (a *Action) commitMessage(message string, changes []string) (string, error) {
+	commit := a.cfg.Git.Commit
+	subject, body, _ := strings.Cut(message, "\n")
+	action := commitAction(subject)
+	paths := changedPaths(changes)
+
+	ticket, err := a.commitTicket(commit.Ticket)
+	if err != nil {
+		return "", err
+	}
+
+	if commit.Template != "" {
+		subject = strings.NewReplacer(
+			"{message}", subject,
+			"{action}", action,
+			"{actor}", a.cfg.Identity.Email,
+			"{ticket}", ticket,
+		).Replace(commit.Template)
+		subject = strings.Join(strings.Fields(subject), " ")
+	}
+	if commit.Prefix != "" {
+		subject = commit.Prefix + " " + subject
+	}
+	if commit.Conventional {
+		scope := ""
+		if s := commitScope(paths); s != "" {
+			scope = "(" + s + ")"
+		}
+		subject = fmt.Sprintf("%s%s: %s", commit.CommitType(), scope, lowerFirst(subject))
+	}
+
+	var b strings.Builder
+	b.WriteString(subject)
+	if body = strings.TrimSpace(body); body != "" {
+		b.WriteString("\n\n" + body)
+	}
+	b.WriteString("\n\n")
+	if a.cfg.Identity.Email != "" {
+		fmt.Fprintf(&b, "Actor: %s\n", a.cfg.Identity.Email)
+	}
+	fmt.Fprintf(&b, "Action: %s\n", action)
+	if ticket != "" {
+		fmt.Fprintf(&b, "Ticket: %s\n", ticket)
+	}
+	if len(paths) > maxPathTrailers {
+		fmt.Fprintf(&b, "Paths: %s\n", strings.Join(paths[:maxPathTrailers], ", "))
+		fmt.Fprintf(&b, "Paths-Omitted: %d\n", len(paths)-maxPathTrailers)
+	} else if len(paths) > 0 {
+		fmt.Fprintf(&b, "Paths: %s\n", strings.Join(paths, ", "))
+	}
+	return b.String(), nil
+}
+
+// commitTicket returns the ticket ID to record with this run's commits:
+// PASSBOOK_TICKET if it's set, otherwise asked for once when the store's
+// ticket mode wants one
+func (a *Action) commitTicket(mode string) (string, error) {
+	if a.ticket != nil {
+		return *a.ticket, nil
+	}
+	ticket := strings.TrimSpace(os.Getenv("PASSBOOK_TICKET"))
+	if ticket == "" && mode != "" {
+		if termio.IsTerminal() {
+			var err error
+			if ticket, err = termio.Prompt("Ticket ID: "); err != nil {
+				return "", err
+			}
+		}
+		if ticket == "" && mode == config.TicketRequired {
+			return "", fmt.Errorf("the store requires a ticket ID with every commit; set PASSBOOK_TICKET or run interactively, then 'passbook commit'")
+		}
+	}
+	a.ticket = &ticket
+	return ticket, nil
+}
+
+// commitAction names the kind of change a commit message describes, from
+// its first word: "Set KEY in api/dev" is a set
+func commitAction(subject string) string {
+	word, _, _ := strings.Cut(strings.TrimSpace(subject), " ")
+	word = strings.ToLower(strings.TrimRight(word, ":"))
+	if word == "" {
+		return "update"
+	}
+	return word
+}
+
+// changedPaths returns the files named in 'git status --porcelain' lines,
+// sorted, with a rename giving its new name
+func changedPaths(changes []string) []string {
+	var paths []string
+	for _, line := range changes {
+		if len(line) < 4 {
+			continue
+		}
+		p := line[3:]
+		if _, to, ok := strings.Cut(p, " -> "); ok {
+			p = to
+		}
+		paths = append(paths, strings.Trim(p, `"`))
+	}
+	slices.Sort(paths)
+	return slices.Compact(paths)
+}
+
+// commitScope returns the top-level directory shared by every secret a
+// commit changes, such as credentials or projects, or "" if they differ.
+// Store metadata such as the audit log doesn't count.
+func commitScope(paths []string) string {
+	scope := ""
+	for _, p := range paths {
+		top, _, nested := strings.Cut(p, "/")
+		if !nested {
+			continue
+		}
+		if scope != "" && scope != top {
+			return ""
+		}
+		scope = top
+	}
+	return scope
+}
+
+// lowerFirst lowercases the first letter of s, unless it starts an
+// acronym such as API
+func lowerFirst(s string) string {
+	first, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	if next, _ := utf8.DecodeRuneInString(s[size:]); unicode.IsUpper(next) {
+		return s
+	}
+	return string(unicode.ToLower(first)) + s[size:]
+}
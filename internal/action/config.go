@@ -0,0 +1,173 @@
+package action
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/audit"
+	"passbook/internal/config"
+	"passbook/internal/rbac"
+	"passbook/pkg/editor"
+	"passbook/pkg/ui"
+)
+
+// ConfigGet prints the effective value of a setting
+func (a *Action) ConfigGet(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook config get KEY")
+	}
+
+	setting, err := lookupSetting(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(setting.Get(a.cfg))
+	return nil
+}
+
+// ConfigSet validates and saves a setting. Store settings are shared with
+// the team, so they need admin rights and are committed.
+func (a *Action) ConfigSet(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return fmt.Errorf("usage: passbook config set KEY VALUE")
+	}
+
+	key := c.Args().Get(0)
+	value := c.Args().Get(1)
+
+	setting, err := lookupSetting(key)
+	if err != nil {
+		return err
+	}
+
+	if setting.Scope == config.ScopeStore {
+		if err := a.requireStoreConfig(); err != nil {
+			return err
+		}
+	}
+
+	if err := a.cfg.Update(setting, value); err != nil {
+		return err
+	}
+
+	if setting.Scope == config.ScopeStore {
+		if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Set config: %s", key)); err != nil {
+			ui.Warningf("%v", err)
+		}
+		a.logAudit(audit.EventConfigChanged, key, "value", setting.Get(a.cfg))
+	}
+
+	ui.Successf("%s = %s (%s)", key, setting.Get(a.cfg), setting.Scope)
+	return nil
+}
+
+// ConfigList shows every documented setting with its effective value
+func (a *Action) ConfigList(c *cli.Context) error {
+	scopes := []config.Scope{config.ScopeUser, config.ScopeStore}
+	if c.Bool("user") {
+		scopes = []config.Scope{config.ScopeUser}
+	} else if c.Bool("store") {
+		scopes = []config.Scope{config.ScopeStore}
+	}
+
+	for i, scope := range scopes {
+		if i > 0 {
+			fmt.Println()
+		}
+
+		title := "User Settings"
+		if scope == config.ScopeStore {
+			title = "Store Settings (shared with the team)"
+		}
+		ui.Heading(title)
+		fmt.Printf("File: %s\n", a.cfg.ScopePath(scope))
+		fmt.Println()
+
+		table := ui.NewTable()
+		for _, s := range config.Settings() {
+			if s.Scope != scope {
+				continue
+			}
+			value := s.Get(a.cfg)
+			if value == "" {
+				value = "-"
+			}
+			table.Row(s.Key, value, ui.Muted(s.Usage))
+		}
+		table.Print()
+	}
+
+	return nil
+}
+
+// ConfigEdit opens a config file in an editor and only saves it if it is valid
+func (a *Action) ConfigEdit(c *cli.Context) error {
+	scope := config.ScopeUser
+	if c.Bool("store") {
+		scope = config.ScopeStore
+		if err := a.requireStoreConfig(); err != nil {
+			return err
+		}
+	}
+
+	path := a.cfg.ScopePath(scope)
+	original, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	edited, err := editor.Edit(a.cfg.Preferences.Editor, original, ".yaml")
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(edited, original) {
+		fmt.Println("No changes")
+		return nil
+	}
+
+	if err := config.CheckFile(scope, edited); err != nil {
+		return fmt.Errorf("%w (changes discarded)", err)
+	}
+
+	if scope == config.ScopeUser {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return fmt.Errorf("failed to create config directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(path, edited, 0600); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	if scope == config.ScopeStore {
+		if err := a.GitCommitAndSync(c.Context, "Edit store config"); err != nil {
+			ui.Warningf("%v", err)
+		}
+		a.logAudit(audit.EventConfigChanged, ".passbook-config", "method", "edit")
+	}
+
+	ui.Successf("Saved %s", path)
+	return nil
+}
+
+// requireStoreConfig checks that the store exists and the user may change its config
+func (a *Action) requireStoreConfig() error {
+	if !a.cfg.IsInitialized() {
+		return ErrNotInitialized
+	}
+	_, err := a.authorize(rbac.PermStoreConfig)
+	return err
+}
+
+// lookupSetting finds a documented setting or explains where to look
+func lookupSetting(key string) (config.Setting, error) {
+	setting, ok := config.LookupSetting(key)
+	if !ok {
+		return setting, fmt.Errorf("setting %s %w; run 'passbook config list' to see all settings", key, ErrNotFound)
+	}
+	return setting, nil
+}
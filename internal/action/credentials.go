@@ -1,6 +1,7 @@
 package action
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
@@ -8,15 +9,19 @@ import (
 	"strings"
 	"time"
 
-	"github.com/atotto/clipboard"
 	"github.com/google/uuid"
 	"github.com/urfave/cli/v2"
 	"gopkg.in/yaml.v3"
 
+	"passbook/internal/audit"
 	"passbook/internal/backend/crypto/age"
 	"passbook/internal/models"
+	"passbook/internal/notify"
+	"passbook/internal/rbac"
+	"passbook/pkg/editor"
 	"passbook/pkg/pwgen"
 	"passbook/pkg/termio"
+	"passbook/pkg/ui"
 )
 
 // CredList lists all credentials
@@ -33,8 +38,7 @@ func (a *Action) CredList(c *cli.Context) error {
 		return nil
 	}
 
-	fmt.Println("Credentials")
-	fmt.Println("===========")
+	ui.Heading("Credentials")
 	fmt.Println()
 
 	// Walk credentials directory
@@ -43,6 +47,9 @@ func (a *Action) CredList(c *cli.Context) error {
 		if err != nil {
 			return err
 		}
+		if err := c.Context.Err(); err != nil {
+			return err
+		}
 
 		// Skip directories and non-.age files
 		if info.IsDir() || !strings.HasSuffix(info.Name(), age.Ext) {
@@ -123,6 +130,7 @@ func (a *Action) CredShow(c *cli.Context) error {
 	path := c.Args().First()
 	clip := c.Bool("clip")
 	passwordOnly := c.Bool("password")
+	history := c.Bool("history")
 
 	website, name, err := parseCredentialPath(path)
 	if err != nil {
@@ -134,18 +142,23 @@ func (a *Action) CredShow(c *cli.Context) error {
 		return fmt.Errorf("failed to load credential: %w", err)
 	}
 
+	switch {
+	case clip:
+		a.recordSensitiveAccess(c.Context, cred, "copy")
+	case passwordOnly:
+		a.recordSensitiveAccess(c.Context, cred, "show --password")
+	case history:
+		a.recordSensitiveAccess(c.Context, cred, "show --history")
+	default:
+		a.recordSensitiveAccess(c.Context, cred, "show")
+	}
+
 	if clip || passwordOnly {
 		if clip {
-			if err := clipboard.WriteAll(cred.Password); err != nil {
-				return fmt.Errorf("failed to copy to clipboard: %w", err)
+			if err := a.copyToClipboard(cred.Password); err != nil {
+				return err
 			}
 			fmt.Printf("Password copied to clipboard (clears in %d seconds)\n", a.cfg.Preferences.ClipboardTimeout)
-
-			// Clear clipboard after timeout
-			go func() {
-				time.Sleep(time.Duration(a.cfg.Preferences.ClipboardTimeout) * time.Second)
-				clipboard.WriteAll("")
-			}()
 		} else {
 			fmt.Println(cred.Password)
 		}
@@ -153,8 +166,7 @@ func (a *Action) CredShow(c *cli.Context) error {
 	}
 
 	// Show full credential
-	fmt.Printf("Credential: %s/%s\n", website, name)
-	fmt.Println("========================")
+	ui.Heading(fmt.Sprintf("Credential: %s/%s", website, name))
 	fmt.Printf("Username: %s\n", cred.Username)
 	fmt.Printf("Password: %s\n", cred.Password)
 	if cred.URL != "" {
@@ -166,18 +178,47 @@ func (a *Action) CredShow(c *cli.Context) error {
 	if len(cred.Tags) > 0 {
 		fmt.Printf("Tags:     %s\n", strings.Join(cred.Tags, ", "))
 	}
+	if cred.Sensitive {
+		fmt.Printf("Sensitive: %s\n", ui.Warn("yes, reads are reported"))
+	}
 	fmt.Printf("Created:  %s\n", cred.CreatedAt.Format("2006-01-02 15:04"))
 	fmt.Printf("Updated:  %s\n", cred.UpdatedAt.Format("2006-01-02 15:04"))
 
+	if history {
+		printPasswordHistory(cred)
+	}
+
 	return nil
 }
 
+// printPasswordHistory lists a credential's previous passwords, newest first
+func printPasswordHistory(cred *models.Credential) {
+	fmt.Println()
+	if len(cred.PasswordHistory) == 0 {
+		fmt.Println("No previous passwords")
+		return
+	}
+	fmt.Println("Previous passwords:")
+	for i, old := range cred.PasswordHistory {
+		by := old.ReplacedBy
+		if by == "" {
+			by = "unknown"
+		}
+		fmt.Printf("  %d. %s  (replaced %s by %s)\n", i+1, old.Password, old.ReplacedAt.Format("2006-01-02 15:04"), by)
+	}
+}
+
 // CredAdd adds a new credential
 func (a *Action) CredAdd(c *cli.Context) error {
 	if c.NArg() < 1 {
 		return fmt.Errorf("usage: passbook cred add WEBSITE [--name NAME]")
 	}
 
+	currentUser, err := a.authorize(rbac.PermCredentialsWrite)
+	if err != nil {
+		return err
+	}
+
 	website := c.Args().First()
 	name := c.String("name")
 	username := c.String("username")
@@ -197,7 +238,7 @@ func (a *Action) CredAdd(c *cli.Context) error {
 	// Check if credential already exists
 	credPath := filepath.Join(a.cfg.StorePath, "credentials", website, name+age.Ext)
 	if _, err := os.Stat(credPath); err == nil {
-		return fmt.Errorf("credential %s/%s already exists", website, name)
+		return fmt.Errorf("credential %s/%s %w", website, name, ErrConflict)
 	}
 
 	// Prompt for username if not provided
@@ -209,34 +250,56 @@ func (a *Action) CredAdd(c *cli.Context) error {
 		}
 	}
 
-	// Generate or prompt for password
-	if generate {
+	// Generate or prompt for a password until one is accepted
+	interactive := termio.IsTerminal()
+	for {
 		var err error
-		password, err = pwgen.GenerateSimple(length)
-		if err != nil {
-			return fmt.Errorf("failed to generate password: %w", err)
+		if generate {
+			password, err = a.generatePassword(c.Context, website, length, c.IsSet("length"))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Generated password: %s\n", password)
+		} else if password == "" {
+			password, err = termio.PromptPasswordMeter("Password: ", passwordMeter)
+			if err != nil {
+				return err
+			}
 		}
-		fmt.Printf("Generated password: %s\n", password)
-	} else if password == "" {
-		var err error
-		password, err = termio.PromptPassword("Password: ")
-		if err != nil {
-			return err
+		if password == "" {
+			return fmt.Errorf("password is required")
 		}
-	}
 
-	if password == "" {
-		return fmt.Errorf("password is required")
-	}
+		verdict, ok := a.passwordVerdict(password)
+		fmt.Printf("Strength: %s\n", verdict)
+		if !ok && generate {
+			return fmt.Errorf("%w: generated password is too weak (%s); use a longer --length", ErrInvalidInput, verdict)
+		}
+		if !ok && !interactive {
+			return fmt.Errorf("%w: password is too weak (%s)", ErrInvalidInput, verdict)
+		}
+		if !interactive {
+			break
+		}
+		if generate {
+			accept, err := termio.Confirm("Use this password?", true)
+			if err != nil {
+				return err
+			}
+			if accept {
+				break
+			}
+			continue
+		}
+		if ok {
+			break
+		}
 
-	// Get current user
-	currentUser, err := a.getCurrentUser()
-	if err != nil {
-		// Use public key as fallback
-		currentUser = &models.User{
-			Email:     "unknown",
-			PublicKey: a.cfg.Identity.PublicKey,
+		instead, err := termio.Confirm("Generate a password instead?", true)
+		if err != nil {
+			return err
 		}
+		generate, password = instead, ""
 	}
 
 	// Create credential
@@ -246,6 +309,7 @@ func (a *Action) CredAdd(c *cli.Context) error {
 		Name:      name,
 		Username:  username,
 		Password:  password,
+		Sensitive: c.Bool("sensitive"),
 		CreatedBy: currentUser.Email,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
@@ -256,16 +320,69 @@ func (a *Action) CredAdd(c *cli.Context) error {
 		return fmt.Errorf("failed to save credential: %w", err)
 	}
 
+	a.logAudit(audit.EventCredentialCreated, website+"/"+name)
+
 	// Git commit
-	if err := a.GitCommitAndSync(fmt.Sprintf("Add credential: %s/%s", website, name)); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Add credential: %s/%s", website, name)); err != nil {
+		ui.Warningf("%v", err)
 	}
 
-	fmt.Printf("\n✓ Added credential: %s/%s\n", website, name)
+	fmt.Println()
+	ui.Successf("Added credential: %s/%s", website, name)
+	if a.cfg.Access.Explicit() {
+		fmt.Printf("Only you and the admins can read it; share it with 'passbook cred access grant %s/%s EMAIL'\n", website, name)
+	}
 
 	return nil
 }
 
+// generatePassword generates a password for a site, following its profile
+// if it has one. A profile's length limits win over the default length but
+// not over one the user asked for.
+func (a *Action) generatePassword(ctx context.Context, website string, length int, explicitLength bool) (string, error) {
+	opts := pwgen.DefaultOptions()
+	opts.Length = length
+	if profile, domain, ok := pwgen.ProfileFor(website, a.cfg.Passwords.Profiles); ok {
+		opts = profile.Options(length)
+		if explicitLength && opts.Length != length {
+			return "", fmt.Errorf("%w: %s passwords must be %s", ErrInvalidInput, domain, profile)
+		}
+		fmt.Printf("Using the %s profile: %s\n", domain, profile)
+	}
+
+	password, err := pwgen.GenerateContext(ctx, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return password, nil
+}
+
+// passwordMeter describes a password's strength while it's typed
+func passwordMeter(password string) string {
+	if password == "" {
+		return ""
+	}
+	bits := pwgen.Entropy(password)
+	return fmt.Sprintf("[%.0f bits, %s]", bits, pwgen.Rating(bits))
+}
+
+// passwordVerdict describes a password's strength against the team's
+// minimum, and whether it meets it
+func (a *Action) passwordVerdict(password string) (string, bool) {
+	bits := pwgen.Entropy(password)
+	verdict := fmt.Sprintf("%.0f bits, %s", bits, pwgen.Rating(bits))
+
+	minimum := a.cfg.Passwords.MinEntropy
+	switch {
+	case minimum == 0:
+		return verdict, true
+	case bits < float64(minimum):
+		return fmt.Sprintf("%s; below the team minimum of %d bits", verdict, minimum), false
+	default:
+		return fmt.Sprintf("%s; meets the team minimum of %d bits", verdict, minimum), true
+	}
+}
+
 // CredEdit edits a credential
 func (a *Action) CredEdit(c *cli.Context) error {
 	if c.NArg() < 1 {
@@ -283,8 +400,65 @@ func (a *Action) CredEdit(c *cli.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to load credential: %w", err)
 	}
+	if err := a.requireWrite(cred.CanUserWrite, website+"/"+name); err != nil {
+		return err
+	}
+
+	// Dropping the prod tag doesn't skip review
+	needsReview := a.credNeedsReview(cred)
+	oldPassword := cred.Password
+
+	if c.Bool("editor") {
+		changed, err := a.editCredentialInEditor(cred)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			fmt.Println("No changes")
+			return nil
+		}
+	} else if err := promptCredentialChanges(cred); err != nil {
+		return err
+	}
+	by := "unknown"
+	if user, err := a.getCurrentUser(); err == nil {
+		by = user.Email
+	}
+	cred.RecordPasswordChange(oldPassword, by, a.cfg.Passwords.HistoryLimit())
+	cred.UpdatedAt = time.Now()
+
+	// Save
+	if err := a.saveCredential(c.Context, cred); err != nil {
+		return fmt.Errorf("failed to save credential: %w", err)
+	}
 
-	fmt.Printf("Editing credential: %s/%s\n", website, name)
+	if needsReview || a.credNeedsReview(cred) {
+		msg := fmt.Sprintf("Update credential: %s/%s", website, name)
+		id, err := a.propose(c.Context, cred.FullPath(), msg)
+		if err != nil {
+			return err
+		}
+		fmt.Println()
+		printProposed(id, msg)
+		return nil
+	}
+
+	a.logAudit(audit.EventCredentialUpdated, website+"/"+name)
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Update credential: %s/%s", website, name)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	fmt.Println()
+	ui.Successf("Updated credential: %s/%s", website, name)
+
+	return nil
+}
+
+// promptCredentialChanges asks for new values, keeping the current ones on Enter
+func promptCredentialChanges(cred *models.Credential) error {
+	fmt.Printf("Editing credential: %s/%s\n", cred.Website, cred.Name)
 	fmt.Println("(Press Enter to keep current value)")
 	fmt.Println()
 
@@ -308,25 +482,63 @@ func (a *Action) CredEdit(c *cli.Context) error {
 		return err
 	}
 
-	// Update credential
 	cred.Username = newUsername
 	cred.Password = newPassword
 	cred.Notes = newNotes
-	cred.UpdatedAt = time.Now()
+	return nil
+}
 
-	// Save
-	if err := a.saveCredential(c.Context, cred); err != nil {
-		return fmt.Errorf("failed to save credential: %w", err)
+// editableCredential is the part of a credential opened in the editor
+type editableCredential struct {
+	Username string            `yaml:"username"`
+	Password string            `yaml:"password"`
+	URL      string            `yaml:"url,omitempty"`
+	Notes    string            `yaml:"notes,omitempty"`
+	Tags     []string          `yaml:"tags,omitempty"`
+	Metadata map[string]string `yaml:"metadata,omitempty"`
+}
+
+// editCredentialInEditor opens a credential's fields in the user's editor and
+// applies the result. It reports whether anything changed.
+func (a *Action) editCredentialInEditor(cred *models.Credential) (bool, error) {
+	data, err := yaml.Marshal(editableCredential{
+		Username: cred.Username,
+		Password: cred.Password,
+		URL:      cred.URL,
+		Notes:    cred.Notes,
+		Tags:     cred.Tags,
+		Metadata: cred.Metadata,
+	})
+	if err != nil {
+		return false, err
 	}
+	original := append([]byte(fmt.Sprintf("# Editing %s/%s. Save and quit to apply, or quit without saving to cancel.\n", cred.Website, cred.Name)), data...)
 
-	// Git commit
-	if err := a.GitCommitAndSync(fmt.Sprintf("Update credential: %s/%s", website, name)); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+	edited, err := editor.Edit(a.cfg.Preferences.Editor, original, ".yaml")
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(edited, original) {
+		return false, nil
 	}
 
-	fmt.Printf("\n✓ Updated credential: %s/%s\n", website, name)
+	var updated editableCredential
+	dec := yaml.NewDecoder(bytes.NewReader(edited))
+	dec.KnownFields(true)
+	if err := dec.Decode(&updated); err != nil {
+		return false, fmt.Errorf("%w: %v (changes discarded)", ErrInvalidInput, err)
+	}
+	if updated.Password == "" {
+		return false, fmt.Errorf("%w: password is required (changes discarded)", ErrInvalidInput)
+	}
 
-	return nil
+	cred.Username = updated.Username
+	cred.Password = updated.Password
+	cred.URL = updated.URL
+	cred.Notes = updated.Notes
+	cred.Tags = updated.Tags
+	cred.Metadata = updated.Metadata
+	return true, nil
 }
 
 // CredRemove removes a credential
@@ -347,7 +559,10 @@ func (a *Action) CredRemove(c *cli.Context) error {
 
 	// Check if exists
 	if _, err := os.Stat(credPath); os.IsNotExist(err) {
-		return fmt.Errorf("credential %s/%s not found", website, name)
+		return fmt.Errorf("credential %s/%s %w", website, name, ErrNotFound)
+	}
+	if err := a.checkWrite(c.Context, filepath.Join("credentials", website, name+age.Ext)); err != nil {
+		return err
 	}
 
 	// Confirm
@@ -362,6 +577,10 @@ func (a *Action) CredRemove(c *cli.Context) error {
 		}
 	}
 
+	if err := a.checkHold(filepath.Join("credentials", website, name), "delete"); err != nil {
+		return err
+	}
+
 	// Delete file
 	if err := os.Remove(credPath); err != nil {
 		return fmt.Errorf("failed to delete credential: %w", err)
@@ -374,12 +593,14 @@ func (a *Action) CredRemove(c *cli.Context) error {
 		os.Remove(websiteDir)
 	}
 
+	a.logAudit(audit.EventCredentialDeleted, website+"/"+name)
+
 	// Git commit
-	if err := a.GitCommitAndSync(fmt.Sprintf("Delete credential: %s/%s", website, name)); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Delete credential: %s/%s", website, name)); err != nil {
+		ui.Warningf("%v", err)
 	}
 
-	fmt.Printf("✓ Deleted credential: %s/%s\n", website, name)
+	ui.Successf("Deleted credential: %s/%s", website, name)
 
 	return nil
 }
@@ -387,7 +608,7 @@ func (a *Action) CredRemove(c *cli.Context) error {
 // CredCopy copies password to clipboard
 func (a *Action) CredCopy(c *cli.Context) error {
 	if c.NArg() < 1 {
-		return fmt.Errorf("usage: passbook cred copy WEBSITE/NAME")
+		return fmt.Errorf("usage: passbook cred copy [--username-then-password] WEBSITE/NAME")
 	}
 
 	path := c.Args().First()
@@ -401,176 +622,169 @@ func (a *Action) CredCopy(c *cli.Context) error {
 		return fmt.Errorf("failed to load credential: %w", err)
 	}
 
-	if err := clipboard.WriteAll(cred.Password); err != nil {
-		return fmt.Errorf("failed to copy to clipboard: %w", err)
-	}
-
-	timeout := a.cfg.Preferences.ClipboardTimeout
-	fmt.Printf("✓ Password copied to clipboard (clears in %d seconds)\n", timeout)
-
-	// Clear clipboard after timeout
-	go func() {
-		time.Sleep(time.Duration(timeout) * time.Second)
-		clipboard.WriteAll("")
-	}()
+	a.recordSensitiveAccess(c.Context, cred, "copy")
 
-	return nil
+	usernameFirst := c.Bool("username-then-password") || a.cfg.Preferences.CopyUsernameFirst
+	if usernameFirst && !c.Bool("password-only") {
+		return a.copyUsernameThenPassword(cred)
+	}
+	return a.copyPassword(cred)
 }
 
-// loadCredential loads and decrypts a credential
-func (a *Action) loadCredential(ctx context.Context, website, name string) (*models.Credential, error) {
-	credPath := filepath.Join(a.cfg.StorePath, "credentials", website, name+age.Ext)
+// CredType types a credential's username and password into the focused
+// window, for places where the clipboard can't be trusted. Given a URL, it
+// types the credential that matches it most closely.
+func (a *Action) CredType(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook cred type [--delay SECONDS] [--password-only] [--enter] WEBSITE/NAME|URL")
+	}
 
-	// Read encrypted file
-	encrypted, err := os.ReadFile(credPath)
+	website, name, err := a.resolveCredentialArg(c.Context, c.Args().First())
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	// Decrypt
-	ageBackend, err := age.New(a.cfg.IdentityPath())
+	typer, err := newAutoTyper()
 	if err != nil {
-		return nil, fmt.Errorf("failed to load identity: %w", err)
+		return err
 	}
 
-	plaintext, err := ageBackend.Decrypt(ctx, encrypted)
+	cred, err := a.loadCredential(c.Context, website, name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt: %w", err)
+		return fmt.Errorf("failed to load credential: %w", err)
 	}
 
-	// Parse YAML
-	var cred models.Credential
-	if err := yaml.Unmarshal(plaintext, &cred); err != nil {
-		return nil, fmt.Errorf("failed to parse credential: %w", err)
-	}
+	a.recordSensitiveAccess(c.Context, cred, "type")
 
-	return &cred, nil
-}
+	if delay := c.Int("delay"); delay > 0 {
+		fmt.Printf("Typing in %d seconds; focus the field to fill...\n", delay)
+		time.Sleep(time.Duration(delay) * time.Second)
+	}
 
-// saveCredential encrypts and saves a credential
-func (a *Action) saveCredential(ctx context.Context, cred *models.Credential) error {
-	// Serialize to YAML
-	data, err := yaml.Marshal(cred)
-	if err != nil {
+	if !c.Bool("password-only") && cred.Username != "" {
+		if err := typer.text(cred.Username); err != nil {
+			return err
+		}
+		if err := typer.key(keyTab); err != nil {
+			return err
+		}
+	}
+	if err := typer.text(cred.Password); err != nil {
 		return err
 	}
+	if c.Bool("enter") {
+		if err := typer.key(keyEnter); err != nil {
+			return err
+		}
+	}
 
-	// Get recipients (all team members)
-	recipients, err := a.getAllRecipientKeys()
-	if err != nil {
-		return fmt.Errorf("failed to get recipients: %w", err)
+	ui.Successf("Typed %s/%s with %s", website, name, typer.name)
+	return nil
+}
+
+// CredSensitive marks a credential as sensitive, or clears the mark with --off
+func (a *Action) CredSensitive(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook cred sensitive WEBSITE/NAME [--off]")
 	}
 
-	// Encrypt
-	ageBackend, err := age.New(a.cfg.IdentityPath())
+	website, name, err := parseCredentialPath(c.Args().First())
 	if err != nil {
-		return fmt.Errorf("failed to load identity: %w", err)
+		return err
 	}
 
-	encrypted, err := ageBackend.Encrypt(ctx, data, recipients)
+	cred, err := a.loadCredential(c.Context, website, name)
 	if err != nil {
-		return fmt.Errorf("failed to encrypt: %w", err)
+		return fmt.Errorf("failed to load credential: %w", err)
 	}
-
-	// Create directory
-	credDir := filepath.Join(a.cfg.StorePath, "credentials", cred.Website)
-	if err := os.MkdirAll(credDir, 0700); err != nil {
+	if err := a.requireWrite(cred.CanUserWrite, website+"/"+name); err != nil {
 		return err
 	}
 
-	// Write file
-	credPath := filepath.Join(credDir, cred.Name+age.Ext)
-	return os.WriteFile(credPath, encrypted, 0600)
-}
-
-// getAllRecipientKeys returns all recipient public keys from the team
-func (a *Action) getAllRecipientKeys() ([]string, error) {
-	userList, err := a.loadUsers()
-	if err != nil {
-		return nil, err
+	sensitive := !c.Bool("off")
+	if cred.Sensitive == sensitive {
+		fmt.Println("No changes")
+		return nil
 	}
+	cred.Sensitive = sensitive
+	cred.UpdatedAt = time.Now()
 
-	var keys []string
-	for _, user := range userList.Users {
-		if user.PublicKey != "" {
-			keys = append(keys, user.PublicKey)
-		}
+	if err := a.saveCredential(c.Context, cred); err != nil {
+		return fmt.Errorf("failed to save credential: %w", err)
 	}
 
-	// Always include self
-	if a.cfg.Identity.PublicKey != "" {
-		found := false
-		for _, k := range keys {
-			if k == a.cfg.Identity.PublicKey {
-				found = true
-				break
-			}
-		}
-		if !found {
-			keys = append(keys, a.cfg.Identity.PublicKey)
-		}
+	a.logAudit(audit.EventCredentialUpdated, website+"/"+name, "sensitive", fmt.Sprintf("%t", sensitive))
+
+	msg := fmt.Sprintf("Mark credential sensitive: %s/%s", website, name)
+	if !sensitive {
+		msg = fmt.Sprintf("Unmark credential sensitive: %s/%s", website, name)
+	}
+	if err := a.GitCommitAndSync(c.Context, msg); err != nil {
+		ui.Warningf("%v", err)
 	}
 
-	return keys, nil
+	if !sensitive {
+		ui.Successf("%s/%s is no longer sensitive", website, name)
+		return nil
+	}
+	ui.Successf("%s/%s is sensitive; every read is now audited and reported", website, name)
+	if a.cfg.Notify.Webhook == "" {
+		ui.Warningf("no webhook configured; set one with 'passbook config set notify.webhook URL'")
+	}
+	return nil
 }
 
-// saveCredentialWithPermissions encrypts and saves a credential using per-secret permissions
-func (a *Action) saveCredentialWithPermissions(ctx context.Context, cred *models.Credential) error {
-	// Serialize to YAML
-	data, err := yaml.Marshal(cred)
-	if err != nil {
-		return err
+// recordSensitiveAccess audits a read of a sensitive credential and posts it
+// to the store's webhook. A failed delivery is reported but doesn't block the
+// read, since the audit event already records it.
+func (a *Action) recordSensitiveAccess(ctx context.Context, cred *models.Credential, how string) {
+	if !cred.Sensitive {
+		return
 	}
 
-	// Get recipients from permissions
-	var recipients []string
-	if cred.Permissions != nil && !cred.Permissions.UseRoleBasedAccess && cred.Permissions.Count() > 0 {
-		// Use per-secret permissions
-		for _, perm := range cred.Permissions.Recipients {
-			if perm.PublicKey != "" {
-				recipients = append(recipients, perm.PublicKey)
-			}
-		}
-	} else {
-		// Fall back to all team members
-		recipients, err = a.getAllRecipientKeys()
-		if err != nil {
-			return fmt.Errorf("failed to get recipients: %w", err)
-		}
+	target := cred.Website + "/" + cred.Name
+	actor := "unknown"
+	if user, err := a.getCurrentUser(); err == nil {
+		actor = user.AuditName()
 	}
+	hostname, _ := os.Hostname()
 
-	// Always include self
-	if a.cfg.Identity.PublicKey != "" {
-		found := false
-		for _, k := range recipients {
-			if k == a.cfg.Identity.PublicKey {
-				found = true
-				break
-			}
-		}
-		if !found {
-			recipients = append(recipients, a.cfg.Identity.PublicKey)
-		}
+	a.logAudit(audit.EventSensitiveAccess, target, "action", how, "host", hostname)
+
+	if a.cfg.Notify.Webhook == "" {
+		return
+	}
+	msg := notify.Message{
+		Text:      fmt.Sprintf(":rotating_light: %s read sensitive credential %s (%s on %s)", actor, target, how, hostname),
+		Event:     string(audit.EventSensitiveAccess),
+		Actor:     actor,
+		Target:    target,
+		Timestamp: time.Now().UTC(),
+		Details:   map[string]string{"action": how, "host": hostname},
 	}
+	if err := notify.Send(ctx, a.cfg.Notify.Webhook, msg); err != nil {
+		ui.Warningf("sensitive read of %s not reported: %v", target, err)
+	}
+}
 
-	// Encrypt
-	ageBackend, err := age.New(a.cfg.IdentityPath())
+// loadCredential loads and decrypts a credential
+func (a *Action) loadCredential(ctx context.Context, website, name string) (*models.Credential, error) {
+	s, err := a.openStore()
 	if err != nil {
-		return fmt.Errorf("failed to load identity: %w", err)
+		return nil, err
 	}
+	return s.GetCredential(ctx, website, name)
+}
 
-	encrypted, err := ageBackend.Encrypt(ctx, data, recipients)
+// saveCredential encrypts and saves a credential for its recipients
+func (a *Action) saveCredential(ctx context.Context, cred *models.Credential) error {
+	s, err := a.openStore()
 	if err != nil {
-		return fmt.Errorf("failed to encrypt: %w", err)
+		return err
 	}
-
-	// Create directory
-	credDir := filepath.Join(a.cfg.StorePath, "credentials", cred.Website)
-	if err := os.MkdirAll(credDir, 0700); err != nil {
+	if err := s.SaveCredential(ctx, cred); err != nil {
 		return err
 	}
-
-	// Write file
-	credPath := filepath.Join(credDir, cred.Name+age.Ext)
-	return os.WriteFile(credPath, encrypted, 0600)
+	a.trackLargeFile(cred.FullPath())
+	return nil
 }
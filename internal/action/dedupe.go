@@ -0,0 +1,335 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/audit"
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/pkg/termio"
+	"passbook/pkg/ui"
+	"passbook/pkg/urlmatch"
+)
+
+// duplicateGroup is a set of credentials that look like the same login
+type duplicateGroup struct {
+	reason string
+	creds  []*models.Credential
+}
+
+// CredDedupe finds credentials saved more than once, either with the same
+// username and password or under the same name for one site spelled two
+// ways (www.github.com and github.com), and merges or deletes them one
+// group at a time
+func (a *Action) CredDedupe(c *cli.Context) error {
+	dryRun := c.Bool("dry-run")
+	perm := rbac.PermCredentialsWrite
+	if dryRun {
+		perm = rbac.PermCredentialsRead
+	}
+	if _, err := a.authorize(perm); err != nil {
+		return err
+	}
+
+	creds, err := a.loadAllCredentials(c.Context)
+	if err != nil {
+		return err
+	}
+	groups := findDuplicates(creds)
+	if len(groups) == 0 {
+		ui.Successf("No duplicate credentials")
+		return nil
+	}
+
+	gone := make(map[string]bool)
+	var merged, deleted []string
+	for i, g := range groups {
+		// An earlier merge may have removed some of this group already
+		g.creds = slices.DeleteFunc(g.creds, func(cred *models.Credential) bool { return gone[cred.Path()] })
+		if len(g.creds) < 2 {
+			continue
+		}
+
+		fmt.Println()
+		ui.Heading(fmt.Sprintf("Duplicates %d of %d: %s", i+1, len(groups), g.reason))
+		fmt.Println()
+		printDuplicates(g.creds)
+		if dryRun {
+			continue
+		}
+		fmt.Println()
+
+		if err := a.resolveDuplicates(c.Context, g, gone, &merged, &deleted); err != nil {
+			return err
+		}
+	}
+
+	if dryRun {
+		fmt.Printf("\n%d group(s) of duplicates; run without --dry-run to resolve them\n", len(groups))
+		return nil
+	}
+	if len(merged) == 0 && len(deleted) == 0 {
+		fmt.Println("\nNo changes")
+		return nil
+	}
+
+	msg := fmt.Sprintf("Dedupe credentials: merged %d, deleted %d", len(merged), len(deleted))
+	if err := a.GitCommitAndSync(c.Context, msg); err != nil {
+		ui.Warningf("%v", err)
+	}
+	fmt.Println()
+	ui.Successf("Merged %d and deleted %d duplicate credential(s)", len(merged), len(deleted))
+	return nil
+}
+
+// resolveDuplicates asks what to do with one group and does it
+func (a *Action) resolveDuplicates(ctx context.Context, g duplicateGroup, gone map[string]bool, merged, deleted *[]string) error {
+	labels := make([]string, len(g.creds))
+	for i, cred := range g.creds {
+		labels[i] = cred.Website + "/" + cred.Name
+	}
+
+	choice, err := termio.Select("What should happen to them?", []string{
+		"Merge them into one",
+		"Delete one",
+		"Leave them",
+	}, 2)
+	if err != nil {
+		return err
+	}
+
+	switch choice {
+	case 0:
+		keep, err := termio.Select("Keep which one? The others are merged into it and deleted.", labels, 0)
+		if err != nil {
+			return err
+		}
+		target := g.creds[keep]
+		var others []*models.Credential
+		for i, cred := range g.creds {
+			if i != keep {
+				others = append(others, cred)
+			}
+		}
+		if a.credNeedsReview(target) {
+			ui.Warningf("%s needs review to change; merge it by hand with 'passbook cred edit'", labels[keep])
+			return nil
+		}
+		for i, cred := range g.creds {
+			if err := a.requireWrite(cred.CanUserWrite, labels[i]); err != nil {
+				return err
+			}
+		}
+		for _, other := range others {
+			if err := a.checkHold(filepath.Join("credentials", other.Website, other.Name), "delete"); err != nil {
+				return err
+			}
+		}
+
+		if mergeCredentials(target, others) {
+			ui.Warningf("the others had different passwords; %s keeps its own", labels[keep])
+		}
+		target.UpdatedAt = time.Now()
+		if err := a.saveCredential(ctx, target); err != nil {
+			return fmt.Errorf("failed to save credential: %w", err)
+		}
+		var from []string
+		for _, other := range others {
+			if err := a.deleteCredentialFile(other); err != nil {
+				return err
+			}
+			gone[other.Path()] = true
+			from = append(from, other.Website+"/"+other.Name)
+			*deleted = append(*deleted, other.Website+"/"+other.Name)
+		}
+		a.logAudit(audit.EventCredentialUpdated, labels[keep], "merged", strings.Join(from, ","))
+		*merged = append(*merged, labels[keep])
+		ui.Successf("Merged %s into %s", strings.Join(from, ", "), labels[keep])
+
+	case 1:
+		drop, err := termio.Select("Delete which one?", labels, -1)
+		if err != nil {
+			return err
+		}
+		cred := g.creds[drop]
+		if err := a.requireWrite(cred.CanUserWrite, labels[drop]); err != nil {
+			return err
+		}
+		if err := a.checkHold(filepath.Join("credentials", cred.Website, cred.Name), "delete"); err != nil {
+			return err
+		}
+		if err := a.deleteCredentialFile(cred); err != nil {
+			return err
+		}
+		gone[cred.Path()] = true
+		*deleted = append(*deleted, labels[drop])
+		ui.Successf("Deleted %s", labels[drop])
+
+	default:
+		fmt.Println("Left as is.")
+	}
+	return nil
+}
+
+// loadAllCredentials decrypts every credential the caller can read
+func (a *Action) loadAllCredentials(ctx context.Context) ([]*models.Credential, error) {
+	s, err := a.openStore()
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := s.ListCredentials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credentials: %w", err)
+	}
+
+	creds := make([]*models.Credential, 0, len(summaries))
+	for _, sum := range summaries {
+		cred, err := s.GetCredential(ctx, sum.Website, sum.Name)
+		if err != nil {
+			continue
+		}
+		creds = append(creds, cred)
+	}
+	return creds, nil
+}
+
+// deleteCredentialFile removes a credential, and its website directory if
+// it was the last one there, and audits the deletion
+func (a *Action) deleteCredentialFile(cred *models.Credential) error {
+	if err := os.Remove(filepath.Join(a.cfg.StorePath, filepath.FromSlash(cred.FullPath()))); err != nil {
+		return fmt.Errorf("failed to delete credential: %w", err)
+	}
+	websiteDir := filepath.Join(a.cfg.StorePath, "credentials", cred.Website)
+	if entries, _ := os.ReadDir(websiteDir); len(entries) == 0 {
+		os.Remove(websiteDir)
+	}
+	a.logAudit(audit.EventCredentialDeleted, cred.Website+"/"+cred.Name, "reason", "duplicate")
+	return nil
+}
+
+// findDuplicates groups credentials with the same username and password,
+// then those with the same name on the same site under different websites
+func findDuplicates(creds []*models.Credential) []duplicateGroup {
+	var groups []duplicateGroup
+	add := func(reason string, byKey map[string][]*models.Credential) {
+		keys := make([]string, 0, len(byKey))
+		for k, list := range byKey {
+			if len(list) > 1 {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			groups = append(groups, duplicateGroup{reason: reason, creds: byKey[k]})
+		}
+	}
+
+	byLogin := make(map[string][]*models.Credential)
+	for _, cred := range creds {
+		if cred.Password == "" {
+			continue
+		}
+		key := cred.Username + "\x00" + cred.Password
+		byLogin[key] = append(byLogin[key], cred)
+	}
+	add("same username and password", byLogin)
+
+	bySite := make(map[string][]*models.Credential)
+	for _, cred := range creds {
+		host := strings.ToLower(cred.Website)
+		if t, err := urlmatch.Parse(cred.Website); err == nil {
+			host = t.Host
+		}
+		key := host + "/" + strings.ToLower(cred.Name)
+		bySite[key] = append(bySite[key], cred)
+	}
+	add("same site and name", bySite)
+
+	return groups
+}
+
+// printDuplicates shows a group side by side. Passwords aren't shown, only
+// whether each matches the first.
+func printDuplicates(creds []*models.Credential) {
+	headers := []string{""}
+	for i, cred := range creds {
+		headers = append(headers, fmt.Sprintf("%d. %s/%s", i+1, cred.Website, cred.Name))
+	}
+	table := ui.NewTable(headers...)
+
+	row := func(field string, value func(*models.Credential) string) {
+		cells := []string{field}
+		for _, cred := range creds {
+			cells = append(cells, value(cred))
+		}
+		table.Row(cells...)
+	}
+	row("Username", func(cred *models.Credential) string { return cred.Username })
+	row("Password", func(cred *models.Credential) string {
+		switch {
+		case cred == creds[0]:
+			return "********"
+		case cred.Password == creds[0].Password:
+			return "same as 1"
+		}
+		return ui.Highlight("different")
+	})
+	row("URL", func(cred *models.Credential) string { return cred.URL })
+	row("Tags", func(cred *models.Credential) string { return strings.Join(cred.Tags, ", ") })
+	row("Notes", func(cred *models.Credential) string {
+		if cred.Notes == "" {
+			return ""
+		}
+		return fmt.Sprintf("%d line(s)", strings.Count(strings.TrimRight(cred.Notes, "\n"), "\n")+1)
+	})
+	row("Updated", func(cred *models.Credential) string { return cred.UpdatedAt.Local().Format("2006-01-02") })
+	table.Print()
+}
+
+// mergeCredentials folds others into target: empty fields are filled in,
+// tags and metadata are combined, and differing notes are appended. The
+// target's password is kept; it reports whether any other password differed.
+func mergeCredentials(target *models.Credential, others []*models.Credential) bool {
+	passwordsDiffer := false
+	for _, other := range others {
+		if target.Username == "" {
+			target.Username = other.Username
+		}
+		if target.URL == "" {
+			target.URL = other.URL
+		}
+		switch {
+		case target.Notes == "":
+			target.Notes = other.Notes
+		case other.Notes != "" && !strings.Contains(target.Notes, other.Notes):
+			target.Notes = strings.TrimRight(target.Notes, "\n") + "\n\n" + other.Notes
+		}
+		for _, tag := range other.Tags {
+			if !slices.Contains(target.Tags, tag) {
+				target.Tags = append(target.Tags, tag)
+			}
+		}
+		for k, v := range other.Metadata {
+			if _, ok := target.Metadata[k]; !ok {
+				if target.Metadata == nil {
+					target.Metadata = make(map[string]string)
+				}
+				target.Metadata[k] = v
+			}
+		}
+		target.Sensitive = target.Sensitive || other.Sensitive
+		if other.Password != target.Password {
+			passwordsDiffer = true
+		}
+	}
+	return passwordsDiffer
+}
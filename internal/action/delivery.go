@@ -0,0 +1,101 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"passbook/internal/models"
+	"passbook/pkg/securetmp"
+)
+
+// formatSystemdCreds is the env export format that encrypts a dotenv file
+// with systemd-creds, for a unit to load with LoadCredentialEncrypted
+const formatSystemdCreds = "systemd-creds"
+
+// systemdCredsEncrypt encrypts plaintext as a systemd credential called
+// name, bound to this host's key or TPM. The plaintext goes through a pipe,
+// never a file.
+func systemdCredsEncrypt(ctx context.Context, name string, plaintext []byte) ([]byte, error) {
+	path, err := exec.LookPath("systemd-creds")
+	if err != nil {
+		return nil, fmt.Errorf("--format %s needs systemd-creds (systemd 250 or later): %w", formatSystemdCreds, err)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, "encrypt", "--name="+name, "-", "-")
+	cmd.Stdin = bytes.NewReader(plaintext)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("systemd-creds encrypt failed: %s", msg)
+		}
+		return nil, fmt.Errorf("systemd-creds encrypt failed: %w", err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// defaultTmpfsPath is where --tmpfs writes an env when no file is given
+func defaultTmpfsPath(project string, stage models.Stage) string {
+	return filepath.Join(securetmp.Dir(), "passbook-"+project+"-"+string(stage)+".env")
+}
+
+// requireMemoryFS refuses a path that isn't on a ramdisk, so a rendered
+// env is never written where it would outlive a reboot
+func requireMemoryFS(path string) error {
+	ok, err := onMemoryFS(filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("failed to check %s: %w", filepath.Dir(path), err)
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s isn't on a tmpfs; use a path under /run or /dev/shm", ErrInvalidInput, filepath.Dir(path))
+	}
+	return nil
+}
+
+// parseOwner looks up an owner given as USER or USER:GROUP, by name or id
+func parseOwner(spec string) (int, int, error) {
+	userName, groupName, hasGroup := strings.Cut(spec, ":")
+	u, err := user.Lookup(userName)
+	if err != nil {
+		if u, err = user.LookupId(userName); err != nil {
+			return 0, 0, fmt.Errorf("%w: unknown user %q", ErrInvalidInput, userName)
+		}
+	}
+	gid := u.Gid
+	if hasGroup {
+		g, err := user.LookupGroup(groupName)
+		if err != nil {
+			if g, err = user.LookupGroupId(groupName); err != nil {
+				return 0, 0, fmt.Errorf("%w: unknown group %q", ErrInvalidInput, groupName)
+			}
+		}
+		gid = g.Gid
+	}
+
+	uidNum, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: --owner needs numeric ids, which this system doesn't have", ErrInvalidInput)
+	}
+	gidNum, err := strconv.Atoi(gid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: --owner needs numeric ids, which this system doesn't have", ErrInvalidInput)
+	}
+	return uidNum, gidNum, nil
+}
+
+// chownFile hands a written file to its owner. It stays readable only by
+// them.
+func chownFile(path string, uid, gid int) error {
+	if err := os.Chown(path, uid, gid); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to set owner of %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,479 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atotto/clipboard"
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/auth"
+	"passbook/internal/backend/crypto/age"
+	"passbook/pkg/ui"
+)
+
+// doctorStatus is how serious a doctor finding is
+type doctorStatus int
+
+const (
+	doctorOK doctorStatus = iota
+	doctorWarn
+	doctorFail
+)
+
+// doctorFinding is one result of a doctor check
+type doctorFinding struct {
+	status doctorStatus
+	msg    string
+	hint   string       // What to do about it
+	fix    func() error // Applied by --fix; nil if it needs a person
+}
+
+// doctorSection groups related checks under a heading
+type doctorSection struct {
+	title string
+	run   func(c *cli.Context) []doctorFinding
+}
+
+// minGitVersion is the oldest git sparse clones work with; they need
+// sparse-checkout --no-cone
+var minGitVersion = [2]int{2, 35}
+
+// maxClockSkew is how far the clock may drift before expiry of logins,
+// challenges and access grants is judged wrongly
+const maxClockSkew = 2 * time.Minute
+
+// doctorTimeout bounds each network check
+const doctorTimeout = 10 * time.Second
+
+// syncedFolders are path segments of folders that cloud clients upload,
+// which would copy identities and plaintext exports off the machine
+var syncedFolders = []string{"Dropbox", "iCloud Drive", "iCloudDrive", "Mobile Documents", "OneDrive", "Google Drive", "GoogleDrive", "My Drive", "Box Sync", "pCloud Drive", "Nextcloud", "ownCloud"}
+
+// Doctor checks the local setup for problems and, with --fix, repairs the
+// ones it can
+func (a *Action) Doctor(c *cli.Context) error {
+	fix := c.Bool("fix")
+	if fix {
+		if err := a.requireWritable(c, true); err != nil {
+			return err
+		}
+	}
+
+	sections := []doctorSection{
+		{"Environment", a.doctorEnvironment},
+		{"Permissions", a.doctorPermissions},
+	}
+
+	ui.Heading("Passbook Doctor")
+	problems, warnings, fixed := 0, 0, 0
+	for _, section := range sections {
+		fmt.Printf("\n%s:\n", section.title)
+		for _, f := range section.run(c) {
+			if f.status != doctorOK && fix && f.fix != nil {
+				if err := f.fix(); err != nil {
+					f.hint = fmt.Sprintf("fix failed: %v", err)
+				} else {
+					fmt.Printf("  %s %s %s\n", ui.Success("✓"), f.msg, ui.Muted("(fixed)"))
+					fixed++
+					continue
+				}
+			}
+
+			switch f.status {
+			case doctorOK:
+				fmt.Printf("  %s %s\n", ui.Success("✓"), f.msg)
+			case doctorWarn:
+				fmt.Printf("  %s %s\n", ui.Warn("!"), f.msg)
+				warnings++
+			case doctorFail:
+				fmt.Printf("  %s %s\n", ui.Fail("✗"), f.msg)
+				problems++
+			}
+			if f.status != doctorOK && f.hint != "" {
+				fmt.Printf("    %s\n", ui.Muted(f.hint))
+			}
+		}
+	}
+	fmt.Println()
+
+	if fixed > 0 {
+		ui.Successf("Fixed %d issue(s)", fixed)
+	}
+	if problems > 0 {
+		return fmt.Errorf("doctor found %d problem(s) and %d warning(s)", problems, warnings)
+	}
+	if warnings > 0 {
+		ui.Warningf("%d warning(s)", warnings)
+		return nil
+	}
+	ui.Successf("No problems found")
+	return nil
+}
+
+// doctorEnvironment checks the tools and services passbook relies on
+func (a *Action) doctorEnvironment(c *cli.Context) []doctorFinding {
+	findings := []doctorFinding{checkGitVersion()}
+	if origin := a.storeOrigin(); origin != "" {
+		findings = append(findings, checkRemote(c.Context, origin))
+	} else if a.cfg.IsInitialized() {
+		findings = append(findings, doctorFinding{
+			status: doctorWarn,
+			msg:    "store has no remote, so changes aren't shared",
+			hint:   "run 'passbook store move --to URL' to publish it",
+		})
+	}
+	findings = append(findings, checkClockSkew(c.Context))
+	findings = append(findings, checkClipboard()...)
+	findings = append(findings, a.checkIdentity())
+	findings = append(findings, a.checkGitHubAuth()...)
+	return findings
+}
+
+// checkGitVersion checks git is installed and new enough
+func checkGitVersion() doctorFinding {
+	output, err := exec.Command("git", "version").Output()
+	if err != nil {
+		return doctorFinding{status: doctorFail, msg: "git is not installed", hint: "install git from https://git-scm.com"}
+	}
+	version := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(output)), "git version"))
+
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) >= 2 {
+		major, _ := strconv.Atoi(parts[0])
+		minor, _ := strconv.Atoi(parts[1])
+		if major < minGitVersion[0] || major == minGitVersion[0] && minor < minGitVersion[1] {
+			return doctorFinding{
+				status: doctorWarn,
+				msg:    fmt.Sprintf("git %s is older than %d.%d; sparse clones won't work", version, minGitVersion[0], minGitVersion[1]),
+				hint:   "upgrade git",
+			}
+		}
+	}
+	return doctorFinding{status: doctorOK, msg: "git " + version}
+}
+
+// checkRemote checks the store's remote answers
+func checkRemote(ctx context.Context, origin string) doctorFinding {
+	ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+	if err := gitLsRemote(ctx, origin); err != nil {
+		// git explains itself over several lines; the first says what failed
+		reason, _, _ := strings.Cut(err.Error(), "\n")
+		if ctx.Err() != nil {
+			reason = fmt.Sprintf("no answer in %s", doctorTimeout)
+		}
+		return doctorFinding{
+			status: doctorFail,
+			msg:    fmt.Sprintf("remote %s is unreachable: %s", origin, reason),
+			hint:   "check your network and git credentials, e.g. with 'git ls-remote " + origin + "'",
+		}
+	}
+	return doctorFinding{status: doctorOK, msg: "remote " + origin + " is reachable"}
+}
+
+// checkClockSkew compares the local clock with GitHub's. Logins, challenges
+// and access grants all expire by the local clock.
+func checkClockSkew(ctx context.Context) doctorFinding {
+	ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, "https://api.github.com", nil)
+	if err != nil {
+		return doctorFinding{status: doctorWarn, msg: fmt.Sprintf("can't check the clock: %v", err)}
+	}
+	sent := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return doctorFinding{status: doctorWarn, msg: "can't check the clock: api.github.com is unreachable"}
+	}
+	resp.Body.Close()
+	remote, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return doctorFinding{status: doctorWarn, msg: "can't check the clock: no date from api.github.com"}
+	}
+
+	// The server stamped the reply somewhere within the round trip
+	local := sent.Add(time.Since(sent) / 2)
+	skew := local.Sub(remote)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxClockSkew {
+		direction := "ahead"
+		if local.Before(remote) {
+			direction = "behind"
+		}
+		return doctorFinding{
+			status: doctorFail,
+			msg:    fmt.Sprintf("clock is %s %s, so expiry of logins, challenges and grants is wrong", skew.Round(time.Second), direction),
+			hint:   "enable network time sync (timedatectl set-ntp true, or your OS's date settings)",
+		}
+	}
+	return doctorFinding{status: doctorOK, msg: "clock is in sync"}
+}
+
+// checkClipboard checks the tools cred copy and cred type need
+func checkClipboard() []doctorFinding {
+	var findings []doctorFinding
+	if clipboard.Unsupported {
+		findings = append(findings, doctorFinding{
+			status: doctorWarn,
+			msg:    "no clipboard tool found, so cred copy won't work",
+			hint:   "install wl-clipboard on Wayland, or xclip or xsel on X11",
+		})
+	} else {
+		findings = append(findings, doctorFinding{status: doctorOK, msg: "clipboard is available"})
+	}
+	if _, err := newAutoTyper(); err != nil {
+		findings = append(findings, doctorFinding{status: doctorWarn, msg: "cred type won't work", hint: err.Error()})
+	}
+	return findings
+}
+
+// checkIdentity checks the identity file holds the key passbook is
+// configured with, and that the team knows it
+func (a *Action) checkIdentity() doctorFinding {
+	path := a.cfg.IdentityPath()
+	if a.cfg.Identity.IsHardware() {
+		if _, err := exec.LookPath("pkcs11-tool"); err != nil {
+			return doctorFinding{status: doctorFail, msg: "identity is on a PKCS#11 token, but pkcs11-tool is not installed", hint: "install OpenSC"}
+		}
+		if _, err := os.Stat(a.cfg.Identity.PKCS11.Module); err != nil {
+			return doctorFinding{status: doctorFail, msg: fmt.Sprintf("PKCS#11 module %s is missing", a.cfg.Identity.PKCS11.Module), hint: "install the token's driver or run 'passbook key hsm' again"}
+		}
+		return doctorFinding{status: doctorOK, msg: fmt.Sprintf("identity is key %s on a PKCS#11 token", a.cfg.Identity.PKCS11.KeyID)}
+	}
+	if !a.cfg.HasIdentity() {
+		return doctorFinding{status: doctorFail, msg: "identity missing: " + path, hint: "run 'passbook key import' or 'passbook clone' to create one"}
+	}
+
+	encrypted, err := age.IsKeyEncrypted(path)
+	if err != nil {
+		return doctorFinding{status: doctorFail, msg: fmt.Sprintf("can't read identity: %v", err)}
+	}
+	wrapped, _ := age.IsKeyKMSWrapped(path)
+	var publicKey string
+	if encrypted || wrapped {
+		// Unlocking needs the passphrase or KMS; the key's header names its
+		// public key
+		publicKey, err = age.GetPublicKeyFromFile(path)
+	} else {
+		var id *age.Age
+		if id, err = age.New(path); err == nil {
+			publicKey = id.PublicKey()
+		}
+	}
+	if err != nil {
+		return doctorFinding{status: doctorFail, msg: fmt.Sprintf("identity %s is invalid: %v", path, err), hint: "restore it from a backup or run 'passbook key import'"}
+	}
+
+	if a.cfg.Identity.PublicKey != "" && publicKey != a.cfg.Identity.PublicKey {
+		return doctorFinding{
+			status: doctorFail,
+			msg:    fmt.Sprintf("identity's key %s doesn't match the configured %s", publicKey, a.cfg.Identity.PublicKey),
+			hint:   "point identity.private_key_path at the right file, or run 'passbook key import'",
+		}
+	}
+	if a.cfg.IsInitialized() {
+		if _, err := a.getCurrentUser(); err != nil {
+			return doctorFinding{status: doctorWarn, msg: "identity is valid but not in the team yet", hint: "ask an admin to run 'passbook team add'"}
+		}
+	}
+	return doctorFinding{status: doctorOK, msg: "identity is valid"}
+}
+
+// checkGitHubAuth checks GitHub logins can work, and are current if the
+// team requires them
+func (a *Action) checkGitHubAuth() []doctorFinding {
+	githubAuth := a.githubAuth()
+	if !githubAuth.Configured() {
+		return []doctorFinding{{
+			status: doctorWarn,
+			msg:    "GitHub OAuth is not configured, so 'passbook login' won't work",
+			hint:   "set PASSBOOK_GITHUB_CLIENT_ID to your OAuth app's client ID",
+		}}
+	}
+
+	required := a.cfg.Auth.AdminSessionHours > 0 || a.cfg.Auth.StepUpMinutes > 0
+	_, err := githubAuth.Session()
+	switch {
+	case err == nil:
+		return []doctorFinding{{status: doctorOK, msg: "logged in to GitHub"}}
+	case !required && errors.Is(err, auth.ErrNoSession):
+		return []doctorFinding{{status: doctorOK, msg: "GitHub OAuth is configured"}}
+	default:
+		status := doctorWarn
+		if !required {
+			status = doctorOK
+		}
+		return []doctorFinding{{status: status, msg: fmt.Sprintf("GitHub login: %v", err), hint: "run 'passbook login'"}}
+	}
+}
+
+// doctorPermissions checks that the store, config and identity are private
+// to the user and not inside a synced folder
+func (a *Action) doctorPermissions(c *cli.Context) []doctorFinding {
+	var findings []doctorFinding
+	identityPath := a.cfg.IdentityPath()
+
+	if runtime.GOOS == "windows" {
+		findings = append(findings, doctorFinding{status: doctorOK, msg: "file modes are not checked on Windows; access is governed by ACLs"})
+	} else {
+		if mask, ok := currentUmask(); ok {
+			if mask&0077 != 0077 {
+				findings = append(findings, doctorFinding{
+					status: doctorWarn,
+					msg:    fmt.Sprintf("umask is %04o, so files git checks out are readable by other users", mask),
+					hint:   "add 'umask 077' to your shell profile",
+				})
+			} else {
+				findings = append(findings, doctorFinding{status: doctorOK, msg: fmt.Sprintf("umask is %04o", mask)})
+			}
+		}
+
+		findings = append(findings, checkMode("store directory", a.cfg.StorePath, 0700, doctorFail))
+		findings = append(findings, checkMode("config directory", a.cfg.ConfigDir, 0700, doctorFail))
+		findings = append(findings, checkMode("identity", identityPath, 0600, doctorFail))
+		findings = append(findings, checkMode("config file", a.cfg.UserConfigPath, 0600, doctorWarn))
+
+		// Anything below them should be private too; git's own files are
+		// left to git
+		for _, root := range []string{a.cfg.StorePath, a.cfg.ConfigDir} {
+			findings = append(findings, checkTreeModes(root)...)
+		}
+
+		findings = append(findings, checkExports()...)
+	}
+
+	for _, p := range []struct{ name, path string }{
+		{"store", a.cfg.StorePath},
+		{"config directory", a.cfg.ConfigDir},
+		{"identity", identityPath},
+	} {
+		if folder := syncedFolder(p.path); folder != "" {
+			findings = append(findings, doctorFinding{
+				status: doctorWarn,
+				msg:    fmt.Sprintf("%s is inside %s: %s", p.name, folder, p.path),
+				hint:   "cloud sync copies it off this machine; move it elsewhere (passbook store move for the store)",
+			})
+		}
+	}
+	return findings
+}
+
+// checkMode reports whether path has no more permissions than want
+func checkMode(name, path string, want fs.FileMode, status doctorStatus) doctorFinding {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return doctorFinding{status: doctorOK, msg: fmt.Sprintf("%s not present", name)}
+		}
+		return doctorFinding{status: status, msg: fmt.Sprintf("can't check %s: %v", name, err)}
+	}
+	mode := info.Mode().Perm()
+	if mode&^want == 0 {
+		return doctorFinding{status: doctorOK, msg: fmt.Sprintf("%s is %04o", name, mode)}
+	}
+	return doctorFinding{
+		status: status,
+		msg:    fmt.Sprintf("%s is %04o, should be %04o: %s", name, mode, want, path),
+		hint:   fmt.Sprintf("run 'chmod %o %s' or 'passbook doctor --fix'", want, path),
+		fix:    func() error { return os.Chmod(path, mode&want) },
+	}
+}
+
+// checkTreeModes finds files and directories under root that other users
+// can read, skipping .git
+func checkTreeModes(root string) []doctorFinding {
+	var loose []string
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if path == root || d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.Mode().Perm()&0077 != 0 {
+			loose = append(loose, path)
+		}
+		return nil
+	})
+	if len(loose) == 0 {
+		return nil
+	}
+
+	msg := fmt.Sprintf("%d file(s) under %s are accessible to other users", len(loose), root)
+	if len(loose) == 1 {
+		msg = fmt.Sprintf("%s is accessible to other users", loose[0])
+	}
+	return []doctorFinding{{
+		status: doctorWarn,
+		msg:    msg,
+		hint:   "run 'passbook doctor --fix' to restrict them to you",
+		fix: func() error {
+			for _, path := range loose {
+				info, err := os.Stat(path)
+				if err != nil {
+					return err
+				}
+				if err := os.Chmod(path, info.Mode().Perm()&0700); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}}
+}
+
+// checkExports finds plaintext exports in the current directory, such as
+// .env files, that other users can read
+func checkExports() []doctorFinding {
+	entries, err := os.ReadDir(".")
+	if err != nil {
+		return nil
+	}
+
+	var findings []doctorFinding
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !(name == ".env" || strings.HasPrefix(name, ".env.") || strings.HasSuffix(name, ".env")) {
+			continue
+		}
+		if strings.HasSuffix(name, ".example") || strings.HasSuffix(name, ".sample") || strings.HasSuffix(name, ".template") {
+			continue
+		}
+		if f := checkMode("export "+name, name, 0600, doctorFail); f.status != doctorOK {
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// syncedFolder returns the cloud-synced folder path is inside, if any
+func syncedFolder(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	for _, segment := range strings.Split(filepath.ToSlash(abs), "/") {
+		for _, folder := range syncedFolders {
+			if strings.EqualFold(segment, folder) || strings.HasPrefix(segment, folder+" - ") {
+				return folder
+			}
+		}
+	}
+	return ""
+}
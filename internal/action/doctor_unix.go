@@ -0,0 +1,13 @@
+//go:build !windows
+
+package action
+
+import "syscall"
+
+// currentUmask returns the process umask. Reading it means setting it, so
+// it's restored straight away.
+func currentUmask() (int, bool) {
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+	return mask, true
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package action
+
+// currentUmask reports that Windows has no umask
+func currentUmask() (int, bool) {
+	return 0, false
+}
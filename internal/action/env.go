@@ -2,20 +2,33 @@ package action
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/urfave/cli/v2"
-	"gopkg.in/yaml.v3"
+	"golang.org/x/term"
 
+	"passbook/internal/audit"
 	"passbook/internal/backend/crypto/age"
+	"passbook/internal/envformat"
 	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/pkg/editor"
+	"passbook/pkg/redact"
+	"passbook/pkg/securetmp"
+	"passbook/pkg/ui"
 )
 
 // EnvList lists projects or stages
@@ -33,13 +46,12 @@ func (a *Action) EnvList(c *cli.Context) error {
 
 	if projectFilter != "" {
 		// List stages for specific project
-		fmt.Printf("Stages for project: %s\n", projectFilter)
-		fmt.Println("========================")
+		ui.Heading(fmt.Sprintf("Stages for project: %s", projectFilter))
 		fmt.Println()
 
 		projectDir := filepath.Join(projectsDir, projectFilter)
 		if _, err := os.Stat(projectDir); os.IsNotExist(err) {
-			return fmt.Errorf("project %s not found", projectFilter)
+			return fmt.Errorf("project %s %w", projectFilter, ErrNotFound)
 		}
 
 		// Get current user to check access
@@ -56,18 +68,9 @@ func (a *Action) EnvList(c *cli.Context) error {
 				stage := models.Stage(stageName)
 
 				// Check access
-				canAccess := "✓"
-				if currentUser != nil {
-					hasAccess := false
-					for _, role := range currentUser.Roles {
-						if role.CanAccessStage(stage) {
-							hasAccess = true
-							break
-						}
-					}
-					if !hasAccess {
-						canAccess = "✗ (no access)"
-					}
+				canAccess := ui.Success("✓")
+				if currentUser != nil && !a.policy().CanAccessStage(currentUser, stage, false) {
+					canAccess = ui.Fail("✗ (no access)")
 				}
 
 				fmt.Printf("  %s %s\n", stageName, canAccess)
@@ -75,8 +78,7 @@ func (a *Action) EnvList(c *cli.Context) error {
 		}
 	} else {
 		// List all projects
-		fmt.Println("Projects")
-		fmt.Println("========")
+		ui.Heading("Projects")
 		fmt.Println()
 
 		entries, err := os.ReadDir(projectsDir)
@@ -119,27 +121,16 @@ func (a *Action) EnvShow(c *cli.Context) error {
 	stage := models.Stage(c.Args().Get(1))
 	asExport := c.Bool("export")
 	asDotenv := c.Bool("dotenv")
+	reveal := c.Bool("reveal")
 
 	// Validate stage
 	if !stage.IsValid() {
 		return fmt.Errorf("invalid stage: %s (valid: dev, staging, prod)", stage)
 	}
 
-	// Check permission
-	currentUser, err := a.getCurrentUser()
-	if err != nil {
-		return fmt.Errorf("failed to get current user: %w", err)
-	}
-
-	hasAccess := false
-	for _, role := range currentUser.Roles {
-		if role.CanAccessStage(stage) {
-			hasAccess = true
-			break
-		}
-	}
-	if !hasAccess {
-		return fmt.Errorf("access denied: you don't have permission to access %s environment", stage)
+	// Check permission; revealing secrets on screen takes write access
+	if _, err := a.authorize(rbac.GetStagePermission(stage, reveal)); err != nil {
+		return err
 	}
 
 	// Load env file
@@ -147,30 +138,140 @@ func (a *Action) EnvShow(c *cli.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to load environment: %w", err)
 	}
+	if !reveal {
+		envFile = envFile.Masked(redact.Mask)
+	}
 
 	// Output in requested format
 	if asExport {
 		fmt.Print(envFile.ToExport())
 	} else if asDotenv {
 		fmt.Print(envFile.ToDotEnv())
+	} else if c.Bool("json") {
+		data, err := json.MarshalIndent(struct {
+			Project   string          `json:"project"`
+			Stage     models.Stage    `json:"stage"`
+			UpdatedBy string          `json:"updated_by"`
+			UpdatedAt time.Time       `json:"updated_at"`
+			Vars      []models.EnvVar `json:"vars"`
+		}{project, stage, envFile.UpdatedBy, envFile.UpdatedAt, envFile.Vars}, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		fmt.Println(string(data))
 	} else {
-		fmt.Printf("Environment: %s/%s\n", project, stage)
-		fmt.Println("========================")
+		ui.Heading(fmt.Sprintf("Environment: %s/%s", project, stage))
 		fmt.Printf("Updated: %s by %s\n\n", envFile.UpdatedAt.Format("2006-01-02 15:04"), envFile.UpdatedBy)
 
 		if len(envFile.Vars) == 0 {
 			fmt.Println("No variables set.")
+		} else if c.Bool("long") {
+			printEnvVarsLong(envFile.Vars)
 		} else {
 			for _, v := range envFile.Vars {
-				value := v.Value
-				if v.IsSecret {
-					value = "********"
-				}
-				fmt.Printf("  %-30s = %s\n", v.Key, value)
+				fmt.Printf("  %-30s = %s\n", v.Key, v.Value)
+			}
+		}
+	}
+
+	return nil
+}
+
+// printEnvVarsLong prints variables with their metadata
+func printEnvVarsLong(vars []models.EnvVar) {
+	table := ui.NewTable("KEY", "VALUE", "OWNER", "CHANGED", "DESCRIPTION")
+	for _, v := range vars {
+		owner := v.Owner
+		if owner == "" {
+			owner = "-"
+		}
+		changed := "-"
+		if !v.UpdatedAt.IsZero() {
+			changed = fmt.Sprintf("%s by %s", v.UpdatedAt.Format("2006-01-02 15:04"), v.UpdatedBy)
+		}
+		table.Row(v.Key, v.Value, owner, changed, v.Description)
+	}
+	table.Print()
+}
+
+// EnvDescribe sets the description and owner of a variable
+func (a *Action) EnvDescribe(c *cli.Context) error {
+	owner := c.String("owner")
+	if c.NArg() < 3 || (c.NArg() < 4 && !c.IsSet("owner")) {
+		return fmt.Errorf("usage: passbook env describe PROJECT STAGE KEY [DESCRIPTION] [--owner EMAIL]")
+	}
+
+	project := c.Args().Get(0)
+	stage := models.Stage(c.Args().Get(1))
+	key := c.Args().Get(2)
+
+	// Validate stage
+	if !stage.IsValid() {
+		return fmt.Errorf("invalid stage: %s (valid: dev, staging, prod)", stage)
+	}
+
+	// Check permission
+	currentUser, err := a.authorize(rbac.GetStagePermission(stage, true))
+	if err != nil {
+		return err
+	}
+
+	// Owners must be team members
+	if owner != "" {
+		userList, err := a.loadUsers()
+		if err != nil {
+			return fmt.Errorf("failed to load users: %w", err)
+		}
+		found := false
+		for _, u := range userList.Users {
+			if u.Email == owner {
+				found = true
+				break
 			}
 		}
+		if !found {
+			return fmt.Errorf("user %s %w", owner, ErrNotFound)
+		}
+	}
+
+	// Load env file
+	envFile, err := a.loadEnvFile(c.Context, project, stage)
+	if err != nil {
+		return fmt.Errorf("failed to load environment: %w", err)
+	}
+	if err := a.requireWrite(envFile.CanUserWrite, fmt.Sprintf("%s/%s", project, stage)); err != nil {
+		return err
+	}
+
+	v := envFile.Var(key)
+	if v == nil {
+		return fmt.Errorf("variable %s %w in %s/%s", key, ErrNotFound, project, stage)
+	}
+	if c.NArg() >= 4 {
+		v.Description = c.Args().Get(3)
+	}
+	if c.IsSet("owner") {
+		v.Owner = owner
+	}
+	envFile.UpdatedBy = currentUser.Email
+	envFile.UpdatedAt = time.Now()
+
+	// Save
+	if err := a.saveEnvFile(c.Context, envFile); err != nil {
+		return fmt.Errorf("failed to save environment: %w", err)
+	}
+
+	if a.envNeedsReview(stage) {
+		return a.proposeEnvChange(c, project, stage, fmt.Sprintf("Describe %s in %s/%s", key, project, stage))
 	}
 
+	a.logAudit(audit.EventEnvUpdated, fmt.Sprintf("%s/%s", project, stage), "action", "describe", "key", key)
+
+	// Git commit (deferred with --no-commit)
+	a.commitOrDefer(c, fmt.Sprintf("Describe %s in %s/%s", key, project, stage))
+
+	ui.Successf("Updated %s in %s/%s", key, project, stage)
+
 	return nil
 }
 
@@ -183,7 +284,7 @@ func (a *Action) EnvSet(c *cli.Context) error {
 	project := c.Args().Get(0)
 	stage := models.Stage(c.Args().Get(1))
 	kvPair := c.Args().Get(2)
-	isSecret := c.Bool("secret")
+	isSecret := c.Bool("secret") && !c.Bool("public")
 
 	// Validate stage
 	if !stage.IsValid() {
@@ -198,20 +299,14 @@ func (a *Action) EnvSet(c *cli.Context) error {
 	key, value := parts[0], parts[1]
 
 	// Check permission
-	currentUser, err := a.getCurrentUser()
+	currentUser, err := a.authorize(rbac.GetStagePermission(stage, true))
 	if err != nil {
-		return fmt.Errorf("failed to get current user: %w", err)
+		return err
 	}
 
-	hasAccess := false
-	for _, role := range currentUser.Roles {
-		if role.CanAccessStage(stage) {
-			hasAccess = true
-			break
-		}
-	}
-	if !hasAccess {
-		return fmt.Errorf("access denied: you don't have permission to modify %s environment", stage)
+	// Check the value against the project schema
+	if err := a.validateEnvVars(project, stage, []models.EnvVar{{Key: key, Value: value}}); err != nil {
+		return err
 	}
 
 	// Load or create env file
@@ -227,9 +322,15 @@ func (a *Action) EnvSet(c *cli.Context) error {
 			UpdatedAt: time.Now(),
 		}
 	}
+	if err := a.requireWrite(envFile.CanUserWrite, fmt.Sprintf("%s/%s", project, stage)); err != nil {
+		return err
+	}
 
-	// Update variable
-	envFile.Set(key, value, isSecret)
+	// Update variable; setting the value it already has changes nothing
+	if !envFile.Update(key, value, isSecret, currentUser.Email) {
+		fmt.Printf("%s is already set to that value in %s/%s\n", key, project, stage)
+		return nil
+	}
 	envFile.UpdatedBy = currentUser.Email
 	envFile.UpdatedAt = time.Now()
 
@@ -238,12 +339,16 @@ func (a *Action) EnvSet(c *cli.Context) error {
 		return fmt.Errorf("failed to save environment: %w", err)
 	}
 
-	// Git commit
-	if err := a.GitCommitAndSync(fmt.Sprintf("Set %s in %s/%s", key, project, stage)); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+	if a.envNeedsReview(stage) {
+		return a.proposeEnvChange(c, project, stage, fmt.Sprintf("Set %s in %s/%s", key, project, stage))
 	}
 
-	fmt.Printf("✓ Set %s in %s/%s\n", key, project, stage)
+	a.logAudit(audit.EventEnvUpdated, fmt.Sprintf("%s/%s", project, stage), "action", "set", "key", key)
+
+	// Git commit (deferred with --no-commit)
+	a.commitOrDefer(c, fmt.Sprintf("Set %s in %s/%s", key, project, stage))
+
+	ui.Successf("Set %s in %s/%s", key, project, stage)
 
 	return nil
 }
@@ -264,20 +369,9 @@ func (a *Action) EnvRemove(c *cli.Context) error {
 	}
 
 	// Check permission
-	currentUser, err := a.getCurrentUser()
+	currentUser, err := a.authorize(rbac.GetStagePermission(stage, true))
 	if err != nil {
-		return fmt.Errorf("failed to get current user: %w", err)
-	}
-
-	hasAccess := false
-	for _, role := range currentUser.Roles {
-		if role.CanAccessStage(stage) {
-			hasAccess = true
-			break
-		}
-	}
-	if !hasAccess {
-		return fmt.Errorf("access denied: you don't have permission to modify %s environment", stage)
+		return err
 	}
 
 	// Load env file
@@ -285,10 +379,16 @@ func (a *Action) EnvRemove(c *cli.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to load environment: %w", err)
 	}
+	if err := a.requireWrite(envFile.CanUserWrite, fmt.Sprintf("%s/%s", project, stage)); err != nil {
+		return err
+	}
 
 	// Remove variable
 	if !envFile.Delete(key) {
-		return fmt.Errorf("variable %s not found", key)
+		return fmt.Errorf("variable %s %w", key, ErrNotFound)
+	}
+	if err := a.checkHold(filepath.Join("projects", project, string(stage)), "delete "+key); err != nil {
+		return err
 	}
 
 	envFile.UpdatedBy = currentUser.Email
@@ -299,26 +399,29 @@ func (a *Action) EnvRemove(c *cli.Context) error {
 		return fmt.Errorf("failed to save environment: %w", err)
 	}
 
-	// Git commit
-	if err := a.GitCommitAndSync(fmt.Sprintf("Remove %s from %s/%s", key, project, stage)); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+	if a.envNeedsReview(stage) {
+		return a.proposeEnvChange(c, project, stage, fmt.Sprintf("Remove %s from %s/%s", key, project, stage))
 	}
 
-	fmt.Printf("✓ Removed %s from %s/%s\n", key, project, stage)
+	a.logAudit(audit.EventEnvUpdated, fmt.Sprintf("%s/%s", project, stage), "action", "remove", "key", key)
+
+	// Git commit (deferred with --no-commit)
+	a.commitOrDefer(c, fmt.Sprintf("Remove %s from %s/%s", key, project, stage))
+
+	ui.Successf("Removed %s from %s/%s", key, project, stage)
 
 	return nil
 }
 
-// EnvExport exports environment to file
-func (a *Action) EnvExport(c *cli.Context) error {
+// EnvEdit opens an environment in the user's editor as a .env file. The
+// plaintext only exists in a private temp file that is shredded afterwards.
+func (a *Action) EnvEdit(c *cli.Context) error {
 	if c.NArg() < 2 {
-		return fmt.Errorf("usage: passbook env export PROJECT STAGE")
+		return fmt.Errorf("usage: passbook env edit PROJECT STAGE")
 	}
 
 	project := c.Args().Get(0)
 	stage := models.Stage(c.Args().Get(1))
-	output := c.String("output")
-	format := c.String("format")
 
 	// Validate stage
 	if !stage.IsValid() {
@@ -326,53 +429,228 @@ func (a *Action) EnvExport(c *cli.Context) error {
 	}
 
 	// Check permission
-	currentUser, err := a.getCurrentUser()
+	currentUser, err := a.authorize(rbac.GetStagePermission(stage, true))
 	if err != nil {
-		return fmt.Errorf("failed to get current user: %w", err)
+		return err
 	}
 
-	hasAccess := false
-	for _, role := range currentUser.Roles {
-		if role.CanAccessStage(stage) {
-			hasAccess = true
-			break
+	// Load or start a new env file
+	envFile, err := a.loadEnvFile(c.Context, project, stage)
+	if errors.Is(err, os.ErrNotExist) {
+		envFile = &models.EnvFile{
+			Project:   project,
+			Stage:     stage,
+			Vars:      []models.EnvVar{},
+			CreatedBy: currentUser.Email,
 		}
+	} else if err != nil {
+		return fmt.Errorf("failed to load environment: %w", err)
 	}
-	if !hasAccess {
-		return fmt.Errorf("access denied: you don't have permission to access %s environment", stage)
+	if err := a.requireWrite(envFile.CanUserWrite, fmt.Sprintf("%s/%s", project, stage)); err != nil {
+		return err
 	}
 
-	// Load env file
-	envFile, err := a.loadEnvFile(c.Context, project, stage)
+	header := fmt.Sprintf("# %s/%s: one KEY=VALUE per line. Save and quit to apply, or quit without saving to cancel.\n", project, stage)
+	original := []byte(header + envFile.ToDotEnv())
+
+	edited, err := editor.Edit(a.cfg.Preferences.Editor, original, ".env")
 	if err != nil {
-		return fmt.Errorf("failed to load environment: %w", err)
+		return err
+	}
+	if bytes.Equal(edited, original) {
+		fmt.Println("No changes")
+		return nil
+	}
+
+	// Rebuild the variable list, keeping the metadata of existing keys
+	previous := make(map[string]models.EnvVar, len(envFile.Vars))
+	for _, v := range envFile.Vars {
+		previous[v.Key] = v
+	}
+
+	var added, changed []string
+	vars, err := models.ParseDotEnv(string(edited))
+	if err != nil {
+		return fmt.Errorf("%w: %w (changes discarded)", ErrInvalidInput, err)
+	}
+	seen := make(map[string]bool, len(vars))
+	for i, v := range vars {
+		if v.Key == "" {
+			return fmt.Errorf("%w: empty variable name (changes discarded)", ErrInvalidInput)
+		}
+		if seen[v.Key] {
+			return fmt.Errorf("%w: %s is set twice (changes discarded)", ErrInvalidInput, v.Key)
+		}
+		seen[v.Key] = true
+
+		old, ok := previous[v.Key]
+		if !ok {
+			added = append(added, v.Key)
+			vars[i].UpdatedBy = currentUser.Email
+			vars[i].UpdatedAt = time.Now()
+			continue
+		}
+		vars[i] = old
+		if old.Value != v.Value {
+			changed = append(changed, v.Key)
+			vars[i].Value = v.Value
+			vars[i].UpdatedBy = currentUser.Email
+			vars[i].UpdatedAt = time.Now()
+		}
 	}
 
-	// Format output
-	var content string
-	switch format {
-	case "dotenv", "":
-		content = envFile.ToDotEnv()
-	case "export":
-		content = envFile.ToExport()
-	case "json":
-		data, err := json.MarshalIndent(envFile.ToMap(), "", "  ")
+	var removed []string
+	for _, v := range envFile.Vars {
+		if !seen[v.Key] {
+			removed = append(removed, v.Key)
+		}
+	}
+
+	if len(added)+len(changed)+len(removed) == 0 {
+		fmt.Println("No changes")
+		return nil
+	}
+
+	// Check new and changed values against the project schema
+	var touched []models.EnvVar
+	for _, v := range vars {
+		if slices.Contains(added, v.Key) || slices.Contains(changed, v.Key) {
+			touched = append(touched, v)
+		}
+	}
+	if err := a.validateEnvVars(project, stage, touched); err != nil {
+		return fmt.Errorf("%w (changes discarded)", err)
+	}
+
+	envFile.Vars = vars
+	envFile.UpdatedBy = currentUser.Email
+	envFile.UpdatedAt = time.Now()
+
+	// Save
+	if err := a.saveEnvFile(c.Context, envFile); err != nil {
+		return fmt.Errorf("failed to save environment: %w", err)
+	}
+
+	if a.envNeedsReview(stage) {
+		return a.proposeEnvChange(c, project, stage, fmt.Sprintf("Edit %s/%s", project, stage))
+	}
+
+	a.logAudit(audit.EventEnvUpdated, fmt.Sprintf("%s/%s", project, stage), "action", "edit")
+
+	// Git commit (deferred with --no-commit)
+	a.commitOrDefer(c, fmt.Sprintf("Edit %s/%s", project, stage))
+
+	for _, k := range added {
+		fmt.Printf("  + %s\n", k)
+	}
+	for _, k := range changed {
+		fmt.Printf("  ~ %s\n", k)
+	}
+	for _, k := range removed {
+		fmt.Printf("  - %s\n", k)
+	}
+	ui.Successf("Updated %s/%s", project, stage)
+
+	return nil
+}
+
+// EnvExport exports environment to file
+func (a *Action) EnvExport(c *cli.Context) error {
+	output := c.String("output")
+	format := c.String("format")
+	if c.String("owner") != "" && output == "" && !c.Bool("tmpfs") {
+		return fmt.Errorf("%w: --owner needs --output or --tmpfs", ErrInvalidInput)
+	}
+	uid, gid := -1, -1
+	if owner := c.String("owner"); owner != "" {
+		var err error
+		if uid, gid, err = parseOwner(owner); err != nil {
+			return err
+		}
+	}
+
+	var envFile *models.EnvFile
+	if tok := c.String("token"); tok != "" {
+		// Token grants carry their own project and stage
+		var err error
+		envFile, err = a.redeemToken(tok)
 		if err != nil {
-			return fmt.Errorf("failed to marshal JSON: %w", err)
+			return fmt.Errorf("failed to redeem token: %w", err)
+		}
+	} else {
+		if c.NArg() < 2 {
+			return fmt.Errorf("usage: passbook env export PROJECT STAGE")
+		}
+
+		project := c.Args().Get(0)
+		stage := models.Stage(c.Args().Get(1))
+
+		// Validate stage
+		if !stage.IsValid() {
+			return fmt.Errorf("invalid stage: %s (valid: dev, staging, prod)", stage)
+		}
+
+		// Check permission
+		if _, err := a.authorize(rbac.GetStagePermission(stage, false)); err != nil {
+			return err
+		}
+
+		// Load env file
+		var err error
+		envFile, err = a.loadEnvFile(c.Context, project, stage)
+		if err != nil {
+			return fmt.Errorf("failed to load environment: %w", err)
+		}
+	}
+	project, stage := envFile.Project, envFile.Stage
+	if c.Bool("public-only") {
+		envFile = envFile.PublicOnly()
+	}
+
+	// Format output. systemd credentials hold a dotenv file, encrypted for
+	// this host.
+	encodeAs := format
+	if format == formatSystemdCreds {
+		encodeAs = "dotenv"
+	}
+	content, err := envformat.Encode(encodeAs, envFile, c.String("separator"))
+	if err != nil {
+		return err
+	}
+	credName := c.String("credential-name")
+	if credName == "" {
+		credName = "passbook-" + project + "-" + string(stage)
+	}
+	if format == formatSystemdCreds {
+		if content, err = systemdCredsEncrypt(c.Context, credName, content); err != nil {
+			return err
 		}
-		content = string(data) + "\n"
-	default:
-		return fmt.Errorf("unknown format: %s (valid: dotenv, export, json)", format)
 	}
 
 	// Write output
+	if c.Bool("tmpfs") {
+		if output == "" {
+			output = defaultTmpfsPath(project, stage)
+		}
+		if err := requireMemoryFS(output); err != nil {
+			return err
+		}
+	}
 	if output != "" {
-		if err := os.WriteFile(output, []byte(content), 0600); err != nil {
+		if err := securetmp.WriteFile(output, content); err != nil {
 			return fmt.Errorf("failed to write file: %w", err)
 		}
-		fmt.Printf("✓ Exported %s/%s to %s\n", project, stage, output)
+		if uid >= 0 {
+			if err := chownFile(output, uid, gid); err != nil {
+				return err
+			}
+		}
+		ui.Successf("Exported %s/%s to %s", project, stage, output)
+		if format == formatSystemdCreds {
+			fmt.Printf("  Load it in a unit with: LoadCredentialEncrypted=%s:%s\n", credName, output)
+		}
 	} else {
-		fmt.Print(content)
+		os.Stdout.Write(content)
 	}
 
 	return nil
@@ -394,20 +672,9 @@ func (a *Action) EnvImport(c *cli.Context) error {
 	}
 
 	// Check permission
-	currentUser, err := a.getCurrentUser()
+	currentUser, err := a.authorize(rbac.GetStagePermission(stage, true))
 	if err != nil {
-		return fmt.Errorf("failed to get current user: %w", err)
-	}
-
-	hasAccess := false
-	for _, role := range currentUser.Roles {
-		if role.CanAccessStage(stage) {
-			hasAccess = true
-			break
-		}
-	}
-	if !hasAccess {
-		return fmt.Errorf("access denied: you don't have permission to modify %s environment", stage)
+		return err
 	}
 
 	// Read file
@@ -416,12 +683,24 @@ func (a *Action) EnvImport(c *cli.Context) error {
 		return fmt.Errorf("failed to read file: %w", err)
 	}
 
-	// Parse
-	vars := models.ParseDotEnv(string(content))
+	// Parse, guessing the format from the extension unless given
+	format := c.String("format")
+	if format == "" {
+		format = envformat.FromPath(file)
+	}
+	vars, err := envformat.Decode(format, content, c.String("separator"))
+	if err != nil {
+		return fmt.Errorf("%w: failed to parse %s: %w", ErrInvalidInput, file, err)
+	}
 	if len(vars) == 0 {
 		return fmt.Errorf("no variables found in %s", file)
 	}
 
+	// Check the values against the project schema
+	if err := a.validateEnvVars(project, stage, vars); err != nil {
+		return err
+	}
+
 	// Load or create env file
 	envFile, err := a.loadEnvFile(c.Context, project, stage)
 	if err != nil {
@@ -434,10 +713,20 @@ func (a *Action) EnvImport(c *cli.Context) error {
 			UpdatedAt: time.Now(),
 		}
 	}
+	if err := a.requireWrite(envFile.CanUserWrite, fmt.Sprintf("%s/%s", project, stage)); err != nil {
+		return err
+	}
 
 	// Merge variables
+	changed := 0
 	for _, v := range vars {
-		envFile.Set(v.Key, v.Value, v.IsSecret)
+		if envFile.Update(v.Key, v.Value, v.IsSecret && !c.Bool("public"), currentUser.Email) {
+			changed++
+		}
+	}
+	if changed == 0 {
+		fmt.Printf("All %d variables are already set to those values in %s/%s\n", len(vars), project, stage)
+		return nil
 	}
 	envFile.UpdatedBy = currentUser.Email
 	envFile.UpdatedAt = time.Now()
@@ -447,12 +736,16 @@ func (a *Action) EnvImport(c *cli.Context) error {
 		return fmt.Errorf("failed to save environment: %w", err)
 	}
 
-	// Git commit
-	if err := a.GitCommitAndSync(fmt.Sprintf("Import %d variables into %s/%s", len(vars), project, stage)); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+	if a.envNeedsReview(stage) {
+		return a.proposeEnvChange(c, project, stage, fmt.Sprintf("Import %d variables into %s/%s", len(vars), project, stage))
 	}
 
-	fmt.Printf("✓ Imported %d variables into %s/%s\n", len(vars), project, stage)
+	a.logAudit(audit.EventEnvUpdated, fmt.Sprintf("%s/%s", project, stage), "action", "import", "count", fmt.Sprintf("%d", len(vars)))
+
+	// Git commit (deferred with --no-commit)
+	a.commitOrDefer(c, fmt.Sprintf("Import %d variables into %s/%s", len(vars), project, stage))
+
+	ui.Successf("Imported %d variables into %s/%s", len(vars), project, stage)
 
 	return nil
 }
@@ -483,20 +776,8 @@ func (a *Action) EnvExec(c *cli.Context) error {
 	}
 
 	// Check permission
-	currentUser, err := a.getCurrentUser()
-	if err != nil {
-		return fmt.Errorf("failed to get current user: %w", err)
-	}
-
-	hasAccess := false
-	for _, role := range currentUser.Roles {
-		if role.CanAccessStage(stage) {
-			hasAccess = true
-			break
-		}
-	}
-	if !hasAccess {
-		return fmt.Errorf("access denied: you don't have permission to access %s environment", stage)
+	if _, err := a.authorize(rbac.GetStagePermission(stage, false)); err != nil {
+		return err
 	}
 
 	// Load env file
@@ -505,125 +786,274 @@ func (a *Action) EnvExec(c *cli.Context) error {
 		return fmt.Errorf("failed to load environment: %w", err)
 	}
 
+	// Pass only the requested variables, if any were named
+	vars, err := selectEnvVars(envFile, c.StringSlice("only"))
+	if err != nil {
+		return err
+	}
+
 	// Build command
 	cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
 	cmd.Env = os.Environ()
 
-	// Add env vars
-	for _, v := range envFile.Vars {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", v.Key, v.Value))
+	// Add env vars, or with --via-fd hand them over through an inherited
+	// pipe, so they aren't in /proc/PID/environ or a core dump
+	var fdWriter *os.File
+	var fdContent []byte
+	if c.Bool("via-fd") {
+		if runtime.GOOS == "windows" {
+			return fmt.Errorf("%w: --via-fd is not supported on Windows", ErrInvalidInput)
+		}
+		fdContent, err = envformat.Encode("dotenv", &models.EnvFile{Project: project, Stage: stage, Vars: vars}, "")
+		if err != nil {
+			return err
+		}
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			return fmt.Errorf("failed to create pipe: %w", err)
+		}
+		cmd.ExtraFiles = []*os.File{reader}
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d", envFDVar, 3))
+		fdWriter = writer
+	} else {
+		for _, v := range vars {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", v.Key, v.Value))
+		}
 	}
 
-	// Connect stdio
+	// Connect stdio, masking secret values in the child's output if asked
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	if c.Bool("mask") {
+		var secrets []string
+		for _, v := range vars {
+			if v.IsSecret {
+				secrets = append(secrets, v.Value)
+			}
+		}
+		stdout := redact.NewWriter(os.Stdout, secrets)
+		stderr := redact.NewWriter(os.Stderr, secrets)
+		defer stdout.Flush()
+		defer stderr.Flush()
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+	}
+
+	// Run, relaying signals so the child can shut down cleanly
+	startErr := cmd.Start()
+	var written chan error
+	if fdWriter != nil {
+		// The child has its own copy of the read end, so once ours is closed
+		// a write fails instead of blocking if the child exits without
+		// reading, or never started. Writing happens alongside the child
+		// since it blocks until the child reads.
+		cmd.ExtraFiles[0].Close()
+		written = make(chan error, 1)
+		go func() {
+			_, err := fdWriter.Write(fdContent)
+			fdWriter.Close()
+			age.ZeroBytes(fdContent)
+			written <- err
+		}()
+	}
+	if startErr != nil {
+		if written != nil {
+			<-written
+		}
+		return fmt.Errorf("failed to start %s: %w", cmdArgs[0], startErr)
+	}
+	stopForwarding := forwardSignals(cmd.Process)
+	err = cmd.Wait()
+	stopForwarding()
 
-	// Run
-	return cmd.Run()
+	// The child's own failure says more than the pipe breaking under it
+	if written != nil {
+		if writeErr := <-written; writeErr != nil && err == nil {
+			return fmt.Errorf("failed to pass variables to %s on fd 3: %w", cmdArgs[0], writeErr)
+		}
+	}
+	return childExitError(err)
 }
 
-// loadEnvFile loads and decrypts an env file
-func (a *Action) loadEnvFile(ctx context.Context, project string, stage models.Stage) (*models.EnvFile, error) {
-	envPath := filepath.Join(a.cfg.StorePath, "projects", project, string(stage)+".env.age")
+// envFDVar tells a child started with env exec --via-fd which file
+// descriptor to read its variables from, as a .env file
+const envFDVar = "PASSBOOK_ENV_FD"
 
-	// Read encrypted file
-	encrypted, err := os.ReadFile(envPath)
-	if err != nil {
-		return nil, err
+// selectEnvVars returns the variables named in only, or all of them
+func selectEnvVars(envFile *models.EnvFile, only []string) ([]models.EnvVar, error) {
+	if len(only) == 0 {
+		return envFile.Vars, nil
 	}
 
-	// Decrypt
-	ageBackend, err := age.New(a.cfg.IdentityPath())
-	if err != nil {
-		return nil, fmt.Errorf("failed to load identity: %w", err)
+	byKey := make(map[string]models.EnvVar, len(envFile.Vars))
+	for _, v := range envFile.Vars {
+		byKey[v.Key] = v
 	}
 
-	plaintext, err := ageBackend.Decrypt(ctx, encrypted)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	var vars []models.EnvVar
+	for _, key := range only {
+		v, ok := byKey[key]
+		if !ok {
+			return nil, fmt.Errorf("variable %s %w in %s/%s", key, ErrNotFound, envFile.Project, envFile.Stage)
+		}
+		vars = append(vars, v)
 	}
+	return vars, nil
+}
 
-	// Parse YAML
-	var envFile models.EnvFile
-	if err := yaml.Unmarshal(plaintext, &envFile); err != nil {
-		return nil, fmt.Errorf("failed to parse env file: %w", err)
+// forwardSignals relays termination signals to a child process until the
+// returned function is called. Ctrl-C from a terminal already reaches the
+// child through its process group, so it isn't sent twice.
+func forwardSignals(proc *os.Process) func() {
+	fromTerminal := term.IsTerminal(int(os.Stdin.Fd()))
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, forwardedSignals...)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-sigs:
+				if fromTerminal && terminalSignals[sig] {
+					continue
+				}
+				proc.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
+
+// childExitError turns a child's failure into passbook's own exit status:
+// its exit code, or 128 plus the signal number if it was killed
+func childExitError(err error) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return err
 	}
 
-	return &envFile, nil
+	code := exitErr.ExitCode()
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		code = 128 + int(status.Signal())
+	}
+	return cli.Exit("", code)
 }
 
-// saveEnvFile encrypts and saves an env file
-func (a *Action) saveEnvFile(ctx context.Context, envFile *models.EnvFile) error {
-	// Serialize to YAML
-	data, err := yaml.Marshal(envFile)
-	if err != nil {
+// EnvShellenv prints statements that load an environment into the current
+// shell, for use as: eval "$(passbook env shellenv PROJECT STAGE)"
+func (a *Action) EnvShellenv(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return fmt.Errorf("usage: passbook env shellenv PROJECT STAGE [--shell SHELL]")
+	}
+
+	project := c.Args().Get(0)
+	stage := models.Stage(c.Args().Get(1))
+	shell := c.String("shell")
+	if shell == "" {
+		shell = detectShell()
+	}
+
+	// Validate stage
+	if !stage.IsValid() {
+		return fmt.Errorf("invalid stage: %s (valid: dev, staging, prod)", stage)
+	}
+
+	// Check permission
+	if _, err := a.authorize(rbac.GetStagePermission(stage, false)); err != nil {
 		return err
 	}
 
-	// Get recipients for this stage
-	recipients, err := a.getStageRecipients(envFile.Stage)
+	// Load env file
+	envFile, err := a.loadEnvFile(c.Context, project, stage)
 	if err != nil {
-		return fmt.Errorf("failed to get recipients: %w", err)
+		return fmt.Errorf("failed to load environment: %w", err)
 	}
 
-	// Encrypt
-	ageBackend, err := age.New(a.cfg.IdentityPath())
+	content, skipped, err := envFile.ToShell(shell)
 	if err != nil {
-		return fmt.Errorf("failed to load identity: %w", err)
+		return fmt.Errorf("usage: %w", err)
 	}
 
-	encrypted, err := ageBackend.Encrypt(ctx, data, recipients)
-	if err != nil {
-		return fmt.Errorf("failed to encrypt: %w", err)
+	// Notes go to stderr so they never end up in the evaluated output
+	for _, key := range skipped {
+		fmt.Fprintf(os.Stderr, "Skipping %s: not a valid shell variable name\n", key)
+	}
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Fprintf(os.Stderr, "# Load into your shell with: %s\n", shellEvalHint(shell, project, stage))
 	}
 
-	// Create directory
-	envDir := filepath.Join(a.cfg.StorePath, "projects", envFile.Project)
-	if err := os.MkdirAll(envDir, 0700); err != nil {
-		return err
+	fmt.Print(content)
+	return nil
+}
+
+// detectShell guesses the user's shell from $SHELL, defaulting to
+// PowerShell on Windows and POSIX sh elsewhere
+func detectShell() string {
+	if runtime.GOOS == "windows" {
+		return "powershell"
+	}
+	name := strings.TrimSuffix(filepath.Base(os.Getenv("SHELL")), ".exe")
+	for _, s := range models.Shells {
+		if s == name {
+			return s
+		}
 	}
+	return "sh"
+}
 
-	// Write file
-	envPath := filepath.Join(envDir, string(envFile.Stage)+".env.age")
-	return os.WriteFile(envPath, encrypted, 0600)
+// shellEvalHint shows how to evaluate shellenv output in a shell
+func shellEvalHint(shell, project string, stage models.Stage) string {
+	cmd := fmt.Sprintf("passbook env shellenv %s %s --shell %s", project, stage, shell)
+	switch shell {
+	case "fish":
+		return cmd + " | source"
+	case "powershell", "pwsh":
+		return cmd + " | Out-String | Invoke-Expression"
+	default:
+		return fmt.Sprintf("eval \"$(%s)\"", cmd)
+	}
 }
 
-// getStageRecipients returns public keys of users who can access a stage
-func (a *Action) getStageRecipients(stage models.Stage) ([]string, error) {
-	userList, err := a.loadUsers()
+// loadEnvFile loads and decrypts an env file
+func (a *Action) loadEnvFile(ctx context.Context, project string, stage models.Stage) (*models.EnvFile, error) {
+	s, err := a.openStore()
 	if err != nil {
 		return nil, err
 	}
+	return s.GetEnvFile(ctx, project, stage)
+}
 
-	var keys []string
-	for _, user := range userList.Users {
-		if user.PublicKey == "" {
-			continue
-		}
-		for _, role := range user.Roles {
-			if role.CanAccessStage(stage) {
-				keys = append(keys, user.PublicKey)
-				break
-			}
-		}
+// loadEnvFileAt loads and decrypts the env file at envPath
+func (a *Action) loadEnvFileAt(ctx context.Context, envPath string) (*models.EnvFile, error) {
+	rel, err := filepath.Rel(a.cfg.StorePath, envPath)
+	if err != nil {
+		return nil, err
 	}
-
-	// Always include self
-	if a.cfg.Identity.PublicKey != "" {
-		found := false
-		for _, k := range keys {
-			if k == a.cfg.Identity.PublicKey {
-				found = true
-				break
-			}
-		}
-		if !found {
-			keys = append(keys, a.cfg.Identity.PublicKey)
-		}
+	s, err := a.openStore()
+	if err != nil {
+		return nil, err
 	}
+	return s.GetEnvFileAt(ctx, rel)
+}
 
-	return keys, nil
+// saveEnvFile encrypts and saves an env file for its recipients
+func (a *Action) saveEnvFile(ctx context.Context, envFile *models.EnvFile) error {
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+	if err := s.SaveEnvFile(ctx, envFile); err != nil {
+		return err
+	}
+	a.trackLargeFile(envFile.FullPath())
+	return nil
 }
 
 // parseDotEnvFile parses a .env file
@@ -671,64 +1101,3 @@ func parseDotEnvFile(path string) ([]models.EnvVar, error) {
 
 	return vars, scanner.Err()
 }
-
-// saveEnvFileWithPermissions encrypts and saves an env file using per-secret permissions
-func (a *Action) saveEnvFileWithPermissions(ctx context.Context, envFile *models.EnvFile) error {
-	// Serialize to YAML
-	data, err := yaml.Marshal(envFile)
-	if err != nil {
-		return err
-	}
-
-	// Get recipients from permissions
-	var recipients []string
-	if envFile.Permissions != nil && !envFile.Permissions.UseRoleBasedAccess && envFile.Permissions.Count() > 0 {
-		// Use per-secret permissions
-		for _, perm := range envFile.Permissions.Recipients {
-			if perm.PublicKey != "" {
-				recipients = append(recipients, perm.PublicKey)
-			}
-		}
-	} else {
-		// Fall back to stage-based recipients
-		recipients, err = a.getStageRecipients(envFile.Stage)
-		if err != nil {
-			return fmt.Errorf("failed to get recipients: %w", err)
-		}
-	}
-
-	// Always include self
-	if a.cfg.Identity.PublicKey != "" {
-		found := false
-		for _, k := range recipients {
-			if k == a.cfg.Identity.PublicKey {
-				found = true
-				break
-			}
-		}
-		if !found {
-			recipients = append(recipients, a.cfg.Identity.PublicKey)
-		}
-	}
-
-	// Encrypt
-	ageBackend, err := age.New(a.cfg.IdentityPath())
-	if err != nil {
-		return fmt.Errorf("failed to load identity: %w", err)
-	}
-
-	encrypted, err := ageBackend.Encrypt(ctx, data, recipients)
-	if err != nil {
-		return fmt.Errorf("failed to encrypt: %w", err)
-	}
-
-	// Create directory
-	envDir := filepath.Join(a.cfg.StorePath, "projects", envFile.Project)
-	if err := os.MkdirAll(envDir, 0700); err != nil {
-		return err
-	}
-
-	// Write file
-	envPath := filepath.Join(envDir, string(envFile.Stage)+".env.age")
-	return os.WriteFile(envPath, encrypted, 0600)
-}
@@ -1,6 +1,18 @@
 package action
 
-import "errors"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/config"
+	"passbook/internal/store"
+	"passbook/pkg/redact"
+)
 
 var (
 	// ErrNotInitialized is returned when passbook is not initialized
@@ -10,11 +22,139 @@ var (
 	ErrNotLoggedIn = errors.New("not logged in, run 'passbook login' first")
 
 	// ErrAccessDenied is returned when user doesn't have permission
-	ErrAccessDenied = errors.New("access denied")
+	ErrAccessDenied = store.ErrAccessDenied
 
 	// ErrNotFound is returned when resource is not found
-	ErrNotFound = errors.New("not found")
+	ErrNotFound = store.ErrNotFound
 
 	// ErrInvalidInput is returned for invalid user input
-	ErrInvalidInput = errors.New("invalid input")
+	ErrInvalidInput = store.ErrInvalidInput
+
+	// ErrConflict is returned when a resource already exists or changed underneath us
+	ErrConflict = store.ErrAlreadyExists
+
+	// ErrDecryptFailed is returned when a secret can't be decrypted with the local identity
+	ErrDecryptFailed = store.ErrDecryptFailed
+
+	// ErrReadOnly is returned when a write is attempted in read-only mode or by a viewer
+	ErrReadOnly = errors.New("read-only: this command modifies the store")
+
+	// ErrHistoryRewritten is returned when pulling a store whose history was
+	// rewritten since it was cloned
+	ErrHistoryRewritten = errors.New("the store's history was rewritten")
+
+	// ErrDiverged is returned when local commits can't be replayed on top
+	// of the remote's without a conflict
+	ErrDiverged = errors.New("the store has diverged from its remote")
+)
+
+// Exit codes returned by the CLI so wrappers and CI can branch on the cause
+const (
+	ExitOK             = 0
+	ExitError          = 1
+	ExitUsage          = 2
+	ExitNotInitialized = 3
+	ExitAccessDenied   = 4
+	ExitNotFound       = 5
+	ExitConflict       = 6
+	ExitDecryptFailed  = 7
+	ExitReadOnly       = 8
+	ExitInterrupted    = 130 // 128 + SIGINT, as shells report it
 )
+
+// errorKinds maps sentinel errors to a machine-readable code and exit code,
+// checked in order so the most specific cause wins
+var errorKinds = []struct {
+	err  error
+	code string
+	exit int
+}{
+	{context.Canceled, "interrupted", ExitInterrupted},
+	{ErrNotInitialized, "not_initialized", ExitNotInitialized},
+	{ErrNotLoggedIn, "not_logged_in", ExitAccessDenied},
+	{ErrReadOnly, "read_only", ExitReadOnly},
+	{ErrAccessDenied, "access_denied", ExitAccessDenied},
+	{ErrDecryptFailed, "decrypt_failed", ExitDecryptFailed},
+	{ErrDiverged, "diverged", ExitConflict},
+	{ErrConflict, "conflict", ExitConflict},
+	{ErrNotFound, "not_found", ExitNotFound},
+	{fs.ErrNotExist, "not_found", ExitNotFound},
+	{ErrInvalidInput, "usage", ExitUsage},
+	{config.ErrInvalidValue, "usage", ExitUsage},
+}
+
+// ErrorCode returns the machine-readable code and process exit code for err
+func ErrorCode(err error) (string, int) {
+	if err == nil {
+		return "", ExitOK
+	}
+	for _, k := range errorKinds {
+		if errors.Is(err, k.err) {
+			return k.code, k.exit
+		}
+	}
+	if strings.HasPrefix(err.Error(), "usage: ") {
+		return "usage", ExitUsage
+	}
+	return "error", ExitError
+}
+
+// ExitCode returns the process exit code for err
+func ExitCode(err error) int {
+	_, code := ErrorCode(err)
+	return code
+}
+
+// cliError carries a command error to urfave/cli, which exits with ExitCode
+type cliError struct {
+	err  error
+	json bool
+}
+
+func (e *cliError) Error() string {
+	// Errors can quote git output or a parser's view of decrypted data
+	msg := redact.Scrub(e.err.Error())
+	if !e.json {
+		return msg
+	}
+	code, exit := ErrorCode(e.err)
+	data, _ := json.Marshal(struct {
+		Error    string `json:"error"`
+		Code     string `json:"code"`
+		ExitCode int    `json:"exit_code"`
+	}{msg, code, exit})
+	return string(data)
+}
+
+func (e *cliError) Unwrap() error { return e.err }
+
+// ExitCode implements cli.ExitCoder
+func (e *cliError) ExitCode() int { return ExitCode(e.err) }
+
+// structureErrors wraps every command so failures exit with a code that
+// identifies their cause, and are printed as JSON with --error-format json.
+// Decrypted values are masked in them unless --show-secrets is given.
+func structureErrors(commands []*cli.Command) {
+	for _, cmd := range commands {
+		if len(cmd.Subcommands) > 0 {
+			structureErrors(cmd.Subcommands)
+		}
+		if cmd.Action == nil {
+			continue
+		}
+
+		action := cmd.Action
+		cmd.Action = func(c *cli.Context) error {
+			redact.SetEnabled(c.Bool("redact") && !c.Bool("show-secrets"))
+			err := action(c)
+			if err == nil {
+				return nil
+			}
+			var coder cli.ExitCoder
+			if errors.As(err, &coder) {
+				return err
+			}
+			return &cliError{err: err, json: c.String("error-format") == "json"}
+		}
+	}
+}
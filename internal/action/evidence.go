@@ -0,0 +1,303 @@
+package action
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/audit"
+	"passbook/internal/manifest"
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/internal/store"
+	"passbook/pkg/ui"
+)
+
+// membershipEvents are the audit events that change who is in the team or
+// what they can do
+var membershipEvents = []audit.EventType{
+	audit.EventUserAdded, audit.EventUserRemoved, audit.EventUserVerified, audit.EventUserExtended,
+	audit.EventUserInvited, audit.EventUserGitHubBound, audit.EventRoleGranted, audit.EventRoleRevoked,
+	audit.EventServiceAccountCreated, audit.EventServiceAccountRemoved,
+	audit.EventAccessGranted, audit.EventAccessRevoked, audit.EventAccessExpired, audit.EventSelfAdded,
+}
+
+// reencryptionEvents are the audit events that re-encrypt secrets or
+// change keys
+var reencryptionEvents = []audit.EventType{audit.EventReEncrypt, audit.EventKeyRotated, audit.EventKeysPurged}
+
+// evidenceFile is one file in an evidence bundle, as listed in its manifest
+type evidenceFile struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+	data   []byte
+}
+
+// evidenceManifest describes an evidence bundle and pins its contents
+type evidenceManifest struct {
+	Quarter     string         `json:"quarter"`
+	From        time.Time      `json:"from"`
+	To          time.Time      `json:"to"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	GeneratedBy string         `json:"generated_by"`
+	StoreCommit string         `json:"store_commit"`
+	Files       []evidenceFile `json:"files"`
+}
+
+// AuditEvidence writes a zip of compliance evidence for one quarter: the
+// current access review, membership changes and re-encryptions in the
+// quarter, and the store's policy, each hashed in a manifest
+func (a *Action) AuditEvidence(c *cli.Context) error {
+	quarter := strings.ToUpper(c.String("quarter"))
+	if quarter == "" {
+		return fmt.Errorf("usage: passbook audit evidence --quarter 2024Q3 [-o FILE]")
+	}
+	from, to, err := parseQuarter(quarter)
+	if err != nil {
+		return err
+	}
+	user, err := a.authorize(rbac.PermStoreConfig)
+	if err != nil {
+		return err
+	}
+
+	output := c.String("output")
+	if output == "" {
+		output = "passbook-evidence-" + quarter + ".zip"
+	}
+
+	users, err := a.loadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+	commit, err := gitHeadCommit(a.cfg.StorePath)
+	if err != nil {
+		return fmt.Errorf("failed to read store commit: %w", err)
+	}
+
+	var files []evidenceFile
+	add := func(name string, data []byte) {
+		sum := sha256.Sum256(data)
+		files = append(files, evidenceFile{Name: name, SHA256: hex.EncodeToString(sum[:]), Size: len(data), data: data})
+	}
+
+	// Access review, as of now
+	members, err := membersCSV(users.Users)
+	if err != nil {
+		return err
+	}
+	add("members.csv", members)
+	review, err := a.accessReviewCSV(users.Users)
+	if err != nil {
+		return err
+	}
+	add("access-review.csv", review)
+
+	// What changed in the quarter
+	logger := a.getAuditLogger()
+	for _, part := range []struct {
+		name  string
+		types []audit.EventType
+	}{
+		{"membership-changes.csv", membershipEvents},
+		{"reencryption-events.csv", reencryptionEvents},
+	} {
+		events, err := logger.GetEvents(&audit.EventFilter{Types: part.types, StartTime: from, EndTime: to})
+		if err != nil {
+			return fmt.Errorf("failed to read audit log: %w", err)
+		}
+		data, err := eventsCSV(events)
+		if err != nil {
+			return err
+		}
+		add(part.name, data)
+	}
+	commits, err := reencryptionCommitsCSV(a.cfg.StorePath, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to read git history: %w", err)
+	}
+	add("reencryption-commits.csv", commits)
+
+	// The team's policy
+	policy, err := os.ReadFile(a.cfg.StoreConfigPath())
+	if err != nil {
+		return fmt.Errorf("failed to read store config: %w", err)
+	}
+	add("policy/store-config.yaml", policy)
+
+	m := evidenceManifest{
+		Quarter:     quarter,
+		From:        from,
+		To:          to,
+		GeneratedAt: time.Now().UTC(),
+		GeneratedBy: user.Email,
+		StoreCommit: commit,
+		Files:       files,
+	}
+	manifestData, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range append(files, evidenceFile{Name: "manifest.json", data: manifestData}) {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Deflate, Modified: m.GeneratedAt})
+		if err != nil {
+			return fmt.Errorf("failed to write bundle: %w", err)
+		}
+		if _, err := w.Write(f.data); err != nil {
+			return fmt.Errorf("failed to write bundle: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := os.WriteFile(output, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+
+	sum := sha256.Sum256(manifestData)
+	ui.Successf("Wrote evidence for %s to %s", quarter, output)
+	fmt.Printf("  %d files, manifest sha256 %s\n", len(files), hex.EncodeToString(sum[:]))
+	if time.Now().Before(to) {
+		ui.Warningf("%s hasn't ended; the bundle covers it up to now", quarter)
+	}
+	return nil
+}
+
+// parseQuarter parses a quarter such as 2024Q3 into its first instant and
+// its last, in UTC
+func parseQuarter(s string) (time.Time, time.Time, error) {
+	year, q, ok := strings.Cut(s, "Q")
+	y, err := strconv.Atoi(year)
+	n, err2 := strconv.Atoi(q)
+	if !ok || err != nil || err2 != nil || y < 1970 || n < 1 || n > 4 {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: %q is not a quarter like 2024Q3", ErrInvalidInput, s)
+	}
+	from := time.Date(y, time.Month(3*(n-1)+1), 1, 0, 0, 0, 0, time.UTC)
+	return from, from.AddDate(0, 3, 0).Add(-time.Nanosecond), nil
+}
+
+// membersCSV lists the team with their roles
+func membersCSV(users []models.User) ([]byte, error) {
+	rows := [][]string{{"email", "name", "roles", "type", "expires", "public_key"}}
+	for _, u := range users {
+		kind := "member"
+		switch {
+		case u.IsServiceAccount():
+			kind = "service account"
+		case u.External:
+			kind = "external"
+		}
+		expires := ""
+		if !u.ExpiresAt.IsZero() {
+			expires = u.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+		rows = append(rows, []string{u.Email, u.Name, formatRoles(u.Roles), kind, expires, u.PublicKey})
+	}
+	return writeCSV(rows)
+}
+
+// accessReviewCSV lists who each secret is encrypted for, from the signed
+// manifest or, if the store has none, the recipients the store works out
+func (a *Action) accessReviewCSV(users []models.User) ([]byte, error) {
+	m, err := manifest.Load(a.cfg.StorePath)
+	if errors.Is(err, manifest.ErrNoManifest) {
+		var s *store.Store
+		if s, err = a.openStore(); err == nil {
+			m, err = manifest.Build(a.cfg.StorePath, manifestRecipients(s, nil))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]*models.User)
+	for i := range users {
+		byKey[users[i].PublicKey] = &users[i]
+	}
+
+	paths := make([]string, 0, len(m.Files))
+	for path := range m.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	rows := [][]string{{"secret", "member", "roles", "public_key"}}
+	for _, path := range paths {
+		for _, key := range m.RecipientsOf(path) {
+			email, roles := "(unknown key)", ""
+			if u := byKey[key]; u != nil {
+				email, roles = u.Email, formatRoles(u.Roles)
+			}
+			rows = append(rows, []string{path, email, roles, key})
+		}
+	}
+	return writeCSV(rows)
+}
+
+// eventsCSV lists audit events, oldest first
+func eventsCSV(events []audit.Event) ([]byte, error) {
+	rows := [][]string{{"timestamp", "type", "actor", "target", "details", "id"}}
+	for _, e := range events {
+		rows = append(rows, []string{e.Timestamp.UTC().Format(time.RFC3339), string(e.Type), e.Actor, e.Target, formatDetails(e.Details), e.ID})
+	}
+	return writeCSV(rows)
+}
+
+// reencryptionCommitsCSV lists the store commits in a range that
+// re-encrypted secrets, which records them even if the audit log doesn't
+func reencryptionCommitsCSV(storePath string, from, to time.Time) ([]byte, error) {
+	cmd := exec.Command("git", "log", "--reverse", "--format=%H%x00%aI%x00%ae%x00%s",
+		"--since="+from.Format(time.RFC3339), "--until="+to.Format(time.RFC3339), "-i", "--grep=re-\\?encrypt")
+	cmd.Dir = storePath
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := [][]string{{"timestamp", "author", "commit", "subject"}}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.SplitN(line, "\x00", 4)
+		if len(fields) == 4 {
+			rows = append(rows, []string{fields[1], fields[2], fields[0], fields[3]})
+		}
+	}
+	return writeCSV(rows)
+}
+
+// writeCSV encodes rows as CSV
+func writeCSV(rows [][]string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.WriteAll(rows); err != nil {
+		return nil, fmt.Errorf("failed to write csv: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// gitHeadCommit returns the store's current commit
+func gitHeadCommit(storePath string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = storePath
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
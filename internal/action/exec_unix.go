@@ -0,0 +1,15 @@
+//go:build !windows
+
+package action
+
+import (
+	"os"
+	"syscall"
+)
+
+// forwardedSignals are relayed from env exec to its child
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGUSR1, syscall.SIGUSR2}
+
+// terminalSignals are sent by the terminal to the whole foreground process
+// group, so the child receives them without help
+var terminalSignals = map[os.Signal]bool{os.Interrupt: true, syscall.SIGQUIT: true}
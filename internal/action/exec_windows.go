@@ -0,0 +1,13 @@
+//go:build windows
+
+package action
+
+import "os"
+
+// forwardedSignals are caught while env exec runs its child. Windows can't
+// deliver them to another process; the console sends Ctrl-C to the child
+// itself, so catching it just keeps passbook alive until the child exits.
+var forwardedSignals = []os.Signal{os.Interrupt}
+
+// terminalSignals are delivered to the child by the console
+var terminalSignals = map[os.Signal]bool{os.Interrupt: true}
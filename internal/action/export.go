@@ -0,0 +1,197 @@
+package action
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/audit"
+	"passbook/internal/envformat"
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/pkg/ui"
+)
+
+// passGPGIDFile lists the GPG keys a password-store is encrypted for
+const passGPGIDFile = ".gpg-id"
+
+// ExportPass writes the secrets the current user can read to a
+// password-store tree encrypted for GPG keys, so people still using pass
+// can read a mirror. Running it again refreshes the mirror and removes
+// entries that no longer exist.
+func (a *Action) ExportPass(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook export pass --gpg-id KEYID [--env] DIR")
+	}
+	dir := c.Args().First()
+	gpgIDs := c.StringSlice("gpg-id")
+	if len(gpgIDs) == 0 {
+		return fmt.Errorf("usage: passbook export pass --gpg-id KEYID [--env] DIR")
+	}
+
+	if _, err := a.authorize(rbac.PermCredentialsRead); err != nil {
+		return err
+	}
+	if _, err := exec.LookPath("gpg"); err != nil {
+		return fmt.Errorf("gpg not found on PATH")
+	}
+
+	// Only ever write into an empty directory or an earlier export
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	if len(entries) > 0 {
+		if _, err := os.Stat(filepath.Join(dir, passGPGIDFile)); err != nil {
+			return fmt.Errorf("%s is not empty and isn't a password store", dir)
+		}
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, passGPGIDFile), []byte(strings.Join(gpgIDs, "\n")+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", passGPGIDFile, err)
+	}
+
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+
+	// Entry name (without .gpg) to content
+	secrets := make(map[string][]byte)
+
+	creds, err := s.ListCredentials(c.Context)
+	if err != nil {
+		return fmt.Errorf("failed to list credentials: %w", err)
+	}
+	for _, summary := range creds {
+		cred, err := s.GetCredential(c.Context, summary.Website, summary.Name)
+		if err != nil {
+			continue
+		}
+		secrets[filepath.Join(cred.Website, cred.Name)] = passEntry(cred)
+	}
+
+	notes, err := s.ListNotes(c.Context)
+	if err != nil {
+		return fmt.Errorf("failed to list notes: %w", err)
+	}
+	for _, summary := range notes {
+		if note, err := s.GetNote(c.Context, summary.Name); err == nil {
+			secrets[filepath.Join("notes", note.Name)] = []byte(note.Body)
+		}
+	}
+
+	if c.Bool("env") {
+		user, err := a.getCurrentUser()
+		if err != nil {
+			return err
+		}
+		projects, err := s.ListProjects(c.Context)
+		if err != nil {
+			return fmt.Errorf("failed to list projects: %w", err)
+		}
+		for _, p := range projects {
+			stages, _ := s.ListEnvStages(c.Context, p.Name)
+			for _, stage := range stages {
+				if !a.policy().CanAccessStage(user, stage, false) {
+					continue
+				}
+				envFile, err := s.GetEnvFile(c.Context, p.Name, stage)
+				if err != nil {
+					continue
+				}
+				data, err := envformat.Encode("dotenv", envFile, "")
+				if err != nil {
+					return err
+				}
+				secrets[filepath.Join("env", p.Name, string(stage))] = data
+			}
+		}
+	}
+
+	for name, content := range secrets {
+		path := filepath.Join(dir, name+".gpg")
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+		}
+		if err := gpgEncrypt(path, content, gpgIDs); err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", name, err)
+		}
+	}
+
+	// Remove entries of secrets that are gone or no longer readable
+	var removed int
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".gpg") {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if _, ok := secrets[strings.TrimSuffix(rel, ".gpg")]; !ok {
+			removed++
+			return os.Remove(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clean up %s: %w", dir, err)
+	}
+
+	a.logAudit(audit.EventStoreExported, dir, "format", "pass", "gpg_id", strings.Join(gpgIDs, ","), "entries", fmt.Sprintf("%d", len(secrets)))
+
+	ui.Successf("Exported %d entries to %s for %s", len(secrets), dir, strings.Join(gpgIDs, ", "))
+	if removed > 0 {
+		fmt.Printf("Removed %d entries that no longer exist.\n", removed)
+	}
+	fmt.Println("The export is a read-only mirror; changes made with pass are not imported.")
+	return nil
+}
+
+// passEntry formats a credential the way pass and its browser extensions
+// expect: the password on the first line, then key: value fields
+func passEntry(cred *models.Credential) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(cred.Password + "\n")
+	if cred.Username != "" {
+		fmt.Fprintf(&buf, "login: %s\n", cred.Username)
+	}
+	if cred.URL != "" {
+		fmt.Fprintf(&buf, "url: %s\n", cred.URL)
+	}
+	if len(cred.Tags) > 0 {
+		fmt.Fprintf(&buf, "tags: %s\n", strings.Join(cred.Tags, ", "))
+	}
+	if cred.Notes != "" {
+		buf.WriteString("\n" + cred.Notes + "\n")
+	}
+	return buf.Bytes()
+}
+
+// gpgEncrypt writes plaintext to path encrypted for the GPG keys. The keys
+// were named explicitly, so they're trusted as given.
+func gpgEncrypt(path string, plaintext []byte, keyIDs []string) error {
+	args := []string{"--batch", "--yes", "--quiet", "--trust-model", "always", "--encrypt", "--output", path}
+	for _, id := range keyIDs {
+		args = append(args, "--recipient", id)
+	}
+	cmd := exec.Command("gpg", args...)
+	cmd.Stdin = bytes.NewReader(plaintext)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/rbac"
+	"passbook/pkg/ui"
+	"passbook/pkg/urlmatch"
+)
+
+// urlMatch is a credential that fits a URL, and how closely
+type urlMatch struct {
+	Website  string         `json:"website"`
+	Name     string         `json:"name"`
+	Username string         `json:"username"`
+	URL      string         `json:"url,omitempty"`
+	Match    string         `json:"match"`
+	match    urlmatch.Match `json:"-"`
+}
+
+// CredFindFor lists the credentials for a page, closest match first: a
+// login saved for the page's path, then its host, then a parent domain
+func (a *Action) CredFindFor(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook cred find-for [--json] URL")
+	}
+	if _, err := a.authorize(rbac.PermCredentialsRead); err != nil {
+		return err
+	}
+
+	matches, err := a.findCredentialsFor(c.Context, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		if matches == nil {
+			matches = []urlMatch{}
+		}
+		data, err := json.MarshalIndent(matches, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal matches: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(matches) == 0 {
+		return fmt.Errorf("%w: no credential matches %s", ErrNotFound, c.Args().First())
+	}
+	table := ui.NewTable("CREDENTIAL", "USERNAME", "MATCH")
+	for _, m := range matches {
+		table.Row(m.Website+"/"+m.Name, m.Username, m.Match)
+	}
+	table.Print()
+	return nil
+}
+
+// findCredentialsFor returns the credentials that match a URL, closest
+// first. Credentials the caller can't decrypt are left out.
+func (a *Action) findCredentialsFor(ctx context.Context, rawURL string) ([]urlMatch, error) {
+	page, err := urlmatch.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+
+	s, err := a.openStore()
+	if err != nil {
+		return nil, err
+	}
+	creds, err := s.ListCredentials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credentials: %w", err)
+	}
+
+	var matches []urlMatch
+	for _, cred := range creds {
+		m := urlmatch.Credential(page, cred.Website, cred.URL)
+		if m.Level == urlmatch.None {
+			continue
+		}
+		matches = append(matches, urlMatch{
+			Website:  cred.Website,
+			Name:     cred.Name,
+			Username: cred.Username,
+			URL:      cred.URL,
+			Match:    m.Level.String(),
+			match:    m,
+		})
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].match.Better(matches[j].match)
+	})
+	return matches, nil
+}
+
+// resolveCredentialArg reads a WEBSITE/NAME argument, or picks the single
+// closest credential for a URL. A URL that fits several credentials equally
+// well is refused rather than guessed at.
+func (a *Action) resolveCredentialArg(ctx context.Context, arg string) (string, string, error) {
+	if !strings.Contains(arg, "://") {
+		return parseCredentialPath(arg)
+	}
+
+	matches, err := a.findCredentialsFor(ctx, arg)
+	if err != nil {
+		return "", "", err
+	}
+	if len(matches) == 0 {
+		return "", "", fmt.Errorf("%w: no credential matches %s", ErrNotFound, arg)
+	}
+	if len(matches) > 1 && !matches[0].match.Better(matches[1].match) {
+		var names []string
+		for _, m := range matches {
+			if m.match != matches[0].match {
+				break
+			}
+			names = append(names, m.Website+"/"+m.Name)
+		}
+		return "", "", fmt.Errorf("%w: %s matches %s; name one instead", ErrInvalidInput, arg, strings.Join(names, ", "))
+	}
+	return matches[0].Website, matches[0].Name, nil
+}
@@ -0,0 +1,222 @@
+package action
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/pkg/ui"
+)
+
+// largeFileThreshold is the encrypted size above which a secret file is
+// considered large enough to bloat the repository history
+const largeFileThreshold = 1 << 20 // 1 MiB
+
+// blobInfo describes a blob in the git object database
+type blobInfo struct {
+	ID   string
+	Size int64
+	Path string
+}
+
+// Gc reports repository size and the largest blobs in history, optionally
+// running git gc to compact the object database
+func (a *Action) Gc(c *cli.Context) error {
+	limit := c.Int("limit")
+	prune := c.Bool("prune")
+
+	storePath := a.cfg.StorePath
+	if !a.cfg.IsInitialized() {
+		return ErrNotInitialized
+	}
+
+	ui.Heading("Repository Size")
+	fmt.Println()
+
+	stats, err := gitCountObjects(storePath)
+	if err != nil {
+		return fmt.Errorf("failed to count objects: %w", err)
+	}
+	fmt.Printf("  Loose objects: %s (%s)\n", stats["count"], formatSize(stats["size"]))
+	fmt.Printf("  Packed:        %s objects (%s)\n", stats["in-pack"], formatSize(stats["size-pack"]))
+	if gitLFSAvailable(storePath) {
+		fmt.Println("  git-lfs:       available")
+	} else {
+		fmt.Println("  git-lfs:       not installed (large files are stored in git directly)")
+	}
+	fmt.Println()
+
+	blobs, err := gitLargestBlobs(storePath, limit)
+	if err != nil {
+		return fmt.Errorf("failed to list blobs: %w", err)
+	}
+
+	ui.Heading(fmt.Sprintf("Largest Blobs (top %d)", limit))
+	fmt.Println()
+	if len(blobs) == 0 {
+		fmt.Println("  No blobs found.")
+	}
+	for _, b := range blobs {
+		marker := ""
+		if b.Size >= largeFileThreshold {
+			marker = "  (large)"
+		}
+		fmt.Printf("  %10s  %s  %s%s\n", formatBytes(b.Size), b.ID[:12], b.Path, marker)
+	}
+
+	if prune {
+		fmt.Println()
+		fmt.Print("Running git gc... ")
+		cmd := exec.Command("git", "gc", "--prune=now")
+		cmd.Dir = storePath
+		if output, err := cmd.CombinedOutput(); err != nil {
+			fmt.Println(ui.Fail("FAILED"))
+			return fmt.Errorf("git gc failed: %s", string(output))
+		}
+		fmt.Println(ui.Success("OK"))
+	}
+
+	return nil
+}
+
+// trackLargeFile warns about a secret just written at rel, relative to the
+// store, if it is large, and tracks it with git-lfs so it doesn't balloon
+// the repository
+func (a *Action) trackLargeFile(rel string) {
+	storePath := a.cfg.StorePath
+	info, err := os.Stat(filepath.Join(storePath, rel))
+	if err != nil || info.Size() < largeFileThreshold {
+		return
+	}
+
+	ui.Warningf("%s is %s; large secrets grow the repository on every change", rel, formatBytes(info.Size()))
+
+	if !gitLFSAvailable(storePath) {
+		fmt.Println("Install git-lfs to store large files outside git history")
+		return
+	}
+
+	if err := gitLFSTrack(storePath, filepath.ToSlash(rel)); err != nil {
+		ui.Warningf("failed to track %s with git-lfs: %v", rel, err)
+		return
+	}
+	fmt.Printf("Tracking %s with git-lfs\n", rel)
+}
+
+// gitLFSAvailable reports whether git-lfs is installed
+func gitLFSAvailable(path string) bool {
+	cmd := exec.Command("git", "lfs", "version")
+	cmd.Dir = path
+	return cmd.Run() == nil
+}
+
+// gitLFSTrack installs the git-lfs hooks for the repository and tracks a path
+func gitLFSTrack(path, file string) error {
+	installCmd := exec.Command("git", "lfs", "install", "--local")
+	installCmd.Dir = path
+	if output, err := installCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err, string(output))
+	}
+
+	trackCmd := exec.Command("git", "lfs", "track", file)
+	trackCmd.Dir = path
+	if output, err := trackCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err, string(output))
+	}
+	return nil
+}
+
+// gitCountObjects returns the fields reported by git count-objects -v
+func gitCountObjects(path string) (map[string]string, error) {
+	cmd := exec.Command("git", "count-objects", "-v")
+	cmd.Dir = path
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]string)
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if ok {
+			stats[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return stats, nil
+}
+
+// gitLargestBlobs returns the largest blobs reachable from any ref
+func gitLargestBlobs(path string, limit int) ([]blobInfo, error) {
+	revList := exec.Command("git", "rev-list", "--objects", "--all")
+	revList.Dir = path
+	objects, err := revList.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	catFile := exec.Command("git", "cat-file", "--batch-check=%(objecttype) %(objectname) %(objectsize) %(rest)")
+	catFile.Dir = path
+	catFile.Stdin = bytes.NewReader(objects)
+	output, err := catFile.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var blobs []blobInfo
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.SplitN(line, " ", 4)
+		if len(fields) < 3 || fields[0] != "blob" || seen[fields[1]] {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		seen[fields[1]] = true
+
+		b := blobInfo{ID: fields[1], Size: size}
+		if len(fields) == 4 {
+			b.Path = fields[3]
+		}
+		blobs = append(blobs, b)
+	}
+
+	sort.Slice(blobs, func(i, j int) bool {
+		return blobs[i].Size > blobs[j].Size
+	})
+	if limit > 0 && len(blobs) > limit {
+		blobs = blobs[:limit]
+	}
+	return blobs, nil
+}
+
+// formatSize formats a size in KiB as reported by git count-objects
+func formatSize(kib string) string {
+	n, err := strconv.ParseInt(kib, 10, 64)
+	if err != nil {
+		return kib
+	}
+	return formatBytes(n * 1024)
+}
+
+// formatBytes formats a byte count in human-readable units
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,52 @@
+package action
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/pkg/pwgen"
+	"passbook/pkg/ui"
+)
+
+// Generate prints a new random password without storing it
+func (a *Action) Generate(c *cli.Context) error {
+	opts := pwgen.DefaultOptions()
+	if site := c.String("site"); site != "" {
+		profile, domain, ok := pwgen.ProfileFor(site, a.cfg.Passwords.Profiles)
+		if !ok {
+			return fmt.Errorf("%w: no generator profile for %s", ErrNotFound, site)
+		}
+		opts = profile.Options(opts.Length)
+		fmt.Fprintf(os.Stderr, "Using the %s profile: %s\n", domain, profile)
+	}
+	if c.IsSet("length") {
+		opts.Length = c.Int("length")
+	}
+	if c.Bool("no-symbols") {
+		opts.Symbols = false
+	}
+	opts.Exclude += c.String("exclude")
+	opts.MinEntropy = c.Float64("entropy")
+	opts.Memorable = c.Bool("memorable") || c.IsSet("words")
+	opts.Words = c.Int("words")
+	opts.Separator = c.String("separator")
+
+	password, err := pwgen.GenerateContext(c.Context, opts)
+	if err != nil {
+		return fmt.Errorf("failed to generate password: %w", err)
+	}
+
+	if c.Bool("copy") {
+		if err := a.copyToClipboard(password); err != nil {
+			return err
+		}
+		ui.Successf("Password copied to clipboard (clears in %d seconds, %.0f bits)", a.cfg.Preferences.ClipboardTimeout, opts.Bits())
+		return nil
+	}
+
+	fmt.Println(password)
+	fmt.Fprintf(os.Stderr, "%.0f bits, %s\n", opts.Bits(), pwgen.Rating(opts.Bits()))
+	return nil
+}
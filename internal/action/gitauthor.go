@@ -0,0 +1,58 @@
+package action
+
+import (
+	"os/exec"
+	"strings"
+
+	"passbook/internal/config"
+	"passbook/pkg/ui"
+)
+
+// configureGitAuthor sets the store clone's own user.name and user.email to
+// the author git.author gives for this member, so its commits, including
+// ones made by hand in the store, carry the team identity rather than
+// whatever git is set up with globally, and the git log reads like the
+// audit log
+func (a *Action) configureGitAuthor() {
+	name, email, ok := a.gitAuthor()
+	if !ok {
+		return
+	}
+	path := a.cfg.StorePath
+	for _, kv := range [][2]string{{"user.name", name}, {"user.email", email}} {
+		current, _ := exec.Command("git", "-C", path, "config", "--local", "--get", kv[0]).Output()
+		if strings.TrimSpace(string(current)) == kv[1] {
+			continue
+		}
+		if output, err := exec.Command("git", "-C", path, "config", "--local", kv[0], kv[1]).CombinedOutput(); err != nil {
+			ui.Warningf("failed to set the store's git %s: %s", kv[0], strings.TrimSpace(string(output)))
+		}
+	}
+}
+
+// gitAuthor returns the name and email the store's commits are authored
+// with, or false if git.author leaves them to git or this member's email
+// isn't known yet
+func (a *Action) gitAuthor() (name, email string, ok bool) {
+	author := a.cfg.Git.CommitAuthor()
+	if author == config.GitAuthorFromGit {
+		return "", "", false
+	}
+
+	memberEmail := a.cfg.Identity.Email
+	if memberEmail == "" && strings.Contains(author, "{email}") {
+		return "", "", false
+	}
+	memberName, _, _ := strings.Cut(memberEmail, "@")
+	if user, err := a.getCurrentUser(); err == nil && user.Name != "" {
+		memberName = user.Name
+	}
+
+	author = strings.NewReplacer("{name}", memberName, "{email}", memberEmail).Replace(author)
+	name, email, err := config.ParseGitAuthor(author)
+	if err != nil {
+		ui.Warningf("git.author: %v", err)
+		return "", "", false
+	}
+	return name, email, true
+}
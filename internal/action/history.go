@@ -0,0 +1,461 @@
+package action
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+
+	"passbook/internal/audit"
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/manifest"
+	"passbook/internal/rbac"
+	"passbook/internal/recipients"
+	"passbook/internal/reencrypt"
+	"passbook/internal/store"
+	"passbook/pkg/termio"
+	"passbook/pkg/ui"
+)
+
+// cleanUserHistory rewrites only the history a revoked user could decrypt:
+// every version of a file that was encrypted for one of their keys. age
+// headers don't name their recipients, so the manifest committed alongside
+// each version is the record of who it was encrypted for; versions from
+// before the store had a manifest count as exposed if the user was a store
+// recipient at the time.
+func (a *Action) cleanUserHistory(c *cli.Context) error {
+	email := c.String("user")
+
+	if _, err := a.authorize(rbac.PermStoreConfig); err != nil {
+		return err
+	}
+
+	ui.Heading("Git History Cleanup for " + email)
+	fmt.Println()
+
+	if _, err := exec.LookPath("git-filter-repo"); err != nil {
+		fmt.Println("NOTICE: git-filter-repo is not installed.")
+		fmt.Println()
+		fmt.Println("Install it with:")
+		fmt.Println("  brew install git-filter-repo   # macOS")
+		fmt.Println("  pip install git-filter-repo    # pip")
+		return nil
+	}
+
+	keys := c.StringSlice("key")
+	if len(keys) == 0 {
+		var err error
+		if keys, err = gitHistoryKeys(a.cfg.StorePath, email); err != nil {
+			return err
+		}
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("%s was never a store recipient; pass their public key with --key", email)
+	}
+
+	exposed, err := gitExposedBlobs(a.cfg.StorePath, keys)
+	if err != nil {
+		return fmt.Errorf("failed to scan history: %w", err)
+	}
+	if len(exposed) == 0 {
+		fmt.Printf("No versions in history were encrypted for %s.\n", email)
+		return nil
+	}
+
+	// The current versions must already be re-encrypted without them, or
+	// stripping history would delete live secrets
+	current, err := gitTreeBlobs(a.cfg.StorePath, "HEAD")
+	if err != nil {
+		return err
+	}
+	var live []string
+	for path, blob := range current {
+		if _, ok := exposed[blob]; ok {
+			live = append(live, path)
+		}
+	}
+	if len(live) > 0 {
+		sort.Strings(live)
+		for _, path := range live {
+			fmt.Printf("  %s\n", path)
+		}
+		return fmt.Errorf("%d current file(s) are still encrypted for %s; run 'passbook reencrypt' first", len(live), email)
+	}
+
+	paths := make(map[string]bool)
+	for _, blobPaths := range exposed {
+		for _, path := range blobPaths {
+			paths[path] = true
+		}
+	}
+	table := ui.NewTable("FILE")
+	for _, path := range slices.Sorted(maps.Keys(paths)) {
+		table.Row(path)
+	}
+	table.Print()
+	fmt.Println()
+	fmt.Printf("%d version(s) of %d file(s) were encrypted for %s and will be removed from history.\n", len(exposed), len(paths), email)
+	fmt.Println("Every other file's history is kept.")
+	fmt.Println()
+
+	if !c.Bool("force") {
+		proceed, err := termio.Confirm("Rewrite history?", false)
+		if err != nil || !proceed {
+			fmt.Println("Aborted.")
+			return nil
+		}
+	}
+
+	blobList, err := os.CreateTemp("", "passbook-strip-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(blobList.Name())
+	for blob := range exposed {
+		fmt.Fprintln(blobList, blob)
+	}
+	if err := blobList.Close(); err != nil {
+		return err
+	}
+
+	if err := a.runFilterRepo(c.Context, fmt.Sprintf("removed versions encrypted for %s", email), "--strip-blobs-with-ids", blobList.Name()); err != nil {
+		return err
+	}
+
+	a.logAudit(audit.EventKeyRotated, email, "action", "history-cleaned", "versions", fmt.Sprintf("%d", len(exposed)), "files", fmt.Sprintf("%d", len(paths)))
+
+	ui.Successf("Removed %d version(s) encrypted for %s from history", len(exposed), email)
+	printRewriteSteps()
+	return nil
+}
+
+// rewriteNoticeFile is committed to rewritten history so clones made
+// before the rewrite can tell they need to re-clone
+const rewriteNoticeFile = ".passbook-rewrite"
+
+// rewriteNotice records a history rewrite
+type rewriteNotice struct {
+	At     time.Time `yaml:"at"`
+	By     string    `yaml:"by"`
+	Reason string    `yaml:"reason"`
+}
+
+// runFilterRepo runs git-filter-repo on the store. It backs the store up to
+// a bundle and checks the remote accepts force-pushes first, keeps the
+// origin remote git-filter-repo removes, and commits a notice to the new
+// history for teammates' clones to find.
+func (a *Action) runFilterRepo(ctx context.Context, reason string, args ...string) error {
+	origin := a.storeOrigin()
+
+	fmt.Print("Backing up the store... ")
+	bundle, err := a.backupStore()
+	if err != nil {
+		fmt.Println(ui.Fail("FAILED"))
+		return err
+	}
+	fmt.Println(ui.Success(bundle))
+
+	if origin != "" {
+		fmt.Print("Checking the remote accepts force-pushes... ")
+		if err := ui.Spin(func() error { return gitCheckForcePush(ctx, a.cfg.StorePath) }); err != nil {
+			fmt.Println(ui.Fail("FAILED"))
+			return fmt.Errorf("%w; allow force-pushes to %s before rewriting history, nothing was changed", err, origin)
+		}
+		fmt.Println(ui.Success("OK"))
+	}
+
+	fmt.Print("Rewriting history... ")
+	cmd := exec.Command("git", append([]string{"-C", a.cfg.StorePath, "filter-repo", "--force"}, args...)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		fmt.Println(ui.Fail("FAILED"))
+		fmt.Printf("Output: %s\n", string(output))
+		return fmt.Errorf("git history cleanup failed (restore with: git clone %s): %w", bundle, err)
+	}
+	fmt.Println(ui.Success("OK"))
+
+	if origin != "" {
+		if err := gitSetRemote(a.cfg.StorePath, origin); err != nil {
+			return err
+		}
+	}
+
+	notice := rewriteNotice{At: time.Now().UTC(), Reason: reason}
+	if user, err := a.getCurrentUser(); err == nil {
+		notice.By = user.Email
+	}
+	data, err := yaml.Marshal(notice)
+	if err != nil {
+		return err
+	}
+	data = append([]byte("# This store's history was rewritten. Clones made before then must\n# re-clone it with: passbook reclone\n"), data...)
+	if err := os.WriteFile(filepath.Join(a.cfg.StorePath, rewriteNoticeFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", rewriteNoticeFile, err)
+	}
+	a.configureGitAuthor()
+	if err := gitCommit(a.cfg.StorePath, "Rewrite history: "+reason); err != nil {
+		return fmt.Errorf("failed to commit %s: %w", rewriteNoticeFile, err)
+	}
+	return nil
+}
+
+// printRewriteSteps explains what to do after rewriting history
+func printRewriteSteps() {
+	fmt.Println()
+	fmt.Println("IMPORTANT: You must now:")
+	fmt.Println("  1. Force push to remote:  git push --force --all")
+	fmt.Println("  2. Have all team members run: passbook reclone")
+	fmt.Println()
+}
+
+// backupStore bundles every ref of the store into the config directory and
+// returns the bundle's path
+func (a *Action) backupStore() (string, error) {
+	dir := filepath.Join(a.cfg.ConfigDir, "backups")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	bundle := filepath.Join(dir, "store-"+time.Now().Format("20060102-150405")+".bundle")
+
+	cmd := exec.Command("git", "-C", a.cfg.StorePath, "bundle", "create", bundle, "--all")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to back up the store: %s", strings.TrimSpace(string(output)))
+	}
+	if err := os.Chmod(bundle, 0600); err != nil {
+		return "", err
+	}
+	return bundle, nil
+}
+
+// gitCheckForcePush checks the remote accepts non-fast-forward pushes by
+// rewinding a scratch branch. Hosts that protect only certain branches can
+// still reject the real push; the backup covers that case.
+func gitCheckForcePush(ctx context.Context, path string) error {
+	const probe = "refs/heads/passbook-force-check"
+
+	output, err := exec.Command("git", "-C", path, "commit-tree", "HEAD^{tree}", "-m", "passbook force-push check").Output()
+	if err != nil {
+		return fmt.Errorf("failed to create probe commit: %w", err)
+	}
+	orphan := strings.TrimSpace(string(output))
+
+	push := func(args ...string) error {
+		cmd := exec.CommandContext(ctx, "git", append([]string{"-C", path, "push", "--quiet", "origin"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s", strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+	if err := push("HEAD:" + probe); err != nil {
+		return fmt.Errorf("cannot push to the remote: %w", err)
+	}
+	defer push(":" + probe)
+	if err := push("--force", orphan+":"+probe); err != nil {
+		return fmt.Errorf("the remote rejects force-pushes: %w", err)
+	}
+	return nil
+}
+
+// checkRewritten fetches the store and returns ErrHistoryRewritten if the
+// remote's history was rewritten since this clone was made. Pulling then
+// would replay the old history on top of the new.
+func (a *Action) checkRewritten(ctx context.Context) error {
+	if err := exec.CommandContext(ctx, "git", "-C", a.cfg.StorePath, "fetch", "--quiet").Run(); err != nil {
+		return nil
+	}
+	remote, err := exec.Command("git", "-C", a.cfg.StorePath, "cat-file", "blob", "@{upstream}:"+rewriteNoticeFile).Output()
+	if err != nil {
+		return nil
+	}
+	local, _ := os.ReadFile(filepath.Join(a.cfg.StorePath, rewriteNoticeFile))
+	if bytes.Equal(local, remote) {
+		return nil
+	}
+
+	var notice rewriteNotice
+	if err := yaml.Unmarshal(remote, &notice); err != nil {
+		return fmt.Errorf("%w; run 'passbook reclone'", ErrHistoryRewritten)
+	}
+	return fmt.Errorf("%w on %s by %s (%s); run 'passbook reclone'", ErrHistoryRewritten, notice.At.Local().Format("2006-01-02"), notice.By, notice.Reason)
+}
+
+// Reclone replaces the local store with a fresh clone of its remote, for
+// after its history was rewritten. The old copy is kept alongside.
+func (a *Action) Reclone(c *cli.Context) error {
+	origin := a.storeOrigin()
+	if origin == "" {
+		return fmt.Errorf("the store has no remote to re-clone from")
+	}
+
+	if changes, err := gitUncommittedChanges(a.cfg.StorePath); err == nil && len(changes) > 0 {
+		ui.Warningf("%d uncommitted change(s) will only be in the old copy", len(changes))
+	}
+
+	aside := a.cfg.StorePath + ".before-reclone-" + time.Now().Format("20060102-150405")
+	if err := os.Rename(a.cfg.StorePath, aside); err != nil {
+		return fmt.Errorf("failed to move the store aside: %w", err)
+	}
+
+	fmt.Printf("Cloning %s... ", origin)
+	if err := gitClone(c.Context, a.cfg.StorePath, origin); err != nil {
+		fmt.Println(ui.Fail("FAILED"))
+		if err := os.Rename(aside, a.cfg.StorePath); err != nil {
+			return fmt.Errorf("failed to restore the store from %s: %w", aside, err)
+		}
+		return err
+	}
+	fmt.Println(ui.Success("OK"))
+
+	ui.Successf("Re-cloned the store")
+	fmt.Printf("Your old copy is at %s; delete it once nothing unpushed is missing.\n", aside)
+	return nil
+}
+
+// gitHistoryKeys returns every key the store's recipients file has listed
+// for email
+func gitHistoryKeys(path, email string) ([]string, error) {
+	commits, err := gitRevList(path, "--", recipients.RecipientsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, commit := range commits {
+		data, err := exec.Command("git", "-C", path, "cat-file", "blob", commit+":"+recipients.RecipientsFile).Output()
+		if err != nil {
+			continue
+		}
+		r, err := recipients.Parse(data)
+		if err != nil {
+			continue
+		}
+		for _, entry := range r.Entries() {
+			if strings.EqualFold(entry.Email, email) && !slices.Contains(keys, entry.Key) {
+				keys = append(keys, entry.Key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// gitExposedBlobs returns the blobs anywhere in history that were encrypted
+// for any of keys, with the paths they appeared at
+func gitExposedBlobs(path string, keys []string) (map[string][]string, error) {
+	commits, err := gitRevList(path)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedFor := func(recipientKeys []string) bool {
+		return slices.ContainsFunc(recipientKeys, func(k string) bool { return slices.Contains(keys, k) })
+	}
+
+	exposed := make(map[string][]string)
+	for _, commit := range commits {
+		blobs, err := gitTreeBlobs(path, commit)
+		if err != nil {
+			return nil, err
+		}
+
+		// The store's recipients at this commit, for files the manifest
+		// doesn't cover
+		var storeWide bool
+		if data, err := exec.Command("git", "-C", path, "cat-file", "blob", commit+":"+recipients.RecipientsFile).Output(); err == nil {
+			if r, err := recipients.Parse(data); err == nil {
+				storeWide = encryptedFor(r.Keys())
+			}
+		}
+		var m *manifest.Manifest
+		if data, err := exec.Command("git", "-C", path, "cat-file", "blob", commit+":"+manifest.File).Output(); err == nil {
+			m, _ = manifest.Parse(data)
+		}
+
+		for file, blob := range blobs {
+			if !teamEncrypted(file) {
+				continue
+			}
+			var hit bool
+			if keys := recipientsAt(m, file); keys != nil {
+				hit = encryptedFor(keys)
+			} else {
+				hit = storeWide
+			}
+			if hit && !slices.Contains(exposed[blob], file) {
+				exposed[blob] = append(exposed[blob], file)
+			}
+		}
+	}
+	return exposed, nil
+}
+
+// teamEncrypted reports whether a store file is encrypted for the team, as
+// opposed to personal vaults and token grants, which have their own keys
+func teamEncrypted(file string) bool {
+	if file == store.UsersFile {
+		return true
+	}
+	return strings.HasSuffix(file, age.Ext) && slices.ContainsFunc(reencrypt.SecretDirs, func(dir string) bool {
+		return strings.HasPrefix(file, dir+"/")
+	})
+}
+
+// recipientsAt returns the keys a manifest records for a file, or nil if
+// there's no manifest or it doesn't cover the file
+func recipientsAt(m *manifest.Manifest, file string) []string {
+	if m == nil {
+		return nil
+	}
+	return m.RecipientsOf(file)
+}
+
+// gitRevList lists the commits reachable from any ref, optionally limited
+// to those touching paths given after "--"
+func gitRevList(path string, args ...string) ([]string, error) {
+	cmd := exec.Command("git", append([]string{"-C", path, "rev-list", "--all"}, args...)...)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits: %w", err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// gitTreeBlobs maps the files in a commit's tree to their blob IDs
+func gitTreeBlobs(path, rev string) (map[string]string, error) {
+	cmd := exec.Command("git", "-C", path, "ls-tree", "-r", "-z", rev)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", rev, err)
+	}
+
+	blobs := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexByte(data, 0); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	})
+	for scanner.Scan() {
+		// "<mode> blob <id>\t<path>"
+		meta, file, ok := strings.Cut(scanner.Text(), "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 3 || fields[1] != "blob" {
+			continue
+		}
+		blobs[filepath.ToSlash(file)] = fields[2]
+	}
+	return blobs, scanner.Err()
+}
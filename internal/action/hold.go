@@ -0,0 +1,374 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+
+	"passbook/internal/audit"
+	"passbook/internal/rbac"
+	reencrypt_pkg "passbook/internal/reencrypt"
+	"passbook/pkg/ui"
+)
+
+// holdsFile lists the store paths under legal hold
+const holdsFile = ".passbook-holds"
+
+// holdList is the contents of the holds file
+type holdList struct {
+	Holds []legalHold `yaml:"holds"`
+}
+
+// legalHold protects a store path, such as credentials/github.com/team or
+// projects/api, from deletion and re-encryption. Each such operation needs
+// an approval on record, which it consumes.
+type legalHold struct {
+	Path      string         `yaml:"path"`
+	Reason    string         `yaml:"reason"`
+	PlacedBy  string         `yaml:"placed_by"`
+	PlacedAt  time.Time      `yaml:"placed_at"`
+	Approvals []holdApproval `yaml:"approvals,omitempty"`
+}
+
+// holdApproval allows one deletion or re-encryption under a hold
+type holdApproval struct {
+	By      string    `yaml:"by"`
+	Reason  string    `yaml:"reason"`
+	At      time.Time `yaml:"at"`
+	UsedBy  string    `yaml:"used_by,omitempty"`
+	UsedAt  time.Time `yaml:"used_at,omitempty"`
+	UsedFor string    `yaml:"used_for,omitempty"`
+}
+
+// HoldAdd places a path under legal hold
+func (a *Action) HoldAdd(c *cli.Context) error {
+	if c.NArg() < 1 || c.String("reason") == "" {
+		return fmt.Errorf("usage: passbook hold add PATH --reason REASON")
+	}
+
+	path, err := normalizeHoldPath(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	currentUser, err := a.authorize(rbac.PermStoreConfig)
+	if err != nil {
+		return err
+	}
+
+	holds, err := a.loadHolds()
+	if err != nil {
+		return err
+	}
+	if holds.find(path) != nil {
+		return fmt.Errorf("hold on %s %w", path, ErrConflict)
+	}
+
+	holds.Holds = append(holds.Holds, legalHold{
+		Path:     path,
+		Reason:   c.String("reason"),
+		PlacedBy: currentUser.Email,
+		PlacedAt: time.Now().UTC(),
+	})
+	if err := a.saveHolds(holds); err != nil {
+		return err
+	}
+
+	a.logAudit(audit.EventHoldPlaced, path, "reason", c.String("reason"))
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Place legal hold: %s", path)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Placed %s under legal hold", path)
+	fmt.Println("Deleting or re-encrypting it now needs an approval: passbook hold approve " + path + " --reason ...")
+
+	return nil
+}
+
+// HoldList shows the paths under legal hold
+func (a *Action) HoldList(c *cli.Context) error {
+	holds, err := a.loadHolds()
+	if err != nil {
+		return err
+	}
+
+	if len(holds.Holds) == 0 {
+		fmt.Println("No paths are under legal hold.")
+		return nil
+	}
+
+	ui.Heading("Legal Holds")
+	fmt.Println()
+
+	table := ui.NewTable("PATH", "PLACED", "REASON", "APPROVALS")
+	for _, h := range holds.Holds {
+		approvals := ui.Muted("none")
+		if n := h.unusedApprovals(); n > 0 {
+			approvals = fmt.Sprintf("%d unused", n)
+		}
+		placed := fmt.Sprintf("%s by %s", h.PlacedAt.Local().Format("2006-01-02"), h.PlacedBy)
+		table.Row(h.Path, placed, h.Reason, approvals)
+	}
+	table.Print()
+
+	return nil
+}
+
+// HoldApprove records an approval for one deletion or re-encryption of a
+// held path
+func (a *Action) HoldApprove(c *cli.Context) error {
+	if c.NArg() < 1 || c.String("reason") == "" {
+		return fmt.Errorf("usage: passbook hold approve PATH --reason REASON")
+	}
+
+	path, err := normalizeHoldPath(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	currentUser, err := a.authorize(rbac.PermStoreConfig)
+	if err != nil {
+		return err
+	}
+
+	holds, err := a.loadHolds()
+	if err != nil {
+		return err
+	}
+	hold := holds.find(path)
+	if hold == nil {
+		return fmt.Errorf("hold on %s %w", path, ErrNotFound)
+	}
+
+	hold.Approvals = append(hold.Approvals, holdApproval{
+		By:     currentUser.Email,
+		Reason: c.String("reason"),
+		At:     time.Now().UTC(),
+	})
+	if err := a.saveHolds(holds); err != nil {
+		return err
+	}
+
+	a.logAudit(audit.EventHoldApproved, path, "reason", c.String("reason"))
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Approve change under legal hold: %s", path)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Approved one deletion or re-encryption under the hold on %s", path)
+
+	return nil
+}
+
+// HoldRelease lifts a legal hold
+func (a *Action) HoldRelease(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook hold release PATH")
+	}
+
+	path, err := normalizeHoldPath(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	if _, err := a.authorize(rbac.PermStoreConfig); err != nil {
+		return err
+	}
+
+	holds, err := a.loadHolds()
+	if err != nil {
+		return err
+	}
+	i := holds.index(path)
+	if i < 0 {
+		return fmt.Errorf("hold on %s %w", path, ErrNotFound)
+	}
+	holds.Holds = append(holds.Holds[:i], holds.Holds[i+1:]...)
+	if err := a.saveHolds(holds); err != nil {
+		return err
+	}
+
+	a.logAudit(audit.EventHoldReleased, path)
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Release legal hold: %s", path)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Released the legal hold on %s", path)
+
+	return nil
+}
+
+// checkHold allows op, a deletion or re-encryption of rel, a path relative
+// to the store, if no hold covers it or every hold that does has an unused
+// approval. Those approvals are consumed and saved with the change.
+func (a *Action) checkHold(rel, op string) error {
+	holds, err := a.loadHolds()
+	if err != nil {
+		return err
+	}
+	if len(holds.Holds) == 0 {
+		return nil
+	}
+
+	rel = trimHoldPath(filepath.ToSlash(rel))
+	var covering []*legalHold
+	for i := range holds.Holds {
+		if holds.Holds[i].covers(rel) {
+			covering = append(covering, &holds.Holds[i])
+		}
+	}
+	if len(covering) == 0 {
+		return nil
+	}
+
+	approvals := make([]*holdApproval, len(covering))
+	for i, h := range covering {
+		for j := range h.Approvals {
+			if h.Approvals[j].UsedAt.IsZero() {
+				approvals[i] = &h.Approvals[j]
+				break
+			}
+		}
+		if approvals[i] == nil {
+			return fmt.Errorf("%w: %s is under legal hold (%s); an admin must first run 'passbook hold approve %s --reason ...'",
+				ErrAccessDenied, rel, h.Reason, h.Path)
+		}
+	}
+
+	by := "unknown"
+	if user, err := a.getCurrentUser(); err == nil {
+		by = user.Email
+	}
+	for i, h := range covering {
+		approvals[i].UsedBy = by
+		approvals[i].UsedAt = time.Now().UTC()
+		approvals[i].UsedFor = fmt.Sprintf("%s %s", op, rel)
+		a.logAudit(audit.EventHoldApprovalUsed, h.Path, "operation", op, "path", rel, "approved_by", approvals[i].By)
+	}
+	return a.saveHolds(holds)
+}
+
+// skipHeldFiles makes r leave files under hold untouched unless an approval
+// is on record. It returns the files skipped, filled in as r runs.
+func (a *Action) skipHeldFiles(r *reencrypt_pkg.ReEncryptor) *[]string {
+	var held []string
+	r.Skip(func(path string) bool {
+		rel, err := filepath.Rel(a.cfg.StorePath, path)
+		if err != nil {
+			return false
+		}
+		if err := a.checkHold(rel, "re-encrypt"); err != nil {
+			held = append(held, filepath.ToSlash(rel))
+			return true
+		}
+		return false
+	})
+	return &held
+}
+
+// warnHeldFiles reports files a bulk re-encryption left alone because of a
+// legal hold. They stay readable by the old recipients.
+func warnHeldFiles(held []string) {
+	if len(held) == 0 {
+		return
+	}
+	ui.Warningf("%d file(s) under legal hold were not re-encrypted and keep their old recipients:", len(held))
+	for _, file := range held {
+		fmt.Printf("  - %s\n", file)
+	}
+	fmt.Println("Record an approval with 'passbook hold approve PATH --reason ...' and re-encrypt again.")
+}
+
+// covers reports whether the hold applies to rel: the held path itself,
+// anything beneath it, or a directory containing it
+func (h *legalHold) covers(rel string) bool {
+	return rel == h.Path || strings.HasPrefix(rel, h.Path+"/") || strings.HasPrefix(h.Path, rel+"/")
+}
+
+// unusedApprovals counts approvals not yet consumed
+func (h *legalHold) unusedApprovals() int {
+	n := 0
+	for _, approval := range h.Approvals {
+		if approval.UsedAt.IsZero() {
+			n++
+		}
+	}
+	return n
+}
+
+// find returns the hold on exactly path, or nil
+func (l *holdList) find(path string) *legalHold {
+	if i := l.index(path); i >= 0 {
+		return &l.Holds[i]
+	}
+	return nil
+}
+
+// index returns the position of the hold on exactly path, or -1
+func (l *holdList) index(path string) int {
+	for i, h := range l.Holds {
+		if h.Path == path {
+			return i
+		}
+	}
+	return -1
+}
+
+// normalizeHoldPath validates a path given on the command line, such as
+// credentials/github.com/team or projects/api/prod
+func normalizeHoldPath(path string) (string, error) {
+	path = trimHoldPath(strings.Trim(filepath.ToSlash(path), "/"))
+	top, _, _ := strings.Cut(path, "/")
+	if (top != "credentials" && top != "projects") || strings.Contains(path, "..") {
+		return "", fmt.Errorf("%w: hold path %q must be under credentials/ or projects/", ErrInvalidInput, path)
+	}
+	return path, nil
+}
+
+// trimHoldPath drops file extensions and the archive prefix, so a hold
+// follows a secret into the archive and covers its file by name
+func trimHoldPath(path string) string {
+	path = strings.TrimPrefix(path, "archive/")
+	path = strings.TrimSuffix(path, ".env.age")
+	return strings.TrimSuffix(path, ".age")
+}
+
+// loadHolds reads the holds file, which may not exist yet
+func (a *Action) loadHolds() (*holdList, error) {
+	data, err := os.ReadFile(filepath.Join(a.cfg.StorePath, holdsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return &holdList{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read legal holds: %w", err)
+	}
+
+	var holds holdList
+	if err := yaml.Unmarshal(data, &holds); err != nil {
+		return nil, fmt.Errorf("failed to parse legal holds: %w", err)
+	}
+	return &holds, nil
+}
+
+// saveHolds writes the holds file
+func (a *Action) saveHolds(holds *holdList) error {
+	data, err := yaml.Marshal(holds)
+	if err != nil {
+		return fmt.Errorf("failed to marshal legal holds: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(a.cfg.StorePath, holdsFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to write legal holds: %w", err)
+	}
+	return nil
+}
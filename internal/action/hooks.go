@@ -0,0 +1,113 @@
+package action
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/scan"
+	"passbook/pkg/ui"
+)
+
+// hookMarker identifies pre-commit hooks written by passbook
+const hookMarker = "# Installed by passbook"
+
+// HooksInstall installs a pre-commit hook that blocks plaintext secrets
+func (a *Action) HooksInstall(c *cli.Context) error {
+	repoPath := c.String("repo")
+	force := c.Bool("force")
+
+	if repoPath == "" {
+		if !a.cfg.IsInitialized() {
+			return ErrNotInitialized
+		}
+		repoPath = a.cfg.StorePath
+	}
+
+	// Resolve the hooks directory (respects core.hooksPath and worktrees)
+	cmd := exec.Command("git", "rev-parse", "--git-path", "hooks")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("%s is not a git repository", repoPath)
+	}
+	hooksDir := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(hooksDir) {
+		hooksDir = filepath.Join(repoPath, hooksDir)
+	}
+
+	hookPath := filepath.Join(hooksDir, "pre-commit")
+	if existing, err := os.ReadFile(hookPath); err == nil && !strings.Contains(string(existing), hookMarker) && !force {
+		return fmt.Errorf("a pre-commit hook already exists at %s (use --force to replace it)", hookPath)
+	}
+
+	// Prefer the running binary so the hook works without passbook on PATH
+	// Git for Windows runs hooks with its own sh, which wants forward slashes
+	binary := "passbook"
+	if exe, err := os.Executable(); err == nil {
+		binary = filepath.ToSlash(exe)
+	}
+
+	script := fmt.Sprintf("#!/bin/sh\n%s: blocks plaintext secrets from being committed\nexec '%s' hooks check\n", hookMarker, strings.ReplaceAll(binary, "'", `'\''`))
+
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+	if err := os.WriteFile(hookPath, []byte(script), 0755); err != nil {
+		return fmt.Errorf("failed to write hook: %w", err)
+	}
+
+	ui.Successf("Installed pre-commit hook: %s", hookPath)
+	return nil
+}
+
+// HooksCheck scans staged files for plaintext secrets and fails if any are found
+func (a *Action) HooksCheck(c *cli.Context) error {
+	repoPath := c.String("repo")
+
+	// Get staged files (added, copied, modified, renamed)
+	cmd := exec.Command("git", "diff", "--cached", "--name-only", "--diff-filter=ACMR", "-z")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to list staged files: %w", err)
+	}
+
+	var findings []scan.Finding
+	for _, file := range strings.Split(string(output), "\x00") {
+		if file == "" || strings.HasSuffix(file, age.Ext) {
+			continue
+		}
+
+		// Scan the staged content, not the working tree
+		showCmd := exec.Command("git", "show", ":"+file)
+		showCmd.Dir = repoPath
+		data, err := showCmd.Output()
+		if err != nil {
+			return fmt.Errorf("failed to read staged %s: %w", file, err)
+		}
+
+		// Store metadata holds public keys and IDs that look random
+		opts := scan.Options{SkipEntropy: strings.HasPrefix(filepath.Base(file), ".passbook-")}
+		findings = append(findings, scan.Content(file, data, opts)...)
+	}
+
+	if len(findings) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(os.Stderr, "passbook: potential plaintext secrets in staged files:")
+	for _, f := range findings {
+		fmt.Fprintf(os.Stderr, "  %s:%d  %s  %s\n", f.Path, f.Line, f.Rule, f.Match)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Encrypt these values with passbook instead of committing them.")
+	fmt.Fprintln(os.Stderr, "If this is a false positive, commit with --no-verify.")
+
+	return fmt.Errorf("commit blocked: %d potential secret(s) found", len(findings))
+}
@@ -0,0 +1,67 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/pkg/securetmp"
+)
+
+// forwardsSignals lists commands that relay signals to a child process
+// themselves instead of being cancelled
+var forwardsSignals = map[string]bool{
+	"env exec": true,
+}
+
+// handleInterrupts wraps every command so Ctrl-C cancels c.Context instead
+// of killing passbook mid-write. Long operations check the context and stop
+// at a safe point; a second Ctrl-C exits immediately.
+func handleInterrupts(commands []*cli.Command, parent string) {
+	for _, cmd := range commands {
+		path := strings.TrimSpace(parent + " " + cmd.Name)
+		if len(cmd.Subcommands) > 0 {
+			handleInterrupts(cmd.Subcommands, path)
+		}
+		if cmd.Action == nil || forwardsSignals[path] {
+			continue
+		}
+
+		action := cmd.Action
+		cmd.Action = func(c *cli.Context) error {
+			base := c.Context
+			if base == nil {
+				base = context.Background()
+			}
+			ctx, stop := signal.NotifyContext(base, os.Interrupt, syscall.SIGTERM)
+			finished := make(chan struct{})
+			defer func() {
+				close(finished)
+				stop()
+			}()
+
+			go func() {
+				select {
+				case <-ctx.Done():
+					if base.Err() == nil {
+						// Restore default handling so a second Ctrl-C exits right away
+						stop()
+						fmt.Fprintln(os.Stderr, "\nInterrupted, stopping... (press Ctrl-C again to force)")
+					}
+				case <-finished:
+				}
+			}()
+
+			// Plaintext temp files go even if the command doesn't remove them
+			defer securetmp.Cleanup()
+
+			c.Context = ctx
+			return action(c)
+		}
+	}
+}
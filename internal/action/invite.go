@@ -0,0 +1,308 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/audit"
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/invite"
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/pkg/ui"
+)
+
+// teamInviteLink creates a sealed invite token instead of exchanging keys by hand
+func (a *Action) teamInviteLink(c *cli.Context, email string, roles []models.Role, expires time.Time) error {
+	currentUser, err := a.authorize(rbac.PermTeamInvite)
+	if err != nil {
+		return err
+	}
+
+	userList, err := a.loadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+	for _, u := range userList.Users {
+		if u.Email == email {
+			return fmt.Errorf("user %s %w", email, ErrConflict)
+		}
+	}
+
+	repoURL := c.String("repo-url")
+	if repoURL == "" {
+		repoURL = a.storeRemoteURL()
+	}
+	if repoURL == "" {
+		return fmt.Errorf("store has no remote; pass --repo-url so the invitee can clone it")
+	}
+
+	inv := &invite.Invite{
+		Email:          email,
+		Roles:          roles,
+		RepoURL:        repoURL,
+		Org:            a.cfg.Org.Name,
+		AllowedDomain:  a.cfg.Org.AllowedDomain,
+		AllowedDomains: a.cfg.Org.AllowedDomains,
+		IssuedBy:       currentUser.Email,
+
+		External:        !expires.IsZero(),
+		AccessExpiresAt: expires,
+	}
+
+	crypto, err := a.crypto()
+	if err != nil {
+		return err
+	}
+	token, err := invite.NewManager(a.cfg.StorePath).Create(inv, crypto)
+	if err != nil {
+		return fmt.Errorf("failed to create invite: %w", err)
+	}
+
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Invite team member: %s", email)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	a.logAudit(audit.EventUserInvited, email, "roles", formatRoles(roles), "expires", inv.ExpiresAt.Format(time.RFC3339))
+
+	ui.Successf("Created invite for %s (roles: %s)", email, formatRoles(roles))
+	fmt.Printf("  Expires: %s\n", inv.ExpiresAt.Format("2006-01-02 15:04"))
+	fmt.Println()
+
+	if output := c.String("output"); output != "" {
+		if err := os.WriteFile(output, []byte(token+"\n"), 0600); err != nil {
+			return fmt.Errorf("failed to write invite: %w", err)
+		}
+		fmt.Printf("Invite written to %s\n", output)
+		fmt.Println("Send the file to the invitee over a private channel. They run:")
+		fmt.Printf("  passbook join --invite %s\n", output)
+	} else {
+		fmt.Println("Send this invite to the invitee over a private channel:")
+		fmt.Println()
+		fmt.Println(token)
+		fmt.Println()
+		fmt.Println("They run:")
+		fmt.Println("  passbook join --invite TOKEN")
+	}
+	fmt.Println()
+	fmt.Println("Once they have joined, run:")
+	fmt.Printf("  passbook team accept %s\n", email)
+
+	return nil
+}
+
+// storeRemoteURL returns the configured remote, falling back to origin
+func (a *Action) storeRemoteURL() string {
+	if a.cfg.Git.Remote != "" {
+		return a.cfg.Git.Remote
+	}
+	cmd := exec.Command("git", "remote", "get-url", "origin")
+	cmd.Dir = a.cfg.StorePath
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// Join redeems an invite: clones the store, generates keys and proves
+// control of them in one step
+func (a *Action) Join(c *cli.Context) error {
+	token := c.String("invite")
+	if token == "" {
+		return fmt.Errorf("usage: passbook join --invite TOKEN|FILE")
+	}
+
+	inv, err := invite.DecodeToken(token)
+	if err != nil {
+		return err
+	}
+	if time.Now().After(inv.ExpiresAt) {
+		return fmt.Errorf("%w (expired %s); ask %s for a new one", invite.ErrInviteExpired, inv.ExpiresAt.Format("2006-01-02 15:04"), inv.IssuedBy)
+	}
+
+	fmt.Printf("Joining %s as %s\n", orDefault(inv.Org, "team"), inv.Email)
+	fmt.Printf("Invited by: %s\n", inv.IssuedBy)
+	fmt.Printf("Roles: %s\n", formatRoles(inv.Roles))
+	if inv.External {
+		fmt.Printf("Access as an external collaborator until %s\n", inv.AccessExpiresAt.Format("2006-01-02"))
+	}
+	fmt.Println()
+
+	// Step 1: Clone the store and generate keys
+	if !a.cfg.IsInitialized() {
+		if err := a.cloneWithArgs(c.Context, inv.RepoURL); err != nil {
+			return err
+		}
+		fmt.Println()
+	} else {
+		fmt.Print("Pulling latest changes... ")
+		if err := gitPull(c.Context, a.cfg.StorePath); err != nil {
+			fmt.Println("skipped")
+		} else {
+			fmt.Println(ui.Success("OK"))
+		}
+	}
+
+	if a.cfg.Identity.PublicKey == "" {
+		return fmt.Errorf("no identity found; run 'passbook init' or 'passbook clone' first")
+	}
+
+	// Step 2: Save identity and org settings from the invite
+	a.cfg.Identity.Email = inv.Email
+	if a.cfg.Org.Name == "" {
+		a.cfg.Org.Name = inv.Org
+	}
+	if len(a.cfg.Org.Domains()) == 0 {
+		a.cfg.Org.AllowedDomain, a.cfg.Org.AllowedDomains = inv.AllowedDomain, inv.AllowedDomains
+	}
+	if err := a.cfg.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	// Step 3: Prove control of the new key
+	resp, err := invite.NewManager(a.cfg.StorePath).Respond(inv, a.cfg.Identity.PublicKey)
+	if err != nil {
+		if errors.Is(err, invite.ErrInviteNotFound) {
+			return fmt.Errorf("%w in %s; the invite may have been revoked or already accepted", err, inv.RepoURL)
+		}
+		return fmt.Errorf("failed to respond to invite: %w", err)
+	}
+
+	responseToken, err := invite.EncodeResponse(resp)
+	if err != nil {
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+
+	a.configureGitAuthor()
+	if err := gitCommit(a.cfg.StorePath, fmt.Sprintf("Join via invite: %s", inv.Email)); err != nil {
+		ui.Warningf("commit failed: %v", err)
+	}
+
+	fmt.Print("Pushing response... ")
+	if err := gitPush(c.Context, a.cfg.StorePath); err != nil {
+		fmt.Println(ui.Fail("FAILED"))
+		fmt.Println()
+		fmt.Println("You don't have push access yet. Send this response to the admin instead:")
+		fmt.Println()
+		fmt.Println(responseToken)
+		fmt.Println()
+		fmt.Printf("They run: passbook team accept %s --response TOKEN\n", inv.Email)
+		return nil
+	}
+	fmt.Println(ui.Success("OK"))
+
+	fmt.Println()
+	ui.Successf("Joined. Ask the admin to run:")
+	fmt.Printf("  passbook team accept %s\n", inv.Email)
+	return nil
+}
+
+// TeamAccept adds an invitee after checking their invite response
+func (a *Action) TeamAccept(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook team accept EMAIL [--response TOKEN]")
+	}
+
+	email := c.Args().First()
+
+	// Check permission
+	if _, err := a.authorize(rbac.PermTeamInvite); err != nil {
+		return err
+	}
+
+	mgr := invite.NewManager(a.cfg.StorePath)
+
+	// Use a response sent out of band, or pick up the one the invitee pushed
+	if response := c.String("response"); response != "" {
+		resp, err := invite.DecodeResponse(response)
+		if err != nil {
+			return err
+		}
+		if resp.Email != email {
+			return fmt.Errorf("response is for %s, not %s", resp.Email, email)
+		}
+		if err := mgr.ApplyResponse(resp); err != nil {
+			return err
+		}
+	} else if err := gitPull(c.Context, a.cfg.StorePath); err != nil {
+		ui.Warningf("pull failed, checking local copy only: %v", err)
+	}
+
+	crypto, err := a.crypto()
+	if err != nil {
+		return err
+	}
+
+	record, err := mgr.Verify(email, crypto)
+	if err != nil {
+		return err
+	}
+
+	if !age.ValidatePublicKey(record.PublicKey) {
+		return fmt.Errorf("invalid public key format")
+	}
+
+	userList, err := a.loadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+	for _, u := range userList.Users {
+		if u.Email == email {
+			return fmt.Errorf("user %s %w", email, ErrConflict)
+		}
+	}
+
+	newUser := models.User{
+		ID:        uuid.New().String(),
+		Email:     email,
+		Name:      email,
+		PublicKey: record.PublicKey,
+		CreatedAt: time.Now(),
+		Roles:     record.Roles,
+		External:  record.External,
+		ExpiresAt: record.AccessExpiresAt,
+	}
+	if newUser.IsExpired() {
+		return fmt.Errorf("%s's access as an external collaborator expired on %s; create a new invite", email, newUser.ExpiresAt.Format("2006-01-02"))
+	}
+	userList.Users = append(userList.Users, newUser)
+
+	if err := a.saveUsers(userList); err != nil {
+		return fmt.Errorf("failed to save users: %w", err)
+	}
+
+	if err := a.updateRecipientsFile(userList); err != nil {
+		return fmt.Errorf("failed to update recipients: %w", err)
+	}
+
+	if err := mgr.Remove(email); err != nil {
+		return fmt.Errorf("failed to remove invite: %w", err)
+	}
+
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Accept invite: %s", email)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	a.logAudit(audit.EventUserAdded, email, append([]string{"roles", formatRoles(record.Roles), "method", "invite"}, externalDetails(&newUser)...)...)
+
+	ui.Successf("Added %s to the team with roles: %s", email, formatRoles(record.Roles))
+	fmt.Println()
+	fmt.Println("To give them access to existing secrets, run: passbook reencrypt")
+	return nil
+}
+
+// orDefault returns s, or def if s is empty
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
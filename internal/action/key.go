@@ -2,42 +2,176 @@ package action
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/urfave/cli/v2"
 
 	"passbook/internal/backend/crypto/age"
+	"passbook/internal/backend/crypto/kms"
+	"passbook/internal/config"
+	"passbook/pkg/qr"
+	"passbook/pkg/termio"
+	"passbook/pkg/ui"
 )
 
 // KeyShow shows the user's public key
 func (a *Action) KeyShow(c *cli.Context) error {
-	if a.cfg.Identity.PublicKey != "" {
-		fmt.Printf("Public Key: %s\n", a.cfg.Identity.PublicKey)
-	} else {
-		// Try to read from identity file
-		pubKey, err := age.GetPublicKeyFromFile(a.cfg.IdentityPath())
-		if err != nil {
-			return fmt.Errorf("no identity found: %w", err)
-		}
-		fmt.Printf("Public Key: %s\n", pubKey)
+	pubKey, err := a.ownPublicKey()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Public Key:  %s\n", pubKey)
+	if fp, err := age.Fingerprint(pubKey); err == nil {
+		fmt.Printf("Fingerprint: %s\n", fp)
 	}
 
 	// Check if encrypted
-	encrypted, err := age.IsKeyEncrypted(a.cfg.IdentityPath())
-	if err == nil {
+	if a.cfg.Identity.IsHardware() {
+		fmt.Printf("Status:      On a PKCS#11 token (key %s)\n", a.cfg.Identity.PKCS11.KeyID)
+		fmt.Printf("Module:      %s\n", a.cfg.Identity.PKCS11.Module)
+		return nil
+	}
+	if keyURI, err := age.KMSKeyOf(a.cfg.IdentityPath()); err == nil {
+		fmt.Printf("Status:      Wrapped with %s\n", keyURI)
+	} else if encrypted, err := age.IsKeyEncrypted(a.cfg.IdentityPath()); err == nil {
 		if encrypted {
-			fmt.Println("Status:     Passphrase-protected")
+			fmt.Println("Status:      Passphrase-protected")
 		} else {
-			fmt.Println("Status:     Unencrypted (consider running 'passbook key encrypt')")
+			fmt.Println("Status:      Unencrypted (consider running 'passbook key encrypt')")
 		}
 	}
 
-	fmt.Printf("Key File:   %s\n", a.cfg.IdentityPath())
+	fmt.Printf("Key File:    %s\n", a.cfg.IdentityPath())
+	return nil
+}
+
+// KeyFingerprint shows the short fingerprint of a public key (yours by default)
+func (a *Action) KeyFingerprint(c *cli.Context) error {
+	pubKey := c.Args().First()
+	if pubKey == "" {
+		var err error
+		if pubKey, err = a.ownPublicKey(); err != nil {
+			return err
+		}
+	}
+
+	fp, err := age.Fingerprint(pubKey)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(fp)
+	return nil
+}
+
+// KeyQR displays your public key as a QR code for scanning
+func (a *Action) KeyQR(c *cli.Context) error {
+	pubKey, err := a.ownPublicKey()
+	if err != nil {
+		return err
+	}
+
+	code, err := qr.Encode([]byte(pubKey))
+	if err != nil {
+		return fmt.Errorf("failed to encode QR code: %w", err)
+	}
+
+	fmt.Print(code.Terminal(c.Bool("invert")))
+	fmt.Println()
+	fmt.Printf("Public Key:  %s\n", pubKey)
+	if fp, err := age.Fingerprint(pubKey); err == nil {
+		fmt.Printf("Fingerprint: %s\n", fp)
+	}
+	return nil
+}
+
+// KeyImport installs a private key from a passphrase-sealed bundle made by
+// 'passbook team invite'
+func (a *Action) KeyImport(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook key import BUNDLE")
+	}
+
+	bundle, err := os.ReadFile(c.Args().First())
+	if err != nil {
+		return fmt.Errorf("failed to read key bundle: %w", err)
+	}
+
+	identityPath := a.cfg.IdentityPath()
+	if a.cfg.HasIdentity() && !c.Bool("force") {
+		return fmt.Errorf("an identity already exists at %s (use --force to replace it)", identityPath)
+	}
+
+	passphrase, err := age.PromptPassphrase("Bundle passphrase: ")
+	if err != nil {
+		return err
+	}
+
+	pubKey, err := age.OpenSealedIdentity(bundle, passphrase, identityPath)
+	if err != nil {
+		return err
+	}
+
+	a.cfg.Identity.PublicKey = pubKey
+	a.cfg.Identity.PrivateKeyPath = identityPath
+	if err := a.cfg.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	ui.Successf("Imported key to %s", identityPath)
+	fmt.Printf("  Public key: %s\n", pubKey)
+	fmt.Println()
+	fmt.Println("Delete the bundle file now, and consider protecting the key with: passbook key encrypt")
+	return nil
+}
+
+// ownPublicKey returns the configured public key, falling back to the identity file
+func (a *Action) ownPublicKey() (string, error) {
+	if a.cfg.Identity.PublicKey != "" {
+		return a.cfg.Identity.PublicKey, nil
+	}
+	pubKey, err := age.GetPublicKeyFromFile(a.cfg.IdentityPath())
+	if err != nil {
+		return "", fmt.Errorf("no identity found: %w", err)
+	}
+	return pubKey, nil
+}
+
+// confirmKeyFingerprint checks a pasted or scanned public key against the
+// fingerprint the key owner reads out from 'passbook key fingerprint'
+func (a *Action) confirmKeyFingerprint(c *cli.Context, pubKey string) error {
+	expected, err := age.Fingerprint(pubKey)
+	if err != nil {
+		return err
+	}
+
+	fp := c.String("fingerprint")
+	if fp == "" {
+		fp, err = termio.Prompt("Their fingerprint from 'passbook key fingerprint' (Enter to skip): ")
+		if err != nil {
+			return err
+		}
+	}
+
+	if fp == "" {
+		ui.Warningf("fingerprint not checked; key fingerprint is %s", expected)
+		return nil
+	}
+
+	if !age.MatchFingerprint(pubKey, fp) {
+		return fmt.Errorf("fingerprint mismatch: key has %s, invitee reported %s (key was mistyped or altered)", expected, fp)
+	}
+
+	ui.Successf("Fingerprint matches (%s)", expected)
 	return nil
 }
 
 // KeyEncrypt encrypts the private key with a passphrase
 func (a *Action) KeyEncrypt(c *cli.Context) error {
 	identityPath := a.cfg.IdentityPath()
+	if err := requireUnwrapped(identityPath); err != nil {
+		return err
+	}
 
 	// Check if already encrypted
 	encrypted, err := age.IsKeyEncrypted(identityPath)
@@ -64,7 +198,7 @@ func (a *Action) KeyEncrypt(c *cli.Context) error {
 		return fmt.Errorf("failed to encrypt key: %w", err)
 	}
 
-	fmt.Println("✓ Private key is now passphrase-protected")
+	ui.Successf("Private key is now passphrase-protected")
 	fmt.Println("\nIMPORTANT: Remember your passphrase! If you forget it, you will lose")
 	fmt.Println("access to all encrypted secrets. There is no recovery mechanism.")
 
@@ -74,6 +208,9 @@ func (a *Action) KeyEncrypt(c *cli.Context) error {
 // KeyDecrypt removes passphrase protection from the private key
 func (a *Action) KeyDecrypt(c *cli.Context) error {
 	identityPath := a.cfg.IdentityPath()
+	if err := requireUnwrapped(identityPath); err != nil {
+		return err
+	}
 
 	// Check if encrypted
 	encrypted, err := age.IsKeyEncrypted(identityPath)
@@ -96,7 +233,7 @@ func (a *Action) KeyDecrypt(c *cli.Context) error {
 		return fmt.Errorf("failed to decrypt key: %w", err)
 	}
 
-	fmt.Println("✓ Passphrase protection removed")
+	ui.Successf("Passphrase protection removed")
 	fmt.Println("\nWARNING: Your private key is now stored in plaintext.")
 	fmt.Println("Anyone with access to your filesystem can read it.")
 
@@ -106,6 +243,9 @@ func (a *Action) KeyDecrypt(c *cli.Context) error {
 // KeyChangePassphrase changes the passphrase on an encrypted key
 func (a *Action) KeyChangePassphrase(c *cli.Context) error {
 	identityPath := a.cfg.IdentityPath()
+	if err := requireUnwrapped(identityPath); err != nil {
+		return err
+	}
 
 	// Check if encrypted
 	encrypted, err := age.IsKeyEncrypted(identityPath)
@@ -138,7 +278,108 @@ func (a *Action) KeyChangePassphrase(c *cli.Context) error {
 		return fmt.Errorf("failed to change passphrase: %w", err)
 	}
 
-	fmt.Println("✓ Passphrase changed successfully")
+	ui.Successf("Passphrase changed successfully")
+
+	return nil
+}
+
+// KeyWrap encrypts the private key with a cloud KMS key, so it unlocks with
+// the machine's IAM access instead of a passphrase typed at a prompt
+func (a *Action) KeyWrap(c *cli.Context) error {
+	keyURI := c.Args().First()
+	if keyURI == "" {
+		return fmt.Errorf("usage: passbook key wrap aws-kms://ARN | gcp-kms://projects/.../cryptoKeys/NAME")
+	}
+	if err := kms.Validate(keyURI); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+	identityPath := a.cfg.IdentityPath()
+	if err := requireUnwrapped(identityPath); err != nil {
+		return err
+	}
+	encrypted, err := age.IsKeyEncrypted(identityPath)
+	if err != nil {
+		return fmt.Errorf("failed to check key status: %w", err)
+	}
+
+	if err := age.WrapKeyWithKMS(c.Context, identityPath, keyURI); err != nil {
+		return fmt.Errorf("failed to wrap key: %w", err)
+	}
+
+	ui.Successf("Private key is now wrapped with %s", keyURI)
+	if encrypted {
+		fmt.Println("\nIt keeps its passphrase too, so unlocking needs both.")
+	}
+	fmt.Println("\nIMPORTANT: Anyone who loses access to the KMS key loses access to the")
+	fmt.Println("private key. Keep the KMS key's deletion protection on.")
+	return nil
+}
+
+// KeyUnwrap removes KMS wrapping from the private key
+func (a *Action) KeyUnwrap(c *cli.Context) error {
+	identityPath := a.cfg.IdentityPath()
+	wrapped, err := age.IsKeyKMSWrapped(identityPath)
+	if err != nil {
+		return fmt.Errorf("failed to check key status: %w", err)
+	}
+	if !wrapped {
+		return fmt.Errorf("key is not wrapped with a KMS key")
+	}
+
+	if err := age.UnwrapKMSKey(c.Context, identityPath); err != nil {
+		return fmt.Errorf("failed to unwrap key: %w", err)
+	}
+
+	ui.Successf("KMS wrapping removed")
+	if encrypted, _ := age.IsKeyEncrypted(identityPath); !encrypted {
+		fmt.Println("\nWARNING: Your private key is now stored in plaintext.")
+		fmt.Println("Anyone with access to your filesystem can read it.")
+	}
+	return nil
+}
+
+// KeyHSM switches your identity to an X25519 key on a PKCS#11 token or HSM,
+// which decrypts without the private key ever leaving it
+func (a *Action) KeyHSM(c *cli.Context) error {
+	opts := age.PKCS11Options{
+		Module: c.String("module"),
+		Token:  c.String("token"),
+		KeyID:  c.String("id"),
+	}
+	if opts.Module == "" || opts.KeyID == "" {
+		return fmt.Errorf("usage: passbook key hsm --module PATH --id HEX [--token LABEL]")
+	}
+
+	pubKey, err := age.PKCS11PublicKey(opts)
+	if err != nil {
+		return fmt.Errorf("failed to read the token's public key: %w", err)
+	}
+	previous := a.cfg.Identity.PublicKey
 
+	a.cfg.Identity.Provider = config.IdentityProviderPKCS11
+	a.cfg.Identity.PKCS11 = config.PKCS11Config{Module: opts.Module, Token: opts.Token, KeyID: opts.KeyID}
+	a.cfg.Identity.PublicKey = pubKey
+	if err := a.cfg.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	ui.Successf("Your identity is now key %s on the token", opts.KeyID)
+	fmt.Printf("  Public key: %s\n", pubKey)
+	fmt.Println("\nThe token's PIN is asked for when secrets are decrypted; servers can set")
+	fmt.Println("PASSBOOK_PKCS11_PIN instead. Hardware identities can't sign manifests.")
+	if previous != "" && previous != pubKey {
+		fmt.Println()
+		ui.Warningf("the team knows you by %s; an admin must register the new key and run 'passbook reencrypt' before you can decrypt", previous)
+		fmt.Println("Switch back with: passbook config set identity.provider file")
+	}
+	return nil
+}
+
+// requireUnwrapped refuses to change the passphrase inside a KMS-wrapped
+// key, which would lose the wrapping
+func requireUnwrapped(identityPath string) error {
+	if wrapped, _ := age.IsKeyKMSWrapped(identityPath); wrapped {
+		return fmt.Errorf("%w; run 'passbook key unwrap' first and 'passbook key wrap' after", age.ErrKeyKMSWrapped)
+	}
 	return nil
 }
@@ -0,0 +1,299 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/pkg/ui"
+)
+
+// prodPatterns match the prod env files, live and archived, that the
+// branch-per-environment layout keeps on the prod branch
+var prodPatterns = []string{"projects/*/prod.env.age", "archive/projects/*/prod.env.age"}
+
+// Layout shows where the store keeps each environment
+func (a *Action) Layout(c *cli.Context) error {
+	main := a.cfg.Git.Branch
+	if branch, err := gitCurrentBranch(a.cfg.StorePath); err == nil && branch != "" {
+		main = branch
+	}
+
+	ui.Heading("Store Layout")
+	fmt.Println()
+	if a.cfg.Git.ProdBranch == "" {
+		fmt.Printf("Single branch: every secret lives on %s.\n", main)
+		fmt.Println("\nKeep prod secrets on their own branch with: passbook layout prod-branch BRANCH")
+		return nil
+	}
+
+	table := ui.NewTable("SECRETS", "BRANCH")
+	table.Row("credentials, dev and staging", main)
+	table.Row("prod", a.cfg.Git.ProdBranch)
+	table.Print()
+	fmt.Println("\nProtect the prod branch on your git host so only prod members can push to it.")
+	return nil
+}
+
+// LayoutProdBranch moves the prod env files from the main branch to their
+// own branch, which can be given separate push permissions
+func (a *Action) LayoutProdBranch(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook layout prod-branch BRANCH")
+	}
+	branch := c.Args().First()
+
+	if _, err := a.authorize(rbac.PermStoreConfig); err != nil {
+		return err
+	}
+	if strings.ContainsAny(branch, " ~^:?*[\\") || strings.HasPrefix(branch, proposalBranchPrefix) {
+		return fmt.Errorf("%w: %q is not a valid branch name", ErrInvalidInput, branch)
+	}
+	if current, err := gitCurrentBranch(a.cfg.StorePath); err == nil && current == branch {
+		return fmt.Errorf("%w: %s is the store's main branch", ErrInvalidInput, branch)
+	}
+	if a.cfg.Git.ProdBranch != "" {
+		return fmt.Errorf("prod secrets already live on branch %s", a.cfg.Git.ProdBranch)
+	}
+	if gitBranchExists(a.cfg.StorePath, branch) {
+		return fmt.Errorf("branch %s %w", branch, ErrConflict)
+	}
+
+	a.cfg.Git.ProdBranch = branch
+	if err := a.cfg.SaveStoreConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	if err := ignoreProdFiles(a.cfg.StorePath, true); err != nil {
+		return err
+	}
+
+	// Untrack the prod files on main; they stay in the working tree and the
+	// next commit puts them on the prod branch
+	args := []string{"rm", "-r", "--cached", "--quiet", "--ignore-unmatch", "--"}
+	for _, pattern := range prodPatterns {
+		args = append(args, ":(glob)"+pattern)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = a.cfg.StorePath
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to untrack prod secrets: %s", strings.TrimSpace(string(output)))
+	}
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Move prod secrets to branch %s", branch)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Prod secrets now live on branch %s", branch)
+	fmt.Println("Protect it on your git host so only prod members can push to it.")
+	fmt.Println("Earlier prod secrets remain in the history of the main branch.")
+
+	return nil
+}
+
+// LayoutSingle moves the prod env files back to the main branch
+func (a *Action) LayoutSingle(c *cli.Context) error {
+	if _, err := a.authorize(rbac.PermStoreConfig); err != nil {
+		return err
+	}
+	branch := a.cfg.Git.ProdBranch
+	if branch == "" {
+		fmt.Println("Every secret already lives on one branch.")
+		return nil
+	}
+
+	// Bring the working tree up to date so nothing on the branch is lost
+	if err := a.pullProdBranch(c.Context); err != nil {
+		return err
+	}
+
+	a.cfg.Git.ProdBranch = ""
+	if err := a.cfg.SaveStoreConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	if err := ignoreProdFiles(a.cfg.StorePath, false); err != nil {
+		return err
+	}
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Move prod secrets back from branch %s", branch)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Prod secrets live on the main branch again")
+	fmt.Printf("Branch %s is no longer used; delete it once everyone has synced.\n", branch)
+
+	return nil
+}
+
+// onProdBranch reports whether rel, relative to the store, is kept on the
+// prod branch
+func (a *Action) onProdBranch(rel string) bool {
+	if a.cfg.Git.ProdBranch == "" {
+		return false
+	}
+	for _, pattern := range prodPatterns {
+		if ok, _ := path.Match(pattern, filepath.ToSlash(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// commitProdBranch commits the prod env files in the working tree to the
+// prod branch. It reports whether anything changed.
+func (a *Action) commitProdBranch(message string) (bool, error) {
+	branch := a.cfg.Git.ProdBranch
+	parent := ""
+	if gitBranchExists(a.cfg.StorePath, branch) {
+		parent = branch
+	}
+
+	var files []string
+	for _, pattern := range prodPatterns {
+		matches, _ := filepath.Glob(filepath.Join(a.cfg.StorePath, filepath.FromSlash(pattern)))
+		for _, match := range matches {
+			rel, _ := filepath.Rel(a.cfg.StorePath, match)
+			files = append(files, filepath.ToSlash(rel))
+		}
+	}
+	if parent != "" {
+		// Files deleted from the working tree are deleted on the branch
+		cmd := exec.Command("git", "ls-tree", "-r", "--name-only", parent)
+		cmd.Dir = a.cfg.StorePath
+		output, err := cmd.Output()
+		if err != nil {
+			return false, fmt.Errorf("failed to list branch %s: %w", branch, err)
+		}
+		for _, rel := range strings.Fields(string(output)) {
+			if !slices.Contains(files, rel) {
+				files = append(files, rel)
+			}
+		}
+	}
+
+	changed, err := gitCommitPaths(a.cfg.StorePath, branch, parent, files, message)
+	if err != nil {
+		return false, fmt.Errorf("failed to commit to branch %s: %w", branch, err)
+	}
+	return changed, nil
+}
+
+// pushProdBranch pushes the prod branch, if the layout uses one
+func (a *Action) pushProdBranch(ctx context.Context) error {
+	if a.cfg.Git.ProdBranch == "" || a.storeRemoteURL() == "" {
+		return nil
+	}
+	return gitPushBranch(ctx, a.cfg.StorePath, a.cfg.Git.ProdBranch)
+}
+
+// pullProdBranch fetches the prod branch and writes its files into the
+// working tree. Members without prod access skip it; they couldn't decrypt
+// its files and the git host may not let them fetch it.
+func (a *Action) pullProdBranch(ctx context.Context) error {
+	branch := a.cfg.Git.ProdBranch
+	if branch == "" {
+		return nil
+	}
+	if user, err := a.getCurrentUser(); err != nil || !a.policy().CanAccessStage(user, models.StageProd, false) {
+		return nil
+	}
+
+	if a.storeRemoteURL() != "" {
+		cmd := exec.CommandContext(ctx, "git", "fetch", "origin", fmt.Sprintf("+refs/heads/%[1]s:refs/remotes/origin/%[1]s", branch))
+		cmd.Dir = a.cfg.StorePath
+		if output, err := cmd.CombinedOutput(); err != nil {
+			// A prod branch nobody has pushed yet is fine
+			if !strings.Contains(string(output), "couldn't find remote ref") {
+				return fmt.Errorf("failed to fetch branch %s: %s", branch, strings.TrimSpace(string(output)))
+			}
+		} else if !gitBranchExists(a.cfg.StorePath, branch) || !gitIsAncestor(a.cfg.StorePath, "origin/"+branch, branch) {
+			if err := gitFastForward(a.cfg.StorePath, branch, "origin/"+branch); err != nil {
+				return fmt.Errorf("branch %s has diverged from the remote; reconcile it with git", branch)
+			}
+		}
+	}
+
+	return a.checkoutProdBranch()
+}
+
+// checkoutProdBranch writes the prod branch's files into the working tree
+// without checking the branch out
+func (a *Action) checkoutProdBranch() error {
+	branch := a.cfg.Git.ProdBranch
+	if !gitBranchExists(a.cfg.StorePath, branch) {
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", "passbook-index-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	for _, args := range [][]string{{"read-tree", branch}, {"checkout-index", "--all", "--force"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = a.cfg.StorePath
+		cmd.Env = append(os.Environ(), "GIT_INDEX_FILE="+filepath.Join(dir, "index"))
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to check out branch %s: %s", branch, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// prodIgnoreComment introduces the .gitignore entries that keep prod env
+// files off the main branch
+const prodIgnoreComment = "# Prod secrets live on their own branch"
+
+// ignoreProdFiles adds the prod env files to the store's .gitignore, or with
+// ignore false removes them again
+func ignoreProdFiles(storePath string, ignore bool) error {
+	file := filepath.Join(storePath, ".gitignore")
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read .gitignore: %w", err)
+	}
+
+	entries := make([]string, len(prodPatterns))
+	for i, pattern := range prodPatterns {
+		entries[i] = "/" + pattern
+	}
+
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if line != prodIgnoreComment && !slices.Contains(entries, line) {
+			lines = append(lines, line)
+		}
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if ignore {
+		lines = append(append(lines, "", prodIgnoreComment), entries...)
+	}
+
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write .gitignore: %w", err)
+	}
+	return nil
+}
+
+// gitIsAncestor reports whether commit is part of ref's history
+func gitIsAncestor(path, commit, ref string) bool {
+	return exec.Command("git", "-C", path, "merge-base", "--is-ancestor", commit, ref).Run() == nil
+}
+
+// gitBranchExists reports whether a local branch exists
+func gitBranchExists(path, branch string) bool {
+	return exec.Command("git", "-C", path, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch).Run() == nil
+}
@@ -0,0 +1,250 @@
+package action
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/fingerprint"
+	"passbook/internal/manifest"
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/internal/reencrypt"
+	"passbook/internal/store"
+	"passbook/pkg/ui"
+)
+
+// signingKeyMetadata is the user metadata key holding a member's public
+// signing key
+const signingKeyMetadata = "signing_key"
+
+// Verify checks the store against its signed manifest, reporting files that
+// changed without an admin re-signing, such as edits made directly in git.
+// With --update an admin re-signs the manifest for the current store.
+func (a *Action) Verify(c *cli.Context) error {
+	if c.Bool("update") {
+		// verify is a read command, so guard the write here
+		if err := a.requireWritable(c, false); err != nil {
+			return err
+		}
+		currentUser, err := a.authorize(rbac.PermStoreConfig)
+		if err != nil {
+			return err
+		}
+		if err := a.updateManifest(currentUser); err != nil {
+			return err
+		}
+		if err := a.GitCommitAndSync(c.Context, "Update manifest"); err != nil {
+			ui.Warningf("%v", err)
+		}
+		ui.Successf("Signed manifest for the current store")
+		return nil
+	}
+
+	m, err := manifest.Load(a.cfg.StorePath)
+	if errors.Is(err, manifest.ErrNoManifest) {
+		return fmt.Errorf("store has no manifest; an admin can create one with 'passbook verify --update'")
+	}
+	if err != nil {
+		return err
+	}
+
+	userList, err := a.loadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+
+	ui.Heading("Store Integrity")
+	fmt.Println()
+
+	problems := 0
+
+	// The signature must be valid and belong to a current admin
+	if err := m.VerifySignature(); err != nil {
+		fmt.Printf("%s Signature: %v\n", ui.Fail("✗"), err)
+		problems++
+	} else if signer := signerOf(userList.Users, m.SignerKey); signer == nil {
+		fmt.Printf("%s Signature: valid, but the key isn't any member's signing key\n", ui.Fail("✗"))
+		problems++
+	} else if !signer.IsAdmin() {
+		fmt.Printf("%s Signature: by %s, who is no longer an admin\n", ui.Fail("✗"), signer.Email)
+		problems++
+	} else {
+		fmt.Printf("%s Signature: by %s at %s\n", ui.Success("✓"), signer.Email, m.UpdatedAt.Local().Format("2006-01-02 15:04"))
+	}
+
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+	current, err := manifest.Build(a.cfg.StorePath, manifestRecipients(s, m))
+	if err != nil {
+		return err
+	}
+
+	changes := m.Compare(current)
+	if len(changes) == 0 {
+		fmt.Printf("%s Files:     %d match the manifest\n", ui.Success("✓"), len(m.Files))
+	} else {
+		fmt.Printf("%s Files:     %d of %d differ from the manifest\n", ui.Fail("✗"), len(changes), len(m.Files))
+		fmt.Println()
+		table := ui.NewTable("FILE", "CHANGE")
+		for _, change := range changes {
+			table.Row(change.Path, describeChange(change.Kind))
+		}
+		table.Print()
+		problems += len(changes)
+	}
+
+	if problems > 0 {
+		fmt.Println()
+		fmt.Println("Changes made by non-admins through the CLI also show here until an admin")
+		fmt.Println("re-signs. Review them in 'git log', then run 'passbook verify --update'.")
+		return fmt.Errorf("store doesn't match its manifest (%d problem(s))", problems)
+	}
+
+	return nil
+}
+
+// updateManifest re-signs the manifest for the current store as user, who
+// must be an admin. Their public signing key is recorded on their user
+// entry so others can check the signature. A manifest that already matches
+// the store under a current admin's signature is left alone.
+func (a *Action) updateManifest(user *models.User) error {
+	userList, err := a.loadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+	// Without a manifest there are no signed recipients to fall back on
+	old, _ := manifest.Load(a.cfg.StorePath)
+	m, err := manifest.Build(a.cfg.StorePath, manifestRecipients(s, old))
+	if err != nil {
+		return err
+	}
+	if old != nil && len(old.Compare(m)) == 0 && old.VerifySignature() == nil {
+		if signer := signerOf(userList.Users, old.SignerKey); signer != nil && signer.IsAdmin() {
+			return nil
+		}
+	}
+
+	crypto, err := a.crypto()
+	if err != nil {
+		return err
+	}
+	key, err := crypto.SigningKey()
+	if err != nil {
+		return err
+	}
+	publicKey := age.EncodeSigningKey(key.Public().(ed25519.PublicKey))
+
+	recorded, err := a.recordSigningKey(userList, user.Email, publicKey)
+	if err != nil {
+		return err
+	}
+	if recorded {
+		// The users file changed, so hash it again
+		if m, err = manifest.Build(a.cfg.StorePath, manifestRecipients(s, old)); err != nil {
+			return err
+		}
+	}
+
+	if err := m.Sign(user.Email, key); err != nil {
+		return err
+	}
+	return m.Save(a.cfg.StorePath)
+}
+
+// recordSigningKey records publicKey as the signing key of the member with
+// email, saving the users file if it changed. It reports whether it did.
+func (a *Action) recordSigningKey(userList *models.UserList, email, publicKey string) (bool, error) {
+	for i := range userList.Users {
+		u := &userList.Users[i]
+		if u.Email != email || u.Metadata[signingKeyMetadata] == publicKey {
+			continue
+		}
+		if u.Metadata == nil {
+			u.Metadata = make(map[string]string)
+		}
+		u.Metadata[signingKeyMetadata] = publicKey
+		if err := a.saveUsers(userList); err != nil {
+			return false, fmt.Errorf("failed to record signing key: %w", err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// refreshManifest re-signs the manifest before an admin's commit, so the
+// snapshot follows every admin operation. Other members' changes show up in
+// verify until an admin next commits.
+func (a *Action) refreshManifest() {
+	user, err := a.getCurrentUser()
+	if err != nil || !user.IsAdmin() {
+		return
+	}
+	if err := a.updateManifest(user); err != nil {
+		ui.Warningf("manifest not updated: %v", err)
+	}
+}
+
+// manifestRecipients returns the keys each secret should be encrypted for,
+// worked out as the store does when it writes the secret, so per-secret
+// permissions and the default access policy count. A secret the current
+// member can't decrypt keeps the recipients signed for it while it is
+// unchanged, since its permissions can't be read.
+func manifestRecipients(s *store.Store, signed *manifest.Manifest) func(path string, data []byte) []string {
+	return func(path string, data []byte) []string {
+		top, _, _ := strings.Cut(path, "/")
+		if !strings.HasSuffix(path, age.Ext) || !slices.Contains(reencrypt.SecretDirs, top) {
+			return nil
+		}
+
+		keys, err := s.SecretRecipients(path, func() ([]byte, error) {
+			return s.Crypto().Decrypt(context.Background(), data)
+		})
+		if err == nil {
+			return keys
+		}
+		if signed != nil {
+			if entry, ok := signed.Files[path]; ok && entry.Hash == fingerprint.Hash(data) {
+				return signed.RecipientsOf(path)
+			}
+		}
+		return nil
+	}
+}
+
+// signerOf finds the member whose signing key is key
+func signerOf(users []models.User, key string) *models.User {
+	for i := range users {
+		if key != "" && users[i].Metadata[signingKeyMetadata] == key {
+			return &users[i]
+		}
+	}
+	return nil
+}
+
+// describeChange explains a manifest change kind
+func describeChange(kind string) string {
+	switch kind {
+	case "modified":
+		return ui.Warn("modified")
+	case "missing":
+		return ui.Fail("deleted")
+	case "added":
+		return ui.Warn("added")
+	case "recipients":
+		return ui.Warn("intended recipients changed")
+	}
+	return kind
+}
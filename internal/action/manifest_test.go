@@ -0,0 +1,94 @@
+package action
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/backend/storage/gitfs"
+	"passbook/internal/config"
+	"passbook/internal/manifest"
+	"passbook/internal/models"
+	"passbook/internal/store"
+)
+
+// openTestStore opens dir as a member with a fresh identity, returning the
+// store and the member's public key
+func openTestStore(t *testing.T, dir, email string) (*store.Store, string) {
+	t.Helper()
+	keyPath := filepath.Join(t.TempDir(), "identity")
+	publicKey, err := age.GenerateIdentity(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{StorePath: dir}
+	cfg.Identity.Email = email
+	cfg.Identity.PrivateKeyPath = keyPath
+	cfg.Identity.PublicKey = publicKey
+	s, err := store.New(cfg)
+	if err != nil {
+		t.Fatalf("open store as %s: %v", email, err)
+	}
+	return s, publicKey
+}
+
+func TestManifestRecipientsFollowPerSecretPermissions(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "store")
+	if _, err := gitfs.Init(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	admin, adminKey := openTestStore(t, dir, "admin@example.com")
+	dev, devKey := openTestStore(t, dir, "dev@example.com")
+	now := time.Now()
+	users := []models.User{
+		{ID: "admin@example.com", Email: "admin@example.com", PublicKey: adminKey, Roles: []models.Role{models.RoleAdmin}, CreatedAt: now},
+		{ID: "dev@example.com", Email: "dev@example.com", PublicKey: devKey, Roles: []models.Role{models.RoleDev}, CreatedAt: now},
+	}
+	if err := admin.SaveUsers(ctx, users); err != nil {
+		t.Fatal(err)
+	}
+
+	perms := models.NewSecretPermissions()
+	perms.AddRecipient("admin@example.com", adminKey, models.AccessWrite)
+	restricted := &models.Credential{Website: "example.com", Name: "root", Password: "s3cret", Permissions: perms}
+	shared := &models.Credential{Website: "example.com", Name: "shared", Password: "s3cret"}
+	for _, cred := range []*models.Credential{restricted, shared} {
+		if err := admin.SaveCredential(ctx, cred); err != nil {
+			t.Fatalf("SaveCredential: %v", err)
+		}
+	}
+
+	signed, err := manifest.Build(dir, manifestRecipients(admin, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"credentials/example.com/root.age":   {adminKey},
+		"credentials/example.com/shared.age": sorted(adminKey, devKey),
+	}
+	for path, keys := range want {
+		if got := signed.RecipientsOf(path); !slices.Equal(got, keys) {
+			t.Errorf("%s recipients = %v, want %v", path, got, keys)
+		}
+	}
+
+	// A member who can't decrypt a secret checks it against the signed
+	// recipients, so verify finds nothing wrong
+	current, err := manifest.Build(dir, manifestRecipients(dev, signed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := signed.Compare(current); len(changes) != 0 {
+		t.Errorf("changes for a member who can't read everything: %v", changes)
+	}
+}
+
+// sorted returns keys sorted, as a manifest records them
+func sorted(keys ...string) []string {
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,212 @@
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/pkg/ui"
+)
+
+// metricsFile records, in the user's config directory, how often each
+// command runs and how long it takes. It's only written when
+// preferences.metrics is on, and never leaves the machine.
+const metricsFile = "metrics.json"
+
+// commandMetrics is what's recorded about one command
+type commandMetrics struct {
+	Runs     int           `json:"runs"`
+	Failures int           `json:"failures"`
+	Total    time.Duration `json:"total_ns"`
+	Max      time.Duration `json:"max_ns"`
+	LastRun  time.Time     `json:"last_run"`
+}
+
+// metrics is the contents of metricsFile
+type metrics struct {
+	Since    time.Time                  `json:"since"`
+	Commands map[string]*commandMetrics `json:"commands"`
+}
+
+// recordMetrics wraps every command so its run is counted and timed when
+// metrics are enabled. It wraps the command alone, so waiting on a step-up
+// prompt or a hook isn't counted against it.
+func (a *Action) recordMetrics(commands []*cli.Command, parent string) {
+	for _, cmd := range commands {
+		path := strings.TrimSpace(parent + " " + cmd.Name)
+		if len(cmd.Subcommands) > 0 {
+			a.recordMetrics(cmd.Subcommands, path)
+		}
+		// clipboard-clear runs in the background after every copy, and
+		// counting metrics commands would undo metrics reset
+		if cmd.Action == nil || cmd.Hidden || strings.HasPrefix(path, "metrics") {
+			continue
+		}
+
+		action := cmd.Action
+		cmd.Action = func(c *cli.Context) error {
+			if !a.cfg.Preferences.Metrics {
+				return action(c)
+			}
+			start := time.Now()
+			err := action(c)
+			a.saveMetric(path, time.Since(start), err)
+			return err
+		}
+	}
+}
+
+// saveMetric adds one run to the metrics file. Metrics are best effort, so
+// failing to record them never fails the command.
+func (a *Action) saveMetric(command string, took time.Duration, runErr error) {
+	m, err := a.loadMetrics()
+	if err != nil {
+		return
+	}
+
+	cm := m.Commands[command]
+	if cm == nil {
+		cm = &commandMetrics{}
+		m.Commands[command] = cm
+	}
+	cm.Runs++
+	if runErr != nil {
+		cm.Failures++
+	}
+	cm.Total += took
+	if took > cm.Max {
+		cm.Max = took
+	}
+	cm.LastRun = time.Now().UTC()
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return
+	}
+	path := filepath.Join(a.cfg.ConfigDir, metricsFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+	}
+}
+
+// loadMetrics reads the metrics file, starting a new one if there's none
+func (a *Action) loadMetrics() (*metrics, error) {
+	m := &metrics{Since: time.Now().UTC(), Commands: make(map[string]*commandMetrics)}
+	data, err := os.ReadFile(filepath.Join(a.cfg.ConfigDir, metricsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics: %w", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse metrics: %w", err)
+	}
+	if m.Commands == nil {
+		m.Commands = make(map[string]*commandMetrics)
+	}
+	return m, nil
+}
+
+// MetricsShow prints how often each command ran and how long it took
+func (a *Action) MetricsShow(c *cli.Context) error {
+	m, err := a.loadMetrics()
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		data, err := json.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal metrics: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(m.Commands) == 0 {
+		fmt.Println("No metrics recorded.")
+		if !a.cfg.Preferences.Metrics {
+			fmt.Println("\nMetrics are off. Turn them on with: passbook config set preferences.metrics true")
+		}
+		return nil
+	}
+
+	names := make([]string, 0, len(m.Commands))
+	for name := range m.Commands {
+		names = append(names, name)
+	}
+	average := func(cm *commandMetrics) time.Duration { return cm.Total / time.Duration(cm.Runs) }
+	var less func(x, y *commandMetrics) bool
+	switch sortBy := c.String("sort"); sortBy {
+	case "total":
+		less = func(x, y *commandMetrics) bool { return x.Total > y.Total }
+	case "runs":
+		less = func(x, y *commandMetrics) bool { return x.Runs > y.Runs }
+	case "avg":
+		less = func(x, y *commandMetrics) bool { return average(x) > average(y) }
+	case "max":
+		less = func(x, y *commandMetrics) bool { return x.Max > y.Max }
+	default:
+		return fmt.Errorf("%w: unknown sort %q (valid: total, runs, avg, max)", ErrInvalidInput, sortBy)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		x, y := m.Commands[names[i]], m.Commands[names[j]]
+		if less(x, y) != less(y, x) {
+			return less(x, y)
+		}
+		return names[i] < names[j]
+	})
+
+	ui.Heading("Command Metrics")
+	fmt.Printf("Since %s\n\n", m.Since.Local().Format("2006-01-02 15:04"))
+	table := ui.NewTable("COMMAND", "RUNS", "FAILED", "AVG", "MAX", "TOTAL", "LAST RUN")
+	for _, name := range names {
+		cm := m.Commands[name]
+		failed := "0"
+		if cm.Failures > 0 {
+			failed = ui.Warn(fmt.Sprint(cm.Failures))
+		}
+		table.Row(name, fmt.Sprint(cm.Runs), failed, roundDuration(average(cm)), roundDuration(cm.Max), roundDuration(cm.Total), cm.LastRun.Local().Format("2006-01-02 15:04"))
+	}
+	table.Print()
+
+	if !a.cfg.Preferences.Metrics {
+		fmt.Println("\nMetrics are off, so these aren't being updated.")
+	}
+	return nil
+}
+
+// MetricsReset deletes the recorded metrics
+func (a *Action) MetricsReset(c *cli.Context) error {
+	if err := os.Remove(filepath.Join(a.cfg.ConfigDir, metricsFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to reset metrics: %w", err)
+	}
+	ui.Successf("Metrics reset")
+	return nil
+}
+
+// roundDuration formats a duration to a precision worth reading
+func roundDuration(d time.Duration) string {
+	switch {
+	case d >= time.Minute:
+		return d.Round(time.Second).String()
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(time.Millisecond).String()
+	default:
+		return "<1ms"
+	}
+}
@@ -0,0 +1,272 @@
+package action
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/audit"
+	"passbook/internal/config"
+	"passbook/internal/rbac"
+	"passbook/internal/store"
+	"passbook/pkg/termio"
+	"passbook/pkg/ui"
+)
+
+// pendingKeysDir is where old versions of 'team invite' wrote generated private keys
+const pendingKeysDir = ".pending-keys"
+
+// Migrate upgrades the store to the current format: it encrypts the users
+// file, rewrites credentials and env files written before versioning, and
+// records the new store version. Stores newer than this version are refused
+// when the config loads.
+func (a *Action) Migrate(c *cli.Context) error {
+	if _, err := a.authorize(rbac.PermStoreReencrypt); err != nil {
+		return err
+	}
+
+	from := a.cfg.StoreVersion
+	if from == config.StoreVersion {
+		ui.Successf("The store is already at format v%d", from)
+		return nil
+	}
+
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+
+	// Step 1: Users file
+	fmt.Print("Encrypting users file... ")
+	usersMigrated, err := s.MigrateUsers(c.Context)
+	if err != nil {
+		fmt.Println(ui.Fail("FAILED"))
+		return fmt.Errorf("failed to migrate users: %w", err)
+	}
+	if usersMigrated {
+		fmt.Println(ui.Success("OK"))
+	} else {
+		fmt.Println(ui.Muted("already encrypted"))
+	}
+
+	// Step 2: Secrets, leaving files under legal hold alone
+	fmt.Print("Rewriting secrets... ")
+	result, err := s.MigrateSecrets(c.Context, func(path string) bool {
+		return a.checkHold(path, "migrate") != nil
+	})
+	if err != nil {
+		fmt.Println(ui.Fail("FAILED"))
+		return err
+	}
+	fmt.Println(ui.Success(fmt.Sprintf("%d rewritten", len(result.Migrated))))
+
+	// Step 3: Record the version once nothing is left behind
+	done := len(result.Skipped) == 0 && len(result.Failed) == 0
+	if done {
+		a.cfg.StoreVersion = config.StoreVersion
+		if err := a.cfg.SaveStoreConfig(); err != nil {
+			return fmt.Errorf("failed to save store config: %w", err)
+		}
+	}
+
+	if usersMigrated || len(result.Migrated) > 0 || done {
+		a.logAudit(audit.EventStoreMigrated, a.cfg.StorePath,
+			"from", fmt.Sprintf("%d", from),
+			"to", fmt.Sprintf("%d", a.cfg.StoreVersion),
+			"secrets", fmt.Sprintf("%d", len(result.Migrated)))
+
+		// Git commit
+		msg := fmt.Sprintf("Migrate store from format v%d to v%d", from, a.cfg.StoreVersion)
+		if !done {
+			msg = fmt.Sprintf("Partially migrate store from format v%d", from)
+		}
+		if err := a.GitCommitAndSync(c.Context, msg); err != nil {
+			ui.Warningf("%v", err)
+		}
+	}
+
+	if len(result.Skipped) > 0 {
+		ui.Warningf("%d file(s) under legal hold were not migrated:", len(result.Skipped))
+		for _, file := range result.Skipped {
+			fmt.Printf("  - %s\n", file)
+		}
+	}
+	if len(result.Failed) > 0 {
+		ui.Warningf("%d file(s) could not be migrated:", len(result.Failed))
+		for _, file := range result.Failed {
+			fmt.Printf("  - %s\n", file)
+		}
+	}
+	if !done {
+		fmt.Println("The store stays at its old format; fix the files above and run 'passbook migrate' again.")
+		return nil
+	}
+
+	fmt.Println()
+	ui.Successf("Migrated the store from format v%d to v%d", from, config.StoreVersion)
+	if usersMigrated {
+		fmt.Println("Everyone must upgrade passbook; older versions can't read the new users file.")
+	}
+	return nil
+}
+
+// MigratePurgePendingKeys removes private keys written to .pending-keys/ by
+// older versions from the working tree and from all of git history
+func (a *Action) MigratePurgePendingKeys(c *cli.Context) error {
+	if _, err := a.authorize(rbac.PermStoreReencrypt); err != nil {
+		return err
+	}
+
+	storePath := a.cfg.StorePath
+
+	if _, err := exec.LookPath("git-filter-repo"); err != nil {
+		fmt.Println("NOTICE: git-filter-repo is not installed.")
+		fmt.Println()
+		fmt.Println("Install it with:")
+		fmt.Println("  brew install git-filter-repo   # macOS")
+		fmt.Println("  pip install git-filter-repo    # pip")
+		return nil
+	}
+
+	// Step 1: Find every key that was ever committed
+	exposed, err := gitPathsInHistory(storePath, pendingKeysDir)
+	if err != nil {
+		return fmt.Errorf("failed to search history: %w", err)
+	}
+	if len(exposed) == 0 {
+		ui.Successf("No pending keys found in history")
+		return a.ignorePendingKeys()
+	}
+
+	ui.Heading("Pending Keys in History")
+	fmt.Println()
+	for _, p := range exposed {
+		fmt.Printf("  %s\n", p)
+	}
+	fmt.Println()
+	fmt.Println("These private keys were readable by everyone with access to the repository.")
+	fmt.Println("This rewrites all branches; every clone must be re-cloned afterwards.")
+	fmt.Println()
+
+	if !c.Bool("force") {
+		confirmed, err := termio.Confirm("Purge them from history?", false)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Cancelled")
+			return nil
+		}
+	}
+
+	// Step 2: Remove from the working tree and stop tracking new ones
+	if err := os.RemoveAll(filepath.Join(storePath, pendingKeysDir)); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", pendingKeysDir, err)
+	}
+	if err := a.ignorePendingKeys(); err != nil {
+		return err
+	}
+
+	// Step 3: Rewrite history
+	if err := a.runFilterRepo(c.Context, "purged pending private keys", "--invert-paths", "--path", pendingKeysDir); err != nil {
+		return err
+	}
+
+	a.logAudit(audit.EventKeysPurged, pendingKeysDir, "keys", fmt.Sprintf("%d", len(exposed)))
+
+	ui.Successf("Purged %d key(s) from history", len(exposed))
+	printRewriteSteps()
+	fmt.Println("The affected members must generate new keys; re-invite them and run: passbook reencrypt")
+	return nil
+}
+
+// MigrateUsers encrypts the plaintext users file of older stores so the
+// team's emails, roles and keys are only readable by members
+func (a *Action) MigrateUsers(c *cli.Context) error {
+	if _, err := a.authorize(rbac.PermStoreConfig); err != nil {
+		return err
+	}
+
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+	migrated, err := s.MigrateUsers(c.Context)
+	if err != nil {
+		return fmt.Errorf("failed to migrate users: %w", err)
+	}
+	if !migrated {
+		ui.Successf("The users file is already encrypted")
+		return nil
+	}
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, "Encrypt users file"); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Encrypted the users file as %s", store.UsersFile)
+	fmt.Println()
+	fmt.Println("Everyone must upgrade passbook; older versions can't read the new file.")
+	fmt.Printf("Earlier commits still hold the plaintext %s in history.\n", store.LegacyUsersFile)
+	return nil
+}
+
+// ignorePendingKeys adds the pending keys directory to the store's .gitignore
+func (a *Action) ignorePendingKeys() error {
+	path := filepath.Join(a.cfg.StorePath, ".gitignore")
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read .gitignore: %w", err)
+	}
+
+	entry := pendingKeysDir + "/"
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == entry {
+			return nil
+		}
+	}
+
+	content := string(data)
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += "# Never commit private keys\n" + entry + "\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to write .gitignore: %w", err)
+	}
+
+	// Commit locally only; the history rewrite is pushed by hand
+	a.configureGitAuthor()
+	if err := gitCommit(a.cfg.StorePath, "Ignore pending private keys"); err != nil {
+		ui.Warningf("commit failed: %v", err)
+	}
+	return nil
+}
+
+// gitPathsInHistory lists every file under dir that appears in any local branch or tag
+func gitPathsInHistory(repoPath, dir string) ([]string, error) {
+	cmd := exec.Command("git", "log", "--branches", "--tags", "--name-only", "--format=", "--", dir)
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var paths []string
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !seen[line] {
+			seen[line] = true
+			paths = append(paths, line)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
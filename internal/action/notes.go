@@ -0,0 +1,258 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/audit"
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/internal/store"
+	"passbook/pkg/editor"
+	"passbook/pkg/termio"
+	"passbook/pkg/ui"
+)
+
+// NoteList lists the notes
+func (a *Action) NoteList(c *cli.Context) error {
+	if _, err := a.authorize(rbac.PermCredentialsRead); err != nil {
+		return err
+	}
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+
+	notes, err := s.ListNotes(c.Context)
+	if err != nil {
+		return fmt.Errorf("failed to list notes: %w", err)
+	}
+	if len(notes) == 0 {
+		fmt.Println("No notes found.")
+		fmt.Println("\nAdd one with: passbook note add NAME")
+		return nil
+	}
+
+	ui.Heading("Notes")
+	fmt.Println()
+	table := ui.NewTable("NAME", "TAGS", "LINES", "UPDATED")
+	for _, n := range notes {
+		table.Row(n.Name, strings.Join(n.Tags, ", "), fmt.Sprintf("%d", n.Lines), n.UpdatedAt.Format("2006-01-02"))
+	}
+	table.Print()
+	fmt.Printf("\nTotal: %d note(s)\n", len(notes))
+	return nil
+}
+
+// NoteShow prints a note
+func (a *Action) NoteShow(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook note show NAME")
+	}
+	if _, err := a.authorize(rbac.PermCredentialsRead); err != nil {
+		return err
+	}
+
+	note, err := a.loadNote(c, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("raw") {
+		fmt.Print(note.Body)
+		return nil
+	}
+
+	ui.Heading("Note: " + note.Name)
+	if len(note.Tags) > 0 {
+		fmt.Println(ui.Muted("Tags: " + strings.Join(note.Tags, ", ")))
+	}
+	fmt.Println()
+	fmt.Println(strings.TrimRight(note.Body, "\n"))
+	return nil
+}
+
+// NoteAdd creates a note from --file, stdin or the editor
+func (a *Action) NoteAdd(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook note add NAME [--file PATH]")
+	}
+	name := c.Args().First()
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\") {
+		return fmt.Errorf("%w: invalid note name %q", ErrInvalidInput, name)
+	}
+
+	currentUser, err := a.authorize(rbac.PermCredentialsWrite)
+	if err != nil {
+		return err
+	}
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+
+	body, err := a.noteBody(c, fmt.Sprintf("# %s\n\n", name))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(body) == "" {
+		return fmt.Errorf("%w: note is empty", ErrInvalidInput)
+	}
+
+	note := &models.Note{Name: name, Body: body, Tags: c.StringSlice("tag")}
+	err = s.CreateNote(c.Context, note, currentUser.Email)
+	if errors.Is(err, store.ErrAlreadyExists) {
+		return fmt.Errorf("note %s %w", name, ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save note: %w", err)
+	}
+	a.trackLargeFile(note.FullPath())
+
+	a.logAudit(audit.EventNoteCreated, name)
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Add note: %s", name)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Added note: %s", name)
+	return nil
+}
+
+// NoteEdit changes a note in the editor, or replaces it from --file
+func (a *Action) NoteEdit(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook note edit NAME [--file PATH]")
+	}
+
+	currentUser, err := a.authorize(rbac.PermCredentialsWrite)
+	if err != nil {
+		return err
+	}
+	note, err := a.loadNote(c, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	body, err := a.noteBody(c, note.Body)
+	if err != nil {
+		return err
+	}
+	tags := c.StringSlice("tag")
+	if body == note.Body && len(tags) == 0 {
+		fmt.Println("No changes")
+		return nil
+	}
+	if strings.TrimSpace(body) == "" {
+		return fmt.Errorf("%w: note is empty (use 'passbook note rm' to delete it)", ErrInvalidInput)
+	}
+
+	note.Body = body
+	if len(tags) > 0 {
+		note.Tags = tags
+	}
+	note.UpdatedBy = currentUser.Email
+	note.UpdatedAt = time.Now()
+
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+	if err := s.SaveNote(c.Context, note); err != nil {
+		return fmt.Errorf("failed to save note: %w", err)
+	}
+	a.trackLargeFile(note.FullPath())
+
+	a.logAudit(audit.EventNoteUpdated, note.Name)
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Update note: %s", note.Name)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Updated note: %s", note.Name)
+	return nil
+}
+
+// NoteRemove deletes a note
+func (a *Action) NoteRemove(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook note rm NAME")
+	}
+	name := c.Args().First()
+
+	if _, err := a.authorize(rbac.PermCredentialsWrite); err != nil {
+		return err
+	}
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+
+	err = s.DeleteNote(c.Context, name)
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("note %s %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete note: %w", err)
+	}
+
+	a.logAudit(audit.EventNoteDeleted, name)
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Delete note: %s", name)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Deleted note: %s", name)
+	return nil
+}
+
+// noteBody reads a note's content from --file ("-" for stdin), from piped
+// stdin, or by opening current in the editor
+func (a *Action) noteBody(c *cli.Context, current string) (string, error) {
+	file := c.String("file")
+	switch {
+	case file == "-" || (file == "" && !termio.IsTerminal()):
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read stdin: %w", err)
+		}
+		return string(data), nil
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		return string(data), nil
+	}
+
+	edited, err := editor.Edit(a.cfg.Preferences.Editor, []byte(current), ".md")
+	if err != nil {
+		return "", err
+	}
+	return string(edited), nil
+}
+
+// loadNote decrypts a note by name
+func (a *Action) loadNote(c *cli.Context, name string) (*models.Note, error) {
+	s, err := a.openStore()
+	if err != nil {
+		return nil, err
+	}
+	note, err := s.GetNote(c.Context, name)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("note %s %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load note: %w", err)
+	}
+	return note, nil
+}
@@ -0,0 +1,219 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/models"
+	"passbook/internal/store"
+	"passbook/pkg/termio"
+	"passbook/pkg/ui"
+)
+
+// PersonalList lists the current user's personal credentials
+func (a *Action) PersonalList(c *cli.Context) error {
+	user, err := a.getCurrentUser()
+	if err != nil {
+		return err
+	}
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+
+	creds, err := s.ListPersonalCredentials(c.Context, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list personal credentials: %w", err)
+	}
+	if len(creds) == 0 {
+		fmt.Println("No personal credentials found.")
+		fmt.Println("\nAdd one with: passbook personal add example.com")
+		return nil
+	}
+
+	ui.Heading("Personal Credentials")
+	fmt.Println()
+	table := ui.NewTable("CREDENTIAL", "USERNAME", "UPDATED")
+	for _, cred := range creds {
+		table.Row(cred.Website+"/"+cred.Name, cred.Username, cred.UpdatedAt.Format("2006-01-02"))
+	}
+	table.Print()
+	fmt.Printf("\nTotal: %d credential(s), encrypted only for you\n", len(creds))
+	return nil
+}
+
+// PersonalShow shows one of the current user's personal credentials
+func (a *Action) PersonalShow(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook personal show WEBSITE/NAME")
+	}
+	website, name, err := parseCredentialPath(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	cred, err := a.loadPersonalCredential(c, website, name)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case c.Bool("clip"):
+		if err := a.copyToClipboard(cred.Password); err != nil {
+			return err
+		}
+		fmt.Printf("Password copied to clipboard (clears in %d seconds)\n", a.cfg.Preferences.ClipboardTimeout)
+		return nil
+	case c.Bool("password"):
+		fmt.Println(cred.Password)
+		return nil
+	}
+
+	ui.Heading(fmt.Sprintf("Personal credential: %s/%s", website, name))
+	fmt.Printf("Username: %s\n", cred.Username)
+	fmt.Printf("Password: %s\n", cred.Password)
+	if cred.URL != "" {
+		fmt.Printf("URL:      %s\n", cred.URL)
+	}
+	if cred.Notes != "" {
+		fmt.Printf("Notes:    %s\n", cred.Notes)
+	}
+	fmt.Printf("Created:  %s\n", cred.CreatedAt.Format("2006-01-02 15:04"))
+	fmt.Printf("Updated:  %s\n", cred.UpdatedAt.Format("2006-01-02 15:04"))
+	return nil
+}
+
+// PersonalAdd adds a credential encrypted only for the current user. Team
+// re-encryption never touches it, so admins can't read it.
+func (a *Action) PersonalAdd(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook personal add WEBSITE [--name NAME]")
+	}
+	website := c.Args().First()
+	name := c.String("name")
+	username := c.String("username")
+	password := c.String("password")
+
+	user, err := a.getCurrentUser()
+	if err != nil {
+		return err
+	}
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+
+	if name == "" {
+		name, err = termio.PromptDefault("Account name: ", "default")
+		if err != nil {
+			return err
+		}
+	}
+	if _, _, err := parseCredentialPath(website + "/" + name); err != nil {
+		return err
+	}
+	if username == "" {
+		username, err = termio.Prompt("Username/Email: ")
+		if err != nil {
+			return err
+		}
+	}
+	if c.Bool("generate") {
+		password, err = a.generatePassword(c.Context, website, c.Int("length"), c.IsSet("length"))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Generated password: %s\n", password)
+	} else if password == "" {
+		password, err = termio.PromptPassword("Password: ")
+		if err != nil {
+			return err
+		}
+	}
+	if password == "" {
+		return fmt.Errorf("password is required")
+	}
+
+	cred := &models.Credential{
+		Website:  website,
+		Name:     name,
+		Username: username,
+		Password: password,
+	}
+	err = s.CreatePersonalCredential(c.Context, user.ID, cred, user.Email)
+	if errors.Is(err, store.ErrAlreadyExists) {
+		return fmt.Errorf("personal credential %s/%s %w", website, name, ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save personal credential: %w", err)
+	}
+
+	// Git commit; the message leaves out the name, which is the owner's business
+	if err := a.GitCommitAndSync(c.Context, "Update personal credentials"); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	fmt.Println()
+	ui.Successf("Added personal credential: %s/%s", website, name)
+	return nil
+}
+
+// PersonalRemove deletes one of the current user's personal credentials
+func (a *Action) PersonalRemove(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook personal rm WEBSITE/NAME")
+	}
+	website, name, err := parseCredentialPath(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	user, err := a.getCurrentUser()
+	if err != nil {
+		return err
+	}
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+
+	err = s.DeletePersonalCredential(c.Context, user.ID, website, name)
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("personal credential %s/%s %w", website, name, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete personal credential: %w", err)
+	}
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, "Update personal credentials"); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Deleted personal credential: %s/%s", website, name)
+	return nil
+}
+
+// loadPersonalCredential decrypts one of the current user's personal
+// credentials
+func (a *Action) loadPersonalCredential(c *cli.Context, website, name string) (*models.Credential, error) {
+	user, err := a.getCurrentUser()
+	if err != nil {
+		return nil, err
+	}
+	s, err := a.openStore()
+	if err != nil {
+		return nil, err
+	}
+
+	cred, err := s.GetPersonalCredential(c.Context, user.ID, website, name)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("personal credential %s/%s %w", website, name, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load personal credential: %w", err)
+	}
+	return cred, nil
+}
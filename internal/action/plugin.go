@@ -0,0 +1,201 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/pkg/ui"
+)
+
+// pluginPrefix names executables on PATH that add commands, like git's
+// git-foo: 'passbook foo' runs passbook-foo
+const pluginPrefix = "passbook-"
+
+// hooksDir holds the local hooks, inside the user's config directory. Hooks
+// never come from the store, so a push can't make teammates run code.
+const hooksDir = "hooks"
+
+// RunPlugin runs the plugin for an unknown command and exits with its
+// status. Set it as the app's CommandNotFound handler.
+func (a *Action) RunPlugin(c *cli.Context, name string) {
+	path, err := exec.LookPath(pluginPrefix + name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "passbook: '%s' is not a passbook command. See 'passbook help'.\n", name)
+		os.Exit(ExitUsage)
+	}
+
+	cmd := exec.CommandContext(c.Context, path, c.Args().Tail()...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = a.pluginEnv(name)
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		os.Exit(exitErr.ExitCode())
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "passbook: failed to run %s: %v\n", path, err)
+		os.Exit(ExitError)
+	}
+	os.Exit(0)
+}
+
+// PluginsList shows the plugins on PATH and the hooks installed locally
+func (a *Action) PluginsList(c *cli.Context) error {
+	plugins := findPlugins()
+	hooks, err := a.listHooks()
+	if err != nil {
+		return err
+	}
+
+	ui.Heading("Plugins")
+	fmt.Println()
+	if len(plugins) == 0 {
+		fmt.Printf("No plugins found. Put an executable named %sNAME on your PATH to add 'passbook NAME'.\n", pluginPrefix)
+	} else {
+		table := ui.NewTable("COMMAND", "PATH")
+		for _, name := range sortedKeys(plugins) {
+			table.Row("passbook "+name, plugins[name])
+		}
+		table.Print()
+	}
+
+	fmt.Println()
+	ui.Heading("Hooks")
+	fmt.Println()
+	if len(hooks) == 0 {
+		fmt.Printf("No hooks installed. Add executables such as pre-env-set or post-cred-add to %s\n", a.hooksPath())
+		return nil
+	}
+	for _, hook := range hooks {
+		fmt.Printf("  %s\n", hook)
+	}
+	return nil
+}
+
+// runHooks wraps every command so the local pre-COMMAND hook runs before it
+// and can stop it, and the post-COMMAND hook runs after it succeeds. The
+// command path names the hook: 'env set' runs pre-env-set and post-env-set.
+func (a *Action) runHooks(commands []*cli.Command, parent string) {
+	for _, cmd := range commands {
+		path := strings.TrimSpace(parent + " " + cmd.Name)
+		if len(cmd.Subcommands) > 0 {
+			a.runHooks(cmd.Subcommands, path)
+		}
+		if cmd.Action == nil {
+			continue
+		}
+
+		action := cmd.Action
+		event := strings.ReplaceAll(path, " ", "-")
+		cmd.Action = func(c *cli.Context) error {
+			if err := a.runHook(c, "pre-"+event, path); err != nil {
+				return err
+			}
+			if err := action(c); err != nil {
+				return err
+			}
+			if err := a.runHook(c, "post-"+event, path); err != nil {
+				ui.Warningf("%v", err)
+			}
+			return nil
+		}
+	}
+}
+
+// runHook runs the named hook, if installed, with the command's arguments
+func (a *Action) runHook(c *cli.Context, hook, command string) error {
+	path := filepath.Join(a.hooksPath(), hook)
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+		return nil
+	}
+
+	cmd := exec.CommandContext(c.Context, path, c.Args().Slice()...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(a.pluginEnv(command), "PASSBOOK_HOOK="+hook)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s hook failed: %w", hook, err)
+	}
+	return nil
+}
+
+// pluginEnv is the environment plugins and hooks run with
+func (a *Action) pluginEnv(command string) []string {
+	return append(os.Environ(),
+		"PASSBOOK_STORE="+a.cfg.StorePath,
+		"PASSBOOK_CONFIG_DIR="+a.cfg.ConfigDir,
+		"PASSBOOK_IDENTITY="+a.cfg.IdentityPath(),
+		"PASSBOOK_USER="+a.cfg.Identity.Email,
+		"PASSBOOK_COMMAND="+command,
+	)
+}
+
+// hooksPath returns the directory local hooks are read from
+func (a *Action) hooksPath() string {
+	return filepath.Join(a.cfg.ConfigDir, hooksDir)
+}
+
+// listHooks returns the names of the executable hooks installed
+func (a *Action) listHooks() ([]string, error) {
+	entries, err := os.ReadDir(a.hooksPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hooks: %w", err)
+	}
+
+	var hooks []string
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() || info.Mode()&0111 == 0 {
+			continue
+		}
+		if strings.HasPrefix(entry.Name(), "pre-") || strings.HasPrefix(entry.Name(), "post-") {
+			hooks = append(hooks, entry.Name())
+		}
+	}
+	return hooks, nil
+}
+
+// findPlugins maps plugin command names to the first matching executable on
+// PATH, the one 'passbook NAME' runs
+func findPlugins() map[string]string {
+	plugins := make(map[string]string)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutPrefix(entry.Name(), pluginPrefix)
+			if !ok || name == "" || plugins[name] != "" {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if info, err := os.Stat(path); err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+				continue
+			}
+			plugins[name] = path
+		}
+	}
+	return plugins
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
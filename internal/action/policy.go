@@ -0,0 +1,220 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/pkg/ui"
+)
+
+// usersFile adapts the users file to rbac.UserStore
+type usersFile struct {
+	a *Action
+}
+
+// ListUsers returns all team members
+func (u usersFile) ListUsers() ([]models.User, error) {
+	userList, err := u.a.loadUsers()
+	if err != nil {
+		return nil, err
+	}
+	return userList.Users, nil
+}
+
+// GetUser returns a team member by email
+func (u usersFile) GetUser(email string) (*models.User, error) {
+	userList, err := u.a.loadUsers()
+	if err != nil {
+		return nil, err
+	}
+	for i := range userList.Users {
+		if userList.Users[i].Email == email {
+			return &userList.Users[i], nil
+		}
+	}
+	return nil, fmt.Errorf("user %s %w", email, ErrNotFound)
+}
+
+// policy returns the RBAC engine every authorization decision goes through
+func (a *Action) policy() *rbac.Engine {
+	return rbac.NewEngine(usersFile{a: a})
+}
+
+// authorize checks that the current user holds a permission and returns them
+func (a *Action) authorize(perm rbac.Permission) (*models.User, error) {
+	currentUser, err := a.getCurrentUser()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+
+	if d := a.policy().Explain(currentUser, perm); !d.Allowed {
+		return nil, fmt.Errorf("%w: %s", ErrAccessDenied, d.Reason)
+	}
+	if slices.Contains(adminPermissions, perm) && !currentUser.IsServiceAccount() {
+		if err := a.requireRecentLogin(); err != nil {
+			return nil, err
+		}
+	}
+
+	return currentUser, nil
+}
+
+// adminPermissions guard admin operations, which need a recent GitHub login
+// when the store sets auth.admin_session_hours
+var adminPermissions = []rbac.Permission{
+	rbac.PermTeamInvite, rbac.PermTeamRevoke, rbac.PermTeamGrant,
+	rbac.PermProjectDelete, rbac.PermStoreReencrypt, rbac.PermStoreConfig,
+}
+
+// requireWrite checks that the current user may change a secret under its
+// per-secret permissions, given by canWrite. Secrets without any follow
+// roles, which the caller checks with authorize.
+func (a *Action) requireWrite(canWrite func(email string) bool, target string) error {
+	currentUser, err := a.getCurrentUser()
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	if !canWrite(currentUser.Email) {
+		return fmt.Errorf("%w: you have read-only access to %s", ErrAccessDenied, target)
+	}
+	return nil
+}
+
+// checkWrite checks that the secret at rel, a path relative to the store,
+// may be changed or deleted by us under the per-secret permissions it was
+// saved with
+func (a *Action) checkWrite(ctx context.Context, rel string) error {
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+	return s.CheckWrite(ctx, filepath.ToSlash(rel))
+}
+
+// authorizeProject checks that the current user holds a permission on a
+// project, either through their roles or as one of its owners
+func (a *Action) authorizeProject(project string, perm rbac.Permission) (*models.User, error) {
+	currentUser, err := a.getCurrentUser()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+
+	p, err := a.loadProjectByName(project)
+	if err != nil {
+		return nil, err
+	}
+
+	if d := a.policy().ExplainProject(currentUser, perm, project, p.Owners); !d.Allowed {
+		return nil, fmt.Errorf("%w: %s", ErrAccessDenied, d.Reason)
+	}
+
+	return currentUser, nil
+}
+
+// authorizeEnvAccess checks that the current user may change who can access
+// a project stage: anyone who can write the stage, or the project's owners
+func (a *Action) authorizeEnvAccess(project string, stage models.Stage) (*models.User, error) {
+	currentUser, err := a.authorize(rbac.GetStagePermission(stage, true))
+	if err == nil {
+		return currentUser, nil
+	}
+	if owner, ownerErr := a.authorizeProject(project, rbac.PermProjectManage); ownerErr == nil {
+		return owner, nil
+	}
+	return nil, err
+}
+
+// Can evaluates a permission and explains why it would be allowed or denied
+func (a *Action) Can(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook can PERMISSION [TARGET]")
+	}
+
+	perm := rbac.Permission(c.Args().Get(0))
+	target := c.Args().Get(1)
+	email := c.String("user")
+
+	// Resolve stage-generic env permissions against the target's stage
+	if perm == "env:read" || perm == "env:write" {
+		if target == "" {
+			return fmt.Errorf("%s needs a TARGET (PROJECT/STAGE or STAGE)", perm)
+		}
+		stage := models.Stage(target)
+		if i := strings.LastIndex(target, "/"); i >= 0 {
+			stage = models.Stage(target[i+1:])
+		}
+		if !stage.IsValid() {
+			return fmt.Errorf("invalid stage: %s (valid: dev, staging, prod)", stage)
+		}
+		perm = rbac.GetStagePermission(stage, perm == "env:write")
+	}
+
+	if !rbac.IsValidPermission(perm) {
+		var names []string
+		for _, p := range rbac.AllPermissions() {
+			names = append(names, string(p))
+		}
+		return fmt.Errorf("unknown permission: %s (valid: env:read, env:write, %s)", perm, strings.Join(names, ", "))
+	}
+
+	var user *models.User
+	var err error
+	if email != "" {
+		user, err = usersFile{a: a}.GetUser(email)
+	} else {
+		user, err = a.getCurrentUser()
+	}
+	if err != nil {
+		return err
+	}
+
+	d := a.policy().Explain(user, perm)
+
+	// Project owners hold extra permissions on their own project
+	if perm == rbac.PermProjectManage && target != "" {
+		project, err := a.loadProjectByName(target)
+		if err != nil {
+			return err
+		}
+		d = a.policy().ExplainProject(user, perm, target, project.Owners)
+	}
+
+	// Per-secret permissions can narrow role-based access to a credential
+	if d.Allowed && target != "" && (perm == rbac.PermCredentialsRead || perm == rbac.PermCredentialsWrite) {
+		website, name, err := parseCredentialPath(target)
+		if err != nil {
+			return err
+		}
+		cred, err := a.loadCredential(c.Context, website, name)
+		if err != nil {
+			return fmt.Errorf("failed to load credential: %w", err)
+		}
+		allowed := cred.CanUserRead(user.Email)
+		if perm == rbac.PermCredentialsWrite {
+			allowed = cred.CanUserWrite(user.Email)
+		}
+		if !allowed {
+			d.Allowed = false
+			d.Reason = fmt.Sprintf("%s's per-secret permissions don't include %s", target, user.Email)
+		}
+	}
+
+	subject := user.Email
+	if target != "" {
+		subject += " on " + target
+	}
+	if !d.Allowed {
+		return fmt.Errorf("%w: %s for %s: %s", ErrAccessDenied, d.Permission, subject, d.Reason)
+	}
+	ui.Successf("ALLOWED: %s for %s", d.Permission, subject)
+	fmt.Printf("  Reason: %s\n", d.Reason)
+
+	return nil
+}
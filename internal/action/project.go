@@ -4,14 +4,18 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/urfave/cli/v2"
 	"gopkg.in/yaml.v3"
 
+	"passbook/internal/audit"
 	"passbook/internal/models"
+	"passbook/internal/rbac"
 	"passbook/pkg/termio"
+	"passbook/pkg/ui"
 )
 
 // Project represents project metadata
@@ -21,10 +25,24 @@ type Project struct {
 	Stages      []models.Stage `yaml:"stages"`
 	CreatedBy   string         `yaml:"created_by"`
 	CreatedAt   time.Time      `yaml:"created_at"`
+
+	// Team members who manage the project's owners, stages, schema and
+	// access without being admins
+	Owners []string `yaml:"owners,omitempty"`
+
+	// Variables the application expects, checked by env set/import/check
+	Schema []models.VarSchema `yaml:"schema,omitempty"`
 }
 
-// ProjectList lists all projects
+// ProjectList lists all projects, or archived ones with --archived
 func (a *Action) ProjectList(c *cli.Context) error {
+	if c.Bool("archived") {
+		return a.listArchivedProjects()
+	}
+	if c.Bool("long") {
+		return a.listProjectsLong()
+	}
+
 	projectsDir := filepath.Join(a.cfg.StorePath, "projects")
 
 	// Check if projects directory exists
@@ -34,8 +52,7 @@ func (a *Action) ProjectList(c *cli.Context) error {
 		return nil
 	}
 
-	fmt.Println("Projects")
-	fmt.Println("========")
+	ui.Heading("Projects")
 	fmt.Println()
 
 	entries, err := os.ReadDir(projectsDir)
@@ -81,6 +98,71 @@ func (a *Action) ProjectList(c *cli.Context) error {
 	return nil
 }
 
+// listProjectsLong prints a table of projects with their stages and owners
+func (a *Action) listProjectsLong() error {
+	projectsDir := filepath.Join(a.cfg.StorePath, "projects")
+	entries, _ := os.ReadDir(projectsDir)
+
+	table := ui.NewTable("PROJECT", "STAGES", "OWNERS", "DESCRIPTION")
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		project, _ := loadProject(filepath.Join(projectsDir, entry.Name()))
+		if project == nil {
+			table.Row(entry.Name(), "", "", "")
+			continue
+		}
+		stages := make([]string, len(project.Stages))
+		for i, stage := range project.Stages {
+			stages[i] = string(stage)
+		}
+		owners := strings.Join(project.Owners, ", ")
+		if owners == "" {
+			owners = ui.Muted("admins")
+		}
+		table.Row(entry.Name(), strings.Join(stages, ", "), owners, project.Description)
+	}
+
+	if table.Len() == 0 {
+		fmt.Println("No projects found.")
+		fmt.Println("\nCreate one with: passbook project create myapp")
+		return nil
+	}
+	table.Print()
+
+	return nil
+}
+
+// listArchivedProjects prints archived projects
+func (a *Action) listArchivedProjects() error {
+	entries, _ := os.ReadDir(a.archivedProjectsDir())
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	if len(names) == 0 {
+		fmt.Println("No archived projects.")
+		return nil
+	}
+
+	ui.Heading("Archived projects")
+	fmt.Println()
+	for _, name := range names {
+		fmt.Printf("  %s\n", name)
+		if project, _ := loadProject(filepath.Join(a.archivedProjectsDir(), name)); project != nil && project.Description != "" {
+			fmt.Printf("    Description: %s\n", project.Description)
+		}
+	}
+	fmt.Println("\nRestore one with: passbook project unarchive NAME")
+
+	return nil
+}
+
 // ProjectCreate creates a new project
 func (a *Action) ProjectCreate(c *cli.Context) error {
 	if c.NArg() < 1 {
@@ -91,6 +173,28 @@ func (a *Action) ProjectCreate(c *cli.Context) error {
 	description := c.String("description")
 	stageStrs := c.StringSlice("stage")
 
+	// A template supplies defaults for everything not given on the command line
+	var template *Project
+	var templateDir string
+	if from := c.String("from-template"); from != "" {
+		var err error
+		templateDir, err = a.findProjectDir(from)
+		if err != nil {
+			return err
+		}
+		if template, err = loadProject(templateDir); err != nil {
+			return fmt.Errorf("failed to load template %s: %w", from, err)
+		}
+		if description == "" {
+			description = template.Description
+		}
+		if len(stageStrs) == 0 {
+			for _, stage := range template.Stages {
+				stageStrs = append(stageStrs, string(stage))
+			}
+		}
+	}
+
 	if len(stageStrs) == 0 {
 		stageStrs = []string{"dev", "staging", "prod"}
 	}
@@ -106,26 +210,26 @@ func (a *Action) ProjectCreate(c *cli.Context) error {
 	}
 
 	// Check permission (prod-access or admin can create projects)
-	currentUser, err := a.getCurrentUser()
+	currentUser, err := a.authorize(rbac.PermProjectCreate)
 	if err != nil {
-		return fmt.Errorf("failed to get current user: %w", err)
+		return err
 	}
 
-	canCreate := false
-	for _, role := range currentUser.Roles {
-		if role == models.RoleAdmin || role == models.RoleProdAccess {
-			canCreate = true
-			break
+	// Owners must be team members
+	owners := c.StringSlice("owner")
+	for _, owner := range owners {
+		if _, err := (usersFile{a: a}).GetUser(owner); err != nil {
+			return fmt.Errorf("owner must be a team member: %w", err)
 		}
 	}
-	if !canCreate {
-		return fmt.Errorf("permission denied: only prod-access or admin can create projects")
-	}
 
 	// Check if project already exists
 	projectDir := filepath.Join(a.cfg.StorePath, "projects", name)
 	if _, err := os.Stat(projectDir); err == nil {
-		return fmt.Errorf("project %s already exists", name)
+		return fmt.Errorf("project %s %w", name, ErrConflict)
+	}
+	if _, err := os.Stat(filepath.Join(a.archivedProjectsDir(), name)); err == nil {
+		return fmt.Errorf("project %s %w (archived); restore it with 'passbook project unarchive %s'", name, ErrConflict, name)
 	}
 
 	// Create project directory
@@ -140,6 +244,10 @@ func (a *Action) ProjectCreate(c *cli.Context) error {
 		Stages:      stages,
 		CreatedBy:   currentUser.Email,
 		CreatedAt:   time.Now(),
+		Owners:      owners,
+	}
+	if template != nil {
+		project.Schema = template.Schema
 	}
 
 	projectData, err := yaml.Marshal(project)
@@ -152,12 +260,24 @@ func (a *Action) ProjectCreate(c *cli.Context) error {
 		return fmt.Errorf("failed to write project file: %w", err)
 	}
 
+	// Copy variable keys and permissions from the template
+	msg := fmt.Sprintf("Create project: %s", name)
+	if template != nil {
+		if err := a.copyTemplateEnvs(c, templateDir, name, stages); err != nil {
+			os.RemoveAll(projectDir)
+			return err
+		}
+		msg = fmt.Sprintf("Create project: %s (from %s)", name, c.String("from-template"))
+	}
+
+	a.logAudit(audit.EventProjectCreated, name)
+
 	// Git commit
-	if err := a.GitCommitAndSync(fmt.Sprintf("Create project: %s", name)); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+	if err := a.GitCommitAndSync(c.Context, msg); err != nil {
+		ui.Warningf("%v", err)
 	}
 
-	fmt.Printf("✓ Created project: %s\n", name)
+	ui.Successf("Created project: %s", name)
 	fmt.Printf("  Stages: %s\n", strings.Join(stageStrs, ", "))
 	fmt.Println("\nAdd environment variables with:")
 	fmt.Printf("  passbook env set %s dev DATABASE_URL=...\n", name)
@@ -165,29 +285,211 @@ func (a *Action) ProjectCreate(c *cli.Context) error {
 	return nil
 }
 
-// ProjectRemove removes a project
+// archivedProjectsDir holds archived projects, outside projects/ so they are
+// hidden from listings but still re-encrypted with everything else
+func (a *Action) archivedProjectsDir() string {
+	return filepath.Join(a.cfg.StorePath, "archive", "projects")
+}
+
+// ProjectArchive moves a project out of the way without deleting it
+func (a *Action) ProjectArchive(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook project archive NAME")
+	}
+
+	// Check permission (admin only can archive projects)
+	if _, err := a.authorize(rbac.PermProjectDelete); err != nil {
+		return err
+	}
+
+	return a.archiveProject(c, c.Args().First())
+}
+
+// archiveProject moves projects/NAME to archive/projects/NAME and commits
+func (a *Action) archiveProject(c *cli.Context, name string) error {
+	projectDir := filepath.Join(a.cfg.StorePath, "projects", name)
+	if _, err := os.Stat(projectDir); os.IsNotExist(err) {
+		return fmt.Errorf("project %s %w", name, ErrNotFound)
+	}
+
+	archiveDir := filepath.Join(a.archivedProjectsDir(), name)
+	if _, err := os.Stat(archiveDir); err == nil {
+		return fmt.Errorf("archived project %s %w; purge it first with 'passbook project rm --purge %s'", name, ErrConflict, name)
+	}
+
+	if err := os.MkdirAll(a.archivedProjectsDir(), 0700); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	if err := os.Rename(projectDir, archiveDir); err != nil {
+		return fmt.Errorf("failed to archive project: %w", err)
+	}
+
+	a.logAudit(audit.EventProjectDeleted, name, "action", "archive")
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Archive project: %s", name)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Archived project: %s", name)
+	fmt.Printf("\nRestore it with: passbook project unarchive %s\n", name)
+
+	return nil
+}
+
+// ProjectUnarchive restores an archived project
+func (a *Action) ProjectUnarchive(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook project unarchive NAME")
+	}
+
+	name := c.Args().First()
+
+	// Check permission (admin only can restore projects)
+	if _, err := a.authorize(rbac.PermProjectDelete); err != nil {
+		return err
+	}
+
+	archiveDir := filepath.Join(a.archivedProjectsDir(), name)
+	if _, err := os.Stat(archiveDir); os.IsNotExist(err) {
+		return fmt.Errorf("archived project %s %w", name, ErrNotFound)
+	}
+
+	projectDir := filepath.Join(a.cfg.StorePath, "projects", name)
+	if _, err := os.Stat(projectDir); err == nil {
+		return fmt.Errorf("project %s %w", name, ErrConflict)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(projectDir), 0700); err != nil {
+		return fmt.Errorf("failed to create projects directory: %w", err)
+	}
+	if err := os.Rename(archiveDir, projectDir); err != nil {
+		return fmt.Errorf("failed to restore project: %w", err)
+	}
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Unarchive project: %s", name)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Restored project: %s", name)
+
+	return nil
+}
+
+// findProjectDir returns the directory of a project, looking in the archive
+// if no active project has that name. Archived projects can serve as
+// templates for new ones.
+func (a *Action) findProjectDir(name string) (string, error) {
+	for _, dir := range []string{filepath.Join(a.cfg.StorePath, "projects", name), filepath.Join(a.archivedProjectsDir(), name)} {
+		if _, err := os.Stat(dir); err == nil {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("project %s %w", name, ErrNotFound)
+}
+
+// copyTemplateEnvs creates an env file for each stage with the template's
+// keys, descriptions and permissions. Values are left empty, or prompted for
+// with --prompt. Stages the template lacks, or the user can't decrypt, start
+// empty.
+func (a *Action) copyTemplateEnvs(c *cli.Context, templateDir, project string, stages []models.Stage) error {
+	prompt := c.Bool("prompt")
+
+	currentUser, err := a.getCurrentUser()
+	if err != nil {
+		return err
+	}
+
+	for _, stage := range stages {
+		path := filepath.Join(templateDir, string(stage)+".env.age")
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		source, err := a.loadEnvFileAt(c.Context, path)
+		if err != nil {
+			ui.Warningf("skipping %s variables: %v", stage, err)
+			continue
+		}
+
+		envFile := &models.EnvFile{
+			Project:     project,
+			Stage:       stage,
+			Permissions: source.Permissions,
+			CreatedBy:   currentUser.Email,
+			UpdatedBy:   currentUser.Email,
+			UpdatedAt:   time.Now(),
+		}
+		for _, v := range source.Vars {
+			value := ""
+			if prompt {
+				label := fmt.Sprintf("%s/%s %s", project, stage, v.Key)
+				if v.Description != "" {
+					label += fmt.Sprintf(" (%s)", v.Description)
+				}
+				if v.IsSecret {
+					value, err = termio.PromptPassword(label + ": ")
+				} else {
+					value, err = termio.Prompt(label + ": ")
+				}
+				if err != nil {
+					return err
+				}
+			}
+			envFile.Vars = append(envFile.Vars, models.EnvVar{
+				Key:         v.Key,
+				Value:       value,
+				Description: v.Description,
+				IsSecret:    v.IsSecret,
+				Owner:       v.Owner,
+			})
+		}
+
+		// Check the values given against the copied schema
+		var given []models.EnvVar
+		for _, v := range envFile.Vars {
+			if v.Value != "" {
+				given = append(given, v)
+			}
+		}
+		if err := a.validateEnvVars(project, stage, given); err != nil {
+			return err
+		}
+
+		if err := a.saveEnvFile(c.Context, envFile); err != nil {
+			return fmt.Errorf("failed to save %s environment: %w", stage, err)
+		}
+		fmt.Printf("  %s: %d variables\n", stage, len(envFile.Vars))
+	}
+
+	return nil
+}
+
+// ProjectRemove archives a project, or permanently deletes it with --purge
 func (a *Action) ProjectRemove(c *cli.Context) error {
 	if c.NArg() < 1 {
-		return fmt.Errorf("usage: passbook project rm NAME")
+		return fmt.Errorf("usage: passbook project rm NAME [--purge]")
 	}
 
 	name := c.Args().First()
 	force := c.Bool("force")
 
 	// Check permission (admin only can delete projects)
-	currentUser, err := a.getCurrentUser()
-	if err != nil {
-		return fmt.Errorf("failed to get current user: %w", err)
+	if _, err := a.authorize(rbac.PermProjectDelete); err != nil {
+		return err
 	}
 
-	if !currentUser.IsAdmin() {
-		return fmt.Errorf("permission denied: only admins can delete projects")
+	if !c.Bool("purge") {
+		return a.archiveProject(c, name)
 	}
 
-	// Check if project exists
+	// Purge an active project, or one already archived
 	projectDir := filepath.Join(a.cfg.StorePath, "projects", name)
 	if _, err := os.Stat(projectDir); os.IsNotExist(err) {
-		return fmt.Errorf("project %s not found", name)
+		projectDir = filepath.Join(a.archivedProjectsDir(), name)
+		if _, err := os.Stat(projectDir); os.IsNotExist(err) {
+			return fmt.Errorf("project %s %w", name, ErrNotFound)
+		}
 	}
 
 	// Count env files to show what will be deleted
@@ -199,9 +501,9 @@ func (a *Action) ProjectRemove(c *cli.Context) error {
 		}
 	}
 
-	// Confirm
+	// Confirm twice: a purge can't be undone with unarchive
 	if !force {
-		msg := fmt.Sprintf("Delete project %s", name)
+		msg := fmt.Sprintf("Permanently delete project %s", name)
 		if envCount > 0 {
 			msg += fmt.Sprintf(" (%d environment files)", envCount)
 		}
@@ -215,6 +517,23 @@ func (a *Action) ProjectRemove(c *cli.Context) error {
 			fmt.Println("Cancelled.")
 			return nil
 		}
+
+		typed, err := termio.Prompt(fmt.Sprintf("Type the project name (%s) to confirm: ", name))
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(typed) != name {
+			fmt.Println("Name doesn't match. Cancelled.")
+			return nil
+		}
+	}
+
+	rel, err := filepath.Rel(a.cfg.StorePath, projectDir)
+	if err != nil {
+		return err
+	}
+	if err := a.checkHold(rel, "delete"); err != nil {
+		return err
 	}
 
 	// Delete project directory
@@ -222,13 +541,205 @@ func (a *Action) ProjectRemove(c *cli.Context) error {
 		return fmt.Errorf("failed to delete project: %w", err)
 	}
 
+	a.logAudit(audit.EventProjectDeleted, name)
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Delete project: %s", name)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Deleted project: %s", name)
+
+	return nil
+}
+
+// ProjectOwnerAdd makes a team member an owner of a project
+func (a *Action) ProjectOwnerAdd(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return fmt.Errorf("usage: passbook project owner add PROJECT EMAIL")
+	}
+
+	name := c.Args().Get(0)
+	email := c.Args().Get(1)
+
+	// Check permission (admins, or the project's existing owners)
+	if _, err := a.authorizeProject(name, rbac.PermProjectManage); err != nil {
+		return err
+	}
+
+	if _, err := (usersFile{a: a}).GetUser(email); err != nil {
+		return fmt.Errorf("owner must be a team member: %w", err)
+	}
+
+	project, err := a.loadProjectByName(name)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(project.Owners, email) {
+		return fmt.Errorf("%s is already an owner of %s", email, name)
+	}
+	project.Owners = append(project.Owners, email)
+
+	if err := saveProject(filepath.Join(a.cfg.StorePath, "projects", name), project); err != nil {
+		return err
+	}
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Add owner %s to project: %s", email, name)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("%s now owns %s", email, name)
+
+	return nil
+}
+
+// ProjectOwnerRemove removes an owner from a project
+func (a *Action) ProjectOwnerRemove(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return fmt.Errorf("usage: passbook project owner rm PROJECT EMAIL")
+	}
+
+	name := c.Args().Get(0)
+	email := c.Args().Get(1)
+
+	// Check permission (admins, or the project's existing owners)
+	if _, err := a.authorizeProject(name, rbac.PermProjectManage); err != nil {
+		return err
+	}
+
+	project, err := a.loadProjectByName(name)
+	if err != nil {
+		return err
+	}
+	i := slices.Index(project.Owners, email)
+	if i < 0 {
+		return fmt.Errorf("owner %s %w in %s", email, ErrNotFound, name)
+	}
+	project.Owners = slices.Delete(project.Owners, i, i+1)
+
+	if err := saveProject(filepath.Join(a.cfg.StorePath, "projects", name), project); err != nil {
+		return err
+	}
+
 	// Git commit
-	if err := a.GitCommitAndSync(fmt.Sprintf("Delete project: %s", name)); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Remove owner %s from project: %s", email, name)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("%s no longer owns %s", email, name)
+
+	return nil
+}
+
+// ProjectStageAdd adds a stage to a project
+func (a *Action) ProjectStageAdd(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return fmt.Errorf("usage: passbook project stage add PROJECT STAGE")
 	}
 
-	fmt.Printf("✓ Deleted project: %s\n", name)
+	name := c.Args().Get(0)
+	stage := models.Stage(c.Args().Get(1))
+	if !stage.IsValid() {
+		return fmt.Errorf("invalid stage: %s (valid: dev, staging, prod)", stage)
+	}
+
+	// Check permission (admins, or the project's owners)
+	if _, err := a.authorizeProject(name, rbac.PermProjectManage); err != nil {
+		return err
+	}
 
+	project, err := a.loadProjectByName(name)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(project.Stages, stage) {
+		return fmt.Errorf("stage %s %w in %s", stage, ErrConflict, name)
+	}
+	project.Stages = append(project.Stages, stage)
+
+	if err := saveProject(filepath.Join(a.cfg.StorePath, "projects", name), project); err != nil {
+		return err
+	}
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Add stage %s to project: %s", stage, name)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Added stage %s to %s", stage, name)
+
+	return nil
+}
+
+// ProjectStageRemove removes a stage from a project, deleting its variables
+// with --force
+func (a *Action) ProjectStageRemove(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return fmt.Errorf("usage: passbook project stage rm PROJECT STAGE [--force]")
+	}
+
+	name := c.Args().Get(0)
+	stage := models.Stage(c.Args().Get(1))
+	if !stage.IsValid() {
+		return fmt.Errorf("invalid stage: %s (valid: dev, staging, prod)", stage)
+	}
+
+	// Check permission (admins, or the project's owners)
+	if _, err := a.authorizeProject(name, rbac.PermProjectManage); err != nil {
+		return err
+	}
+
+	project, err := a.loadProjectByName(name)
+	if err != nil {
+		return err
+	}
+	i := slices.Index(project.Stages, stage)
+	if i < 0 {
+		return fmt.Errorf("stage %s %w in %s", stage, ErrNotFound, name)
+	}
+
+	projectDir := filepath.Join(a.cfg.StorePath, "projects", name)
+	envPath := filepath.Join(projectDir, string(stage)+".env.age")
+	if _, err := os.Stat(envPath); err == nil {
+		if !c.Bool("force") {
+			return fmt.Errorf("stage %s of %s has variables; use --force to delete them", stage, name)
+		}
+		if err := a.checkWrite(c.Context, filepath.Join("projects", name, string(stage)+".env.age")); err != nil {
+			return err
+		}
+		if err := a.checkHold(filepath.Join("projects", name, string(stage)), "delete"); err != nil {
+			return err
+		}
+		if err := os.Remove(envPath); err != nil {
+			return fmt.Errorf("failed to delete environment: %w", err)
+		}
+	}
+	project.Stages = slices.Delete(project.Stages, i, i+1)
+
+	if err := saveProject(projectDir, project); err != nil {
+		return err
+	}
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Remove stage %s from project: %s", stage, name)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Removed stage %s from %s", stage, name)
+
+	return nil
+}
+
+// saveProject writes project metadata to a directory
+func saveProject(projectDir string, project *Project) error {
+	data, err := yaml.Marshal(project)
+	if err != nil {
+		return fmt.Errorf("failed to marshal project: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, ".passbook-project"), data, 0600); err != nil {
+		return fmt.Errorf("failed to write project file: %w", err)
+	}
 	return nil
 }
 
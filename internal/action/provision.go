@@ -0,0 +1,153 @@
+package action
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/models"
+	"passbook/pkg/ui"
+)
+
+// stageRoles is the role a machine needs to read each stage
+var stageRoles = map[models.Stage]models.Role{
+	models.StageDev:     models.RoleDev,
+	models.StageStaging: models.RoleStagingAccess,
+	models.StageProd:    models.RoleProdAccess,
+}
+
+// unitNameUnsafe matches what can't go in a systemd unit or directory name
+var unitNameUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// Provision sets up a server to read one project stage: it installs a
+// machine identity readable only by root and, with --systemd, a drop-in
+// that hands the identity to a service with LoadCredential and renders the
+// env into its tmpfs runtime directory at each start. The env never touches
+// persistent disk.
+func (a *Action) Provision(c *cli.Context) error {
+	identityPath := c.String("identity-file")
+	project := c.String("project")
+	stage := models.Stage(c.String("stage"))
+	if identityPath == "" || project == "" || stage == "" {
+		return fmt.Errorf("usage: passbook provision --identity-file PATH --project PROJECT --stage STAGE [--systemd --service NAME]")
+	}
+	if !stage.IsValid() {
+		return fmt.Errorf("invalid stage: %s (valid: dev, staging, prod)", stage)
+	}
+	service := strings.TrimSuffix(c.String("service"), ".service")
+	if c.Bool("systemd") && service == "" {
+		return fmt.Errorf("--systemd needs --service, the unit whose environment passbook renders")
+	}
+	identityPath, err := filepath.Abs(identityPath)
+	if err != nil {
+		return err
+	}
+	storePath, err := filepath.Abs(a.cfg.StorePath)
+	if err != nil {
+		return err
+	}
+
+	name := c.String("name")
+	if name == "" {
+		host, _ := os.Hostname()
+		host, _, _ = strings.Cut(host, ".")
+		name = strings.Trim(strings.ToLower(unitNameUnsafe.ReplaceAllString(host+"-"+project+"-"+string(stage), "-")), "-")
+	}
+	if !serviceAccountNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid name: %s (use lowercase letters, digits and dashes)", name)
+	}
+
+	// Identity: reuse one already installed so provisioning can be re-run
+	var pubKey string
+	if _, err := os.Stat(identityPath); err == nil {
+		if pubKey, err = age.GetPublicKeyFromFile(identityPath); err != nil {
+			return fmt.Errorf("failed to read identity %s: %w", identityPath, err)
+		}
+		ui.Successf("Using the identity at %s", identityPath)
+	} else {
+		if pubKey, err = age.GenerateIdentity(identityPath); err != nil {
+			return fmt.Errorf("failed to generate identity: %w", err)
+		}
+		if err := os.Chmod(identityPath, 0400); err != nil {
+			return fmt.Errorf("failed to restrict identity: %w", err)
+		}
+		ui.Successf("Installed a new identity at %s", identityPath)
+	}
+	fmt.Printf("  Public key: %s\n", pubKey)
+
+	if c.Bool("systemd") {
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to find the passbook binary: %w", err)
+		}
+		dropIn := filepath.Join(c.String("unit-dir"), service+".service.d", "passbook.conf")
+		if err := os.MkdirAll(filepath.Dir(dropIn), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(dropIn), err)
+		}
+		unit := systemdDropIn(exe, identityPath, storePath, project, stage)
+		if err := os.WriteFile(dropIn, []byte(unit), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", dropIn, err)
+		}
+		ui.Successf("Wrote %s", dropIn)
+	}
+
+	if !a.cfg.IsInitialized() {
+		fmt.Println()
+		ui.Warningf("no store at %s; clone it there (passbook clone URL) before starting the service", storePath)
+	}
+
+	fmt.Println()
+	fmt.Println("Next steps:")
+	fmt.Println("  1. On an admin's machine, register this server and re-encrypt for it:")
+	fmt.Printf("       passbook service-account create --role %s --public-key %s %s\n", stageRoles[stage], pubKey, name)
+	fmt.Println("       passbook reencrypt")
+	if c.Bool("systemd") {
+		fmt.Println("  2. Here, once that's pushed:")
+		fmt.Printf("       systemctl daemon-reload && systemctl restart %s\n", service)
+	} else {
+		fmt.Println("  2. Here, once that's pushed, read the env with:")
+		fmt.Printf("       PASSBOOK_IDENTITY=%s passbook env exec %s %s -- COMMAND\n", identityPath, project, stage)
+	}
+	return nil
+}
+
+// systemdDropIn returns a drop-in that renders a project stage's env for a
+// service at each start. The identity reaches passbook through
+// LoadCredential, so only the service can read it, and the env is written
+// to the service's runtime directory on /run, which is a tmpfs removed when
+// the service stops. passbook's own settings are passed to it alone, not to
+// the service.
+func systemdDropIn(exe, identityPath, storePath string, project string, stage models.Stage) string {
+	dir := "passbook-" + unitNameUnsafe.ReplaceAllString(project, "-") + "-" + string(stage)
+	envFile := "%t/" + dir + "/env"
+	passbook := fmt.Sprintf("/usr/bin/env HOME=%s PASSBOOK_STORE=%s PASSBOOK_IDENTITY=%%d/passbook-identity %s --read-only",
+		systemdEscape(filepath.Dir(storePath)), systemdEscape(storePath), systemdEscape(exe))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Written by passbook provision: renders %s/%s into a tmpfs at each start\n", project, stage)
+	b.WriteString("[Service]\n")
+	fmt.Fprintf(&b, "LoadCredential=passbook-identity:%s\n", systemdEscape(identityPath))
+	fmt.Fprintf(&b, "RuntimeDirectory=%s\n", dir)
+	b.WriteString("RuntimeDirectoryMode=0700\n")
+	// A failed pull leaves the last synced secrets in place, so an outage
+	// of the remote doesn't stop the service
+	fmt.Fprintf(&b, "ExecStartPre=-%s sync\n", passbook)
+	fmt.Fprintf(&b, "ExecStartPre=%s env export --tmpfs -o %s %s %s\n", passbook, envFile, systemdEscape(project), stage)
+	fmt.Fprintf(&b, "EnvironmentFile=-%s\n", envFile)
+	return b.String()
+}
+
+// systemdEscape escapes % so systemd doesn't read it as a specifier, and
+// quotes words with spaces
+func systemdEscape(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if strings.ContainsAny(s, " \t\"") {
+		return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+	}
+	return s
+}
@@ -0,0 +1,420 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/audit"
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/backend/storage/gitfs"
+	"passbook/internal/config"
+	"passbook/internal/envformat"
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/pkg/ui"
+)
+
+// publishFilterKeys are the attributes a publish filter can match on
+var publishFilterKeys = []string{"kind", "project", "stage", "website", "name", "tag"}
+
+// publishStateFile records, inside a mirror's .git directory, a hash of
+// each published file so unchanged secrets aren't re-encrypted and
+// committed again
+const publishStateFile = "passbook-publish.json"
+
+// publishReadme explains a mirror to the people it's published for
+const publishReadme = `# Secrets mirror
+
+This repository is published from a passbook store and is overwritten on
+every publish; changes made here are lost.
+
+Decrypt a file with your age key:
+
+    age -d -i key.txt projects/PROJECT/STAGE.env.age > .env
+    age -d -i key.txt credentials/WEBSITE/NAME.age
+`
+
+// publishFilter matches secrets against "key=value" terms; each value may
+// list comma-separated globs, and a secret must match every term
+type publishFilter map[string][]string
+
+// parsePublishFilter parses a filter such as "project=website stage=dev"
+func parsePublishFilter(s string) (publishFilter, error) {
+	f := make(publishFilter)
+	for _, term := range strings.Fields(s) {
+		key, value, ok := strings.Cut(term, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%w: filter term %q isn't key=value", ErrInvalidInput, term)
+		}
+		if !slices.Contains(publishFilterKeys, key) {
+			return nil, fmt.Errorf("%w: unknown filter key %q (use %s)", ErrInvalidInput, key, strings.Join(publishFilterKeys, ", "))
+		}
+		for _, pattern := range strings.Split(value, ",") {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("%w: bad pattern %q", ErrInvalidInput, pattern)
+			}
+			f[key] = append(f[key], pattern)
+		}
+	}
+	if len(f) == 0 {
+		return nil, fmt.Errorf("%w: a filter is required", ErrInvalidInput)
+	}
+	return f, nil
+}
+
+// matches reports whether a secret with the given attributes passes the
+// filter. A secret without an attribute the filter names doesn't match.
+func (f publishFilter) matches(attrs map[string][]string) bool {
+	for key, patterns := range f {
+		found := false
+		for _, value := range attrs[key] {
+			for _, pattern := range patterns {
+				if ok, _ := path.Match(pattern, value); ok {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Publish updates read-only mirrors holding a filtered subset of the store,
+// re-encrypted for their own recipients. With --to it publishes once
+// (saving the target with --save); otherwise it republishes the named
+// saved targets, or all of them with --all.
+func (a *Action) Publish(c *cli.Context) error {
+	if c.String("to") != "" {
+		target := config.PublishTarget{
+			Remote:     c.String("to"),
+			Filter:     c.String("filter"),
+			Recipients: c.StringSlice("recipient"),
+			Auto:       c.Bool("auto"),
+		}
+		if err := validatePublishTarget(target); err != nil {
+			return err
+		}
+
+		name := c.String("save")
+		if name != "" {
+			if a.cfg.Publish == nil {
+				a.cfg.Publish = make(map[string]config.PublishTarget)
+			}
+			a.cfg.Publish[name] = target
+			if err := a.cfg.Save(); err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+		}
+		return a.publish(c.Context, name, target)
+	}
+
+	names := c.Args().Slice()
+	if c.Bool("all") {
+		names = sortedPublishTargets(a.cfg.Publish)
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("usage: passbook publish --to REMOTE --filter FILTER --recipient KEY [--save NAME] | NAME... | --all")
+	}
+	for _, name := range names {
+		target, ok := a.cfg.Publish[name]
+		if !ok {
+			return fmt.Errorf("publish target %s %w", name, ErrNotFound)
+		}
+		if err := a.publish(c.Context, name, target); err != nil {
+			return fmt.Errorf("failed to publish %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// PublishList lists the saved publish targets
+func (a *Action) PublishList(c *cli.Context) error {
+	if len(a.cfg.Publish) == 0 {
+		fmt.Println("No publish targets. Save one with 'passbook publish --save NAME'.")
+		return nil
+	}
+
+	ui.Heading("Publish targets")
+	table := ui.NewTable("NAME", "REMOTE", "FILTER", "RECIPIENTS", "AUTO")
+	for _, name := range sortedPublishTargets(a.cfg.Publish) {
+		t := a.cfg.Publish[name]
+		auto := ""
+		if t.Auto {
+			auto = "yes"
+		}
+		table.Row(name, t.Remote, t.Filter, fmt.Sprintf("%d", len(t.Recipients)), auto)
+	}
+	table.Print()
+	return nil
+}
+
+// PublishRemove forgets a saved publish target and its local checkout. The
+// remote repo is left as it is.
+func (a *Action) PublishRemove(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook publish rm NAME")
+	}
+	name := c.Args().First()
+	if _, ok := a.cfg.Publish[name]; !ok {
+		return fmt.Errorf("publish target %s %w", name, ErrNotFound)
+	}
+
+	delete(a.cfg.Publish, name)
+	if err := a.cfg.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	if err := os.RemoveAll(a.publishCheckout(name)); err != nil {
+		ui.Warningf("failed to remove checkout: %v", err)
+	}
+
+	ui.Successf("Removed publish target %s", name)
+	fmt.Println("The published repo still holds the last publish; delete it on the remote if it's no longer needed.")
+	return nil
+}
+
+// publishAuto republishes the targets marked auto after a change to the
+// store. Failures are only warnings: the store change already happened.
+func (a *Action) publishAuto(ctx context.Context) {
+	for _, name := range sortedPublishTargets(a.cfg.Publish) {
+		target := a.cfg.Publish[name]
+		if !target.Auto {
+			continue
+		}
+		if err := a.publish(ctx, name, target); err != nil {
+			ui.Warningf("failed to publish %s: %v", name, err)
+		}
+	}
+}
+
+// validatePublishTarget checks a target before anything is decrypted
+func validatePublishTarget(t config.PublishTarget) error {
+	if _, err := parsePublishFilter(t.Filter); err != nil {
+		return err
+	}
+	if len(t.Recipients) == 0 {
+		return fmt.Errorf("%w: at least one --recipient is required", ErrInvalidInput)
+	}
+	for _, key := range t.Recipients {
+		if !age.ValidatePublicKey(key) {
+			return fmt.Errorf("%w: invalid recipient %q (should start with 'age1')", ErrInvalidInput, key)
+		}
+	}
+	return nil
+}
+
+// publish writes the secrets the current user can read that match the
+// target's filter to its repo and pushes it. Unnamed targets are published
+// from a temporary checkout.
+func (a *Action) publish(ctx context.Context, name string, target config.PublishTarget) error {
+	filter, err := parsePublishFilter(target.Filter)
+	if err != nil {
+		return err
+	}
+	user, err := a.authorize(rbac.PermCredentialsRead)
+	if err != nil {
+		return err
+	}
+
+	files, hasProd, err := a.publishFiles(ctx, user, filter)
+	if err != nil {
+		return err
+	}
+	if hasProd {
+		if err := a.stepUp("publish"); err != nil {
+			return err
+		}
+	}
+
+	// Check out the mirror
+	dir := a.publishCheckout(name)
+	if name == "" {
+		tmp, err := os.MkdirTemp("", "passbook-publish-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		dir = filepath.Join(tmp, "mirror")
+	}
+	repo, err := openPublishCheckout(ctx, dir, target.Remote)
+	if err != nil {
+		return err
+	}
+
+	// Hash each file with its recipients, so a change to either is published
+	recipients := slices.Clone(target.Recipients)
+	sort.Strings(recipients)
+	statePath := filepath.Join(dir, ".git", publishStateFile)
+	previous := make(map[string]string)
+	if data, err := os.ReadFile(statePath); err == nil {
+		_ = json.Unmarshal(data, &previous)
+	}
+	state := make(map[string]string, len(files))
+
+	crypto := age.NewWithoutIdentity()
+	for file, plaintext := range files {
+		sum := sha256.Sum256(append(append([]byte{}, plaintext...), strings.Join(recipients, "\n")...))
+		state[file] = hex.EncodeToString(sum[:])
+		if previous[file] == state[file] && repo.Exists(ctx, file) {
+			continue
+		}
+		err := repo.SetStream(ctx, file, func(w io.Writer) error {
+			return crypto.EncryptStream(ctx, bytes.NewReader(plaintext), w, recipients)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", file, err)
+		}
+	}
+
+	// Remove secrets that no longer match or exist
+	for _, dir := range []string{"credentials", "projects"} {
+		existing, _ := repo.List(ctx, dir)
+		for _, file := range existing {
+			if _, ok := files[file]; !ok {
+				if err := repo.Delete(ctx, file); err != nil {
+					return fmt.Errorf("failed to remove %s: %w", file, err)
+				}
+			}
+		}
+	}
+	if err := repo.Set(ctx, "README.md", []byte(publishReadme)); err != nil {
+		return err
+	}
+
+	if err := repo.Commit(ctx, fmt.Sprintf("Publish %d secrets", len(files))); err != nil {
+		return fmt.Errorf("commit failed: %w", err)
+	}
+	if branch, err := repo.GetCurrentBranch(); err == nil && branch != "" {
+		repo.SetBranch(branch)
+	}
+	if err := repo.Push(ctx); err != nil {
+		return fmt.Errorf("push failed: %w", err)
+	}
+
+	data, _ := json.Marshal(state)
+	if err := os.WriteFile(statePath, data, 0600); err != nil {
+		ui.Warningf("failed to save publish state: %v", err)
+	}
+
+	label := name
+	if label == "" {
+		label = target.Remote
+	}
+	a.logAudit(audit.EventStorePublished, label, "remote", target.Remote, "filter", target.Filter, "recipients", fmt.Sprintf("%d", len(recipients)), "entries", fmt.Sprintf("%d", len(files)))
+	ui.Successf("Published %d secrets to %s", len(files), target.Remote)
+	return nil
+}
+
+// publishFiles returns the mirror's files, path to plaintext, for the
+// secrets matching filter that user can read, and whether any is a prod
+// env file
+func (a *Action) publishFiles(ctx context.Context, user *models.User, filter publishFilter) (map[string][]byte, bool, error) {
+	s, err := a.openStore()
+	if err != nil {
+		return nil, false, err
+	}
+	files := make(map[string][]byte)
+	hasProd := false
+
+	creds, err := s.ListCredentials(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list credentials: %w", err)
+	}
+	for _, summary := range creds {
+		attrs := map[string][]string{"kind": {"credential"}, "website": {summary.Website}, "name": {summary.Name}, "tag": summary.Tags}
+		if !filter.matches(attrs) {
+			continue
+		}
+		cred, err := s.GetCredential(ctx, summary.Website, summary.Name)
+		if err != nil || !cred.CanUserRead(user.Email) {
+			continue
+		}
+
+		// Who can read it in the store is no business of the mirror's
+		cred.Permissions = nil
+		data, err := models.MarshalCanonical(cred)
+		if err != nil {
+			return nil, false, err
+		}
+		files[cred.FullPath()] = data
+	}
+
+	projects, err := s.ListProjects(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list projects: %w", err)
+	}
+	for _, p := range projects {
+		stages, _ := s.ListEnvStages(ctx, p.Name)
+		for _, stage := range stages {
+			attrs := map[string][]string{"kind": {"env"}, "project": {p.Name}, "stage": {string(stage)}}
+			if !filter.matches(attrs) || !a.policy().CanAccessStage(user, stage, false) {
+				continue
+			}
+			envFile, err := s.GetEnvFile(ctx, p.Name, stage)
+			if err != nil || !envFile.CanUserRead(user.Email) {
+				continue
+			}
+			data, err := envformat.Encode("dotenv", envFile, "")
+			if err != nil {
+				return nil, false, err
+			}
+			files[envFile.FullPath()] = data
+			hasProd = hasProd || stage == models.StageProd
+		}
+	}
+
+	return files, hasProd, nil
+}
+
+// publishCheckout is where a saved target's repo is checked out
+func (a *Action) publishCheckout(name string) string {
+	return filepath.Join(a.cfg.ConfigDir, "publish", name)
+}
+
+// openPublishCheckout opens the checkout of a mirror at dir, cloning it
+// first if needed, and brings it up to date with the remote
+func openPublishCheckout(ctx context.Context, dir, remote string) (*gitfs.Git, error) {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		if err := os.MkdirAll(filepath.Dir(dir), 0700); err != nil {
+			return nil, err
+		}
+		return gitfs.Clone(remote, dir)
+	}
+
+	repo, err := gitfs.New(dir)
+	if err != nil {
+		return nil, err
+	}
+	if branch, err := repo.GetCurrentBranch(); err == nil && branch != "" {
+		repo.SetBranch(branch)
+	}
+	// A newly created remote has nothing to pull yet
+	if err := repo.Pull(ctx); err != nil && !strings.Contains(err.Error(), "couldn't find remote ref") {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// sortedPublishTargets returns the names of targets in order
+func sortedPublishTargets(targets map[string]config.PublishTarget) []string {
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
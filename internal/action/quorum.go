@@ -0,0 +1,89 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/models"
+	"passbook/pkg/ui"
+)
+
+// recommendedAdmins is the number of active admins below which the store
+// is one lost key away from lockout
+const recommendedAdmins = 2
+
+// errNoAdminQuorum is returned when a change would leave no active admin
+var errNoAdminQuorum = errors.New("this change would leave the store without a verified admin holding a valid key")
+
+// activeAdmins returns admins who can actually manage the team: verified,
+// with a public key, and not restricted to read-only
+func activeAdmins(users []models.User) []models.User {
+	var admins []models.User
+	for _, u := range users {
+		if u.IsAdmin() && u.PublicKey != "" && !u.IsPendingVerification() && !u.IsReadOnly() {
+			admins = append(admins, u)
+		}
+	}
+	return admins
+}
+
+// checkAdminQuorum rejects a resulting user list that has no active admin
+func checkAdminQuorum(users []models.User) error {
+	if len(activeAdmins(users)) == 0 {
+		return fmt.Errorf("%w; grant admin to another member first", errNoAdminQuorum)
+	}
+	return nil
+}
+
+// TeamAdmins shows admins and the health of the admin quorum
+func (a *Action) TeamAdmins(c *cli.Context) error {
+	userList, err := a.loadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+
+	ui.Heading("Admins")
+	fmt.Println()
+
+	admins := ui.NewTable()
+	for _, u := range userList.Users {
+		if !u.HasRole(models.RoleAdmin) {
+			continue
+		}
+
+		state := "active"
+		switch {
+		case u.IsServiceAccount():
+			state = "ignored (service account)"
+		case u.PublicKey == "":
+			state = "inactive (no public key)"
+		case u.IsPendingVerification():
+			state = "inactive (pending verification)"
+		case u.IsReadOnly():
+			state = "inactive (viewer role)"
+		}
+		admins.Row(u.Email, state)
+	}
+	admins.Print()
+
+	active := len(activeAdmins(userList.Users))
+	fmt.Println()
+	fmt.Printf("Active admins: %d\n", active)
+	fmt.Println(quorumHealth(active))
+
+	return nil
+}
+
+// quorumHealth describes the admin quorum for a count of active admins
+func quorumHealth(active int) string {
+	switch {
+	case active == 0:
+		return ui.Fail("✗ CRITICAL: no active admin can manage the team")
+	case active < recommendedAdmins:
+		return ui.Warn(fmt.Sprintf("! At risk: fewer than %d active admins; losing one key locks out team management", recommendedAdmins))
+	default:
+		return ui.Success("✓ Healthy")
+	}
+}
@@ -0,0 +1,146 @@
+package action
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// readCommands never modify the store or local state
+var readCommands = map[string]bool{
+	"status":               true,
+	"stats":                true,
+	"doctor":               true,
+	"metrics show":         true,
+	"whoami":               true,
+	"can":                  true,
+	"config list":          true,
+	"config get":           true,
+	"auth-status":          true,
+	"cert list":            true,
+	"export pass":          true,
+	"publish list":         true,
+	"cert show":            true,
+	"note list":            true,
+	"note show":            true,
+	"personal list":        true,
+	"ssh list":             true,
+	"ssh load":             true,
+	"personal show":        true,
+	"cred list":            true,
+	"cred show":            true,
+	"cred log":             true,
+	"cred find-for":        true,
+	"cred copy":            true,
+	"cred type":            true,
+	"generate":             true,
+	"clipboard-clear":      true,
+	"cred access list":     true,
+	"env list":             true,
+	"env show":             true,
+	"env log":              true,
+	"env export":           true,
+	"env exec":             true,
+	"env watch":            true,
+	"env serve":            true,
+	"env shellenv":         true,
+	"env check":            true,
+	"env access list":      true,
+	"project list":         true,
+	"project schema show":  true,
+	"team list":            true,
+	"team roles":           true,
+	"team admins":          true,
+	"team pending":         true,
+	"service-account list": true,
+	"token list":           true,
+	"key show":             true,
+	"key fingerprint":      true,
+	"key qr":               true,
+	"verify-key":           true,
+	"verify":               true,
+	"hooks check":          true,
+	"plugins list":         true,
+	"scan":                 true,
+	"render":               true,
+	"audit log":            true,
+	"audit stats":          true,
+	"audit stale":          true,
+	"audit evidence":       true,
+	"campaign status":      true,
+	"campaign list":        true,
+	"hold list":            true,
+	"layout":               true,
+	"review list":          true,
+	"review show":          true,
+	"rotate help":          true,
+	"rotate exposed":       true,
+	"sync":                 true,
+	"fetch":                true,
+	"gc":                   true,
+}
+
+// localCommands only change the user's own machine, so viewers may run them;
+// they are still blocked in read-only mode
+var localCommands = map[string]bool{
+	"init":                  true,
+	"clone":                 true,
+	"reclone":               true,
+	"provision":             true,
+	"setup":                 true,
+	"join":                  true,
+	"login":                 true,
+	"logout":                true,
+	"key import":            true,
+	"key encrypt":           true,
+	"key decrypt":           true,
+	"key change-passphrase": true,
+	"key wrap":              true,
+	"key unwrap":            true,
+	"key hsm":               true,
+	"hooks install":         true,
+	"config set":            true,
+	"config edit":           true,
+	"metrics reset":         true,
+	"publish rm":            true,
+}
+
+// guardWrites wraps every command that isn't known to be read-only so
+// writes are rejected in one place. New commands are treated as writes
+// until they are added to readCommands.
+func (a *Action) guardWrites(commands []*cli.Command, parent string) {
+	for _, cmd := range commands {
+		path := strings.TrimSpace(parent + " " + cmd.Name)
+		if len(cmd.Subcommands) > 0 {
+			a.guardWrites(cmd.Subcommands, path)
+		}
+		if cmd.Action == nil || readCommands[path] {
+			continue
+		}
+
+		action := cmd.Action
+		local := localCommands[path]
+		cmd.Action = func(c *cli.Context) error {
+			if err := a.requireWritable(c, local); err != nil {
+				return err
+			}
+			return action(c)
+		}
+	}
+}
+
+// requireWritable returns ErrReadOnly if the store is in read-only mode or
+// the current user has the viewer role
+func (a *Action) requireWritable(c *cli.Context, local bool) error {
+	if a.cfg.ReadOnly || c.Bool("read-only") {
+		return fmt.Errorf("%w (read-only mode is enabled)", ErrReadOnly)
+	}
+	if local {
+		return nil
+	}
+	if user, err := a.getCurrentUser(); err == nil && user.IsReadOnly() {
+		return fmt.Errorf("%w (%s has the viewer role)", ErrReadOnly, user.Email)
+	}
+	return nil
+}
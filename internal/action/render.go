@@ -0,0 +1,135 @@
+package action
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/pkg/securetmp"
+	"passbook/pkg/ui"
+)
+
+// Render fills a Go template with secrets from the store, for generating
+// config files at deploy time. Templates can call:
+//
+//	{{ env "KEY" }}                    a variable from --project/--stage
+//	{{ cred "WEBSITE/NAME" "FIELD" }}  username, password, url, notes or a metadata key
+//
+// Nothing is written unless every lookup succeeds.
+func (a *Action) Render(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook render TEMPLATE [--project NAME --stage STAGE] [-o FILE]")
+	}
+
+	file := c.Args().First()
+	project := c.String("project")
+	stage := models.Stage(c.String("stage"))
+	output := c.String("output")
+
+	if (project == "") != (stage == "") {
+		return fmt.Errorf("usage: --project and --stage must be given together")
+	}
+	if stage != "" && !stage.IsValid() {
+		return fmt.Errorf("invalid stage: %s (valid: dev, staging, prod)", stage)
+	}
+
+	// Read the template, from stdin with "-"
+	var src []byte
+	var err error
+	if file == "-" {
+		src, err = io.ReadAll(os.Stdin)
+	} else {
+		src, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read template: %w", err)
+	}
+
+	// Environments and credentials are loaded on first use
+	var envFile *models.EnvFile
+	creds := make(map[string]*models.Credential)
+
+	funcs := template.FuncMap{
+		"env": func(key string) (string, error) {
+			if project == "" {
+				return "", fmt.Errorf("env %q needs --project and --stage", key)
+			}
+			if envFile == nil {
+				if _, err := a.authorize(rbac.GetStagePermission(stage, false)); err != nil {
+					return "", err
+				}
+				envFile, err = a.loadEnvFile(c.Context, project, stage)
+				if err != nil {
+					return "", fmt.Errorf("failed to load environment: %w", err)
+				}
+			}
+			value, ok := envFile.Get(key)
+			if !ok {
+				return "", fmt.Errorf("variable %s %w in %s/%s", key, ErrNotFound, project, stage)
+			}
+			return value, nil
+		},
+		"cred": func(path, field string) (string, error) {
+			cred, ok := creds[path]
+			if !ok {
+				website, name, err := parseCredentialPath(path)
+				if err != nil {
+					return "", err
+				}
+				cred, err = a.loadCredential(c.Context, website, name)
+				if err != nil {
+					return "", fmt.Errorf("failed to load credential %s: %w", path, err)
+				}
+				a.recordSensitiveAccess(c.Context, cred, "render")
+				creds[path] = cred
+			}
+			return credentialField(cred, field)
+		},
+	}
+
+	tmpl, err := template.New(filepath.Base(file)).Funcs(funcs).Option("missingkey=error").Parse(string(src))
+	if err != nil {
+		return fmt.Errorf("%w: invalid template: %w", ErrInvalidInput, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return fmt.Errorf("failed to render %s: %w", file, err)
+	}
+
+	if output == "" {
+		os.Stdout.Write(buf.Bytes())
+		return nil
+	}
+	if err := securetmp.WriteFile(output, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	ui.Successf("Rendered %s to %s", file, output)
+
+	return nil
+}
+
+// credentialField returns one field of a credential by name
+func credentialField(cred *models.Credential, field string) (string, error) {
+	switch field {
+	case "username":
+		return cred.Username, nil
+	case "password":
+		return cred.Password, nil
+	case "url":
+		return cred.URL, nil
+	case "notes":
+		return cred.Notes, nil
+	}
+	if value, ok := cred.Metadata[field]; ok {
+		return value, nil
+	}
+	return "", fmt.Errorf("field %s %w in %s/%s", field, ErrNotFound, cred.Website, cred.Name)
+}
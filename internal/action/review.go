@@ -0,0 +1,830 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/audit"
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/pkg/redact"
+	"passbook/pkg/ui"
+)
+
+// proposalBranchPrefix names the branches proposed changes wait on, like
+// proposal/3f9c2a1b
+const proposalBranchPrefix = "proposal/"
+
+// proposal is a change to one secret waiting on its own branch for another
+// member to approve it
+type proposal struct {
+	ID         string
+	Ref        string // Branch or remote-tracking branch holding the change
+	Message    string
+	Path       string // The changed file, relative to the store
+	ProposedBy string
+	Signature  string // ProposedBy's signature of the proposal
+	At         time.Time
+
+	// Unverified says why the proposal's signature doesn't show that
+	// ProposedBy made it, or is nil if it does
+	Unverified error
+}
+
+// proposalStatement is what a proposer signs: the proposal, the contents it
+// proposes for the file (as a git object ID, empty for a deletion), and who
+// proposes it. Signing it keeps anyone else from claiming the proposal.
+func proposalStatement(id, rel, blob, message, proposedBy string) []byte {
+	return []byte(strings.Join([]string{"passbook proposal v1", id, rel, blob, message, strings.ToLower(proposedBy)}, "\x00"))
+}
+
+// reviewField is one reviewable value of a secret
+type reviewField struct {
+	Value  string
+	Secret bool
+}
+
+// envNeedsReview reports whether writes to a stage must be proposed
+func (a *Action) envNeedsReview(stage models.Stage) bool {
+	return a.cfg.Review.Prod && stage == models.StageProd
+}
+
+// credNeedsReview reports whether edits to a credential must be proposed
+func (a *Action) credNeedsReview(cred *models.Credential) bool {
+	return a.cfg.Review.Prod && slices.Contains(cred.Tags, string(models.StageProd))
+}
+
+// propose moves the uncommitted change to rel onto a new proposal branch,
+// restores rel in the working tree, and pushes the branch so others can
+// review it. It returns the proposal's ID.
+func (a *Action) propose(ctx context.Context, rel, message string) (string, error) {
+	currentUser, err := a.getCurrentUser()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current user: %w", err)
+	}
+
+	idBytes := make([]byte, 4)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", fmt.Errorf("failed to generate proposal ID: %w", err)
+	}
+	id := hex.EncodeToString(idBytes)
+	branch := proposalBranchPrefix + id
+	rel = filepath.ToSlash(rel)
+
+	base := a.proposalBase(rel)
+
+	// The proposal is signed so reviewers know who made it, and the
+	// proposer's signing key is recorded for them to check it against
+	crypto, err := a.crypto()
+	if err != nil {
+		return "", err
+	}
+	key, err := crypto.SigningKey()
+	if err != nil {
+		return "", fmt.Errorf("failed to sign proposal: %w", err)
+	}
+	blob, err := gitHashFile(a.cfg.StorePath, rel)
+	if err != nil {
+		return "", err
+	}
+	summary, _, _ := strings.Cut(message, "\n")
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, proposalStatement(id, rel, blob, summary, currentUser.Email)))
+
+	body := fmt.Sprintf("%s\n\nProposed-By: %s\nProposal-Path: %s\nProposal-Signature: %s\n", message, currentUser.Email, rel, signature)
+	if _, err := gitCommitPaths(a.cfg.StorePath, branch, base, []string{rel}, body); err != nil {
+		return "", fmt.Errorf("failed to record proposal: %w", err)
+	}
+	if err := gitRestorePath(a.cfg.StorePath, base, rel); err != nil {
+		return "", fmt.Errorf("failed to restore %s: %w", rel, err)
+	}
+
+	userList, err := a.loadUsers()
+	if err != nil {
+		_ = gitDeleteBranch(a.cfg.StorePath, branch)
+		return "", fmt.Errorf("failed to load users: %w", err)
+	}
+	recorded, err := a.recordSigningKey(userList, currentUser.Email, age.EncodeSigningKey(key.Public().(ed25519.PublicKey)))
+	if err != nil {
+		_ = gitDeleteBranch(a.cfg.StorePath, branch)
+		return "", err
+	}
+	if recorded {
+		if err := a.GitCommitAndSync(ctx, "Record signing key for "+currentUser.Email); err != nil {
+			ui.Warningf("%v", err)
+		}
+	}
+
+	a.logAudit(audit.EventProposalCreated, rel, "id", id)
+
+	if a.storeRemoteURL() != "" {
+		if err := gitPushBranch(ctx, a.cfg.StorePath, branch); err != nil {
+			ui.Warningf("proposal %s is saved locally but was not pushed: %v", id, err)
+			return id, nil
+		}
+		// Once pushed, the remote copy is the one reviewers see
+		_ = gitDeleteBranch(a.cfg.StorePath, branch)
+	}
+	return id, nil
+}
+
+// proposalBase returns the branch a proposal to change rel merges into:
+// the prod branch for prod env files in that layout, else the current one
+func (a *Action) proposalBase(rel string) string {
+	if a.onProdBranch(rel) {
+		return a.cfg.Git.ProdBranch
+	}
+	return "HEAD"
+}
+
+// proposeEnvChange proposes the saved change to a stage's env file instead
+// of committing it. --no-commit doesn't apply; each proposal stands alone.
+func (a *Action) proposeEnvChange(c *cli.Context, project string, stage models.Stage, message string) error {
+	rel := fmt.Sprintf("projects/%s/%s.env.age", project, stage)
+	id, err := a.propose(c.Context, rel, message)
+	if err != nil {
+		return err
+	}
+	printProposed(id, message)
+	return nil
+}
+
+// printProposed tells the user their change is waiting for review
+func printProposed(id, change string) {
+	ui.Successf("Proposed for review: %s", change)
+	fmt.Printf("It takes effect once another member approves it with 'passbook review approve %s'\n", id)
+}
+
+// ReviewList shows the proposals waiting for review
+func (a *Action) ReviewList(c *cli.Context) error {
+	proposals, err := a.loadProposals(c.Context)
+	if err != nil {
+		return err
+	}
+
+	if len(proposals) == 0 {
+		fmt.Println("No proposals are waiting for review.")
+		return nil
+	}
+
+	ui.Heading("Proposals")
+	fmt.Println()
+
+	table := ui.NewTable("ID", "PROPOSED", "CHANGE")
+	for _, p := range proposals {
+		proposed := fmt.Sprintf("%s by %s", p.At.Local().Format("2006-01-02 15:04"), p.ProposedBy)
+		if p.Unverified != nil {
+			proposed += " " + ui.Fail("(unverified)")
+		}
+		table.Row(p.ID, proposed, p.Message)
+	}
+	table.Print()
+
+	fmt.Println()
+	fmt.Println("Review a change with: passbook review show ID")
+
+	return nil
+}
+
+// ReviewShow shows a proposal's change with secret values masked
+func (a *Action) ReviewShow(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook review show ID")
+	}
+
+	p, err := a.findProposal(c.Context, c.Args().First())
+	if err != nil {
+		return err
+	}
+	if _, err := a.authorizeProposal(p, false); err != nil {
+		return err
+	}
+
+	before, after, err := a.proposalFields(c.Context, p)
+	if err != nil {
+		return err
+	}
+
+	ui.Heading("Proposal " + p.ID)
+	fmt.Println()
+	fmt.Printf("  Change:   %s\n", p.Message)
+	fmt.Printf("  File:     %s\n", p.Path)
+	fmt.Printf("  Proposed: %s by %s\n", p.At.Local().Format("2006-01-02 15:04"), p.ProposedBy)
+	if p.Unverified != nil {
+		fmt.Printf("  %s %v; it can't be approved\n", ui.Fail("✗"), p.Unverified)
+	}
+	fmt.Println()
+	printReviewDiff(before, after)
+	fmt.Println()
+	fmt.Printf("Approve with: passbook review approve %s\n", p.ID)
+
+	return nil
+}
+
+// ReviewApprove merges a proposal another member made into the store
+func (a *Action) ReviewApprove(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook review approve ID")
+	}
+
+	p, err := a.findProposal(c.Context, c.Args().First())
+	if err != nil {
+		return err
+	}
+	currentUser, err := a.authorizeProposal(p, true)
+	if err != nil {
+		return err
+	}
+	if p.Unverified != nil {
+		return fmt.Errorf("%w: %v; reject it", ErrAccessDenied, p.Unverified)
+	}
+	if strings.EqualFold(currentUser.Email, p.ProposedBy) {
+		return fmt.Errorf("%w: you can't approve your own proposal; ask another member to review it", ErrAccessDenied)
+	}
+
+	// A proposal may only change the file it names, so approving it can't
+	// slip in other changes
+	base := a.proposalBase(p.Path)
+	changed, err := gitChangedPaths(a.cfg.StorePath, base, p.Ref)
+	if err != nil {
+		return err
+	}
+	if len(changed) != 1 || changed[0] != p.Path {
+		return fmt.Errorf("%w: proposal %s changes files other than %s; reject it", ErrAccessDenied, p.ID, p.Path)
+	}
+
+	// The prod branch is never checked out, so its proposals can only
+	// fast-forward it
+	if base != "HEAD" {
+		err = gitFastForward(a.cfg.StorePath, base, p.Ref)
+		if err == nil {
+			err = a.checkoutProdBranch()
+		}
+	} else {
+		err = gitMergeNoCommit(a.cfg.StorePath, p.Ref)
+	}
+	if errors.Is(err, errMergeConflict) {
+		return fmt.Errorf("%s changed since proposal %s was made; reject it and propose the change again", p.Path, p.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to merge proposal %s: %w", p.ID, err)
+	}
+
+	a.logAudit(audit.EventProposalApproved, p.Path, "id", p.ID, "proposed_by", p.ProposedBy)
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("%s (proposal %s by %s, approved by %s)", p.Message, p.ID, p.ProposedBy, currentUser.Email)); err != nil {
+		ui.Warningf("%v", err)
+	}
+	if err := a.deleteProposal(c.Context, p); err != nil {
+		ui.Warningf("failed to delete proposal branch: %v", err)
+	}
+
+	ui.Successf("Approved proposal %s: %s", p.ID, p.Message)
+
+	return nil
+}
+
+// ReviewReject discards a proposal
+func (a *Action) ReviewReject(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook review reject ID")
+	}
+
+	p, err := a.findProposal(c.Context, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	// Proposers can withdraw their own proposals
+	currentUser, err := a.getCurrentUser()
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	if p.Unverified != nil || !strings.EqualFold(currentUser.Email, p.ProposedBy) {
+		if _, err := a.authorizeProposal(p, true); err != nil {
+			return err
+		}
+	}
+
+	if err := a.deleteProposal(c.Context, p); err != nil {
+		return err
+	}
+
+	details := []string{"id", p.ID, "proposed_by", p.ProposedBy}
+	if reason := c.String("reason"); reason != "" {
+		details = append(details, "reason", reason)
+	}
+	a.logAudit(audit.EventProposalRejected, p.Path, details...)
+
+	ui.Successf("Rejected proposal %s: %s", p.ID, p.Message)
+
+	return nil
+}
+
+// authorizeProposal checks that the current user may read, or with write
+// also change, the file a proposal changes
+func (a *Action) authorizeProposal(p *proposal, write bool) (*models.User, error) {
+	if project, stage, ok := envPathParts(p.Path); ok {
+		if write {
+			return a.authorizeEnvAccess(project, stage)
+		}
+		return a.authorize(rbac.GetStagePermission(stage, false))
+	}
+	if write {
+		return a.authorize(rbac.PermCredentialsWrite)
+	}
+	return a.authorize(rbac.PermCredentialsRead)
+}
+
+// proposalFields decrypts the proposed file as it was when proposed and
+// with the change, as reviewable fields
+func (a *Action) proposalFields(ctx context.Context, p *proposal) (before, after map[string]reviewField, err error) {
+	s, err := a.openStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	base, err := gitMergeBase(a.cfg.StorePath, a.proposalBase(p.Path), p.Ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fields := func(rev string) (map[string]reviewField, error) {
+		if _, _, ok := envPathParts(p.Path); ok {
+			envFile, err := s.GetEnvFileAtRevision(ctx, rev, p.Path)
+			if errors.Is(err, os.ErrNotExist) {
+				return map[string]reviewField{}, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			return envReviewFields(envFile), nil
+		}
+		cred, err := s.GetCredentialAtRevision(ctx, rev, p.Path)
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]reviewField{}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return credentialReviewFields(cred), nil
+	}
+
+	if before, err = fields(base); err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s before the change: %w", p.Path, err)
+	}
+	if after, err = fields(p.Ref); err != nil {
+		return nil, nil, fmt.Errorf("failed to read the proposed %s: %w", p.Path, err)
+	}
+	return before, after, nil
+}
+
+// envReviewFields returns an env file's variables as reviewable fields
+func envReviewFields(envFile *models.EnvFile) map[string]reviewField {
+	fields := make(map[string]reviewField, len(envFile.Vars))
+	for _, v := range envFile.Vars {
+		fields[v.Key] = reviewField{Value: v.Value, Secret: v.IsSecret}
+	}
+	return fields
+}
+
+// credentialReviewFields returns a credential's fields as reviewable fields.
+// The password, notes and metadata are secret.
+func credentialReviewFields(cred *models.Credential) map[string]reviewField {
+	fields := map[string]reviewField{
+		"username":  {Value: cred.Username},
+		"password":  {Value: cred.Password, Secret: true},
+		"url":       {Value: cred.URL},
+		"notes":     {Value: cred.Notes, Secret: true},
+		"tags":      {Value: strings.Join(cred.Tags, ", ")},
+		"sensitive": {Value: strconv.FormatBool(cred.Sensitive)},
+	}
+	for k, v := range cred.Metadata {
+		fields["metadata."+k] = reviewField{Value: v, Secret: true}
+	}
+	for name, f := range fields {
+		if f.Value == "" {
+			delete(fields, name)
+		}
+	}
+	return fields
+}
+
+// printReviewDiff prints what changed between two sets of fields, masking
+// secret values
+func printReviewDiff(before, after map[string]reviewField) {
+	names := make([]string, 0, len(before)+len(after))
+	for name := range before {
+		names = append(names, name)
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	show := func(f reviewField) string {
+		if f.Secret {
+			return redact.Mask
+		}
+		return f.Value
+	}
+
+	changes := 0
+	for _, name := range names {
+		old, hadOld := before[name]
+		cur, hasCur := after[name]
+		switch {
+		case !hadOld:
+			fmt.Printf("  %s %s = %s\n", ui.Success("+"), name, show(cur))
+		case !hasCur:
+			fmt.Printf("  %s %s\n", ui.Fail("-"), name)
+		case old != cur:
+			change := fmt.Sprintf("%s → %s", show(old), show(cur))
+			if old.Secret && cur.Secret {
+				change = redact.Mask + " (changed)"
+			}
+			if old.Value == cur.Value {
+				change = "now secret"
+				if !cur.Secret {
+					change = "now public: " + cur.Value
+				}
+			}
+			fmt.Printf("  %s %s: %s\n", ui.Warn("~"), name, change)
+		default:
+			continue
+		}
+		changes++
+	}
+	if changes == 0 {
+		fmt.Println(ui.Muted("  No changes"))
+	}
+}
+
+// envPathParts splits an env file path like projects/api/prod.env.age
+func envPathParts(rel string) (string, models.Stage, bool) {
+	parts := strings.Split(rel, "/")
+	if len(parts) != 3 || parts[0] != "projects" || !strings.HasSuffix(parts[2], ".env.age") {
+		return "", "", false
+	}
+	stage := models.Stage(strings.TrimSuffix(parts[2], ".env.age"))
+	return parts[1], stage, stage.IsValid()
+}
+
+// loadProposals fetches proposals from the remote, if there is one, and
+// returns every proposal waiting for review, oldest first
+func (a *Action) loadProposals(ctx context.Context) ([]*proposal, error) {
+	if a.storeRemoteURL() != "" {
+		if err := gitFetchProposals(ctx, a.cfg.StorePath); err != nil {
+			ui.Warningf("failed to fetch proposals, showing local ones: %v", err)
+		}
+	}
+
+	refs, err := gitProposalRefs(a.cfg.StorePath)
+	if err != nil {
+		return nil, err
+	}
+	userList, err := a.loadUsers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load users: %w", err)
+	}
+
+	// A local branch is one that couldn't be pushed, so it's the newest copy
+	byID := make(map[string]*proposal)
+	for _, ref := range refs {
+		id := ref[strings.LastIndex(ref, "/")+1:]
+		if existing, ok := byID[id]; ok && !strings.HasPrefix(existing.Ref, "origin/") {
+			continue
+		}
+		p, err := readProposal(a.cfg.StorePath, id, ref)
+		if err != nil {
+			return nil, err
+		}
+		p.Unverified = verifyProposal(a.cfg.StorePath, p, userList.Users)
+		byID[id] = p
+	}
+
+	proposals := make([]*proposal, 0, len(byID))
+	for _, p := range byID {
+		proposals = append(proposals, p)
+	}
+	sort.Slice(proposals, func(i, j int) bool { return proposals[i].At.Before(proposals[j].At) })
+	return proposals, nil
+}
+
+// findProposal returns the proposal with an ID
+func (a *Action) findProposal(ctx context.Context, id string) (*proposal, error) {
+	proposals, err := a.loadProposals(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range proposals {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("proposal %s %w", id, ErrNotFound)
+}
+
+// deleteProposal removes a proposal's branches, locally and on the remote
+func (a *Action) deleteProposal(ctx context.Context, p *proposal) error {
+	branch := proposalBranchPrefix + p.ID
+	_ = gitDeleteBranch(a.cfg.StorePath, branch)
+	if strings.HasPrefix(p.Ref, "origin/") {
+		if err := gitDeleteRemoteBranch(ctx, a.cfg.StorePath, branch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readProposal reads a proposal's details from the commit on its branch
+func readProposal(path, id, ref string) (*proposal, error) {
+	out, err := exec.Command("git", "-C", path, "log", "-1", "--format=%ct%n%B", ref).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read proposal %s: %w", id, err)
+	}
+
+	p := &proposal{ID: id, Ref: ref}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if ts, err := strconv.ParseInt(lines[0], 10, 64); err == nil {
+		p.At = time.Unix(ts, 0)
+	}
+	if len(lines) > 1 {
+		p.Message = lines[1]
+	}
+	for _, line := range lines[1:] {
+		if v, ok := strings.CutPrefix(line, "Proposed-By: "); ok {
+			p.ProposedBy = v
+		}
+		if v, ok := strings.CutPrefix(line, "Proposal-Path: "); ok {
+			p.Path = v
+		}
+		if v, ok := strings.CutPrefix(line, "Proposal-Signature: "); ok {
+			p.Signature = v
+		}
+	}
+	if p.Path == "" || p.ProposedBy == "" {
+		return nil, fmt.Errorf("branch %s is not a passbook proposal", ref)
+	}
+	return p, nil
+}
+
+// verifyProposal checks a proposal's signature against the signing key
+// recorded for the member it names, since the Proposed-By trailer is only
+// text anyone pushing a branch can write. It returns why the proposal
+// isn't verified, or nil.
+func verifyProposal(path string, p *proposal, users []models.User) error {
+	var proposer *models.User
+	for i := range users {
+		if strings.EqualFold(users[i].Email, p.ProposedBy) {
+			proposer = &users[i]
+			break
+		}
+	}
+	if proposer == nil {
+		return fmt.Errorf("proposal %s names %s, who isn't a member", p.ID, p.ProposedBy)
+	}
+	if p.Signature == "" {
+		return fmt.Errorf("proposal %s is not signed", p.ID)
+	}
+	key := proposer.Metadata[signingKeyMetadata]
+	if key == "" {
+		return fmt.Errorf("%s has no signing key recorded", proposer.Email)
+	}
+	blob, err := gitBlobAt(path, p.Ref, p.Path)
+	if err != nil {
+		return err
+	}
+	if err := age.VerifySignature(key, proposalStatement(p.ID, p.Path, blob, p.Message, p.ProposedBy), p.Signature); err != nil {
+		return fmt.Errorf("proposal %s is not signed by %s", p.ID, proposer.Email)
+	}
+	return nil
+}
+
+// gitHashFile returns the git object ID a file in the working tree would
+// be committed as, or "" if it doesn't exist
+func gitHashFile(path, rel string) (string, error) {
+	if _, err := os.Stat(filepath.Join(path, filepath.FromSlash(rel))); errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	out, err := exec.Command("git", "-C", path, "hash-object", "--", rel).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", rel, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gitBlobAt returns the git object ID of a file at a revision, or "" if
+// the revision has no such file
+func gitBlobAt(path, rev, rel string) (string, error) {
+	out, err := exec.Command("git", "-C", path, "rev-parse", "--verify", "-q", rev+":"+rel).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s at %s: %w", rel, rev, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gitCommitPaths commits the working tree's copies of files on top of
+// parent as branch, using a scratch index so nothing else is staged or
+// committed. Files missing from the working tree are deleted, and ignored
+// files are included. An empty parent starts a new history. It reports
+// whether the commit changed anything.
+func gitCommitPaths(path, branch, parent string, files []string, message string) (bool, error) {
+	dir, err := os.MkdirTemp("", "passbook-index-")
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(dir)
+
+	run := func(stdin string, args ...string) (string, error) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = path
+		cmd.Env = append(os.Environ(), "GIT_INDEX_FILE="+filepath.Join(dir, "index"))
+		cmd.Stdin = strings.NewReader(stdin)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+
+	commitArgs := []string{"commit-tree"}
+	if parent == "" {
+		_, err = run("", "read-tree", "--empty")
+	} else {
+		_, err = run("", "read-tree", parent)
+		commitArgs = []string{"commit-tree", "-p", parent}
+	}
+	if err != nil {
+		return false, err
+	}
+	if len(files) > 0 {
+		if _, err := run("", append([]string{"add", "-A", "-f", "--"}, files...)...); err != nil {
+			return false, err
+		}
+	}
+	tree, err := run("", "write-tree")
+	if err != nil {
+		return false, err
+	}
+	if parent != "" {
+		if parentTree, err := run("", "rev-parse", parent+"^{tree}"); err == nil && parentTree == tree {
+			return false, nil
+		}
+	}
+	commit, err := run(message, append(commitArgs, tree, "-F", "-")...)
+	if err != nil {
+		return false, err
+	}
+	if _, err := run("", "update-ref", "refs/heads/"+branch, commit); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// gitRestorePath puts rel back as it is in rev, removing it if it's new
+func gitRestorePath(path, rev, rel string) error {
+	file := filepath.Join(path, filepath.FromSlash(rel))
+	data, err := exec.Command("git", "-C", path, "cat-file", "blob", rev+":"+rel).Output()
+	if err != nil {
+		err := os.Remove(file)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(file, data, 0600)
+}
+
+// gitProposalRefs lists local and remote-tracking proposal branches
+func gitProposalRefs(path string) ([]string, error) {
+	cmd := exec.Command("git", "for-each-ref", "--format=%(refname:short)",
+		"refs/heads/"+proposalBranchPrefix, "refs/remotes/origin/"+proposalBranchPrefix)
+	cmd.Dir = path
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list proposals: %w", err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// gitFetchProposals refreshes the remote-tracking proposal branches,
+// dropping ones deleted on the remote
+func gitFetchProposals(ctx context.Context, path string) error {
+	spec := fmt.Sprintf("+refs/heads/%[1]s*:refs/remotes/origin/%[1]s*", proposalBranchPrefix)
+	cmd := exec.CommandContext(ctx, "git", "fetch", "--prune", "origin", spec)
+	cmd.Dir = path
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// gitPushBranch pushes a local branch to origin
+func gitPushBranch(ctx context.Context, path, branch string) error {
+	cmd := exec.CommandContext(ctx, "git", "push", "origin", "refs/heads/"+branch+":refs/heads/"+branch)
+	cmd.Dir = path
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// gitDeleteBranch deletes a local branch
+func gitDeleteBranch(path, branch string) error {
+	cmd := exec.Command("git", "branch", "-D", branch)
+	cmd.Dir = path
+	return cmd.Run()
+}
+
+// gitDeleteRemoteBranch deletes a branch on origin
+func gitDeleteRemoteBranch(ctx context.Context, path, branch string) error {
+	cmd := exec.CommandContext(ctx, "git", "push", "origin", "--delete", branch)
+	cmd.Dir = path
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to delete %s on the remote: %s", branch, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// gitMergeBase returns the commit of base a branch was proposed on top of
+func gitMergeBase(path, base, ref string) (string, error) {
+	cmd := exec.Command("git", "merge-base", base, ref)
+	cmd.Dir = path
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to find where %s branched off: %w", ref, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// gitChangedPaths lists the files a branch changes since it branched off base
+func gitChangedPaths(path, base, ref string) ([]string, error) {
+	cmd := exec.Command("git", "diff", "--name-only", base+"..."+ref)
+	cmd.Dir = path
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s: %w", ref, err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// errMergeConflict is returned when a proposal no longer merges cleanly
+var errMergeConflict = errors.New("merge conflict")
+
+// gitMergeNoCommit merges a branch into the working tree without
+// committing, aborting the merge if it fails
+func gitMergeNoCommit(path, ref string) error {
+	cmd := exec.Command("git", "merge", "--no-ff", "--no-commit", ref)
+	cmd.Dir = path
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+
+	abort := exec.Command("git", "merge", "--abort")
+	abort.Dir = path
+	_ = abort.Run()
+	if strings.Contains(string(output), "CONFLICT") {
+		return errMergeConflict
+	}
+	return fmt.Errorf("%s", strings.TrimSpace(string(output)))
+}
+
+// gitFastForward moves branch to ref, which must contain it, creating the
+// branch if needed
+func gitFastForward(path, branch, ref string) error {
+	if gitBranchExists(path, branch) && !gitIsAncestor(path, branch, ref) {
+		return errMergeConflict
+	}
+	cmd := exec.Command("git", "update-ref", "refs/heads/"+branch, ref)
+	cmd.Dir = path
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
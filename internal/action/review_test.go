@@ -0,0 +1,111 @@
+package action
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/models"
+)
+
+const proposedFile = "projects/web/prod.env.age"
+
+// signer is a member who can sign proposals
+type signer struct {
+	user models.User
+	key  ed25519.PrivateKey
+}
+
+func newSigner(t *testing.T, email string) signer {
+	t.Helper()
+	secretKey, _, err := age.GenerateEphemeralIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity, err := age.NewFromSecretKey(secretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := identity.SigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Email: email, Metadata: map[string]string{
+		signingKeyMetadata: age.EncodeSigningKey(key.Public().(ed25519.PublicKey)),
+	}}
+	return signer{user: user, key: key}
+}
+
+// proposeAs commits a change to proposedFile on a proposal branch the way
+// propose does, signed by by but naming proposedBy
+func proposeAs(t *testing.T, dir, id string, by signer, proposedBy string) *proposal {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(proposedFile)), []byte("new "+id), 0600); err != nil {
+		t.Fatal(err)
+	}
+	blob, err := gitHashFile(dir, proposedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(by.key, proposalStatement(id, proposedFile, blob, "Update web prod", proposedBy)))
+	body := fmt.Sprintf("Update web prod\n\nProposed-By: %s\nProposal-Path: %s\nProposal-Signature: %s\n", proposedBy, proposedFile, signature)
+	if _, err := gitCommitPaths(dir, proposalBranchPrefix+id, "HEAD", []string{proposedFile}, body); err != nil {
+		t.Fatalf("commit proposal: %v", err)
+	}
+	if err := gitRestorePath(dir, "HEAD", proposedFile); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := readProposal(dir, id, proposalBranchPrefix+id)
+	if err != nil {
+		t.Fatalf("readProposal: %v", err)
+	}
+	return p
+}
+
+func TestVerifyProposal(t *testing.T) {
+	dir, git := gitTestRepo(t)
+	writeFiles(t, dir, proposedFile)
+	git("add", "-A")
+	git("commit", "-q", "-m", "store")
+
+	alice := newSigner(t, "alice@example.com")
+	mallory := newSigner(t, "mallory@example.com")
+	users := []models.User{alice.user, mallory.user}
+
+	p := proposeAs(t, dir, "a1", alice, alice.user.Email)
+	if err := verifyProposal(dir, p, users); err != nil {
+		t.Errorf("alice's own proposal: %v", err)
+	}
+
+	// Naming another member doesn't make the proposal theirs
+	forged := proposeAs(t, dir, "f1", mallory, alice.user.Email)
+	if err := verifyProposal(dir, forged, users); err == nil {
+		t.Error("a proposal mallory signed verified as alice's")
+	}
+
+	unsigned := *p
+	unsigned.Signature = ""
+	if err := verifyProposal(dir, &unsigned, users); err == nil {
+		t.Error("an unsigned proposal verified")
+	}
+
+	// The signature covers the proposed contents
+	git("checkout", "-q", proposalBranchPrefix+"a1")
+	writeFiles(t, dir, proposedFile)
+	git("commit", "-q", "-am", "swap contents")
+	git("checkout", "-q", "-")
+	if err := verifyProposal(dir, p, users); err == nil {
+		t.Error("a proposal whose contents changed after signing verified")
+	}
+
+	noKey := alice.user
+	noKey.Metadata = nil
+	if err := verifyProposal(dir, proposeAs(t, dir, "n1", alice, alice.user.Email), []models.User{noKey}); err == nil {
+		t.Error("a proposal by a member without a recorded signing key verified")
+	}
+}
@@ -9,12 +9,12 @@ import (
 
 	"passbook/internal/audit"
 	"passbook/pkg/termio"
+	"passbook/pkg/ui"
 )
 
 // RotateSecrets provides guidance and options for rotating secrets after a security incident
 func (a *Action) RotateSecrets(c *cli.Context) error {
-	fmt.Println("Secret Rotation")
-	fmt.Println("===============")
+	ui.Heading("Secret Rotation")
 	fmt.Println()
 	fmt.Println("This command helps you rotate secrets after a security incident,")
 	fmt.Println("such as a team member leaving or a compromised key.")
@@ -44,10 +44,13 @@ func (a *Action) RotateSecrets(c *cli.Context) error {
 	fmt.Println("     - Update environment variables in production")
 	fmt.Println()
 	fmt.Println("  3. Clean git history (optional, requires force push):")
-	fmt.Println("     $ passbook rotate --clean-history")
+	fmt.Println("     $ passbook rotate help --clean-history --user EMAIL")
 	fmt.Println()
 
 	if c.Bool("clean-history") {
+		if c.String("user") != "" {
+			return a.cleanUserHistory(c)
+		}
 		return a.cleanGitHistory(c)
 	}
 
@@ -85,8 +88,7 @@ func (a *Action) rotateAfterRevoke(c *cli.Context) error {
 
 // cleanGitHistory removes old encrypted files from git history
 func (a *Action) cleanGitHistory(c *cli.Context) error {
-	fmt.Println("Git History Cleanup")
-	fmt.Println("===================")
+	ui.Heading("Git History Cleanup")
 	fmt.Println()
 	fmt.Println("WARNING: This operation rewrites git history!")
 	fmt.Println()
@@ -121,29 +123,15 @@ func (a *Action) cleanGitHistory(c *cli.Context) error {
 	}
 
 	fmt.Println()
-	fmt.Println("Running git-filter-repo to remove old .age file versions...")
-	fmt.Println()
-
-	// Run git-filter-repo to remove old versions of .age files
-	// This replaces old blob contents with empty files while keeping the tree structure
-	cmd := exec.Command("git", "-C", a.cfg.StorePath, "filter-repo",
-		"--path-glob", "*.age",
-		"--invert-paths",
-		"--force")
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		fmt.Printf("Error running git-filter-repo: %v\n", err)
-		fmt.Printf("Output: %s\n", string(output))
-		return fmt.Errorf("git history cleanup failed: %w", err)
+	// Remove every .age file from history
+	if err := a.runFilterRepo(c.Context, "removed all encrypted files", "--path-glob", "*.age", "--invert-paths"); err != nil {
+		return err
 	}
 
-	fmt.Println("Git history cleaned successfully!")
-	fmt.Println()
-	fmt.Println("IMPORTANT: You must now:")
-	fmt.Println("  1. Force push to remote:  git push --force-with-lease")
-	fmt.Println("  2. Have all team members re-clone the repository")
-	fmt.Println("  3. Re-add all secrets:    passbook reencrypt")
+	ui.Successf("Git history cleaned")
+	printRewriteSteps()
+	fmt.Println("Then re-add all secrets:  passbook reencrypt")
 	fmt.Println()
 
 	// Log audit event
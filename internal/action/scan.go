@@ -0,0 +1,174 @@
+package action
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/models"
+	"passbook/internal/scan"
+)
+
+// maxScanFileSize skips files too large to be source code
+const maxScanFileSize = 1 << 20 // 1 MiB
+
+// scanSkipDirs are directories that never contain first-party source
+var scanSkipDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	"vendor":       true,
+	".venv":        true,
+}
+
+// Scan scans a codebase for hard-coded secrets
+func (a *Action) Scan(c *cli.Context) error {
+	root := "."
+	if c.NArg() > 0 {
+		root = c.Args().First()
+	}
+	format := c.String("format")
+	outputPath := c.String("output")
+
+	if format != "text" && format != "sarif" {
+		return fmt.Errorf("unknown format: %s (use text or sarif)", format)
+	}
+
+	// Collect secret values the current user can decrypt
+	known := make(map[string]string)
+	if !c.Bool("no-store") && a.cfg.IsInitialized() && a.cfg.HasIdentity() {
+		var err error
+		known, err = a.collectKnownSecrets(c)
+		if err != nil {
+			return fmt.Errorf("failed to load store secrets: %w", err)
+		}
+	}
+
+	var findings []scan.Finding
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := c.Context.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && scanSkipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasSuffix(path, age.Ext) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil || info.Size() > maxScanFileSize {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			relPath = path
+		}
+		findings = append(findings, scan.Content(filepath.ToSlash(relPath), data, scan.Options{Known: known})...)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+
+	var out io.Writer = os.Stdout
+	if outputPath != "" {
+		f, err := os.Create(outputPath)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if format == "sarif" {
+		if err := scan.WriteSARIF(out, findings); err != nil {
+			return fmt.Errorf("failed to write SARIF: %w", err)
+		}
+	} else {
+		for _, f := range findings {
+			fmt.Fprintf(out, "%s:%d  %s  %s\n", f.Path, f.Line, f.Rule, f.Match)
+		}
+	}
+
+	if len(findings) > 0 {
+		return fmt.Errorf("%d potential secret(s) found", len(findings))
+	}
+	if format == "text" {
+		fmt.Fprintln(out, "✓ No secrets found")
+	}
+	return nil
+}
+
+// collectKnownSecrets decrypts every secret the current user can access and
+// returns a map of value -> location label
+func (a *Action) collectKnownSecrets(c *cli.Context) (map[string]string, error) {
+	known := make(map[string]string)
+
+	// Credentials: credentials/<website>/<name>.age
+	credentialsDir := filepath.Join(a.cfg.StorePath, "credentials")
+	websites, _ := os.ReadDir(credentialsDir)
+	for _, website := range websites {
+		if !website.IsDir() {
+			continue
+		}
+		entries, _ := os.ReadDir(filepath.Join(credentialsDir, website.Name()))
+		for _, entry := range entries {
+			if !strings.HasSuffix(entry.Name(), age.Ext) {
+				continue
+			}
+			name := strings.TrimSuffix(entry.Name(), age.Ext)
+			cred, err := a.loadCredential(c.Context, website.Name(), name)
+			if err != nil {
+				continue // No access
+			}
+			if cred.Password != "" {
+				known[cred.Password] = fmt.Sprintf("password of %s/%s", website.Name(), name)
+			}
+		}
+	}
+
+	// Env files: projects/<project>/<stage>.env.age
+	projectsDir := filepath.Join(a.cfg.StorePath, "projects")
+	projects, _ := os.ReadDir(projectsDir)
+	for _, project := range projects {
+		if !project.IsDir() {
+			continue
+		}
+		entries, _ := os.ReadDir(filepath.Join(projectsDir, project.Name()))
+		for _, entry := range entries {
+			if !strings.HasSuffix(entry.Name(), ".env.age") {
+				continue
+			}
+			stage := models.Stage(strings.TrimSuffix(entry.Name(), ".env.age"))
+			envFile, err := a.loadEnvFile(c.Context, project.Name(), stage)
+			if err != nil {
+				continue // No access
+			}
+			for _, v := range envFile.Vars {
+				if v.IsSecret && v.Value != "" {
+					known[v.Value] = fmt.Sprintf("%s in %s/%s", v.Key, project.Name(), stage)
+				}
+			}
+		}
+	}
+
+	return known, nil
+}
@@ -0,0 +1,231 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/pkg/editor"
+	"passbook/pkg/ui"
+)
+
+// projectSchema returns the variable schema declared for a project, or nil
+// if the project has no metadata file or declares none
+func (a *Action) projectSchema(project string) ([]models.VarSchema, error) {
+	p, err := loadProject(filepath.Join(a.cfg.StorePath, "projects", project))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load project %s: %w", project, err)
+	}
+	if err := models.ValidateSchema(p.Schema); err != nil {
+		return nil, fmt.Errorf("invalid schema for project %s: %w", project, err)
+	}
+	return p.Schema, nil
+}
+
+// validateEnvVars checks new or changed values against the project schema
+func (a *Action) validateEnvVars(project string, stage models.Stage, vars []models.EnvVar) error {
+	schema, err := a.projectSchema(project)
+	if err != nil || len(schema) == 0 {
+		return err
+	}
+
+	var problems []string
+	for _, v := range vars {
+		for _, s := range schema {
+			if s.Key != v.Key || !s.AppliesTo(stage) {
+				continue
+			}
+			if err := s.Validate(v.Value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s %v", v.Key, err))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidInput, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// EnvCheck reports variables that are missing or don't match the project
+// schema, failing if there are any so it can gate deployments
+func (a *Action) EnvCheck(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return fmt.Errorf("usage: passbook env check PROJECT STAGE")
+	}
+
+	project := c.Args().Get(0)
+	stage := models.Stage(c.Args().Get(1))
+
+	// Validate stage
+	if !stage.IsValid() {
+		return fmt.Errorf("invalid stage: %s (valid: dev, staging, prod)", stage)
+	}
+
+	// Check permission
+	if _, err := a.authorize(rbac.GetStagePermission(stage, false)); err != nil {
+		return err
+	}
+
+	schema, err := a.projectSchema(project)
+	if err != nil {
+		return err
+	}
+	if len(schema) == 0 {
+		fmt.Printf("Project %s declares no schema.\n", project)
+		fmt.Printf("\nDeclare one with: passbook project schema edit %s\n", project)
+		return nil
+	}
+
+	// Load env file; a missing one is checked as empty
+	envFile, err := a.loadEnvFile(c.Context, project, stage)
+	if errors.Is(err, os.ErrNotExist) {
+		envFile = &models.EnvFile{Project: project, Stage: stage}
+	} else if err != nil {
+		return fmt.Errorf("failed to load environment: %w", err)
+	}
+
+	problems := models.CheckEnv(schema, envFile)
+	if len(problems) == 0 {
+		ui.Successf("%s/%s matches the schema", project, stage)
+		return nil
+	}
+
+	table := ui.NewTable()
+	for _, p := range problems {
+		table.Row(ui.Fail("✗"), p.Key, p.Problem)
+	}
+	table.Print()
+	fmt.Println()
+	return fmt.Errorf("%s/%s has %d schema problem(s)", project, stage, len(problems))
+}
+
+// ProjectSchemaShow prints a project's variable schema
+func (a *Action) ProjectSchemaShow(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook project schema show NAME")
+	}
+
+	name := c.Args().First()
+	project, err := a.loadProjectByName(name)
+	if err != nil {
+		return err
+	}
+
+	if len(project.Schema) == 0 {
+		fmt.Printf("Project %s declares no schema.\n", name)
+		fmt.Printf("\nDeclare one with: passbook project schema edit %s\n", name)
+		return nil
+	}
+	data, err := yaml.Marshal(project.Schema)
+	if err != nil {
+		return fmt.Errorf("failed to marshal schema: %w", err)
+	}
+	fmt.Print(string(data))
+	return nil
+}
+
+// ProjectSchemaEdit edits a project's variable schema in the editor
+func (a *Action) ProjectSchemaEdit(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook project schema edit NAME")
+	}
+
+	name := c.Args().First()
+
+	// Editing the schema changes what every stage must contain
+	if _, err := a.authorizeProject(name, rbac.PermProjectManage); err != nil {
+		return err
+	}
+
+	projectDir := filepath.Join(a.cfg.StorePath, "projects", name)
+	project, err := a.loadProjectByName(name)
+	if err != nil {
+		return err
+	}
+
+	original, err := yaml.Marshal(project.Schema)
+	if err != nil {
+		return fmt.Errorf("failed to marshal schema: %w", err)
+	}
+	if len(project.Schema) == 0 {
+		original = []byte(schemaTemplate)
+	}
+
+	edited, err := editor.Edit(a.cfg.Preferences.Editor, original, ".yaml")
+	if err != nil {
+		return err
+	}
+	if string(edited) == string(original) {
+		fmt.Println("No changes")
+		return nil
+	}
+
+	var schema []models.VarSchema
+	dec := yaml.NewDecoder(strings.NewReader(string(edited)))
+	dec.KnownFields(true)
+	if err := dec.Decode(&schema); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: failed to parse schema (changes discarded): %w", ErrInvalidInput, err)
+	}
+	if err := models.ValidateSchema(schema); err != nil {
+		return fmt.Errorf("%w: %w (changes discarded)", ErrInvalidInput, err)
+	}
+
+	project.Schema = schema
+	data, err := yaml.Marshal(project)
+	if err != nil {
+		return fmt.Errorf("failed to marshal project: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, ".passbook-project"), data, 0600); err != nil {
+		return fmt.Errorf("failed to write project file: %w", err)
+	}
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Update schema for project: %s", name)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Updated schema for %s (%d variables)", name, len(schema))
+
+	return nil
+}
+
+// loadProjectByName loads a project's metadata, reporting a missing project
+func (a *Action) loadProjectByName(name string) (*Project, error) {
+	project, err := loadProject(filepath.Join(a.cfg.StorePath, "projects", name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("project %s %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load project: %w", err)
+	}
+	return project, nil
+}
+
+// schemaTemplate is offered when a project has no schema yet
+const schemaTemplate = `# Variables this project expects. Each entry takes:
+#   key: NAME            required
+#   required: true       must be set in every stage it applies to
+#   type: string         string, int, bool, url or enum
+#   values: [a, b]       allowed values for enum
+#   pattern: "[a-z]+"    regular expression the whole value must match
+#   stages: [prod]       limit the entry to some stages (default: all)
+#   description: text
+#
+# - key: DATABASE_URL
+#   required: true
+#   type: url
+# - key: LOG_LEVEL
+#   type: enum
+#   values: [debug, info, warn, error]
+`
@@ -0,0 +1,185 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/audit"
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/pkg/ui"
+)
+
+// timelineEntry is one thing that happened to a secret, from git history or
+// the audit log
+type timelineEntry struct {
+	When   time.Time `json:"when"`
+	Who    string    `json:"who"`
+	What   string    `json:"what"`
+	Kind   string    `json:"kind"`             // changed, read, re-encrypted or other
+	Commit string    `json:"commit,omitempty"` // Set for entries from git
+}
+
+// CredLog shows everything that happened to one credential
+func (a *Action) CredLog(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook cred log WEBSITE/NAME")
+	}
+	website, name, err := parseCredentialPath(c.Args().First())
+	if err != nil {
+		return err
+	}
+	if _, err := a.authorize(rbac.PermCredentialsRead); err != nil {
+		return err
+	}
+
+	cred := &models.Credential{Website: website, Name: name}
+	return a.showTimeline(c, website+"/"+name, cred.FullPath())
+}
+
+// EnvLog shows everything that happened to one project stage's environment
+func (a *Action) EnvLog(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return fmt.Errorf("usage: passbook env log PROJECT STAGE")
+	}
+	project := c.Args().Get(0)
+	stage := models.Stage(c.Args().Get(1))
+	if !stage.IsValid() {
+		return fmt.Errorf("invalid stage: %s (valid: dev, staging, prod)", stage)
+	}
+	if _, err := a.authorize(rbac.GetStagePermission(stage, false)); err != nil {
+		return err
+	}
+
+	envFile := &models.EnvFile{Project: project, Stage: stage}
+	return a.showTimeline(c, project+"/"+string(stage), envFile.FullPath())
+}
+
+// showTimeline merges a secret's commits and audit events, newest first
+func (a *Action) showTimeline(c *cli.Context, name, file string) error {
+	entries, err := gitFileHistory(a.cfg.StorePath, file)
+	if err != nil {
+		return fmt.Errorf("failed to read git history: %w", err)
+	}
+
+	events, err := a.getAuditLogger().GetEvents(&audit.EventFilter{Target: name})
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	for _, e := range events {
+		entries = append(entries, timelineEntry{
+			When: e.Timestamp,
+			Who:  e.Actor,
+			What: describeEvent(e),
+			Kind: eventKind(e.Type),
+		})
+	}
+
+	if len(entries) == 0 {
+		return fmt.Errorf("%w: no history for %s", ErrNotFound, name)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].When.After(entries[j].When)
+	})
+	if limit := c.Int("limit"); limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	if c.Bool("json") {
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal history: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	ui.Heading("History of " + name)
+	fmt.Println()
+	table := ui.NewTable("WHEN", "WHO", "KIND", "WHAT", "COMMIT")
+	for _, e := range entries {
+		kind := e.Kind
+		switch kind {
+		case "read":
+			kind = ui.Highlight(kind)
+		case "re-encrypted":
+			kind = ui.Muted(kind)
+		}
+		table.Row(e.When.Local().Format("2006-01-02 15:04"), e.Who, kind, e.What, e.Commit)
+	}
+	table.Print()
+	return nil
+}
+
+// gitFileHistory returns the commits that touched file, newest first
+func gitFileHistory(storePath, file string) ([]timelineEntry, error) {
+	cmd := exec.Command("git", "log", "--format=%h%x00%at%x00%ae%x00%s", "--", file)
+	cmd.Dir = storePath
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []timelineEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.SplitN(line, "\x00", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		at, _ := strconv.ParseInt(fields[1], 10, 64)
+		kind := "changed"
+		if subject := strings.ToLower(fields[3]); strings.Contains(subject, "re-encrypt") || strings.Contains(subject, "reencrypt") {
+			kind = "re-encrypted"
+		}
+		entries = append(entries, timelineEntry{
+			When:   time.Unix(at, 0),
+			Who:    fields[2],
+			What:   fields[3],
+			Kind:   kind,
+			Commit: fields[0],
+		})
+	}
+	return entries, nil
+}
+
+// describeEvent summarizes an audit event for a timeline
+func describeEvent(e audit.Event) string {
+	if len(e.Details) == 0 {
+		return string(e.Type)
+	}
+	return fmt.Sprintf("%s (%s)", e.Type, formatDetails(e.Details))
+}
+
+// formatDetails lists an audit event's details in a stable order
+func formatDetails(details map[string]string) string {
+	keys := make([]string, 0, len(details))
+	for k := range details {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+details[k])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// eventKind classifies an audit event for a timeline
+func eventKind(t audit.EventType) string {
+	switch t {
+	case audit.EventCredentialAccess, audit.EventSensitiveAccess, audit.EventEnvAccess, audit.EventTokenRedeemed:
+		return "read"
+	}
+	if t.IsMutation() {
+		return "changed"
+	}
+	return "other"
+}
@@ -0,0 +1,171 @@
+package action
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/audit"
+	"passbook/internal/envformat"
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/pkg/ui"
+)
+
+// serveTokenEnv is where processes started alongside env serve find its
+// token
+const serveTokenEnv = "PASSBOOK_SERVE_TOKEN"
+
+// EnvServe decrypts an environment once and serves it to local processes
+// for the rest of a dev session, so they don't each decrypt it or read a
+// .env file. It listens on the loopback interface only, and every request
+// needs the session's token. Nothing is written to disk.
+func (a *Action) EnvServe(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return fmt.Errorf("usage: passbook env serve PROJECT STAGE [--port PORT] [--ttl DURATION]")
+	}
+	project := c.Args().Get(0)
+	stage := models.Stage(c.Args().Get(1))
+	if !stage.IsValid() {
+		return fmt.Errorf("invalid stage: %s (valid: dev, staging, prod)", stage)
+	}
+	if stage == models.StageProd && !c.Bool("allow-prod") {
+		return fmt.Errorf("%w: env serve is for local development; pass --allow-prod to serve prod anyway", ErrAccessDenied)
+	}
+	port := c.Int("port")
+	if port < 0 || port > 65535 {
+		return fmt.Errorf("%w: port %d is out of range", ErrInvalidInput, port)
+	}
+
+	if _, err := a.authorize(rbac.GetStagePermission(stage, false)); err != nil {
+		return err
+	}
+	envFile, err := a.loadEnvFile(c.Context, project, stage)
+	if err != nil {
+		return fmt.Errorf("failed to load environment: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return fmt.Errorf("failed to listen on port %d: %w", port, err)
+	}
+	token, err := newServeToken()
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	addr := listener.Addr().String()
+
+	server := &http.Server{
+		Handler:           envServeHandler(envFile, token),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	a.logAudit(audit.EventEnvAccess, project+"/"+string(stage), "via", "env serve")
+
+	ctx := c.Context
+	if ttl := c.Duration("ttl"); ttl > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ttl)
+		defer cancel()
+	}
+
+	ui.Successf("Serving %s/%s on http://%s (Ctrl-C to stop)", project, stage, addr)
+	fmt.Println()
+	fmt.Println("Give processes the token with:")
+	fmt.Printf("  export %s=%s\n", serveTokenEnv, token)
+	fmt.Println()
+	fmt.Println("Then fetch variables with:")
+	fmt.Printf("  curl -H \"Authorization: Bearer $%s\" http://%s/env\n", serveTokenEnv, addr)
+	fmt.Printf("  curl -H \"Authorization: Bearer $%s\" http://%s/env/KEY\n", serveTokenEnv, addr)
+	if ttl := c.Duration("ttl"); ttl > 0 {
+		fmt.Printf("\nStopping after %s.\n", ttl)
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- server.Serve(listener) }()
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		fmt.Println("Session expired; stopped serving.")
+	} else {
+		fmt.Println("Stopped serving.")
+	}
+	return nil
+}
+
+// newServeToken returns a random bearer token for one serve session
+func newServeToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// envServeHandler serves an environment: GET /env renders all of it
+// (?format= any env export format), GET /env/KEY returns one value
+func envServeHandler(envFile *models.EnvFile, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /env", func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		data, err := envformat.Encode(format, envFile, envformat.DefaultSeparator)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(data)
+	})
+	mux.HandleFunc("GET /env/{key}", func(w http.ResponseWriter, r *http.Request) {
+		key := r.PathValue("key")
+		for _, v := range envFile.Vars {
+			if v.Key == key {
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				w.Write([]byte(v.Value))
+				return
+			}
+		}
+		http.Error(w, fmt.Sprintf("%s not found", key), http.StatusNotFound)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+
+		// Browsers send an Origin, and a page that rebinds its DNS name to
+		// 127.0.0.1 still sends its own Host; neither is a local process
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if r.Header.Get("Origin") != "" || (host != "127.0.0.1" && host != "localhost") {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,247 @@
+package action
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/audit"
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/pkg/termio"
+	"passbook/pkg/ui"
+)
+
+// serviceAccountNamePattern restricts service account names to simple identifiers
+var serviceAccountNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,62}$`)
+
+// ServiceAccountCreate creates a non-human identity for CI and servers
+func (a *Action) ServiceAccountCreate(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook service-account create NAME --role ROLE")
+	}
+
+	name := c.Args().First()
+	roles := c.StringSlice("role")
+	keyPath := c.String("output")
+	pubKey := c.String("public-key")
+
+	if !serviceAccountNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid name: %s (use lowercase letters, digits and dashes)", name)
+	}
+	if len(roles) == 0 {
+		return fmt.Errorf("at least one --role is required")
+	}
+	if pubKey != "" {
+		if keyPath != "" {
+			return fmt.Errorf("use --output or --public-key, not both")
+		}
+		if !age.ValidatePublicKey(pubKey) {
+			return fmt.Errorf("%w: invalid public key: %s", ErrInvalidInput, pubKey)
+		}
+	} else if keyPath == "" {
+		keyPath = name + ".key"
+	}
+
+	// Check permission
+	currentUser, err := a.authorize(rbac.PermTeamInvite)
+	if err != nil {
+		return err
+	}
+
+	// Validate roles - service accounts never manage the team
+	var userRoles []models.Role
+	for _, r := range roles {
+		role := models.Role(r)
+		if !role.IsValid() {
+			return fmt.Errorf("invalid role: %s (valid: dev, staging-access, prod-access, viewer)", r)
+		}
+		if role == models.RoleAdmin {
+			return fmt.Errorf("service accounts cannot have the admin role")
+		}
+		userRoles = append(userRoles, role)
+	}
+
+	// Load users
+	userList, err := a.loadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+
+	for _, u := range userList.Users {
+		if u.Email == name {
+			return fmt.Errorf("%s %w", name, ErrConflict)
+		}
+		if pubKey != "" && u.PublicKey == pubKey {
+			return fmt.Errorf("public key already belongs to %s: %w", u.Email, ErrConflict)
+		}
+	}
+
+	// Generate a dedicated key unless the machine made its own; either way
+	// the private key never enters the store
+	if pubKey == "" {
+		if _, err := os.Stat(keyPath); err == nil {
+			return fmt.Errorf("key file %s %w", keyPath, ErrConflict)
+		}
+		if pubKey, err = age.GenerateIdentity(keyPath); err != nil {
+			return fmt.Errorf("failed to generate key: %w", err)
+		}
+	}
+
+	newUser := models.User{
+		ID:        uuid.New().String(),
+		Email:     name,
+		Name:      name,
+		PublicKey: pubKey,
+		CreatedAt: time.Now(),
+		Roles:     userRoles,
+		Metadata: map[string]string{
+			"service_account": "true",
+			"created_by":      currentUser.Email,
+		},
+	}
+	userList.Users = append(userList.Users, newUser)
+
+	// Save users
+	if err := a.saveUsers(userList); err != nil {
+		return fmt.Errorf("failed to save users: %w", err)
+	}
+
+	// Update recipients file
+	if err := a.updateRecipientsFile(userList); err != nil {
+		return fmt.Errorf("failed to update recipients: %w", err)
+	}
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Add service account: %s", name)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	// Log audit event
+	a.logAudit(audit.EventServiceAccountCreated, name, "roles", formatRoles(userRoles))
+
+	ui.Successf("Created service account %s with roles: %s", name, formatRoles(userRoles))
+	if keyPath != "" {
+		fmt.Printf("  Private key: %s\n", keyPath)
+	}
+	fmt.Printf("  Public key:  %s\n", pubKey)
+	fmt.Println()
+	if keyPath != "" {
+		fmt.Println("Store the private key in your CI secret store, then delete the local file.")
+	}
+	fmt.Println("Existing secrets must be re-encrypted before the account can read them:")
+	fmt.Println("  passbook reencrypt")
+
+	return nil
+}
+
+// ServiceAccountList lists service accounts
+func (a *Action) ServiceAccountList(c *cli.Context) error {
+	userList, err := a.loadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+
+	ui.Heading("Service Accounts")
+	fmt.Println()
+
+	var count int
+	for _, u := range userList.Users {
+		if !u.IsServiceAccount() {
+			continue
+		}
+		fmt.Printf("  %s\n", u.Email)
+		fmt.Printf("    Roles: %s\n", formatRoles(u.Roles))
+		if createdBy := u.Metadata["created_by"]; createdBy != "" {
+			fmt.Printf("    Created by: %s on %s\n", createdBy, u.CreatedAt.Format("2006-01-02"))
+		}
+		count++
+	}
+
+	if count == 0 {
+		fmt.Println("No service accounts found.")
+		fmt.Println("\nCreate one with: passbook service-account create deploy-bot --role prod-access")
+	}
+
+	return nil
+}
+
+// ServiceAccountRemove removes a service account
+func (a *Action) ServiceAccountRemove(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook service-account rm NAME")
+	}
+
+	name := c.Args().First()
+	force := c.Bool("force")
+
+	// Check permission
+	if _, err := a.authorize(rbac.PermTeamRevoke); err != nil {
+		return err
+	}
+
+	// Load users
+	userList, err := a.loadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+
+	var found bool
+	var newUsers []models.User
+	for _, u := range userList.Users {
+		if u.Email == name {
+			if !u.IsServiceAccount() {
+				return fmt.Errorf("%s is not a service account (use 'passbook team revoke')", name)
+			}
+			found = true
+			continue
+		}
+		newUsers = append(newUsers, u)
+	}
+
+	if !found {
+		return fmt.Errorf("service account %s %w", name, ErrNotFound)
+	}
+
+	// Confirm
+	if !force {
+		confirm, err := termio.Confirm(fmt.Sprintf("Remove service account %s?", name), false)
+		if err != nil {
+			return err
+		}
+		if !confirm {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	userList.Users = newUsers
+
+	// Save users
+	if err := a.saveUsers(userList); err != nil {
+		return fmt.Errorf("failed to save users: %w", err)
+	}
+
+	// Update recipients file
+	if err := a.updateRecipientsFile(userList); err != nil {
+		return fmt.Errorf("failed to update recipients: %w", err)
+	}
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Remove service account: %s", name)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	// Log audit event
+	a.logAudit(audit.EventServiceAccountRemoved, name)
+
+	ui.Successf("Removed service account %s", name)
+	fmt.Println("\nRun 'passbook reencrypt' to remove its access to existing secrets.")
+
+	return nil
+}
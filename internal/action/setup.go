@@ -1,10 +1,12 @@
 package action
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,6 +18,7 @@ import (
 	"passbook/internal/config"
 	"passbook/internal/models"
 	"passbook/pkg/termio"
+	"passbook/pkg/ui"
 )
 
 // Init initializes a new passbook store
@@ -52,27 +55,27 @@ func (a *Action) Init(c *cli.Context) error {
 	// 1. Create store directory
 	fmt.Print("Creating store directory... ")
 	if err := os.MkdirAll(storePath, 0700); err != nil {
-		fmt.Println("FAILED")
+		fmt.Println(ui.Fail("FAILED"))
 		return fmt.Errorf("failed to create store directory: %w", err)
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
 
 	// 2. Initialize git repo
 	fmt.Print("Initializing git repository... ")
 	if err := initGitRepo(storePath); err != nil {
-		fmt.Println("FAILED")
+		fmt.Println(ui.Fail("FAILED"))
 		return fmt.Errorf("failed to initialize git repo: %w", err)
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
 
 	// 3. Add remote if provided
 	if remote != "" {
 		fmt.Print("Adding git remote... ")
 		if err := addGitRemote(storePath, remote); err != nil {
-			fmt.Println("FAILED")
+			fmt.Println(ui.Fail("FAILED"))
 			return fmt.Errorf("failed to add git remote: %w", err)
 		}
-		fmt.Println("OK")
+		fmt.Println(ui.Success("OK"))
 	}
 
 	// 4. Generate identity if needed
@@ -82,31 +85,33 @@ func (a *Action) Init(c *cli.Context) error {
 		var err error
 		publicKey, err = age.GenerateIdentity(identityPath)
 		if err != nil {
-			fmt.Println("FAILED")
+			fmt.Println(ui.Fail("FAILED"))
 			return fmt.Errorf("failed to generate identity: %w", err)
 		}
-		fmt.Println("OK")
+		fmt.Println(ui.Success("OK"))
 		fmt.Printf("  Public key: %s\n", publicKey)
 	} else {
 		// Load existing public key
 		fmt.Print("Loading existing identity... ")
 		ageBackend, err := age.New(identityPath)
 		if err != nil {
-			fmt.Println("FAILED")
+			fmt.Println(ui.Fail("FAILED"))
 			return fmt.Errorf("failed to load identity: %w", err)
 		}
 		publicKey = ageBackend.PublicKey()
-		fmt.Println("OK")
+		fmt.Println(ui.Success("OK"))
 		fmt.Printf("  Public key: %s\n", publicKey)
 	}
 
 	// 5. Create .passbook-config
 	fmt.Print("Creating store configuration... ")
 	storeConfig := struct {
-		Org   config.OrgConfig   `yaml:"org"`
-		Git   config.GitConfig   `yaml:"git"`
-		Email config.EmailConfig `yaml:"email"`
+		StoreVersion int                `yaml:"store_version"`
+		Org          config.OrgConfig   `yaml:"org"`
+		Git          config.GitConfig   `yaml:"git"`
+		Email        config.EmailConfig `yaml:"email"`
 	}{
+		StoreVersion: config.StoreVersion,
 		Org: config.OrgConfig{
 			Name:          org,
 			AllowedDomain: domain,
@@ -125,26 +130,26 @@ func (a *Action) Init(c *cli.Context) error {
 	configPath := filepath.Join(storePath, ".passbook-config")
 	configData, err := yaml.Marshal(storeConfig)
 	if err != nil {
-		fmt.Println("FAILED")
+		fmt.Println(ui.Fail("FAILED"))
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 	if err := os.WriteFile(configPath, configData, 0600); err != nil {
-		fmt.Println("FAILED")
+		fmt.Println(ui.Fail("FAILED"))
 		return fmt.Errorf("failed to write config: %w", err)
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
 
 	// 6. Create .passbook-recipients with the admin's key
 	fmt.Print("Creating recipients file... ")
 	recipientsPath := filepath.Join(storePath, ".passbook-recipients")
 	recipientsContent := fmt.Sprintf("# Passbook Recipients - Team Members\n# Format: <age-public-key> # <email>\n\n%s # admin (initial setup)\n", publicKey)
 	if err := os.WriteFile(recipientsPath, []byte(recipientsContent), 0600); err != nil {
-		fmt.Println("FAILED")
+		fmt.Println(ui.Fail("FAILED"))
 		return fmt.Errorf("failed to write recipients: %w", err)
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
 
-	// 6b. Create .passbook-users with admin user
+	// 6b. Create the users file with the admin user
 	fmt.Print("Creating users file... ")
 	adminUser := models.User{
 		ID:        uuid.New().String(),
@@ -154,67 +159,69 @@ func (a *Action) Init(c *cli.Context) error {
 		CreatedAt: time.Now(),
 		Roles:     []models.Role{models.RoleAdmin},
 	}
-	userList := models.UserList{Users: []models.User{adminUser}}
-	usersPath := filepath.Join(storePath, ".passbook-users")
-	usersData, err := yaml.Marshal(userList)
-	if err != nil {
-		fmt.Println("FAILED")
-		return fmt.Errorf("failed to marshal users: %w", err)
-	}
-	if err := os.WriteFile(usersPath, usersData, 0600); err != nil {
-		fmt.Println("FAILED")
+	if err := a.saveUsers(&models.UserList{Users: []models.User{adminUser}}); err != nil {
+		fmt.Println(ui.Fail("FAILED"))
 		return fmt.Errorf("failed to write users: %w", err)
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
 
 	// 7. Create directories
 	fmt.Print("Creating directory structure... ")
 	dirs := []string{"credentials", "projects"}
 	for _, dir := range dirs {
 		if err := os.MkdirAll(filepath.Join(storePath, dir), 0700); err != nil {
-			fmt.Println("FAILED")
+			fmt.Println(ui.Fail("FAILED"))
 			return fmt.Errorf("failed to create %s directory: %w", dir, err)
 		}
 		// Create .gitkeep to track empty directories
 		gitkeepPath := filepath.Join(storePath, dir, ".gitkeep")
 		if err := os.WriteFile(gitkeepPath, []byte(""), 0600); err != nil {
-			fmt.Println("FAILED")
+			fmt.Println(ui.Fail("FAILED"))
 			return fmt.Errorf("failed to create .gitkeep: %w", err)
 		}
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
 
 	// 8. Create .gitignore
 	fmt.Print("Creating .gitignore... ")
 	gitignorePath := filepath.Join(storePath, ".gitignore")
-	gitignoreContent := "# Local files\n*.local\n*.tmp\n"
+	gitignoreContent := "# Local files\n*.local\n*.tmp\n\n# Never commit private keys\n.pending-keys/\n"
 	if err := os.WriteFile(gitignorePath, []byte(gitignoreContent), 0600); err != nil {
-		fmt.Println("FAILED")
+		fmt.Println(ui.Fail("FAILED"))
 		return fmt.Errorf("failed to write .gitignore: %w", err)
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
+
+	// 9. Create .gitattributes
+	fmt.Print("Creating .gitattributes... ")
+	if err := writeGitAttributes(storePath); err != nil {
+		fmt.Println(ui.Fail("FAILED"))
+		return err
+	}
+	fmt.Println(ui.Success("OK"))
 
-	// 9. Initial commit
+	// 10. Initial commit
 	fmt.Print("Creating initial commit... ")
+	a.configureGitAuthor()
 	if err := gitCommit(storePath, "Initialize passbook store"); err != nil {
-		fmt.Println("FAILED")
+		fmt.Println(ui.Fail("FAILED"))
 		return fmt.Errorf("failed to create initial commit: %w", err)
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
 
-	// 10. Save user config with identity
+	// 11. Save user config with identity
 	fmt.Print("Saving user configuration... ")
 	a.cfg.Identity.PublicKey = publicKey
 	a.cfg.Identity.PrivateKeyPath = identityPath
 	if err := a.cfg.Save(); err != nil {
-		fmt.Println("FAILED")
+		fmt.Println(ui.Fail("FAILED"))
 		return fmt.Errorf("failed to save user config: %w", err)
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
 
 	fmt.Println()
 	fmt.Println("========================================")
-	fmt.Println("  Passbook initialized successfully!")
+	fmt.Println(ui.Success("  Passbook initialized successfully!"))
 	fmt.Println("========================================")
 	fmt.Println()
 	fmt.Printf("Store: %s\n", storePath)
@@ -243,6 +250,7 @@ func (a *Action) Clone(c *cli.Context) error {
 	}
 
 	gitURL := c.Args().First()
+	sparse := c.Bool("sparse")
 	storePath := a.cfg.StorePath
 	identityPath := a.cfg.IdentityPath()
 
@@ -256,12 +264,22 @@ func (a *Action) Clone(c *cli.Context) error {
 
 	// 1. Clone the repo
 	fmt.Print("Cloning repository... ")
-	cmd := exec.Command("git", "clone", gitURL, storePath)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		fmt.Println("FAILED")
-		return fmt.Errorf("failed to clone repository: %s", string(output))
+	var args []string
+	if depth := c.Int("depth"); depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
 	}
-	fmt.Println("OK")
+	if filter := c.String("filter"); filter != "" {
+		args = append(args, "--filter", filter)
+	}
+	if sparse {
+		args = append(args, "--sparse")
+	}
+	args = append(args, gitURL)
+	if err := gitClone(c.Context, storePath, args...); err != nil {
+		fmt.Println(ui.Fail("FAILED"))
+		return fmt.Errorf("failed to clone repository: %w", err)
+	}
+	fmt.Println(ui.Success("OK"))
 
 	// 2. Generate identity if needed
 	var publicKey string
@@ -270,20 +288,20 @@ func (a *Action) Clone(c *cli.Context) error {
 		var err error
 		publicKey, err = age.GenerateIdentity(identityPath)
 		if err != nil {
-			fmt.Println("FAILED")
+			fmt.Println(ui.Fail("FAILED"))
 			return fmt.Errorf("failed to generate identity: %w", err)
 		}
-		fmt.Println("OK")
+		fmt.Println(ui.Success("OK"))
 		fmt.Printf("  Public key: %s\n", publicKey)
 	} else {
 		fmt.Print("Loading existing identity... ")
 		ageBackend, err := age.New(identityPath)
 		if err != nil {
-			fmt.Println("FAILED")
+			fmt.Println(ui.Fail("FAILED"))
 			return fmt.Errorf("failed to load identity: %w", err)
 		}
 		publicKey = ageBackend.PublicKey()
-		fmt.Println("OK")
+		fmt.Println(ui.Success("OK"))
 		fmt.Printf("  Public key: %s\n", publicKey)
 	}
 
@@ -292,14 +310,29 @@ func (a *Action) Clone(c *cli.Context) error {
 	a.cfg.Identity.PublicKey = publicKey
 	a.cfg.Identity.PrivateKeyPath = identityPath
 	if err := a.cfg.Save(); err != nil {
-		fmt.Println("FAILED")
+		fmt.Println(ui.Fail("FAILED"))
 		return fmt.Errorf("failed to save user config: %w", err)
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
+
+	// 4. Narrow the checkout to what this user can decrypt
+	if sparse {
+		fmt.Print("Configuring sparse checkout... ")
+		stages, err := a.applySparseCheckout()
+		if err != nil {
+			fmt.Println(ui.Fail("FAILED"))
+			return fmt.Errorf("failed to configure sparse checkout: %w", err)
+		}
+		if stages == nil {
+			fmt.Println("top-level files and tokens only (you are not in the team yet)")
+		} else {
+			fmt.Println(ui.Success("OK"))
+		}
+	}
 
 	fmt.Println()
 	fmt.Println("========================================")
-	fmt.Println("  Passbook cloned successfully!")
+	fmt.Println(ui.Success("  Passbook cloned successfully!"))
 	fmt.Println("========================================")
 	fmt.Println()
 	fmt.Printf("Store: %s\n", storePath)
@@ -308,14 +341,21 @@ func (a *Action) Clone(c *cli.Context) error {
 	fmt.Println("IMPORTANT: Ask an admin to add your public key to the team.")
 	fmt.Println("Send them this command:")
 	fmt.Printf("  passbook team invite YOUR_EMAIL --key %s\n", publicKey)
+	if sparse {
+		fmt.Println()
+		fmt.Println("Once your access changes, run 'passbook fetch --sparse' to update the checkout.")
+	}
+	if c.Int("depth") > 0 {
+		fmt.Println()
+		fmt.Println("History is truncated. Run 'passbook fetch --deepen N' or 'passbook fetch --unshallow' when you need it.")
+	}
 
 	return nil
 }
 
 // Setup runs the interactive setup wizard
 func (a *Action) Setup(c *cli.Context) error {
-	fmt.Println("Welcome to Passbook Setup Wizard")
-	fmt.Println("================================")
+	ui.Heading("Welcome to Passbook Setup Wizard")
 	fmt.Println()
 
 	// Ask what they want to do
@@ -371,7 +411,7 @@ func (a *Action) Setup(c *cli.Context) error {
 
 		fmt.Println()
 
-		return a.cloneWithArgs(gitURL)
+		return a.cloneWithArgs(c.Context, gitURL)
 	}
 }
 
@@ -400,27 +440,27 @@ func (a *Action) initWithArgs(org, domain, remote string) error {
 	// Create store directory
 	fmt.Print("Creating store directory... ")
 	if err := os.MkdirAll(storePath, 0700); err != nil {
-		fmt.Println("FAILED")
+		fmt.Println(ui.Fail("FAILED"))
 		return fmt.Errorf("failed to create store directory: %w", err)
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
 
 	// Initialize git repo
 	fmt.Print("Initializing git repository... ")
 	if err := initGitRepo(storePath); err != nil {
-		fmt.Println("FAILED")
+		fmt.Println(ui.Fail("FAILED"))
 		return fmt.Errorf("failed to initialize git repo: %w", err)
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
 
 	// Add remote if provided
 	if remote != "" {
 		fmt.Print("Adding git remote... ")
 		if err := addGitRemote(storePath, remote); err != nil {
-			fmt.Println("FAILED")
+			fmt.Println(ui.Fail("FAILED"))
 			return fmt.Errorf("failed to add git remote: %w", err)
 		}
-		fmt.Println("OK")
+		fmt.Println(ui.Success("OK"))
 	}
 
 	// Generate identity
@@ -430,30 +470,32 @@ func (a *Action) initWithArgs(org, domain, remote string) error {
 		var err error
 		publicKey, err = age.GenerateIdentity(identityPath)
 		if err != nil {
-			fmt.Println("FAILED")
+			fmt.Println(ui.Fail("FAILED"))
 			return fmt.Errorf("failed to generate identity: %w", err)
 		}
-		fmt.Println("OK")
+		fmt.Println(ui.Success("OK"))
 		fmt.Printf("  Public key: %s\n", publicKey)
 	} else {
 		fmt.Print("Loading existing identity... ")
 		ageBackend, err := age.New(identityPath)
 		if err != nil {
-			fmt.Println("FAILED")
+			fmt.Println(ui.Fail("FAILED"))
 			return fmt.Errorf("failed to load identity: %w", err)
 		}
 		publicKey = ageBackend.PublicKey()
-		fmt.Println("OK")
+		fmt.Println(ui.Success("OK"))
 		fmt.Printf("  Public key: %s\n", publicKey)
 	}
 
 	// Create config
 	fmt.Print("Creating store configuration... ")
 	storeConfig := struct {
-		Org   config.OrgConfig   `yaml:"org"`
-		Git   config.GitConfig   `yaml:"git"`
-		Email config.EmailConfig `yaml:"email"`
+		StoreVersion int                `yaml:"store_version"`
+		Org          config.OrgConfig   `yaml:"org"`
+		Git          config.GitConfig   `yaml:"git"`
+		Email        config.EmailConfig `yaml:"email"`
 	}{
+		StoreVersion: config.StoreVersion,
 		Org: config.OrgConfig{
 			Name:          org,
 			AllowedDomain: domain,
@@ -472,64 +514,73 @@ func (a *Action) initWithArgs(org, domain, remote string) error {
 	configPath := filepath.Join(storePath, ".passbook-config")
 	configData, err := yaml.Marshal(storeConfig)
 	if err != nil {
-		fmt.Println("FAILED")
+		fmt.Println(ui.Fail("FAILED"))
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 	if err := os.WriteFile(configPath, configData, 0600); err != nil {
-		fmt.Println("FAILED")
+		fmt.Println(ui.Fail("FAILED"))
 		return fmt.Errorf("failed to write config: %w", err)
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
 
 	// Create recipients
 	fmt.Print("Creating recipients file... ")
 	recipientsPath := filepath.Join(storePath, ".passbook-recipients")
 	recipientsContent := fmt.Sprintf("# Passbook Recipients\n\n%s # admin\n", publicKey)
 	if err := os.WriteFile(recipientsPath, []byte(recipientsContent), 0600); err != nil {
-		fmt.Println("FAILED")
+		fmt.Println(ui.Fail("FAILED"))
 		return fmt.Errorf("failed to write recipients: %w", err)
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
 
 	// Create directories
 	fmt.Print("Creating directory structure... ")
 	for _, dir := range []string{"credentials", "projects"} {
 		if err := os.MkdirAll(filepath.Join(storePath, dir), 0700); err != nil {
-			fmt.Println("FAILED")
+			fmt.Println(ui.Fail("FAILED"))
 			return err
 		}
 		if err := os.WriteFile(filepath.Join(storePath, dir, ".gitkeep"), []byte(""), 0600); err != nil {
-			fmt.Println("FAILED")
+			fmt.Println(ui.Fail("FAILED"))
 			return err
 		}
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
 
 	// Create .gitignore
 	fmt.Print("Creating .gitignore... ")
-	if err := os.WriteFile(filepath.Join(storePath, ".gitignore"), []byte("*.local\n*.tmp\n"), 0600); err != nil {
-		fmt.Println("FAILED")
+	if err := os.WriteFile(filepath.Join(storePath, ".gitignore"), []byte("*.local\n*.tmp\n.pending-keys/\n"), 0600); err != nil {
+		fmt.Println(ui.Fail("FAILED"))
 		return err
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
+
+	// Create .gitattributes
+	fmt.Print("Creating .gitattributes... ")
+	if err := writeGitAttributes(storePath); err != nil {
+		fmt.Println(ui.Fail("FAILED"))
+		return err
+	}
+	fmt.Println(ui.Success("OK"))
 
 	// Initial commit
 	fmt.Print("Creating initial commit... ")
+	a.configureGitAuthor()
 	if err := gitCommit(storePath, "Initialize passbook store"); err != nil {
-		fmt.Println("FAILED")
+		fmt.Println(ui.Fail("FAILED"))
 		return err
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
 
 	// Save user config
 	fmt.Print("Saving user configuration... ")
 	a.cfg.Identity.PublicKey = publicKey
 	a.cfg.Identity.PrivateKeyPath = identityPath
 	if err := a.cfg.Save(); err != nil {
-		fmt.Println("FAILED")
+		fmt.Println(ui.Fail("FAILED"))
 		return err
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
 
 	fmt.Println()
 	fmt.Println("Passbook initialized successfully!")
@@ -537,7 +588,7 @@ func (a *Action) initWithArgs(org, domain, remote string) error {
 }
 
 // cloneWithArgs runs clone with the given URL
-func (a *Action) cloneWithArgs(gitURL string) error {
+func (a *Action) cloneWithArgs(ctx context.Context, gitURL string) error {
 	storePath := a.cfg.StorePath
 	identityPath := a.cfg.IdentityPath()
 
@@ -546,12 +597,11 @@ func (a *Action) cloneWithArgs(gitURL string) error {
 	}
 
 	fmt.Print("Cloning repository... ")
-	cmd := exec.Command("git", "clone", gitURL, storePath)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		fmt.Println("FAILED")
-		return fmt.Errorf("failed to clone: %s", string(output))
+	if err := gitClone(ctx, storePath, gitURL); err != nil {
+		fmt.Println(ui.Fail("FAILED"))
+		return fmt.Errorf("failed to clone: %w", err)
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
 
 	var publicKey string
 	if !a.cfg.HasIdentity() {
@@ -559,30 +609,30 @@ func (a *Action) cloneWithArgs(gitURL string) error {
 		var err error
 		publicKey, err = age.GenerateIdentity(identityPath)
 		if err != nil {
-			fmt.Println("FAILED")
+			fmt.Println(ui.Fail("FAILED"))
 			return err
 		}
-		fmt.Println("OK")
+		fmt.Println(ui.Success("OK"))
 		fmt.Printf("  Public key: %s\n", publicKey)
 	} else {
 		fmt.Print("Loading existing identity... ")
 		ageBackend, err := age.New(identityPath)
 		if err != nil {
-			fmt.Println("FAILED")
+			fmt.Println(ui.Fail("FAILED"))
 			return err
 		}
 		publicKey = ageBackend.PublicKey()
-		fmt.Println("OK")
+		fmt.Println(ui.Success("OK"))
 	}
 
 	fmt.Print("Saving user configuration... ")
 	a.cfg.Identity.PublicKey = publicKey
 	a.cfg.Identity.PrivateKeyPath = identityPath
 	if err := a.cfg.Save(); err != nil {
-		fmt.Println("FAILED")
+		fmt.Println(ui.Fail("FAILED"))
 		return err
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
 
 	fmt.Println()
 	fmt.Println("Cloned successfully!")
@@ -593,6 +643,42 @@ func (a *Action) cloneWithArgs(gitURL string) error {
 
 // Git helper functions
 
+// gitAttributes stops git from converting line endings (core.autocrlf on
+// Windows), so encrypted files and signatures stay byte-identical everywhere
+const gitAttributes = "# Keep every file byte-identical across platforms\n* -text\n"
+
+// writeGitAttributes writes the store's .gitattributes
+func writeGitAttributes(storePath string) error {
+	if err := os.WriteFile(filepath.Join(storePath, ".gitattributes"), []byte(gitAttributes), 0600); err != nil {
+		return fmt.Errorf("failed to write .gitattributes: %w", err)
+	}
+	return nil
+}
+
+// gitClone clones into storePath, removing a partial clone if git fails or
+// the user interrupts it
+func gitClone(ctx context.Context, storePath string, args ...string) error {
+	_, statErr := os.Stat(storePath)
+	existed := statErr == nil
+
+	cmd := exec.CommandContext(ctx, "git", append(append([]string{"clone"}, args...), storePath)...)
+	var output []byte
+	err := ui.Spin(func() (err error) {
+		output, err = cmd.CombinedOutput()
+		return err
+	})
+	if err != nil {
+		if !existed {
+			os.RemoveAll(storePath)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
 func initGitRepo(path string) error {
 	cmd := exec.Command("git", "init")
 	cmd.Dir = path
@@ -0,0 +1,231 @@
+package action
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"passbook/internal/audit"
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/internal/store"
+	"passbook/pkg/termio"
+	"passbook/pkg/ui"
+)
+
+// SSHList lists the SSH keys with their fingerprints
+func (a *Action) SSHList(c *cli.Context) error {
+	if _, err := a.authorize(rbac.PermCredentialsRead); err != nil {
+		return err
+	}
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+
+	keys, err := s.ListSSHKeys(c.Context)
+	if err != nil {
+		return fmt.Errorf("failed to list SSH keys: %w", err)
+	}
+	if len(keys) == 0 {
+		fmt.Println("No SSH keys found.")
+		fmt.Println("\nAdd one with: passbook ssh add --file ~/.ssh/id_ed25519 NAME")
+		return nil
+	}
+
+	ui.Heading("SSH Keys")
+	fmt.Println()
+	table := ui.NewTable("NAME", "TYPE", "FINGERPRINT", "COMMENT")
+	for _, k := range keys {
+		table.Row(k.Name, k.Type, k.Fingerprint, k.Comment)
+	}
+	table.Print()
+	fmt.Printf("\nTotal: %d key(s)\n", len(keys))
+	return nil
+}
+
+// SSHAdd stores an SSH private key read from --file or stdin. Keys with a
+// passphrase are decrypted first; the store's encryption protects them.
+func (a *Action) SSHAdd(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook ssh add [--file PATH] NAME")
+	}
+	name := c.Args().First()
+	if name == "." || name == ".." || strings.ContainsAny(name, "/\\") {
+		return fmt.Errorf("%w: invalid key name %q", ErrInvalidInput, name)
+	}
+
+	currentUser, err := a.authorize(rbac.PermCredentialsWrite)
+	if err != nil {
+		return err
+	}
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if file := c.String("file"); file != "" && file != "-" {
+		data, err = os.ReadFile(file)
+	} else {
+		data, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	raw, err := ssh.ParseRawPrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		passphrase, perr := termio.PromptPassword("Key passphrase: ")
+		if perr != nil {
+			return perr
+		}
+		raw, err = ssh.ParseRawPrivateKeyWithPassphrase(data, []byte(passphrase))
+	}
+	if err != nil {
+		return fmt.Errorf("%w: not an SSH private key: %v", ErrInvalidInput, err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(raw)
+	if err != nil {
+		return fmt.Errorf("%w: unsupported SSH key: %v", ErrInvalidInput, err)
+	}
+	comment := c.String("comment")
+	if comment == "" {
+		comment = name
+	}
+	block, err := ssh.MarshalPrivateKey(raw, comment)
+	if err != nil {
+		return fmt.Errorf("failed to encode private key: %w", err)
+	}
+	pub := signer.PublicKey()
+
+	key := &models.SSHKey{
+		Name:        name,
+		Type:        pub.Type(),
+		Fingerprint: ssh.FingerprintSHA256(pub),
+		Comment:     comment,
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))) + " " + comment,
+		PrivateKey:  string(pem.EncodeToMemory(block)),
+	}
+	err = s.CreateSSHKey(c.Context, key, currentUser.Email)
+	if errors.Is(err, store.ErrAlreadyExists) {
+		return fmt.Errorf("SSH key %s %w", name, ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save SSH key: %w", err)
+	}
+
+	a.logAudit(audit.EventSSHKeyCreated, name, "fingerprint", key.Fingerprint)
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Add SSH key: %s", name)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Added SSH key %s (%s)", name, key.Fingerprint)
+	fmt.Println(key.PublicKey)
+	return nil
+}
+
+// SSHLoad adds a stored key to the running ssh-agent for a limited time.
+// The decrypted key goes straight to the agent and never touches disk.
+func (a *Action) SSHLoad(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook ssh load [--lifetime DURATION] NAME")
+	}
+	name := c.Args().First()
+	lifetime := c.Duration("lifetime")
+	if lifetime < time.Second {
+		return fmt.Errorf("%w: --lifetime must be at least 1s", ErrInvalidInput)
+	}
+
+	if _, err := a.authorize(rbac.PermCredentialsRead); err != nil {
+		return err
+	}
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return fmt.Errorf("no ssh-agent running (SSH_AUTH_SOCK is not set)")
+	}
+
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+	key, err := s.GetSSHKey(c.Context, name)
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("SSH key %s %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load SSH key: %w", err)
+	}
+	raw, err := ssh.ParseRawPrivateKey([]byte(key.PrivateKey))
+	if err != nil {
+		return fmt.Errorf("failed to parse SSH key %s: %w", name, err)
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return fmt.Errorf("failed to connect to ssh-agent: %w", err)
+	}
+	defer conn.Close()
+
+	err = agent.NewClient(conn).Add(agent.AddedKey{
+		PrivateKey:       raw,
+		Comment:          key.Comment,
+		LifetimeSecs:     uint32(lifetime / time.Second),
+		ConfirmBeforeUse: c.Bool("confirm"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add key to ssh-agent: %w", err)
+	}
+
+	a.logAudit(audit.EventSSHKeyLoaded, name, "lifetime", lifetime.String())
+
+	ui.Successf("Loaded %s into ssh-agent for %s", name, lifetime)
+	return nil
+}
+
+// SSHRemove deletes a stored SSH key
+func (a *Action) SSHRemove(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook ssh rm NAME")
+	}
+	name := c.Args().First()
+
+	if _, err := a.authorize(rbac.PermCredentialsWrite); err != nil {
+		return err
+	}
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+
+	err = s.DeleteSSHKey(c.Context, name)
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("SSH key %s %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete SSH key: %w", err)
+	}
+
+	a.logAudit(audit.EventSSHKeyDeleted, name)
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Delete SSH key: %s", name)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Deleted SSH key: %s", name)
+	return nil
+}
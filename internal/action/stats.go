@@ -0,0 +1,222 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/models"
+	"passbook/pkg/ui"
+)
+
+// storeStats is the inventory reported by passbook stats
+type storeStats struct {
+	Projects         []projectStats `json:"projects"`
+	ArchivedProjects int            `json:"archived_projects"`
+	Credentials      map[string]int `json:"credentials"`
+	UsersByRole      map[string]int `json:"users_by_role"`
+	Members          int            `json:"members"`
+	StoreBytes       int64          `json:"store_bytes"`
+	HistoryBytes     int64          `json:"history_bytes"`
+}
+
+// projectStats describes one project. Variables holds a count per stage,
+// or -1 for stages the current user can't decrypt.
+type projectStats struct {
+	Name         string         `json:"name"`
+	Variables    map[string]int `json:"variables"`
+	LastActivity time.Time      `json:"last_activity,omitzero"`
+}
+
+// Stats summarizes what the store holds, for periodic inventory and for
+// spotting abandoned projects
+func (a *Action) Stats(c *cli.Context) error {
+	stats, err := a.collectStats(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		data, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal stats: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	ui.Heading("Store Statistics")
+	fmt.Println()
+
+	// Projects
+	totalVars := 0
+	table := ui.NewTable("PROJECT", "STAGES", "VARIABLES", "LAST ACTIVITY")
+	for _, p := range stats.Projects {
+		var stages, counts []string
+		for _, stage := range models.AllStages() {
+			n, ok := p.Variables[string(stage)]
+			if !ok {
+				continue
+			}
+			stages = append(stages, string(stage))
+			if n < 0 {
+				counts = append(counts, fmt.Sprintf("%s: %s", stage, ui.Muted("no access")))
+				continue
+			}
+			counts = append(counts, fmt.Sprintf("%s: %d", stage, n))
+			totalVars += n
+		}
+		activity := ui.Muted("never")
+		if !p.LastActivity.IsZero() {
+			activity = fmt.Sprintf("%s (%s)", p.LastActivity.Format("2006-01-02"), formatAge(time.Since(p.LastActivity)))
+		}
+		table.Row(p.Name, strings.Join(stages, ", "), strings.Join(counts, ", "), activity)
+	}
+	fmt.Printf("Projects: %d (%d archived), %d variables readable\n", len(stats.Projects), stats.ArchivedProjects, totalVars)
+	if table.Len() > 0 {
+		table.Print()
+	}
+	fmt.Println()
+
+	// Credentials
+	websites := make([]string, 0, len(stats.Credentials))
+	totalCreds := 0
+	for website, n := range stats.Credentials {
+		websites = append(websites, website)
+		totalCreds += n
+	}
+	sort.Strings(websites)
+	fmt.Printf("Credentials: %d across %d websites\n", totalCreds, len(websites))
+	byWebsite := ui.NewTable()
+	for _, website := range websites {
+		byWebsite.Row(website, strconv.Itoa(stats.Credentials[website]))
+	}
+	byWebsite.Print()
+	fmt.Println()
+
+	// Team
+	fmt.Printf("Team: %d members\n", stats.Members)
+	byRole := ui.NewTable()
+	for _, role := range models.AllRoles() {
+		if n := stats.UsersByRole[string(role)]; n > 0 {
+			byRole.Row(string(role), strconv.Itoa(n))
+		}
+	}
+	byRole.Print()
+	fmt.Println()
+
+	// Size
+	fmt.Printf("Store size: %s (history %s)\n", formatBytes(stats.StoreBytes), formatBytes(stats.HistoryBytes))
+
+	return nil
+}
+
+// collectStats walks the store and gathers its inventory
+func (a *Action) collectStats(c *cli.Context) (*storeStats, error) {
+	storePath := a.cfg.StorePath
+	stats := &storeStats{
+		Credentials: make(map[string]int),
+		UsersByRole: make(map[string]int),
+	}
+
+	// Projects, with variable counts for the stages we can decrypt
+	projectsDir := filepath.Join(storePath, "projects")
+	entries, _ := os.ReadDir(projectsDir)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if err := c.Context.Err(); err != nil {
+			return nil, err
+		}
+
+		p := projectStats{Name: entry.Name(), Variables: make(map[string]int)}
+		for _, stage := range models.AllStages() {
+			if _, err := os.Stat(filepath.Join(projectsDir, entry.Name(), string(stage)+".env.age")); err != nil {
+				continue
+			}
+			envFile, err := a.loadEnvFile(c.Context, entry.Name(), stage)
+			if err != nil {
+				p.Variables[string(stage)] = -1
+				continue
+			}
+			p.Variables[string(stage)] = len(envFile.Vars)
+		}
+		if ts, err := gitLastCommitTime(storePath, filepath.Join("projects", entry.Name())); err == nil && ts > 0 {
+			p.LastActivity = time.Unix(ts, 0)
+		}
+		stats.Projects = append(stats.Projects, p)
+	}
+
+	archived, _ := os.ReadDir(a.archivedProjectsDir())
+	for _, entry := range archived {
+		if entry.IsDir() {
+			stats.ArchivedProjects++
+		}
+	}
+
+	// Credentials per website: credentials/<website>/<name>.age
+	websites, _ := os.ReadDir(filepath.Join(storePath, "credentials"))
+	for _, website := range websites {
+		if !website.IsDir() {
+			continue
+		}
+		files, _ := os.ReadDir(filepath.Join(storePath, "credentials", website.Name()))
+		for _, f := range files {
+			if strings.HasSuffix(f.Name(), ".age") {
+				stats.Credentials[website.Name()]++
+			}
+		}
+	}
+
+	// Team members per role
+	userList, err := a.loadUsers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load users: %w", err)
+	}
+	stats.Members = len(userList.Users)
+	for _, u := range userList.Users {
+		for _, role := range u.Roles {
+			stats.UsersByRole[string(role)]++
+		}
+	}
+
+	// Size of the working tree, and of git history separately
+	filepath.WalkDir(storePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if strings.HasPrefix(path, filepath.Join(storePath, ".git")+string(filepath.Separator)) {
+			stats.HistoryBytes += info.Size()
+		} else {
+			stats.StoreBytes += info.Size()
+		}
+		return nil
+	})
+
+	return stats, nil
+}
+
+// formatAge describes a duration in whole days, such as "today" or "12 days ago"
+func formatAge(d time.Duration) string {
+	switch days := int(d.Hours() / 24); days {
+	case 0:
+		return "today"
+	case 1:
+		return "1 day ago"
+	default:
+		return fmt.Sprintf("%d days ago", days)
+	}
+}
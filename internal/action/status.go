@@ -0,0 +1,379 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/config"
+	"passbook/internal/models"
+	reencrypt_pkg "passbook/internal/reencrypt"
+	"passbook/internal/store"
+	"passbook/internal/token"
+	"passbook/internal/verification"
+	"passbook/pkg/ui"
+)
+
+// expiryWarningWindow is how far ahead status looks for things about to expire
+const expiryWarningWindow = 7 * 24 * time.Hour
+
+// Status shows a one-shot health overview of the store
+func (a *Action) Status(c *cli.Context) error {
+	storePath := a.cfg.StorePath
+	identityPath := a.cfg.IdentityPath()
+
+	ui.Heading("Passbook Status")
+	fmt.Println()
+
+	// Store
+	fmt.Printf("Store:      %s\n", storePath)
+	if !a.cfg.IsInitialized() {
+		fmt.Println("            not initialized (run 'passbook init' or 'passbook clone')")
+		return nil
+	}
+	if a.cfg.StoreVersion < config.StoreVersion {
+		fmt.Printf("            format v%d, current is v%d (run 'passbook migrate')\n", a.cfg.StoreVersion, config.StoreVersion)
+	}
+
+	// Identity
+	if a.cfg.Identity.IsHardware() {
+		fmt.Printf("Identity:   key %s on a PKCS#11 token\n", a.cfg.Identity.PKCS11.KeyID)
+		fmt.Printf("            module %s\n", a.cfg.Identity.PKCS11.Module)
+	} else {
+		fmt.Printf("Identity:   %s\n", identityPath)
+		if !a.cfg.HasIdentity() {
+			fmt.Println("            missing (run 'passbook clone' to generate one)")
+		} else if keyURI, err := age.KMSKeyOf(identityPath); err == nil {
+			fmt.Printf("            wrapped with %s\n", keyURI)
+		} else if encrypted, err := age.IsKeyEncrypted(identityPath); err == nil {
+			if encrypted {
+				fmt.Println("            passphrase-protected")
+			} else {
+				fmt.Println("            unencrypted (consider running 'passbook key encrypt')")
+			}
+		}
+	}
+	if user, err := a.getCurrentUser(); err == nil {
+		fmt.Printf("User:       %s (%s)\n", user.Email, formatRoles(user.Roles))
+	} else {
+		fmt.Println("User:       your key is not in the team yet")
+	}
+	fmt.Println()
+
+	// Git
+	fmt.Println("Git:")
+	if branch, err := gitCurrentBranch(storePath); err == nil && branch != "" {
+		fmt.Printf("  Branch:      %s\n", branch)
+	}
+	if a.cfg.Git.ProdBranch != "" {
+		fmt.Printf("  Prod branch: %s\n", a.cfg.Git.ProdBranch)
+	}
+	if ahead, behind, err := gitAheadBehind(storePath); err == nil {
+		fmt.Printf("  Ahead:       %d commit(s)\n", ahead)
+		fmt.Printf("  Behind:      %d commit(s)\n", behind)
+		if ahead > 0 || behind > 0 {
+			fmt.Println("               run 'passbook sync' to reconcile")
+		}
+	} else {
+		fmt.Println("  Upstream:    none (no remote tracking branch)")
+	}
+	changes, err := gitUncommittedChanges(storePath)
+	if err != nil {
+		return fmt.Errorf("failed to read git status: %w", err)
+	}
+	fmt.Printf("  Uncommitted: %d file(s)\n", len(changes))
+	for _, change := range changes {
+		fmt.Printf("    %s\n", change)
+	}
+	fmt.Println()
+
+	// Team; members whose key isn't verified yet can't decrypt the users file
+	fmt.Println("Team:")
+	var pending []string
+	var members []models.User
+	if userList, err := a.loadUsers(); err != nil {
+		fmt.Printf("  unreadable: %v\n", err)
+	} else {
+		members = userList.Users
+		var keyless, expired []string
+		for _, u := range userList.Users {
+			if u.PublicKey == "" {
+				keyless = append(keyless, u.Email)
+			} else if u.IsPendingVerification() {
+				pending = append(pending, u.Email)
+			} else if u.IsExpired() {
+				expired = append(expired, u.Email)
+			}
+		}
+
+		fmt.Printf("  Members:               %d\n", len(userList.Users))
+		fmt.Printf("  Pending verification:  %d\n", len(pending))
+		for _, email := range pending {
+			fmt.Printf("    - %s\n", email)
+		}
+		fmt.Printf("  Without public key:    %d\n", len(keyless))
+		for _, email := range keyless {
+			fmt.Printf("    - %s\n", email)
+		}
+		if len(expired) > 0 {
+			fmt.Printf("  Access expired:        %d\n", len(expired))
+			for _, email := range expired {
+				fmt.Printf("    - %s\n", email)
+			}
+			fmt.Println("    run 'passbook reencrypt' so existing secrets leave them out too")
+		}
+		active := len(activeAdmins(userList.Users))
+		fmt.Printf("  Active admins:         %d\n", active)
+		if active < recommendedAdmins {
+			fmt.Printf("    %s\n", quorumHealth(active))
+		}
+	}
+	if _, err := os.Stat(filepath.Join(storePath, store.UsersFile)); os.IsNotExist(err) {
+		fmt.Println("  Users file:            plaintext (run 'passbook migrate users' to encrypt it)")
+	}
+	fmt.Println()
+
+	// Secrets
+	stale, err := gitFilesOlderThan(storePath, ".passbook-recipients", reencrypt_pkg.SecretDirs...)
+	if err != nil {
+		return fmt.Errorf("failed to read git history: %w", err)
+	}
+	fmt.Println("Secrets:")
+	fmt.Printf("  Needing re-encryption: %d\n", len(stale))
+	for _, file := range stale {
+		fmt.Printf("    - %s\n", file)
+	}
+	if len(stale) > 0 {
+		fmt.Println("    run 'passbook reencrypt' to update recipients")
+	}
+	fmt.Println()
+
+	// Expiring
+	fmt.Println("Expiring:")
+	var expiring int
+	verifier := verification.NewVerifier(storePath, a.cfg.ConfigDir)
+	for _, email := range pending {
+		pv, err := verifier.GetPendingVerification(email)
+		if err != nil {
+			fmt.Printf("  - verification challenge for %s: lapsed, run 'passbook team rechallenge %s'\n", email, email)
+			expiring++
+			continue
+		}
+		if time.Until(pv.ExpiresAt) < expiryWarningWindow {
+			fmt.Printf("  - verification challenge for %s: expires %s\n", email, pv.ExpiresAt.Format("2006-01-02 15:04"))
+			expiring++
+		}
+	}
+	githubAuth := a.githubAuth()
+	if session, err := githubAuth.LoadSession(); err == nil && !session.ExpiresAt.IsZero() {
+		if time.Until(session.ExpiresAt) < expiryWarningWindow {
+			fmt.Printf("  - GitHub session: expires %s\n", session.ExpiresAt.Format("2006-01-02 15:04"))
+			expiring++
+		}
+	}
+	for _, u := range members {
+		if !u.ExpiresAt.IsZero() && !u.IsExpired() && time.Until(u.ExpiresAt) < expiryWarningWindow {
+			fmt.Printf("  - access for %s: ends %s\n", u.Email, u.ExpiresAt.Format("2006-01-02 15:04"))
+			expiring++
+		}
+	}
+	for _, line := range a.expiringGrants(c.Context) {
+		fmt.Printf("  - %s\n", line)
+		expiring++
+	}
+	if infos, err := token.NewManager(storePath).List(); err == nil {
+		for _, info := range infos {
+			if !info.Expired() && time.Until(info.ExpiresAt) < expiryWarningWindow {
+				fmt.Printf("  - token %s for %s/%s: expires %s\n", info.ID, info.Project, info.Stage, info.ExpiresAt.Format("2006-01-02 15:04"))
+				expiring++
+			}
+		}
+	}
+	if certs, err := a.listCertificates(c); err == nil {
+		for _, cert := range certs {
+			if cert.ExpiresWithin(certWarningWindow) {
+				fmt.Printf("  - certificate %s: %s (%s)\n", cert.Name, certExpiryState(cert), cert.NotAfter.Format("2006-01-02"))
+				expiring++
+			}
+		}
+	}
+	if expiring == 0 {
+		fmt.Println("  Nothing expiring in the next 7 days")
+	}
+
+	return nil
+}
+
+// expiringGrants describes the per-secret grants, on the credentials and
+// env files the local user can read, that end within expiryWarningWindow
+func (a *Action) expiringGrants(ctx context.Context) []string {
+	s, err := a.openStore()
+	if err != nil {
+		return nil
+	}
+	var lines []string
+	add := func(secret string, grants []models.RecipientPermission) {
+		for _, g := range grants {
+			if !g.ExpiresAt.IsZero() && !g.IsExpired() && time.Until(g.ExpiresAt) < expiryWarningWindow {
+				lines = append(lines, fmt.Sprintf("%s's access to %s: ends %s", g.Email, secret, g.ExpiresAt.Format("2006-01-02 15:04")))
+			}
+		}
+	}
+
+	creds, _ := s.ListCredentials(ctx)
+	for _, cred := range creds {
+		if grants, err := s.ListCredentialRecipients(ctx, cred.Website, cred.Name); err == nil {
+			add(cred.Website+"/"+cred.Name, grants)
+		}
+	}
+	projects, _ := s.ListProjects(ctx)
+	for _, project := range projects {
+		stages, _ := s.ListEnvStages(ctx, project.Name)
+		for _, stage := range stages {
+			if grants, err := s.ListEnvRecipients(ctx, project.Name, stage); err == nil {
+				add(project.Name+"/"+string(stage), grants)
+			}
+		}
+	}
+	return lines
+}
+
+// gitCurrentBranch returns the checked out branch name
+func gitCurrentBranch(path string) (string, error) {
+	cmd := exec.Command("git", "branch", "--show-current")
+	cmd.Dir = path
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// gitAheadBehind returns how many commits HEAD is ahead of and behind its upstream
+func gitAheadBehind(path string) (ahead, behind int, err error) {
+	cmd := exec.Command("git", "rev-list", "--left-right", "--count", "HEAD...@{upstream}")
+	cmd.Dir = path
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	fields := strings.Fields(string(output))
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("unexpected rev-list output: %s", string(output))
+	}
+	if ahead, err = strconv.Atoi(fields[0]); err != nil {
+		return 0, 0, err
+	}
+	if behind, err = strconv.Atoi(fields[1]); err != nil {
+		return 0, 0, err
+	}
+	return ahead, behind, nil
+}
+
+// gitUncommittedChanges returns the porcelain status lines of the working tree
+func gitUncommittedChanges(path string) ([]string, error) {
+	cmd := exec.Command("git", "status", "--porcelain")
+	cmd.Dir = path
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) != "" {
+			changes = append(changes, line)
+		}
+	}
+	return changes, nil
+}
+
+// gitFilesOlderThan returns the .age files under dirs whose last commit
+// predates the last commit touching ref. Used to find secrets that were
+// encrypted before the recipients file last changed.
+func gitFilesOlderThan(path, ref string, dirs ...string) ([]string, error) {
+	refTime, err := gitLastCommitTime(path, ref)
+	if err != nil || refTime == 0 {
+		return nil, err
+	}
+
+	files, latest, err := gitLastChanged(path, dirs...)
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []string
+	for _, file := range files {
+		if latest[file] < refTime {
+			stale = append(stale, file)
+		}
+	}
+	return stale, nil
+}
+
+// gitLastChanged returns the .age files under dirs that still exist, most
+// recently changed first, with the unix time of each one's last commit
+func gitLastChanged(path string, dirs ...string) ([]string, map[string]int64, error) {
+	args := append([]string{"log", "--format=@%ct", "--name-only", "--"}, dirs...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = path
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// git log is newest first, so the first time we see a file is its latest commit
+	latest := make(map[string]int64)
+	var order []string
+	var current int64
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "@") {
+			current, _ = strconv.ParseInt(line[1:], 10, 64)
+			continue
+		}
+		if !strings.HasSuffix(line, age.Ext) {
+			continue
+		}
+		if _, seen := latest[line]; !seen {
+			latest[line] = current
+			order = append(order, line)
+		}
+	}
+
+	// Skip files that have since been deleted
+	var files []string
+	for _, file := range order {
+		if _, err := os.Stat(filepath.Join(path, file)); err == nil {
+			files = append(files, file)
+		}
+	}
+	return files, latest, nil
+}
+
+// gitLastCommitTime returns the unix time of the last commit touching a path
+func gitLastCommitTime(path, file string) (int64, error) {
+	cmd := exec.Command("git", "log", "-1", "--format=%ct", "--", file)
+	cmd.Dir = path
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(output))
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
@@ -0,0 +1,134 @@
+package action
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/models"
+	"passbook/pkg/termio"
+	"passbook/pkg/ui"
+)
+
+// stepUpFile records, in the user's config directory, when the local user
+// last re-entered their key passphrase
+const stepUpFile = "step-up"
+
+// stepUpCommands are the sensitive commands that need a recent re-auth when
+// the store sets auth.step_up_minutes, each with whether a given invocation
+// is sensitive
+var stepUpCommands = map[string]func(c *cli.Context) bool{
+	"team revoke":   func(*cli.Context) bool { return true },
+	"reencrypt":     func(*cli.Context) bool { return true },
+	"store migrate": func(c *cli.Context) bool { return !c.Bool("dry-run") },
+	"store split":   func(*cli.Context) bool { return true },
+	"store merge":   func(*cli.Context) bool { return true },
+	"env export": func(c *cli.Context) bool {
+		return c.String("token") == "" && models.Stage(c.Args().Get(1)) == models.StageProd
+	},
+	"env show": func(c *cli.Context) bool {
+		return (c.Bool("dotenv") || c.Bool("export") || c.Bool("reveal")) && models.Stage(c.Args().Get(1)) == models.StageProd
+	},
+	"export pass": func(c *cli.Context) bool { return c.Bool("env") },
+}
+
+// requireStepUp wraps the sensitive commands so they check for a recent
+// re-auth before running
+func (a *Action) requireStepUp(commands []*cli.Command, parent string) {
+	for _, cmd := range commands {
+		path := strings.TrimSpace(parent + " " + cmd.Name)
+		if len(cmd.Subcommands) > 0 {
+			a.requireStepUp(cmd.Subcommands, path)
+		}
+		sensitive, ok := stepUpCommands[path]
+		if cmd.Action == nil || !ok {
+			continue
+		}
+
+		action := cmd.Action
+		cmd.Action = func(c *cli.Context) error {
+			if sensitive(c) {
+				if err := a.stepUp(path); err != nil {
+					return err
+				}
+			}
+			return action(c)
+		}
+	}
+}
+
+// stepUp checks that the user logged in with GitHub or entered their key
+// passphrase within the store's auth.step_up_minutes, asking them to do one
+// of the two when they haven't. Service accounts can't re-auth and are
+// exempt; anyone whose membership can't be looked up is refused.
+func (a *Action) stepUp(command string) error {
+	minutes := a.cfg.Auth.StepUpMinutes
+	if minutes == 0 {
+		return nil
+	}
+	user, err := a.getCurrentUser()
+	if err != nil {
+		return err
+	}
+	if user.IsServiceAccount() {
+		return nil
+	}
+
+	window := time.Duration(minutes) * time.Minute
+	if since, ok := a.lastStepUp(); ok && since < window {
+		return nil
+	}
+
+	if !termio.IsTerminal() {
+		return fmt.Errorf("%w: '%s' needs a re-auth within the last %d minutes; run it from a terminal", ErrAccessDenied, command, minutes)
+	}
+	fmt.Printf("'%s' needs you to re-authenticate.\n", command)
+
+	identityPath := a.cfg.IdentityPath()
+	if encrypted, _ := age.IsKeyEncrypted(identityPath); encrypted {
+		passphrase, err := age.PromptPassphrase("Key passphrase: ")
+		if err != nil {
+			return fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		if _, err := age.NewWithPassphrase(identityPath, passphrase); err != nil {
+			return fmt.Errorf("%w: wrong passphrase", ErrAccessDenied)
+		}
+		if err := os.WriteFile(filepath.Join(a.cfg.ConfigDir, stepUpFile), []byte(time.Now().UTC().Format(time.RFC3339)+"\n"), 0600); err != nil {
+			ui.Warningf("failed to record re-auth: %v", err)
+		}
+		return nil
+	}
+
+	session, err := a.githubAuth().Reauthenticate()
+	if err != nil {
+		return fmt.Errorf("%w: re-authentication failed: %v", ErrAccessDenied, err)
+	}
+	if user.GitHubID != 0 && session.GitHubID != user.GitHubID {
+		_ = a.githubAuth().ClearSession()
+		return fmt.Errorf("%w: %s is bound to GitHub account %s, not @%s", ErrAccessDenied, user.Email, formatGitHub(user.GitHubLogin, user.GitHubID), session.GitHubLogin)
+	}
+	return nil
+}
+
+// lastStepUp returns how long ago the user last logged in with GitHub or
+// entered their passphrase for a step-up
+func (a *Action) lastStepUp() (time.Duration, bool) {
+	var last time.Time
+	if session, err := a.githubAuth().Session(); err == nil {
+		last = session.AuthenticatedAt
+	}
+	if data, err := os.ReadFile(filepath.Join(a.cfg.ConfigDir, stepUpFile)); err == nil {
+		if t, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data))); err == nil && t.After(last) {
+			last = t
+		}
+	}
+	if last.IsZero() {
+		return 0, false
+	}
+	return time.Since(last), true
+}
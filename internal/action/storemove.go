@@ -0,0 +1,340 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/audit"
+	"passbook/internal/config"
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/pkg/ui"
+)
+
+// StoreMigrate moves the store to a new remote, optionally renaming the
+// organization and moving members from one email domain to another. The
+// old remote gets a final commit recording the move, so teammates switch
+// over on their next pull.
+func (a *Action) StoreMigrate(c *cli.Context) error {
+	newRemote := c.String("new-remote")
+	newDomain := strings.ToLower(strings.TrimPrefix(c.String("new-domain"), "@"))
+	oldDomain := strings.ToLower(strings.TrimPrefix(c.String("old-domain"), "@"))
+	if newRemote == "" {
+		return fmt.Errorf("usage: passbook store migrate --new-remote URL [--new-domain DOMAIN [--old-domain DOMAIN]] [--org NAME] [--dry-run]")
+	}
+	if newDomain != "" && oldDomain == "" {
+		oldDomain = a.cfg.Org.AllowedDomain
+		if oldDomain == "" {
+			return fmt.Errorf("the store has no single allowed domain; pass --old-domain")
+		}
+	}
+
+	if _, err := a.authorize(rbac.PermStoreConfig); err != nil {
+		return err
+	}
+
+	oldRemote := a.storeOrigin()
+	if oldRemote == newRemote {
+		return fmt.Errorf("the store already uses %s", newRemote)
+	}
+
+	// Fail before changing anything if the new remote can't be reached
+	fmt.Print("Checking new remote... ")
+	if err := ui.Spin(func() error { return gitLsRemote(c.Context, newRemote) }); err != nil {
+		fmt.Println(ui.Fail("FAILED"))
+		return fmt.Errorf("cannot reach %s: %w", newRemote, err)
+	}
+	fmt.Println(ui.Success("OK"))
+
+	if oldRemote != "" {
+		if err := a.pull(c.Context); err != nil {
+			return fmt.Errorf("pull failed; sync the store before moving it: %w", err)
+		}
+	}
+
+	userList, err := a.loadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+	renames := emailRenames(userList.Users, oldDomain, newDomain)
+
+	// Plan
+	ui.Heading("Store migration")
+	fmt.Printf("  Remote:  %s -> %s\n", orDefault(oldRemote, "(none)"), newRemote)
+	if org := c.String("org"); org != "" {
+		fmt.Printf("  Org:     %s -> %s\n", orDefault(a.cfg.Org.Name, "(none)"), org)
+	}
+	if newDomain != "" {
+		fmt.Printf("  Domain:  @%s -> @%s (%d members)\n", oldDomain, newDomain, len(renames))
+		for _, u := range userList.Users {
+			if to, ok := renames[u.Email]; ok {
+				fmt.Printf("    %s -> %s\n", u.Email, to)
+			}
+		}
+	}
+	fmt.Println()
+	if c.Bool("dry-run") {
+		fmt.Println("Dry run; nothing changed.")
+		return nil
+	}
+
+	// Rename members everywhere their email is recorded
+	var skipped []string
+	if len(renames) > 0 {
+		skipped, err = a.renameMembers(c.Context, userList, renames)
+		if err != nil {
+			return err
+		}
+		if to, ok := renames[a.cfg.Identity.Email]; ok {
+			a.cfg.Identity.Email = to
+			if err := a.cfg.Save(); err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+		}
+	}
+
+	// Store config: the new remote, and where clones should move from
+	if newDomain != "" {
+		if a.cfg.Org.AllowedDomain == oldDomain {
+			a.cfg.Org.AllowedDomain = newDomain
+		} else {
+			a.cfg.Org.AllowedDomains = append(a.cfg.Org.AllowedDomains, newDomain)
+		}
+		a.cfg.Org.AllowedDomains = slices.DeleteFunc(a.cfg.Org.AllowedDomains, func(d string) bool { return d == oldDomain })
+	}
+	if org := c.String("org"); org != "" {
+		a.cfg.Org.Name = org
+	}
+	if oldRemote != "" && !slices.Contains(a.cfg.Git.MovedFrom, oldRemote) {
+		a.cfg.Git.MovedFrom = append(a.cfg.Git.MovedFrom, oldRemote)
+	}
+	a.cfg.Git.Remote = newRemote
+	if err := a.cfg.SaveStoreConfig(); err != nil {
+		return fmt.Errorf("failed to save store config: %w", err)
+	}
+
+	a.logAudit(audit.EventStoreMoved, newRemote, "from", oldRemote, "org", a.cfg.Org.Name, "renamed", fmt.Sprintf("%d", len(renames)))
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Move store to %s", newRemote)); err != nil {
+		return err
+	}
+
+	// The old remote gets the move first so teammates can follow it
+	if oldRemote != "" {
+		fmt.Print("Recording the move on the old remote... ")
+		if err := ui.Spin(func() error { return a.push(c.Context) }); err != nil {
+			fmt.Println(ui.Fail("FAILED"))
+			ui.Warningf("teammates won't be switched over automatically: %v", err)
+		} else {
+			fmt.Println(ui.Success("OK"))
+		}
+	}
+
+	if err := gitSetRemote(a.cfg.StorePath, newRemote); err != nil {
+		return err
+	}
+	fmt.Print("Pushing to the new remote... ")
+	if err := ui.Spin(func() error { return a.push(c.Context) }); err != nil {
+		fmt.Println(ui.Fail("FAILED"))
+		return fmt.Errorf("push failed; run 'passbook sync' once %s accepts pushes: %w", newRemote, err)
+	}
+	fmt.Println(ui.Success("OK"))
+
+	fmt.Println()
+	ui.Successf("Moved the store to %s", newRemote)
+	for _, path := range skipped {
+		ui.Warningf("couldn't check %s for renamed members (you can't read it); someone who can should re-grant its access", path)
+	}
+	fmt.Println("Teammates switch over on their next 'passbook sync'; new clones use the new URL.")
+	if oldRemote != "" {
+		fmt.Printf("Keep %s read-only until everyone has synced, then archive it.\n", oldRemote)
+	}
+	return nil
+}
+
+// emailRenames maps the email of each member at oldDomain to newDomain.
+// Service accounts and external collaborators keep their addresses.
+func emailRenames(users []models.User, oldDomain, newDomain string) map[string]string {
+	renames := make(map[string]string)
+	if newDomain == "" {
+		return renames
+	}
+	for _, u := range users {
+		local, domain, ok := strings.Cut(u.Email, "@")
+		if !ok || !strings.EqualFold(domain, oldDomain) || u.IsServiceAccount() || u.External {
+			continue
+		}
+		renames[u.Email] = local + "@" + newDomain
+	}
+	return renames
+}
+
+// renameMembers rewrites members' emails in the users and recipients files,
+// project owners, and the access lists of secrets, re-encrypting the secrets
+// whose access lists change. Returns the secrets it couldn't read.
+func (a *Action) renameMembers(ctx context.Context, userList *models.UserList, renames map[string]string) ([]string, error) {
+	for i, u := range userList.Users {
+		if to, ok := renames[u.Email]; ok {
+			userList.Users[i].Email = to
+			if userList.Users[i].Name == u.Email {
+				userList.Users[i].Name = to
+			}
+		}
+	}
+	if err := a.saveUsers(userList); err != nil {
+		return nil, fmt.Errorf("failed to save users: %w", err)
+	}
+	if err := a.updateRecipientsFile(userList); err != nil {
+		return nil, fmt.Errorf("failed to update recipients: %w", err)
+	}
+
+	s, err := a.openStore()
+	if err != nil {
+		return nil, err
+	}
+	var skipped []string
+
+	projects, err := s.ListProjects(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list projects: %w", err)
+	}
+	for _, p := range projects {
+		if renameEmails(p.Owners, renames) {
+			err := s.UpdateProject(ctx, p.Name, func(p *models.Project) { renameEmails(p.Owners, renames) })
+			if err != nil {
+				return nil, fmt.Errorf("failed to update project %s: %w", p.Name, err)
+			}
+		}
+
+		stages, _ := s.ListEnvStages(ctx, p.Name)
+		for _, stage := range stages {
+			envFile, err := s.GetEnvFile(ctx, p.Name, stage)
+			if err != nil {
+				skipped = append(skipped, fmt.Sprintf("%s/%s", p.Name, stage))
+				continue
+			}
+			if renamePermissions(envFile.Permissions, renames) {
+				if err := a.saveEnvFile(ctx, envFile); err != nil {
+					return nil, fmt.Errorf("failed to save %s/%s: %w", p.Name, stage, err)
+				}
+			}
+		}
+	}
+
+	creds, err := s.ListCredentials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credentials: %w", err)
+	}
+	for _, summary := range creds {
+		cred, err := s.GetCredential(ctx, summary.Website, summary.Name)
+		if err != nil {
+			skipped = append(skipped, summary.Website+"/"+summary.Name)
+			continue
+		}
+		if renamePermissions(cred.Permissions, renames) {
+			if err := a.saveCredential(ctx, cred); err != nil {
+				return nil, fmt.Errorf("failed to save %s/%s: %w", cred.Website, cred.Name, err)
+			}
+		}
+	}
+
+	return skipped, nil
+}
+
+// renameEmails renames emails in place and reports whether any changed
+func renameEmails(emails []string, renames map[string]string) bool {
+	changed := false
+	for i, e := range emails {
+		if to, ok := renames[e]; ok {
+			emails[i] = to
+			changed = true
+		}
+	}
+	return changed
+}
+
+// renamePermissions renames the recipients of an access list and reports
+// whether any changed
+func renamePermissions(perms *models.SecretPermissions, renames map[string]string) bool {
+	if perms == nil {
+		return false
+	}
+	changed := false
+	for i, r := range perms.Recipients {
+		if to, ok := renames[r.Email]; ok {
+			perms.Recipients[i].Email = to
+			changed = true
+		}
+	}
+	return changed
+}
+
+// followStoreMove switches the store's origin to the new remote when the
+// store config, just pulled, says the store moved away from the current one.
+// It also picks up a rename of the user's own email.
+func (a *Action) followStoreMove(ctx context.Context) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	origin := a.storeOrigin()
+	if origin == "" || cfg.Git.Remote == "" || cfg.Git.Remote == origin || !slices.Contains(cfg.Git.MovedFrom, origin) {
+		return nil
+	}
+
+	if err := gitSetRemote(a.cfg.StorePath, cfg.Git.Remote); err != nil {
+		return err
+	}
+	a.cfg.Git, a.cfg.Org = cfg.Git, cfg.Org
+	ui.Warningf("the store moved to %s; switched this clone over", cfg.Git.Remote)
+
+	if user, err := a.getCurrentUser(); err == nil && user.Email != a.cfg.Identity.Email {
+		a.cfg.Identity.Email = user.Email
+		if err := a.cfg.Save(); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		fmt.Printf("Your email in the team is now %s\n", user.Email)
+	}
+
+	return gitPull(ctx, a.cfg.StorePath)
+}
+
+// storeOrigin returns the URL of the store's origin remote
+func (a *Action) storeOrigin() string {
+	cmd := exec.Command("git", "remote", "get-url", "origin")
+	cmd.Dir = a.cfg.StorePath
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// gitSetRemote points the store's origin at url, adding it if missing
+func gitSetRemote(path, url string) error {
+	cmd := exec.Command("git", "remote", "set-url", "origin", url)
+	cmd.Dir = path
+	if _, err := cmd.CombinedOutput(); err != nil {
+		cmd = exec.Command("git", "remote", "add", "origin", url)
+		cmd.Dir = path
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to set remote: %s", strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// gitLsRemote checks that a remote can be reached
+func gitLsRemote(ctx context.Context, url string) error {
+	cmd := exec.CommandContext(ctx, "git", "ls-remote", url)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,367 @@
+package action
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/audit"
+	"passbook/internal/backend/storage/gitfs"
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/internal/reencrypt"
+	"passbook/pkg/ui"
+)
+
+// mergeDirs are the store directories a merge brings over: everything
+// encrypted for the team, and members' personal vaults
+var mergeDirs = append(slices.Clone(reencrypt.SecretDirs), "personal")
+
+// auditLogFile is the store's audit log
+const auditLogFile = ".passbook-audit.log"
+
+// StoreSplit moves projects into a new store, with the members who can
+// reach them and their slice of the audit log, and pushes it to a new
+// remote. The secrets are moved still encrypted, so nothing is decrypted.
+func (a *Action) StoreSplit(c *cli.Context) error {
+	to := c.String("to")
+	projects := c.StringSlice("projects")
+	if to == "" || len(projects) == 0 {
+		return fmt.Errorf("usage: passbook store split --projects A,B --to URL [--keep]")
+	}
+
+	if _, err := a.authorize(rbac.PermStoreConfig); err != nil {
+		return err
+	}
+	if a.cfg.Git.ProdBranch != "" {
+		return fmt.Errorf("store split doesn't support the branch-per-environment layout; run 'passbook layout' to switch back first")
+	}
+
+	s, err := a.openStore()
+	if err != nil {
+		return err
+	}
+	var stages []models.Stage
+	var owners []string
+	for _, name := range projects {
+		p, err := s.GetProject(c.Context, name)
+		if err != nil {
+			return fmt.Errorf("project %s %w", name, ErrNotFound)
+		}
+		owners = append(owners, p.Owners...)
+		projectStages, _ := s.ListEnvStages(c.Context, name)
+		stages = append(stages, projectStages...)
+		for _, stage := range projectStages {
+			if envFile, err := s.GetEnvFile(c.Context, name, stage); err == nil && envFile.Permissions != nil {
+				for _, r := range envFile.Permissions.Recipients {
+					owners = append(owners, r.Email)
+				}
+			}
+		}
+	}
+
+	// Members who can reach the projects, and the admins
+	userList, err := a.loadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+	engine := a.policy()
+	members := slices.DeleteFunc(slices.Clone(userList.Users), func(u models.User) bool {
+		if u.IsAdmin() || slices.Contains(owners, u.Email) {
+			return false
+		}
+		return !slices.ContainsFunc(stages, func(stage models.Stage) bool { return engine.CanAccessStage(&u, stage, false) })
+	})
+
+	// Build the new store in a scratch checkout, then push it
+	tmp, err := os.MkdirTemp("", "passbook-split-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	split := a.atStore(filepath.Join(tmp, "store"))
+
+	repo, err := gitfs.Init(split.cfg.StorePath, to)
+	if err != nil {
+		return err
+	}
+	for _, name := range projects {
+		if err := copyTree(filepath.Join(a.cfg.StorePath, "projects", name), filepath.Join(split.cfg.StorePath, "projects", name)); err != nil {
+			return fmt.Errorf("failed to copy project %s: %w", name, err)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(a.cfg.StorePath, ".gitignore")); err == nil {
+		if err := os.WriteFile(filepath.Join(split.cfg.StorePath, ".gitignore"), data, 0600); err != nil {
+			return err
+		}
+	}
+	split.cfg.Git.Remote, split.cfg.Git.MovedFrom = to, nil
+	if err := split.cfg.SaveStoreConfig(); err != nil {
+		return fmt.Errorf("failed to write store config: %w", err)
+	}
+	splitUsers := &models.UserList{Users: members}
+	if err := split.saveUsers(splitUsers); err != nil {
+		return fmt.Errorf("failed to write users: %w", err)
+	}
+	if err := split.updateRecipientsFile(splitUsers); err != nil {
+		return fmt.Errorf("failed to write recipients: %w", err)
+	}
+	events, err := copyAuditEvents(a.cfg.StorePath, split.cfg.StorePath, func(e audit.Event) bool {
+		return slices.ContainsFunc(projects, func(p string) bool { return e.Target == p || strings.HasPrefix(e.Target, p+"/") })
+	})
+	if err != nil {
+		return err
+	}
+	split.logAudit(audit.EventStoreSplit, strings.Join(projects, ","), "from", a.storeOrigin(), "members", fmt.Sprintf("%d", len(members)))
+	split.refreshManifest()
+
+	if err := repo.Commit(c.Context, fmt.Sprintf("Split %s from %s", strings.Join(projects, ", "), orDefault(a.cfg.Org.Name, "store"))); err != nil {
+		return fmt.Errorf("commit failed: %w", err)
+	}
+	if branch, err := repo.GetCurrentBranch(); err == nil && branch != "" {
+		repo.SetBranch(branch)
+	}
+	fmt.Printf("Pushing %s to %s... ", strings.Join(projects, ", "), to)
+	if err := ui.Spin(func() error { return repo.Push(c.Context) }); err != nil {
+		fmt.Println(ui.Fail("FAILED"))
+		return fmt.Errorf("push failed: %w", err)
+	}
+	fmt.Println(ui.Success("OK"))
+
+	// Remove the projects from this store
+	if !c.Bool("keep") {
+		for _, name := range projects {
+			if err := os.RemoveAll(filepath.Join(a.cfg.StorePath, "projects", name)); err != nil {
+				return fmt.Errorf("failed to remove project %s: %w", name, err)
+			}
+		}
+	}
+	a.logAudit(audit.EventStoreSplit, strings.Join(projects, ","), "to", to, "members", fmt.Sprintf("%d", len(members)), "kept", fmt.Sprintf("%t", c.Bool("keep")))
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Split %s to %s", strings.Join(projects, ", "), to)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Split %d project(s) to %s with %d member(s) and %d audit event(s)", len(projects), to, len(members), events)
+	fmt.Printf("Members clone it with: passbook clone %s\n", to)
+	if slices.ContainsFunc(members, func(u models.User) bool { return u.IsServiceAccount() }) {
+		fmt.Println("Service account tokens aren't moved; issue new ones from the new store.")
+	}
+	return nil
+}
+
+// StoreMerge brings another store's secrets, members and audit log into
+// this one. You must be a member of both. Secrets stay encrypted for the
+// other store's members until the next re-encryption.
+func (a *Action) StoreMerge(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook store merge OTHER_REPO")
+	}
+	source := c.Args().First()
+
+	if _, err := a.authorize(rbac.PermStoreConfig); err != nil {
+		return err
+	}
+
+	tmp, err := os.MkdirTemp("", "passbook-merge-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	other := a.atStore(filepath.Join(tmp, "store"))
+
+	fmt.Printf("Fetching %s... ", source)
+	if err := ui.Spin(func() error { _, err := gitfs.Clone(source, other.cfg.StorePath); return err }); err != nil {
+		fmt.Println(ui.Fail("FAILED"))
+		return err
+	}
+	fmt.Println(ui.Success("OK"))
+
+	otherUsers, err := other.loadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to read %s's members; you must be a member of both stores: %w", source, err)
+	}
+	userList, err := a.loadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+
+	// Check for conflicts before changing anything
+	var conflicts, added []string
+	for _, u := range otherUsers.Users {
+		i := slices.IndexFunc(userList.Users, func(existing models.User) bool { return strings.EqualFold(existing.Email, u.Email) })
+		switch {
+		case i < 0:
+			added = append(added, u.Email)
+		case userList.Users[i].PublicKey != u.PublicKey:
+			conflicts = append(conflicts, fmt.Sprintf("member %s has a different key", u.Email))
+		}
+	}
+	var files []string
+	for _, dir := range mergeDirs {
+		err := filepath.WalkDir(filepath.Join(other.cfg.StorePath, dir), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			rel, _ := filepath.Rel(other.cfg.StorePath, path)
+			existing, err := os.ReadFile(filepath.Join(a.cfg.StorePath, rel))
+			if err != nil {
+				files = append(files, rel)
+				return nil
+			}
+			incoming, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(existing, incoming) {
+				conflicts = append(conflicts, fmt.Sprintf("%s exists in both stores", filepath.ToSlash(rel)))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(conflicts) > 0 {
+		for _, conflict := range conflicts {
+			fmt.Printf("  %s\n", conflict)
+		}
+		return fmt.Errorf("cannot merge %s: %d conflict(s) %w", source, len(conflicts), ErrConflict)
+	}
+
+	// Bring over members, secrets and the audit log
+	for _, email := range added {
+		i := slices.IndexFunc(otherUsers.Users, func(u models.User) bool { return u.Email == email })
+		userList.Users = append(userList.Users, otherUsers.Users[i])
+	}
+	if err := a.saveUsers(userList); err != nil {
+		return fmt.Errorf("failed to save users: %w", err)
+	}
+	if err := a.updateRecipientsFile(userList); err != nil {
+		return fmt.Errorf("failed to update recipients: %w", err)
+	}
+	for _, rel := range files {
+		data, err := os.ReadFile(filepath.Join(other.cfg.StorePath, rel))
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(a.cfg.StorePath, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dst, data, 0600); err != nil {
+			return err
+		}
+	}
+	events, err := copyAuditEvents(other.cfg.StorePath, a.cfg.StorePath, func(audit.Event) bool { return true })
+	if err != nil {
+		return err
+	}
+
+	a.logAudit(audit.EventStoreMerged, source, "files", fmt.Sprintf("%d", len(files)), "members", fmt.Sprintf("%d", len(added)))
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Merge store %s", source)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Merged %d file(s), %d new member(s) and %d audit event(s) from %s", len(files), len(added), events, source)
+	if len(files) > 0 {
+		fmt.Println("Merged secrets are still encrypted for the other store's members; run 'passbook reencrypt' to share them with this team.")
+	}
+	return nil
+}
+
+// atStore returns an Action working on the store at path, with this one's
+// config and identity
+func (a *Action) atStore(path string) *Action {
+	cfg := *a.cfg
+	cfg.StorePath = path
+	return &Action{cfg: &cfg}
+}
+
+// copyTree copies the files under src to dst
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0700)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0600)
+	})
+}
+
+// copyAuditEvents appends the events of one store's audit log that match
+// keep to another's, skipping any already there, and returns how many it
+// copied
+func copyAuditEvents(fromStore, toStore string, keep func(audit.Event) bool) (int, error) {
+	data, err := os.ReadFile(filepath.Join(fromStore, auditLogFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	if existing, err := os.ReadFile(filepath.Join(toStore, auditLogFile)); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(existing))
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			var e audit.Event
+			if json.Unmarshal(scanner.Bytes(), &e) == nil {
+				seen[e.ID] = true
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	count := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var e audit.Event
+		if json.Unmarshal(scanner.Bytes(), &e) != nil || seen[e.ID] || !keep(e) {
+			continue
+		}
+		out.Write(scanner.Bytes())
+		out.WriteByte('\n')
+		count++
+	}
+	if count == 0 {
+		return 0, nil
+	}
+
+	f, err := os.OpenFile(filepath.Join(toStore, auditLogFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(out.Bytes()); err != nil {
+		return 0, fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return count, nil
+}
@@ -1,10 +1,20 @@
 package action
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/urfave/cli/v2"
+
+	"passbook/internal/models"
+	reencrypt_pkg "passbook/internal/reencrypt"
+	"passbook/internal/token"
+	"passbook/pkg/ui"
 )
 
 // Sync synchronizes with git remote
@@ -12,43 +22,60 @@ func (a *Action) Sync(c *cli.Context) error {
 	pushOnly := c.Bool("push")
 	pullOnly := c.Bool("pull")
 
-	storePath := a.cfg.StorePath
+	if c.Bool("repair") {
+		return a.syncRepair(c)
+	}
+	if c.Bool("ours") || c.Bool("theirs") {
+		return fmt.Errorf("%w: --ours and --theirs go with --repair", ErrInvalidInput)
+	}
 
 	if pullOnly {
 		fmt.Print("Pulling from remote... ")
-		if err := gitPull(storePath); err != nil {
-			fmt.Println("FAILED")
+		if err := ui.Spin(func() error { return a.pull(c.Context) }); err != nil {
+			fmt.Println(ui.Fail("FAILED"))
 			return fmt.Errorf("pull failed: %w", err)
 		}
-		fmt.Println("OK")
+		fmt.Println(ui.Success("OK"))
 		return nil
 	}
 
 	if pushOnly {
 		fmt.Print("Pushing to remote... ")
-		if err := gitPush(storePath); err != nil {
-			fmt.Println("FAILED")
+		if err := ui.Spin(func() error { return a.pushWithRecovery(c.Context) }); err != nil {
+			fmt.Println(ui.Fail("FAILED"))
 			return fmt.Errorf("push failed: %w", err)
 		}
-		fmt.Println("OK")
+		fmt.Println(ui.Success("OK"))
 		return nil
 	}
 
 	// Full sync: pull then push
 	fmt.Print("Pulling from remote... ")
-	if err := gitPull(storePath); err != nil {
+	if err := ui.Spin(func() error { return a.pull(c.Context) }); err != nil {
+		if c.Context.Err() != nil {
+			fmt.Println(ui.Fail("interrupted"))
+			return c.Context.Err()
+		}
+		if errors.Is(err, ErrHistoryRewritten) {
+			fmt.Println(ui.Fail("FAILED"))
+			return err
+		}
+		if gitAbortRebase(a.cfg.StorePath) {
+			fmt.Println(ui.Fail("FAILED"))
+			return fmt.Errorf("%w; run 'passbook sync --repair'", ErrDiverged)
+		}
 		// Pull might fail on first sync, that's ok
 		fmt.Println("skipped (no remote history)")
 	} else {
-		fmt.Println("OK")
+		fmt.Println(ui.Success("OK"))
 	}
 
 	fmt.Print("Pushing to remote... ")
-	if err := gitPush(storePath); err != nil {
-		fmt.Println("FAILED")
+	if err := ui.Spin(func() error { return a.pushWithRecovery(c.Context) }); err != nil {
+		fmt.Println(ui.Fail("FAILED"))
 		return fmt.Errorf("push failed: %w", err)
 	}
-	fmt.Println("OK")
+	fmt.Println(ui.Success("OK"))
 
 	fmt.Println("Sync complete!")
 	return nil
@@ -56,49 +83,116 @@ func (a *Action) Sync(c *cli.Context) error {
 
 // GitSync performs a full git sync (pull + push)
 // This is called by other commands when autopush is enabled
-func (a *Action) GitSync() error {
+func (a *Action) GitSync(ctx context.Context) error {
 	if !a.cfg.Git.AutoSync {
 		return nil
 	}
 
-	storePath := a.cfg.StorePath
-
 	// Try to pull first (ignore errors on empty remote)
-	_ = gitPull(storePath)
+	_ = a.pull(ctx)
 
 	// Push changes
 	if a.cfg.Git.AutoPush {
-		return gitPush(storePath)
+		return a.push(ctx)
 	}
 
 	return nil
 }
 
-// GitCommitAndSync commits changes and syncs if autopush is enabled
-func (a *Action) GitCommitAndSync(message string) error {
+// GitCommitAndSync commits changes and syncs if autopush is enabled. A
+// commit takes every change in the working tree, so while changes made with
+// --no-commit are waiting this change joins them instead, for 'passbook
+// commit' to commit together.
+func (a *Action) GitCommitAndSync(ctx context.Context, message string) error {
+	if queued, err := a.loadCommitQueue(); err == nil && len(queued) > 0 {
+		if err := a.queueCommitMessage(message); err != nil {
+			return fmt.Errorf("failed to queue commit message: %w", err)
+		}
+		return fmt.Errorf("%d change(s) made with --no-commit are waiting, so this one was left uncommitted with them; run 'passbook commit'", len(queued))
+	}
+	return a.commitAndSync(ctx, message)
+}
+
+// commitAndSync commits everything in the working tree and syncs if
+// autopush is enabled
+func (a *Action) commitAndSync(ctx context.Context, message string) error {
 	storePath := a.cfg.StorePath
 
+	// Admin commits re-sign the integrity manifest
+	a.refreshManifest()
+	a.configureGitAuthor()
+
+	changes, _ := gitUncommittedChanges(storePath)
+	message, err := a.commitMessage(message, changes)
+	if err != nil {
+		return err
+	}
+
+	// In the branch-per-environment layout, prod env files are committed
+	// to their own branch
+	prodChanged := false
+	if a.cfg.Git.ProdBranch != "" {
+		changed, err := a.commitProdBranch(message)
+		if err != nil {
+			return err
+		}
+		prodChanged = changed
+	}
+
 	// Add and commit
-	if err := gitCommit(storePath, message); err != nil {
-		return fmt.Errorf("commit failed: %w", err)
+	if changes, err := gitUncommittedChanges(storePath); err != nil || len(changes) > 0 || !prodChanged {
+		if err := gitCommit(storePath, message); err != nil {
+			return fmt.Errorf("commit failed: %w", err)
+		}
 	}
 
 	// Sync if enabled
 	if a.cfg.Git.AutoPush {
-		if err := gitPush(storePath); err != nil {
+		if err := a.pushWithRecovery(ctx); err != nil {
 			// Don't fail the command, just warn
-			fmt.Printf("Warning: auto-push failed: %v\n", err)
-			fmt.Println("Run 'passbook sync' to push manually")
+			ui.Warningf("auto-push failed: %v", err)
+			if errors.Is(err, ErrDiverged) {
+				fmt.Println("Your change is committed locally; run 'passbook sync --repair' to reconcile it with the remote")
+			} else {
+				fmt.Println("Run 'passbook sync' to push manually")
+			}
 		}
 	}
 
+	// Keep mirrors marked auto in step with the store
+	a.publishAuto(ctx)
+
 	return nil
 }
 
+// pull pulls the store, and in the branch-per-environment layout the prod
+// branch too
+func (a *Action) pull(ctx context.Context) error {
+	if err := a.checkRewritten(ctx); err != nil {
+		return err
+	}
+	if err := gitPull(ctx, a.cfg.StorePath); err != nil {
+		return err
+	}
+	if err := a.followStoreMove(ctx); err != nil {
+		return err
+	}
+	return a.pullProdBranch(ctx)
+}
+
+// push pushes the store, and in the branch-per-environment layout the prod
+// branch too
+func (a *Action) push(ctx context.Context) error {
+	if err := gitPush(ctx, a.cfg.StorePath); err != nil {
+		return err
+	}
+	return a.pushProdBranch(ctx)
+}
+
 // Git helper functions
 
-func gitPull(path string) error {
-	cmd := exec.Command("git", "pull", "--rebase")
+func gitPull(ctx context.Context, path string) error {
+	cmd := exec.CommandContext(ctx, "git", "pull", "--rebase", "--autostash")
 	cmd.Dir = path
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -107,8 +201,8 @@ func gitPull(path string) error {
 	return nil
 }
 
-func gitPush(path string) error {
-	cmd := exec.Command("git", "push")
+func gitPush(ctx context.Context, path string) error {
+	cmd := exec.CommandContext(ctx, "git", "push")
 	cmd.Dir = path
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -116,3 +210,143 @@ func gitPush(path string) error {
 	}
 	return nil
 }
+
+// Fetch downloads objects from the remote, optionally extending a shallow
+// clone's history or refreshing the sparse checkout to match current access
+func (a *Action) Fetch(c *cli.Context) error {
+	deepen := c.Int("deepen")
+	unshallow := c.Bool("unshallow")
+	sparse := c.Bool("sparse")
+
+	if deepen > 0 && unshallow {
+		return fmt.Errorf("--deepen and --unshallow cannot be used together")
+	}
+
+	storePath := a.cfg.StorePath
+
+	args := []string{"fetch"}
+	switch {
+	case unshallow:
+		if !gitIsShallow(storePath) {
+			fmt.Println("Repository already has full history.")
+		} else {
+			args = append(args, "--unshallow")
+		}
+	case deepen > 0:
+		if !gitIsShallow(storePath) {
+			fmt.Println("Repository already has full history.")
+		} else {
+			args = append(args, "--deepen", strconv.Itoa(deepen))
+		}
+	}
+
+	fmt.Print("Fetching from remote... ")
+	cmd := exec.CommandContext(c.Context, "git", args...)
+	cmd.Dir = storePath
+	var output []byte
+	if err := ui.Spin(func() (err error) { output, err = cmd.CombinedOutput(); return err }); err != nil {
+		fmt.Println(ui.Fail("FAILED"))
+		if c.Context.Err() != nil {
+			return c.Context.Err()
+		}
+		return fmt.Errorf("fetch failed: %s", string(output))
+	}
+	fmt.Println(ui.Success("OK"))
+
+	if sparse {
+		fmt.Print("Updating sparse checkout... ")
+		stages, err := a.applySparseCheckout()
+		if err != nil {
+			fmt.Println(ui.Fail("FAILED"))
+			return fmt.Errorf("failed to update sparse checkout: %w", err)
+		}
+		fmt.Println(ui.Success("OK"))
+		if stages == nil {
+			fmt.Println("  You are not in the team yet; only top-level files and tokens are checked out.")
+		} else {
+			fmt.Printf("  Stages checked out: %s\n", joinStages(stages))
+		}
+	}
+
+	return nil
+}
+
+// sparseDirs are the store's top-level directories a sparse clone checks
+// out. Env files under the project directories are limited to the stages
+// the member can access; everything else in them is checked out whole.
+var sparseDirs = append(slices.Clone(reencrypt_pkg.SecretDirs), "personal", token.Dir, campaignsDir)
+
+// stageDirs are the sparse directories that hold projects' env files
+var stageDirs = []string{"projects", "archive/projects"}
+
+// sparsePatterns returns the sparse-checkout patterns for a member who can
+// access stages. A nil stages is someone not in the team yet, who gets the
+// top-level files and the tokens a CI checkout redeems.
+func sparsePatterns(stages []models.Stage) []string {
+	patterns := []string{"/*", "!/*/", "/" + token.Dir + "/"}
+	if stages == nil {
+		return patterns
+	}
+	for _, dir := range sparseDirs {
+		if dir != token.Dir {
+			patterns = append(patterns, "/"+dir+"/")
+		}
+	}
+	for _, dir := range stageDirs {
+		patterns = append(patterns, "!/"+dir+"/*/*"+".env.age")
+		for _, stage := range stages {
+			patterns = append(patterns, "/"+dir+"/*/"+string(stage)+".env.age")
+		}
+	}
+	return patterns
+}
+
+// applySparseCheckout limits the working tree to top-level files, the store
+// directories in sparseDirs, and the env files of stages the current user
+// can access. Returns the stages included, or nil if the current user is
+// not in the team yet.
+func (a *Action) applySparseCheckout() ([]models.Stage, error) {
+	var stages []models.Stage
+	if user, err := a.getCurrentUser(); err == nil {
+		stages = []models.Stage{}
+		engine := a.policy()
+		for _, stage := range models.AllStages() {
+			if engine.CanAccessStage(user, stage, false) {
+				stages = append(stages, stage)
+			}
+		}
+	}
+	patterns := sparsePatterns(stages)
+
+	args := append([]string{"sparse-checkout", "set", "--no-cone"}, patterns...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = a.cfg.StorePath
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%s: %s", err, string(output))
+	}
+
+	return stages, nil
+}
+
+// gitIsShallow reports whether the repository is a shallow clone
+func gitIsShallow(path string) bool {
+	cmd := exec.Command("git", "rev-parse", "--is-shallow-repository")
+	cmd.Dir = path
+	output, err := cmd.Output()
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(output)) == "true"
+}
+
+// joinStages joins stage names into a comma-separated list
+func joinStages(stages []models.Stage) string {
+	if len(stages) == 0 {
+		return "none"
+	}
+	names := make([]string, len(stages))
+	for i, s := range stages {
+		names[i] = string(s)
+	}
+	return strings.Join(names, ", ")
+}
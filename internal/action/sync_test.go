@@ -0,0 +1,116 @@
+package action
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"passbook/internal/models"
+)
+
+// gitTestRepo creates an empty repository and returns it with a function
+// running git in it
+func gitTestRepo(t *testing.T) (string, func(args ...string)) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, output)
+		}
+	}
+	git("init", "-q")
+	return dir, git
+}
+
+// writeFiles writes files into dir, each holding its own name
+func writeFiles(t *testing.T, dir string, files ...string) {
+	t.Helper()
+	for _, file := range files {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(file), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// sparseRepo commits files to a fresh repository and checks it out with the
+// sparse patterns for stages
+func sparseRepo(t *testing.T, files []string, stages []models.Stage) string {
+	t.Helper()
+	dir, git := gitTestRepo(t)
+	writeFiles(t, dir, files...)
+	git("add", "-A")
+	git("commit", "-q", "-m", "store")
+	git(append([]string{"sparse-checkout", "set", "--no-cone"}, sparsePatterns(stages)...)...)
+	return dir
+}
+
+func checkedOut(dir, file string) bool {
+	_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(file)))
+	return err == nil
+}
+
+func TestSparseCheckoutCoversEveryStoreDirectory(t *testing.T) {
+	files := []string{".passbook-users.age", ".passbook-config"}
+	for _, dir := range sparseDirs {
+		files = append(files, dir+"/example/secret.age")
+	}
+	stages := []models.Stage{models.StageDev}
+	dir := sparseRepo(t, files, stages)
+
+	for _, file := range files {
+		if !checkedOut(dir, file) {
+			t.Errorf("%s is not checked out", file)
+		}
+	}
+}
+
+func TestSparseCheckoutLimitsEnvFilesToStages(t *testing.T) {
+	var files []string
+	for _, dir := range stageDirs {
+		files = append(files,
+			dir+"/web/.passbook-project",
+			dir+"/web/dev.env.age",
+			dir+"/web/prod.env.age",
+		)
+	}
+	dir := sparseRepo(t, files, []models.Stage{models.StageDev})
+
+	for _, projects := range stageDirs {
+		for _, file := range []string{projects + "/web/.passbook-project", projects + "/web/dev.env.age"} {
+			if !checkedOut(dir, file) {
+				t.Errorf("%s is not checked out", file)
+			}
+		}
+		if file := projects + "/web/prod.env.age"; checkedOut(dir, file) {
+			t.Errorf("%s is checked out without prod access", file)
+		}
+	}
+}
+
+func TestSparseCheckoutForNonMembers(t *testing.T) {
+	files := []string{".passbook-users.age", ".passbook-tokens/index.yaml", "credentials/example.com/login.age", "projects/web/dev.env.age"}
+	dir := sparseRepo(t, files, nil)
+
+	for _, file := range files[:2] {
+		if !checkedOut(dir, file) {
+			t.Errorf("%s is not checked out", file)
+		}
+	}
+	for _, file := range files[2:] {
+		if checkedOut(dir, file) {
+			t.Errorf("%s is checked out for someone not in the team", file)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/pkg/termio"
+	"passbook/pkg/ui"
+)
+
+// pushRetries is how many times a failed push is tried again
+const pushRetries = 3
+
+// pushBackoff is how long the first retry of a push that failed for a
+// reason that may pass waits; each retry after waits twice as long
+var pushBackoff = time.Second
+
+// pushWithRecovery pushes the store. A push the remote rejects because it
+// has commits this clone lacks pulls them, replaying local commits on top,
+// and pushes again. One that fails for a reason that may pass, such as the
+// network, is retried with backoff. If the local commits conflict with the
+// remote's, the pull is undone and ErrDiverged returned, leaving them for
+// 'passbook sync --repair'.
+func (a *Action) pushWithRecovery(ctx context.Context) error {
+	delay := pushBackoff
+	for attempt := 0; ; attempt++ {
+		err := a.push(ctx)
+		if err == nil || attempt == pushRetries || ctx.Err() != nil {
+			return err
+		}
+
+		switch {
+		case isNonFastForward(err):
+			if err := a.pull(ctx); err != nil {
+				if errors.Is(err, ErrHistoryRewritten) {
+					return err
+				}
+				if gitAbortRebase(a.cfg.StorePath) {
+					return ErrDiverged
+				}
+				return fmt.Errorf("push was rejected and pulling failed: %w", err)
+			}
+		case isTransientGitError(err):
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+			delay *= 2
+		default:
+			return err
+		}
+	}
+}
+
+// isNonFastForward reports whether a push was rejected because the remote
+// has commits the local branch doesn't
+func isNonFastForward(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "non-fast-forward") || strings.Contains(msg, "fetch first") ||
+		strings.Contains(msg, "[rejected]") && strings.Contains(msg, "behind")
+}
+
+// transientGitErrors are fragments of git output for failures that may pass
+// if tried again
+var transientGitErrors = []string{
+	"could not resolve host",
+	"connection timed out",
+	"connection refused",
+	"connection reset",
+	"operation timed out",
+	"the remote end hung up",
+	"early eof",
+	"http 502",
+	"http 503",
+	"http 504",
+	"temporarily unavailable",
+}
+
+// isTransientGitError reports whether a git command failed for a reason
+// that may pass, such as the network
+func isTransientGitError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, fragment := range transientGitErrors {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// syncRepair brings a store that failed to sync back in step with its
+// remote: it undoes an interrupted rebase or merge, then pulls, pushes or
+// replays local commits on the remote's as the two histories need. When
+// local and remote commits change the same secrets, which being encrypted
+// can't be merged, it says which and stops unless told to keep one side:
+// --ours replays local commits taking their version of those secrets, and
+// --theirs resets to the remote, keeping local commits on a backup branch.
+func (a *Action) syncRepair(c *cli.Context) error {
+	ctx := c.Context
+	path := a.cfg.StorePath
+	ours, theirs := c.Bool("ours"), c.Bool("theirs")
+	if ours && theirs {
+		return fmt.Errorf("%w: choose one of --ours and --theirs", ErrInvalidInput)
+	}
+
+	ui.Heading("Sync Repair")
+	fmt.Println()
+
+	if gitAbortRebase(path) {
+		fmt.Printf("%s Undid an unfinished rebase\n", ui.Success("✓"))
+	}
+	if gitAbortMerge(path) {
+		fmt.Printf("%s Undid an unfinished merge\n", ui.Success("✓"))
+	}
+
+	if err := a.checkRewritten(ctx); err != nil {
+		return err
+	}
+	if err := exec.CommandContext(ctx, "git", "-C", path, "fetch", "--quiet").Run(); err != nil {
+		return fmt.Errorf("cannot reach the remote: %w", err)
+	}
+
+	ahead, behind, err := gitAheadBehind(path)
+	if err != nil {
+		return fmt.Errorf("the store's branch has no upstream to sync with; set git.remote and run 'passbook sync --push'")
+	}
+
+	switch {
+	case ahead == 0 && behind == 0:
+		fmt.Printf("%s In step with the remote\n", ui.Success("✓"))
+		return nil
+	case ahead == 0:
+		if err := a.pull(ctx); err != nil {
+			return fmt.Errorf("pull failed: %w", err)
+		}
+		fmt.Printf("%s Pulled %d commit(s)\n", ui.Success("✓"), behind)
+		return nil
+	case behind == 0:
+		if err := a.pushWithRecovery(ctx); err != nil {
+			return fmt.Errorf("push failed: %w", err)
+		}
+		fmt.Printf("%s Pushed %d commit(s)\n", ui.Success("✓"), ahead)
+		return nil
+	}
+
+	fmt.Printf("The store has %d local and %d remote commit(s) the other side lacks\n", ahead, behind)
+
+	backup := "passbook-repair-" + time.Now().Format("20060102-150405")
+	if err := exec.Command("git", "-C", path, "branch", backup).Run(); err != nil {
+		return fmt.Errorf("failed to save local commits on %s: %w", backup, err)
+	}
+	fmt.Printf("%s Saved local commits on branch %s\n", ui.Success("✓"), backup)
+
+	if theirs {
+		return a.repairTakeRemote(ctx, backup)
+	}
+
+	args := []string{"-C", path, "rebase", "--autostash"}
+	if ours {
+		// While rebasing, the commits being replayed are "theirs"
+		args = append(args, "-X", "theirs")
+	}
+	output, err := exec.CommandContext(ctx, "git", append(args, "@{upstream}")...).CombinedOutput()
+	if err != nil {
+		gitAbortRebase(path)
+		both, _ := gitChangedOnBothSides(path)
+		if len(both) == 0 {
+			return fmt.Errorf("%w: rebase failed: %s", ErrDiverged, strings.TrimSpace(string(output)))
+		}
+		fmt.Println()
+		fmt.Println("These secrets changed both here and on the remote:")
+		for _, file := range both {
+			fmt.Printf("  %s\n", file)
+		}
+		fmt.Println()
+		fmt.Println("Encrypted secrets can't be merged. Choose which side to keep:")
+		fmt.Println("  passbook sync --repair --ours     keep your versions, replacing the remote's")
+		fmt.Println("  passbook sync --repair --theirs   take the remote's, then redo your changes")
+		return ErrDiverged
+	}
+	fmt.Printf("%s Replayed local commits on the remote's\n", ui.Success("✓"))
+
+	if err := a.pushWithRecovery(ctx); err != nil {
+		return fmt.Errorf("push failed: %w", err)
+	}
+	fmt.Printf("%s Pushed\n", ui.Success("✓"))
+	fmt.Printf("Branch %s can be deleted once you've checked the result\n", backup)
+	return nil
+}
+
+// repairTakeRemote resets the store to its remote, whose commits win over
+// the local ones saved on backup
+func (a *Action) repairTakeRemote(ctx context.Context, backup string) error {
+	path := a.cfg.StorePath
+	changes, err := gitUncommittedChanges(path)
+	if err != nil {
+		return fmt.Errorf("failed to read git status: %w", err)
+	}
+	if len(changes) > 0 {
+		return fmt.Errorf("the store has %d uncommitted change(s); run 'passbook commit' first", len(changes))
+	}
+
+	if termio.IsTerminal() {
+		confirm, err := termio.Confirm("Reset the store to the remote, dropping your local commits from the branch?", false)
+		if err != nil {
+			return err
+		}
+		if !confirm {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	if output, err := exec.CommandContext(ctx, "git", "-C", path, "reset", "--hard", "@{upstream}").CombinedOutput(); err != nil {
+		return fmt.Errorf("reset failed: %s", strings.TrimSpace(string(output)))
+	}
+	fmt.Printf("%s Reset to the remote\n", ui.Success("✓"))
+	fmt.Printf("Your commits are on branch %s; 'git -C %s log -p @{upstream}..%s' shows what to redo\n", backup, path, backup)
+	return nil
+}
+
+// gitAbortRebase undoes a rebase left unfinished, such as by a pull that
+// conflicted, reporting whether there was one
+func gitAbortRebase(path string) bool {
+	for _, dir := range []string{"rebase-merge", "rebase-apply"} {
+		if _, err := os.Stat(filepath.Join(path, ".git", dir)); err == nil {
+			return exec.Command("git", "-C", path, "rebase", "--abort").Run() == nil
+		}
+	}
+	return false
+}
+
+// gitAbortMerge undoes a merge left unfinished, reporting whether there was
+// one
+func gitAbortMerge(path string) bool {
+	if _, err := os.Stat(filepath.Join(path, ".git", "MERGE_HEAD")); err != nil {
+		return false
+	}
+	return exec.Command("git", "-C", path, "merge", "--abort").Run() == nil
+}
+
+// gitChangedOnBothSides returns the files changed both by local commits and
+// by remote ones since the two histories split
+func gitChangedOnBothSides(path string) ([]string, error) {
+	changed := func(rng string) ([]string, error) {
+		output, err := exec.Command("git", "-C", path, "diff", "--name-only", rng).Output()
+		if err != nil {
+			return nil, err
+		}
+		return strings.Fields(string(output)), nil
+	}
+	local, err := changed("@{upstream}...HEAD")
+	if err != nil {
+		return nil, err
+	}
+	remote, err := changed("HEAD...@{upstream}")
+	if err != nil {
+		return nil, err
+	}
+
+	var both []string
+	for _, file := range local {
+		if slices.Contains(remote, file) {
+			both = append(both, file)
+		}
+	}
+	return both, nil
+}
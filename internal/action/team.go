@@ -2,55 +2,57 @@ package action
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/urfave/cli/v2"
-	"gopkg.in/yaml.v3"
 
 	"passbook/internal/audit"
 	"passbook/internal/auth"
 	"passbook/internal/backend/crypto/age"
 	"passbook/internal/models"
+	"passbook/internal/rbac"
 	reencrypt_pkg "passbook/internal/reencrypt"
 	"passbook/internal/verification"
+	"passbook/pkg/pwgen"
 	"passbook/pkg/termio"
+	"passbook/pkg/ui"
 )
 
 // loadUsers loads the users file
 func (a *Action) loadUsers() (*models.UserList, error) {
-	usersPath := filepath.Join(a.cfg.StorePath, ".passbook-users")
-	data, err := os.ReadFile(usersPath)
+	s, err := a.openStore()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return &models.UserList{Users: []models.User{}}, nil
-		}
 		return nil, err
 	}
-
-	var userList models.UserList
-	if err := yaml.Unmarshal(data, &userList); err != nil {
+	users, err := s.ListUsers()
+	if err != nil {
 		return nil, err
 	}
-
-	return &userList, nil
+	return &models.UserList{Users: users}, nil
 }
 
 // saveUsers saves the users file
 func (a *Action) saveUsers(userList *models.UserList) error {
-	usersPath := filepath.Join(a.cfg.StorePath, ".passbook-users")
-	data, err := yaml.Marshal(userList)
+	s, err := a.openStore()
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(usersPath, data, 0600)
+	return s.SaveUsers(context.Background(), userList.Users)
 }
 
 // getCurrentUser finds the current user by public key
 func (a *Action) getCurrentUser() (*models.User, error) {
+	if !a.cfg.IsInitialized() {
+		return nil, ErrNotInitialized
+	}
+
 	userList, err := a.loadUsers()
 	if err != nil {
 		return nil, err
@@ -62,7 +64,7 @@ func (a *Action) getCurrentUser() (*models.User, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("current user not found in team")
+	return nil, fmt.Errorf("%w: current user not found in team", ErrAccessDenied)
 }
 
 // TeamList lists team members
@@ -72,8 +74,7 @@ func (a *Action) TeamList(c *cli.Context) error {
 		return fmt.Errorf("failed to load users: %w", err)
 	}
 
-	fmt.Println("Team Members")
-	fmt.Println("============")
+	ui.Heading("Team Members")
 	fmt.Println()
 
 	if len(userList.Users) == 0 {
@@ -106,6 +107,11 @@ func (a *Action) TeamList(c *cli.Context) error {
 			email += " (you)"
 		}
 
+		// Mark external collaborators
+		if user.External {
+			key += " " + externalMarker(&user)
+		}
+
 		fmt.Printf("%-30s %-20s %s\n", email, roles, key)
 	}
 
@@ -115,29 +121,19 @@ func (a *Action) TeamList(c *cli.Context) error {
 // TeamInvite invites a new member
 func (a *Action) TeamInvite(c *cli.Context) error {
 	if c.NArg() < 1 {
-		return fmt.Errorf("usage: passbook team invite EMAIL [--role ROLE]")
+		return fmt.Errorf("usage: passbook team invite EMAIL [--role ROLE] [--link]")
 	}
 
 	email := c.Args().First()
 	roles := c.StringSlice("role")
 
 	if len(roles) == 0 {
-		roles = []string{"dev"}
+		roles = []string{string(defaultMemberRole(c))}
 	}
 
-	// Check if current user is admin
-	currentUser, err := a.getCurrentUser()
-	if err != nil {
-		return fmt.Errorf("failed to get current user: %w", err)
-	}
-
-	if !currentUser.IsAdmin() {
-		return fmt.Errorf("permission denied: only admins can invite members")
-	}
-
-	// Validate email domain
-	if !a.cfg.IsAllowedEmail(email) {
-		return fmt.Errorf("email domain not allowed: must be @%s", a.cfg.Org.AllowedDomain)
+	// Check permission
+	if _, err := a.authorize(rbac.PermTeamInvite); err != nil {
+		return err
 	}
 
 	// Validate roles
@@ -145,11 +141,22 @@ func (a *Action) TeamInvite(c *cli.Context) error {
 	for _, r := range roles {
 		role := models.Role(r)
 		if !role.IsValid() {
-			return fmt.Errorf("invalid role: %s (valid: dev, staging-access, prod-access, admin)", r)
+			return fmt.Errorf("invalid role: %s (valid: dev, staging-access, prod-access, admin, viewer)", r)
 		}
 		userRoles = append(userRoles, role)
 	}
 
+	// Validate email domain, or the terms of an external collaborator
+	expires, err := a.externalMember(c, email, userRoles)
+	if err != nil {
+		return err
+	}
+
+	// Sealed invite link: the invitee bootstraps themselves with join --invite
+	if c.Bool("link") {
+		return a.teamInviteLink(c, email, userRoles, expires)
+	}
+
 	// Load users
 	userList, err := a.loadUsers()
 	if err != nil {
@@ -190,19 +197,11 @@ func (a *Action) TeamInvite(c *cli.Context) error {
 
 				switch choice {
 				case "1":
-					keyDir := filepath.Join(a.cfg.StorePath, ".pending-keys")
-					if err := os.MkdirAll(keyDir, 0700); err != nil {
-						return fmt.Errorf("failed to create key directory: %w", err)
-					}
-					privateKeyPath := filepath.Join(keyDir, email+".key")
-					pubKey, err := age.GenerateIdentity(privateKeyPath)
+					pubKey, _, err := a.sealNewMemberKey(c, email)
 					if err != nil {
-						return fmt.Errorf("failed to generate key: %w", err)
+						return err
 					}
 					userList.Users[i].PublicKey = pubKey
-					fmt.Printf("\n✓ Generated key pair\n")
-					fmt.Printf("  Private key: %s\n", privateKeyPath)
-					fmt.Printf("  Public key: %s\n", pubKey)
 				case "2":
 					pubKey, err := termio.Prompt("Enter their public key (age1...): ")
 					if err != nil {
@@ -211,6 +210,9 @@ func (a *Action) TeamInvite(c *cli.Context) error {
 					if pubKey == "" || len(pubKey) < 10 || pubKey[:4] != "age1" {
 						return fmt.Errorf("invalid public key format")
 					}
+					if err := a.confirmKeyFingerprint(c, pubKey); err != nil {
+						return err
+					}
 					userList.Users[i].PublicKey = pubKey
 				}
 			}
@@ -228,17 +230,20 @@ func (a *Action) TeamInvite(c *cli.Context) error {
 			}
 
 			// Git commit
-			if err := a.GitCommitAndSync(fmt.Sprintf("Update user: %s", email)); err != nil {
-				fmt.Printf("Warning: %v\n", err)
+			if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Update user: %s", email)); err != nil {
+				ui.Warningf("%v", err)
 			}
 
-			fmt.Printf("✓ Updated %s with roles: %v\n", email, userList.Users[i].Roles)
+			ui.Successf("Updated %s with roles: %v", email, userList.Users[i].Roles)
 			return nil
 		}
 	}
 
 	fmt.Printf("Inviting: %s\n", email)
 	fmt.Printf("Roles: %v\n", roles)
+	if !expires.IsZero() {
+		fmt.Printf("External collaborator until %s\n", expires.Format("2006-01-02"))
+	}
 	fmt.Println()
 
 	// Ask how to handle the key
@@ -254,28 +259,16 @@ func (a *Action) TeamInvite(c *cli.Context) error {
 	}
 
 	var pubKey string
-	var privateKeyPath string
+	var bundlePath string
 
 	switch choice {
 	case "1":
-		// Generate new key
-		keyDir := filepath.Join(a.cfg.StorePath, ".pending-keys")
-		if err := os.MkdirAll(keyDir, 0700); err != nil {
-			return fmt.Errorf("failed to create key directory: %w", err)
-		}
-
-		privateKeyPath = filepath.Join(keyDir, email+".key")
-		pubKey, err = age.GenerateIdentity(privateKeyPath)
+		// Generate new key, sealed for out-of-band delivery
+		pubKey, bundlePath, err = a.sealNewMemberKey(c, email)
 		if err != nil {
-			return fmt.Errorf("failed to generate key: %w", err)
+			return err
 		}
 
-		fmt.Printf("\n✓ Generated key pair for %s\n", email)
-		fmt.Printf("  Private key saved to: %s\n", privateKeyPath)
-		fmt.Printf("  Public key: %s\n", pubKey)
-		fmt.Println("\n  Send them the private key file securely!")
-		fmt.Println("  They should save it as: ~/.config/passbook/identity")
-
 	case "2":
 		// Enter existing key with verification
 		pubKey, err = termio.Prompt("Enter their public key (age1...): ")
@@ -288,6 +281,9 @@ func (a *Action) TeamInvite(c *cli.Context) error {
 		if !age.ValidatePublicKey(pubKey) {
 			return fmt.Errorf("invalid public key format (should start with 'age1')")
 		}
+		if err := a.confirmKeyFingerprint(c, pubKey); err != nil {
+			return err
+		}
 
 		// Ask if they want to verify key ownership
 		skipVerify := c.Bool("skip-verify")
@@ -302,7 +298,7 @@ func (a *Action) TeamInvite(c *cli.Context) error {
 
 			if verify {
 				// Create verification challenge
-				verifier := verification.NewVerifier(a.cfg.StorePath)
+				verifier := verification.NewVerifier(a.cfg.StorePath, a.cfg.ConfigDir)
 				pv, err := verifier.CreateChallenge(email, pubKey)
 				if err != nil {
 					return fmt.Errorf("failed to create verification challenge: %w", err)
@@ -326,6 +322,8 @@ func (a *Action) TeamInvite(c *cli.Context) error {
 					PublicKey: pubKey, // Store key but don't add to recipients yet
 					CreatedAt: time.Now(),
 					Roles:     userRoles,
+					External:  !expires.IsZero(),
+					ExpiresAt: expires,
 				}
 				// Add a marker that this user is pending verification
 				if newUser.Metadata == nil {
@@ -339,11 +337,12 @@ func (a *Action) TeamInvite(c *cli.Context) error {
 					return fmt.Errorf("failed to save users: %w", err)
 				}
 
-				if err := a.GitCommitAndSync(fmt.Sprintf("Add pending team member: %s (awaiting verification)", email)); err != nil {
-					fmt.Printf("Warning: %v\n", err)
+				if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Add pending team member: %s (awaiting verification)", email)); err != nil {
+					ui.Warningf("%v", err)
 				}
 
-				fmt.Printf("\n✓ Added %s as pending (awaiting key verification)\n", email)
+				fmt.Println()
+				ui.Successf("Added %s as pending (awaiting key verification)", email)
 				return nil
 			}
 		}
@@ -368,6 +367,11 @@ func (a *Action) TeamInvite(c *cli.Context) error {
 		PublicKey: pubKey,
 		CreatedAt: time.Now(),
 		Roles:     userRoles,
+		External:  !expires.IsZero(),
+		ExpiresAt: expires,
+	}
+	if id := c.Int64("github-id"); id != 0 {
+		newUser.BindGitHub(id, c.String("github"))
 	}
 
 	userList.Users = append(userList.Users, newUser)
@@ -385,27 +389,222 @@ func (a *Action) TeamInvite(c *cli.Context) error {
 	}
 
 	// Git commit
-	if err := a.GitCommitAndSync(fmt.Sprintf("Add team member: %s", email)); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Add team member: %s", email)); err != nil {
+		ui.Warningf("%v", err)
 	}
 
-	fmt.Printf("\n✓ Invited %s with roles: %v\n", email, roles)
+	fmt.Println()
+	ui.Successf("Invited %s with roles: %v", email, roles)
 
 	if pubKey == "" {
 		fmt.Println("\nNext steps for the new user:")
 		fmt.Println("  1. Run: passbook clone <repo-url>")
 		fmt.Println("  2. Their key will be generated automatically")
 		fmt.Println("  3. An admin will need to re-sync to add their key to secrets")
-	} else if privateKeyPath != "" {
+	} else if bundlePath != "" {
 		fmt.Println("\nNext steps:")
-		fmt.Println("  1. Send them the private key file securely")
-		fmt.Println("  2. They should run: passbook clone <repo-url>")
-		fmt.Println("  3. When prompted, import the key file")
+		fmt.Println("  1. Send them the key bundle and the passphrase over separate channels")
+		fmt.Printf("  2. They run: passbook key import %s\n", filepath.Base(bundlePath))
+		fmt.Println("  3. Then: passbook clone <repo-url>")
 	}
 
 	return nil
 }
 
+// defaultMemberRole is the role new members get when none is given;
+// external collaborators start with the most restricted one
+func defaultMemberRole(c *cli.Context) models.Role {
+	if c.Bool("external") {
+		return models.RoleViewer
+	}
+	return models.RoleDev
+}
+
+// externalMember checks the --external and --expires flags for a new member
+// and returns when an external collaborator's access ends, or the zero time
+// for a member of the org. Only admins add external collaborators, who are
+// the only members allowed outside the org's email domain and must expire.
+func (a *Action) externalMember(c *cli.Context, email string, roles []models.Role) (time.Time, error) {
+	if !c.Bool("external") {
+		if c.String("expires") != "" {
+			return time.Time{}, fmt.Errorf("--expires is only for external collaborators (add --external)")
+		}
+		if !a.cfg.IsAllowedEmail(email) {
+			return time.Time{}, fmt.Errorf("email domain not allowed: must be %s (use --external for a collaborator outside the org)", a.cfg.Org.DescribeDomains())
+		}
+		return time.Time{}, nil
+	}
+
+	currentUser, err := a.getCurrentUser()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !currentUser.IsAdmin() {
+		return time.Time{}, fmt.Errorf("%w: only admins can add external collaborators", ErrAccessDenied)
+	}
+	if slices.Contains(roles, models.RoleAdmin) {
+		return time.Time{}, fmt.Errorf("external collaborators cannot be granted the admin role")
+	}
+	if c.String("expires") == "" {
+		return time.Time{}, fmt.Errorf("external collaborators need --expires (a date like 2026-12-31 or a duration like 90d)")
+	}
+	return parseExpiry(c.String("expires"))
+}
+
+// parseExpiry parses when access ends: a date, which is the last day of
+// access, or a duration from now such as 90d
+func parseExpiry(s string) (time.Time, error) {
+	var expires time.Time
+	if date, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		expires = date.AddDate(0, 0, 1).Add(-time.Second)
+	} else {
+		d, err := parseWindow(s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: %q is not a date like 2026-12-31 or a duration like 90d", ErrInvalidInput, s)
+		}
+		expires = time.Now().Add(d)
+	}
+	if !expires.After(time.Now()) {
+		return time.Time{}, fmt.Errorf("%w: %s is in the past", ErrInvalidInput, s)
+	}
+	return expires, nil
+}
+
+// externalMarker labels an external collaborator in listings
+func externalMarker(u *models.User) string {
+	if u.IsExpired() {
+		return "[external, expired]"
+	}
+	return fmt.Sprintf("[external until %s]", u.ExpiresAt.Format("2006-01-02"))
+}
+
+// externalDetails are the audit details recording that a member is external
+func externalDetails(u *models.User) []string {
+	if !u.External {
+		return nil
+	}
+	return []string{"external", "true", "expires", u.ExpiresAt.Format(time.RFC3339)}
+}
+
+// TeamExtend changes when an external collaborator's access ends (admin only)
+func (a *Action) TeamExtend(c *cli.Context) error {
+	if c.NArg() < 1 || c.String("expires") == "" {
+		return fmt.Errorf("usage: passbook team extend --expires DATE|DURATION EMAIL")
+	}
+	email := c.Args().First()
+
+	currentUser, err := a.authorize(rbac.PermTeamGrant)
+	if err != nil {
+		return err
+	}
+	if !currentUser.IsAdmin() {
+		return fmt.Errorf("%w: only admins can extend external collaborators", ErrAccessDenied)
+	}
+	expires, err := parseExpiry(c.String("expires"))
+	if err != nil {
+		return err
+	}
+
+	userList, err := a.loadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+	var user *models.User
+	for i := range userList.Users {
+		if userList.Users[i].Email == email {
+			user = &userList.Users[i]
+		}
+	}
+	if user == nil {
+		return fmt.Errorf("user %s %w", email, ErrNotFound)
+	}
+	if !user.External {
+		return fmt.Errorf("%s is not an external collaborator", email)
+	}
+	user.ExpiresAt = expires
+
+	if err := a.saveUsers(userList); err != nil {
+		return fmt.Errorf("failed to save users: %w", err)
+	}
+
+	a.logAudit(audit.EventUserExtended, email, externalDetails(user)...)
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Extend access for %s", email)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("%s's access now ends %s", email, expires.Format("2006-01-02"))
+	return nil
+}
+
+// sealNewMemberKey generates a key for a new member and writes it as a
+// passphrase-sealed bundle outside the store, so the private key is never
+// committed. The passphrase is copied to the clipboard rather than printed,
+// unless --show-passphrase asks for it. Returns the public key and bundle
+// path.
+func (a *Action) sealNewMemberKey(c *cli.Context, email string) (string, string, error) {
+	bundlePath := c.String("key-out")
+	if bundlePath == "" {
+		bundlePath = email + ".passbook-key"
+	}
+	bundlePath, err := filepath.Abs(bundlePath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve bundle path: %w", err)
+	}
+
+	// Anything inside the store would be committed and pushed
+	storePath, err := filepath.Abs(a.cfg.StorePath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve store path: %w", err)
+	}
+	if rel, err := filepath.Rel(storePath, bundlePath); err == nil && filepath.IsLocal(rel) {
+		return "", "", fmt.Errorf("refusing to write key bundle inside the store: %s", bundlePath)
+	}
+
+	passphrase, err := pwgen.GenerateAlphanumeric(24)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate passphrase: %w", err)
+	}
+
+	// Hand over the passphrase before writing the bundle, so a bundle is
+	// never left behind without a way to open it
+	showPassphrase := c.Bool("show-passphrase")
+	if !showPassphrase {
+		if err := a.copyToClipboard(passphrase); err != nil {
+			return "", "", fmt.Errorf("%w; pass --show-passphrase to print it instead", err)
+		}
+	}
+
+	bundle, pubKey, err := age.SealIdentity(passphrase)
+	if err != nil {
+		return "", "", err
+	}
+
+	f, err := os.OpenFile(bundlePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to write key bundle: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(bundle); err != nil {
+		return "", "", fmt.Errorf("failed to write key bundle: %w", err)
+	}
+
+	fmt.Println()
+	ui.Successf("Generated key pair for %s", email)
+	fmt.Printf("  Public key:  %s\n", pubKey)
+	fmt.Printf("  Key bundle:  %s\n", bundlePath)
+	if showPassphrase {
+		fmt.Printf("  Passphrase:  %s\n", passphrase)
+	} else {
+		fmt.Printf("  Passphrase:  copied to clipboard (clears in %d seconds)\n", a.cfg.Preferences.ClipboardTimeout)
+	}
+	fmt.Println("\n  Send the bundle and the passphrase over separate channels.")
+	fmt.Println("  The private key is not stored anywhere else; delete the bundle once delivered.")
+
+	return pubKey, bundlePath, nil
+}
+
 // updateRecipientsFile updates .passbook-recipients from users
 // Only includes verified users with public keys
 func (a *Action) updateRecipientsFile(userList *models.UserList) error {
@@ -420,8 +619,8 @@ func (a *Action) updateRecipientsFile(userList *models.UserList) error {
 		if user.PublicKey == "" {
 			continue
 		}
-		// Skip users pending verification
-		if user.IsPendingVerification() {
+		// Skip users pending verification or whose access has expired
+		if user.IsPendingVerification() || user.IsExpired() {
 			continue
 		}
 		content += fmt.Sprintf("%s # %s\n", user.PublicKey, user.Email)
@@ -432,14 +631,9 @@ func (a *Action) updateRecipientsFile(userList *models.UserList) error {
 
 // ReEncryptAll re-encrypts all secrets with current recipients
 func (a *Action) ReEncryptAll(c *cli.Context) error {
-	// Check if current user is admin
-	currentUser, err := a.getCurrentUser()
-	if err != nil {
-		return fmt.Errorf("failed to get current user: %w", err)
-	}
-
-	if !currentUser.IsAdmin() {
-		return fmt.Errorf("permission denied: only admins can re-encrypt secrets")
+	// Check permission
+	if _, err := a.authorize(rbac.PermStoreReencrypt); err != nil {
+		return err
 	}
 
 	// Load users
@@ -451,7 +645,7 @@ func (a *Action) ReEncryptAll(c *cli.Context) error {
 	// Build recipient list (only verified users)
 	var recipients []string
 	for _, u := range userList.Users {
-		if u.PublicKey != "" && !u.IsPendingVerification() {
+		if u.CanReceiveSecrets() {
 			recipients = append(recipients, u.PublicKey)
 		}
 	}
@@ -460,7 +654,12 @@ func (a *Action) ReEncryptAll(c *cli.Context) error {
 		return fmt.Errorf("no verified recipients found")
 	}
 
-	fmt.Printf("Re-encrypting secrets for %d recipients...\n", len(recipients))
+	fmt.Printf("Re-encrypting secrets for who may read each of them, among %d members...\n", len(recipients))
+	for _, u := range userList.Users {
+		if u.PublicKey != "" && u.IsExpired() {
+			fmt.Printf("  leaving out %s, whose access expired on %s\n", u.Email, u.ExpiresAt.Format("2006-01-02"))
+		}
+	}
 
 	// Confirm
 	force := c.Bool("force")
@@ -476,21 +675,24 @@ func (a *Action) ReEncryptAll(c *cli.Context) error {
 	}
 
 	// Load crypto backend
-	crypto, err := age.New(a.cfg.IdentityPath())
+	crypto, err := a.crypto()
 	if err != nil {
-		return fmt.Errorf("failed to load crypto backend: %w", err)
+		return err
 	}
 
 	// Re-encrypt
 	reencryptor := reencrypt_pkg.NewReEncryptor(a.cfg.StorePath, crypto)
-	stats, err := reencryptor.ReEncryptAll(context.Background(), recipients)
+	held := a.skipHeldFiles(reencryptor)
+	stats, err := reencryptWithProgress(c.Context, reencryptor, a.store)
 	if err != nil {
 		return fmt.Errorf("re-encryption failed: %w", err)
 	}
+	warnHeldFiles(*held)
 
 	fmt.Printf("\nRe-encryption complete:\n")
 	fmt.Printf("  Total files: %d\n", stats.TotalFiles)
 	fmt.Printf("  Successful:  %d\n", stats.SuccessfulFiles)
+	fmt.Printf("  Unchanged:   %d\n", stats.UnchangedFiles)
 	fmt.Printf("  Failed:      %d\n", stats.FailedFiles)
 
 	if len(stats.Errors) > 0 {
@@ -504,12 +706,13 @@ func (a *Action) ReEncryptAll(c *cli.Context) error {
 	a.logAudit(audit.EventReEncrypt, "all",
 		"total", fmt.Sprintf("%d", stats.TotalFiles),
 		"successful", fmt.Sprintf("%d", stats.SuccessfulFiles),
+		"unchanged", fmt.Sprintf("%d", stats.UnchangedFiles),
 		"failed", fmt.Sprintf("%d", stats.FailedFiles))
 
 	// Git commit
 	if stats.SuccessfulFiles > 0 {
-		if err := a.GitCommitAndSync("Re-encrypt all secrets"); err != nil {
-			fmt.Printf("Warning: %v\n", err)
+		if err := a.GitCommitAndSync(c.Context, "Re-encrypt all secrets"); err != nil {
+			ui.Warningf("%v", err)
 		}
 	}
 
@@ -526,14 +729,10 @@ func (a *Action) TeamRevoke(c *cli.Context) error {
 	force := c.Bool("force")
 	reencryptSecrets := c.Bool("reencrypt")
 
-	// Check if current user is admin
-	currentUser, err := a.getCurrentUser()
+	// Check permission
+	currentUser, err := a.authorize(rbac.PermTeamRevoke)
 	if err != nil {
-		return fmt.Errorf("failed to get current user: %w", err)
-	}
-
-	if !currentUser.IsAdmin() {
-		return fmt.Errorf("permission denied: only admins can revoke access")
+		return err
 	}
 
 	// Can't revoke yourself
@@ -561,7 +760,11 @@ func (a *Action) TeamRevoke(c *cli.Context) error {
 	}
 
 	if !found {
-		return fmt.Errorf("user %s not found", email)
+		return fmt.Errorf("user %s %w", email, ErrNotFound)
+	}
+
+	if err := checkAdminQuorum(newUsers); err != nil {
+		return err
 	}
 
 	// Confirm
@@ -595,36 +798,32 @@ func (a *Action) TeamRevoke(c *cli.Context) error {
 	// Log audit event
 	a.logAudit(audit.EventUserRemoved, email)
 
-	fmt.Printf("✓ Revoked access for %s\n", email)
+	ui.Successf("Revoked access for %s", email)
 
 	// Re-encrypt if requested
 	if reencryptSecrets && revokedKey != "" {
 		fmt.Println("\nRe-encrypting all secrets without the revoked user's key...")
 
-		// Get new recipient list (all remaining users)
-		var newRecipients []string
-		for _, u := range userList.Users {
-			if u.PublicKey != "" && !u.IsPendingVerification() {
-				newRecipients = append(newRecipients, u.PublicKey)
-			}
-		}
-
 		// Load crypto backend
-		crypto, err := age.New(a.cfg.IdentityPath())
+		crypto, err := a.crypto()
 		if err != nil {
-			return fmt.Errorf("failed to load crypto backend: %w", err)
+			return err
 		}
 
-		// Re-encrypt all secrets
+		// Re-encrypt all secrets; recipients come from the saved users file,
+		// which no longer has the revoked member
 		reencryptor := reencrypt_pkg.NewReEncryptor(a.cfg.StorePath, crypto)
-		stats, err := reencryptor.ReEncryptAll(context.Background(), newRecipients)
+		held := a.skipHeldFiles(reencryptor)
+		stats, err := reencryptWithProgress(c.Context, reencryptor, a.store)
 		if err != nil {
 			return fmt.Errorf("re-encryption failed: %w", err)
 		}
+		warnHeldFiles(*held)
 
 		fmt.Printf("\nRe-encryption complete:\n")
 		fmt.Printf("  Total files: %d\n", stats.TotalFiles)
 		fmt.Printf("  Successful:  %d\n", stats.SuccessfulFiles)
+		fmt.Printf("  Unchanged:   %d\n", stats.UnchangedFiles)
 		fmt.Printf("  Failed:      %d\n", stats.FailedFiles)
 
 		if len(stats.Errors) > 0 {
@@ -640,8 +839,8 @@ func (a *Action) TeamRevoke(c *cli.Context) error {
 	if reencryptSecrets {
 		commitMsg = fmt.Sprintf("Revoke team member: %s (with re-encryption)", email)
 	}
-	if err := a.GitCommitAndSync(commitMsg); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+	if err := a.GitCommitAndSync(c.Context, commitMsg); err != nil {
+		ui.Warningf("%v", err)
 	}
 
 	if !reencryptSecrets && revokedKey != "" {
@@ -664,20 +863,15 @@ func (a *Action) TeamGrant(c *cli.Context) error {
 	email := c.Args().Get(0)
 	roleStr := c.Args().Get(1)
 
-	// Check if current user is admin
-	currentUser, err := a.getCurrentUser()
-	if err != nil {
-		return fmt.Errorf("failed to get current user: %w", err)
-	}
-
-	if !currentUser.IsAdmin() {
-		return fmt.Errorf("permission denied: only admins can grant roles")
+	// Check permission
+	if _, err := a.authorize(rbac.PermTeamGrant); err != nil {
+		return err
 	}
 
 	// Validate role
 	role := models.Role(roleStr)
 	if !role.IsValid() {
-		return fmt.Errorf("invalid role: %s (valid: dev, staging-access, prod-access, admin)", roleStr)
+		return fmt.Errorf("invalid role: %s (valid: dev, staging-access, prod-access, admin, viewer)", roleStr)
 	}
 
 	// Load users
@@ -691,6 +885,12 @@ func (a *Action) TeamGrant(c *cli.Context) error {
 	for i, u := range userList.Users {
 		if u.Email == email {
 			found = true
+			if role == models.RoleAdmin && u.IsServiceAccount() {
+				return fmt.Errorf("service accounts cannot be granted the admin role")
+			}
+			if role == models.RoleAdmin && u.External {
+				return fmt.Errorf("external collaborators cannot be granted the admin role")
+			}
 			// Check if already has role
 			for _, r := range u.Roles {
 				if r == role {
@@ -703,7 +903,12 @@ func (a *Action) TeamGrant(c *cli.Context) error {
 	}
 
 	if !found {
-		return fmt.Errorf("user %s not found", email)
+		return fmt.Errorf("user %s %w", email, ErrNotFound)
+	}
+
+	// Granting viewer to the last admin would make them read-only
+	if err := checkAdminQuorum(userList.Users); err != nil {
+		return err
 	}
 
 	// Save users
@@ -712,14 +917,14 @@ func (a *Action) TeamGrant(c *cli.Context) error {
 	}
 
 	// Git commit
-	if err := a.GitCommitAndSync(fmt.Sprintf("Grant %s role to %s", role, email)); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Grant %s role to %s", role, email)); err != nil {
+		ui.Warningf("%v", err)
 	}
 
 	// Log audit event
 	a.logAudit(audit.EventRoleGranted, email, "role", string(role))
 
-	fmt.Printf("✓ Granted %s role to %s\n", role, email)
+	ui.Successf("Granted %s role to %s", role, email)
 
 	return nil
 }
@@ -733,14 +938,10 @@ func (a *Action) TeamUngrant(c *cli.Context) error {
 	email := c.Args().Get(0)
 	roleStr := c.Args().Get(1)
 
-	// Check if current user is admin
-	currentUser, err := a.getCurrentUser()
+	// Check permission
+	currentUser, err := a.authorize(rbac.PermTeamGrant)
 	if err != nil {
-		return fmt.Errorf("failed to get current user: %w", err)
-	}
-
-	if !currentUser.IsAdmin() {
-		return fmt.Errorf("permission denied: only admins can remove roles")
+		return err
 	}
 
 	// Prevent removing own admin role
@@ -751,7 +952,7 @@ func (a *Action) TeamUngrant(c *cli.Context) error {
 	// Validate role
 	role := models.Role(roleStr)
 	if !role.IsValid() {
-		return fmt.Errorf("invalid role: %s (valid: dev, staging-access, prod-access, admin)", roleStr)
+		return fmt.Errorf("invalid role: %s (valid: dev, staging-access, prod-access, admin, viewer)", roleStr)
 	}
 
 	// Load users
@@ -791,7 +992,11 @@ func (a *Action) TeamUngrant(c *cli.Context) error {
 	}
 
 	if !found {
-		return fmt.Errorf("user %s not found", email)
+		return fmt.Errorf("user %s %w", email, ErrNotFound)
+	}
+
+	if err := checkAdminQuorum(userList.Users); err != nil {
+		return err
 	}
 
 	// Save users
@@ -800,14 +1005,14 @@ func (a *Action) TeamUngrant(c *cli.Context) error {
 	}
 
 	// Git commit
-	if err := a.GitCommitAndSync(fmt.Sprintf("Remove %s role from %s", role, email)); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Remove %s role from %s", role, email)); err != nil {
+		ui.Warningf("%v", err)
 	}
 
 	// Log audit event
 	a.logAudit(audit.EventRoleRevoked, email, "role", string(role))
 
-	fmt.Printf("✓ Removed %s role from %s\n", role, email)
+	ui.Successf("Removed %s role from %s", role, email)
 
 	return nil
 }
@@ -839,7 +1044,7 @@ func (a *Action) TeamRoles(c *cli.Context) error {
 		}
 	}
 
-	return fmt.Errorf("user %s not found", email)
+	return fmt.Errorf("user %s %w", email, ErrNotFound)
 }
 
 // getRoleDescription returns a description for a role
@@ -853,11 +1058,22 @@ func getRoleDescription(role models.Role) string {
 		return "Access to all environments + write credentials"
 	case models.RoleAdmin:
 		return "Full access + team management"
+	case models.RoleViewer:
+		return "Read-only; all writes are rejected"
 	default:
 		return "Unknown role"
 	}
 }
 
+// formatRoles joins roles into a comma-separated list
+func formatRoles(roles []models.Role) string {
+	names := make([]string, len(roles))
+	for i, r := range roles {
+		names[i] = string(r)
+	}
+	return strings.Join(names, ", ")
+}
+
 // TeamVerify verifies a pending member's key ownership
 func (a *Action) TeamVerify(c *cli.Context) error {
 	if c.NArg() < 2 {
@@ -867,14 +1083,9 @@ func (a *Action) TeamVerify(c *cli.Context) error {
 	email := c.Args().Get(0)
 	response := c.Args().Get(1)
 
-	// Check if current user is admin
-	currentUser, err := a.getCurrentUser()
-	if err != nil {
-		return fmt.Errorf("failed to get current user: %w", err)
-	}
-
-	if !currentUser.IsAdmin() {
-		return fmt.Errorf("permission denied: only admins can verify members")
+	// Check permission
+	if _, err := a.authorize(rbac.PermTeamInvite); err != nil {
+		return err
 	}
 
 	// Load users
@@ -893,7 +1104,7 @@ func (a *Action) TeamVerify(c *cli.Context) error {
 	}
 
 	if foundIdx == -1 {
-		return fmt.Errorf("user %s not found", email)
+		return fmt.Errorf("user %s %w", email, ErrNotFound)
 	}
 
 	user := &userList.Users[foundIdx]
@@ -903,8 +1114,17 @@ func (a *Action) TeamVerify(c *cli.Context) error {
 	}
 
 	// Verify the response
-	verifier := verification.NewVerifier(a.cfg.StorePath)
+	verifier := verification.NewVerifier(a.cfg.StorePath, a.cfg.ConfigDir)
 	if err := verifier.VerifyResponse(email, response); err != nil {
+		// The verifier keeps the count; the audit log records the attempt
+		var verr *verification.VerifyError
+		if errors.As(err, &verr) {
+			locked := ""
+			if !verr.LockedUntil.IsZero() {
+				locked = verr.LockedUntil.Format(time.RFC3339)
+			}
+			a.logAudit(audit.EventVerifyFailed, email, "attempts", fmt.Sprintf("%d", verr.Attempts), "locked_until", locked)
+		}
 		return fmt.Errorf("verification failed: %w", err)
 	}
 
@@ -922,11 +1142,13 @@ func (a *Action) TeamVerify(c *cli.Context) error {
 	}
 
 	// Git commit
-	if err := a.GitCommitAndSync(fmt.Sprintf("Verify team member: %s", email)); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Verify team member: %s", email)); err != nil {
+		ui.Warningf("%v", err)
 	}
 
-	fmt.Printf("✓ Successfully verified %s\n", email)
+	a.logAudit(audit.EventUserVerified, email)
+
+	ui.Successf("Successfully verified %s", email)
 	fmt.Println("Their public key has been added to the recipients list.")
 	fmt.Println("\nNote: They will be able to decrypt new secrets encrypted after this point.")
 	fmt.Println("To give them access to existing secrets, you need to re-encrypt them.")
@@ -934,6 +1156,70 @@ func (a *Action) TeamVerify(c *cli.Context) error {
 	return nil
 }
 
+// TeamRechallenge issues a fresh verification challenge for a pending member
+func (a *Action) TeamRechallenge(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook team rechallenge EMAIL")
+	}
+
+	email := c.Args().First()
+
+	// Check permission
+	if _, err := a.authorize(rbac.PermTeamInvite); err != nil {
+		return err
+	}
+
+	userList, err := a.loadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+
+	var user *models.User
+	for i := range userList.Users {
+		if userList.Users[i].Email == email {
+			user = &userList.Users[i]
+			break
+		}
+	}
+	if user == nil {
+		return fmt.Errorf("user %s %w", email, ErrNotFound)
+	}
+	if !user.IsPendingVerification() {
+		return fmt.Errorf("user %s is not pending verification", email)
+	}
+
+	// Don't replace a live challenge by accident. A lockout outlives the
+	// challenge, so reissuing doesn't lift it.
+	verifier := verification.NewVerifier(a.cfg.StorePath, a.cfg.ConfigDir)
+	if pv, err := verifier.GetPendingVerification(email); err == nil && !c.Bool("force") {
+		return fmt.Errorf("challenge for %s is still valid until %s (use --force to reissue)", email, pv.ExpiresAt.Format("2006-01-02 15:04"))
+	}
+
+	// Drop every lapsed challenge while we're changing the file
+	expired, err := verifier.CleanupExpired()
+	if err != nil {
+		return fmt.Errorf("failed to clean up expired challenges: %w", err)
+	}
+
+	pv, err := verifier.CreateChallenge(email, user.PublicKey)
+	if err != nil {
+		return fmt.Errorf("failed to create verification challenge: %w", err)
+	}
+
+	message := fmt.Sprintf("Reissue verification challenge: %s", email)
+	if len(expired) > 0 {
+		message += fmt.Sprintf("\n\nRemove %d expired verification challenge(s): %s", len(expired), strings.Join(expired, ", "))
+	}
+	if err := a.GitCommitAndSync(c.Context, message); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("New challenge for %s (expires %s)", email, pv.ExpiresAt.Format("2006-01-02 15:04"))
+	fmt.Println("\n" + verification.GenerateVerificationInstructions(pv.EncryptedChallenge))
+	fmt.Println("Then run: passbook team verify EMAIL RESPONSE")
+	return nil
+}
+
 // TeamPending lists pending verifications
 func (a *Action) TeamPending(c *cli.Context) error {
 	// Load users
@@ -942,10 +1228,11 @@ func (a *Action) TeamPending(c *cli.Context) error {
 		return fmt.Errorf("failed to load users: %w", err)
 	}
 
-	fmt.Println("Pending Verifications")
-	fmt.Println("====================")
+	ui.Heading("Pending Verifications")
 	fmt.Println()
 
+	verifier := verification.NewVerifier(a.cfg.StorePath, a.cfg.ConfigDir)
+
 	var hasPending bool
 	for _, user := range userList.Users {
 		if user.IsPendingVerification() {
@@ -958,12 +1245,14 @@ func (a *Action) TeamPending(c *cli.Context) error {
 			fmt.Printf("  Public Key: %s\n", key)
 
 			// Check if verification exists
-			verifier := verification.NewVerifier(a.cfg.StorePath)
 			pv, err := verifier.GetPendingVerification(user.Email)
 			if err == nil {
 				fmt.Printf("  Challenge expires: %s\n", pv.ExpiresAt.Format(time.RFC3339))
+				if at, err := verifier.Attempts(user.Email); err == nil && at.IsLocked() {
+					fmt.Printf("  Locked until: %s (too many failed attempts)\n", at.LockedUntil.Format(time.RFC3339))
+				}
 			} else {
-				fmt.Printf("  Challenge: expired or not created\n")
+				fmt.Printf("  Challenge: expired or not created (run 'passbook team rechallenge %s')\n", user.Email)
 			}
 			fmt.Println()
 		}
@@ -1016,8 +1305,7 @@ func (a *Action) VerifyKey(c *cli.Context) error {
 
 // TeamJoin allows a new user to request to join the team using GitHub auth
 func (a *Action) TeamJoin(c *cli.Context) error {
-	fmt.Println("Join Team Request")
-	fmt.Println("=================")
+	ui.Heading("Join Team Request")
 	fmt.Println()
 	fmt.Println("This will verify your identity using GitHub and generate")
 	fmt.Println("a request for an admin to add you to the team.")
@@ -1039,14 +1327,14 @@ func (a *Action) TeamJoin(c *cli.Context) error {
 	fmt.Println("Authenticating with GitHub to verify your email...")
 	fmt.Println()
 
-	githubAuth := auth.NewGitHubAuth(a.cfg.ConfigDir, a.cfg.Org.AllowedDomain)
+	githubAuth := a.githubAuth()
 	session, err := githubAuth.Authenticate()
 	if err != nil {
 		switch err {
 		case auth.ErrEmailNotVerified:
 			return fmt.Errorf("your GitHub email is not verified. Please verify at github.com")
 		case auth.ErrEmailDomainMismatch:
-			return fmt.Errorf("no verified email matching @%s found in your GitHub account", a.cfg.Org.AllowedDomain)
+			return fmt.Errorf("no verified email matching %s found in your GitHub account", a.cfg.Org.DescribeDomains())
 		default:
 			return fmt.Errorf("GitHub authentication failed: %w", err)
 		}
@@ -1055,7 +1343,7 @@ func (a *Action) TeamJoin(c *cli.Context) error {
 	// Update config with verified email
 	a.cfg.Identity.Email = session.Email
 	if err := a.cfg.Save(); err != nil {
-		fmt.Printf("Warning: failed to save config: %v\n", err)
+		ui.Warningf("failed to save config: %v", err)
 	}
 
 	fmt.Println()
@@ -1072,16 +1360,72 @@ func (a *Action) TeamJoin(c *cli.Context) error {
 	fmt.Println("Then provide them your public key when prompted.")
 	fmt.Println()
 	fmt.Println("Alternatively, they can add you directly with:")
-	fmt.Printf("  passbook team add-verified %s %s\n", session.Email, a.cfg.Identity.PublicKey)
+	fmt.Printf("  passbook team add-verified --github-id %d --github %s %s %s\n", session.GitHubID, session.GitHubLogin, session.Email, a.cfg.Identity.PublicKey)
+
+	return nil
+}
+
+// TeamBindGitHub binds a member's GitHub account to their team record, so
+// logins with any other account are refused
+func (a *Action) TeamBindGitHub(c *cli.Context) error {
+	if c.NArg() < 1 || c.Int64("github-id") == 0 {
+		return fmt.Errorf("usage: passbook team bind-github --github-id ID [--github LOGIN] EMAIL")
+	}
+	email := c.Args().First()
+	id, login := c.Int64("github-id"), c.String("github")
+
+	if _, err := a.authorize(rbac.PermTeamInvite); err != nil {
+		return err
+	}
+
+	userList, err := a.loadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+
+	var user *models.User
+	for i := range userList.Users {
+		u := &userList.Users[i]
+		switch {
+		case u.Email == email:
+			user = u
+		case u.GitHubID == id:
+			return fmt.Errorf("GitHub account %d is already bound to %s", id, u.Email)
+		}
+	}
+	if user == nil {
+		return fmt.Errorf("user %s %w", email, ErrNotFound)
+	}
 
+	user.BindGitHub(id, login)
+	if err := a.saveUsers(userList); err != nil {
+		return fmt.Errorf("failed to save users: %w", err)
+	}
+
+	a.logAudit(audit.EventUserGitHubBound, email, "github", login, "github_id", fmt.Sprintf("%d", id))
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Bind GitHub account for %s", email)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	ui.Successf("Bound %s to GitHub account %s", email, formatGitHub(login, id))
 	return nil
 }
 
+// formatGitHub names a GitHub account by login, falling back to its ID
+func formatGitHub(login string, id int64) string {
+	if login == "" {
+		return fmt.Sprintf("#%d", id)
+	}
+	return "@" + login
+}
+
 // TeamAddVerified adds a GitHub-verified user to the team (admin only)
 // This is used when the new user has already authenticated via GitHub
 func (a *Action) TeamAddVerified(c *cli.Context) error {
 	if c.NArg() < 2 {
-		return fmt.Errorf("usage: passbook team add-verified EMAIL PUBLIC_KEY [--role ROLE]")
+		return fmt.Errorf("usage: passbook team add-verified [--role ROLE] [--github-id ID --github LOGIN] EMAIL PUBLIC_KEY")
 	}
 
 	email := c.Args().Get(0)
@@ -1089,22 +1433,12 @@ func (a *Action) TeamAddVerified(c *cli.Context) error {
 	roles := c.StringSlice("role")
 
 	if len(roles) == 0 {
-		roles = []string{"dev"}
-	}
-
-	// Check if current user is admin
-	currentUser, err := a.getCurrentUser()
-	if err != nil {
-		return fmt.Errorf("failed to get current user: %w", err)
+		roles = []string{string(defaultMemberRole(c))}
 	}
 
-	if !currentUser.IsAdmin() {
-		return fmt.Errorf("permission denied: only admins can add members")
-	}
-
-	// Validate email domain
-	if !a.cfg.IsAllowedEmail(email) {
-		return fmt.Errorf("email domain not allowed: must be @%s", a.cfg.Org.AllowedDomain)
+	// Check permission
+	if _, err := a.authorize(rbac.PermTeamInvite); err != nil {
+		return err
 	}
 
 	// Validate public key
@@ -1122,6 +1456,12 @@ func (a *Action) TeamAddVerified(c *cli.Context) error {
 		userRoles = append(userRoles, role)
 	}
 
+	// Validate email domain, or the terms of an external collaborator
+	expires, err := a.externalMember(c, email, userRoles)
+	if err != nil {
+		return err
+	}
+
 	// Load users
 	userList, err := a.loadUsers()
 	if err != nil {
@@ -1131,7 +1471,7 @@ func (a *Action) TeamAddVerified(c *cli.Context) error {
 	// Check if user already exists
 	for _, u := range userList.Users {
 		if u.Email == email {
-			return fmt.Errorf("user %s already exists", email)
+			return fmt.Errorf("user %s %w", email, ErrConflict)
 		}
 	}
 
@@ -1143,6 +1483,11 @@ func (a *Action) TeamAddVerified(c *cli.Context) error {
 		PublicKey: publicKey,
 		CreatedAt: time.Now(),
 		Roles:     userRoles,
+		External:  !expires.IsZero(),
+		ExpiresAt: expires,
+	}
+	if id := c.Int64("github-id"); id != 0 {
+		newUser.BindGitHub(id, c.String("github"))
 	}
 
 	userList.Users = append(userList.Users, newUser)
@@ -1158,9 +1503,9 @@ func (a *Action) TeamAddVerified(c *cli.Context) error {
 	}
 
 	// Log audit event
-	a.logAudit(audit.EventUserAdded, email, "roles", fmt.Sprintf("%v", roles), "method", "github-verified")
+	a.logAudit(audit.EventUserAdded, email, append([]string{"roles", fmt.Sprintf("%v", roles), "method", "github-verified"}, externalDetails(&newUser)...)...)
 
-	fmt.Printf("✓ Added %s to the team with roles: %v\n", email, roles)
+	ui.Successf("Added %s to the team with roles: %v", email, roles)
 	fmt.Println()
 
 	// Ask if user wants to re-encrypt existing secrets
@@ -1170,7 +1515,7 @@ func (a *Action) TeamAddVerified(c *cli.Context) error {
 
 	doReencrypt, err := termio.Confirm("Re-encrypt all secrets now?", true)
 	if err != nil {
-		fmt.Printf("Warning: failed to read input: %v\n", err)
+		ui.Warningf("failed to read input: %v", err)
 		doReencrypt = false
 	}
 
@@ -1178,37 +1523,31 @@ func (a *Action) TeamAddVerified(c *cli.Context) error {
 		fmt.Println()
 		fmt.Println("Re-encrypting all secrets...")
 
-		// Gather all recipients (verified users with public keys)
-		var recipients []string
-		for _, u := range userList.Users {
-			if u.PublicKey != "" && !u.IsPendingVerification() {
-				recipients = append(recipients, u.PublicKey)
-			}
-		}
-
 		// Load crypto backend
-		crypto, err := age.New(a.cfg.IdentityPath())
+		crypto, err := a.crypto()
 		if err != nil {
-			return fmt.Errorf("failed to load crypto backend: %w", err)
+			return err
 		}
 
 		reencryptor := reencrypt_pkg.NewReEncryptor(a.cfg.StorePath, crypto)
-		stats, err := reencryptor.ReEncryptAll(context.Background(), recipients)
+		held := a.skipHeldFiles(reencryptor)
+		stats, err := reencryptWithProgress(c.Context, reencryptor, a.store)
 		if err != nil {
 			return fmt.Errorf("re-encryption failed: %w", err)
 		}
+		warnHeldFiles(*held)
 
-		fmt.Printf("✓ Re-encrypted %d files (%d successful)\n",
+		ui.Successf("Re-encrypted %d files (%d successful)",
 			stats.TotalFiles, stats.SuccessfulFiles)
 
 		// Git commit with re-encryption
-		if err := a.GitCommitAndSync(fmt.Sprintf("Add verified team member: %s (with re-encryption)", email)); err != nil {
-			fmt.Printf("Warning: %v\n", err)
+		if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Add verified team member: %s (with re-encryption)", email)); err != nil {
+			ui.Warningf("%v", err)
 		}
 	} else {
 		// Git commit without re-encryption
-		if err := a.GitCommitAndSync(fmt.Sprintf("Add verified team member: %s", email)); err != nil {
-			fmt.Printf("Warning: %v\n", err)
+		if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Add verified team member: %s", email)); err != nil {
+			ui.Warningf("%v", err)
 		}
 		fmt.Println()
 		fmt.Println("You can re-encrypt later with: passbook reencrypt")
@@ -1216,3 +1555,21 @@ func (a *Action) TeamAddVerified(c *cli.Context) error {
 
 	return nil
 }
+
+// reencryptWithProgress runs a full re-encryption while drawing a progress bar
+func reencryptWithProgress(ctx context.Context, r *reencrypt_pkg.ReEncryptor, policy reencrypt_pkg.Policy) (*reencrypt_pkg.Stats, error) {
+	var bar *ui.Progress
+	r.OnProgress(func(done, total int) {
+		if bar == nil {
+			bar = ui.NewProgress("Re-encrypting", total)
+			return
+		}
+		bar.Add(1)
+	})
+
+	stats, err := r.ReEncryptAll(ctx, policy)
+	if bar != nil {
+		bar.Finish()
+	}
+	return stats, err
+}
@@ -0,0 +1,21 @@
+//go:build linux
+
+package action
+
+import "syscall"
+
+// Filesystem types whose contents live only in memory
+const (
+	tmpfsMagic = 0x01021994
+	ramfsMagic = 0x858458f6
+)
+
+// onMemoryFS reports whether path is on a tmpfs or ramfs, so what's
+// written there is gone at reboot and never reaches a disk
+func onMemoryFS(path string) (bool, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return false, err
+	}
+	return st.Type == tmpfsMagic || st.Type == ramfsMagic, nil
+}
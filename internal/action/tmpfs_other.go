@@ -0,0 +1,10 @@
+//go:build !linux
+
+package action
+
+import "fmt"
+
+// onMemoryFS can't tell a ramdisk from a disk outside Linux
+func onMemoryFS(path string) (bool, error) {
+	return false, fmt.Errorf("%w: --tmpfs is only supported on Linux", ErrInvalidInput)
+}
@@ -0,0 +1,222 @@
+package action
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/audit"
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/internal/token"
+	"passbook/pkg/ui"
+)
+
+// TokenCreate mints a sealed, time-limited grant for one project stage
+func (a *Action) TokenCreate(c *cli.Context) error {
+	project := c.String("project")
+	stage := models.Stage(c.String("stage"))
+	ttl := c.Duration("ttl")
+	serviceAccount := c.String("service-account")
+
+	if project == "" || stage == "" {
+		return fmt.Errorf("usage: passbook token create --project PROJECT --stage STAGE [--ttl 1h]")
+	}
+	if !stage.IsValid() {
+		return fmt.Errorf("invalid stage: %s (valid: dev, staging, prod)", stage)
+	}
+	if ttl <= 0 || ttl > token.MaxTTL {
+		return fmt.Errorf("invalid ttl: %s (must be between 0 and %s)", ttl, token.MaxTTL)
+	}
+
+	// Issuer must be able to read the stage themselves
+	currentUser, err := a.authorize(rbac.GetStagePermission(stage, false))
+	if err != nil {
+		return err
+	}
+
+	// Tokens minted for a service account are limited to what it may read
+	if serviceAccount != "" {
+		userList, err := a.loadUsers()
+		if err != nil {
+			return fmt.Errorf("failed to load users: %w", err)
+		}
+		var sa *models.User
+		for i, u := range userList.Users {
+			if u.Email == serviceAccount && u.IsServiceAccount() {
+				sa = &userList.Users[i]
+				break
+			}
+		}
+		if sa == nil {
+			return fmt.Errorf("service account %s %w", serviceAccount, ErrNotFound)
+		}
+		if !a.policy().CanAccessStage(sa, stage, false) {
+			return fmt.Errorf("service account %s has no access to %s", serviceAccount, stage)
+		}
+	}
+
+	envFile, err := a.loadEnvFile(c.Context, project, stage)
+	if err != nil {
+		return fmt.Errorf("failed to load environment: %w", err)
+	}
+
+	now := time.Now()
+	grant := &token.Grant{
+		Info: token.Info{
+			Project:        project,
+			Stage:          stage,
+			ServiceAccount: serviceAccount,
+			IssuedBy:       currentUser.Email,
+			IssuedAt:       now,
+			ExpiresAt:      now.Add(ttl),
+		},
+		Vars: envFile.Vars,
+	}
+
+	tok, err := token.NewManager(a.cfg.StorePath).Mint(grant)
+	if err != nil {
+		return fmt.Errorf("failed to create token: %w", err)
+	}
+
+	// Git commit the metadata so CI checkouts can check the token
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Create token %s for %s/%s", grant.ID, project, stage)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	// Log audit event
+	a.logAudit(audit.EventTokenCreated, fmt.Sprintf("%s/%s", project, stage),
+		"token", grant.ID, "expires_at", grant.ExpiresAt.Format(time.RFC3339))
+
+	ui.Successf("Created token %s for %s/%s (expires %s)", grant.ID, project, stage, grant.ExpiresAt.Format("2006-01-02 15:04"))
+	fmt.Println()
+	fmt.Println(tok)
+	fmt.Println()
+	fmt.Println("This token is shown once. Redeem it in CI with:")
+	fmt.Println("  passbook env export --token $PASSBOOK_TOKEN")
+	fmt.Println()
+	fmt.Println("The token carries a snapshot of the variables; re-create it after changing them.")
+	fmt.Println("Keep it as secret as the variables themselves: revoking it stops passbook redeeming")
+	fmt.Println("it, but a copy that leaked still holds them.")
+
+	return nil
+}
+
+// TokenList lists issued tokens
+func (a *Action) TokenList(c *cli.Context) error {
+	infos, err := token.NewManager(a.cfg.StorePath).List()
+	if err != nil {
+		return fmt.Errorf("failed to list tokens: %w", err)
+	}
+
+	ui.Heading("Tokens")
+	fmt.Println()
+
+	if len(infos) == 0 {
+		fmt.Println("No tokens found.")
+		return nil
+	}
+
+	for _, info := range infos {
+		status := "active"
+		if info.Expired() {
+			status = "expired"
+		}
+		fmt.Printf("  %s  %s/%s  (%s)\n", info.ID, info.Project, info.Stage, status)
+		fmt.Printf("    Issued by: %s on %s\n", info.IssuedBy, info.IssuedAt.Format("2006-01-02 15:04"))
+		fmt.Printf("    Expires:   %s\n", info.ExpiresAt.Format("2006-01-02 15:04"))
+		if info.ServiceAccount != "" {
+			fmt.Printf("    Service account: %s\n", info.ServiceAccount)
+		}
+	}
+
+	return nil
+}
+
+// TokenRevoke revokes a token, or all expired tokens with --expired. Either
+// takes the access to the project stage that creating the token does.
+func (a *Action) TokenRevoke(c *cli.Context) error {
+	manager := token.NewManager(a.cfg.StorePath)
+
+	if c.Bool("expired") {
+		currentUser, err := a.getCurrentUser()
+		if err != nil {
+			return fmt.Errorf("failed to get current user: %w", err)
+		}
+		skipped := 0
+		removed, err := manager.PruneExpired(func(info token.Info) bool {
+			if a.policy().Can(currentUser, rbac.GetStagePermission(info.Stage, false)) {
+				return true
+			}
+			skipped++
+			return false
+		})
+		if err != nil {
+			return fmt.Errorf("failed to prune tokens: %w", err)
+		}
+		if skipped > 0 {
+			fmt.Printf("Left %d expired token(s) for stages you can't read\n", skipped)
+		}
+		if len(removed) == 0 {
+			fmt.Println("No expired tokens.")
+			return nil
+		}
+		if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Remove %d expired token(s)", len(removed))); err != nil {
+			ui.Warningf("%v", err)
+		}
+		for _, id := range removed {
+			a.logAudit(audit.EventTokenRevoked, id, "reason", "expired")
+		}
+		ui.Successf("Removed %d expired token(s)", len(removed))
+		return nil
+	}
+
+	if c.NArg() < 1 {
+		return fmt.Errorf("usage: passbook token revoke ID")
+	}
+
+	id := c.Args().First()
+	info, err := manager.Lookup(id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	if _, err := a.authorize(rbac.GetStagePermission(info.Stage, false)); err != nil {
+		return err
+	}
+	if err := manager.Revoke(id); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	// Git commit
+	if err := a.GitCommitAndSync(c.Context, fmt.Sprintf("Revoke token %s", id)); err != nil {
+		ui.Warningf("%v", err)
+	}
+
+	// Log audit event
+	a.logAudit(audit.EventTokenRevoked, id)
+
+	ui.Successf("Revoked token %s", id)
+	fmt.Println("passbook will no longer redeem it; if it may have leaked, rotate the variables it held.")
+	return nil
+}
+
+// redeemToken loads the env file sealed in a token
+func (a *Action) redeemToken(tok string) (*models.EnvFile, error) {
+	grant, err := token.NewManager(a.cfg.StorePath).Redeem(tok)
+	if err != nil {
+		return nil, err
+	}
+
+	// Attribute the access to the token, not whoever's identity is on disk
+	actor := "token:" + grant.ID
+	if grant.ServiceAccount != "" {
+		actor = "service-account:" + grant.ServiceAccount + " (token:" + grant.ID + ")"
+	}
+	logger := audit.NewLogger(a.cfg.StorePath, actor)
+	if err := logger.LogWithDetails(audit.EventTokenRedeemed, fmt.Sprintf("%s/%s", grant.Project, grant.Stage)); err != nil {
+		ui.Warningf("failed to log audit event: %v", err)
+	}
+
+	return grant.EnvFile(), nil
+}
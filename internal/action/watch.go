@@ -0,0 +1,213 @@
+package action
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"passbook/internal/envformat"
+	"passbook/internal/models"
+	"passbook/internal/rbac"
+	"passbook/pkg/securetmp"
+	"passbook/pkg/ui"
+)
+
+// stopGracePeriod is how long a watched process gets to exit after an
+// interrupt before it is killed
+const stopGracePeriod = 5 * time.Second
+
+// EnvWatch keeps an exported env file, and optionally a running process, in
+// step with the store. It pulls from the remote every interval and rewrites
+// the output or restarts the process when the variables change.
+func (a *Action) EnvWatch(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return fmt.Errorf("usage: passbook env watch PROJECT STAGE [--output FILE] [--exec COMMAND]")
+	}
+
+	project := c.Args().Get(0)
+	stage := models.Stage(c.Args().Get(1))
+	output := c.String("output")
+	format := c.String("format")
+	cmdArgs := strings.Fields(c.String("exec"))
+	interval := c.Duration("interval")
+
+	// Validate stage
+	if !stage.IsValid() {
+		return fmt.Errorf("invalid stage: %s (valid: dev, staging, prod)", stage)
+	}
+	if output == "" && len(cmdArgs) == 0 {
+		return fmt.Errorf("usage: passbook env watch PROJECT STAGE needs --output, --exec or both")
+	}
+	if interval < time.Second {
+		return fmt.Errorf("usage: --interval must be at least 1s")
+	}
+
+	// Check permission
+	if _, err := a.authorize(rbac.GetStagePermission(stage, false)); err != nil {
+		return err
+	}
+
+	envPath := filepath.Join(a.cfg.StorePath, "projects", project, string(stage)+".env.age")
+
+	var (
+		lastEncrypted []byte
+		lastContent   string
+		proc          *watchedProcess
+		pullWarned    bool
+	)
+	defer func() { proc.stop() }()
+
+	// refresh re-renders the output and restarts the process if the
+	// variables changed; a re-encryption alone changes nothing
+	refresh := func(first bool) error {
+		encrypted, err := os.ReadFile(envPath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("environment %s/%s %w", project, stage, ErrNotFound)
+			}
+			return fmt.Errorf("failed to read environment: %w", err)
+		}
+		if bytes.Equal(encrypted, lastEncrypted) {
+			return nil
+		}
+		lastEncrypted = encrypted
+
+		envFile, err := a.loadEnvFile(c.Context, project, stage)
+		if err != nil {
+			return fmt.Errorf("failed to load environment: %w", err)
+		}
+		data, err := envformat.Encode(format, envFile, envformat.DefaultSeparator)
+		if err != nil {
+			return err
+		}
+		content := string(data)
+		if content == lastContent {
+			return nil
+		}
+		lastContent = content
+
+		if !first {
+			fmt.Printf("[%s] %s/%s changed\n", time.Now().Format("15:04:05"), project, stage)
+		}
+
+		if output != "" {
+			if err := securetmp.WriteFile(output, []byte(content)); err != nil {
+				return fmt.Errorf("failed to write file: %w", err)
+			}
+			fmt.Printf("Wrote %s\n", output)
+		}
+
+		if len(cmdArgs) > 0 {
+			if proc != nil {
+				fmt.Printf("Restarting %s\n", cmdArgs[0])
+				proc.stop()
+			}
+			proc, err = startWatchedProcess(cmdArgs, envFile)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := refresh(true); err != nil {
+		return err
+	}
+	fmt.Printf("Watching %s/%s every %s (Ctrl-C to stop)\n", project, stage, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Context.Done():
+			fmt.Println("Stopped watching.")
+			return nil
+		case <-ticker.C:
+		}
+
+		if err := a.pull(c.Context); err != nil && c.Context.Err() == nil && !pullWarned {
+			ui.Warningf("pull failed, watching local changes only: %v", strings.TrimSpace(err.Error()))
+			pullWarned = true
+		}
+		if c.Context.Err() != nil {
+			continue
+		}
+
+		// Keep watching through transient errors, such as a teammate
+		// removing the environment or revoking then restoring access
+		if err := refresh(false); err != nil {
+			ui.Warningf("%v", err)
+		}
+	}
+}
+
+// watchedProcess is a child that env watch restarts when variables change
+type watchedProcess struct {
+	cmd      *exec.Cmd
+	done     chan struct{}
+	stopping atomic.Bool
+}
+
+// startWatchedProcess runs args with the environment's variables added
+func startWatchedProcess(args []string, envFile *models.EnvFile) (*watchedProcess, error) {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = os.Environ()
+	for _, v := range envFile.Vars {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", v.Key, v.Value))
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", args[0], err)
+	}
+
+	p := &watchedProcess{cmd: cmd, done: make(chan struct{})}
+	go func() {
+		err := cmd.Wait()
+		if !p.stopping.Load() {
+			if err != nil {
+				ui.Warningf("%s exited: %v; it will restart on the next change", args[0], err)
+			} else {
+				fmt.Printf("%s exited; it will restart on the next change\n", args[0])
+			}
+		}
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// stop interrupts the process, killing it if it hasn't exited within the
+// grace period. Interrupts can't be sent on Windows, so it is killed there.
+func (p *watchedProcess) stop() {
+	if p == nil {
+		return
+	}
+	p.stopping.Store(true)
+
+	select {
+	case <-p.done:
+		return
+	default:
+	}
+
+	if err := p.cmd.Process.Signal(os.Interrupt); err != nil {
+		p.cmd.Process.Kill()
+	}
+	select {
+	case <-p.done:
+	case <-time.After(stopGracePeriod):
+		p.cmd.Process.Kill()
+		<-p.done
+	}
+}
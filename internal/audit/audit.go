@@ -1,29 +1,63 @@
 package audit
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"time"
 )
 
+// readChunk is how much of the log is read at a time when reading it
+// backwards
+const readChunk = 64 * 1024
+
 // EventType represents the type of audit event
 type EventType string
 
 const (
 	// User events
-	EventUserAdded    EventType = "user.added"
-	EventUserRemoved  EventType = "user.removed"
-	EventUserVerified EventType = "user.verified"
-	EventRoleGranted  EventType = "role.granted"
-	EventRoleRevoked  EventType = "role.revoked"
+	EventUserAdded       EventType = "user.added"
+	EventUserRemoved     EventType = "user.removed"
+	EventUserVerified    EventType = "user.verified"
+	EventUserGitHubBound EventType = "user.github_bound"
+	EventUserExtended    EventType = "user.extended"
+	EventRoleGranted     EventType = "role.granted"
+	EventRoleRevoked     EventType = "role.revoked"
+	EventUserInvited     EventType = "user.invited"
+	EventVerifyFailed    EventType = "user.verification_failed"
+
+	// Service account events
+	EventServiceAccountCreated EventType = "service_account.created"
+	EventServiceAccountRemoved EventType = "service_account.removed"
+
+	// Token events
+	EventTokenCreated  EventType = "token.created"
+	EventTokenRevoked  EventType = "token.revoked"
+	EventTokenRedeemed EventType = "token.redeemed"
 
 	// Credential events
 	EventCredentialCreated EventType = "credential.created"
 	EventCredentialUpdated EventType = "credential.updated"
 	EventCredentialDeleted EventType = "credential.deleted"
 	EventCredentialAccess  EventType = "credential.accessed"
+	EventSensitiveAccess   EventType = "credential.sensitive_accessed"
+
+	// Note events
+	EventNoteCreated EventType = "note.created"
+	EventNoteUpdated EventType = "note.updated"
+	EventNoteDeleted EventType = "note.deleted"
+
+	// SSH key events
+	EventSSHKeyCreated EventType = "ssh_key.created"
+	EventSSHKeyDeleted EventType = "ssh_key.deleted"
+	EventSSHKeyLoaded  EventType = "ssh_key.loaded"
+
+	// Certificate events
+	EventCertificateCreated EventType = "certificate.created"
+	EventCertificateDeleted EventType = "certificate.deleted"
 
 	// Environment events
 	EventEnvCreated EventType = "env.created"
@@ -35,12 +69,39 @@ const (
 	EventProjectCreated EventType = "project.created"
 	EventProjectDeleted EventType = "project.deleted"
 
+	// Per-secret access events
+	EventAccessGranted EventType = "access.granted"
+	EventAccessRevoked EventType = "access.revoked"
+	EventAccessExpired EventType = "access.expired"
+	EventSelfAdded     EventType = "access.self_added"
+
 	// Security events
 	EventReEncrypt    EventType = "security.reencrypt"
 	EventKeyRotated   EventType = "security.key_rotated"
+	EventKeysPurged   EventType = "security.keys_purged"
 	EventLoginSuccess EventType = "auth.login"
 	EventLoginFailed  EventType = "auth.login_failed"
 	EventLogout       EventType = "auth.logout"
+
+	// Store events
+	EventConfigChanged  EventType = "store.config_changed"
+	EventStoreMigrated  EventType = "store.migrated"
+	EventStoreExported  EventType = "store.exported"
+	EventStoreMoved     EventType = "store.moved"
+	EventStoreSplit     EventType = "store.split"
+	EventStoreMerged    EventType = "store.merged"
+	EventStorePublished EventType = "store.published"
+
+	// Legal hold events
+	EventHoldPlaced       EventType = "hold.placed"
+	EventHoldReleased     EventType = "hold.released"
+	EventHoldApproved     EventType = "hold.approved"
+	EventHoldApprovalUsed EventType = "hold.approval_used"
+
+	// Review events
+	EventProposalCreated  EventType = "proposal.created"
+	EventProposalApproved EventType = "proposal.approved"
+	EventProposalRejected EventType = "proposal.rejected"
 )
 
 // Event represents an audit log entry
@@ -54,11 +115,23 @@ type Event struct {
 	IP        string            `json:"ip,omitempty"` // Client IP if available
 }
 
+// IsMutation reports whether the event records a change to the store, as
+// opposed to a read or a login
+func (t EventType) IsMutation() bool {
+	switch t {
+	case EventCredentialAccess, EventSensitiveAccess, EventEnvAccess, EventTokenRedeemed, EventSSHKeyLoaded, EventStoreExported, EventStorePublished,
+		EventLoginSuccess, EventLoginFailed, EventLogout, EventVerifyFailed:
+		return false
+	}
+	return true
+}
+
 // Logger handles audit logging
 type Logger struct {
 	storePath string
 	logFile   string
 	actor     string // Current user's email
+	listeners []func(Event)
 }
 
 // NewLogger creates a new audit logger
@@ -81,7 +154,18 @@ func (l *Logger) Log(eventType EventType, target string, details map[string]stri
 		Details:   details,
 	}
 
-	return l.writeEvent(event)
+	if err := l.writeEvent(event); err != nil {
+		return err
+	}
+	for _, fn := range l.listeners {
+		fn(event)
+	}
+	return nil
+}
+
+// OnEvent registers fn to receive every event the logger records
+func (l *Logger) OnEvent(fn func(Event)) {
+	l.listeners = append(l.listeners, fn)
 }
 
 // LogWithDetails is a convenience method for logging with key-value pairs
@@ -120,8 +204,17 @@ func (l *Logger) writeEvent(event Event) error {
 	return nil
 }
 
-// GetEvents retrieves audit events, optionally filtered
+// GetEvents retrieves audit events, optionally filtered, oldest first. With
+// a Limit only the end of the log is read.
 func (l *Logger) GetEvents(filter *EventFilter) ([]Event, error) {
+	if filter != nil && filter.Limit > 0 {
+		events, _, err := l.ReadBackward(filter, 0, filter.Limit)
+		for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+			events[i], events[j] = events[j], events[i]
+		}
+		return events, err
+	}
+
 	data, err := os.ReadFile(l.logFile)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -151,6 +244,75 @@ func (l *Logger) GetEvents(filter *EventFilter) ([]Event, error) {
 	return events, nil
 }
 
+// ReadBackward returns up to limit events that match filter, newest first,
+// reading the log from its end so recent events come back at once however
+// large it is. It starts before byte offset before, or at the end if that's
+// zero, and returns the offset to pass for the next page: zero once the
+// start of the log is reached.
+func (l *Logger) ReadBackward(filter *EventFilter, before int64, limit int) ([]Event, int64, error) {
+	f, err := os.Open(l.logFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Event{}, 0, nil
+		}
+		return nil, 0, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	pos := info.Size()
+	if before > 0 && before < pos {
+		pos = before
+	}
+
+	events := []Event{}
+	var partial []byte // Start of a line whose beginning is in an earlier chunk
+	for pos > 0 {
+		n := min(int64(readChunk), pos)
+		pos -= n
+		chunk := make([]byte, n, n+int64(len(partial)))
+		if _, err := f.ReadAt(chunk, pos); err != nil && err != io.EOF {
+			return nil, 0, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		data := append(chunk, partial...)
+
+		for {
+			i := bytes.LastIndexByte(data, '\n')
+			if i < 0 {
+				break
+			}
+			start := pos + int64(i) + 1
+			if event, ok := parseEvent(data[i+1:], filter); ok {
+				events = append(events, event)
+				if len(events) == limit {
+					return events, start, nil
+				}
+			}
+			data = data[:i]
+		}
+		partial = data
+	}
+
+	// The first line of the log
+	if event, ok := parseEvent(partial, filter); ok {
+		events = append(events, event)
+	}
+	return events, 0, nil
+}
+
+// parseEvent parses one line of the log, reporting whether it's an event
+// that matches filter
+func parseEvent(line []byte, filter *EventFilter) (Event, bool) {
+	var event Event
+	if len(bytes.TrimSpace(line)) == 0 || json.Unmarshal(line, &event) != nil {
+		return event, false // Skip malformed lines
+	}
+	return event, filter == nil || filter.Matches(event)
+}
+
 // EventFilter filters audit events
 type EventFilter struct {
 	Types     []EventType
@@ -158,7 +320,7 @@ type EventFilter struct {
 	Target    string
 	StartTime time.Time
 	EndTime   time.Time
-	Limit     int
+	Limit     int // Most recent events to return; zero for all
 }
 
 // Matches checks if an event matches the filter
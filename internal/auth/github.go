@@ -14,6 +14,9 @@ import (
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"passbook/internal/config"
+	"passbook/pkg/ui"
 )
 
 const (
@@ -30,6 +33,15 @@ const (
 
 	// Polling interval for device flow
 	defaultPollInterval = 5 * time.Second
+
+	// DefaultSessionTTL is how long a login lasts unless the store sets
+	// auth.session_hours
+	DefaultSessionTTL = 30 * 24 * time.Hour
+
+	// revalidateInterval is how often a session's token is silently checked
+	// against GitHub, so revoked tokens stop working without a round trip on
+	// every command
+	revalidateInterval = time.Hour
 )
 
 // GitHubClientID is the OAuth App client ID
@@ -52,13 +64,20 @@ var (
 	ErrEmailDomainMismatch = errors.New("email domain not allowed")
 	// ErrNoValidEmail is returned when no valid email found
 	ErrNoValidEmail = errors.New("no valid email found in github account")
+	// ErrNoSession is returned when nobody has logged in
+	ErrNoSession = errors.New("not logged in")
+	// ErrSessionExpired is returned when the saved session is past its expiry
+	ErrSessionExpired = errors.New("session expired")
+	// ErrTokenRevoked is returned when GitHub no longer accepts the token
+	ErrTokenRevoked = errors.New("github token revoked")
 )
 
 // GitHubAuth handles GitHub OAuth authentication
 type GitHubAuth struct {
-	clientID      string
-	configDir     string
-	allowedDomain string
+	clientID       string
+	configDir      string
+	allowedDomains []string
+	sessionTTL     time.Duration
 }
 
 // DeviceCodeResponse from GitHub
@@ -104,10 +123,11 @@ type GitHubSession struct {
 	Name            string    `yaml:"name"`
 	AuthenticatedAt time.Time `yaml:"authenticated_at"`
 	ExpiresAt       time.Time `yaml:"expires_at,omitempty"`
+	ValidatedAt     time.Time `yaml:"validated_at,omitempty"` // Last time GitHub accepted the token
 }
 
 // NewGitHubAuth creates a new GitHub auth handler
-func NewGitHubAuth(configDir, allowedDomain string) *GitHubAuth {
+func NewGitHubAuth(configDir string, allowedDomains []string) *GitHubAuth {
 	// Priority: env var > build-time > error
 	clientID := os.Getenv("PASSBOOK_GITHUB_CLIENT_ID")
 	if clientID == "" {
@@ -115,9 +135,17 @@ func NewGitHubAuth(configDir, allowedDomain string) *GitHubAuth {
 	}
 
 	return &GitHubAuth{
-		clientID:      clientID,
-		configDir:     configDir,
-		allowedDomain: allowedDomain,
+		clientID:       clientID,
+		configDir:      configDir,
+		allowedDomains: allowedDomains,
+		sessionTTL:     DefaultSessionTTL,
+	}
+}
+
+// SetSessionTTL sets how long new logins last; zero keeps the default
+func (g *GitHubAuth) SetSessionTTL(ttl time.Duration) {
+	if ttl > 0 {
+		g.sessionTTL = ttl
 	}
 }
 
@@ -227,6 +255,9 @@ func (g *GitHubAuth) GetUser(accessToken string) (*GitHubUser, error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrTokenRevoked
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("github API error: %s", string(body))
@@ -308,40 +339,30 @@ func (g *GitHubAuth) GetVerifiedEmail(accessToken string) (string, error) {
 	}
 
 	// If we have verified emails but none match domain
-	if g.allowedDomain != "" {
+	if len(g.allowedDomains) > 0 {
 		return "", ErrEmailDomainMismatch
 	}
 
 	return "", ErrNoValidEmail
 }
 
-// isAllowedDomain checks if email matches allowed domain
+// isAllowedDomain checks if email matches one of the allowed domains
 func (g *GitHubAuth) isAllowedDomain(email string) bool {
-	if g.allowedDomain == "" {
-		return true // No domain restriction
-	}
-
-	parts := strings.Split(email, "@")
-	if len(parts) != 2 {
-		return false
-	}
-
-	return strings.EqualFold(parts[1], g.allowedDomain)
+	return config.EmailInDomains(email, g.allowedDomains)
 }
 
 // Authenticate performs the full GitHub authentication flow
 func (g *GitHubAuth) Authenticate() (*GitHubSession, error) {
-	// Check for existing valid session
-	session, err := g.LoadSession()
-	if err == nil && session != nil {
-		// Verify session is still valid by making an API call
-		user, err := g.GetUser(session.AccessToken)
-		if err == nil && user != nil {
-			return session, nil
-		}
-		// Session invalid, continue with new auth
+	// Reuse the saved session while it's valid
+	if session, err := g.Session(); err == nil {
+		return session, nil
 	}
+	return g.Reauthenticate()
+}
 
+// Reauthenticate runs the GitHub device flow even if a valid session is
+// saved, replacing it
+func (g *GitHubAuth) Reauthenticate() (*GitHubSession, error) {
 	// Start device flow
 	deviceResp, err := g.StartDeviceFlow()
 	if err != nil {
@@ -350,14 +371,13 @@ func (g *GitHubAuth) Authenticate() (*GitHubSession, error) {
 
 	// Display instructions to user
 	fmt.Println()
-	fmt.Println("GitHub Authentication")
-	fmt.Println("=====================")
+	ui.Heading("GitHub Authentication")
 	fmt.Println()
 	fmt.Printf("1. Open this URL in your browser:\n")
-	fmt.Printf("   \033[36m%s\033[0m\n", deviceResp.VerificationURI)
+	fmt.Printf("   %s\n", ui.Highlight(deviceResp.VerificationURI))
 	fmt.Println()
 	fmt.Printf("2. Enter this code:\n")
-	fmt.Printf("   \033[1;33m%s\033[0m\n", deviceResp.UserCode)
+	fmt.Printf("   %s\n", ui.Bold(ui.Warn(deviceResp.UserCode)))
 	fmt.Println()
 	fmt.Println("Waiting for authorization...")
 	fmt.Println()
@@ -394,7 +414,7 @@ func (g *GitHubAuth) Authenticate() (*GitHubSession, error) {
 		return nil, ErrExpiredToken
 	}
 
-	fmt.Println("\033[32m✓ Authorization successful!\033[0m")
+	ui.Successf("Authorization successful!")
 	fmt.Println()
 
 	// Get user info
@@ -410,13 +430,15 @@ func (g *GitHubAuth) Authenticate() (*GitHubSession, error) {
 	}
 
 	// Create session
-	session = &GitHubSession{
+	session := &GitHubSession{
 		AccessToken:     accessToken,
 		GitHubID:        user.ID,
 		GitHubLogin:     user.Login,
 		Email:           email,
 		Name:            user.Name,
 		AuthenticatedAt: time.Now(),
+		ExpiresAt:       time.Now().Add(g.sessionTTL),
+		ValidatedAt:     time.Now(),
 	}
 
 	// Save session
@@ -460,6 +482,45 @@ func (g *GitHubAuth) LoadSession() (*GitHubSession, error) {
 	return &session, nil
 }
 
+// Session returns the saved session if it's still valid. The token is
+// revalidated with GitHub at most once an hour; a revoked token clears the
+// session, while GitHub being unreachable doesn't end it before it expires.
+func (g *GitHubAuth) Session() (*GitHubSession, error) {
+	session, err := g.LoadSession()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoSession
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Sessions saved before expiry was recorded last one TTL from login
+	if session.ExpiresAt.IsZero() {
+		session.ExpiresAt = session.AuthenticatedAt.Add(g.sessionTTL)
+	}
+	if session.Expired() {
+		return nil, ErrSessionExpired
+	}
+
+	if time.Since(session.ValidatedAt) >= revalidateInterval {
+		_, err := g.GetUser(session.AccessToken)
+		if errors.Is(err, ErrTokenRevoked) {
+			_ = g.ClearSession()
+			return nil, err
+		}
+		if err == nil {
+			session.ValidatedAt = time.Now()
+			_ = g.SaveSession(session)
+		}
+	}
+	return session, nil
+}
+
+// Expired reports whether the session is past its expiry
+func (s *GitHubSession) Expired() bool {
+	return !s.ExpiresAt.IsZero() && time.Now().After(s.ExpiresAt)
+}
+
 // ClearSession removes the saved session
 func (g *GitHubAuth) ClearSession() error {
 	sessionPath := filepath.Join(g.configDir, "github-session.yaml")
@@ -470,22 +531,22 @@ func (g *GitHubAuth) ClearSession() error {
 	return err
 }
 
+// Configured reports whether an OAuth client ID is set, without which no
+// one can log in
+func (g *GitHubAuth) Configured() bool {
+	return g.clientID != ""
+}
+
 // IsAuthenticated checks if user is authenticated
 func (g *GitHubAuth) IsAuthenticated() bool {
-	session, err := g.LoadSession()
-	if err != nil || session == nil {
-		return false
-	}
-
-	// Verify token is still valid
-	_, err = g.GetUser(session.AccessToken)
+	_, err := g.Session()
 	return err == nil
 }
 
 // VerifyEmail performs GitHub auth and returns the verified email
 // This is the main function to use for verifying a user's email
-func VerifyEmailWithGitHub(configDir, allowedDomain string) (string, error) {
-	auth := NewGitHubAuth(configDir, allowedDomain)
+func VerifyEmailWithGitHub(configDir string, allowedDomains []string) (string, error) {
+	auth := NewGitHubAuth(configDir, allowedDomains)
 	session, err := auth.Authenticate()
 	if err != nil {
 		return "", err
@@ -500,5 +561,8 @@ func (s *GitHubSession) PrettyPrint() string {
 	buf.WriteString(fmt.Sprintf("Email:       %s\n", s.Email))
 	buf.WriteString(fmt.Sprintf("GitHub ID:   %d\n", s.GitHubID))
 	buf.WriteString(fmt.Sprintf("Auth Time:   %s\n", s.AuthenticatedAt.Format(time.RFC3339)))
+	if !s.ExpiresAt.IsZero() {
+		buf.WriteString(fmt.Sprintf("Expires:     %s\n", s.ExpiresAt.Format(time.RFC3339)))
+	}
 	return buf.String()
 }
@@ -11,6 +11,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -68,20 +69,26 @@ type Age struct {
 	publicKey    string              // User's public key (age1...)
 	identity     *age.X25519Identity // Cached identity
 	isEncrypted  bool                // Whether the key file is passphrase-protected
+	hsm          *pkcs11Identity     // Set instead of identity for keys on a token
 }
 
 // New creates a new Age crypto backend
-// If the key is passphrase-protected, it will prompt for the passphrase
+// If the key is wrapped with a KMS key, it's unwrapped with the machine's
+// IAM credentials; if it's passphrase-protected, it will prompt for the
+// passphrase
 func New(identityPath string) (*Age, error) {
 	a := &Age{
 		identityPath: identityPath,
 	}
 
-	// Check if key is encrypted
-	encrypted, err := IsKeyEncrypted(identityPath)
+	data, err := readIdentityFile(identityPath)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to open identity file: %w", err)
 	}
+	defer ZeroBytes(data)
+
+	// Check if key is encrypted
+	encrypted := bytes.Contains(data, []byte(encryptedKeyHeader))
 	a.isEncrypted = encrypted
 
 	if encrypted {
@@ -90,14 +97,14 @@ func New(identityPath string) (*Age, error) {
 		if err != nil {
 			return nil, err
 		}
-		if err := a.loadIdentityWithPassphrase(passphrase); err != nil {
+		if err := a.unlockIdentity(data, passphrase); err != nil {
 			return nil, err
 		}
 		// Note: passphrase is a string, can't be zeroed. The underlying bytes
-		// in loadIdentityWithPassphrase are zeroed after use.
+		// in unlockIdentity are zeroed after use.
 	} else {
 		// Load unencrypted identity
-		if err := a.loadIdentity(); err != nil {
+		if err := a.parseIdentity(data); err != nil {
 			return nil, err
 		}
 	}
@@ -125,6 +132,29 @@ func NewWithoutIdentity() *Age {
 	return &Age{}
 }
 
+// NewFromSecretKey creates an Age backend from an AGE-SECRET-KEY string
+// Used for ephemeral identities that never touch disk
+func NewFromSecretKey(secretKey string) (*Age, error) {
+	identity, err := age.ParseX25519Identity(strings.TrimSpace(secretKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse identity: %w", err)
+	}
+
+	return &Age{
+		identity:  identity,
+		publicKey: identity.Recipient().String(),
+	}, nil
+}
+
+// GenerateEphemeralIdentity creates a new age keypair in memory without saving it
+func GenerateEphemeralIdentity() (secretKey, publicKey string, err error) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate identity: %w", err)
+	}
+	return identity.String(), identity.Recipient().String(), nil
+}
+
 // IsEncrypted returns whether the key file is passphrase-protected
 func (a *Age) IsEncrypted() bool {
 	return a.isEncrypted
@@ -241,6 +271,11 @@ func saveEncryptedIdentity(path string, identity *age.X25519Identity, passphrase
 
 // EncryptExistingKey encrypts an existing unencrypted key file with a passphrase
 func EncryptExistingKey(path, passphrase string) error {
+	if wrapped, err := IsKeyKMSWrapped(path); err != nil {
+		return err
+	} else if wrapped {
+		return ErrKeyKMSWrapped
+	}
 	// Load existing identity
 	a := &Age{identityPath: path}
 	if err := a.loadIdentity(); err != nil {
@@ -253,6 +288,11 @@ func EncryptExistingKey(path, passphrase string) error {
 
 // DecryptKeyFile decrypts an encrypted key file and saves it unencrypted
 func DecryptKeyFile(path, passphrase string) error {
+	if wrapped, err := IsKeyKMSWrapped(path); err != nil {
+		return err
+	} else if wrapped {
+		return ErrKeyKMSWrapped
+	}
 	// Load encrypted identity
 	a := &Age{identityPath: path}
 	if err := a.loadIdentityWithPassphrase(passphrase); err != nil {
@@ -265,6 +305,11 @@ func DecryptKeyFile(path, passphrase string) error {
 
 // ChangePassphrase changes the passphrase on an encrypted key file
 func ChangePassphrase(path, oldPassphrase, newPassphrase string) error {
+	if wrapped, err := IsKeyKMSWrapped(path); err != nil {
+		return err
+	} else if wrapped {
+		return ErrKeyKMSWrapped
+	}
 	// Load with old passphrase
 	a := &Age{identityPath: path}
 	if err := a.loadIdentityWithPassphrase(oldPassphrase); err != nil {
@@ -285,64 +330,118 @@ func (a *Age) PublicKey() string {
 	return a.publicKey
 }
 
-// Encrypt encrypts plaintext for the given recipients
+// Encrypt encrypts plaintext for exactly the given recipients. Our own key
+// is not added; callers that need to read the result back include it, see
+// WithSelf.
 func (a *Age) Encrypt(ctx context.Context, plaintext []byte, recipients []string) ([]byte, error) {
-	// Parse recipient public keys
-	recps, err := a.parseRecipients(recipients)
-	if err != nil {
+	var buf bytes.Buffer
+	if err := a.EncryptStream(ctx, bytes.NewReader(plaintext), &buf, recipients); err != nil {
 		return nil, err
 	}
+	return buf.Bytes(), nil
+}
 
-	// Always include self so we can decrypt
-	if a.publicKey != "" {
-		selfRecp, err := age.ParseX25519Recipient(a.publicKey)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse self public key: %w", err)
-		}
-		recps = append(recps, selfRecp)
+// EncryptStream encrypts everything read from r for exactly the given
+// recipients, like Encrypt, writing the ciphertext to w as it goes, so
+// memory use doesn't grow with the size of the input. It stops when ctx is
+// cancelled, leaving w with a truncated ciphertext that won't decrypt.
+func (a *Age) EncryptStream(ctx context.Context, r io.Reader, w io.Writer, recipients []string) error {
+	// Parse recipient public keys
+	recps, err := a.parseRecipients(recipients)
+	if err != nil {
+		return err
 	}
 
 	// Deduplicate recipients
 	recps = dedupeRecipients(recps)
 
 	if len(recps) == 0 {
-		return nil, errors.New("no recipients specified")
+		return errors.New("no recipients specified")
 	}
 
 	// Encrypt
-	var buf bytes.Buffer
-	w, err := age.Encrypt(&buf, recps...)
+	enc, err := age.Encrypt(w, recps...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create encrypter: %w", err)
+		return fmt.Errorf("failed to create encrypter: %w", err)
 	}
 
-	if _, err := w.Write(plaintext); err != nil {
-		return nil, fmt.Errorf("failed to write plaintext: %w", err)
+	if _, err := io.Copy(enc, contextReader{ctx, r}); err != nil {
+		return fmt.Errorf("failed to write plaintext: %w", err)
 	}
 
-	if err := w.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close encrypter: %w", err)
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("failed to close encrypter: %w", err)
 	}
 
-	return buf.Bytes(), nil
+	return nil
+}
+
+// WithSelf returns recipients with our own key added, if it isn't there
+// already, and whether it had to be added
+func (a *Age) WithSelf(recipients []string) ([]string, bool) {
+	if a.publicKey == "" || slices.Contains(recipients, a.publicKey) {
+		return recipients, false
+	}
+	return append(slices.Clip(recipients), a.publicKey), true
 }
 
 // Decrypt decrypts ciphertext using the user's identity
 func (a *Age) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
-	if a.identity == nil {
+	r, err := a.DecryptStream(ctx, bytes.NewReader(ciphertext))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// DecryptStream returns a reader of the plaintext of the ciphertext read
+// from r, decrypted with the user's identity a chunk at a time. Only the
+// header is read before it returns; a ciphertext that's been tampered with
+// further on fails with ErrDecryptionFailed when that part is read.
+func (a *Age) DecryptStream(ctx context.Context, r io.Reader) (io.Reader, error) {
+	id := a.decryptIdentity()
+	if id == nil {
 		return nil, ErrNoIdentity
 	}
 
 	// Decrypt
-	r, err := age.Decrypt(bytes.NewReader(ciphertext), a.identity)
+	plain, err := age.Decrypt(contextReader{ctx, r}, id)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
 	}
 
-	return io.ReadAll(r)
+	return decryptReader{plain}, nil
+}
+
+// decryptReader reports errors reading a stream's payload as
+// ErrDecryptionFailed, like Decrypt does for its header
+type decryptReader struct {
+	r io.Reader
+}
+
+func (d decryptReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	if err != nil && err != io.EOF && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
+	}
+	return n, err
+}
+
+// contextReader stops reading once its context is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
 }
 
-// EncryptToArmor encrypts and returns ASCII-armored output using age's built-in armor
+// EncryptToArmor encrypts for exactly the given recipients, like Encrypt, and
+// returns ASCII-armored output using age's built-in armor
 func (a *Age) EncryptToArmor(ctx context.Context, plaintext []byte, recipients []string) ([]byte, error) {
 	// Parse recipient public keys
 	recps, err := a.parseRecipients(recipients)
@@ -350,15 +449,6 @@ func (a *Age) EncryptToArmor(ctx context.Context, plaintext []byte, recipients [
 		return nil, err
 	}
 
-	// Always include self
-	if a.publicKey != "" {
-		selfRecp, err := age.ParseX25519Recipient(a.publicKey)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse self public key: %w", err)
-		}
-		recps = append(recps, selfRecp)
-	}
-
 	recps = dedupeRecipients(recps)
 
 	if len(recps) == 0 {
@@ -390,13 +480,14 @@ func (a *Age) EncryptToArmor(ctx context.Context, plaintext []byte, recipients [
 
 // DecryptFromArmor decrypts ASCII-armored ciphertext using age's built-in armor
 func (a *Age) DecryptFromArmor(ctx context.Context, armoredCiphertext []byte) ([]byte, error) {
-	if a.identity == nil {
+	id := a.decryptIdentity()
+	if id == nil {
 		return nil, ErrNoIdentity
 	}
 
 	armorReader := armor.NewReader(bytes.NewReader(armoredCiphertext))
 
-	r, err := age.Decrypt(armorReader, a.identity)
+	r, err := age.Decrypt(armorReader, id)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
 	}
@@ -404,15 +495,31 @@ func (a *Age) DecryptFromArmor(ctx context.Context, armoredCiphertext []byte) ([
 	return io.ReadAll(r)
 }
 
+// decryptIdentity returns the identity that decrypts: the key on a token
+// if there is one, otherwise the loaded key
+func (a *Age) decryptIdentity() age.Identity {
+	if a.hsm != nil {
+		return a.hsm
+	}
+	if a.identity == nil {
+		return nil
+	}
+	return a.identity
+}
+
 // loadIdentity loads the private key from file
 func (a *Age) loadIdentity() error {
-	f, err := os.Open(a.identityPath)
+	data, err := readIdentityFile(a.identityPath)
 	if err != nil {
 		return fmt.Errorf("failed to open identity file: %w", err)
 	}
-	defer f.Close()
+	defer ZeroBytes(data)
+	return a.parseIdentity(data)
+}
 
-	identities, err := age.ParseIdentities(f)
+// parseIdentity parses an unencrypted private key
+func (a *Age) parseIdentity(data []byte) error {
+	identities, err := age.ParseIdentities(bytes.NewReader(data))
 	if err != nil {
 		return fmt.Errorf("failed to parse identity: %w", err)
 	}
@@ -484,10 +591,17 @@ func ValidatePublicKey(key string) bool {
 
 // loadIdentityWithPassphrase loads an encrypted private key file
 func (a *Age) loadIdentityWithPassphrase(passphrase string) error {
-	data, err := os.ReadFile(a.identityPath)
+	data, err := readIdentityFile(a.identityPath)
 	if err != nil {
 		return fmt.Errorf("failed to read identity file: %w", err)
 	}
+	defer ZeroBytes(data)
+	return a.unlockIdentity(data, passphrase)
+}
+
+// unlockIdentity decrypts a passphrase-protected private key
+func (a *Age) unlockIdentity(data []byte, passphrase string) error {
+	var err error
 
 	// Parse the encrypted file format
 	var salt, nonce, ciphertext []byte
@@ -650,6 +764,35 @@ func IsArmored(data []byte) bool {
 	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN AGE ENCRYPTED FILE-----"))
 }
 
+// CountRecipients returns how many recipient stanzas the header of an age
+// file has. Stanzas don't say whose key they are for, so this is as close
+// as anyone without the private keys can get to who can decrypt it.
+func CountRecipients(ciphertext []byte) (int, error) {
+	var r io.Reader = bytes.NewReader(ciphertext)
+	if IsArmored(ciphertext) {
+		r = armor.NewReader(r)
+	}
+
+	br := bufio.NewReader(r)
+	version, err := br.ReadString('\n')
+	if err != nil || strings.TrimSpace(version) != "age-encryption.org/v1" {
+		return 0, errors.New("not an age file")
+	}
+	var n int
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return 0, errors.New("truncated age header")
+		}
+		switch {
+		case strings.HasPrefix(line, "-> "):
+			n++
+		case strings.HasPrefix(line, "--- "):
+			return n, nil
+		}
+	}
+}
+
 // ZeroBytes securely zeros a byte slice
 func ZeroBytes(b []byte) {
 	for i := range b {
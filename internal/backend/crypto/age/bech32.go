@@ -0,0 +1,104 @@
+package age
+
+import (
+	"fmt"
+	"strings"
+)
+
+// recipientHRP is the Bech32 prefix of age X25519 recipients
+const recipientHRP = "age"
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// encodeRecipient formats a raw X25519 public key as an age recipient
+func encodeRecipient(key []byte) (string, error) {
+	data, err := convertBits(key, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	values := append(data, bech32Checksum(recipientHRP, data)...)
+	var b strings.Builder
+	b.WriteString(recipientHRP + "1")
+	for _, v := range values {
+		b.WriteByte(bech32Charset[v])
+	}
+	return b.String(), nil
+}
+
+// decodeRecipient returns the raw X25519 public key of an age recipient
+func decodeRecipient(recipient string) ([]byte, error) {
+	if !ValidatePublicKey(recipient) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRecipient, recipient)
+	}
+	// The recipient parsed, so its checksum and characters are valid
+	s := strings.ToLower(recipient)
+	data := s[strings.LastIndexByte(s, '1')+1:]
+	values := make([]byte, 0, len(data)-6)
+	for _, c := range data[:len(data)-6] {
+		values = append(values, byte(strings.IndexRune(bech32Charset, c)))
+	}
+	return convertBits(values, 5, 8, false)
+}
+
+// bech32Checksum computes the six checksum values for data
+func bech32Checksum(hrp string, data []byte) []byte {
+	values := append(hrpExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	mod := bech32Polymod(values) ^ 1
+	checksum := make([]byte, 6)
+	for i := range checksum {
+		checksum[i] = byte(mod >> uint(5*(5-i)) & 31)
+	}
+	return checksum
+}
+
+func hrpExpand(hrp string) []byte {
+	out := make([]byte, 0, 2*len(hrp)+1)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]>>5)
+	}
+	out = append(out, 0)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]&31)
+	}
+	return out
+}
+
+func bech32Polymod(values []byte) uint32 {
+	gen := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+// convertBits regroups data from frombits-bit values to tobits-bit values
+func convertBits(data []byte, frombits, tobits uint, pad bool) ([]byte, error) {
+	var acc uint32
+	var bits uint
+	maxv := uint32(1)<<tobits - 1
+	var out []byte
+	for _, b := range data {
+		acc = acc<<frombits | uint32(b)
+		bits += frombits
+		for bits >= tobits {
+			bits -= tobits
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(tobits-bits)&maxv))
+		}
+	} else if bits >= frombits || acc<<(tobits-bits)&maxv != 0 {
+		return nil, fmt.Errorf("invalid padding in recipient")
+	}
+	return out, nil
+}
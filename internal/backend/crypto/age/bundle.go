@@ -0,0 +1,82 @@
+package age
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// SealIdentity generates a new keypair in memory and seals the private key
+// with a passphrase (age scrypt) so it can be handed over out of band.
+// The private key never touches disk unencrypted.
+func SealIdentity(passphrase string) (bundle []byte, publicKey string, err error) {
+	if passphrase == "" {
+		return nil, "", fmt.Errorf("passphrase cannot be empty")
+	}
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate identity: %w", err)
+	}
+
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create passphrase recipient: %w", err)
+	}
+
+	var buf bytes.Buffer
+	armorWriter := armor.NewWriter(&buf)
+	w, err := age.Encrypt(armorWriter, recipient)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to seal identity: %w", err)
+	}
+	if _, err := io.WriteString(w, identity.String()+"\n"); err != nil {
+		return nil, "", fmt.Errorf("failed to seal identity: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to seal identity: %w", err)
+	}
+	if err := armorWriter.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to seal identity: %w", err)
+	}
+
+	return buf.Bytes(), identity.Recipient().String(), nil
+}
+
+// OpenSealedIdentity decrypts a bundle made by SealIdentity and saves the
+// private key to path
+func OpenSealedIdentity(bundle []byte, passphrase, path string) (publicKey string, err error) {
+	scryptIdentity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return "", fmt.Errorf("failed to create passphrase identity: %w", err)
+	}
+
+	r, err := age.Decrypt(armor.NewReader(bytes.NewReader(bundle)), scryptIdentity)
+	if err != nil {
+		return "", fmt.Errorf("failed to open key bundle (wrong passphrase?): %w", err)
+	}
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read key bundle: %w", err)
+	}
+	defer ZeroBytes(plaintext)
+
+	identity, err := age.ParseX25519Identity(string(bytes.TrimSpace(plaintext)))
+	if err != nil {
+		return "", fmt.Errorf("key bundle does not contain an age identity: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := saveUnencryptedIdentity(path, identity); err != nil {
+		return "", err
+	}
+
+	return identity.Recipient().String(), nil
+}
@@ -0,0 +1,55 @@
+package age
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// fingerprintBytes is how much of the key hash a fingerprint shows (96 bits)
+const fingerprintBytes = 12
+
+// Fingerprint returns a short, human-comparable representation of a public
+// key, e.g. "3F9A-0C1D-77B2-E4A8-5D10-9C6E"
+func Fingerprint(publicKey string) (string, error) {
+	recipient, err := age.ParseX25519Recipient(strings.TrimSpace(publicKey))
+	if err != nil {
+		return "", fmt.Errorf("invalid public key: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(recipient.String()))
+	digits := strings.ToUpper(hex.EncodeToString(sum[:fingerprintBytes]))
+
+	var groups []string
+	for i := 0; i < len(digits); i += 4 {
+		groups = append(groups, digits[i:i+4])
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// MatchFingerprint reports whether a fingerprint read back by a person
+// (any case, with or without separators) belongs to the public key
+func MatchFingerprint(publicKey, fingerprint string) bool {
+	expected, err := Fingerprint(publicKey)
+	if err != nil {
+		return false
+	}
+	got := normalizeFingerprint(fingerprint)
+	want := normalizeFingerprint(expected)
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// normalizeFingerprint strips separators and upper-cases a fingerprint
+func normalizeFingerprint(fp string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(fp) {
+		if (r >= '0' && r <= '9') || (r >= 'A' && r <= 'F') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
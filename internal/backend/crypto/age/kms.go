@@ -0,0 +1,184 @@
+package age
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"passbook/internal/backend/crypto/kms"
+)
+
+const (
+	// KMS-wrapped key file markers
+	kmsKeyHeader = "-----BEGIN PASSBOOK KMS WRAPPED KEY-----"
+	kmsKeyFooter = "-----END PASSBOOK KMS WRAPPED KEY-----"
+)
+
+// ErrKeyKMSWrapped is returned when changing the passphrase of a key that's
+// wrapped with a KMS key, which would lose the wrapping
+var ErrKeyKMSWrapped = errors.New("key is wrapped with a KMS key")
+
+// IsKeyKMSWrapped checks if an identity file is wrapped with a KMS key
+func IsKeyKMSWrapped(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return bytes.Contains(data, []byte(kmsKeyHeader)), nil
+}
+
+// KMSKeyOf returns the KMS key an identity file is wrapped with
+func KMSKeyOf(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	keyURI, _, err := parseKMSWrapped(data)
+	return keyURI, err
+}
+
+// WrapKeyWithKMS encrypts an identity file with a KMS key, so unlocking it
+// needs IAM access to the key instead of, or as well as, a passphrase. A
+// passphrase-protected key keeps its passphrase inside the wrapping.
+func WrapKeyWithKMS(ctx context.Context, path, keyURI string) error {
+	if wrapped, err := IsKeyKMSWrapped(path); err != nil {
+		return err
+	} else if wrapped {
+		return ErrKeyKMSWrapped
+	}
+	publicKey, err := GetPublicKeyFromFile(path)
+	if err != nil {
+		return err
+	}
+	inner, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read identity file: %w", err)
+	}
+	defer ZeroBytes(inner)
+
+	ciphertext, err := kms.Encrypt(ctx, keyURI, inner)
+	if err != nil {
+		return err
+	}
+	// Check the key can be unwrapped before the plaintext is gone
+	check, err := kms.Decrypt(ctx, keyURI, ciphertext)
+	if err != nil {
+		return fmt.Errorf("KMS key can encrypt but not decrypt: %w", err)
+	}
+	matches := bytes.Equal(check, inner)
+	ZeroBytes(check)
+	if !matches {
+		return fmt.Errorf("KMS returned a different key than it was given")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", kmsKeyHeader)
+	fmt.Fprintf(&b, "# created: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "# public key: %s\n", publicKey)
+	fmt.Fprintf(&b, "kms: %s\n", keyURI)
+	fmt.Fprintf(&b, "data: %s\n", base64.StdEncoding.EncodeToString(ciphertext))
+	fmt.Fprintf(&b, "%s\n", kmsKeyFooter)
+	return replaceKeyFile(path, []byte(b.String()))
+}
+
+// UnwrapKMSKey decrypts a KMS-wrapped identity file back to what it was
+// before wrapping
+func UnwrapKMSKey(ctx context.Context, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read identity file: %w", err)
+	}
+	if !bytes.Contains(data, []byte(kmsKeyHeader)) {
+		return fmt.Errorf("key is not wrapped with a KMS key")
+	}
+	inner, err := unwrapKMS(ctx, data)
+	if err != nil {
+		return err
+	}
+	defer ZeroBytes(inner)
+	return replaceKeyFile(path, inner)
+}
+
+// readIdentityFile reads an identity file, unwrapping it with its KMS key
+// if it has one
+func readIdentityFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Contains(data, []byte(kmsKeyHeader)) {
+		return data, nil
+	}
+	return unwrapKMS(context.Background(), data)
+}
+
+// unwrapKMS decrypts the contents of a KMS-wrapped key file
+func unwrapKMS(ctx context.Context, data []byte) ([]byte, error) {
+	keyURI, ciphertext, err := parseKMSWrapped(data)
+	if err != nil {
+		return nil, err
+	}
+	inner, err := kms.Decrypt(ctx, keyURI, ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap identity with %s: %w", keyURI, err)
+	}
+	return inner, nil
+}
+
+// parseKMSWrapped returns the KMS key and ciphertext of a wrapped key file
+func parseKMSWrapped(data []byte) (string, []byte, error) {
+	var keyURI string
+	var ciphertext []byte
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if v, ok := strings.CutPrefix(line, "kms:"); ok {
+			keyURI = strings.TrimSpace(v)
+		} else if v, ok := strings.CutPrefix(line, "data:"); ok {
+			var err error
+			if ciphertext, err = base64.StdEncoding.DecodeString(strings.TrimSpace(v)); err != nil {
+				return "", nil, fmt.Errorf("failed to decode wrapped key: %w", err)
+			}
+		}
+	}
+	if keyURI == "" || ciphertext == nil {
+		return "", nil, fmt.Errorf("key is not wrapped with a KMS key")
+	}
+	return keyURI, ciphertext, nil
+}
+
+// replaceKeyFile writes an identity file through a temporary file beside
+// it, so a failed write never leaves the key half-written
+func replaceKeyFile(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to create identity file: %w", err)
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write identity file: %w", err)
+	}
+	return nil
+}
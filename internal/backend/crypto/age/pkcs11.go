@@ -0,0 +1,204 @@
+package age
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+
+	"passbook/pkg/securetmp"
+)
+
+const (
+	// pkcs11PINEnv holds the token PIN, for the pkcs11-tool child process and
+	// for servers that can't answer a prompt
+	pkcs11PINEnv = "PASSBOOK_PKCS11_PIN"
+
+	// x25519Label is the HKDF info of age's X25519 recipient stanzas
+	x25519Label = "age-encryption.org/v1/X25519"
+
+	// x25519SPKIPrefix starts the DER SubjectPublicKeyInfo of an X25519 key,
+	// which the 32-byte key follows
+	x25519SPKIPrefix = "302a300506032b656e032100"
+)
+
+// ErrNoSigningKey is returned when an identity can't derive a signing key,
+// because its private key never leaves a hardware token
+var ErrNoSigningKey = errors.New("hardware identities can't sign")
+
+// PKCS11Options selects an X25519 key on a PKCS#11 token
+type PKCS11Options struct {
+	Module    string // Path to the token's PKCS#11 module
+	Token     string // Token label, when there's more than one
+	KeyID     string // Hex CKA_ID of the key
+	PublicKey string // The key's age recipient, as registered with the team
+}
+
+// pkcs11Identity unwraps age file keys with an X25519 key held in a PKCS#11
+// token or HSM, which does the key agreement, so the private key never
+// exists outside it. The token is driven with OpenSC's pkcs11-tool.
+type pkcs11Identity struct {
+	opts      PKCS11Options
+	publicKey []byte
+	pin       string
+}
+
+// NewPKCS11 creates an Age backend whose identity is a key on a PKCS#11
+// token. The PIN is read from PASSBOOK_PKCS11_PIN, or prompted for on
+// first use.
+func NewPKCS11(opts PKCS11Options) (*Age, error) {
+	if opts.Module == "" || opts.KeyID == "" {
+		return nil, fmt.Errorf("pkcs11 identity needs a module and a key id")
+	}
+	if _, err := hex.DecodeString(opts.KeyID); err != nil {
+		return nil, fmt.Errorf("pkcs11 key id must be hex: %w", err)
+	}
+	publicKey, err := decodeRecipient(opts.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11 identity needs its public key: %w", err)
+	}
+	return &Age{
+		publicKey: opts.PublicKey,
+		hsm:       &pkcs11Identity{opts: opts, publicKey: publicKey, pin: os.Getenv(pkcs11PINEnv)},
+	}, nil
+}
+
+// IsHardware reports whether the identity's private key is on a token
+func (a *Age) IsHardware() bool {
+	return a.hsm != nil
+}
+
+// PKCS11PublicKey reads a token's X25519 public key as an age recipient,
+// for registering it with the team
+func PKCS11PublicKey(opts PKCS11Options) (string, error) {
+	id := &pkcs11Identity{opts: opts}
+	der, err := id.run(false, nil, "--read-object", "--type", "pubkey", "--id", opts.KeyID)
+	if err != nil {
+		return "", err
+	}
+	prefix, _ := hex.DecodeString(x25519SPKIPrefix)
+	if len(der) != len(prefix)+curve25519.PointSize || !bytes.HasPrefix(der, prefix) {
+		return "", fmt.Errorf("key %s is not an X25519 key", opts.KeyID)
+	}
+	return encodeRecipient(der[len(prefix):])
+}
+
+// Unwrap implements age.Identity
+func (i *pkcs11Identity) Unwrap(stanzas []*age.Stanza) ([]byte, error) {
+	for _, s := range stanzas {
+		fileKey, err := i.unwrap(s)
+		if errors.Is(err, age.ErrIncorrectIdentity) {
+			continue
+		}
+		return fileKey, err
+	}
+	return nil, age.ErrIncorrectIdentity
+}
+
+// unwrap opens one X25519 stanza, as age's X25519Identity does, with the
+// key agreement done on the token
+func (i *pkcs11Identity) unwrap(s *age.Stanza) ([]byte, error) {
+	if s.Type != "X25519" {
+		return nil, age.ErrIncorrectIdentity
+	}
+	if len(s.Args) != 1 {
+		return nil, errors.New("invalid X25519 recipient block")
+	}
+	share, err := base64.RawStdEncoding.DecodeString(s.Args[0])
+	if err != nil || len(share) != curve25519.PointSize {
+		return nil, errors.New("invalid X25519 recipient block")
+	}
+	if len(s.Body) != 32 {
+		return nil, errors.New("invalid X25519 recipient block")
+	}
+
+	prefix, _ := hex.DecodeString(x25519SPKIPrefix)
+	shared, err := i.run(true, append(prefix, share...), "--derive", "-m", "ECDH1-DERIVE", "--id", i.opts.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	defer ZeroBytes(shared)
+	if len(shared) != curve25519.PointSize || bytes.Equal(shared, make([]byte, curve25519.PointSize)) {
+		return nil, errors.New("token returned an invalid shared secret")
+	}
+
+	salt := append(append([]byte{}, share...), i.publicKey...)
+	wrappingKey := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(x25519Label)), wrappingKey); err != nil {
+		return nil, err
+	}
+	defer ZeroBytes(wrappingKey)
+
+	aead, err := chacha20poly1305.New(wrappingKey)
+	if err != nil {
+		return nil, err
+	}
+	fileKey, err := aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), s.Body, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", age.ErrIncorrectIdentity, err)
+	}
+	return fileKey, nil
+}
+
+// run runs pkcs11-tool against the token with input, returning what it
+// wrote. Files pass through a private directory on a memory-backed
+// filesystem, and the PIN through the environment, never the command line.
+func (i *pkcs11Identity) run(login bool, input []byte, args ...string) ([]byte, error) {
+	tool, err := exec.LookPath("pkcs11-tool")
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11 identities need pkcs11-tool (OpenSC): %w", err)
+	}
+	dir, err := securetmp.MkdirTemp("passbook-pkcs11-")
+	if err != nil {
+		return nil, err
+	}
+	defer securetmp.RemoveAll(dir)
+
+	cmdArgs := []string{"--module", i.opts.Module}
+	if i.opts.Token != "" {
+		cmdArgs = append(cmdArgs, "--token-label", i.opts.Token)
+	}
+	env := os.Environ()
+	if login {
+		if i.pin == "" {
+			if i.pin, err = PromptPassphrase("Enter PIN for token: "); err != nil {
+				return nil, err
+			}
+		}
+		cmdArgs = append(cmdArgs, "--login", "--pin", "env:"+pkcs11PINEnv)
+		env = append(env, pkcs11PINEnv+"="+i.pin)
+	}
+	if input != nil {
+		in := filepath.Join(dir, "input")
+		if err := os.WriteFile(in, input, 0600); err != nil {
+			return nil, err
+		}
+		cmdArgs = append(cmdArgs, "--input-file", in)
+	}
+	out := filepath.Join(dir, "output")
+	cmdArgs = append(cmdArgs, append(args, "--output-file", out)...)
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(tool, cmdArgs...)
+	cmd.Env = env
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("pkcs11-tool failed: %s", msg)
+		}
+		return nil, fmt.Errorf("pkcs11-tool failed: %w", err)
+	}
+	return os.ReadFile(out)
+}
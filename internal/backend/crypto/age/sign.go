@@ -0,0 +1,48 @@
+package age
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// signingKeyContext separates the signing key from any other use of the
+// identity
+const signingKeyContext = "passbook signing key v1\x00"
+
+// SigningKey derives an Ed25519 key from the identity, so members sign with
+// the same key file they decrypt with. age keys can only encrypt.
+func (a *Age) SigningKey() (ed25519.PrivateKey, error) {
+	if a.hsm != nil {
+		return nil, ErrNoSigningKey
+	}
+	if a.identity == nil {
+		return nil, ErrNoIdentity
+	}
+	seed := sha256.Sum256([]byte(signingKeyContext + a.identity.String()))
+	return ed25519.NewKeyFromSeed(seed[:]), nil
+}
+
+// EncodeSigningKey formats a public signing key for storage
+func EncodeSigningKey(key ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// VerifySignature checks a base64 signature of data against an encoded
+// public signing key
+func VerifySignature(encodedKey string, data []byte, signature string) error {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid signing key")
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), data, sig) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+// Package kms wraps small secrets, such as an identity key, with a cloud KMS
+// key. It calls the provider's CLI, which authenticates with whatever IAM
+// credentials the machine has: an instance role, workload identity or a CI
+// job's federated token. Secrets pass through pipes, never files or
+// command lines.
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const (
+	// AWSScheme prefixes AWS KMS keys: aws-kms://arn:aws:kms:REGION:ACCOUNT:key/ID
+	AWSScheme = "aws-kms://"
+
+	// GCPScheme prefixes Cloud KMS keys:
+	// gcp-kms://projects/P/locations/L/keyRings/R/cryptoKeys/K
+	GCPScheme = "gcp-kms://"
+)
+
+// ErrUnsupportedKey is returned for a key URI with an unknown scheme
+var ErrUnsupportedKey = errors.New("unsupported KMS key")
+
+// Validate checks a key URI names a key passbook can use
+func Validate(keyURI string) error {
+	switch {
+	case strings.HasPrefix(keyURI, AWSScheme) && len(keyURI) > len(AWSScheme):
+		return nil
+	case strings.HasPrefix(keyURI, GCPScheme) && strings.Contains(keyURI, "/cryptoKeys/"):
+		return nil
+	}
+	return fmt.Errorf("%w: %q (use %sARN or %sprojects/.../cryptoKeys/NAME)", ErrUnsupportedKey, keyURI, AWSScheme, GCPScheme)
+}
+
+// Encrypt encrypts plaintext with a KMS key
+func Encrypt(ctx context.Context, keyURI string, plaintext []byte) ([]byte, error) {
+	if err := Validate(keyURI); err != nil {
+		return nil, err
+	}
+	if key, ok := strings.CutPrefix(keyURI, AWSScheme); ok {
+		out, err := run(ctx, plaintext, "aws", awsArgs(key, "encrypt", "--plaintext", "fileb:///dev/stdin", "--query", "CiphertextBlob")...)
+		if err != nil {
+			return nil, err
+		}
+		return decodeBase64(out)
+	}
+	key := strings.TrimPrefix(keyURI, GCPScheme)
+	return run(ctx, plaintext, "gcloud", "kms", "encrypt", "--key="+key, "--plaintext-file=-", "--ciphertext-file=-")
+}
+
+// Decrypt decrypts ciphertext made by Encrypt with the same key
+func Decrypt(ctx context.Context, keyURI string, ciphertext []byte) ([]byte, error) {
+	if err := Validate(keyURI); err != nil {
+		return nil, err
+	}
+	if key, ok := strings.CutPrefix(keyURI, AWSScheme); ok {
+		out, err := run(ctx, ciphertext, "aws", awsArgs(key, "decrypt", "--ciphertext-blob", "fileb:///dev/stdin", "--query", "Plaintext")...)
+		if err != nil {
+			return nil, err
+		}
+		defer zero(out)
+		return decodeBase64(out)
+	}
+	key := strings.TrimPrefix(keyURI, GCPScheme)
+	return run(ctx, ciphertext, "gcloud", "kms", "decrypt", "--key="+key, "--ciphertext-file=-", "--plaintext-file=-")
+}
+
+// awsArgs builds an aws kms command for a key, in the key's region when
+// it's an ARN so the CLI's default region doesn't matter
+func awsArgs(key, op string, args ...string) []string {
+	cmd := []string{"kms", op, "--key-id", key, "--output", "text"}
+	if parts := strings.Split(key, ":"); len(parts) >= 6 && parts[0] == "arn" {
+		cmd = append(cmd, "--region", parts[3])
+	}
+	return append(cmd, args...)
+}
+
+// run runs a provider CLI with stdin, returning its output or its error
+// message
+func run(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, fmt.Errorf("KMS needs the %s CLI: %w", name, err)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s kms failed: %s", name, msg)
+		}
+		return nil, fmt.Errorf("%s kms failed: %w", name, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// decodeBase64 decodes the base64 the aws CLI prints blobs as
+func decodeBase64(data []byte) ([]byte, error) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode KMS output: %w", err)
+	}
+	return decoded, nil
+}
+
+// zero overwrites b
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -110,13 +112,27 @@ func (g *Git) Get(ctx context.Context, name string) ([]byte, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("file not found: %s", name)
+			return nil, fmt.Errorf("%s: %w", name, fs.ErrNotExist)
 		}
 		return nil, err
 	}
 	return data, nil
 }
 
+// GetAt reads a file as of a revision, such as a branch or commit
+func (g *Git) GetAt(ctx context.Context, rev, name string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", "show", rev+":"+filepath.ToSlash(name))
+	cmd.Dir = g.path
+	data, err := cmd.Output()
+	if err != nil {
+		if g.cmd("cat-file", "-e", rev+":"+filepath.ToSlash(name)) != nil {
+			return nil, fmt.Errorf("%s at %s: %w", name, rev, fs.ErrNotExist)
+		}
+		return nil, fmt.Errorf("failed to read %s at %s: %w", name, rev, err)
+	}
+	return data, nil
+}
+
 // Set writes a file
 func (g *Git) Set(ctx context.Context, name string, data []byte) error {
 	path := filepath.Join(g.path, name)
@@ -129,6 +145,33 @@ func (g *Git) Set(ctx context.Context, name string, data []byte) error {
 	return os.WriteFile(path, data, 0600)
 }
 
+// SetStream writes a file from what write produces, without holding it in
+// memory. The file is replaced only once write succeeds.
+func (g *Git) SetStream(ctx context.Context, name string, write func(w io.Writer) error) error {
+	path := filepath.Join(g.path, name)
+
+	// Ensure directory exists
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(path), ".set-*")
+	if err != nil {
+		return err
+	}
+	tmp := out.Name()
+	defer os.Remove(tmp)
+
+	if err := write(out); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
 // Delete removes a file
 func (g *Git) Delete(ctx context.Context, name string) error {
 	path := filepath.Join(g.path, name)
@@ -171,7 +214,7 @@ func (g *Git) List(ctx context.Context, prefix string) ([]string, error) {
 		}
 		if !info.IsDir() {
 			relPath, _ := filepath.Rel(g.path, path)
-			files = append(files, relPath)
+			files = append(files, filepath.ToSlash(relPath))
 		}
 		return nil
 	})
@@ -2,27 +2,63 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"passbook/internal/backend/crypto/age"
+	"passbook/pkg/pwgen"
+)
+
+const (
+	// ConfigVersion is the user config format written by this version
+	ConfigVersion = 1
+
+	// StoreVersion is the store format written by this version: versioned
+	// secrets and an encrypted users file
+	StoreVersion = 1
 )
 
 // Config holds all configuration
 type Config struct {
+	// Format of the user config file
+	Version int `yaml:"version"`
+
+	// Format of the store, kept in .passbook-config; zero for stores
+	// created before versioning
+	StoreVersion int `yaml:"store_version,omitempty"`
+
 	// User identity config (local)
 	Identity IdentityConfig `yaml:"identity"`
 
 	// Store config (from .passbook-config)
-	Org   OrgConfig   `yaml:"org"`
-	Git   GitConfig   `yaml:"git"`
-	Email EmailConfig `yaml:"email"`
+	Org       OrgConfig       `yaml:"org"`
+	Git       GitConfig       `yaml:"git"`
+	Email     EmailConfig     `yaml:"email"`
+	Notify    NotifyConfig    `yaml:"notify"`
+	Review    ReviewConfig    `yaml:"review,omitempty"`
+	Auth      AuthConfig      `yaml:"auth,omitempty"`
+	Passwords PasswordsConfig `yaml:"passwords,omitempty"`
+	Access    AccessConfig    `yaml:"access,omitempty"`
+
+	// Local event sinks; never read from the store config
+	Events EventsConfig `yaml:"events,omitempty"`
+
+	// Mirrors kept with 'passbook publish'; local only, since they
+	// re-encrypt secrets for people outside the store
+	Publish map[string]PublishTarget `yaml:"publish,omitempty"`
 
 	// Preferences
 	Preferences PreferencesConfig `yaml:"preferences"`
 
 	// Runtime (not serialized)
+	ReadOnly       bool   `yaml:"-"` // Reject all store writes (PASSBOOK_READ_ONLY)
 	StorePath      string `yaml:"-"`
 	ConfigDir      string `yaml:"-"`
 	UserConfigPath string `yaml:"-"`
@@ -33,12 +69,63 @@ type IdentityConfig struct {
 	Email          string `yaml:"email"`
 	PrivateKeyPath string `yaml:"private_key_path"`
 	PublicKey      string `yaml:"public_key"`
+
+	// Provider holds the private key: "file" (the default) for an age key
+	// file at PrivateKeyPath, or "pkcs11" for a key on a token or HSM
+	Provider string       `yaml:"provider,omitempty"`
+	PKCS11   PKCS11Config `yaml:"pkcs11,omitempty"`
+}
+
+// Identity providers
+const (
+	IdentityProviderFile   = "file"
+	IdentityProviderPKCS11 = "pkcs11"
+)
+
+// PKCS11Config selects an X25519 key on a PKCS#11 token. The PIN isn't
+// stored; it's prompted for or read from PASSBOOK_PKCS11_PIN.
+type PKCS11Config struct {
+	Module string `yaml:"module,omitempty"` // Path to the token's PKCS#11 module
+	Token  string `yaml:"token,omitempty"`  // Token label, when there's more than one
+	KeyID  string `yaml:"key_id,omitempty"` // Hex CKA_ID of the key
+}
+
+// IsHardware reports whether the private key is on a PKCS#11 token
+func (i IdentityConfig) IsHardware() bool {
+	return i.Provider == IdentityProviderPKCS11
 }
 
 // OrgConfig holds organization settings
 type OrgConfig struct {
 	Name          string `yaml:"name"`
 	AllowedDomain string `yaml:"allowed_domain"` // e.g., "mycompany.com"
+
+	// More allowed domains; "*.corp.mycompany.com" allows any subdomain
+	AllowedDomains []string `yaml:"allowed_domains,omitempty"`
+}
+
+// Domains returns every allowed email domain, empty when any is allowed
+func (o OrgConfig) Domains() []string {
+	var domains []string
+	if o.AllowedDomain != "" {
+		domains = append(domains, o.AllowedDomain)
+	}
+	for _, d := range o.AllowedDomains {
+		if d != "" && !slices.Contains(domains, d) {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+// DescribeDomains lists the allowed domains for messages, e.g.
+// "@mycompany.com or @*.corp.mycompany.com"
+func (o OrgConfig) DescribeDomains() string {
+	domains := o.Domains()
+	for i, d := range domains {
+		domains[i] = "@" + d
+	}
+	return strings.Join(domains, " or ")
 }
 
 // GitConfig holds git settings
@@ -47,6 +134,89 @@ type GitConfig struct {
 	AutoPush bool   `yaml:"autopush"`
 	AutoSync bool   `yaml:"autosync"`
 	Branch   string `yaml:"branch"`
+
+	// Branch prod env files live on instead of Branch; set with 'passbook layout'
+	ProdBranch string `yaml:"prod_branch,omitempty"`
+
+	// Remotes the store moved away from with 'passbook store migrate'; clones
+	// still pointing at one switch to Remote on their next pull
+	MovedFrom []string `yaml:"moved_from,omitempty"`
+
+	// How the messages of passbook's commits are written
+	Commit CommitConfig `yaml:"commit,omitempty"`
+
+	// Author of the store's commits, "Name <email>" with {name} and {email}
+	// standing for the member committing; "git" leaves it to git's own
+	// user.name and user.email. DefaultGitAuthor if empty.
+	Author string `yaml:"author,omitempty"`
+}
+
+// GitAuthorFromGit is the git.author that leaves the author to git
+const GitAuthorFromGit = "git"
+
+// DefaultGitAuthor commits as the team member's name and email
+const DefaultGitAuthor = "{name} <{email}>"
+
+// CommitAuthor returns the template for the author of the store's commits
+func (g GitConfig) CommitAuthor() string {
+	if g.Author == "" {
+		return DefaultGitAuthor
+	}
+	return g.Author
+}
+
+// ParseGitAuthor splits a git.author of the form "Name <email>"
+func ParseGitAuthor(author string) (name, email string, err error) {
+	open, end := strings.LastIndex(author, "<"), strings.LastIndex(author, ">")
+	if open < 0 || end != len(author)-1 {
+		return "", "", fmt.Errorf("%q is not of the form \"Name <email>\"", author)
+	}
+	name = strings.TrimSpace(author[:open])
+	email = strings.TrimSpace(author[open+1 : end])
+	if name == "" || email == "" || strings.ContainsAny(name+email, "<>\n") {
+		return "", "", fmt.Errorf("%q is not of the form \"Name <email>\"", author)
+	}
+	return name, email, nil
+}
+
+// CommitConfig shapes the messages of the commits passbook makes. Every
+// commit also ends with Actor, Action and Paths trailers.
+type CommitConfig struct {
+	// Prefix goes before each subject, or before the description of a
+	// conventional commit
+	Prefix string `yaml:"prefix,omitempty"`
+
+	// Template for each subject; {message} is what the command did, and
+	// {action}, {actor} and {ticket} can be used too. Just {message} if empty.
+	Template string `yaml:"template,omitempty"`
+
+	// Conventional writes subjects as conventional commits,
+	// "type(scope): description", scoped to the kind of secret changed
+	Conventional bool `yaml:"conventional,omitempty"`
+
+	// Type of conventional commits; "chore" if empty
+	Type string `yaml:"type,omitempty"`
+
+	// Ticket asks for a ticket ID to record with each commit: "prompt"
+	// allows none to be given, "required" doesn't. Empty to never ask.
+	Ticket string `yaml:"ticket,omitempty"`
+}
+
+// Ticket modes
+const (
+	TicketPrompt   = "prompt"
+	TicketRequired = "required"
+)
+
+// DefaultCommitType is the conventional commit type used when none is set
+const DefaultCommitType = "chore"
+
+// CommitType returns the conventional commit type
+func (c CommitConfig) CommitType() string {
+	if c.Type == "" {
+		return DefaultCommitType
+	}
+	return c.Type
 }
 
 // EmailConfig holds email settings for magic link auth
@@ -63,11 +233,98 @@ type SMTPConfig struct {
 	Password string `yaml:"password"` // Or use env var PASSBOOK_SMTP_PASSWORD
 }
 
+// NotifyConfig holds where store activity notifications are sent
+type NotifyConfig struct {
+	Webhook   string `yaml:"webhook,omitempty"`    // Receives reads of sensitive credentials
+	EventsURL string `yaml:"events_url,omitempty"` // Receives every change to the store
+}
+
+// ReviewConfig holds which changes need another member's approval
+type ReviewConfig struct {
+	Prod bool `yaml:"prod,omitempty"` // Prod env writes and prod-tagged credential edits become proposals
+}
+
+// Default access for new secrets
+const (
+	// AccessTeam encrypts new secrets for everyone whose role allows it
+	AccessTeam = "team"
+	// AccessExplicit encrypts new secrets for their creator and the admins
+	// only; others need an explicit grant
+	AccessExplicit = "explicit"
+)
+
+// AccessConfig holds who new secrets are shared with
+type AccessConfig struct {
+	DefaultAccess string `yaml:"default_access,omitempty"` // AccessTeam or AccessExplicit; empty for team
+}
+
+// Explicit reports whether new secrets start with only their creator and
+// the admins as recipients
+func (a AccessConfig) Explicit() bool {
+	return a.DefaultAccess == AccessExplicit
+}
+
+// AuthConfig holds how long GitHub logins last
+type AuthConfig struct {
+	SessionHours      int `yaml:"session_hours,omitempty"`       // Lifetime of a login; zero for 30 days
+	AdminSessionHours int `yaml:"admin_session_hours,omitempty"` // Admin operations need a login this recent; zero to not require one
+	StepUpMinutes     int `yaml:"step_up_minutes,omitempty"`     // Sensitive commands need a re-auth this recent; zero to not require one
+}
+
+// SessionTTL returns how long new logins last, zero for the default
+func (c AuthConfig) SessionTTL() time.Duration {
+	return time.Duration(c.SessionHours) * time.Hour
+}
+
+// PasswordsConfig holds the team's rules for credential passwords
+type PasswordsConfig struct {
+	MinEntropy int `yaml:"min_entropy,omitempty"` // Bits a new password needs; zero for no minimum
+	History    int `yaml:"history,omitempty"`     // Previous passwords kept per credential; zero for the default
+
+	// Profiles holds generator rules by site, over the built-in ones
+	Profiles map[string]pwgen.Profile `yaml:"profiles,omitempty"`
+}
+
+// DefaultPasswordHistory is how many previous passwords a credential keeps
+// when the store doesn't say
+const DefaultPasswordHistory = 5
+
+// HistoryLimit returns how many previous passwords a credential keeps
+func (p PasswordsConfig) HistoryLimit() int {
+	if p.History == 0 {
+		return DefaultPasswordHistory
+	}
+	return p.History
+}
+
+// EventsConfig holds the local sinks every change to the store is sent to.
+// They run on this machine only, so they live in the user config.
+type EventsConfig struct {
+	Command string `yaml:"command,omitempty"` // Run with the event as JSON on stdin
+	File    string `yaml:"file,omitempty"`    // Appended with one JSON event per line
+}
+
+// PublishTarget is a separate repo kept up to date with a filtered subset
+// of the store, encrypted for its own recipients
+type PublishTarget struct {
+	Remote     string   `yaml:"remote"`
+	Filter     string   `yaml:"filter"`         // e.g. "project=website stage=dev"
+	Recipients []string `yaml:"recipients"`     // age public keys
+	Auto       bool     `yaml:"auto,omitempty"` // Republish after every change to the store
+}
+
 // PreferencesConfig holds user preferences
 type PreferencesConfig struct {
 	Editor           string `yaml:"editor"`
 	ClipboardTimeout int    `yaml:"clipboard_timeout"` // seconds
 	Color            bool   `yaml:"color"`
+
+	// CopyUsernameFirst makes cred copy copy the username, then the password
+	CopyUsernameFirst bool `yaml:"copy_username_first,omitempty"`
+
+	// Metrics records command counts and durations in the config directory,
+	// for passbook metrics show; nothing is sent anywhere
+	Metrics bool `yaml:"metrics,omitempty"`
 }
 
 // ServerConfig holds web server settings
@@ -82,6 +339,9 @@ type ServerConfig struct {
 func Load() (*Config, error) {
 	cfg := &Config{}
 
+	// Color defaults to on; set before loading so "color: false" sticks
+	cfg.Preferences.Color = true
+
 	// Set default paths
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -102,11 +362,31 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
-	// 2. Load store config (shared settings)
+	if cfg.Version > ConfigVersion {
+		return nil, fmt.Errorf("%s is version %d, written by a newer version of passbook; upgrade passbook", cfg.UserConfigPath, cfg.Version)
+	}
+
+	// 2. Load store config (shared settings). The store version only comes
+	// from here, never from the user config.
+	// Local event sinks run commands and publish targets send secrets
+	// elsewhere, so a pushed config must not set them.
+	// Review, auth, password and access rules are team policies, so only
+	// the store config can set them.
+	cfg.StoreVersion = 0
+	cfg.Review = ReviewConfig{}
+	cfg.Auth = AuthConfig{}
+	cfg.Passwords = PasswordsConfig{}
+	cfg.Access = AccessConfig{}
+	events, publish := cfg.Events, cfg.Publish
+	cfg.Publish = nil
 	storeConfigPath := filepath.Join(cfg.StorePath, ".passbook-config")
 	if err := loadYAML(storeConfigPath, cfg); err != nil && !os.IsNotExist(err) {
 		return nil, err
 	}
+	cfg.Events, cfg.Publish = events, publish
+	if cfg.StoreVersion > StoreVersion {
+		return nil, fmt.Errorf("the store at %s is version %d, written by a newer version of passbook; upgrade passbook", cfg.StorePath, cfg.StoreVersion)
+	}
 
 	// 3. Apply defaults
 	applyDefaults(cfg)
@@ -125,6 +405,7 @@ func (c *Config) Save() error {
 	}
 
 	// Marshal user config
+	c.Version = ConfigVersion
 	data, err := yaml.Marshal(c)
 	if err != nil {
 		return err
@@ -135,39 +416,70 @@ func (c *Config) Save() error {
 
 // SaveStoreConfig saves the store configuration
 func (c *Config) SaveStoreConfig() error {
-	storeConfigPath := filepath.Join(c.StorePath, ".passbook-config")
+	return writeYAML(c.StoreConfigPath(), c.storeView())
+}
 
-	// Only save store-relevant config
-	storeConfig := struct {
-		Org   OrgConfig   `yaml:"org"`
-		Git   GitConfig   `yaml:"git"`
-		Email EmailConfig `yaml:"email"`
-	}{
-		Org:   c.Org,
-		Git:   c.Git,
-		Email: c.Email,
-	}
+// StoreConfigPath returns the path of the shared store configuration
+func (c *Config) StoreConfigPath() string {
+	return filepath.Join(c.StorePath, ".passbook-config")
+}
 
-	data, err := yaml.Marshal(storeConfig)
-	if err != nil {
-		return err
-	}
+// storeConfig is the subset of Config shared through the store
+type storeConfig struct {
+	StoreVersion int             `yaml:"store_version,omitempty"`
+	Org          OrgConfig       `yaml:"org"`
+	Git          GitConfig       `yaml:"git"`
+	Email        EmailConfig     `yaml:"email"`
+	Notify       NotifyConfig    `yaml:"notify,omitempty"`
+	Review       ReviewConfig    `yaml:"review,omitempty"`
+	Auth         AuthConfig      `yaml:"auth,omitempty"`
+	Passwords    PasswordsConfig `yaml:"passwords,omitempty"`
+	Access       AccessConfig    `yaml:"access,omitempty"`
+}
 
-	return os.WriteFile(storeConfigPath, data, 0600)
+// storeView returns only the store-relevant config
+func (c *Config) storeView() storeConfig {
+	return storeConfig{StoreVersion: c.StoreVersion, Org: c.Org, Git: c.Git, Email: c.Email, Notify: c.Notify, Review: c.Review, Auth: c.Auth, Passwords: c.Passwords, Access: c.Access}
 }
 
-// IsAllowedEmail checks if email matches org's allowed domain
+// IsAllowedEmail checks if email matches one of the org's allowed domains
 func (c *Config) IsAllowedEmail(email string) bool {
-	if c.Org.AllowedDomain == "" {
+	return EmailInDomains(email, c.Org.Domains())
+}
+
+// EmailInDomains checks if email's domain matches one of domains, where
+// "*.example.com" matches any subdomain of example.com but not example.com
+// itself. No domains means no restriction.
+func EmailInDomains(email string, domains []string) bool {
+	if len(domains) == 0 {
 		return true // No restriction
 	}
 
 	parts := strings.Split(email, "@")
-	if len(parts) != 2 {
+	if len(parts) != 2 || parts[1] == "" {
 		return false
 	}
+	host := strings.ToLower(parts[1])
+
+	for _, d := range domains {
+		d = strings.ToLower(d)
+		if suffix, ok := strings.CutPrefix(d, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == d {
+			return true
+		}
+	}
+	return false
+}
 
-	return strings.EqualFold(parts[1], c.Org.AllowedDomain)
+// EventsFilePath returns the local events file, if one is set
+func (c *Config) EventsFilePath() string {
+	if c.Events.File == "" {
+		return ""
+	}
+	return expandPath(c.Events.File)
 }
 
 // IdentityPath returns the path to the age identity file
@@ -194,6 +506,9 @@ func (c *Config) IsInitialized() bool {
 
 // HasIdentity checks if user has an identity configured
 func (c *Config) HasIdentity() bool {
+	if c.Identity.IsHardware() {
+		return c.Identity.PublicKey != ""
+	}
 	identityPath := c.IdentityPath()
 	_, err := os.Stat(identityPath)
 	return err == nil
@@ -216,6 +531,15 @@ func loadYAML(path string, cfg *Config) error {
 	return yaml.Unmarshal(data, cfg)
 }
 
+// writeYAML writes v to path as YAML
+func writeYAML(path string, v interface{}) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
 // expandPath expands ~ to home directory
 func expandPath(path string) string {
 	if strings.HasPrefix(path, "~/") {
@@ -234,7 +558,19 @@ func applyEnvOverrides(cfg *Config) {
 		cfg.Email.SMTP.Password = password
 	}
 
-	if domain := os.Getenv("PASSBOOK_ALLOWED_DOMAIN"); domain != "" {
-		cfg.Org.AllowedDomain = domain
+	if domains := os.Getenv("PASSBOOK_ALLOWED_DOMAIN"); domains != "" {
+		cfg.Org.AllowedDomain, cfg.Org.AllowedDomains = "", strings.Split(domains, ",")
+	}
+
+	if readOnly, err := strconv.ParseBool(os.Getenv("PASSBOOK_READ_ONLY")); err == nil {
+		cfg.ReadOnly = readOnly
+	}
+
+	// A machine identity, such as one systemd passes in with LoadCredential,
+	// replaces the configured one along with its public key
+	if path := os.Getenv("PASSBOOK_IDENTITY"); path != "" {
+		cfg.Identity.PrivateKeyPath = path
+		cfg.Identity.PublicKey, _ = age.GetPublicKeyFromFile(path)
+		cfg.Identity.Provider = ""
 	}
 }
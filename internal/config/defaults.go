@@ -17,30 +17,10 @@ func applyDefaults(cfg *Config) {
 		cfg.Email.SMTP.Port = 587
 	}
 
-	// Preferences defaults
-	if cfg.Preferences.Editor == "" {
-		cfg.Preferences.Editor = getDefaultEditor()
-	}
+	// Preferences defaults; an empty editor falls back to $VISUAL/$EDITOR when used
 	if cfg.Preferences.ClipboardTimeout == 0 {
 		cfg.Preferences.ClipboardTimeout = 45 // 45 seconds
 	}
-	// Color defaults to true
-	if !cfg.Preferences.Color {
-		cfg.Preferences.Color = true
-	}
-}
-
-// getDefaultEditor returns the default editor from environment
-func getDefaultEditor() string {
-	// Check environment variables in order of preference
-	if editor := os.Getenv("EDITOR"); editor != "" {
-		return editor
-	}
-	if editor := os.Getenv("VISUAL"); editor != "" {
-		return editor
-	}
-	// Default to vim
-	return "vim"
 }
 
 // DefaultConfig returns a new config with default values
@@ -63,7 +43,6 @@ func DefaultConfig() *Config {
 			},
 		},
 		Preferences: PreferencesConfig{
-			Editor:           getDefaultEditor(),
 			ClipboardTimeout: 45,
 			Color:            true,
 		},
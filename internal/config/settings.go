@@ -0,0 +1,513 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scope says which file a setting is stored in
+type Scope string
+
+const (
+	// ScopeUser settings live in ~/.config/passbook/config.yaml
+	ScopeUser Scope = "user"
+	// ScopeStore settings live in the store's .passbook-config and are shared
+	ScopeStore Scope = "store"
+)
+
+// ErrInvalidValue is returned when a setting fails validation
+var ErrInvalidValue = errors.New("invalid value")
+
+// Setting describes a configuration key that can be read and changed from the CLI
+type Setting struct {
+	Key   string
+	Scope Scope
+	Usage string
+	get   func(*Config) string
+	set   func(*Config, string) error
+}
+
+// Get returns the setting's current value
+func (s Setting) Get(c *Config) string {
+	return s.get(c)
+}
+
+// Set validates value and applies it to c
+func (s Setting) Set(c *Config, value string) error {
+	if err := s.set(c, strings.TrimSpace(value)); err != nil {
+		return fmt.Errorf("%w for %s: %w", ErrInvalidValue, s.Key, err)
+	}
+	return nil
+}
+
+// settings documents every supported key. The SMTP password is left out on
+// purpose; it belongs in PASSBOOK_SMTP_PASSWORD, not a file.
+var settings = []Setting{
+	{
+		Key: "identity.email", Scope: ScopeUser, Usage: "Your email address in the team",
+		get: func(c *Config) string { return c.Identity.Email },
+		set: func(c *Config, v string) error {
+			if v != "" && !strings.Contains(v, "@") {
+				return fmt.Errorf("%q is not an email address", v)
+			}
+			c.Identity.Email = v
+			return nil
+		},
+	},
+	{
+		Key: "identity.private_key_path", Scope: ScopeUser, Usage: "Path to your age identity file",
+		get: func(c *Config) string { return c.Identity.PrivateKeyPath },
+		set: func(c *Config, v string) error { c.Identity.PrivateKeyPath = v; return nil },
+	},
+	{
+		Key: "identity.provider", Scope: ScopeUser, Usage: "Where your private key is: file or pkcs11 (set up with 'passbook key hsm')",
+		get: func(c *Config) string { return c.Identity.Provider },
+		set: func(c *Config, v string) error {
+			switch v {
+			case "", IdentityProviderFile:
+			case IdentityProviderPKCS11:
+				if c.Identity.PKCS11.Module == "" || c.Identity.PKCS11.KeyID == "" {
+					return fmt.Errorf("set up the token with 'passbook key hsm' first")
+				}
+			default:
+				return fmt.Errorf("%q is not file or pkcs11", v)
+			}
+			c.Identity.Provider = v
+			return nil
+		},
+	},
+	{
+		Key: "preferences.editor", Scope: ScopeUser, Usage: "Editor for 'edit' commands (else $VISUAL, $EDITOR)",
+		get: func(c *Config) string { return c.Preferences.Editor },
+		set: func(c *Config, v string) error { c.Preferences.Editor = v; return nil },
+	},
+	{
+		Key: "preferences.clipboard_timeout", Scope: ScopeUser, Usage: "Seconds before a copied secret is cleared",
+		get: func(c *Config) string { return strconv.Itoa(c.Preferences.ClipboardTimeout) },
+		set: func(c *Config, v string) error {
+			n, err := parseIntRange(v, 0, 3600)
+			if err != nil {
+				return err
+			}
+			c.Preferences.ClipboardTimeout = n
+			return nil
+		},
+	},
+	{
+		Key: "preferences.color", Scope: ScopeUser, Usage: "Use colored output",
+		get: func(c *Config) string { return strconv.FormatBool(c.Preferences.Color) },
+		set: func(c *Config, v string) error { return parseBoolInto(v, &c.Preferences.Color) },
+	},
+	{
+		Key: "preferences.copy_username_first", Scope: ScopeUser, Usage: "Have cred copy copy the username, then the password",
+		get: func(c *Config) string { return strconv.FormatBool(c.Preferences.CopyUsernameFirst) },
+		set: func(c *Config, v string) error { return parseBoolInto(v, &c.Preferences.CopyUsernameFirst) },
+	},
+	{
+		Key: "preferences.metrics", Scope: ScopeUser, Usage: "Record command counts and durations locally for passbook metrics show",
+		get: func(c *Config) string { return strconv.FormatBool(c.Preferences.Metrics) },
+		set: func(c *Config, v string) error { return parseBoolInto(v, &c.Preferences.Metrics) },
+	},
+	{
+		Key: "org.name", Scope: ScopeStore, Usage: "Organization name",
+		get: func(c *Config) string { return c.Org.Name },
+		set: func(c *Config, v string) error { c.Org.Name = v; return nil },
+	},
+	{
+		Key: "org.allowed_domain", Scope: ScopeStore, Usage: "Only allow team members with this email domain",
+		get: func(c *Config) string { return c.Org.AllowedDomain },
+		set: func(c *Config, v string) error {
+			domain, err := parseDomain(v)
+			if err != nil {
+				return err
+			}
+			c.Org.AllowedDomain = domain
+			return nil
+		},
+	},
+	{
+		Key: "org.allowed_domains", Scope: ScopeStore, Usage: "More allowed email domains, comma-separated; *.example.com allows subdomains",
+		get: func(c *Config) string { return strings.Join(c.Org.AllowedDomains, ",") },
+		set: func(c *Config, v string) error {
+			var domains []string
+			for _, d := range strings.Split(v, ",") {
+				if strings.TrimSpace(d) == "" {
+					continue
+				}
+				domain, err := parseDomain(d)
+				if err != nil {
+					return err
+				}
+				domains = append(domains, domain)
+			}
+			c.Org.AllowedDomains = domains
+			return nil
+		},
+	},
+	{
+		Key: "git.remote", Scope: ScopeStore, Usage: "Remote URL the store syncs with",
+		get: func(c *Config) string { return c.Git.Remote },
+		set: func(c *Config, v string) error { c.Git.Remote = v; return nil },
+	},
+	{
+		Key: "git.branch", Scope: ScopeStore, Usage: "Branch the store syncs",
+		get: func(c *Config) string { return c.Git.Branch },
+		set: func(c *Config, v string) error {
+			if strings.ContainsAny(v, " ~^:?*[\\") {
+				return fmt.Errorf("%q is not a valid branch name", v)
+			}
+			c.Git.Branch = v
+			return nil
+		},
+	},
+	{
+		Key: "git.autopush", Scope: ScopeStore, Usage: "Push after every change",
+		get: func(c *Config) string { return strconv.FormatBool(c.Git.AutoPush) },
+		set: func(c *Config, v string) error { return parseBoolInto(v, &c.Git.AutoPush) },
+	},
+	{
+		Key: "git.autosync", Scope: ScopeStore, Usage: "Pull before every change",
+		get: func(c *Config) string { return strconv.FormatBool(c.Git.AutoSync) },
+		set: func(c *Config, v string) error { return parseBoolInto(v, &c.Git.AutoSync) },
+	},
+	{
+		Key: "git.commit.prefix", Scope: ScopeStore, Usage: "Text put before the subject of every commit, such as [secrets]",
+		get: func(c *Config) string { return c.Git.Commit.Prefix },
+		set: func(c *Config, v string) error { c.Git.Commit.Prefix = v; return nil },
+	},
+	{
+		Key: "git.commit.template", Scope: ScopeStore, Usage: "Subject of every commit, with {message} and optionally {action}, {actor} and {ticket}",
+		get: func(c *Config) string { return c.Git.Commit.Template },
+		set: func(c *Config, v string) error {
+			if v != "" && !strings.Contains(v, "{message}") {
+				return fmt.Errorf("%q has no {message}", v)
+			}
+			c.Git.Commit.Template = v
+			return nil
+		},
+	},
+	{
+		Key: "git.commit.conventional", Scope: ScopeStore, Usage: "Write commit subjects as conventional commits, type(scope): description",
+		get: func(c *Config) string { return strconv.FormatBool(c.Git.Commit.Conventional) },
+		set: func(c *Config, v string) error { return parseBoolInto(v, &c.Git.Commit.Conventional) },
+	},
+	{
+		Key: "git.commit.type", Scope: ScopeStore, Usage: "Type of conventional commits (default chore)",
+		get: func(c *Config) string { return c.Git.Commit.CommitType() },
+		set: func(c *Config, v string) error {
+			if strings.ContainsAny(v, " ():!") {
+				return fmt.Errorf("%q is not a commit type", v)
+			}
+			c.Git.Commit.Type = v
+			return nil
+		},
+	},
+	{
+		Key: "git.commit.ticket", Scope: ScopeStore, Usage: "Ask for a ticket ID with each commit: off, prompt or required (PASSBOOK_TICKET answers it)",
+		get: func(c *Config) string {
+			if c.Git.Commit.Ticket == "" {
+				return "off"
+			}
+			return c.Git.Commit.Ticket
+		},
+		set: func(c *Config, v string) error {
+			switch v {
+			case "", "off":
+				c.Git.Commit.Ticket = ""
+			case TicketPrompt, TicketRequired:
+				c.Git.Commit.Ticket = v
+			default:
+				return fmt.Errorf("%q is not off, prompt or required", v)
+			}
+			return nil
+		},
+	},
+	{
+		Key: "git.author", Scope: ScopeStore, Usage: "Author of commits, \"Name <email>\" with {name} and {email} of the member, or git to use git's own identity",
+		get: func(c *Config) string { return c.Git.CommitAuthor() },
+		set: func(c *Config, v string) error {
+			if v != "" && v != GitAuthorFromGit {
+				if _, _, err := ParseGitAuthor(v); err != nil {
+					return err
+				}
+			}
+			c.Git.Author = v
+			return nil
+		},
+	},
+	{
+		Key: "email.provider", Scope: ScopeStore, Usage: "Email provider for login links (console, smtp, sendgrid, ses)",
+		get: func(c *Config) string { return c.Email.Provider },
+		set: func(c *Config, v string) error {
+			switch v {
+			case "", "console", "smtp", "sendgrid", "ses":
+				c.Email.Provider = v
+				return nil
+			}
+			return fmt.Errorf("unknown provider %q (use console, smtp, sendgrid or ses)", v)
+		},
+	},
+	{
+		Key: "email.smtp.host", Scope: ScopeStore, Usage: "SMTP server host",
+		get: func(c *Config) string { return c.Email.SMTP.Host },
+		set: func(c *Config, v string) error { c.Email.SMTP.Host = v; return nil },
+	},
+	{
+		Key: "email.smtp.port", Scope: ScopeStore, Usage: "SMTP server port",
+		get: func(c *Config) string { return strconv.Itoa(c.Email.SMTP.Port) },
+		set: func(c *Config, v string) error {
+			n, err := parseIntRange(v, 0, 65535)
+			if err != nil {
+				return err
+			}
+			c.Email.SMTP.Port = n
+			return nil
+		},
+	},
+	{
+		Key: "email.smtp.username", Scope: ScopeStore, Usage: "SMTP username",
+		get: func(c *Config) string { return c.Email.SMTP.Username },
+		set: func(c *Config, v string) error { c.Email.SMTP.Username = v; return nil },
+	},
+	{
+		Key: "notify.webhook", Scope: ScopeStore, Usage: "Webhook notified when a sensitive credential is read (e.g. a Slack incoming webhook)",
+		get: func(c *Config) string { return c.Notify.Webhook },
+		set: func(c *Config, v string) error {
+			if v != "" && !strings.HasPrefix(v, "https://") && !strings.HasPrefix(v, "http://") {
+				return fmt.Errorf("%q is not an http(s) URL", v)
+			}
+			c.Notify.Webhook = v
+			return nil
+		},
+	},
+	{
+		Key: "notify.events_url", Scope: ScopeStore, Usage: "URL every change to the store is posted to as JSON",
+		get: func(c *Config) string { return c.Notify.EventsURL },
+		set: func(c *Config, v string) error {
+			if v != "" && !strings.HasPrefix(v, "https://") && !strings.HasPrefix(v, "http://") {
+				return fmt.Errorf("%q is not an http(s) URL", v)
+			}
+			c.Notify.EventsURL = v
+			return nil
+		},
+	},
+	{
+		Key: "review.prod", Scope: ScopeStore, Usage: "Send prod env writes and edits of credentials tagged prod to review instead of main",
+		get: func(c *Config) string { return strconv.FormatBool(c.Review.Prod) },
+		set: func(c *Config, v string) error { return parseBoolInto(v, &c.Review.Prod) },
+	},
+	{
+		Key: "auth.session_hours", Scope: ScopeStore, Usage: "Hours a GitHub login lasts before 'passbook login' is needed again (0 for 30 days)",
+		get: func(c *Config) string { return strconv.Itoa(c.Auth.SessionHours) },
+		set: func(c *Config, v string) error {
+			n, err := parseIntRange(v, 0, 24*365)
+			if err != nil {
+				return err
+			}
+			c.Auth.SessionHours = n
+			return nil
+		},
+	},
+	{
+		Key: "auth.admin_session_hours", Scope: ScopeStore, Usage: "Admin operations need a GitHub login within this many hours (0 to not require one)",
+		get: func(c *Config) string { return strconv.Itoa(c.Auth.AdminSessionHours) },
+		set: func(c *Config, v string) error {
+			n, err := parseIntRange(v, 0, 24*365)
+			if err != nil {
+				return err
+			}
+			c.Auth.AdminSessionHours = n
+			return nil
+		},
+	},
+	{
+		Key: "auth.step_up_minutes", Scope: ScopeStore, Usage: "Revoking members, re-encrypting and exporting or revealing prod need a re-auth within this many minutes (0 to not require one)",
+		get: func(c *Config) string { return strconv.Itoa(c.Auth.StepUpMinutes) },
+		set: func(c *Config, v string) error {
+			n, err := parseIntRange(v, 0, 24*60)
+			if err != nil {
+				return err
+			}
+			c.Auth.StepUpMinutes = n
+			return nil
+		},
+	},
+	{
+		Key: "passwords.min_entropy", Scope: ScopeStore, Usage: "Bits of entropy a new credential password needs (0 for no minimum)",
+		get: func(c *Config) string { return strconv.Itoa(c.Passwords.MinEntropy) },
+		set: func(c *Config, v string) error {
+			n, err := parseIntRange(v, 0, 512)
+			if err != nil {
+				return err
+			}
+			c.Passwords.MinEntropy = n
+			return nil
+		},
+	},
+	{
+		Key: "passwords.history", Scope: ScopeStore, Usage: "Previous passwords kept in each credential (0 for the default of 5)",
+		get: func(c *Config) string { return strconv.Itoa(c.Passwords.HistoryLimit()) },
+		set: func(c *Config, v string) error {
+			n, err := parseIntRange(v, 0, 100)
+			if err != nil {
+				return err
+			}
+			c.Passwords.History = n
+			return nil
+		},
+	},
+	{
+		Key: "access.default_access", Scope: ScopeStore, Usage: "Who new credentials and env files are encrypted for: team (everyone their role allows) or explicit (only the creator and admins)",
+		get: func(c *Config) string {
+			if c.Access.DefaultAccess == "" {
+				return AccessTeam
+			}
+			return c.Access.DefaultAccess
+		},
+		set: func(c *Config, v string) error {
+			switch v {
+			case "", AccessTeam:
+				c.Access.DefaultAccess = ""
+			case AccessExplicit:
+				c.Access.DefaultAccess = v
+			default:
+				return fmt.Errorf("%q is not team or explicit", v)
+			}
+			return nil
+		},
+	},
+	{
+		Key: "events.command", Scope: ScopeUser, Usage: "Command run for every change to the store, with the event as JSON on stdin",
+		get: func(c *Config) string { return c.Events.Command },
+		set: func(c *Config, v string) error { c.Events.Command = v; return nil },
+	},
+	{
+		Key: "events.file", Scope: ScopeUser, Usage: "File every change to the store is appended to as a JSON line",
+		get: func(c *Config) string { return c.Events.File },
+		set: func(c *Config, v string) error { c.Events.File = v; return nil },
+	},
+}
+
+// Settings returns every documented setting
+func Settings() []Setting {
+	return settings
+}
+
+// LookupSetting finds a setting by key
+func LookupSetting(key string) (Setting, bool) {
+	for _, s := range settings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+// Update validates and applies a setting, then writes it to the file for its
+// scope. Only that file is rewritten, so values that came from the other file
+// or the environment (like PASSBOOK_SMTP_PASSWORD) are never persisted.
+func (c *Config) Update(s Setting, value string) error {
+	path := c.ScopePath(s.Scope)
+	file := &Config{}
+	if err := loadYAML(path, file); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := s.Set(file, value); err != nil {
+		return err
+	}
+	if err := s.Set(c, value); err != nil {
+		return err
+	}
+
+	if s.Scope == ScopeStore {
+		return writeYAML(path, file.storeView())
+	}
+	if err := os.MkdirAll(c.ConfigDir, 0700); err != nil {
+		return err
+	}
+	file.Version = ConfigVersion
+	return writeYAML(path, file)
+}
+
+// ScopePath returns the file that holds settings of the given scope
+func (c *Config) ScopePath(scope Scope) string {
+	if scope == ScopeStore {
+		return c.StoreConfigPath()
+	}
+	return c.UserConfigPath
+}
+
+// Check re-validates every setting, e.g. after the config file was edited by hand
+func (c *Config) Check() error {
+	scratch := *c
+	for _, s := range settings {
+		if err := s.Set(&scratch, s.Get(c)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckFile validates the contents of a config file before it replaces the
+// original, rejecting unknown keys so typos don't silently do nothing
+func CheckFile(scope Scope, data []byte) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	file := &Config{}
+	var err error
+	if scope == ScopeStore {
+		var view storeConfig
+		err = dec.Decode(&view)
+		file.Org, file.Git, file.Email, file.Notify, file.Review, file.Auth = view.Org, view.Git, view.Email, view.Notify, view.Review, view.Auth
+		file.Passwords, file.Access = view.Passwords, view.Access
+	} else {
+		err = dec.Decode(file)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: %w", ErrInvalidValue, err)
+	}
+	return file.Check()
+}
+
+// parseDomain parses an allowed email domain, which may start with "*."
+// to allow its subdomains
+func parseDomain(v string) (string, error) {
+	v = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(v), "@"))
+	if strings.ContainsAny(v, "@ /") || strings.Contains(strings.TrimPrefix(v, "*."), "*") {
+		return "", fmt.Errorf("%q is not a domain", v)
+	}
+	if v == "*." {
+		return "", fmt.Errorf("%q has no domain after the wildcard", v)
+	}
+	return v, nil
+}
+
+// parseBoolInto parses a boolean setting
+func parseBoolInto(v string, dst *bool) error {
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("%q is not true or false", v)
+	}
+	*dst = b
+	return nil
+}
+
+// parseIntRange parses an integer setting within [lo, hi]
+func parseIntRange(v string, lo, hi int) (int, error) {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", v)
+	}
+	if n < lo || n > hi {
+		return 0, fmt.Errorf("%d is out of range (%d-%d)", n, lo, hi)
+	}
+	return n, nil
+}
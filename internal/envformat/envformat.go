@@ -0,0 +1,201 @@
+// Package envformat converts env files to and from dotenv, shell, JSON,
+// YAML and TOML. Structured formats may nest: nested keys are flattened into
+// variable names joined with a separator, and split again on export.
+package envformat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"passbook/internal/models"
+)
+
+// DefaultSeparator joins nested keys, so {"db": {"host": ...}} becomes db__host
+const DefaultSeparator = "__"
+
+// Formats lists the supported formats
+var Formats = []string{"dotenv", "export", "json", "yaml", "toml"}
+
+// FromPath guesses a format from a file extension, defaulting to dotenv
+func FromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	default:
+		return "dotenv"
+	}
+}
+
+// Decode parses data in format into variables, flattening nested keys with
+// sep. Every variable is marked secret.
+func Decode(format string, data []byte, sep string) ([]models.EnvVar, error) {
+	var tree map[string]any
+	switch format {
+	case "dotenv", "":
+		return models.ParseDotEnv(string(data))
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&tree); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+	case "yaml":
+		if err := yaml.Unmarshal(data, &tree); err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+	case "toml":
+		var err error
+		if tree, err = parseTOML(string(data)); err != nil {
+			return nil, fmt.Errorf("invalid TOML: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown format: %s (valid: dotenv, json, yaml, toml)", format)
+	}
+
+	var vars []models.EnvVar
+	if err := flatten("", tree, sep, &vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// Encode renders variables in format, nesting keys that contain sep for
+// the structured formats. An empty sep keeps them flat.
+func Encode(format string, env *models.EnvFile, sep string) ([]byte, error) {
+	switch format {
+	case "dotenv", "":
+		return []byte(env.ToDotEnv()), nil
+	case "export":
+		return []byte(env.ToExport()), nil
+	case "json", "yaml", "toml":
+	default:
+		return nil, fmt.Errorf("unknown format: %s (valid: %s)", format, strings.Join(Formats, ", "))
+	}
+
+	tree, err := unflatten(env.Vars, sep)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(tree, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		return append(data, '\n'), nil
+	case "yaml":
+		data, err := yaml.Marshal(tree)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal YAML: %w", err)
+		}
+		return data, nil
+	default:
+		return encodeTOML(tree), nil
+	}
+}
+
+// flatten appends the scalars in v as variables named by their key path
+func flatten(prefix string, v any, sep string, vars *[]models.EnvVar) error {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + sep + key
+	}
+
+	switch v := v.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, item := range v {
+			m[fmt.Sprint(k)] = item
+		}
+		return flatten(prefix, m, sep, vars)
+	case map[string]any:
+		if prefix != "" && sep == "" {
+			return fmt.Errorf("%s is nested; set a separator to flatten it", prefix)
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := flatten(join(k), v[k], sep, vars); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if sep == "" {
+			return fmt.Errorf("%s is a list; set a separator to flatten it", prefix)
+		}
+		for i, item := range v {
+			if err := flatten(join(strconv.Itoa(i)), item, sep, vars); err != nil {
+				return err
+			}
+		}
+	default:
+		if prefix == "" {
+			return fmt.Errorf("expected a map of variables at the top level")
+		}
+		*vars = append(*vars, models.EnvVar{Key: prefix, Value: scalarString(v), IsSecret: true})
+	}
+	return nil
+}
+
+// scalarString formats a decoded scalar as a variable value
+func scalarString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// unflatten nests variables into maps by splitting their keys on sep
+func unflatten(vars []models.EnvVar, sep string) (map[string]any, error) {
+	tree := make(map[string]any)
+	for _, v := range vars {
+		parts := []string{v.Key}
+		if sep != "" {
+			parts = strings.Split(v.Key, sep)
+		}
+
+		node := tree
+		for i, part := range parts[:len(parts)-1] {
+			switch child := node[part].(type) {
+			case nil:
+				next := make(map[string]any)
+				node[part] = next
+				node = next
+			case map[string]any:
+				node = child
+			default:
+				return nil, fmt.Errorf("can't nest %s: %s already has a value", v.Key, strings.Join(parts[:i+1], sep))
+			}
+		}
+
+		last := parts[len(parts)-1]
+		if _, ok := node[last].(map[string]any); ok {
+			return nil, fmt.Errorf("can't set %s: other variables are nested under it", v.Key)
+		}
+		node[last] = v.Value
+	}
+	return tree, nil
+}
@@ -0,0 +1,524 @@
+package envformat
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseTOML reads the subset of TOML that config files use: tables, dotted
+// and quoted keys, strings, numbers, booleans, dates (kept as text), arrays
+// and inline tables. Arrays of tables aren't supported.
+func parseTOML(src string) (map[string]any, error) {
+	p := &tomlParser{src: strings.ReplaceAll(src, "\r\n", "\n"), line: 1}
+	root := make(map[string]any)
+	current := root
+
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return root, nil
+		}
+
+		switch p.peek() {
+		case '#':
+			p.skipComment()
+			continue
+		case '[':
+			p.pos++
+			if !p.eof() && p.peek() == '[' {
+				return nil, p.errorf("arrays of tables aren't supported")
+			}
+			path, err := p.parseKey()
+			if err != nil {
+				return nil, err
+			}
+			if !p.consume(']') {
+				return nil, p.errorf("expected ']' after table name")
+			}
+			if current, err = tableAt(root, path); err != nil {
+				return nil, p.errorf("%v", err)
+			}
+		default:
+			if err := p.parseKeyValue(current); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := p.endLine(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// tableAt returns the table at path, creating missing ones
+func tableAt(root map[string]any, path []string) (map[string]any, error) {
+	node := root
+	for _, key := range path {
+		switch child := node[key].(type) {
+		case nil:
+			next := make(map[string]any)
+			node[key] = next
+			node = next
+		case map[string]any:
+			node = child
+		default:
+			return nil, fmt.Errorf("%s is already a value", strings.Join(path, "."))
+		}
+	}
+	return node, nil
+}
+
+// tomlParser reads TOML from a string
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (p *tomlParser) eof() bool  { return p.pos >= len(p.src) }
+func (p *tomlParser) peek() byte { return p.src[p.pos] }
+
+func (p *tomlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+// consume skips spaces and then b, reporting whether b was there
+func (p *tomlParser) consume(b byte) bool {
+	p.skipSpace()
+	if !p.eof() && p.peek() == b {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// skipSpace skips spaces and tabs
+func (p *tomlParser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// skipBlank skips whitespace including newlines
+func (p *tomlParser) skipBlank() {
+	for !p.eof() {
+		switch p.peek() {
+		case '\n':
+			p.line++
+		case ' ', '\t':
+		default:
+			return
+		}
+		p.pos++
+	}
+}
+
+// skipBlankAndComments skips whitespace, newlines and comments, as allowed
+// inside arrays
+func (p *tomlParser) skipBlankAndComments() {
+	for {
+		p.skipBlank()
+		if p.eof() || p.peek() != '#' {
+			return
+		}
+		p.skipComment()
+	}
+}
+
+// skipComment skips to the end of the line
+func (p *tomlParser) skipComment() {
+	for !p.eof() && p.peek() != '\n' {
+		p.pos++
+	}
+}
+
+// endLine requires the rest of the line to be blank or a comment
+func (p *tomlParser) endLine() error {
+	p.skipSpace()
+	if !p.eof() && p.peek() == '#' {
+		p.skipComment()
+	}
+	if !p.eof() && p.peek() != '\n' {
+		return p.errorf("unexpected %q", p.peek())
+	}
+	return nil
+}
+
+// parseKeyValue reads key = value into table
+func (p *tomlParser) parseKeyValue(table map[string]any) error {
+	path, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if !p.consume('=') {
+		return p.errorf("expected '=' after %s", strings.Join(path, "."))
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	parent, err := tableAt(table, path[:len(path)-1])
+	if err != nil {
+		return p.errorf("%v", err)
+	}
+	last := path[len(path)-1]
+	if _, exists := parent[last]; exists {
+		return p.errorf("%s is defined twice", strings.Join(path, "."))
+	}
+	parent[last] = value
+	return nil
+}
+
+// parseKey reads a bare, quoted or dotted key
+func (p *tomlParser) parseKey() ([]string, error) {
+	var path []string
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nil, p.errorf("expected a key")
+		}
+
+		var part string
+		switch p.peek() {
+		case '"':
+			s, err := p.parseBasicString()
+			if err != nil {
+				return nil, err
+			}
+			part = s
+		case '\'':
+			s, err := p.parseLiteralString()
+			if err != nil {
+				return nil, err
+			}
+			part = s
+		default:
+			start := p.pos
+			for !p.eof() && isBareKeyByte(p.peek()) {
+				p.pos++
+			}
+			if p.pos == start {
+				return nil, p.errorf("expected a key")
+			}
+			part = p.src[start:p.pos]
+		}
+		path = append(path, part)
+
+		p.skipSpace()
+		if p.eof() || p.peek() != '.' {
+			return path, nil
+		}
+		p.pos++
+	}
+}
+
+// isBareKeyByte reports whether b may appear in an unquoted key
+func isBareKeyByte(b byte) bool {
+	return b == '_' || b == '-' || (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9')
+}
+
+// parseValue reads any value
+func (p *tomlParser) parseValue() (any, error) {
+	p.skipSpace()
+	if p.eof() {
+		return nil, p.errorf("expected a value")
+	}
+
+	switch p.peek() {
+	case '"':
+		if strings.HasPrefix(p.src[p.pos:], `"""`) {
+			return p.parseMultilineString(`"""`, true)
+		}
+		return p.parseBasicString()
+	case '\'':
+		if strings.HasPrefix(p.src[p.pos:], "'''") {
+			return p.parseMultilineString("'''", false)
+		}
+		return p.parseLiteralString()
+	case '[':
+		return p.parseArray()
+	case '{':
+		return p.parseInlineTable()
+	}
+
+	// Numbers, booleans and dates run to the next delimiter
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(" \t\n#,]}", rune(p.peek())) {
+		p.pos++
+	}
+	// A date and time may be separated by a space
+	if !p.eof() && p.peek() == ' ' && p.pos-start == 10 && strings.Count(p.src[start:p.pos], "-") == 2 &&
+		p.pos+1 < len(p.src) && p.src[p.pos+1] >= '0' && p.src[p.pos+1] <= '9' {
+		p.pos++
+		for !p.eof() && !strings.ContainsRune(" \t\n#,]}", rune(p.peek())) {
+			p.pos++
+		}
+	}
+	raw := p.src[start:p.pos]
+
+	switch raw {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "":
+		return nil, p.errorf("expected a value")
+	}
+
+	// Numbers and dates become variable values, so they are kept as
+	// written apart from digit separators
+	switch c := raw[0]; {
+	case c >= '0' && c <= '9', c == '+', c == '-', raw == "inf", raw == "nan":
+		return strings.ReplaceAll(raw, "_", ""), nil
+	}
+	return nil, p.errorf("invalid value %q", raw)
+}
+
+// parseBasicString reads a "string" with escapes
+func (p *tomlParser) parseBasicString() (string, error) {
+	p.pos++
+	var buf strings.Builder
+	for !p.eof() {
+		b := p.peek()
+		switch b {
+		case '"':
+			p.pos++
+			return buf.String(), nil
+		case '\n':
+			return "", p.errorf("unterminated string")
+		case '\\':
+			if err := p.parseEscape(&buf); err != nil {
+				return "", err
+			}
+		default:
+			buf.WriteByte(b)
+			p.pos++
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// parseEscape reads a backslash escape into buf
+func (p *tomlParser) parseEscape(buf *strings.Builder) error {
+	p.pos++
+	if p.eof() {
+		return p.errorf("unterminated string")
+	}
+	e := p.peek()
+	p.pos++
+	switch e {
+	case 'n':
+		buf.WriteByte('\n')
+	case 't':
+		buf.WriteByte('\t')
+	case 'r':
+		buf.WriteByte('\r')
+	case 'b':
+		buf.WriteByte('\b')
+	case 'f':
+		buf.WriteByte('\f')
+	case 'e':
+		buf.WriteByte(0x1b)
+	case '"', '\\':
+		buf.WriteByte(e)
+	case 'u', 'U':
+		n := 4
+		if e == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.src) {
+			return p.errorf("invalid unicode escape")
+		}
+		code, err := strconv.ParseUint(p.src[p.pos:p.pos+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return p.errorf("invalid unicode escape")
+		}
+		buf.WriteRune(rune(code))
+		p.pos += n
+	default:
+		return p.errorf("invalid escape \\%c", e)
+	}
+	return nil
+}
+
+// parseLiteralString reads a 'string' without escapes
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.pos++
+	end := strings.IndexAny(p.src[p.pos:], "'\n")
+	if end < 0 || p.src[p.pos+end] != '\'' {
+		return "", p.errorf("unterminated string")
+	}
+	s := p.src[p.pos : p.pos+end]
+	p.pos += end + 1
+	return s, nil
+}
+
+// parseMultilineString reads a triple-quoted string. A newline right after the
+// opening quotes is dropped.
+func (p *tomlParser) parseMultilineString(delim string, escapes bool) (string, error) {
+	p.pos += len(delim)
+	if strings.HasPrefix(p.src[p.pos:], "\n") {
+		p.pos++
+		p.line++
+	}
+
+	var buf strings.Builder
+	for !p.eof() {
+		if strings.HasPrefix(p.src[p.pos:], delim) {
+			p.pos += len(delim)
+			return buf.String(), nil
+		}
+		b := p.peek()
+		if escapes && b == '\\' {
+			// A backslash at the end of a line trims the following whitespace
+			rest := strings.TrimLeft(p.src[p.pos+1:], " \t")
+			if strings.HasPrefix(rest, "\n") {
+				p.pos = len(p.src) - len(rest)
+				p.skipBlank()
+				continue
+			}
+			if err := p.parseEscape(&buf); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if b == '\n' {
+			p.line++
+		}
+		buf.WriteByte(b)
+		p.pos++
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// parseArray reads [a, b, ...], which may span lines
+func (p *tomlParser) parseArray() ([]any, error) {
+	p.pos++
+	items := []any{}
+	for {
+		p.skipBlankAndComments()
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return items, nil
+		}
+
+		item, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		p.skipBlankAndComments()
+		if !p.eof() && p.peek() == ',' {
+			p.pos++
+		} else if p.eof() || p.peek() != ']' {
+			return nil, p.errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+// parseInlineTable reads { key = value, ... } on one line
+func (p *tomlParser) parseInlineTable() (map[string]any, error) {
+	p.pos++
+	table := make(map[string]any)
+	if p.consume('}') {
+		return table, nil
+	}
+	for {
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+		if p.consume('}') {
+			return table, nil
+		}
+		if !p.consume(',') {
+			return nil, p.errorf("expected ',' or '}' in inline table")
+		}
+	}
+}
+
+// encodeTOML writes a tree of maps and string values as TOML
+func encodeTOML(tree map[string]any) []byte {
+	var buf strings.Builder
+	writeTOMLTable(&buf, nil, tree)
+	return []byte(buf.String())
+}
+
+// writeTOMLTable writes a table's values, then its sub-tables
+func writeTOMLTable(buf *strings.Builder, path []string, table map[string]any) {
+	keys := make([]string, 0, len(table))
+	for k := range table {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var tables []string
+	for _, k := range keys {
+		if _, ok := table[k].(map[string]any); ok {
+			tables = append(tables, k)
+			continue
+		}
+		fmt.Fprintf(buf, "%s = %s\n", tomlKey(k), tomlString(fmt.Sprint(table[k])))
+	}
+
+	for _, k := range tables {
+		sub := append(append([]string{}, path...), k)
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		quoted := make([]string, len(sub))
+		for i, part := range sub {
+			quoted[i] = tomlKey(part)
+		}
+		fmt.Fprintf(buf, "[%s]\n", strings.Join(quoted, "."))
+		writeTOMLTable(buf, sub, table[k].(map[string]any))
+	}
+}
+
+// tomlKey quotes a key unless it is a valid bare key
+func tomlKey(k string) string {
+	if k == "" {
+		return `""`
+	}
+	for i := 0; i < len(k); i++ {
+		if !isBareKeyByte(k[i]) {
+			return tomlString(k)
+		}
+	}
+	return k
+}
+
+// tomlString quotes s as a basic string
+func tomlString(s string) string {
+	var buf strings.Builder
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&buf, `\u%04X`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
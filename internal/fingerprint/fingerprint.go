@@ -0,0 +1,184 @@
+// Package fingerprint keeps the store's index of the secrets written to it:
+// a hash of each file, of the recipients it was encrypted for, and of the
+// team they were worked out from. While a file still has the recorded hash,
+// its content and recipients are known without decrypting it, so saving the
+// same content for the same recipients, or re-encrypting it for a team that
+// hasn't changed, can be skipped instead of producing a commit that changes
+// nothing but the ciphertext.
+//
+// The index is committed with the store, one entry per line, so every clone
+// shares it, and git merges it line by line as a union. It holds no hash of
+// any plaintext. A member who edits it can only make passbook skip
+// re-encrypting a file, which leaves the file readable by whoever it was
+// already encrypted for; it can't widen who reads anything.
+package fingerprint
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// File is the index's name at the root of the store
+const File = ".passbook-fingerprints"
+
+// mergeAttribute has git merge the index as a union of both sides' lines,
+// so members writing different secrets never conflict over it
+const mergeAttribute = "/" + File + " merge=union"
+
+// Index maps paths relative to the store to the fingerprints of the files
+// written there
+type Index struct {
+	storePath string
+	entries   map[string][]entry
+}
+
+// entry is the fingerprint of one file
+type entry struct {
+	Path       string `json:"path"`
+	File       string `json:"file"`
+	Recipients string `json:"recipients"`
+	Team       string `json:"team,omitempty"`
+}
+
+// Load reads the store's index. A store without one, or whose index can't
+// be read, starts with an empty index. A union merge can leave several
+// entries for a path; the one matching the file on disk is the one used.
+func Load(storePath string) *Index {
+	x := &Index{storePath: storePath, entries: make(map[string][]entry)}
+	data, err := os.ReadFile(filepath.Join(storePath, File))
+	if err != nil {
+		return x
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var e entry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil && e.Path != "" {
+			x.entries[e.Path] = append(x.entries[e.Path], e)
+		}
+	}
+	return x
+}
+
+// Matches reports whether the file with hash fileHash is the one recorded
+// at rel, and so was encrypted for exactly recipients
+func (x *Index) Matches(rel, fileHash string, recipients []string) bool {
+	want := Recipients(recipients)
+	for _, e := range x.entries[rel] {
+		if e.File == fileHash && e.Recipients == want {
+			return true
+		}
+	}
+	return false
+}
+
+// Current reports whether the file with hash fileHash is the one recorded
+// at rel for the team fingerprinted by team, so its recipients haven't
+// changed since it was written and it needn't be decrypted to find out
+func (x *Index) Current(rel, fileHash, team string) bool {
+	if team == "" {
+		return false
+	}
+	for _, e := range x.entries[rel] {
+		if e.File == fileHash && e.Team == team {
+			return true
+		}
+	}
+	return false
+}
+
+// Record notes that the file with hash fileHash was written to rel,
+// encrypted for recipients worked out from the team fingerprinted by team
+func (x *Index) Record(rel, fileHash string, recipients []string, team string) {
+	x.entries[rel] = []entry{{Path: rel, File: fileHash, Recipients: Recipients(recipients), Team: team}}
+}
+
+// Save writes the index back, sorted by path and without files that no
+// longer exist, and makes sure git merges it as a union
+func (x *Index) Save() error {
+	paths := make([]string, 0, len(x.entries))
+	for rel := range x.entries {
+		if _, err := os.Stat(filepath.Join(x.storePath, filepath.FromSlash(rel))); err == nil {
+			paths = append(paths, rel)
+		}
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	for _, rel := range paths {
+		for _, e := range x.entries[rel] {
+			line, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			buf.Write(line)
+			buf.WriteByte('\n')
+		}
+	}
+	if err := os.WriteFile(filepath.Join(x.storePath, File), buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return x.ensureUnionMerge()
+}
+
+// ensureUnionMerge adds the index's merge attribute to the clone's
+// .git/info/attributes. It does nothing for a store that isn't a git
+// repository.
+func (x *Index) ensureUnionMerge() error {
+	gitDir := filepath.Join(x.storePath, ".git")
+	if info, err := os.Stat(gitDir); errors.Is(err, os.ErrNotExist) || (err == nil && !info.IsDir()) {
+		return nil
+	}
+	path := filepath.Join(gitDir, "info", "attributes")
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if slices.Contains(strings.Split(string(existing), "\n"), mergeAttribute) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if len(existing) > 0 && !bytes.HasSuffix(existing, []byte("\n")) {
+		existing = append(existing, '\n')
+	}
+	return os.WriteFile(path, append(existing, mergeAttribute+"\n"...), 0600)
+}
+
+// Recipients fingerprints a set of recipient keys, whatever their order
+func Recipients(keys []string) string {
+	sorted := slices.Clone(keys)
+	sort.Strings(sorted)
+	return Hash([]byte(strings.Join(slices.Compact(sorted), "\n")))
+}
+
+// Hash returns the hex SHA-256 of a file's contents
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// HashFile is Hash for a file on disk, read a piece at a time
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
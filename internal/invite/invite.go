@@ -0,0 +1,432 @@
+package invite
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/models"
+)
+
+const (
+	// InvitesFile stores outstanding invites in the store
+	InvitesFile = ".passbook-invites"
+
+	// TokenPrefix identifies invite tokens
+	TokenPrefix = "pbi_"
+
+	// ResponsePrefix identifies invite responses
+	ResponsePrefix = "pbr_"
+
+	// TTL is how long an invite is valid
+	TTL = 7 * 24 * time.Hour
+
+	// secretLength is the size of the invite secret in bytes
+	secretLength = 32
+)
+
+var (
+	// ErrInvalidToken is returned when an invite token or response is malformed
+	ErrInvalidToken = errors.New("invalid invite token")
+	// ErrInviteNotFound is returned when no invite exists for an email
+	ErrInviteNotFound = errors.New("invite not found")
+	// ErrInviteExpired is returned when an invite has expired
+	ErrInviteExpired = errors.New("invite has expired")
+	// ErrNoResponse is returned when the invitee hasn't joined yet
+	ErrNoResponse = errors.New("invitee has not joined yet")
+	// ErrProofMismatch is returned when a response wasn't produced with the invite secret
+	ErrProofMismatch = errors.New("invite response proof does not match")
+	// ErrTermsChanged is returned when an invite's roles or access no longer
+	// match what its issuer signed
+	ErrTermsChanged = errors.New("invite roles or access were changed after it was issued; create a new invite")
+)
+
+// Invite is the bootstrap data handed to the invitee
+type Invite struct {
+	ID             string        `yaml:"id"`
+	Email          string        `yaml:"email"`
+	Roles          []models.Role `yaml:"roles"`
+	RepoURL        string        `yaml:"repo_url"`
+	Org            string        `yaml:"org,omitempty"`
+	AllowedDomain  string        `yaml:"allowed_domain,omitempty"`
+	AllowedDomains []string      `yaml:"allowed_domains,omitempty"`
+	Secret         string        `yaml:"secret"` // Base64 encoded random bytes
+	IssuedBy       string        `yaml:"issued_by"`
+	ExpiresAt      time.Time     `yaml:"expires_at"`
+
+	// External collaborators join outside the allowed domain, with access
+	// ending at AccessExpiresAt
+	External        bool      `yaml:"external,omitempty"`
+	AccessExpiresAt time.Time `yaml:"access_expires_at,omitempty"`
+}
+
+// Response is what the invitee sends back after generating keys
+type Response struct {
+	ID        string `yaml:"id"`
+	Email     string `yaml:"email"`
+	PublicKey string `yaml:"public_key"`
+	Proof     string `yaml:"proof"` // HMAC of email, public key and terms under the invite secret
+}
+
+// Record is an outstanding invite as stored in the repository.
+// The secret is sealed to the issuing admin so only they can check proofs.
+type Record struct {
+	ID           string        `yaml:"id"`
+	Email        string        `yaml:"email"`
+	Roles        []models.Role `yaml:"roles"`
+	IssuedBy     string        `yaml:"issued_by"`
+	CreatedAt    time.Time     `yaml:"created_at"`
+	ExpiresAt    time.Time     `yaml:"expires_at"`
+	SealedSecret string        `yaml:"sealed_secret"` // Base64 age ciphertext
+	PublicKey    string        `yaml:"public_key,omitempty"`
+	Proof        string        `yaml:"proof,omitempty"`
+	RespondedAt  time.Time     `yaml:"responded_at,omitempty"`
+
+	External        bool      `yaml:"external,omitempty"`
+	AccessExpiresAt time.Time `yaml:"access_expires_at,omitempty"`
+
+	// Signature by the issuer over the invite's terms, so the invitee, who
+	// can push to the store, can't raise their roles or lift their expiry
+	Signature string `yaml:"signature,omitempty"`
+}
+
+// Records holds all outstanding invites
+type Records struct {
+	Invites []Record `yaml:"invites"`
+}
+
+// Manager creates and accepts invites in a store
+type Manager struct {
+	storePath string
+}
+
+// NewManager creates a new invite manager
+func NewManager(storePath string) *Manager {
+	return &Manager{storePath: storePath}
+}
+
+// Create records a new invite and returns the token to give the invitee.
+// The record is signed with the issuer's signing key when it has one.
+func (m *Manager) Create(inv *Invite, issuer *age.Age) (string, error) {
+	secret := make([]byte, secretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate invite secret: %w", err)
+	}
+	defer age.ZeroBytes(secret)
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate invite id: %w", err)
+	}
+
+	inv.ID = hex.EncodeToString(id)
+	inv.Secret = base64.StdEncoding.EncodeToString(secret)
+	if inv.ExpiresAt.IsZero() {
+		inv.ExpiresAt = time.Now().Add(TTL)
+	}
+
+	// Seal the secret to the issuer so proofs can be checked at accept time
+	crypto := age.NewWithoutIdentity()
+	sealed, err := crypto.Encrypt(context.Background(), secret, []string{issuer.PublicKey()})
+	if err != nil {
+		return "", fmt.Errorf("failed to seal invite secret: %w", err)
+	}
+
+	record := Record{
+		ID:           inv.ID,
+		Email:        inv.Email,
+		Roles:        inv.Roles,
+		IssuedBy:     inv.IssuedBy,
+		CreatedAt:    time.Now(),
+		ExpiresAt:    inv.ExpiresAt,
+		SealedSecret: base64.StdEncoding.EncodeToString(sealed),
+
+		External:        inv.External,
+		AccessExpiresAt: inv.AccessExpiresAt,
+	}
+	if key, err := issuer.SigningKey(); err == nil {
+		record.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, record.signedTerms()))
+	} else if !errors.Is(err, age.ErrNoSigningKey) {
+		return "", fmt.Errorf("failed to sign invite: %w", err)
+	}
+	if err := m.saveRecord(record); err != nil {
+		return "", err
+	}
+
+	return encode(TokenPrefix, inv)
+}
+
+// Respond records the invitee's public key and proof in the store
+func (m *Manager) Respond(inv *Invite, publicKey string) (*Response, error) {
+	if time.Now().After(inv.ExpiresAt) {
+		return nil, ErrInviteExpired
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(inv.Secret)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	defer age.ZeroBytes(secret)
+
+	resp := &Response{
+		ID:        inv.ID,
+		Email:     inv.Email,
+		PublicKey: publicKey,
+		Proof:     proof(secret, inv.Email, publicKey, terms(inv.Roles, inv.External, inv.AccessExpiresAt)),
+	}
+
+	if err := m.ApplyResponse(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ApplyResponse stores a response (e.g. one sent back out of band) on its record
+func (m *Manager) ApplyResponse(resp *Response) error {
+	records, err := m.load()
+	if err != nil {
+		return err
+	}
+
+	for i, r := range records.Invites {
+		if r.ID == resp.ID && r.Email == resp.Email {
+			records.Invites[i].PublicKey = resp.PublicKey
+			records.Invites[i].Proof = resp.Proof
+			records.Invites[i].RespondedAt = time.Now()
+			return m.save(records)
+		}
+	}
+	return ErrInviteNotFound
+}
+
+// Verify checks a responded invite's proof using the issuer's identity, and
+// that its roles and access are still the ones the issuer signed. Issuers
+// whose key is on a token have no signing key, so their invites rely on the
+// proof alone.
+func (m *Manager) Verify(email string, issuer *age.Age) (*Record, error) {
+	record, err := m.Get(email)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return nil, ErrInviteExpired
+	}
+	if record.PublicKey == "" || record.Proof == "" {
+		return nil, ErrNoResponse
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(record.SealedSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sealed secret: %w", err)
+	}
+	secret, err := issuer.Decrypt(context.Background(), sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unseal invite secret (only %s can accept this invite): %w", record.IssuedBy, err)
+	}
+	defer age.ZeroBytes(secret)
+
+	if key, err := issuer.SigningKey(); err == nil {
+		publicKey := age.EncodeSigningKey(key.Public().(ed25519.PublicKey))
+		if record.Signature == "" || age.VerifySignature(publicKey, record.signedTerms(), record.Signature) != nil {
+			return nil, ErrTermsChanged
+		}
+	}
+
+	expected := proof(secret, record.Email, record.PublicKey, terms(record.Roles, record.External, record.AccessExpiresAt))
+	if !hmac.Equal([]byte(expected), []byte(record.Proof)) {
+		return nil, ErrProofMismatch
+	}
+
+	return record, nil
+}
+
+// Get returns the invite record for an email
+func (m *Manager) Get(email string) (*Record, error) {
+	records, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records.Invites {
+		if r.Email == email {
+			return &r, nil
+		}
+	}
+	return nil, ErrInviteNotFound
+}
+
+// List returns all invite records
+func (m *Manager) List() ([]Record, error) {
+	records, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+	return records.Invites, nil
+}
+
+// Remove deletes the invite record for an email
+func (m *Manager) Remove(email string) error {
+	records, err := m.load()
+	if err != nil {
+		return err
+	}
+
+	var kept []Record
+	for _, r := range records.Invites {
+		if r.Email != email {
+			kept = append(kept, r)
+		}
+	}
+	records.Invites = kept
+	return m.save(records)
+}
+
+// DecodeToken parses an invite token, or a file containing one
+func DecodeToken(token string) (*Invite, error) {
+	token = readTokenFile(token)
+	var inv Invite
+	if err := decode(TokenPrefix, token, &inv); err != nil {
+		return nil, err
+	}
+	if inv.ID == "" || inv.Email == "" || inv.RepoURL == "" || inv.Secret == "" {
+		return nil, ErrInvalidToken
+	}
+	return &inv, nil
+}
+
+// EncodeResponse serializes a response for sending back out of band
+func EncodeResponse(resp *Response) (string, error) {
+	return encode(ResponsePrefix, resp)
+}
+
+// DecodeResponse parses a response token
+func DecodeResponse(token string) (*Response, error) {
+	var resp Response
+	if err := decode(ResponsePrefix, readTokenFile(token), &resp); err != nil {
+		return nil, err
+	}
+	if resp.ID == "" || resp.Email == "" || resp.PublicKey == "" {
+		return nil, ErrInvalidToken
+	}
+	return &resp, nil
+}
+
+// proof computes the HMAC binding an email to a public key and the terms
+// the invitee was shown under the invite secret
+func proof(secret []byte, email, publicKey, terms string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(email + "\n" + publicKey + "\n" + terms))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// terms serializes the access an invite grants
+func terms(roles []models.Role, external bool, accessExpiresAt time.Time) string {
+	names := make([]string, len(roles))
+	for i, r := range roles {
+		names[i] = string(r)
+	}
+	expires := int64(0)
+	if !accessExpiresAt.IsZero() {
+		expires = accessExpiresAt.Unix()
+	}
+	return fmt.Sprintf("roles=%s\nexternal=%t\naccess_expires=%d", strings.Join(names, ","), external, expires)
+}
+
+// signedTerms is what the issuer signs: the invite's identity, terms and
+// sealed secret
+func (r *Record) signedTerms() []byte {
+	return []byte(fmt.Sprintf("passbook invite v1\nid=%s\nemail=%s\n%s\nexpires=%d\nissued_by=%s\nsealed=%s",
+		r.ID, r.Email, terms(r.Roles, r.External, r.AccessExpiresAt), r.ExpiresAt.Unix(), r.IssuedBy, r.SealedSecret))
+}
+
+// encode serializes v as a prefixed base64 token
+func encode(prefix string, v interface{}) (string, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decode parses a prefixed base64 token into v
+func decode(prefix, token string, v interface{}) error {
+	token = strings.TrimSpace(token)
+	if !strings.HasPrefix(token, prefix) {
+		return ErrInvalidToken
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(token, prefix))
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if err := yaml.Unmarshal(data, v); err != nil {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// readTokenFile returns the contents of path if it names a file, else path itself
+func readTokenFile(token string) string {
+	if strings.HasPrefix(token, TokenPrefix) || strings.HasPrefix(token, ResponsePrefix) {
+		return token
+	}
+	data, err := os.ReadFile(token)
+	if err != nil {
+		return token
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// load loads the invites file
+func (m *Manager) load() (*Records, error) {
+	data, err := os.ReadFile(filepath.Join(m.storePath, InvitesFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Records{}, nil
+		}
+		return nil, err
+	}
+
+	var records Records
+	if err := yaml.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return &records, nil
+}
+
+// save saves the invites file
+func (m *Manager) save(records *Records) error {
+	data, err := yaml.Marshal(records)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.storePath, InvitesFile), data, 0600)
+}
+
+// saveRecord adds a record, replacing any existing invite for the same email
+func (m *Manager) saveRecord(record Record) error {
+	records, err := m.load()
+	if err != nil {
+		return err
+	}
+
+	var kept []Record
+	for _, r := range records.Invites {
+		if r.Email != record.Email {
+			kept = append(kept, r)
+		}
+	}
+	records.Invites = append(kept, record)
+	return m.save(records)
+}
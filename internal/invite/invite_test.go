@@ -0,0 +1,146 @@
+package invite
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/models"
+)
+
+// newIssuer returns an admin identity that can issue invites
+func newIssuer(t *testing.T) *age.Age {
+	t.Helper()
+	secretKey, _, err := age.GenerateEphemeralIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := age.NewFromSecretKey(secretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return issuer
+}
+
+// join has the invitee redeem token with a fresh key, returning its public key
+func join(t *testing.T, m *Manager, token string) string {
+	t.Helper()
+	inv, err := DecodeToken(token)
+	if err != nil {
+		t.Fatalf("DecodeToken: %v", err)
+	}
+	_, publicKey, err := age.GenerateEphemeralIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Respond(inv, publicKey); err != nil {
+		t.Fatalf("Respond: %v", err)
+	}
+	return publicKey
+}
+
+func TestInviteRoundTrip(t *testing.T) {
+	m := NewManager(t.TempDir())
+	issuer := newIssuer(t)
+
+	token, err := m.Create(&Invite{Email: "new@example.com", Roles: []models.Role{models.RoleDev}, IssuedBy: "admin@example.com", RepoURL: "git@example.com:team/store.git"}, issuer)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := m.Verify("new@example.com", issuer); !errors.Is(err, ErrNoResponse) {
+		t.Errorf("Verify before joining = %v, want ErrNoResponse", err)
+	}
+
+	publicKey := join(t, m, token)
+	record, err := m.Verify("new@example.com", issuer)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if record.PublicKey != publicKey {
+		t.Errorf("public key = %s, want %s", record.PublicKey, publicKey)
+	}
+
+	// Only the issuer holds the invite secret
+	if _, err := m.Verify("new@example.com", newIssuer(t)); err == nil {
+		t.Error("another admin verified the invite")
+	}
+}
+
+func TestVerifyRejectsChangedTerms(t *testing.T) {
+	m := NewManager(t.TempDir())
+	issuer := newIssuer(t)
+	token, err := m.Create(&Invite{Email: "guest@partner.com", Roles: []models.Role{models.RoleDev}, IssuedBy: "admin@example.com", RepoURL: "git@example.com:team/store.git",
+		External: true, AccessExpiresAt: time.Now().Add(24 * time.Hour)}, issuer)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	join(t, m, token)
+
+	// The invitee can push to the store, so they could edit their record
+	records, err := m.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	records.Invites[0].Roles = []models.Role{models.RoleAdmin}
+	records.Invites[0].External = false
+	if err := m.save(records); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Verify("guest@partner.com", issuer); !errors.Is(err, ErrTermsChanged) {
+		t.Errorf("Verify = %v, want ErrTermsChanged", err)
+	}
+}
+
+func TestVerifyRejectsForeignResponse(t *testing.T) {
+	m := NewManager(t.TempDir())
+	issuer := newIssuer(t)
+	if _, err := m.Create(&Invite{Email: "new@example.com", Roles: []models.Role{models.RoleDev}, IssuedBy: "admin@example.com", RepoURL: "git@example.com:team/store.git"}, issuer); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	record, err := m.Get("new@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A response made without the invite secret doesn't prove anything
+	_, publicKey, err := age.GenerateEphemeralIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ApplyResponse(&Response{ID: record.ID, Email: record.Email, PublicKey: publicKey, Proof: "forged"}); err != nil {
+		t.Fatalf("ApplyResponse: %v", err)
+	}
+	if _, err := m.Verify("new@example.com", issuer); !errors.Is(err, ErrProofMismatch) {
+		t.Errorf("Verify = %v, want ErrProofMismatch", err)
+	}
+}
+
+func TestExpiredInvites(t *testing.T) {
+	m := NewManager(t.TempDir())
+	issuer := newIssuer(t)
+	token, err := m.Create(&Invite{Email: "late@example.com", Roles: []models.Role{models.RoleDev}, IssuedBy: "admin@example.com", RepoURL: "git@example.com:team/store.git",
+		ExpiresAt: time.Now().Add(-time.Minute)}, issuer)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	inv, err := DecodeToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Respond(inv, "age1unused"); !errors.Is(err, ErrInviteExpired) {
+		t.Errorf("Respond = %v, want ErrInviteExpired", err)
+	}
+	if _, err := m.Verify("late@example.com", issuer); !errors.Is(err, ErrInviteExpired) {
+		t.Errorf("Verify = %v, want ErrInviteExpired", err)
+	}
+}
+
+func TestDecodeTokenRejectsGarbage(t *testing.T) {
+	for _, token := range []string{"", "pbi_", "pbi_!!!", "pbr_" + "AAAA", "not a token"} {
+		if _, err := DecodeToken(token); err == nil {
+			t.Errorf("DecodeToken(%q) succeeded", token)
+		}
+	}
+}
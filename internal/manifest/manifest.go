@@ -0,0 +1,248 @@
+// Package manifest records a signed snapshot of the store: the hash of every
+// file and the recipients each secret is meant to be encrypted for. Comparing
+// the working tree against it reveals changes that bypassed the CLI, such as
+// a file edited or swapped directly in git.
+package manifest
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"passbook/internal/backend/crypto/age"
+)
+
+const (
+	// File is the manifest's name in the store
+	File = ".passbook-manifest"
+
+	// version is the manifest format version
+	version = 1
+)
+
+// Covered lists the store files and directories the manifest covers.
+// Everything else, such as the audit log, changes too often to pin.
+var Covered = []string{
+	".passbook-users",
+	".passbook-users.age",
+	".passbook-recipients",
+	".passbook-config",
+	".passbook-holds",
+	"credentials",
+	"projects",
+	"archive",
+	"notes",
+	"ssh",
+	"certs",
+}
+
+// ErrNoManifest is returned when the store has no manifest yet
+var ErrNoManifest = errors.New("no manifest")
+
+// Manifest is a signed snapshot of the store
+type Manifest struct {
+	Version   int       `yaml:"version"`
+	UpdatedBy string    `yaml:"updated_by"`
+	UpdatedAt time.Time `yaml:"updated_at"`
+
+	// Files maps paths relative to the store to their entries
+	Files map[string]Entry `yaml:"files"`
+
+	// RecipientSets holds each distinct recipient list once, keyed by a
+	// short hash that entries refer to
+	RecipientSets map[string][]string `yaml:"recipient_sets,omitempty"`
+
+	SignerKey string `yaml:"signer_key,omitempty"`
+	Signature string `yaml:"signature,omitempty"`
+}
+
+// Entry describes one file
+type Entry struct {
+	Hash       string `yaml:"hash"`
+	Recipients string `yaml:"recipients,omitempty"`
+}
+
+// Build snapshots the store. recipients returns the keys a secret at a
+// relative path should be encrypted for, or nil for files that aren't
+// secrets.
+func Build(storePath string, recipients func(path string, data []byte) []string) (*Manifest, error) {
+	m := &Manifest{
+		Version:       version,
+		Files:         make(map[string]Entry),
+		RecipientSets: make(map[string][]string),
+	}
+
+	for _, covered := range Covered {
+		root := filepath.Join(storePath, covered)
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() || d.Name() == ".gitkeep" {
+				return nil
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(storePath, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+
+			sum := sha256.Sum256(data)
+			entry := Entry{Hash: hex.EncodeToString(sum[:])}
+			if keys := recipients(rel, data); len(keys) > 0 {
+				entry.Recipients = m.addRecipientSet(keys)
+			}
+			m.Files[rel] = entry
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash %s: %w", covered, err)
+		}
+	}
+
+	return m, nil
+}
+
+// addRecipientSet stores a recipient list once and returns its key
+func (m *Manifest) addRecipientSet(keys []string) string {
+	sorted := slices.Clone(keys)
+	sort.Strings(sorted)
+	sorted = slices.Compact(sorted)
+
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	id := hex.EncodeToString(sum[:6])
+	m.RecipientSets[id] = sorted
+	return id
+}
+
+// Sign records the signer and signs the manifest's contents
+func (m *Manifest) Sign(by string, key ed25519.PrivateKey) error {
+	m.UpdatedBy = by
+	m.UpdatedAt = time.Now().UTC()
+	m.SignerKey = age.EncodeSigningKey(key.Public().(ed25519.PublicKey))
+
+	data, err := m.signedBytes()
+	if err != nil {
+		return err
+	}
+	m.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, data))
+	return nil
+}
+
+// VerifySignature checks that the manifest was signed by SignerKey. Whether
+// that key belongs to someone trusted is up to the caller.
+func (m *Manifest) VerifySignature() error {
+	if m.Signature == "" {
+		return errors.New("manifest is not signed")
+	}
+	data, err := m.signedBytes()
+	if err != nil {
+		return err
+	}
+	return age.VerifySignature(m.SignerKey, data, m.Signature)
+}
+
+// signedBytes is the manifest as it is signed: everything but the signature
+func (m *Manifest) signedBytes() ([]byte, error) {
+	unsigned := *m
+	unsigned.Signature = ""
+	return yaml.Marshal(&unsigned)
+}
+
+// Change is one difference between a manifest and the store
+type Change struct {
+	Path string
+	Kind string // modified, missing, added or recipients
+}
+
+// Compare lists how current differs from the manifest, sorted by path
+func (m *Manifest) Compare(current *Manifest) []Change {
+	var changes []Change
+	for path, want := range m.Files {
+		got, ok := current.Files[path]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Path: path, Kind: "missing"})
+		case got.Hash != want.Hash:
+			changes = append(changes, Change{Path: path, Kind: "modified"})
+		case !slices.Equal(m.RecipientSets[want.Recipients], current.RecipientSets[got.Recipients]):
+			changes = append(changes, Change{Path: path, Kind: "recipients"})
+		}
+	}
+	for path := range current.Files {
+		if _, ok := m.Files[path]; !ok {
+			changes = append(changes, Change{Path: path, Kind: "added"})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// Load reads the store's manifest
+func Load(storePath string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(storePath, File))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoManifest
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes a manifest, such as one read from an older commit
+func Parse(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if m.Version != version {
+		return nil, fmt.Errorf("unsupported manifest version %d", m.Version)
+	}
+	return &m, nil
+}
+
+// RecipientsOf returns the keys the file at a relative path was encrypted
+// for, or nil if the manifest doesn't record any
+func (m *Manifest) RecipientsOf(path string) []string {
+	entry, ok := m.Files[path]
+	if !ok || entry.Recipients == "" {
+		return nil
+	}
+	return m.RecipientSets[entry.Recipients]
+}
+
+// Save writes the manifest to the store
+func (m *Manifest) Save(storePath string) error {
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(storePath, File), data, 0600); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
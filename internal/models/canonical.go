@@ -0,0 +1,57 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MarshalCanonical serializes v as YAML in a canonical form, so the same
+// content always gives the same bytes whatever the field order of the
+// structs or the version of the yaml library: the keys of every mapping are
+// sorted, and timestamps are written in UTC to the second. Lists keep their
+// order, which is part of the content.
+func MarshalCanonical(v any) ([]byte, error) {
+	var node yaml.Node
+	if err := node.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := canonicalize(&node); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(&node)
+}
+
+// canonicalize rewrites node and its children in canonical form
+func canonicalize(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		pairs := make([][2]*yaml.Node, 0, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			pairs = append(pairs, [2]*yaml.Node{node.Content[i], node.Content[i+1]})
+		}
+		sort.SliceStable(pairs, func(i, j int) bool {
+			return pairs[i][0].Value < pairs[j][0].Value
+		})
+		node.Content = node.Content[:0]
+		for _, p := range pairs {
+			node.Content = append(node.Content, p[0], p[1])
+		}
+	case yaml.ScalarNode:
+		if node.Tag == "!!timestamp" {
+			t, err := time.Parse(time.RFC3339Nano, node.Value)
+			if err != nil {
+				return fmt.Errorf("invalid timestamp %q: %w", node.Value, err)
+			}
+			node.Value = t.UTC().Truncate(time.Second).Format(time.RFC3339)
+		}
+	}
+	for _, child := range node.Content {
+		if err := canonicalize(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// CertificateVersion is the certificate format written by this version
+const CertificateVersion = 1
+
+// Certificate is an X.509 certificate, with its chain and optionally its
+// private key. The parsed fields are filled in on import.
+type Certificate struct {
+	// Format version
+	Version int `json:"version,omitempty" yaml:"version,omitempty"`
+
+	// Unique identifier (auto-generated)
+	ID string `json:"id" yaml:"id"`
+
+	// Certificate name (e.g., "api.example.com")
+	Name string `json:"name" yaml:"name"`
+
+	// Parsed from the leaf certificate
+	Subject     string    `json:"subject" yaml:"subject"`
+	Issuer      string    `json:"issuer" yaml:"issuer"`
+	DNSNames    []string  `json:"dns_names,omitempty" yaml:"dns_names,omitempty"`
+	Serial      string    `json:"serial" yaml:"serial"`
+	Fingerprint string    `json:"fingerprint" yaml:"fingerprint"` // SHA-256 of the DER leaf
+	NotBefore   time.Time `json:"not_before" yaml:"not_before"`
+	NotAfter    time.Time `json:"not_after" yaml:"not_after"`
+
+	// PEM certificate chain, leaf first
+	Chain string `json:"chain" yaml:"chain"`
+
+	// PEM private key, if stored (stored encrypted)
+	PrivateKey string `json:"private_key,omitempty" yaml:"private_key,omitempty"`
+
+	// Who created this certificate
+	CreatedBy string `json:"created_by" yaml:"created_by"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" yaml:"updated_at"`
+}
+
+// Path returns the storage path for this certificate
+// Example: "certs/api.example.com"
+func (c *Certificate) Path() string {
+	return fmt.Sprintf("certs/%s", c.Name)
+}
+
+// FullPath returns the full storage path with extension
+func (c *Certificate) FullPath() string {
+	return c.Path() + ".age"
+}
+
+// ExpiresWithin reports whether the certificate expires within d of now,
+// or already has
+func (c *Certificate) ExpiresWithin(d time.Duration) bool {
+	return time.Until(c.NotAfter) < d
+}
@@ -5,8 +5,14 @@ import (
 	"time"
 )
 
+// CredentialVersion is the credential format written by this version
+const CredentialVersion = 1
+
 // Credential stores website login information
 type Credential struct {
+	// Format version, zero for credentials written before versioning
+	Version int `json:"version,omitempty" yaml:"version,omitempty"`
+
 	// Unique identifier (auto-generated)
 	ID string `json:"id" yaml:"id"`
 
@@ -22,6 +28,10 @@ type Credential struct {
 	// Password (stored encrypted)
 	Password string `json:"password" yaml:"password"`
 
+	// Previous passwords, newest first, kept for services that ask for the
+	// old one during a change and for rollbacks
+	PasswordHistory []PasswordVersion `json:"password_history,omitempty" yaml:"password_history,omitempty"`
+
 	// Optional URL (full login URL)
 	URL string `json:"url,omitempty" yaml:"url,omitempty"`
 
@@ -34,6 +44,9 @@ type Credential struct {
 	// Custom metadata key-value pairs
 	Metadata map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
 
+	// Sensitive credentials notify the store's webhook whenever they're read
+	Sensitive bool `json:"sensitive,omitempty" yaml:"sensitive,omitempty"`
+
 	// Per-secret access control (who can read/write this credential)
 	Permissions *SecretPermissions `json:"permissions,omitempty" yaml:"permissions,omitempty"`
 
@@ -45,6 +58,26 @@ type Credential struct {
 	UpdatedAt time.Time `json:"updated_at" yaml:"updated_at"`
 }
 
+// PasswordVersion is a password a credential used to have
+type PasswordVersion struct {
+	Password   string    `json:"password" yaml:"password"`
+	ReplacedBy string    `json:"replaced_by,omitempty" yaml:"replaced_by,omitempty"`
+	ReplacedAt time.Time `json:"replaced_at" yaml:"replaced_at"`
+}
+
+// RecordPasswordChange keeps old in the password history when it differs
+// from the current password, dropping the oldest entries beyond keep
+func (c *Credential) RecordPasswordChange(old, by string, keep int) {
+	if old == "" || old == c.Password || keep <= 0 {
+		return
+	}
+	version := PasswordVersion{Password: old, ReplacedBy: by, ReplacedAt: time.Now()}
+	c.PasswordHistory = append([]PasswordVersion{version}, c.PasswordHistory...)
+	if len(c.PasswordHistory) > keep {
+		c.PasswordHistory = c.PasswordHistory[:keep]
+	}
+}
+
 // GetPermissions returns permissions, initializing if nil
 func (c *Credential) GetPermissions() *SecretPermissions {
 	if c.Permissions == nil {
@@ -86,6 +119,7 @@ type CredentialSummary struct {
 	Website   string    `json:"website"`
 	Name      string    `json:"name"`
 	Username  string    `json:"username"`
+	URL       string    `json:"url,omitempty"`
 	Tags      []string  `json:"tags"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -97,6 +131,7 @@ func (c *Credential) ToSummary() CredentialSummary {
 		Website:   c.Website,
 		Name:      c.Name,
 		Username:  c.Username,
+		URL:       c.URL,
 		Tags:      c.Tags,
 		UpdatedAt: c.UpdatedAt,
 	}
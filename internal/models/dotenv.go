@@ -0,0 +1,280 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseDotEnv parses a .env file. It follows the format most dotenv
+// libraries accept:
+//
+//   - blank lines and lines starting with # are ignored
+//   - an optional "export " prefix is allowed before the key
+//   - unquoted values end at the line or at a " #" inline comment
+//   - 'single-quoted' values are literal and may span lines
+//   - "double-quoted" values may span lines and understand \n, \r, \t,
+//     \", \\ and \$ escapes
+//   - $VAR, ${VAR}, ${VAR:-default} and ${VAR-default} in unquoted and
+//     double-quoted values expand to variables defined earlier in the file.
+//     Unknown references are kept as written, so values that merely
+//     contain a '$' survive.
+//
+// Every parsed variable is marked secret.
+func ParseDotEnv(content string) ([]EnvVar, error) {
+	p := &dotenvParser{src: strings.ReplaceAll(content, "\r\n", "\n"), line: 1, defined: make(map[string]string)}
+
+	var vars []EnvVar
+	for {
+		p.skipSpace(true)
+		if p.eof() {
+			return vars, nil
+		}
+		if p.peek() == '#' {
+			p.skipLine()
+			continue
+		}
+
+		line := p.line
+		key, value, err := p.parseAssignment()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		p.defined[key] = value
+		vars = append(vars, EnvVar{
+			Key:      key,
+			Value:    value,
+			IsSecret: true, // Default to secret
+		})
+	}
+}
+
+// dotenvParser reads a .env file one assignment at a time
+type dotenvParser struct {
+	src     string
+	pos     int
+	line    int
+	defined map[string]string
+}
+
+func (p *dotenvParser) eof() bool  { return p.pos >= len(p.src) }
+func (p *dotenvParser) peek() byte { return p.src[p.pos] }
+
+// next consumes one byte, counting lines
+func (p *dotenvParser) next() byte {
+	b := p.src[p.pos]
+	p.pos++
+	if b == '\n' {
+		p.line++
+	}
+	return b
+}
+
+// skipSpace skips spaces and tabs, and newlines too if newlines is set
+func (p *dotenvParser) skipSpace(newlines bool) {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t':
+		case '\n':
+			if !newlines {
+				return
+			}
+		default:
+			return
+		}
+		p.next()
+	}
+}
+
+// skipLine consumes the rest of the line including the newline
+func (p *dotenvParser) skipLine() {
+	for !p.eof() && p.next() != '\n' {
+	}
+}
+
+// parseAssignment reads [export] KEY = VALUE and the rest of its line
+func (p *dotenvParser) parseAssignment() (string, string, error) {
+	key := p.readKey()
+	if key == "export" && !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.skipSpace(false)
+		key = p.readKey()
+	}
+	if key == "" {
+		return "", "", fmt.Errorf("expected KEY=VALUE")
+	}
+
+	p.skipSpace(false)
+	if p.eof() || p.peek() != '=' {
+		return "", "", fmt.Errorf("expected '=' after %s", key)
+	}
+	p.next()
+	p.skipSpace(false)
+
+	if p.eof() || p.peek() == '\n' {
+		return key, "", nil
+	}
+
+	var value string
+	var err error
+	switch p.peek() {
+	case '\'':
+		value, err = p.readSingleQuoted()
+	case '"':
+		value, err = p.readDoubleQuoted()
+	default:
+		return key, p.readUnquoted(), nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", key, err)
+	}
+
+	// Only a comment may follow a quoted value
+	p.skipSpace(false)
+	if !p.eof() && p.peek() != '\n' && p.peek() != '#' {
+		return "", "", fmt.Errorf("%s: unexpected text after closing quote", key)
+	}
+	p.skipLine()
+	return key, value, nil
+}
+
+// readKey reads a variable name
+func (p *dotenvParser) readKey() string {
+	start := p.pos
+	for !p.eof() && isKeyByte(p.peek()) {
+		p.next()
+	}
+	return p.src[start:p.pos]
+}
+
+// isKeyByte reports whether b may appear in a key. Dots and dashes are
+// accepted because some tools write them, though shells can't use them.
+func isKeyByte(b byte) bool {
+	return b == '_' || b == '.' || b == '-' ||
+		(b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9')
+}
+
+// readSingleQuoted reads a literal value up to the closing quote
+func (p *dotenvParser) readSingleQuoted() (string, error) {
+	p.next()
+	start := p.pos
+	for !p.eof() {
+		if p.peek() == '\'' {
+			value := p.src[start:p.pos]
+			p.next()
+			return value, nil
+		}
+		p.next()
+	}
+	return "", fmt.Errorf("unterminated single-quoted value")
+}
+
+// readDoubleQuoted reads a value up to the closing quote, applying escapes
+// and expanding references
+func (p *dotenvParser) readDoubleQuoted() (string, error) {
+	p.next()
+	var buf strings.Builder
+	for !p.eof() {
+		b := p.next()
+		switch b {
+		case '"':
+			return buf.String(), nil
+		case '\\':
+			if p.eof() {
+				return "", fmt.Errorf("unterminated double-quoted value")
+			}
+			switch e := p.next(); e {
+			case 'n':
+				buf.WriteByte('\n')
+			case 'r':
+				buf.WriteByte('\r')
+			case 't':
+				buf.WriteByte('\t')
+			case '"', '\\', '$':
+				buf.WriteByte(e)
+			default:
+				buf.WriteByte('\\')
+				buf.WriteByte(e)
+			}
+		case '$':
+			buf.WriteString(p.expand())
+		default:
+			buf.WriteByte(b)
+		}
+	}
+	return "", fmt.Errorf("unterminated double-quoted value")
+}
+
+// readUnquoted reads the rest of the line, dropping an inline comment and
+// trailing space, and expands references
+func (p *dotenvParser) readUnquoted() string {
+	var buf strings.Builder
+	for !p.eof() && p.peek() != '\n' {
+		b := p.next()
+		if b == '#' && buf.Len() > 0 {
+			if last := buf.String()[buf.Len()-1]; last == ' ' || last == '\t' {
+				p.skipLine()
+				break
+			}
+		}
+		if b == '$' {
+			buf.WriteString(p.expand())
+			continue
+		}
+		buf.WriteByte(b)
+	}
+	return strings.TrimRight(buf.String(), " \t")
+}
+
+// expand resolves a reference after a '$'. References to unknown variables
+// are returned as written.
+func (p *dotenvParser) expand() string {
+	if p.eof() {
+		return "$"
+	}
+
+	if p.peek() != '{' {
+		start := p.pos
+		for !p.eof() && isNameByte(p.peek()) {
+			p.next()
+		}
+		name := p.src[start:p.pos]
+		if value, ok := p.defined[name]; ok && name != "" {
+			return value
+		}
+		return "$" + name
+	}
+
+	// ${NAME}, ${NAME:-default} or ${NAME-default}
+	end := strings.IndexByte(p.src[p.pos:], '}')
+	if end < 0 || strings.IndexByte(p.src[p.pos:p.pos+end], '\n') >= 0 {
+		return "$"
+	}
+	ref := p.src[p.pos : p.pos+end+1]
+	for range len(ref) {
+		p.next()
+	}
+
+	// ":-" also replaces an empty value, "-" only an unset one
+	inner := ref[1 : len(ref)-1]
+	if name, fallback, ok := strings.Cut(inner, ":-"); ok {
+		if value := p.defined[name]; value != "" {
+			return value
+		}
+		return fallback
+	}
+	if name, fallback, ok := strings.Cut(inner, "-"); ok {
+		if value, defined := p.defined[name]; defined {
+			return value
+		}
+		return fallback
+	}
+	if value, ok := p.defined[inner]; ok {
+		return value
+	}
+	return "$" + ref
+}
+
+// isNameByte reports whether b may appear in a bare $NAME reference
+func isNameByte(b byte) bool {
+	return b == '_' || (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9')
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    [][2]string
+	}{
+		{
+			name:    "plain assignments",
+			content: "A=1\nB=two\n",
+			want:    [][2]string{{"A", "1"}, {"B", "two"}},
+		},
+		{
+			name:    "blank lines and comments",
+			content: "# header\n\nA=1\n   # indented comment\n\nB=2",
+			want:    [][2]string{{"A", "1"}, {"B", "2"}},
+		},
+		{
+			name:    "export prefix",
+			content: "export A=1\nexport\tB=2\n",
+			want:    [][2]string{{"A", "1"}, {"B", "2"}},
+		},
+		{
+			name:    "key named export",
+			content: "export=yes\n",
+			want:    [][2]string{{"export", "yes"}},
+		},
+		{
+			name:    "spaces around equals",
+			content: "A = 1\nB=  2  \n",
+			want:    [][2]string{{"A", "1"}, {"B", "2"}},
+		},
+		{
+			name:    "empty values",
+			content: "A=\nB=''\nC=\"\"\n",
+			want:    [][2]string{{"A", ""}, {"B", ""}, {"C", ""}},
+		},
+		{
+			name:    "inline comment on unquoted value",
+			content: "A=1 # one\nB=2\t# two\n",
+			want:    [][2]string{{"A", "1"}, {"B", "2"}},
+		},
+		{
+			name:    "hash without space is part of the value",
+			content: "URL=http://host/#anchor\nCOLOR=#fff\n",
+			want:    [][2]string{{"URL", "http://host/#anchor"}, {"COLOR", "#fff"}},
+		},
+		{
+			name:    "single quotes are literal",
+			content: `A='$HOME \n # not a comment'` + "\n",
+			want:    [][2]string{{"A", `$HOME \n # not a comment`}},
+		},
+		{
+			name:    "double quote escapes",
+			content: `A="line1\nline2\ttab \"q\" \\ \$X"` + "\n",
+			want:    [][2]string{{"A", "line1\nline2\ttab \"q\" \\ $X"}},
+		},
+		{
+			name:    "comment after quoted value",
+			content: "A=\"x # y\" # comment\n",
+			want:    [][2]string{{"A", "x # y"}},
+		},
+		{
+			name:    "multi-line double-quoted value",
+			content: "KEY=\"-----BEGIN KEY-----\nabc\n-----END KEY-----\"\nNEXT=1\n",
+			want:    [][2]string{{"KEY", "-----BEGIN KEY-----\nabc\n-----END KEY-----"}, {"NEXT", "1"}},
+		},
+		{
+			name:    "multi-line single-quoted value",
+			content: "A='one\ntwo'\n",
+			want:    [][2]string{{"A", "one\ntwo"}},
+		},
+		{
+			name:    "CRLF line endings",
+			content: "A=1\r\nB=\"x\"\r\n",
+			want:    [][2]string{{"A", "1"}, {"B", "x"}},
+		},
+		{
+			name:    "interpolation of earlier variables",
+			content: "HOST=db\nPORT=5432\nURL=postgres://$HOST:${PORT}/app\nQ=\"${HOST}-x\"\n",
+			want: [][2]string{
+				{"HOST", "db"}, {"PORT", "5432"},
+				{"URL", "postgres://db:5432/app"}, {"Q", "db-x"},
+			},
+		},
+		{
+			name:    "unknown references are kept",
+			content: "A=$UNKNOWN\nB=${ALSO_UNKNOWN}\nC=cost$\n",
+			want:    [][2]string{{"A", "$UNKNOWN"}, {"B", "${ALSO_UNKNOWN}"}, {"C", "cost$"}},
+		},
+		{
+			name:    "defaults",
+			content: "EMPTY=\nA=${UNSET:-fallback}\nB=${EMPTY:-fallback}\nC=${EMPTY-fallback}\nD=${UNSET-fallback}\n",
+			want: [][2]string{
+				{"EMPTY", ""}, {"A", "fallback"}, {"B", "fallback"}, {"C", ""}, {"D", "fallback"},
+			},
+		},
+		{
+			name:    "references only see earlier variables",
+			content: "A=$B\nB=1\n",
+			want:    [][2]string{{"A", "$B"}, {"B", "1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars, err := ParseDotEnv(tt.content)
+			if err != nil {
+				t.Fatalf("ParseDotEnv: %v", err)
+			}
+			if len(vars) != len(tt.want) {
+				t.Fatalf("got %d variables %v, want %d", len(vars), vars, len(tt.want))
+			}
+			for i, v := range vars {
+				if v.Key != tt.want[i][0] || v.Value != tt.want[i][1] {
+					t.Errorf("variable %d = %q=%q, want %q=%q", i, v.Key, v.Value, tt.want[i][0], tt.want[i][1])
+				}
+				if !v.IsSecret {
+					t.Errorf("variable %s is not marked secret", v.Key)
+				}
+			}
+		})
+	}
+}
+
+func TestParseDotEnvErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		line    string
+	}{
+		{"missing equals", "A=1\nJUSTAKEY\n", "line 2"},
+		{"no key", "=value\n", "line 1"},
+		{"unterminated double quote", "A=\"open\nB=2\n", "line 1"},
+		{"unterminated single quote", "A=1\nB='open\n", "line 2"},
+		{"text after closing quote", "A=\"x\" y\n", "line 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDotEnv(tt.content)
+			if err == nil {
+				t.Fatal("ParseDotEnv succeeded, want an error")
+			}
+			if !strings.Contains(err.Error(), tt.line) {
+				t.Errorf("error %q doesn't name %s", err, tt.line)
+			}
+		})
+	}
+}
@@ -19,10 +19,23 @@ type EnvVar struct {
 
 	// Is this a secret? (affects display behavior)
 	IsSecret bool `json:"is_secret" yaml:"is_secret"`
+
+	// Optional team member responsible for the variable
+	Owner string `json:"owner,omitempty" yaml:"owner,omitempty"`
+
+	// Who last changed the value, and when
+	UpdatedBy string    `json:"updated_by,omitempty" yaml:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitzero" yaml:"updated_at,omitempty"`
 }
 
+// EnvFileVersion is the env file format written by this version
+const EnvFileVersion = 1
+
 // EnvFile represents all env vars for a project+stage
 type EnvFile struct {
+	// Format version, zero for env files written before versioning
+	Version int `json:"version,omitempty" yaml:"version,omitempty"`
+
 	// Project name
 	Project string `json:"project" yaml:"project"`
 
@@ -104,6 +117,36 @@ func (e *EnvFile) Set(key, value string, isSecret bool) {
 	e.Vars = append(e.Vars, EnvVar{Key: key, Value: value, IsSecret: isSecret})
 }
 
+// Update sets a variable like Set and records who changed it. Nothing is
+// recorded if the value and secret flag are unchanged; it reports whether
+// anything changed.
+func (e *EnvFile) Update(key, value string, isSecret bool, by string) bool {
+	for i, v := range e.Vars {
+		if v.Key == key {
+			if v.Value == value && v.IsSecret == isSecret {
+				return false
+			}
+			e.Vars[i].Value = value
+			e.Vars[i].IsSecret = isSecret
+			e.Vars[i].UpdatedBy = by
+			e.Vars[i].UpdatedAt = time.Now()
+			return true
+		}
+	}
+	e.Vars = append(e.Vars, EnvVar{Key: key, Value: value, IsSecret: isSecret, UpdatedBy: by, UpdatedAt: time.Now()})
+	return true
+}
+
+// Var returns a pointer to the variable with key, or nil
+func (e *EnvFile) Var(key string) *EnvVar {
+	for i := range e.Vars {
+		if e.Vars[i].Key == key {
+			return &e.Vars[i]
+		}
+	}
+	return nil
+}
+
 // Delete removes a variable
 func (e *EnvFile) Delete(key string) bool {
 	for i, v := range e.Vars {
@@ -115,6 +158,31 @@ func (e *EnvFile) Delete(key string) bool {
 	return false
 }
 
+// PublicOnly returns a copy holding only the variables that aren't secret
+func (e *EnvFile) PublicOnly() *EnvFile {
+	public := *e
+	public.Vars = nil
+	for _, v := range e.Vars {
+		if !v.IsSecret {
+			public.Vars = append(public.Vars, v)
+		}
+	}
+	return &public
+}
+
+// Masked returns a copy with secret values replaced by mask
+func (e *EnvFile) Masked(mask string) *EnvFile {
+	masked := *e
+	masked.Vars = make([]EnvVar, len(e.Vars))
+	for i, v := range e.Vars {
+		if v.IsSecret {
+			v.Value = mask
+		}
+		masked.Vars[i] = v
+	}
+	return &masked
+}
+
 // ToMap converts to a map for env injection
 func (e *EnvFile) ToMap() map[string]string {
 	m := make(map[string]string, len(e.Vars))
@@ -124,14 +192,15 @@ func (e *EnvFile) ToMap() map[string]string {
 	return m
 }
 
+// dotenvEscaper escapes a value for a double-quoted .env string so that
+// ParseDotEnv reads it back unchanged
+var dotenvEscaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "$", "\\$", "\n", "\\n", "\r", "\\r")
+
 // ToDotEnv converts to .env file format
 func (e *EnvFile) ToDotEnv() string {
 	var buf strings.Builder
 	for _, v := range e.Vars {
-		// Escape special characters in value
-		value := strings.ReplaceAll(v.Value, "\\", "\\\\")
-		value = strings.ReplaceAll(value, "\"", "\\\"")
-		buf.WriteString(fmt.Sprintf("%s=\"%s\"\n", v.Key, value))
+		buf.WriteString(fmt.Sprintf("%s=\"%s\"\n", v.Key, dotenvEscaper.Replace(v.Value)))
 	}
 	return buf.String()
 }
@@ -147,46 +216,62 @@ func (e *EnvFile) ToExport() string {
 	return buf.String()
 }
 
-// ParseDotEnv parses a .env file format string
-func ParseDotEnv(content string) []EnvVar {
-	var vars []EnvVar
-	lines := strings.Split(content, "\n")
+// powershellQuotes doubles every character PowerShell treats as a single
+// quote, including the typographic ones
+var powershellQuotes = strings.NewReplacer("'", "''", "\u2018", "\u2018\u2018", "\u2019", "\u2019\u2019", "\u201a", "\u201a\u201a", "\u201b", "\u201b\u201b")
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
+// Shells lists the shells ToShell can format for
+var Shells = []string{"bash", "zsh", "sh", "fish", "powershell"}
 
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
+// ToShell converts to statements that load the variables into the current
+// shell when evaluated. Keys that aren't valid identifiers could inject
+// code, so they are left out and returned for the caller to report.
+func (e *EnvFile) ToShell(shell string) (string, []string, error) {
+	var format func(key, value string) string
+	switch shell {
+	case "bash", "zsh", "sh":
+		format = func(key, value string) string {
+			return fmt.Sprintf("export %s='%s'\n", key, strings.ReplaceAll(value, "'", "'\"'\"'"))
+		}
+	case "fish":
+		format = func(key, value string) string {
+			value = strings.ReplaceAll(value, "\\", "\\\\")
+			value = strings.ReplaceAll(value, "'", "\\'")
+			return fmt.Sprintf("set -gx %s '%s';\n", key, value)
+		}
+	case "powershell", "pwsh":
+		format = func(key, value string) string {
+			return fmt.Sprintf("$env:%s = '%s'\n", key, powershellQuotes.Replace(value))
 		}
+	default:
+		return "", nil, fmt.Errorf("unsupported shell: %s (valid: %s)", shell, strings.Join(Shells, ", "))
+	}
 
-		// Parse KEY=VALUE
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
+	var buf strings.Builder
+	var skipped []string
+	for _, v := range e.Vars {
+		if !IsShellIdentifier(v.Key) {
+			skipped = append(skipped, v.Key)
 			continue
 		}
+		buf.WriteString(format(v.Key, v.Value))
+	}
+	return buf.String(), skipped, nil
+}
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-
-		// Remove quotes if present
-		if len(value) >= 2 {
-			if (value[0] == '"' && value[len(value)-1] == '"') ||
-				(value[0] == '\'' && value[len(value)-1] == '\'') {
-				value = value[1 : len(value)-1]
-			}
+// IsShellIdentifier reports whether key can be used as a variable name in
+// every supported shell
+func IsShellIdentifier(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, r := range key {
+		switch {
+		case r == '_', r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
 		}
-
-		// Unescape
-		value = strings.ReplaceAll(value, "\\\"", "\"")
-		value = strings.ReplaceAll(value, "\\\\", "\\")
-
-		vars = append(vars, EnvVar{
-			Key:      key,
-			Value:    value,
-			IsSecret: true, // Default to secret
-		})
 	}
-
-	return vars
+	return true
 }
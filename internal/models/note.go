@@ -0,0 +1,55 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// NoteVersion is the note format written by this version
+const NoteVersion = 1
+
+// Note is a free-form encrypted document, such as a runbook with embedded
+// credentials, a license key or a set of recovery codes
+type Note struct {
+	// Format version
+	Version int `json:"version,omitempty" yaml:"version,omitempty"`
+
+	// Unique identifier (auto-generated)
+	ID string `json:"id" yaml:"id"`
+
+	// Note name (e.g., "db-failover-runbook")
+	Name string `json:"name" yaml:"name"`
+
+	// Markdown or plain text content (stored encrypted)
+	Body string `json:"body" yaml:"body"`
+
+	// Tags for organization
+	Tags []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+
+	// Who created and last changed this note
+	CreatedBy string `json:"created_by" yaml:"created_by"`
+	UpdatedBy string `json:"updated_by,omitempty" yaml:"updated_by,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" yaml:"updated_at"`
+}
+
+// Path returns the storage path for this note
+// Example: "notes/db-failover-runbook"
+func (n *Note) Path() string {
+	return fmt.Sprintf("notes/%s", n.Name)
+}
+
+// FullPath returns the full storage path with extension
+func (n *Note) FullPath() string {
+	return n.Path() + ".age"
+}
+
+// NoteSummary is a lightweight version for listing
+type NoteSummary struct {
+	Name      string    `json:"name"`
+	Tags      []string  `json:"tags,omitempty"`
+	Lines     int       `json:"lines"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // AccessLevel represents read or write access
 type AccessLevel string
 
@@ -31,6 +33,14 @@ type RecipientPermission struct {
 
 	// Access level (read or write)
 	Access AccessLevel `json:"access" yaml:"access"`
+
+	// When the grant ends; zero for a grant that doesn't expire
+	ExpiresAt time.Time `json:"expires_at,omitzero" yaml:"expires_at,omitempty"`
+}
+
+// IsExpired checks if the grant has run out
+func (r RecipientPermission) IsExpired() bool {
+	return !r.ExpiresAt.IsZero() && time.Now().After(r.ExpiresAt)
 }
 
 // SecretPermissions manages per-secret access control
@@ -51,11 +61,18 @@ func NewSecretPermissions() *SecretPermissions {
 
 // AddRecipient adds a recipient with specified access
 func (p *SecretPermissions) AddRecipient(email, publicKey string, access AccessLevel) {
+	p.AddRecipientUntil(email, publicKey, access, time.Time{})
+}
+
+// AddRecipientUntil adds a recipient whose access ends at expires, or never
+// if expires is zero
+func (p *SecretPermissions) AddRecipientUntil(email, publicKey string, access AccessLevel, expires time.Time) {
 	// Check if already exists
 	for i, r := range p.Recipients {
 		if r.Email == email || r.PublicKey == publicKey {
 			// Update existing
 			p.Recipients[i].Access = access
+			p.Recipients[i].ExpiresAt = expires
 			return
 		}
 	}
@@ -64,6 +81,7 @@ func (p *SecretPermissions) AddRecipient(email, publicKey string, access AccessL
 		Email:     email,
 		PublicKey: publicKey,
 		Access:    access,
+		ExpiresAt: expires,
 	})
 }
 
@@ -78,10 +96,27 @@ func (p *SecretPermissions) RemoveRecipient(email string) bool {
 	return false
 }
 
-// GetAccess returns the access level for a recipient
+// RemoveExpired removes grants that have run out and returns the emails
+// they were for
+func (p *SecretPermissions) RemoveExpired() []string {
+	var expired []string
+	kept := p.Recipients[:0]
+	for _, r := range p.Recipients {
+		if r.IsExpired() {
+			expired = append(expired, r.Email)
+			continue
+		}
+		kept = append(kept, r)
+	}
+	p.Recipients = kept
+	return expired
+}
+
+// GetAccess returns the access level for a recipient. Expired grants give
+// no access.
 func (p *SecretPermissions) GetAccess(email string) (AccessLevel, bool) {
 	for _, r := range p.Recipients {
-		if r.Email == email {
+		if r.Email == email && !r.IsExpired() {
 			return r.Access, true
 		}
 	}
@@ -91,7 +126,7 @@ func (p *SecretPermissions) GetAccess(email string) (AccessLevel, bool) {
 // GetAccessByKey returns the access level for a public key
 func (p *SecretPermissions) GetAccessByKey(publicKey string) (AccessLevel, bool) {
 	for _, r := range p.Recipients {
-		if r.PublicKey == publicKey {
+		if r.PublicKey == publicKey && !r.IsExpired() {
 			return r.Access, true
 		}
 	}
@@ -110,10 +145,14 @@ func (p *SecretPermissions) CanWrite(email string) bool {
 	return found && access == AccessWrite
 }
 
-// GetReadRecipients returns public keys of all recipients who can read
+// GetReadRecipients returns public keys of all recipients who can read,
+// leaving out expired grants
 func (p *SecretPermissions) GetReadRecipients() []string {
 	var keys []string
 	for _, r := range p.Recipients {
+		if r.IsExpired() {
+			continue
+		}
 		// Both read and write can read
 		keys = append(keys, r.PublicKey)
 	}
@@ -124,7 +163,7 @@ func (p *SecretPermissions) GetReadRecipients() []string {
 func (p *SecretPermissions) GetWriteRecipients() []string {
 	var keys []string
 	for _, r := range p.Recipients {
-		if r.Access == AccessWrite {
+		if r.Access == AccessWrite && !r.IsExpired() {
 			keys = append(keys, r.PublicKey)
 		}
 	}
@@ -143,10 +182,15 @@ func (p *SecretPermissions) Count() int {
 	return len(p.Recipients)
 }
 
-// HasRecipient checks if a recipient exists
+// HasRecipient checks if a recipient exists, whether or not their grant
+// has expired
 func (p *SecretPermissions) HasRecipient(email string) bool {
-	_, found := p.GetAccess(email)
-	return found
+	for _, r := range p.Recipients {
+		if r.Email == email {
+			return true
+		}
+	}
+	return false
 }
 
 // Clone creates a copy of the permissions
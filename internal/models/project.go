@@ -19,8 +19,14 @@ type Project struct {
 	// Who created this project
 	CreatedBy string `json:"created_by" yaml:"created_by"`
 
+	// Team members who manage this project without being admins
+	Owners []string `json:"owners,omitempty" yaml:"owners,omitempty"`
+
 	// Timestamps
 	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+
+	// Variables the application expects
+	Schema []VarSchema `json:"schema,omitempty" yaml:"schema,omitempty"`
 }
 
 // Path returns the storage path for this project
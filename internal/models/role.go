@@ -1,6 +1,7 @@
 package models
 
-// Role represents a user's access level
+// Role represents a user's access level. What each role may do is defined
+// by rbac.RolePermissions; check access through the rbac engine.
 type Role string
 
 const (
@@ -15,6 +16,9 @@ const (
 
 	// RoleAdmin has full access + team management
 	RoleAdmin Role = "admin"
+
+	// RoleViewer makes a user read-only; stage access comes from their other roles
+	RoleViewer Role = "viewer"
 )
 
 // Stage represents a deployment environment
@@ -33,40 +37,13 @@ func AllStages() []Stage {
 
 // AllRoles returns all valid roles
 func AllRoles() []Role {
-	return []Role{RoleDev, RoleStagingAccess, RoleProdAccess, RoleAdmin}
-}
-
-// CanAccessStage checks if this role can access the given stage
-func (r Role) CanAccessStage(stage Stage) bool {
-	switch r {
-	case RoleAdmin, RoleProdAccess:
-		return true // Can access all stages
-	case RoleStagingAccess:
-		return stage == StageDev || stage == StageStaging
-	case RoleDev:
-		return stage == StageDev
-	default:
-		return false
-	}
-}
-
-// CanManageTeam checks if this role can invite/remove members
-func (r Role) CanManageTeam() bool {
-	return r == RoleAdmin
+	return []Role{RoleDev, RoleStagingAccess, RoleProdAccess, RoleAdmin, RoleViewer}
 }
 
-// CanWriteCredentials checks if this role can modify credentials
-func (r Role) CanWriteCredentials() bool {
-	return r == RoleAdmin || r == RoleProdAccess
-}
-
-// RoleHierarchy defines role ordering (higher index = more permissions)
-var RoleHierarchy = []Role{RoleDev, RoleStagingAccess, RoleProdAccess, RoleAdmin}
-
 // IsValid checks if the role is valid
 func (r Role) IsValid() bool {
 	switch r {
-	case RoleDev, RoleStagingAccess, RoleProdAccess, RoleAdmin:
+	case RoleDev, RoleStagingAccess, RoleProdAccess, RoleAdmin, RoleViewer:
 		return true
 	default:
 		return false
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Value types a schema entry can require
+const (
+	VarTypeString = "string"
+	VarTypeInt    = "int"
+	VarTypeBool   = "bool"
+	VarTypeURL    = "url"
+	VarTypeEnum   = "enum"
+)
+
+// VarSchema describes what an application expects of one variable.
+// Schemas are declared in a project's .passbook-project file.
+type VarSchema struct {
+	// Variable key (e.g., "DATABASE_URL")
+	Key string `json:"key" yaml:"key"`
+
+	// Must the variable be set?
+	Required bool `json:"required,omitempty" yaml:"required,omitempty"`
+
+	// Value type: string (default), int, bool, url or enum
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+
+	// Allowed values when Type is enum
+	Values []string `json:"values,omitempty" yaml:"values,omitempty"`
+
+	// Optional regular expression the whole value must match
+	Pattern string `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+
+	// Stages the entry applies to (default: all)
+	Stages []Stage `json:"stages,omitempty" yaml:"stages,omitempty"`
+
+	// Optional description for people filling in the value
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// AppliesTo reports whether the entry covers stage
+func (s VarSchema) AppliesTo(stage Stage) bool {
+	return len(s.Stages) == 0 || slices.Contains(s.Stages, stage)
+}
+
+// Validate checks a value against the entry. Errors never include the
+// value, which may be secret.
+func (s VarSchema) Validate(value string) error {
+	switch s.Type {
+	case "", VarTypeString:
+	case VarTypeInt:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+	case VarTypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+	case VarTypeURL:
+		u, err := url.Parse(value)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("must be a URL with a scheme and host")
+		}
+	case VarTypeEnum:
+		if !slices.Contains(s.Values, value) {
+			return fmt.Errorf("must be one of: %s", strings.Join(s.Values, ", "))
+		}
+	default:
+		return fmt.Errorf("unknown type %q in schema", s.Type)
+	}
+
+	if s.Pattern != "" {
+		re, err := regexp.Compile("^(?:" + s.Pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid pattern in schema: %w", err)
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("must match pattern %s", s.Pattern)
+		}
+	}
+	return nil
+}
+
+// ValidateSchema checks that a schema is well formed
+func ValidateSchema(schema []VarSchema) error {
+	seen := make(map[string]bool, len(schema))
+	for _, s := range schema {
+		if s.Key == "" {
+			return fmt.Errorf("schema entry without a key")
+		}
+		if seen[s.Key] {
+			return fmt.Errorf("%s is declared twice", s.Key)
+		}
+		seen[s.Key] = true
+
+		switch s.Type {
+		case "", VarTypeString, VarTypeInt, VarTypeBool, VarTypeURL:
+		case VarTypeEnum:
+			if len(s.Values) == 0 {
+				return fmt.Errorf("%s: enum needs a list of values", s.Key)
+			}
+		default:
+			return fmt.Errorf("%s: unknown type %q (valid: string, int, bool, url, enum)", s.Key, s.Type)
+		}
+
+		if s.Pattern != "" {
+			if _, err := regexp.Compile(s.Pattern); err != nil {
+				return fmt.Errorf("%s: invalid pattern: %w", s.Key, err)
+			}
+		}
+		for _, stage := range s.Stages {
+			if !stage.IsValid() {
+				return fmt.Errorf("%s: invalid stage: %s", s.Key, stage)
+			}
+		}
+	}
+	return nil
+}
+
+// SchemaProblem is a variable that doesn't satisfy its schema
+type SchemaProblem struct {
+	Key     string
+	Problem string
+}
+
+// CheckEnv reports required variables that are missing and values that
+// don't match the schema for the env file's stage
+func CheckEnv(schema []VarSchema, env *EnvFile) []SchemaProblem {
+	var problems []SchemaProblem
+	for _, s := range schema {
+		if !s.AppliesTo(env.Stage) {
+			continue
+		}
+		value, ok := env.Get(s.Key)
+		if !ok {
+			if s.Required {
+				problems = append(problems, SchemaProblem{s.Key, "required but not set"})
+			}
+			continue
+		}
+		if err := s.Validate(value); err != nil {
+			problems = append(problems, SchemaProblem{s.Key, err.Error()})
+		}
+	}
+	return problems
+}
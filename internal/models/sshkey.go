@@ -0,0 +1,54 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// SSHKeyVersion is the SSH key format written by this version
+const SSHKeyVersion = 1
+
+// SSHKey is an SSH private key kept in the store
+type SSHKey struct {
+	// Format version
+	Version int `json:"version,omitempty" yaml:"version,omitempty"`
+
+	// Unique identifier (auto-generated)
+	ID string `json:"id" yaml:"id"`
+
+	// Key name (e.g., "deploy-prod")
+	Name string `json:"name" yaml:"name"`
+
+	// Key algorithm, e.g. "ssh-ed25519"
+	Type string `json:"type" yaml:"type"`
+
+	// SHA256 fingerprint of the public key, as ssh-keygen -l shows it
+	Fingerprint string `json:"fingerprint" yaml:"fingerprint"`
+
+	// Comment shown by ssh-add -l
+	Comment string `json:"comment,omitempty" yaml:"comment,omitempty"`
+
+	// Public key in authorized_keys format
+	PublicKey string `json:"public_key" yaml:"public_key"`
+
+	// Unencrypted OpenSSH private key (stored encrypted)
+	PrivateKey string `json:"private_key" yaml:"private_key"`
+
+	// Who created this key
+	CreatedBy string `json:"created_by" yaml:"created_by"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" yaml:"updated_at"`
+}
+
+// Path returns the storage path for this key
+// Example: "ssh/deploy-prod"
+func (k *SSHKey) Path() string {
+	return fmt.Sprintf("ssh/%s", k.Name)
+}
+
+// FullPath returns the full storage path with extension
+func (k *SSHKey) FullPath() string {
+	return k.Path() + ".age"
+}
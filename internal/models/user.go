@@ -25,6 +25,15 @@ type User struct {
 	// User's assigned roles
 	Roles []Role `json:"roles" yaml:"roles"`
 
+	// GitHub account bound to the user when they were verified
+	GitHubID    int64  `json:"github_id,omitempty" yaml:"github_id,omitempty"`
+	GitHubLogin string `json:"github_login,omitempty" yaml:"github_login,omitempty"`
+
+	// External collaborators sit outside the org's email domain; all their
+	// access ends at ExpiresAt
+	External  bool      `json:"external,omitempty" yaml:"external,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitzero" yaml:"expires_at,omitempty"`
+
 	// Metadata for additional user properties
 	Metadata map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
 }
@@ -44,14 +53,40 @@ func (u *User) SetVerified() {
 	}
 }
 
-// CanAccessStage checks if user can access a specific stage
-func (u *User) CanAccessStage(stage Stage) bool {
-	for _, role := range u.Roles {
-		if role.CanAccessStage(stage) {
-			return true
-		}
+// BindGitHub records the user's GitHub account
+func (u *User) BindGitHub(id int64, login string) {
+	u.GitHubID = id
+	u.GitHubLogin = login
+}
+
+// IsExpired checks if the user's access has run out
+func (u *User) IsExpired() bool {
+	return !u.ExpiresAt.IsZero() && time.Now().After(u.ExpiresAt)
+}
+
+// CanReceiveSecrets checks if secrets should be encrypted for the user: they
+// have a verified key and their access hasn't run out
+func (u *User) CanReceiveSecrets() bool {
+	return u.PublicKey != "" && !u.IsPendingVerification() && !u.IsExpired()
+}
+
+// IsServiceAccount checks if user is a non-human machine identity
+func (u *User) IsServiceAccount() bool {
+	if u.Metadata == nil {
+		return false
 	}
-	return false
+	return u.Metadata["service_account"] == "true"
+}
+
+// AuditName returns how the user is attributed in audit logs
+func (u *User) AuditName() string {
+	if u.IsServiceAccount() {
+		return "service-account:" + u.Email
+	}
+	if u.External {
+		return "external:" + u.Email
+	}
+	return u.Email
 }
 
 // HasRole checks if user has a specific role
@@ -65,28 +100,15 @@ func (u *User) HasRole(role Role) bool {
 }
 
 // IsAdmin checks if user has admin role
+// Service accounts and external collaborators are never admins, even if the
+// role was added by hand
 func (u *User) IsAdmin() bool {
-	return u.HasRole(RoleAdmin)
+	return !u.IsServiceAccount() && !u.External && u.HasRole(RoleAdmin)
 }
 
-// CanManageTeam checks if user can manage team members
-func (u *User) CanManageTeam() bool {
-	for _, role := range u.Roles {
-		if role.CanManageTeam() {
-			return true
-		}
-	}
-	return false
-}
-
-// CanWriteCredentials checks if user can modify credentials
-func (u *User) CanWriteCredentials() bool {
-	for _, role := range u.Roles {
-		if role.CanWriteCredentials() {
-			return true
-		}
-	}
-	return false
+// IsReadOnly checks if user's writes must be rejected
+func (u *User) IsReadOnly() bool {
+	return u.HasRole(RoleViewer)
 }
 
 // UserList is a list of users for serialization
@@ -0,0 +1,152 @@
+// Package notify delivers notifications about store activity to webhooks,
+// commands and files. The payload carries a "text" field so Slack and
+// Mattermost incoming webhooks display it as is; other receivers can use
+// the structured fields.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"passbook/internal/audit"
+)
+
+// timeout bounds how long a command waits on the webhook
+const timeout = 5 * time.Second
+
+// Message is the JSON body posted to the webhook
+type Message struct {
+	Text      string            `json:"text"`
+	Event     string            `json:"event"`
+	Actor     string            `json:"actor"`
+	Target    string            `json:"target"`
+	Timestamp time.Time         `json:"timestamp"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// Send posts msg to the webhook at url
+func Send(ctx context.Context, url string, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// EventMessage describes an audit event for the event sinks
+func EventMessage(e audit.Event) Message {
+	return Message{
+		Text:      fmt.Sprintf("passbook: %s %s %s", e.Actor, e.Type, e.Target),
+		Event:     string(e.Type),
+		Actor:     e.Actor,
+		Target:    e.Target,
+		Timestamp: e.Timestamp,
+		Details:   e.Details,
+	}
+}
+
+// Sinks are where store events are delivered
+type Sinks struct {
+	URL     string // HTTP endpoint each event is posted to
+	Command string // Shell command run for each event, with the JSON on stdin
+	File    string // File each event is appended to as a line of JSON
+}
+
+// Empty reports whether no sink is configured
+func (s Sinks) Empty() bool {
+	return s.URL == "" && s.Command == "" && s.File == ""
+}
+
+// Deliver sends msg to every configured sink. One failing sink doesn't stop
+// the others; their errors are returned together.
+func (s Sinks) Deliver(ctx context.Context, msg Message) error {
+	var errs []error
+	if s.URL != "" {
+		if err := Send(ctx, s.URL, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if s.Command != "" {
+		if err := Run(ctx, s.Command, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if s.File != "" {
+		if err := Append(s.File, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run runs command through the shell with msg as JSON on stdin
+func Run(ctx context.Context, command string, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(), "PASSBOOK_EVENT="+msg.Event)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("event command failed: %w: %s", err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+// Append writes msg to path as one line of JSON
+func Append(path string, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open event file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(body, '\n')); err != nil {
+		return fmt.Errorf("failed to write event file: %w", err)
+	}
+	return nil
+}
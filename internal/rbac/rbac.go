@@ -1,6 +1,9 @@
 package rbac
 
 import (
+	"fmt"
+	"strings"
+
 	"passbook/internal/models"
 )
 
@@ -30,6 +33,11 @@ const (
 	PermProjectList   Permission = "project:list"
 	PermProjectCreate Permission = "project:create"
 	PermProjectDelete Permission = "project:delete"
+	PermProjectManage Permission = "project:manage" // owners, stages, schema and access of a project
+
+	// Store permissions
+	PermStoreReencrypt Permission = "store:reencrypt"
+	PermStoreConfig    Permission = "store:config"
 )
 
 // RolePermissions defines what each role can do
@@ -62,6 +70,12 @@ var RolePermissions = map[models.Role][]Permission{
 		PermTeamList,
 		PermProjectList,
 		PermProjectCreate,
+		PermProjectManage,
+	},
+	models.RoleViewer: {
+		PermCredentialsRead,
+		PermTeamList,
+		PermProjectList,
 	},
 	models.RoleAdmin: {
 		// All permissions
@@ -80,9 +94,18 @@ var RolePermissions = map[models.Role][]Permission{
 		PermProjectList,
 		PermProjectCreate,
 		PermProjectDelete,
+		PermProjectManage,
+		PermStoreReencrypt,
+		PermStoreConfig,
 	},
 }
 
+// ProjectOwnerPermissions are held by a project's owners on that project
+// alone, whatever their roles
+var ProjectOwnerPermissions = []Permission{
+	PermProjectManage,
+}
+
 // Engine evaluates permissions
 type Engine struct {
 	userStore UserStore
@@ -99,24 +122,99 @@ func NewEngine(store UserStore) *Engine {
 	return &Engine{userStore: store}
 }
 
+// Decision is the outcome of a permission check and why it was reached
+type Decision struct {
+	Allowed    bool
+	Permission Permission
+	Role       models.Role // Role that granted the permission, if allowed
+	Reason     string
+}
+
 // Can checks if user has permission
 func (e *Engine) Can(user *models.User, perm Permission) bool {
+	return e.Explain(user, perm).Allowed
+}
+
+// Explain evaluates a permission and describes why it is allowed or denied
+func (e *Engine) Explain(user *models.User, perm Permission) Decision {
+	d := Decision{Permission: perm}
+
 	if user == nil {
-		return false
+		d.Reason = "not a member of the team"
+		return d
+	}
+	if !IsValidPermission(perm) {
+		d.Reason = fmt.Sprintf("unknown permission %q", perm)
+		return d
+	}
+
+	if user.IsExpired() {
+		d.Reason = fmt.Sprintf("%s's access expired on %s", user.Email, user.ExpiresAt.Format("2006-01-02"))
+		return d
+	}
+
+	// Viewers keep their read permissions but lose every write
+	if user.IsReadOnly() && !IsReadPermission(perm) {
+		d.Reason = fmt.Sprintf("%s has the viewer role, which rejects all writes", user.Email)
+		return d
+	}
+
+	// Service accounts never manage the team, whatever roles they hold
+	if user.IsServiceAccount() && isTeamManagement(perm) {
+		d.Reason = fmt.Sprintf("%s is a service account and cannot manage the team", user.Email)
+		return d
+	}
+
+	// External collaborators never manage the team or the store
+	if user.External && (isTeamManagement(perm) || isStoreManagement(perm)) {
+		d.Reason = fmt.Sprintf("%s is an external collaborator and cannot manage the team or store", user.Email)
+		return d
 	}
 
 	for _, role := range user.Roles {
-		perms, ok := RolePermissions[role]
-		if !ok {
+		for _, p := range RolePermissions[role] {
+			if p == perm {
+				d.Allowed = true
+				d.Role = role
+				d.Reason = fmt.Sprintf("granted by the %s role", role)
+				return d
+			}
+		}
+	}
+
+	if len(user.Roles) == 0 {
+		d.Reason = fmt.Sprintf("%s has no roles", user.Email)
+		return d
+	}
+	roles := make([]string, len(user.Roles))
+	for i, r := range user.Roles {
+		roles[i] = string(r)
+	}
+	d.Reason = fmt.Sprintf("none of %s's roles (%s) grant %s", user.Email, strings.Join(roles, ", "), perm)
+	return d
+}
+
+// ExplainProject evaluates a permission on one project, where the project's
+// owners also hold ProjectOwnerPermissions
+func (e *Engine) ExplainProject(user *models.User, perm Permission, project string, owners []string) Decision {
+	d := e.Explain(user, perm)
+	if d.Allowed || user == nil || user.IsReadOnly() || !IsValidPermission(perm) {
+		return d
+	}
+
+	for _, p := range ProjectOwnerPermissions {
+		if p != perm {
 			continue
 		}
-		for _, p := range perms {
-			if p == perm {
-				return true
+		for _, owner := range owners {
+			if owner == user.Email {
+				d.Allowed = true
+				d.Reason = fmt.Sprintf("granted as an owner of project %s", project)
+				return d
 			}
 		}
 	}
-	return false
+	return d
 }
 
 // CanAccessStage checks if user can access a specific stage
@@ -125,27 +223,8 @@ func (e *Engine) CanAccessStage(user *models.User, stage models.Stage, write boo
 		return false
 	}
 
-	var perm Permission
-	switch stage {
-	case models.StageDev:
-		if write {
-			perm = PermEnvDevWrite
-		} else {
-			perm = PermEnvDevRead
-		}
-	case models.StageStaging:
-		if write {
-			perm = PermEnvStagingWrite
-		} else {
-			perm = PermEnvStagingRead
-		}
-	case models.StageProd:
-		if write {
-			perm = PermEnvProdWrite
-		} else {
-			perm = PermEnvProdRead
-		}
-	default:
+	perm := GetStagePermission(stage, write)
+	if perm == "" {
 		return false
 	}
 
@@ -167,50 +246,10 @@ func (e *Engine) IsAdmin(user *models.User) bool {
 	if user == nil {
 		return false
 	}
-	return user.HasRole(models.RoleAdmin)
-}
-
-// GetStageRecipients returns public keys of users who can access a stage
-func (e *Engine) GetStageRecipients(stage models.Stage) ([]string, error) {
-	if e.userStore == nil {
-		return nil, nil
-	}
-
-	users, err := e.userStore.ListUsers()
-	if err != nil {
-		return nil, err
-	}
-
-	var keys []string
-	for _, user := range users {
-		if e.CanAccessStage(&user, stage, false) {
-			keys = append(keys, user.PublicKey)
-		}
-	}
-	return keys, nil
-}
-
-// GetAllRecipients returns public keys of all users
-func (e *Engine) GetAllRecipients() ([]string, error) {
-	if e.userStore == nil {
-		return nil, nil
-	}
-
-	users, err := e.userStore.ListUsers()
-	if err != nil {
-		return nil, err
-	}
-
-	var keys []string
-	for _, user := range users {
-		if user.PublicKey != "" {
-			keys = append(keys, user.PublicKey)
-		}
-	}
-	return keys, nil
+	return user.IsAdmin()
 }
 
-// GetStagePermission returns the read permission for a stage
+// GetStagePermission returns the read or write permission for a stage
 func GetStagePermission(stage models.Stage, write bool) Permission {
 	switch stage {
 	case models.StageDev:
@@ -251,5 +290,38 @@ func AllPermissions() []Permission {
 		PermProjectList,
 		PermProjectCreate,
 		PermProjectDelete,
+		PermProjectManage,
+		PermStoreReencrypt,
+		PermStoreConfig,
+	}
+}
+
+// IsReadPermission checks if a permission only reads data
+func IsReadPermission(perm Permission) bool {
+	switch perm {
+	case PermCredentialsRead, PermEnvDevRead, PermEnvStagingRead, PermEnvProdRead, PermTeamList, PermProjectList:
+		return true
+	default:
+		return false
 	}
 }
+
+// IsValidPermission checks if a permission is defined
+func IsValidPermission(perm Permission) bool {
+	for _, p := range AllPermissions() {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// isTeamManagement checks if a permission changes team membership or roles
+func isTeamManagement(perm Permission) bool {
+	return perm == PermTeamInvite || perm == PermTeamRevoke || perm == PermTeamGrant
+}
+
+// isStoreManagement checks if a permission changes the store as a whole
+func isStoreManagement(perm Permission) bool {
+	return perm == PermStoreReencrypt || perm == PermStoreConfig || perm == PermProjectDelete
+}
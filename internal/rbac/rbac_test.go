@@ -0,0 +1,195 @@
+package rbac
+
+import (
+	"testing"
+	"time"
+
+	"passbook/internal/models"
+)
+
+// expectedPermissions is the role model as documented, written out
+// independently of RolePermissions so a change to either shows up here
+var expectedPermissions = map[models.Role][]Permission{
+	models.RoleDev: {
+		PermCredentialsRead,
+		PermEnvDevRead, PermEnvDevWrite,
+		PermTeamList, PermProjectList,
+	},
+	models.RoleStagingAccess: {
+		PermCredentialsRead,
+		PermEnvDevRead, PermEnvDevWrite,
+		PermEnvStagingRead, PermEnvStagingWrite,
+		PermTeamList, PermProjectList,
+	},
+	models.RoleProdAccess: {
+		PermCredentialsRead, PermCredentialsWrite,
+		PermEnvDevRead, PermEnvDevWrite,
+		PermEnvStagingRead, PermEnvStagingWrite,
+		PermEnvProdRead, PermEnvProdWrite,
+		PermTeamList, PermProjectList, PermProjectCreate, PermProjectManage,
+	},
+	models.RoleViewer: {
+		PermCredentialsRead, PermTeamList, PermProjectList,
+	},
+	models.RoleAdmin: AllPermissions(),
+}
+
+func contains(perms []Permission, perm Permission) bool {
+	for _, p := range perms {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRolePermissionMatrix(t *testing.T) {
+	engine := NewEngine(nil)
+	for _, role := range models.AllRoles() {
+		expected, ok := expectedPermissions[role]
+		if !ok {
+			t.Fatalf("role %s has no expected permissions", role)
+		}
+		user := &models.User{Email: "member@example.com", Roles: []models.Role{role}}
+		for _, perm := range AllPermissions() {
+			want := contains(expected, perm)
+			d := engine.Explain(user, perm)
+			if d.Allowed != want {
+				t.Errorf("%s %s: allowed = %v, want %v (%s)", role, perm, d.Allowed, want, d.Reason)
+			}
+			if got := engine.Can(user, perm); got != d.Allowed {
+				t.Errorf("%s %s: Can = %v, Explain = %v", role, perm, got, d.Allowed)
+			}
+			if d.Allowed && d.Role != role {
+				t.Errorf("%s %s: granted by %q", role, perm, d.Role)
+			}
+			if d.Reason == "" {
+				t.Errorf("%s %s: no reason given", role, perm)
+			}
+		}
+	}
+}
+
+func TestEveryPermissionIsHeldByARole(t *testing.T) {
+	for _, perm := range AllPermissions() {
+		held := false
+		for _, perms := range RolePermissions {
+			held = held || contains(perms, perm)
+		}
+		if !held {
+			t.Errorf("no role grants %s", perm)
+		}
+	}
+	for role, perms := range RolePermissions {
+		for _, perm := range perms {
+			if !IsValidPermission(perm) {
+				t.Errorf("%s grants undefined permission %s", role, perm)
+			}
+		}
+	}
+}
+
+func TestCanAccessStageFollowsPermissions(t *testing.T) {
+	engine := NewEngine(nil)
+	for _, role := range models.AllRoles() {
+		user := &models.User{Email: "member@example.com", Roles: []models.Role{role}}
+		for _, stage := range models.AllStages() {
+			for _, write := range []bool{false, true} {
+				want := engine.Can(user, GetStagePermission(stage, write))
+				if got := engine.CanAccessStage(user, stage, write); got != want {
+					t.Errorf("%s %s write=%v: CanAccessStage = %v, Can = %v", role, stage, write, got, want)
+				}
+			}
+		}
+	}
+	if engine.CanAccessStage(&models.User{Roles: []models.Role{models.RoleAdmin}}, "qa", false) {
+		t.Error("unknown stage allowed")
+	}
+}
+
+func TestRestrictedMembers(t *testing.T) {
+	engine := NewEngine(nil)
+	all := []models.Role{models.RoleAdmin, models.RoleProdAccess}
+
+	tests := []struct {
+		name string
+		user *models.User
+		// allowed decides each permission for this kind of member
+		allowed func(perm Permission) bool
+	}{
+		{
+			name:    "viewer keeps only reads",
+			user:    &models.User{Email: "v@example.com", Roles: append([]models.Role{models.RoleViewer}, all...)},
+			allowed: IsReadPermission,
+		},
+		{
+			name:    "expired member has nothing",
+			user:    &models.User{Email: "x@example.com", Roles: all, External: true, ExpiresAt: time.Now().Add(-time.Hour)},
+			allowed: func(Permission) bool { return false },
+		},
+		{
+			name: "service account never manages the team",
+			user: &models.User{Email: "ci@example.com", Roles: all, Metadata: map[string]string{"service_account": "true"}},
+			allowed: func(perm Permission) bool {
+				return !isTeamManagement(perm)
+			},
+		},
+		{
+			name: "external collaborator never manages the team or store",
+			user: &models.User{Email: "c@partner.com", Roles: all, External: true, ExpiresAt: time.Now().Add(time.Hour)},
+			allowed: func(perm Permission) bool {
+				return !isTeamManagement(perm) && !isStoreManagement(perm)
+			},
+		},
+		{
+			name:    "member without roles has nothing",
+			user:    &models.User{Email: "n@example.com"},
+			allowed: func(Permission) bool { return false },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, perm := range AllPermissions() {
+				d := engine.Explain(tt.user, perm)
+				if want := tt.allowed(perm); d.Allowed != want {
+					t.Errorf("%s: allowed = %v, want %v (%s)", perm, d.Allowed, want, d.Reason)
+				}
+			}
+		})
+	}
+}
+
+func TestExplainRejectsUnknownInput(t *testing.T) {
+	engine := NewEngine(nil)
+	if d := engine.Explain(nil, PermCredentialsRead); d.Allowed || d.Reason == "" {
+		t.Errorf("nil user: %+v", d)
+	}
+	admin := &models.User{Email: "a@example.com", Roles: []models.Role{models.RoleAdmin}}
+	if d := engine.Explain(admin, "credentials:delete"); d.Allowed || d.Reason == "" {
+		t.Errorf("unknown permission: %+v", d)
+	}
+}
+
+func TestExplainProjectOwners(t *testing.T) {
+	engine := NewEngine(nil)
+	owners := []string{"owner@example.com"}
+
+	dev := &models.User{Email: "owner@example.com", Roles: []models.Role{models.RoleDev}}
+	if d := engine.ExplainProject(dev, PermProjectManage, "web", owners); !d.Allowed {
+		t.Errorf("owner denied project:manage: %s", d.Reason)
+	}
+	if d := engine.ExplainProject(dev, PermProjectDelete, "web", owners); d.Allowed {
+		t.Error("owner allowed project:delete")
+	}
+
+	other := &models.User{Email: "other@example.com", Roles: []models.Role{models.RoleDev}}
+	if d := engine.ExplainProject(other, PermProjectManage, "web", owners); d.Allowed {
+		t.Error("non-owner allowed project:manage")
+	}
+
+	viewer := &models.User{Email: "owner@example.com", Roles: []models.Role{models.RoleViewer}}
+	if d := engine.ExplainProject(viewer, PermProjectManage, "web", owners); d.Allowed {
+		t.Error("viewer owner allowed project:manage")
+	}
+}
@@ -1,13 +1,18 @@
 package reencrypt
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"passbook/internal/backend/crypto/age"
+	"passbook/internal/fingerprint"
 )
 
 // Stats holds re-encryption statistics
@@ -16,35 +21,82 @@ type Stats struct {
 	SuccessfulFiles int
 	FailedFiles     int
 	SkippedFiles    int
+	UnchangedFiles  int // Already encrypted for the recipients
 	Errors          []string
 }
 
+// SecretDirs are the store directories whose .age files are encrypted for
+// the team. Personal credentials are left out; they stay encrypted for their
+// owner only.
+var SecretDirs = []string{"credentials", "projects", "archive", "notes", "ssh", "certs"}
+
+// maxPolicyDocument is the most of a decrypted secret read into memory to
+// work out who may read it. The rest is streamed.
+const maxPolicyDocument = 4 << 20 // 4 MiB
+
+// Policy decides who each secret is encrypted for
+type Policy interface {
+	// SecretRecipients returns the keys the secret at path, relative to the
+	// store and slash-separated, should be encrypted for. contents returns
+	// the decrypted secret, and is only called when who may read it is
+	// recorded inside.
+	SecretRecipients(path string, contents func() ([]byte, error)) ([]string, error)
+
+	// TeamFingerprint identifies the team recipients are worked out from,
+	// so files written for the same team needn't be decrypted again
+	TeamFingerprint() string
+}
+
 // ReEncryptor handles re-encryption of secrets
 type ReEncryptor struct {
 	storePath string
 	crypto    *age.Age
+	progress  func(done, total int)
+	skip      func(path string) bool
+	index     *fingerprint.Index
+	done      int
+	total     int
 }
 
-// NewReEncryptor creates a new re-encryptor
+// NewReEncryptor creates a new re-encryptor. Files the store's fingerprint
+// index shows are already encrypted for the recipients are left alone.
 func NewReEncryptor(storePath string, crypto *age.Age) *ReEncryptor {
 	return &ReEncryptor{
 		storePath: storePath,
 		crypto:    crypto,
+		index:     fingerprint.Load(storePath),
 	}
 }
 
-// ReEncryptAll re-encrypts all secrets with the new recipient list
-func (r *ReEncryptor) ReEncryptAll(ctx context.Context, newRecipients []string) (*Stats, error) {
+// OnProgress registers fn to be called after each file of ReEncryptAll is
+// processed, with the number of files done and the total
+func (r *ReEncryptor) OnProgress(fn func(done, total int)) {
+	r.progress = fn
+}
+
+// Skip registers fn to decide which files ReEncryptAll leaves untouched.
+// Skipped files are counted in Stats.SkippedFiles.
+func (r *ReEncryptor) Skip(fn func(path string) bool) {
+	r.skip = fn
+}
+
+// ReEncryptAll re-encrypts every secret for the recipients the policy gives
+// it. It stops between files when ctx is cancelled.
+func (r *ReEncryptor) ReEncryptAll(ctx context.Context, policy Policy) (*Stats, error) {
 	stats := &Stats{}
 
-	// Find all .age files in credentials/ and projects/
-	dirs := []string{
-		filepath.Join(r.storePath, "credentials"),
-		filepath.Join(r.storePath, "projects"),
+	r.done, r.total = 0, 0
+	if r.progress != nil {
+		files, err := r.GetAllAgeFiles()
+		if err != nil {
+			return stats, err
+		}
+		r.total = len(files)
+		r.progress(0, r.total)
 	}
 
-	for _, dir := range dirs {
-		if err := r.reEncryptDir(ctx, dir, newRecipients, stats); err != nil {
+	for _, dir := range SecretDirs {
+		if err := r.reEncryptDir(ctx, filepath.Join(r.storePath, dir), policy, stats); err != nil {
 			return stats, err
 		}
 	}
@@ -53,33 +105,41 @@ func (r *ReEncryptor) ReEncryptAll(ctx context.Context, newRecipients []string)
 }
 
 // ReEncryptCredentials re-encrypts only credential files
-func (r *ReEncryptor) ReEncryptCredentials(ctx context.Context, newRecipients []string) (*Stats, error) {
+func (r *ReEncryptor) ReEncryptCredentials(ctx context.Context, policy Policy) (*Stats, error) {
 	stats := &Stats{}
 	dir := filepath.Join(r.storePath, "credentials")
-	if err := r.reEncryptDir(ctx, dir, newRecipients, stats); err != nil {
+	if err := r.reEncryptDir(ctx, dir, policy, stats); err != nil {
 		return stats, err
 	}
 	return stats, nil
 }
 
 // ReEncryptProjects re-encrypts only project/env files
-func (r *ReEncryptor) ReEncryptProjects(ctx context.Context, newRecipients []string) (*Stats, error) {
+func (r *ReEncryptor) ReEncryptProjects(ctx context.Context, policy Policy) (*Stats, error) {
 	stats := &Stats{}
 	dir := filepath.Join(r.storePath, "projects")
-	if err := r.reEncryptDir(ctx, dir, newRecipients, stats); err != nil {
+	if err := r.reEncryptDir(ctx, dir, policy, stats); err != nil {
 		return stats, err
 	}
 	return stats, nil
 }
 
 // reEncryptDir recursively re-encrypts all .age files in a directory
-func (r *ReEncryptor) reEncryptDir(ctx context.Context, dir string, recipients []string, stats *Stats) error {
+func (r *ReEncryptor) reEncryptDir(ctx context.Context, dir string, policy Policy, stats *Stats) error {
 	// Check if directory exists
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
 		return nil // Directory doesn't exist, nothing to re-encrypt
 	}
 
+	// The index only saves work later, so failing to update it isn't an
+	// error
+	defer r.index.Save()
+	team := policy.TeamFingerprint()
+
 	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		if err != nil {
 			stats.Errors = append(stats.Errors, fmt.Sprintf("walk error at %s: %v", path, err))
 			return nil // Continue walking
@@ -96,67 +156,157 @@ func (r *ReEncryptor) reEncryptDir(ctx context.Context, dir string, recipients [
 		}
 
 		stats.TotalFiles++
+		defer r.advance()
+
+		if r.skip != nil && r.skip(path) {
+			stats.SkippedFiles++
+			return nil
+		}
 
 		// Re-encrypt the file
-		if err := r.reEncryptFile(ctx, path, recipients); err != nil {
+		changed, err := r.reEncryptFile(ctx, path, policy, team)
+		if err != nil {
 			stats.FailedFiles++
 			stats.Errors = append(stats.Errors, fmt.Sprintf("failed to re-encrypt %s: %v", path, err))
 			return nil // Continue with other files
 		}
 
-		stats.SuccessfulFiles++
+		if changed {
+			stats.SuccessfulFiles++
+		} else {
+			stats.UnchangedFiles++
+		}
 		return nil
 	})
 }
 
-// reEncryptFile decrypts and re-encrypts a single file
-func (r *ReEncryptor) reEncryptFile(ctx context.Context, path string, recipients []string) error {
-	// Read encrypted file
-	ciphertext, err := os.ReadFile(path)
+// advance reports one more processed file to the progress callback
+func (r *ReEncryptor) advance() {
+	r.done++
+	if r.progress != nil && r.total > 0 {
+		r.progress(r.done, r.total)
+	}
+}
+
+// reEncryptFile re-encrypts a single file for the recipients the policy
+// gives it, without holding it in memory. A file the index shows was written
+// for the same team is left alone before anything is decrypted. Otherwise
+// the decrypted secret is piped straight into the new encryption, which is
+// written to a temporary file beside the original and only replaces it once
+// complete; just enough of it is read first for the policy when who may read
+// the secret is recorded inside. It reports false, without touching the
+// file, if the file is already encrypted for its recipients.
+func (r *ReEncryptor) reEncryptFile(ctx context.Context, path string, policy Policy, team string) (bool, error) {
+	rel, err := filepath.Rel(r.storePath, path)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return false, err
 	}
+	rel = filepath.ToSlash(rel)
 
-	// Decrypt
-	plaintext, err := r.crypto.Decrypt(ctx, ciphertext)
+	sum, err := fingerprint.HashFile(path)
 	if err != nil {
-		return fmt.Errorf("failed to decrypt: %w", err)
+		return false, fmt.Errorf("failed to read file: %w", err)
+	}
+	if r.index.Current(rel, sum, team) {
+		return false, nil
 	}
 
-	// Re-encrypt with new recipients
-	newCiphertext, err := r.crypto.Encrypt(ctx, plaintext, recipients)
+	in, err := os.Open(path)
 	if err != nil {
-		// Zero out plaintext before returning
-		age.ZeroBytes(plaintext)
-		return fmt.Errorf("failed to encrypt: %w", err)
+		return false, fmt.Errorf("failed to read file: %w", err)
 	}
+	defer in.Close()
 
-	// Zero out plaintext
-	age.ZeroBytes(plaintext)
+	var decrypted io.Reader
+	decrypt := func() error {
+		if decrypted != nil {
+			return nil
+		}
+		d, err := r.crypto.DecryptStream(ctx, in)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt: %w", err)
+		}
+		decrypted = d
+		return nil
+	}
+	var head []byte
+	defer func() { age.ZeroBytes(head) }()
+	contents := func() ([]byte, error) {
+		if head != nil {
+			return head, nil
+		}
+		if err := decrypt(); err != nil {
+			return nil, err
+		}
+		head, err = io.ReadAll(io.LimitReader(decrypted, maxPolicyDocument+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt: %w", err)
+		}
+		if len(head) > maxPolicyDocument {
+			return nil, fmt.Errorf("larger than %d MiB, too large to read who may decrypt it", maxPolicyDocument>>20)
+		}
+		return head, nil
+	}
+
+	keys, err := policy.SecretRecipients(rel, contents)
+	if err != nil {
+		return false, err
+	}
+	if len(keys) == 0 {
+		return false, fmt.Errorf("no one may read it any more")
+	}
+	if r.index.Matches(rel, sum, keys) {
+		r.index.Record(rel, sum, keys, team)
+		return false, nil
+	}
+	if err := decrypt(); err != nil {
+		return false, err
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(path), ".reencrypt-*")
+	if err != nil {
+		return false, fmt.Errorf("failed to write file: %w", err)
+	}
+	tmp := out.Name()
+	defer os.Remove(tmp)
+
+	// Re-encrypt with new recipients, hashing the result for the index
+	h := sha256.New()
+	src := io.MultiReader(bytes.NewReader(head), decrypted)
+	if err := r.crypto.EncryptStream(ctx, src, io.MultiWriter(out, h), keys); err != nil {
+		out.Close()
+		return false, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return false, fmt.Errorf("failed to write file: %w", err)
+	}
+	in.Close()
 
 	// Write back
-	if err := os.WriteFile(path, newCiphertext, 0600); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+	if err := os.Rename(tmp, path); err != nil {
+		return false, fmt.Errorf("failed to write file: %w", err)
 	}
+	r.index.Record(rel, hex.EncodeToString(h.Sum(nil)), keys, team)
 
-	return nil
+	return true, nil
 }
 
-// ReEncryptFile re-encrypts a single file with new recipients
-func (r *ReEncryptor) ReEncryptFile(ctx context.Context, path string, recipients []string) error {
-	return r.reEncryptFile(ctx, path, recipients)
+// ReEncryptFile re-encrypts a single file for the recipients the policy
+// gives it
+func (r *ReEncryptor) ReEncryptFile(ctx context.Context, path string, policy Policy) error {
+	if _, err := r.reEncryptFile(ctx, path, policy, policy.TeamFingerprint()); err != nil {
+		return err
+	}
+	_ = r.index.Save()
+	return nil
 }
 
 // GetAllAgeFiles returns all .age files in the store
 func (r *ReEncryptor) GetAllAgeFiles() ([]string, error) {
 	var files []string
 
-	dirs := []string{
-		filepath.Join(r.storePath, "credentials"),
-		filepath.Join(r.storePath, "projects"),
-	}
-
-	for _, dir := range dirs {
+	for _, dir := range SecretDirs {
+		dir = filepath.Join(r.storePath, dir)
 		if _, err := os.Stat(dir); os.IsNotExist(err) {
 			continue
 		}
@@ -0,0 +1,152 @@
+package reencrypt
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"passbook/internal/backend/crypto/age"
+)
+
+// testPolicy encrypts every secret for keys, reading the contents of the
+// ones under credentials/ the way the store's policy does
+type testPolicy struct {
+	keys  []string
+	team  string
+	reads int
+}
+
+func (p *testPolicy) SecretRecipients(path string, contents func() ([]byte, error)) ([]string, error) {
+	if filepath.Dir(filepath.Dir(path)) == "credentials" {
+		if _, err := contents(); err != nil {
+			return nil, err
+		}
+		p.reads++
+	}
+	return p.keys, nil
+}
+
+func (p *testPolicy) TeamFingerprint() string { return p.team }
+
+// newIdentity generates an identity and returns it with its public key
+func newIdentity(t *testing.T) (*age.Age, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "identity")
+	publicKey, err := age.GenerateIdentity(path)
+	if err != nil {
+		t.Fatalf("generate identity: %v", err)
+	}
+	crypto, err := age.New(path)
+	if err != nil {
+		t.Fatalf("load identity: %v", err)
+	}
+	return crypto, publicKey
+}
+
+// writeSecret writes plaintext encrypted for keys to rel in the store
+func writeSecret(t *testing.T, crypto *age.Age, storePath, rel string, plaintext []byte, keys []string) string {
+	t.Helper()
+	encrypted, err := crypto.Encrypt(context.Background(), plaintext, keys)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	path := filepath.Join(storePath, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, encrypted, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReEncryptAllStreamsForNewRecipients(t *testing.T) {
+	ctx := context.Background()
+	storePath := t.TempDir()
+	admin, adminKey := newIdentity(t)
+	member, memberKey := newIdentity(t)
+
+	// Larger than the part read for the policy, so the rest is streamed
+	large := bytes.Repeat([]byte("0123456789abcdef"), (maxPolicyDocument+1<<20)/16)
+	notePath := writeSecret(t, admin, storePath, "notes/big.age", large, []string{adminKey})
+	credPath := writeSecret(t, admin, storePath, "credentials/example.com/login.age", []byte("password: x\n"), []string{adminKey})
+
+	policy := &testPolicy{keys: []string{adminKey, memberKey}, team: "team-1"}
+	stats, err := NewReEncryptor(storePath, admin).ReEncryptAll(ctx, policy)
+	if err != nil {
+		t.Fatalf("ReEncryptAll: %v", err)
+	}
+	if stats.SuccessfulFiles != 2 || stats.FailedFiles != 0 {
+		t.Fatalf("stats = %+v, want 2 re-encrypted", stats)
+	}
+
+	for path, want := range map[string][]byte{notePath: large, credPath: []byte("password: x\n")} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := member.Decrypt(ctx, data)
+		if err != nil {
+			t.Fatalf("new recipient can't decrypt %s: %v", path, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s changed content when re-encrypted", path)
+		}
+	}
+}
+
+func TestReEncryptAllSkipsCurrentFilesWithoutDecrypting(t *testing.T) {
+	ctx := context.Background()
+	storePath := t.TempDir()
+	admin, adminKey := newIdentity(t)
+	_, memberKey := newIdentity(t)
+
+	writeSecret(t, admin, storePath, "credentials/example.com/login.age", []byte("password: x\n"), []string{adminKey})
+	policy := &testPolicy{keys: []string{adminKey, memberKey}, team: "team-1"}
+	if _, err := NewReEncryptor(storePath, admin).ReEncryptAll(ctx, policy); err != nil {
+		t.Fatalf("ReEncryptAll: %v", err)
+	}
+
+	// With no identity any decryption would fail the file
+	policy.reads = 0
+	stats, err := NewReEncryptor(storePath, age.NewWithoutIdentity()).ReEncryptAll(ctx, policy)
+	if err != nil {
+		t.Fatalf("ReEncryptAll: %v", err)
+	}
+	if stats.UnchangedFiles != 1 || stats.FailedFiles != 0 || policy.reads != 0 {
+		t.Errorf("stats = %+v with %d reads, want the file left alone undecrypted", stats, policy.reads)
+	}
+
+	// A changed team means decrypting to find out the recipients
+	policy.team = "team-2"
+	stats, err = NewReEncryptor(storePath, admin).ReEncryptAll(ctx, policy)
+	if err != nil {
+		t.Fatalf("ReEncryptAll: %v", err)
+	}
+	if stats.UnchangedFiles != 1 || policy.reads != 1 {
+		t.Errorf("stats = %+v with %d reads, want the file read and left alone", stats, policy.reads)
+	}
+}
+
+func TestReEncryptAllRefusesOversizedPolicyDocuments(t *testing.T) {
+	ctx := context.Background()
+	storePath := t.TempDir()
+	admin, adminKey := newIdentity(t)
+
+	large := bytes.Repeat([]byte("x"), maxPolicyDocument+1)
+	path := writeSecret(t, admin, storePath, "credentials/example.com/huge.age", large, []string{adminKey})
+	before, _ := os.ReadFile(path)
+
+	stats, err := NewReEncryptor(storePath, admin).ReEncryptAll(ctx, &testPolicy{keys: []string{adminKey}, team: "team-1"})
+	if err != nil {
+		t.Fatalf("ReEncryptAll: %v", err)
+	}
+	if stats.FailedFiles != 1 {
+		t.Errorf("stats = %+v, want the file to fail", stats)
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(before, after) {
+		t.Error("oversized file was rewritten")
+	}
+}
@@ -0,0 +1,113 @@
+package scan
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// sarifVersion is the SARIF schema version written by WriteSARIF
+const sarifVersion = "2.1.0"
+
+// ruleDescriptions describes each rule for SARIF consumers
+var ruleDescriptions = map[string]string{
+	RuleDotEnv:      "Plaintext .env file committed to the repository",
+	RulePrivateKey:  "Private key material in plaintext",
+	RuleHighEntropy: "High-entropy string that may be a hard-coded secret",
+	RuleKnownSecret: "Value that is stored as a secret in passbook",
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifact `json:"artifactLocation"`
+	Region           sarifRegion   `json:"region"`
+}
+
+type sarifArtifact struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// WriteSARIF writes findings as a SARIF log for CI annotation
+func WriteSARIF(w io.Writer, findings []Finding) error {
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: "passbook"}},
+		Results: []sarifResult{},
+	}
+
+	for _, id := range []string{RuleDotEnv, RulePrivateKey, RuleHighEntropy, RuleKnownSecret} {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:               id,
+			ShortDescription: sarifMessage{Text: ruleDescriptions[id]},
+		})
+	}
+
+	for _, f := range findings {
+		level := "warning"
+		if f.Rule == RuleKnownSecret || f.Rule == RulePrivateKey {
+			level = "error"
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:  f.Rule,
+			Level:   level,
+			Message: sarifMessage{Text: ruleDescriptions[f.Rule] + ": " + f.Match},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifact{URI: f.Path},
+					Region:           sarifRegion{StartLine: f.Line},
+				},
+			}},
+		})
+	}
+
+	log := sarifLog{
+		Version: sarifVersion,
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
@@ -0,0 +1,153 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// RuleDotEnv flags plaintext .env files
+	RuleDotEnv = "plaintext-env-file"
+
+	// RulePrivateKey flags private key material
+	RulePrivateKey = "private-key"
+
+	// RuleHighEntropy flags long random-looking strings
+	RuleHighEntropy = "high-entropy-string"
+
+	// RuleKnownSecret flags values that exist in the passbook store
+	RuleKnownSecret = "known-secret"
+
+	// Minimum length for a known value to be matched (shorter values cause noise)
+	minKnownValueLen = 8
+
+	// Minimum token length and Shannon entropy (bits per char) for the high-entropy rule
+	minEntropyTokenLen = 20
+	minEntropy         = 4.2
+)
+
+var (
+	// privateKeyPattern matches age secret keys and PEM private key headers
+	privateKeyPattern = regexp.MustCompile(`AGE-SECRET-KEY-1[0-9A-Z]+|-----BEGIN [A-Z ]*PRIVATE KEY( BLOCK)?-----|-----BEGIN PASSBOOK ENCRYPTED KEY-----`)
+
+	// tokenPattern matches candidate tokens for entropy analysis
+	tokenPattern = regexp.MustCompile(`[A-Za-z0-9+/=_\-]{20,}`)
+)
+
+// Finding is a potential secret found in a file
+type Finding struct {
+	Path  string
+	Line  int
+	Rule  string
+	Match string
+}
+
+// Options configures which rules run
+type Options struct {
+	// SkipEntropy disables the high-entropy rule
+	SkipEntropy bool
+
+	// Known maps secret values to a label describing where they are stored
+	Known map[string]string
+}
+
+// IsDotEnvFile reports whether a file name looks like a plaintext env file
+func IsDotEnvFile(path string) bool {
+	name := filepath.Base(path)
+	if name == ".env" {
+		return true
+	}
+	if !strings.HasPrefix(name, ".env.") {
+		return strings.HasSuffix(name, ".env")
+	}
+	// Example and template files are meant to be committed
+	switch strings.TrimPrefix(name, ".env.") {
+	case "example", "sample", "template", "dist":
+		return false
+	}
+	return true
+}
+
+// Content scans file content for secrets
+func Content(path string, data []byte, opts Options) []Finding {
+	var findings []Finding
+
+	if IsDotEnvFile(path) {
+		findings = append(findings, Finding{Path: path, Line: 1, Rule: RuleDotEnv, Match: filepath.Base(path)})
+	}
+
+	// Binary files can't be meaningfully scanned line by line
+	if bytes.IndexByte(data, 0) >= 0 {
+		return findings
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+
+		if m := privateKeyPattern.FindString(line); m != "" {
+			findings = append(findings, Finding{Path: path, Line: lineNum, Rule: RulePrivateKey, Match: Redact(m)})
+			continue
+		}
+
+		// Known values take priority over the generic entropy rule
+		known := false
+		for value, label := range opts.Known {
+			if len(value) >= minKnownValueLen && strings.Contains(line, value) {
+				findings = append(findings, Finding{Path: path, Line: lineNum, Rule: RuleKnownSecret, Match: label})
+				known = true
+			}
+		}
+		if known {
+			continue
+		}
+
+		if opts.SkipEntropy {
+			continue
+		}
+		for _, token := range tokenPattern.FindAllString(line, -1) {
+			// Public keys are not secret
+			if strings.HasPrefix(token, "age1") {
+				continue
+			}
+			if len(token) >= minEntropyTokenLen && Entropy(token) >= minEntropy {
+				findings = append(findings, Finding{Path: path, Line: lineNum, Rule: RuleHighEntropy, Match: Redact(token)})
+			}
+		}
+	}
+
+	return findings
+}
+
+// Entropy returns the Shannon entropy of s in bits per character
+func Entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	var entropy float64
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// Redact shortens a matched secret so it can be printed safely
+func Redact(s string) string {
+	if len(s) <= 8 {
+		return strings.Repeat("*", len(s))
+	}
+	return s[:4] + strings.Repeat("*", 4) + s[len(s)-2:]
+}
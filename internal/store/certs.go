@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/models"
+	"passbook/pkg/redact"
+)
+
+const (
+	certsDir = "certs"
+)
+
+// ListCertificates returns the certificates the local identity can decrypt
+func (s *Store) ListCertificates(ctx context.Context) ([]*models.Certificate, error) {
+	files, err := s.storage.List(ctx, certsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*models.Certificate
+	for _, file := range files {
+		if !strings.HasSuffix(file, age.Ext) {
+			continue
+		}
+		cert, err := s.loadCertificate(ctx, file)
+		if err != nil {
+			continue // Skip files we can't decrypt
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// GetCertificate returns a certificate by name
+func (s *Store) GetCertificate(ctx context.Context, name string) (*models.Certificate, error) {
+	path := (&models.Certificate{Name: name}).FullPath()
+	if !s.storage.Exists(ctx, path) {
+		return nil, ErrNotFound
+	}
+	return s.loadCertificate(ctx, path)
+}
+
+// CreateCertificate encrypts a new certificate for the team and writes it
+func (s *Store) CreateCertificate(ctx context.Context, cert *models.Certificate, createdBy string) error {
+	if cert.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidInput)
+	}
+	if s.storage.Exists(ctx, cert.FullPath()) {
+		return ErrAlreadyExists
+	}
+
+	if cert.ID == "" {
+		cert.ID = uuid.New().String()
+	}
+	cert.Version = models.CertificateVersion
+	cert.CreatedBy = createdBy
+	cert.CreatedAt = time.Now()
+	cert.UpdatedAt = time.Now()
+
+	recipients, err := s.humanRecipients()
+	if err != nil {
+		return err
+	}
+	data, err := models.MarshalCanonical(cert)
+	if err != nil {
+		return err
+	}
+	return s.writeSecret(ctx, cert.FullPath(), data, recipients)
+}
+
+// DeleteCertificate removes a certificate
+func (s *Store) DeleteCertificate(ctx context.Context, name string) error {
+	path := (&models.Certificate{Name: name}).FullPath()
+	if !s.storage.Exists(ctx, path) {
+		return ErrNotFound
+	}
+	return s.storage.Delete(ctx, path)
+}
+
+// loadCertificate loads and decrypts a certificate
+func (s *Store) loadCertificate(ctx context.Context, path string) (*models.Certificate, error) {
+	data, err := s.storage.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := s.decrypt(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var cert models.Certificate
+	if err := yaml.Unmarshal(plaintext, &cert); err != nil {
+		return nil, parseError("certificate", err)
+	}
+	redact.Register(cert.PrivateKey)
+	if cert.Version > models.CertificateVersion {
+		return nil, fmt.Errorf("%s is version %d, %w", path, cert.Version, ErrNewerVersion)
+	}
+	return &cert, nil
+}
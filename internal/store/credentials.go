@@ -12,6 +12,7 @@ import (
 
 	"passbook/internal/backend/crypto/age"
 	"passbook/internal/models"
+	"passbook/pkg/redact"
 )
 
 const (
@@ -98,7 +99,7 @@ func (s *Store) CreateCredential(ctx context.Context, cred *models.Credential, c
 	cred.UpdatedAt = time.Now()
 
 	// Save
-	return s.saveCredential(ctx, cred)
+	return s.SaveCredential(ctx, cred)
 }
 
 // UpdateCredential updates a credential
@@ -111,7 +112,7 @@ func (s *Store) UpdateCredential(ctx context.Context, cred *models.Credential) e
 
 	cred.UpdatedAt = time.Now()
 
-	return s.saveCredential(ctx, cred)
+	return s.SaveCredential(ctx, cred)
 }
 
 // DeleteCredential removes a credential
@@ -120,6 +121,9 @@ func (s *Store) DeleteCredential(ctx context.Context, website, name string) erro
 	if !s.storage.Exists(ctx, path) {
 		return ErrNotFound
 	}
+	if err := s.CheckWrite(ctx, path); err != nil {
+		return err
+	}
 
 	return s.storage.Delete(ctx, path)
 }
@@ -130,56 +134,65 @@ func (s *Store) loadCredential(ctx context.Context, path string) (*models.Creden
 	if err != nil {
 		return nil, err
 	}
+	return s.parseCredential(ctx, path, data)
+}
 
-	plaintext, err := s.crypto.Decrypt(ctx, data)
+// GetCredentialAtRevision decrypts the credential at path as of a git
+// revision
+func (s *Store) GetCredentialAtRevision(ctx context.Context, rev, path string) (*models.Credential, error) {
+	data, err := s.storage.GetAt(ctx, rev, path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt credential: %w", err)
+		return nil, err
+	}
+	return s.parseCredential(ctx, path, data)
+}
+
+// parseCredential decrypts and decodes the contents of a credential file
+func (s *Store) parseCredential(ctx context.Context, path string, data []byte) (*models.Credential, error) {
+	plaintext, err := s.decrypt(ctx, data)
+	if err != nil {
+		return nil, err
 	}
 
 	var cred models.Credential
 	if err := yaml.Unmarshal(plaintext, &cred); err != nil {
-		return nil, fmt.Errorf("failed to parse credential: %w", err)
+		return nil, parseError("credential", err)
+	}
+	redact.Register(cred.Password)
+	for _, old := range cred.PasswordHistory {
+		redact.Register(old.Password)
+	}
+	if cred.Version > models.CredentialVersion {
+		return nil, fmt.Errorf("%s is version %d, %w", path, cred.Version, ErrNewerVersion)
 	}
 
 	return &cred, nil
 }
 
-// saveCredential encrypts and saves a credential
-func (s *Store) saveCredential(ctx context.Context, cred *models.Credential) error {
-	var keys []string
-	var err error
-
-	// Check if credential has explicit permissions
-	if cred.Permissions != nil && cred.Permissions.Count() > 0 && !cred.Permissions.UseRoleBasedAccess {
-		// Use explicit recipient list (only those who can read)
-		keys = cred.Permissions.GetReadRecipients()
-	} else {
-		// Fall back to all recipients (role-based access)
-		keys, err = s.getAllRecipientKeys()
-		if err != nil {
-			return err
-		}
+// SaveCredential encrypts a credential for its recipients and writes it
+func (s *Store) SaveCredential(ctx context.Context, cred *models.Credential) error {
+	if err := s.CheckWrite(ctx, cred.FullPath()); err != nil {
+		return err
 	}
-
-	if len(keys) == 0 {
-		return fmt.Errorf("no recipients specified for credential")
+	perms, err := s.defaultPermissions(ctx, cred.FullPath(), cred.Permissions)
+	if err != nil {
+		return err
 	}
-
-	// Serialize
-	data, err := yaml.Marshal(cred)
+	cred.Permissions = perms
+	keys, err := s.CredentialRecipients(cred)
 	if err != nil {
 		return err
 	}
 
-	// Encrypt
-	encrypted, err := s.encryptForRecipients(ctx, data, keys)
+	// Serialize
+	cred.Version = models.CredentialVersion
+	data, err := models.MarshalCanonical(cred)
 	if err != nil {
 		return err
 	}
 
-	// Save
-	path := cred.FullPath()
-	return s.storage.Set(ctx, path, encrypted)
+	// Encrypt and save
+	return s.writeSecret(ctx, cred.FullPath(), data, keys)
 }
 
 // AddCredentialRecipient adds a recipient to a credential
@@ -192,7 +205,7 @@ func (s *Store) AddCredentialRecipient(ctx context.Context, website, name, email
 	cred.GetPermissions().AddRecipient(email, publicKey, access)
 	cred.UpdatedAt = time.Now()
 
-	return s.saveCredential(ctx, cred)
+	return s.SaveCredential(ctx, cred)
 }
 
 // RemoveCredentialRecipient removes a recipient from a credential
@@ -207,7 +220,7 @@ func (s *Store) RemoveCredentialRecipient(ctx context.Context, website, name, em
 	}
 	cred.UpdatedAt = time.Now()
 
-	return s.saveCredential(ctx, cred)
+	return s.SaveCredential(ctx, cred)
 }
 
 // ListCredentialRecipients lists recipients for a credential
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"passbook/internal/models"
+)
+
+// newGrantTeam is a team where one member may only read what the admin
+// shares and another may also change it
+func newGrantTeam(t *testing.T) *testTeam {
+	tt := newTestTeam(t)
+	admin := tt.add("admin@example.com", []models.Role{models.RoleAdmin}, nil)
+	tt.add("reader@example.com", []models.Role{models.RoleProdAccess}, nil)
+	tt.add("writer@example.com", []models.Role{models.RoleProdAccess}, nil)
+	tt.save(admin)
+	return tt
+}
+
+// grants shares a secret with the reader read-only and with the writer
+func (tt *testTeam) grants() *models.SecretPermissions {
+	perms := models.NewSecretPermissions()
+	for email, access := range map[string]models.AccessLevel{
+		"admin@example.com":  models.AccessWrite,
+		"reader@example.com": models.AccessRead,
+		"writer@example.com": models.AccessWrite,
+	} {
+		perms.AddRecipient(email, tt.members[email].user.PublicKey, access)
+	}
+	return perms
+}
+
+func TestSaveCredentialNeedsWriteAccess(t *testing.T) {
+	tt := newGrantTeam(t)
+	ctx := context.Background()
+
+	cred := &models.Credential{Website: "example.com", Name: "login", Username: "ops", Password: "first", Permissions: tt.grants()}
+	if err := tt.members["admin@example.com"].store.SaveCredential(ctx, cred); err != nil {
+		t.Fatalf("SaveCredential as admin: %v", err)
+	}
+
+	reader := tt.members["reader@example.com"].store
+	readerCopy, err := reader.GetCredential(ctx, "example.com", "login")
+	if err != nil {
+		t.Fatalf("GetCredential as reader: %v", err)
+	}
+	readerCopy.Password = "changed by reader"
+	if err := reader.SaveCredential(ctx, readerCopy); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("SaveCredential as reader = %v, want ErrAccessDenied", err)
+	}
+	if err := reader.DeleteCredential(ctx, "example.com", "login"); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("DeleteCredential as reader = %v, want ErrAccessDenied", err)
+	}
+
+	writer := tt.members["writer@example.com"].store
+	writerCopy, err := writer.GetCredential(ctx, "example.com", "login")
+	if err != nil {
+		t.Fatalf("GetCredential as writer: %v", err)
+	}
+	if writerCopy.Password != "first" {
+		t.Fatalf("password = %q, the reader's change was saved", writerCopy.Password)
+	}
+	writerCopy.Password = "changed by writer"
+	if err := writer.SaveCredential(ctx, writerCopy); err != nil {
+		t.Errorf("SaveCredential as writer: %v", err)
+	}
+	if err := writer.DeleteCredential(ctx, "example.com", "login"); err != nil {
+		t.Errorf("DeleteCredential as writer: %v", err)
+	}
+}
+
+func TestSaveCredentialNeedsToReadIt(t *testing.T) {
+	tt := newGrantTeam(t)
+	ctx := context.Background()
+
+	perms := models.NewSecretPermissions()
+	perms.AddRecipient("admin@example.com", tt.members["admin@example.com"].user.PublicKey, models.AccessWrite)
+	cred := &models.Credential{Website: "example.com", Name: "root", Password: "first", Permissions: perms}
+	if err := tt.members["admin@example.com"].store.SaveCredential(ctx, cred); err != nil {
+		t.Fatalf("SaveCredential as admin: %v", err)
+	}
+
+	// Someone a secret isn't shared with can't overwrite or delete it
+	writer := tt.members["writer@example.com"].store
+	overwrite := &models.Credential{Website: "example.com", Name: "root", Password: "mine"}
+	if err := writer.SaveCredential(ctx, overwrite); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("SaveCredential = %v, want ErrAccessDenied", err)
+	}
+	if err := writer.DeleteCredential(ctx, "example.com", "root"); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("DeleteCredential = %v, want ErrAccessDenied", err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 	"passbook/internal/backend/crypto/age"
 	"passbook/internal/models"
+	"passbook/pkg/redact"
 )
 
 const (
@@ -23,21 +24,46 @@ func (s *Store) GetEnvFile(ctx context.Context, project string, stage models.Sta
 		return nil, fmt.Errorf("%w: invalid stage", ErrInvalidInput)
 	}
 
-	path := fmt.Sprintf("%s/%s/%s.env%s", projectsDir, project, stage, age.Ext)
+	return s.GetEnvFileAt(ctx, fmt.Sprintf("%s/%s/%s.env%s", projectsDir, project, stage, age.Ext))
+}
 
+// GetEnvFileAt decrypts the env file at path, relative to the store, such
+// as one in a project template or the archive
+func (s *Store) GetEnvFileAt(ctx context.Context, path string) (*models.EnvFile, error) {
 	data, err := s.storage.Get(ctx, path)
 	if err != nil {
-		return nil, ErrNotFound
+		return nil, err
 	}
+	return s.parseEnvFile(ctx, path, data)
+}
 
-	plaintext, err := s.crypto.Decrypt(ctx, data)
+// GetEnvFileAtRevision decrypts the env file at path as of a git revision
+func (s *Store) GetEnvFileAtRevision(ctx context.Context, rev, path string) (*models.EnvFile, error) {
+	data, err := s.storage.GetAt(ctx, rev, path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt env file: %w", err)
+		return nil, err
+	}
+	return s.parseEnvFile(ctx, path, data)
+}
+
+// parseEnvFile decrypts and decodes the contents of an env file
+func (s *Store) parseEnvFile(ctx context.Context, path string, data []byte) (*models.EnvFile, error) {
+	plaintext, err := s.decrypt(ctx, data)
+	if err != nil {
+		return nil, err
 	}
 
 	var envFile models.EnvFile
 	if err := yaml.Unmarshal(plaintext, &envFile); err != nil {
-		return nil, fmt.Errorf("failed to parse env file: %w", err)
+		return nil, parseError("env file", err)
+	}
+	for _, v := range envFile.Vars {
+		if v.IsSecret {
+			redact.Register(v.Value)
+		}
+	}
+	if envFile.Version > models.EnvFileVersion {
+		return nil, fmt.Errorf("%s is version %d, %w", path, envFile.Version, ErrNewerVersion)
 	}
 
 	return &envFile, nil
@@ -62,12 +88,13 @@ func (s *Store) SetEnvVar(ctx context.Context, project string, stage models.Stag
 		}
 	}
 
-	// Update variable
-	envFile.Set(key, value, isSecret)
-	envFile.UpdatedBy = updatedBy
-	envFile.UpdatedAt = time.Now()
+	// Update variable; an unchanged value leaves the file as it was
+	if envFile.Update(key, value, isSecret, updatedBy) {
+		envFile.UpdatedBy = updatedBy
+		envFile.UpdatedAt = time.Now()
+	}
 
-	return s.saveEnvFile(ctx, envFile)
+	return s.SaveEnvFile(ctx, envFile)
 }
 
 // DeleteEnvVar removes an environment variable
@@ -84,7 +111,7 @@ func (s *Store) DeleteEnvVar(ctx context.Context, project string, stage models.S
 	envFile.UpdatedBy = updatedBy
 	envFile.UpdatedAt = time.Now()
 
-	return s.saveEnvFile(ctx, envFile)
+	return s.SaveEnvFile(ctx, envFile)
 }
 
 // ImportEnvFile imports environment variables from parsed data
@@ -99,60 +126,45 @@ func (s *Store) ImportEnvFile(ctx context.Context, project string, stage models.
 		}
 	}
 
-	// Merge variables
+	// Merge variables; unchanged values leave the file as it was
+	changed := false
 	for _, v := range vars {
-		envFile.Set(v.Key, v.Value, v.IsSecret)
+		if envFile.Update(v.Key, v.Value, v.IsSecret, updatedBy) {
+			changed = true
+		}
+	}
+	if changed {
+		envFile.UpdatedBy = updatedBy
+		envFile.UpdatedAt = time.Now()
 	}
 
-	envFile.UpdatedBy = updatedBy
-	envFile.UpdatedAt = time.Now()
-
-	return s.saveEnvFile(ctx, envFile)
+	return s.SaveEnvFile(ctx, envFile)
 }
 
-// saveEnvFile encrypts and saves an env file
-func (s *Store) saveEnvFile(ctx context.Context, envFile *models.EnvFile) error {
-	var keys []string
-	var err error
-
-	// Check if env file has explicit permissions
-	if envFile.Permissions != nil && envFile.Permissions.Count() > 0 && !envFile.Permissions.UseRoleBasedAccess {
-		// Use explicit recipient list (only those who can read)
-		keys = envFile.Permissions.GetReadRecipients()
-	} else {
-		// Fall back to stage-based recipients
-		keys, err = s.getRecipientKeysForStage(envFile.Stage)
-		if err != nil {
-			return err
-		}
-	}
-
-	if len(keys) == 0 {
-		return fmt.Errorf("no recipients specified for env file")
+// SaveEnvFile encrypts an env file for its recipients and writes it
+func (s *Store) SaveEnvFile(ctx context.Context, envFile *models.EnvFile) error {
+	if err := s.CheckWrite(ctx, envFile.FullPath()); err != nil {
+		return err
 	}
-
-	// Serialize
-	data, err := yaml.Marshal(envFile)
+	perms, err := s.defaultPermissions(ctx, envFile.FullPath(), envFile.Permissions)
 	if err != nil {
 		return err
 	}
-
-	// Encrypt
-	encrypted, err := s.encryptForRecipients(ctx, data, keys)
+	envFile.Permissions = perms
+	keys, err := s.EnvRecipients(envFile)
 	if err != nil {
 		return err
 	}
 
-	// Save
-	path := envFile.FullPath()
-	if err := s.storage.Set(ctx, path, encrypted); err != nil {
+	// Serialize
+	envFile.Version = models.EnvFileVersion
+	data, err := models.MarshalCanonical(envFile)
+	if err != nil {
 		return err
 	}
 
-	// Save recipients file (for reference)
-	recipientsPath := envFile.RecipientsPath()
-	recipientsData := s.formatRecipientsFile(keys)
-	return s.storage.Set(ctx, recipientsPath, recipientsData)
+	// Encrypt and save
+	return s.writeSecret(ctx, envFile.FullPath(), data, keys)
 }
 
 // AddEnvRecipient adds a recipient to an env file
@@ -165,7 +177,7 @@ func (s *Store) AddEnvRecipient(ctx context.Context, project string, stage model
 	envFile.GetPermissions().AddRecipient(email, publicKey, access)
 	envFile.UpdatedAt = time.Now()
 
-	return s.saveEnvFile(ctx, envFile)
+	return s.SaveEnvFile(ctx, envFile)
 }
 
 // RemoveEnvRecipient removes a recipient from an env file
@@ -180,7 +192,7 @@ func (s *Store) RemoveEnvRecipient(ctx context.Context, project string, stage mo
 	}
 	envFile.UpdatedAt = time.Now()
 
-	return s.saveEnvFile(ctx, envFile)
+	return s.SaveEnvFile(ctx, envFile)
 }
 
 // ListEnvRecipients lists recipients for an env file
@@ -197,20 +209,6 @@ func (s *Store) ListEnvRecipients(ctx context.Context, project string, stage mod
 	return envFile.Permissions.ListRecipients(), nil
 }
 
-// formatRecipientsFile formats recipient keys for a .recipients file
-func (s *Store) formatRecipientsFile(keys []string) []byte {
-	var lines []string
-	lines = append(lines, "# Stage recipients")
-	lines = append(lines, "# Users who can decrypt this environment")
-	lines = append(lines, "")
-
-	for _, key := range keys {
-		lines = append(lines, key)
-	}
-
-	return []byte(strings.Join(lines, "\n") + "\n")
-}
-
 // ListEnvStages returns available stages for a project
 func (s *Store) ListEnvStages(ctx context.Context, project string) ([]models.Stage, error) {
 	prefix := filepath.Join(projectsDir, project)
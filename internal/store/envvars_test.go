@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"passbook/internal/models"
+)
+
+func TestSaveEnvFileNeedsWriteAccess(t *testing.T) {
+	tt := newGrantTeam(t)
+	ctx := context.Background()
+
+	envFile := &models.EnvFile{
+		Project:     "web",
+		Stage:       models.StageProd,
+		Vars:        []models.EnvVar{{Key: "TOKEN", Value: "first", IsSecret: true}, {Key: "DEBUG", Value: "0"}},
+		Permissions: tt.grants(),
+	}
+	if err := tt.members["admin@example.com"].store.SaveEnvFile(ctx, envFile); err != nil {
+		t.Fatalf("SaveEnvFile as admin: %v", err)
+	}
+
+	reader := tt.members["reader@example.com"].store
+	readerCopy, err := reader.GetEnvFile(ctx, "web", models.StageProd)
+	if err != nil {
+		t.Fatalf("GetEnvFile as reader: %v", err)
+	}
+	readerCopy.Update("TOKEN", "changed by reader", true, "reader@example.com")
+	if err := reader.SaveEnvFile(ctx, readerCopy); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("SaveEnvFile as reader = %v, want ErrAccessDenied", err)
+	}
+	if err := reader.DeleteEnvVar(ctx, "web", models.StageProd, "DEBUG", "reader@example.com"); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("DeleteEnvVar as reader = %v, want ErrAccessDenied", err)
+	}
+
+	writer := tt.members["writer@example.com"].store
+	writerCopy, err := writer.GetEnvFile(ctx, "web", models.StageProd)
+	if err != nil {
+		t.Fatalf("GetEnvFile as writer: %v", err)
+	}
+	if v, _ := writerCopy.Get("TOKEN"); v != "first" {
+		t.Fatalf("TOKEN = %q, the reader's change was saved", v)
+	}
+	writerCopy.Update("TOKEN", "changed by writer", true, "writer@example.com")
+	if err := writer.SaveEnvFile(ctx, writerCopy); err != nil {
+		t.Errorf("SaveEnvFile as writer: %v", err)
+	}
+	if err := writer.DeleteEnvVar(ctx, "web", models.StageProd, "DEBUG", "writer@example.com"); err != nil {
+		t.Errorf("DeleteEnvVar as writer: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/models"
+)
+
+// SecretsMigration reports what MigrateSecrets did
+type SecretsMigration struct {
+	Migrated []string // Files rewritten in the current format
+	Skipped  []string // Files left alone by the skip function
+	Failed   []string // Files that couldn't be read, with the reason
+}
+
+// MigrateSecrets rewrites credentials and env files written before the
+// current format, including archived ones. Files skip returns true for are
+// left alone. Files newer than this version abort the migration.
+func (s *Store) MigrateSecrets(ctx context.Context, skip func(path string) bool) (*SecretsMigration, error) {
+	var files []string
+	for _, dir := range []string{credentialsDir, projectsDir, "archive"} {
+		listed, err := s.storage.List(ctx, dir)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, listed...)
+	}
+
+	result := &SecretsMigration{}
+	for _, path := range files {
+		if !strings.HasSuffix(path, age.Ext) {
+			continue
+		}
+
+		migrated, err := s.migrateSecret(ctx, path, skip)
+		switch {
+		case errors.Is(err, ErrNewerVersion):
+			return nil, fmt.Errorf("%s %w", path, err)
+		case errors.Is(err, errSkipped):
+			result.Skipped = append(result.Skipped, path)
+		case err != nil:
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", path, err))
+		case migrated:
+			result.Migrated = append(result.Migrated, path)
+		}
+	}
+	return result, nil
+}
+
+// errSkipped marks a file the skip function left alone
+var errSkipped = errors.New("skipped")
+
+// migrateSecret rewrites one secret if it predates the current format. It
+// is encrypted for the recipients its current format would get.
+func (s *Store) migrateSecret(ctx context.Context, path string, skip func(string) bool) (bool, error) {
+	data, err := s.storage.Get(ctx, path)
+	if err != nil {
+		return false, err
+	}
+	plaintext, err := s.decrypt(ctx, data)
+	if err != nil {
+		return false, err
+	}
+
+	var version struct {
+		Version int `yaml:"version"`
+	}
+	if err := yaml.Unmarshal(plaintext, &version); err != nil {
+		return false, parseError(path, err)
+	}
+
+	var (
+		current int
+		keys    []string
+		value   interface{}
+	)
+	if strings.HasSuffix(path, ".env"+age.Ext) {
+		var envFile models.EnvFile
+		if err := yaml.Unmarshal(plaintext, &envFile); err != nil {
+			return false, parseError("env file", err)
+		}
+		current = models.EnvFileVersion
+		envFile.Version = current
+		keys, err = s.EnvRecipients(&envFile)
+		value = &envFile
+	} else {
+		var cred models.Credential
+		if err := yaml.Unmarshal(plaintext, &cred); err != nil {
+			return false, parseError("credential", err)
+		}
+		current = models.CredentialVersion
+		cred.Version = current
+		keys, err = s.CredentialRecipients(&cred)
+		value = &cred
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if version.Version > current {
+		return false, ErrNewerVersion
+	}
+	if version.Version == current {
+		return false, nil
+	}
+	if skip != nil && skip(path) {
+		return false, errSkipped
+	}
+
+	out, err := models.MarshalCanonical(value)
+	if err != nil {
+		return false, err
+	}
+	return true, s.writeSecret(ctx, path, out, keys)
+}
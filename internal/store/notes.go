@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/models"
+	"passbook/pkg/redact"
+)
+
+const (
+	notesDir = "notes"
+)
+
+// ListNotes returns the notes the local identity can decrypt
+func (s *Store) ListNotes(ctx context.Context) ([]models.NoteSummary, error) {
+	files, err := s.storage.List(ctx, notesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []models.NoteSummary
+	for _, file := range files {
+		if !strings.HasSuffix(file, age.Ext) {
+			continue
+		}
+		note, err := s.loadNote(ctx, file)
+		if err != nil {
+			continue // Skip files we can't decrypt
+		}
+		summaries = append(summaries, models.NoteSummary{
+			Name:      note.Name,
+			Tags:      note.Tags,
+			Lines:     strings.Count(strings.TrimRight(note.Body, "\n"), "\n") + 1,
+			UpdatedAt: note.UpdatedAt,
+		})
+	}
+	return summaries, nil
+}
+
+// GetNote returns a note by name
+func (s *Store) GetNote(ctx context.Context, name string) (*models.Note, error) {
+	path := (&models.Note{Name: name}).FullPath()
+	if !s.storage.Exists(ctx, path) {
+		return nil, ErrNotFound
+	}
+	return s.loadNote(ctx, path)
+}
+
+// CreateNote creates a new note
+func (s *Store) CreateNote(ctx context.Context, note *models.Note, createdBy string) error {
+	if note.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidInput)
+	}
+	if s.storage.Exists(ctx, note.FullPath()) {
+		return ErrAlreadyExists
+	}
+
+	if note.ID == "" {
+		note.ID = uuid.New().String()
+	}
+	note.CreatedBy = createdBy
+	note.CreatedAt = time.Now()
+	note.UpdatedAt = time.Now()
+
+	return s.SaveNote(ctx, note)
+}
+
+// SaveNote encrypts a note for its recipients and writes it
+func (s *Store) SaveNote(ctx context.Context, note *models.Note) error {
+	keys, err := s.NoteRecipients()
+	if err != nil {
+		return err
+	}
+
+	note.Version = models.NoteVersion
+	data, err := models.MarshalCanonical(note)
+	if err != nil {
+		return err
+	}
+
+	return s.writeSecret(ctx, note.FullPath(), data, keys)
+}
+
+// DeleteNote removes a note
+func (s *Store) DeleteNote(ctx context.Context, name string) error {
+	path := (&models.Note{Name: name}).FullPath()
+	if !s.storage.Exists(ctx, path) {
+		return ErrNotFound
+	}
+	return s.storage.Delete(ctx, path)
+}
+
+// loadNote loads and decrypts a note
+func (s *Store) loadNote(ctx context.Context, path string) (*models.Note, error) {
+	data, err := s.storage.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := s.decrypt(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var note models.Note
+	if err := yaml.Unmarshal(plaintext, &note); err != nil {
+		return nil, parseError("note", err)
+	}
+	redact.Register(note.Body)
+	if note.Version > models.NoteVersion {
+		return nil, fmt.Errorf("%s is version %d, %w", path, note.Version, ErrNewerVersion)
+	}
+	return &note, nil
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/models"
+)
+
+// personalDir holds each member's personal credentials, under their user
+// ID. They are encrypted only for their owner and never re-encrypted for
+// the team.
+const personalDir = "personal"
+
+// personalPath returns where an owner's personal credential is stored
+func personalPath(owner, website, name string) string {
+	return fmt.Sprintf("%s/%s/%s/%s%s", personalDir, owner, website, name, age.Ext)
+}
+
+// ListPersonalCredentials returns the owner's personal credentials
+func (s *Store) ListPersonalCredentials(ctx context.Context, owner string) ([]models.CredentialSummary, error) {
+	files, err := s.storage.List(ctx, personalDir+"/"+owner)
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []models.CredentialSummary
+	for _, file := range files {
+		if !strings.HasSuffix(file, age.Ext) {
+			continue
+		}
+		cred, err := s.loadCredential(ctx, file)
+		if err != nil {
+			continue
+		}
+		summaries = append(summaries, cred.ToSummary())
+	}
+	return summaries, nil
+}
+
+// GetPersonalCredential returns one of the owner's personal credentials
+func (s *Store) GetPersonalCredential(ctx context.Context, owner, website, name string) (*models.Credential, error) {
+	path := personalPath(owner, website, name)
+	if !s.storage.Exists(ctx, path) {
+		return nil, ErrNotFound
+	}
+	return s.loadCredential(ctx, path)
+}
+
+// CreatePersonalCredential adds a personal credential, encrypted only for
+// the local identity
+func (s *Store) CreatePersonalCredential(ctx context.Context, owner string, cred *models.Credential, createdBy string) error {
+	if cred.Website == "" {
+		return fmt.Errorf("%w: website is required", ErrInvalidInput)
+	}
+	if cred.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidInput)
+	}
+
+	path := personalPath(owner, cred.Website, cred.Name)
+	if s.storage.Exists(ctx, path) {
+		return ErrAlreadyExists
+	}
+
+	if cred.ID == "" {
+		cred.ID = uuid.New().String()
+	}
+	cred.CreatedBy = createdBy
+	cred.CreatedAt = time.Now()
+	cred.UpdatedAt = time.Now()
+	cred.Version = models.CredentialVersion
+
+	data, err := models.MarshalCanonical(cred)
+	if err != nil {
+		return err
+	}
+	return s.writeSecret(ctx, path, data, []string{s.crypto.PublicKey()})
+}
+
+// DeletePersonalCredential removes one of the owner's personal credentials
+func (s *Store) DeletePersonalCredential(ctx context.Context, owner, website, name string) error {
+	path := personalPath(owner, website, name)
+	if !s.storage.Exists(ctx, path) {
+		return ErrNotFound
+	}
+	return s.storage.Delete(ctx, path)
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/models"
+	"passbook/pkg/redact"
+)
+
+const (
+	sshDir = "ssh"
+)
+
+// ListSSHKeys returns the SSH keys the local identity can decrypt
+func (s *Store) ListSSHKeys(ctx context.Context) ([]*models.SSHKey, error) {
+	files, err := s.storage.List(ctx, sshDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*models.SSHKey
+	for _, file := range files {
+		if !strings.HasSuffix(file, age.Ext) {
+			continue
+		}
+		key, err := s.loadSSHKey(ctx, file)
+		if err != nil {
+			continue // Skip files we can't decrypt
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// GetSSHKey returns an SSH key by name
+func (s *Store) GetSSHKey(ctx context.Context, name string) (*models.SSHKey, error) {
+	path := (&models.SSHKey{Name: name}).FullPath()
+	if !s.storage.Exists(ctx, path) {
+		return nil, ErrNotFound
+	}
+	return s.loadSSHKey(ctx, path)
+}
+
+// CreateSSHKey encrypts a new SSH key for the team and writes it
+func (s *Store) CreateSSHKey(ctx context.Context, key *models.SSHKey, createdBy string) error {
+	if key.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidInput)
+	}
+	if s.storage.Exists(ctx, key.FullPath()) {
+		return ErrAlreadyExists
+	}
+
+	if key.ID == "" {
+		key.ID = uuid.New().String()
+	}
+	key.Version = models.SSHKeyVersion
+	key.CreatedBy = createdBy
+	key.CreatedAt = time.Now()
+	key.UpdatedAt = time.Now()
+
+	recipients, err := s.humanRecipients()
+	if err != nil {
+		return err
+	}
+	data, err := models.MarshalCanonical(key)
+	if err != nil {
+		return err
+	}
+	return s.writeSecret(ctx, key.FullPath(), data, recipients)
+}
+
+// DeleteSSHKey removes an SSH key
+func (s *Store) DeleteSSHKey(ctx context.Context, name string) error {
+	path := (&models.SSHKey{Name: name}).FullPath()
+	if !s.storage.Exists(ctx, path) {
+		return ErrNotFound
+	}
+	return s.storage.Delete(ctx, path)
+}
+
+// loadSSHKey loads and decrypts an SSH key
+func (s *Store) loadSSHKey(ctx context.Context, path string) (*models.SSHKey, error) {
+	data, err := s.storage.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := s.decrypt(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var key models.SSHKey
+	if err := yaml.Unmarshal(plaintext, &key); err != nil {
+		return nil, parseError("SSH key", err)
+	}
+	redact.Register(key.PrivateKey)
+	if key.Version > models.SSHKeyVersion {
+		return nil, fmt.Errorf("%s is version %d, %w", path, key.Version, ErrNewerVersion)
+	}
+	return &key, nil
+}
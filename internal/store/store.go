@@ -1,15 +1,24 @@
 package store
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
 
 	"passbook/internal/backend/crypto/age"
 	"passbook/internal/backend/storage/gitfs"
 	"passbook/internal/config"
+	"passbook/internal/fingerprint"
 	"passbook/internal/models"
 	"passbook/internal/rbac"
 	"passbook/internal/recipients"
+	"passbook/pkg/redact"
 )
 
 var (
@@ -24,6 +33,12 @@ var (
 
 	// ErrInvalidInput is returned for invalid input
 	ErrInvalidInput = errors.New("invalid input")
+
+	// ErrDecryptFailed is returned when a secret can't be decrypted with the local identity
+	ErrDecryptFailed = errors.New("failed to decrypt")
+
+	// ErrNewerVersion is returned for data written in a format this version doesn't know
+	ErrNewerVersion = errors.New("written by a newer version of passbook; upgrade passbook")
 )
 
 // Store provides access to passbook data
@@ -32,14 +47,22 @@ type Store struct {
 	crypto  *age.Age
 	storage *gitfs.Git
 	rbac    *rbac.Engine
+
+	// usersPlaintext caches the decrypted users file while it still holds
+	// usersCiphertext; a pull or reset that changes the file drops it
+	usersPlaintext  []byte
+	usersCiphertext []byte
+
+	// selfAdded is set by IncludeSelf
+	selfAdded func(path string)
 }
 
 // New creates a new store
 func New(cfg *config.Config) (*Store, error) {
 	// Initialize crypto
-	crypto, err := age.New(cfg.IdentityPath())
+	crypto, err := OpenIdentity(cfg)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to load identity: %w", err)
 	}
 
 	// Initialize storage
@@ -60,6 +83,20 @@ func New(cfg *config.Config) (*Store, error) {
 	return s, nil
 }
 
+// OpenIdentity loads the configured identity: an age key file, or a key on
+// a PKCS#11 token
+func OpenIdentity(cfg *config.Config) (*age.Age, error) {
+	if cfg.Identity.IsHardware() {
+		return age.NewPKCS11(age.PKCS11Options{
+			Module:    cfg.Identity.PKCS11.Module,
+			Token:     cfg.Identity.PKCS11.Token,
+			KeyID:     cfg.Identity.PKCS11.KeyID,
+			PublicKey: cfg.Identity.PublicKey,
+		})
+	}
+	return age.New(cfg.IdentityPath())
+}
+
 // RBAC returns the RBAC engine
 func (s *Store) RBAC() *rbac.Engine {
 	return s.rbac
@@ -109,22 +146,286 @@ func (s *Store) Commit(ctx context.Context, message string) error {
 	return s.storage.Commit(ctx, message)
 }
 
-// encryptForRecipients encrypts data for the given recipients
-func (s *Store) encryptForRecipients(ctx context.Context, data []byte, recipientKeys []string) ([]byte, error) {
-	return s.crypto.Encrypt(ctx, data, recipientKeys)
+// IncludeSelf makes every save also encrypt for the local identity, so it
+// can read back what it writes. widened is called with the path of each
+// secret whose recipients left it out, since that gives it access its
+// permissions don't. Without IncludeSelf, secrets are encrypted for exactly
+// their recipients.
+func (s *Store) IncludeSelf(widened func(path string)) {
+	s.selfAdded = widened
+}
+
+// writeSecret encrypts data for the given recipients, and the local
+// identity if IncludeSelf asked for it, and writes it to path. A file that
+// already holds data for the same recipients is left alone, so a save that
+// changes nothing doesn't produce a new ciphertext to commit.
+func (s *Store) writeSecret(ctx context.Context, path string, data []byte, recipientKeys []string) error {
+	added := false
+	if s.selfAdded != nil {
+		recipientKeys, added = s.crypto.WithSelf(recipientKeys)
+	}
+
+	index := fingerprint.Load(s.cfg.StorePath)
+	if s.unchanged(ctx, index, path, data, recipientKeys) {
+		return nil
+	}
+	if added {
+		s.selfAdded(path)
+	}
+
+	encrypted, err := s.crypto.Encrypt(ctx, data, recipientKeys)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt: %w", err)
+	}
+	if err := s.storage.Set(ctx, path, encrypted); err != nil {
+		return err
+	}
+
+	// The index only saves work later, so failing to update it isn't an
+	// error
+	index.Record(path, fingerprint.Hash(encrypted), recipientKeys, s.TeamFingerprint())
+	_ = index.Save()
+	return nil
+}
+
+// unchanged reports whether the file at path is known to be encrypted for
+// recipientKeys and decrypts to data
+func (s *Store) unchanged(ctx context.Context, index *fingerprint.Index, path string, data []byte, recipientKeys []string) bool {
+	existing, err := s.storage.Get(ctx, path)
+	if err != nil || !index.Matches(path, fingerprint.Hash(existing), recipientKeys) {
+		return false
+	}
+	plaintext, err := s.crypto.Decrypt(ctx, existing)
+	if err != nil {
+		return false
+	}
+	defer age.ZeroBytes(plaintext)
+	return bytes.Equal(plaintext, data)
 }
 
 // decrypt decrypts data
 func (s *Store) decrypt(ctx context.Context, data []byte) ([]byte, error) {
-	return s.crypto.Decrypt(ctx, data)
+	plaintext, err := s.crypto.Decrypt(ctx, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecryptFailed, err)
+	}
+	return plaintext, nil
 }
 
-// getRecipientKeysForStage returns public keys for users who can access a stage
-func (s *Store) getRecipientKeysForStage(stage models.Stage) ([]string, error) {
-	return s.rbac.GetStageRecipients(stage)
+// yamlExcerpt matches the excerpts of a document yaml quotes in its errors
+var yamlExcerpt = regexp.MustCompile("`[^`]*`")
+
+// parseError reports that decrypted content failed to parse, without the
+// excerpt of it yaml quotes, which can be a secret
+func parseError(what string, err error) error {
+	msg := err.Error()
+	if redact.Enabled() {
+		msg = yamlExcerpt.ReplaceAllString(msg, "`"+redact.Mask+"`")
+	}
+	return fmt.Errorf("failed to parse %s: %s", what, msg)
+}
+
+// CredentialRecipients returns the keys a credential is encrypted for: its
+// per-secret recipients if it has any, otherwise every member with a key
+// except service accounts, which only receive the stages their roles grant.
+// Members whose access has expired, or who left the team, receive nothing.
+func (s *Store) CredentialRecipients(cred *models.Credential) ([]string, error) {
+	if keys := permissionRecipients(cred.Permissions); keys != nil {
+		return s.memberKeys(keys)
+	}
+	return s.humanRecipients()
 }
 
-// getAllRecipientKeys returns all user public keys
-func (s *Store) getAllRecipientKeys() ([]string, error) {
-	return s.rbac.GetAllRecipients()
+// NoteRecipients returns the keys a note is encrypted for: like credentials
+// without per-secret grants, every member with a key except service accounts
+func (s *Store) NoteRecipients() ([]string, error) {
+	return s.humanRecipients()
+}
+
+// humanRecipients returns the keys of every member except service accounts
+func (s *Store) humanRecipients() ([]string, error) {
+	users, err := s.ListUsers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recipients: %w", err)
+	}
+
+	var keys []string
+	for _, user := range users {
+		if user.PublicKey != "" && !user.IsServiceAccount() && !user.IsExpired() {
+			keys = append(keys, user.PublicKey)
+		}
+	}
+	return keys, nil
+}
+
+// EnvRecipients returns the keys an env file is encrypted for: its
+// per-secret recipients if it has any, otherwise the members whose roles
+// can read its stage
+func (s *Store) EnvRecipients(envFile *models.EnvFile) ([]string, error) {
+	if keys := permissionRecipients(envFile.Permissions); keys != nil {
+		return s.memberKeys(keys)
+	}
+
+	users, err := s.ListUsers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recipients: %w", err)
+	}
+
+	var keys []string
+	for _, user := range users {
+		if user.PublicKey != "" && s.rbac.CanAccessStage(&user, envFile.Stage, false) {
+			keys = append(keys, user.PublicKey)
+		}
+	}
+	return keys, nil
+}
+
+// memberKeys keeps the keys of a secret's per-secret recipients that still
+// belong to a member whose access hasn't expired
+func (s *Store) memberKeys(keys []string) ([]string, error) {
+	users, err := s.ListUsers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recipients: %w", err)
+	}
+	active := make(map[string]bool)
+	for _, user := range users {
+		if user.PublicKey != "" && !user.IsExpired() {
+			active[user.PublicKey] = true
+		}
+	}
+	kept := []string{}
+	for _, key := range keys {
+		if active[key] {
+			kept = append(kept, key)
+		}
+	}
+	return kept, nil
+}
+
+// SecretRecipients returns the keys the secret at path, relative to the
+// store, should be encrypted for: per-secret grants and stage access for
+// credentials and env files, archived or not, and every member but service
+// accounts for notes, SSH keys and certificates. Only credentials and env
+// files record who may read them, so contents, which returns the decrypted
+// secret, is called for those alone.
+func (s *Store) SecretRecipients(path string, contents func() ([]byte, error)) ([]string, error) {
+	top, _, _ := strings.Cut(path, "/")
+	switch {
+	case strings.HasSuffix(path, ".env"+age.Ext):
+		plaintext, err := contents()
+		if err != nil {
+			return nil, err
+		}
+		var envFile models.EnvFile
+		if err := yaml.Unmarshal(plaintext, &envFile); err != nil {
+			return nil, parseError("env file", err)
+		}
+		return s.EnvRecipients(&envFile)
+	case top == credentialsDir || top == "archive":
+		plaintext, err := contents()
+		if err != nil {
+			return nil, err
+		}
+		var cred models.Credential
+		if err := yaml.Unmarshal(plaintext, &cred); err != nil {
+			return nil, parseError("credential", err)
+		}
+		return s.CredentialRecipients(&cred)
+	case top == "notes":
+		return s.NoteRecipients()
+	default:
+		return s.humanRecipients()
+	}
+}
+
+// TeamFingerprint identifies the team recipients are worked out from: the
+// hash of the users file as it is on disk, or empty if it can't be read
+func (s *Store) TeamFingerprint() string {
+	for _, name := range []string{UsersFile, LegacyUsersFile} {
+		if sum, err := fingerprint.HashFile(filepath.Join(s.cfg.StorePath, name)); err == nil {
+			return sum
+		}
+	}
+	return ""
+}
+
+// permissionRecipients returns the keys named by per-secret permissions, or
+// nil if the secret follows role-based access
+func permissionRecipients(perms *models.SecretPermissions) []string {
+	if perms == nil || perms.UseRoleBasedAccess || perms.Count() == 0 {
+		return nil
+	}
+
+	keys := []string{}
+	for _, key := range perms.GetReadRecipients() {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// CheckWrite refuses changes to the secret at path unless the local identity
+// may write it, going by the per-secret permissions it was last saved with.
+// New secrets and those on role-based access pass, since roles are the
+// caller's to check. A secret we can't decrypt is refused: it has
+// recipients we can't see.
+func (s *Store) CheckWrite(ctx context.Context, path string) error {
+	if !s.storage.Exists(ctx, path) {
+		return nil
+	}
+	data, err := s.storage.Get(ctx, path)
+	if err != nil {
+		return err
+	}
+	plaintext, err := s.decrypt(ctx, data)
+	if err != nil {
+		return fmt.Errorf("%w: %s is not encrypted for you", ErrAccessDenied, path)
+	}
+
+	var saved struct {
+		Permissions *models.SecretPermissions `yaml:"permissions"`
+	}
+	if err := yaml.Unmarshal(plaintext, &saved); err != nil {
+		return parseError(path, err)
+	}
+	perms := saved.Permissions
+	if perms == nil || perms.UseRoleBasedAccess || perms.Count() == 0 {
+		return nil
+	}
+	if access, ok := perms.GetAccessByKey(s.cfg.Identity.PublicKey); ok && access.CanWrite() {
+		return nil
+	}
+	return fmt.Errorf("%w: you have read-only access to %s", ErrAccessDenied, path)
+}
+
+// defaultPermissions returns the permissions a secret at path is saved
+// with: perms, unless the secret is new, has none and the store's default
+// access is explicit, in which case only the local identity and the admins
+// can read it
+func (s *Store) defaultPermissions(ctx context.Context, path string, perms *models.SecretPermissions) (*models.SecretPermissions, error) {
+	if perms != nil || !s.cfg.Access.Explicit() || s.storage.Exists(ctx, path) {
+		return perms, nil
+	}
+
+	users, err := s.ListUsers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recipients: %w", err)
+	}
+	perms = models.NewSecretPermissions()
+	self := s.cfg.Identity.PublicKey
+	for _, user := range users {
+		if user.PublicKey == "" || user.IsExpired() {
+			continue
+		}
+		if user.PublicKey == self || user.IsAdmin() {
+			perms.AddRecipient(user.Email, user.PublicKey, models.AccessWrite)
+		}
+	}
+	if self != "" {
+		if _, ok := perms.GetAccessByKey(self); !ok {
+			perms.AddRecipient(s.cfg.Identity.Email, self, models.AccessWrite)
+		}
+	}
+	return perms, nil
 }
@@ -0,0 +1,221 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/backend/storage/gitfs"
+	"passbook/internal/config"
+	"passbook/internal/models"
+)
+
+// testTeam is a store shared by members who each open it with their own
+// identity
+type testTeam struct {
+	t       *testing.T
+	dir     string
+	members map[string]*testMember
+	users   []models.User
+}
+
+// testMember is one member of a testTeam
+type testMember struct {
+	user  models.User
+	store *Store
+}
+
+// newTestTeam creates an empty store in a temporary git repository
+func newTestTeam(t *testing.T) *testTeam {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "store")
+	if _, err := gitfs.Init(dir, ""); err != nil {
+		t.Fatalf("init store: %v", err)
+	}
+	return &testTeam{t: t, dir: dir, members: make(map[string]*testMember)}
+}
+
+// add gives the team a member with a fresh identity. Members are written to
+// the users file by save.
+func (tt *testTeam) add(email string, roles []models.Role, change func(u *models.User)) *testMember {
+	tt.t.Helper()
+	keyPath := filepath.Join(tt.t.TempDir(), "identity")
+	publicKey, err := age.GenerateIdentity(keyPath)
+	if err != nil {
+		tt.t.Fatalf("generate identity: %v", err)
+	}
+
+	cfg := &config.Config{StorePath: tt.dir}
+	cfg.Identity.Email = email
+	cfg.Identity.PrivateKeyPath = keyPath
+	cfg.Identity.PublicKey = publicKey
+	s, err := New(cfg)
+	if err != nil {
+		tt.t.Fatalf("open store as %s: %v", email, err)
+	}
+
+	user := models.User{ID: email, Email: email, Name: email, PublicKey: publicKey, Roles: roles, CreatedAt: time.Now()}
+	if change != nil {
+		change(&user)
+	}
+	m := &testMember{user: user, store: s}
+	tt.members[email] = m
+	tt.users = append(tt.users, user)
+	return m
+}
+
+// save writes the users file as the given member
+func (tt *testTeam) save(as *testMember) {
+	tt.t.Helper()
+	if err := as.store.writeUsers(context.Background(), tt.users); err != nil {
+		tt.t.Fatalf("write users: %v", err)
+	}
+}
+
+// keys returns the public keys of the named members, sorted
+func (tt *testTeam) keys(emails ...string) []string {
+	var keys []string
+	for _, email := range emails {
+		keys = append(keys, tt.members[email].user.PublicKey)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// sorted returns keys sorted, for comparing recipient sets
+func sorted(keys []string) []string {
+	keys = slices.Clone(keys)
+	slices.Sort(keys)
+	return keys
+}
+
+// newRecipientsTeam is a team with a member of every kind recipients are
+// chosen by
+func newRecipientsTeam(t *testing.T) *testTeam {
+	tt := newTestTeam(t)
+	admin := tt.add("admin@example.com", []models.Role{models.RoleAdmin}, nil)
+	tt.add("dev@example.com", []models.Role{models.RoleDev}, nil)
+	tt.add("prod@example.com", []models.Role{models.RoleProdAccess}, nil)
+	tt.add("ci@example.com", []models.Role{models.RoleProdAccess}, func(u *models.User) {
+		u.Metadata = map[string]string{"service_account": "true"}
+	})
+	tt.add("gone@partner.com", []models.Role{models.RoleProdAccess}, func(u *models.User) {
+		u.External = true
+		u.ExpiresAt = time.Now().Add(-time.Hour)
+	})
+	tt.save(admin)
+	return tt
+}
+
+func TestCredentialRecipientsLeaveOutServiceAccounts(t *testing.T) {
+	tt := newRecipientsTeam(t)
+	s := tt.members["admin@example.com"].store
+
+	keys, err := s.CredentialRecipients(&models.Credential{Website: "example.com", Name: "login"})
+	if err != nil {
+		t.Fatalf("CredentialRecipients: %v", err)
+	}
+	want := tt.keys("admin@example.com", "dev@example.com", "prod@example.com")
+	if got := sorted(keys); !slices.Equal(got, want) {
+		t.Errorf("recipients = %v, want %v", got, want)
+	}
+}
+
+func TestCredentialRecipientsHonorGrants(t *testing.T) {
+	tt := newRecipientsTeam(t)
+	s := tt.members["admin@example.com"].store
+	member := func(email string) models.User { return tt.members[email].user }
+
+	perms := models.NewSecretPermissions()
+	for _, email := range []string{"admin@example.com", "ci@example.com", "gone@partner.com"} {
+		perms.AddRecipient(email, member(email).PublicKey, models.AccessWrite)
+	}
+	perms.AddRecipient("dev@example.com", member("dev@example.com").PublicKey, models.AccessRead)
+	perms.AddRecipientUntil("prod@example.com", member("prod@example.com").PublicKey, models.AccessRead, time.Now().Add(-time.Minute))
+	perms.AddRecipient("left@example.com", "age1notamemberanymore", models.AccessRead)
+
+	keys, err := s.CredentialRecipients(&models.Credential{Website: "example.com", Name: "login", Permissions: perms})
+	if err != nil {
+		t.Fatalf("CredentialRecipients: %v", err)
+	}
+	// Grants name service accounts explicitly; expired grants, expired
+	// members and former members are dropped
+	want := tt.keys("admin@example.com", "ci@example.com", "dev@example.com")
+	if got := sorted(keys); !slices.Equal(got, want) {
+		t.Errorf("recipients = %v, want %v", got, want)
+	}
+
+	// Role-based access set on the secret ignores its grants
+	perms.UseRoleBasedAccess = true
+	keys, err = s.CredentialRecipients(&models.Credential{Website: "example.com", Name: "login", Permissions: perms})
+	if err != nil {
+		t.Fatalf("CredentialRecipients: %v", err)
+	}
+	want = tt.keys("admin@example.com", "dev@example.com", "prod@example.com")
+	if got := sorted(keys); !slices.Equal(got, want) {
+		t.Errorf("role-based recipients = %v, want %v", got, want)
+	}
+}
+
+func TestEnvRecipientsFollowStageAccess(t *testing.T) {
+	tt := newRecipientsTeam(t)
+	s := tt.members["admin@example.com"].store
+
+	tests := []struct {
+		stage models.Stage
+		want  []string
+	}{
+		{models.StageDev, tt.keys("admin@example.com", "dev@example.com", "prod@example.com", "ci@example.com")},
+		{models.StageStaging, tt.keys("admin@example.com", "prod@example.com", "ci@example.com")},
+		{models.StageProd, tt.keys("admin@example.com", "prod@example.com", "ci@example.com")},
+	}
+	for _, test := range tests {
+		keys, err := s.EnvRecipients(&models.EnvFile{Project: "web", Stage: test.stage})
+		if err != nil {
+			t.Fatalf("EnvRecipients(%s): %v", test.stage, err)
+		}
+		if got := sorted(keys); !slices.Equal(got, test.want) {
+			t.Errorf("%s recipients = %v, want %v", test.stage, got, test.want)
+		}
+	}
+}
+
+func TestEnvRecipientsHonorGrants(t *testing.T) {
+	tt := newRecipientsTeam(t)
+	s := tt.members["admin@example.com"].store
+
+	perms := models.NewSecretPermissions()
+	perms.AddRecipient("admin@example.com", tt.members["admin@example.com"].user.PublicKey, models.AccessWrite)
+	perms.AddRecipient("dev@example.com", tt.members["dev@example.com"].user.PublicKey, models.AccessRead)
+
+	// A grant can reach a member whose roles don't cover the stage
+	keys, err := s.EnvRecipients(&models.EnvFile{Project: "web", Stage: models.StageProd, Permissions: perms})
+	if err != nil {
+		t.Fatalf("EnvRecipients: %v", err)
+	}
+	want := tt.keys("admin@example.com", "dev@example.com")
+	if got := sorted(keys); !slices.Equal(got, want) {
+		t.Errorf("recipients = %v, want %v", got, want)
+	}
+}
+
+func TestSavedSecretsAreEncryptedForRecipients(t *testing.T) {
+	tt := newRecipientsTeam(t)
+	ctx := context.Background()
+	admin := tt.members["admin@example.com"].store
+
+	envFile := &models.EnvFile{Project: "web", Stage: models.StageProd, Vars: []models.EnvVar{{Key: "TOKEN", Value: "s3cret", IsSecret: true}}}
+	if err := admin.SaveEnvFile(ctx, envFile); err != nil {
+		t.Fatalf("SaveEnvFile: %v", err)
+	}
+
+	if _, err := tt.members["prod@example.com"].store.GetEnvFile(ctx, "web", models.StageProd); err != nil {
+		t.Errorf("prod-access member can't read prod: %v", err)
+	}
+	if _, err := tt.members["dev@example.com"].store.GetEnvFile(ctx, "web", models.StageProd); err == nil {
+		t.Error("dev member can read prod")
+	}
+}
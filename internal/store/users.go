@@ -1,7 +1,9 @@
 package store
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -14,36 +16,63 @@ import (
 )
 
 const (
-	usersFile = ".passbook-users.age"
+	// UsersFile holds the team, encrypted for every verified member
+	UsersFile = ".passbook-users.age"
+
+	// LegacyUsersFile is the plaintext users file of older stores
+	LegacyUsersFile = ".passbook-users"
+
+	// UsersVersion is the schema version of the users file
+	UsersVersion = 1
 )
 
-// UserList wraps users for serialization
+// ErrLegacyUsers is returned when writing to a store whose users file hasn't
+// been migrated to the encrypted format
+var ErrLegacyUsers = errors.New("the users file is still plaintext; an admin must run 'passbook migrate users'")
+
+// UserList is the users file's contents
 type UserList struct {
-	Users []models.User `yaml:"users"`
+	Version int           `yaml:"version"`
+	Users   []models.User `yaml:"users"`
 }
 
-// ListUsers returns all users
+// ListUsers returns all users. Stores that haven't migrated yet are read
+// from the plaintext file.
 func (s *Store) ListUsers() ([]models.User, error) {
 	ctx := context.Background()
 
-	// Load encrypted users file
-	data, err := s.storage.Get(ctx, usersFile)
-	if err != nil {
-		// Return empty list if file doesn't exist
+	// Read the file every time, since a pull may have changed it, but only
+	// decrypt it again when it has; parse every time so callers can't change
+	// each other's copies
+	var plaintext []byte
+	data, err := s.storage.Get(ctx, UsersFile)
+	switch {
+	case err == nil:
+		if !bytes.Equal(data, s.usersCiphertext) {
+			decrypted, err := s.crypto.Decrypt(ctx, data)
+			if err != nil {
+				return nil, fmt.Errorf("%w: failed to decrypt users; your key may not be verified yet: %w", ErrAccessDenied, err)
+			}
+			s.usersPlaintext, s.usersCiphertext = decrypted, data
+		}
+		plaintext = s.usersPlaintext
+	case s.storage.Exists(ctx, LegacyUsersFile):
+		legacy, err := s.storage.Get(ctx, LegacyUsersFile)
+		if err != nil {
+			return nil, err
+		}
+		plaintext = legacy
+	default:
 		return []models.User{}, nil
 	}
 
-	// Decrypt
-	plaintext, err := s.crypto.Decrypt(ctx, data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt users: %w", err)
-	}
-
-	// Parse
 	var userList UserList
 	if err := yaml.Unmarshal(plaintext, &userList); err != nil {
 		return nil, fmt.Errorf("failed to parse users: %w", err)
 	}
+	if userList.Version > UsersVersion {
+		return nil, fmt.Errorf("users file is version %d, %w", userList.Version, ErrNewerVersion)
+	}
 
 	return userList.Users, nil
 }
@@ -120,7 +149,7 @@ func (s *Store) CreateUser(ctx context.Context, email, name, publicKey string, r
 	users = append(users, user)
 
 	// Save users
-	if err := s.saveUsers(ctx, users); err != nil {
+	if err := s.SaveUsers(ctx, users); err != nil {
 		return nil, err
 	}
 
@@ -157,7 +186,7 @@ func (s *Store) UpdateUser(ctx context.Context, email string, updateFn func(*mod
 		return ErrNotFound
 	}
 
-	return s.saveUsers(ctx, users)
+	return s.SaveUsers(ctx, users)
 }
 
 // DeleteUser removes a user
@@ -182,7 +211,7 @@ func (s *Store) DeleteUser(ctx context.Context, email string) error {
 	}
 
 	// Save users
-	if err := s.saveUsers(ctx, newUsers); err != nil {
+	if err := s.SaveUsers(ctx, newUsers); err != nil {
 		return err
 	}
 
@@ -228,12 +257,44 @@ func (s *Store) RevokeRole(ctx context.Context, email string, role models.Role)
 	})
 }
 
-// saveUsers saves the users file
-func (s *Store) saveUsers(ctx context.Context, users []models.User) error {
-	// Get all recipient keys (everyone can read user list)
-	keys, err := s.getAllRecipientKeys()
+// SaveUsers writes the users file, encrypted for every verified member with
+// a key and for the current user
+func (s *Store) SaveUsers(ctx context.Context, users []models.User) error {
+	if !s.storage.Exists(ctx, UsersFile) && s.storage.Exists(ctx, LegacyUsersFile) {
+		return ErrLegacyUsers
+	}
+	return s.writeUsers(ctx, users)
+}
+
+// MigrateUsers encrypts a legacy plaintext users file and removes it.
+// It returns false if there was nothing to migrate.
+func (s *Store) MigrateUsers(ctx context.Context) (bool, error) {
+	if s.storage.Exists(ctx, UsersFile) || !s.storage.Exists(ctx, LegacyUsersFile) {
+		return false, nil
+	}
+
+	users, err := s.ListUsers()
 	if err != nil {
-		return err
+		return false, err
+	}
+	if err := s.writeUsers(ctx, users); err != nil {
+		return false, err
+	}
+	if err := s.storage.Delete(ctx, LegacyUsersFile); err != nil {
+		return false, fmt.Errorf("failed to remove %s: %w", LegacyUsersFile, err)
+	}
+	return true, nil
+}
+
+// writeUsers encrypts and writes the users file
+func (s *Store) writeUsers(ctx context.Context, users []models.User) error {
+	// Recipients come from the list being saved, so removed members lose
+	// access and new ones gain it in the same write
+	var keys []string
+	for _, u := range users {
+		if u.CanReceiveSecrets() {
+			keys = append(keys, u.PublicKey)
+		}
 	}
 
 	// Add current user's key if not in list
@@ -252,18 +313,17 @@ func (s *Store) saveUsers(ctx context.Context, users []models.User) error {
 	}
 
 	// Serialize
-	userList := UserList{Users: users}
-	data, err := yaml.Marshal(userList)
+	data, err := models.MarshalCanonical(UserList{Version: UsersVersion, Users: users})
 	if err != nil {
 		return err
 	}
 
-	// Encrypt
-	encrypted, err := s.encryptForRecipients(ctx, data, keys)
-	if err != nil {
+	// Encrypt and save
+	if err := s.writeSecret(ctx, UsersFile, data, keys); err != nil {
 		return err
 	}
-
-	// Save
-	return s.storage.Set(ctx, usersFile, encrypted)
+	if written, err := s.storage.Get(ctx, UsersFile); err == nil {
+		s.usersPlaintext, s.usersCiphertext = data, written
+	}
+	return nil
 }
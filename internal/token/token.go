@@ -0,0 +1,330 @@
+// Package token mints time-limited grants of one project stage's variables
+// for CI. A grant is a snapshot of the variables sealed to a fresh key, and
+// the token carries both: nothing redeemable is written to the store. The
+// store only keeps each grant's metadata, committed so every checkout can
+// check a token against it, and redeeming refuses a token that has expired
+// or been revoked there.
+//
+// A token is the variables it carries. Expiry and revocation stop passbook
+// from redeeming it, but can't take back a copy that leaked; rotate the
+// variables of a token that may have.
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"passbook/internal/backend/crypto/age"
+	"passbook/internal/models"
+)
+
+const (
+	// Dir is the store directory holding grant metadata. Stores written by
+	// older versions also hold sealed grants here, which are removed when
+	// their token is revoked.
+	Dir = ".passbook-tokens"
+
+	// IndexFile lists grant metadata (never values) so tokens can be
+	// listed, checked, revoked and pruned
+	IndexFile = "index.yaml"
+
+	// Prefix identifies passbook tokens
+	Prefix = "pbt_"
+
+	// MaxTTL is the longest lifetime a token may have
+	MaxTTL = 7 * 24 * time.Hour
+)
+
+var (
+	// ErrInvalidToken is returned when a token is malformed
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenNotFound is returned when the grant for a token doesn't exist
+	ErrTokenNotFound = errors.New("token not found or revoked")
+	// ErrTokenExpired is returned when a token is past its expiry
+	ErrTokenExpired = errors.New("token has expired")
+	// ErrLegacyToken is returned for tokens whose grant was committed to the
+	// store by an older version
+	ErrLegacyToken = errors.New("token was issued by an older passbook that committed its grant; revoke it and create a new one")
+)
+
+// Info is the non-secret metadata about a grant
+type Info struct {
+	ID             string       `yaml:"id"`
+	Project        string       `yaml:"project"`
+	Stage          models.Stage `yaml:"stage"`
+	ServiceAccount string       `yaml:"service_account,omitempty"`
+	IssuedBy       string       `yaml:"issued_by"`
+	IssuedAt       time.Time    `yaml:"issued_at"`
+	ExpiresAt      time.Time    `yaml:"expires_at"`
+}
+
+// Expired checks if the grant is past its expiry
+func (i *Info) Expired() bool {
+	return time.Now().After(i.ExpiresAt)
+}
+
+// Grant is a sealed, time-limited copy of one project stage's variables
+type Grant struct {
+	Info `yaml:",inline"`
+	Vars []models.EnvVar `yaml:"vars"`
+}
+
+// EnvFile returns the grant's variables as an env file
+func (g *Grant) EnvFile() *models.EnvFile {
+	return &models.EnvFile{
+		Project: g.Project,
+		Stage:   g.Stage,
+		Vars:    g.Vars,
+	}
+}
+
+// index holds metadata for all grants
+type index struct {
+	Tokens []Info `yaml:"tokens"`
+}
+
+// Manager mints and redeems tokens in a store
+type Manager struct {
+	storePath string
+}
+
+// NewManager creates a new token manager
+func NewManager(storePath string) *Manager {
+	return &Manager{storePath: storePath}
+}
+
+// Mint seals a grant to a fresh ephemeral key, records its metadata in the
+// store and returns the token, which holds both the key and the sealed
+// grant. Neither is written anywhere else.
+func (m *Manager) Mint(grant *Grant) (string, error) {
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", fmt.Errorf("failed to generate token id: %w", err)
+	}
+	grant.ID = hex.EncodeToString(idBytes)
+
+	secretKey, publicKey, err := age.GenerateEphemeralIdentity()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := yaml.Marshal(grant)
+	if err != nil {
+		return "", err
+	}
+
+	crypto := age.NewWithoutIdentity()
+	sealed, err := crypto.Encrypt(context.Background(), data, []string{publicKey})
+	age.ZeroBytes(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to seal grant: %w", err)
+	}
+
+	idx, err := m.loadIndex()
+	if err != nil {
+		return "", err
+	}
+	idx.Tokens = append(idx.Tokens, grant.Info)
+	if err := m.saveIndex(idx); err != nil {
+		return "", err
+	}
+
+	return Prefix + grant.ID + "." + secretKey + "." + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Redeem unseals the grant a token carries, failing if the store's index
+// shows it expired or revoked. The expiry and scope recorded in the store
+// are the ones that count, so a holder can't extend them by resealing. A
+// token may also be given as the path of a file holding it.
+func (m *Manager) Redeem(token string) (*Grant, error) {
+	id, secretKey, sealed, err := parse(readTokenFile(token))
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := m.Lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	if info.Expired() {
+		return nil, ErrTokenExpired
+	}
+	if sealed == nil {
+		return nil, ErrLegacyToken
+	}
+
+	crypto, err := age.NewFromSecretKey(secretKey)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	plaintext, err := crypto.Decrypt(context.Background(), sealed)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	defer age.ZeroBytes(plaintext)
+
+	var grant Grant
+	if err := yaml.Unmarshal(plaintext, &grant); err != nil {
+		return nil, fmt.Errorf("failed to parse grant: %w", err)
+	}
+	if grant.ID != id || grant.Project != info.Project || grant.Stage != info.Stage {
+		return nil, ErrInvalidToken
+	}
+	grant.Info = *info
+
+	return &grant, nil
+}
+
+// Lookup returns the metadata of the grant with the given ID, or
+// ErrTokenNotFound if it was never issued or has been revoked
+func (m *Manager) Lookup(id string) (*Info, error) {
+	idx, err := m.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	for i := range idx.Tokens {
+		if idx.Tokens[i].ID == id {
+			return &idx.Tokens[i], nil
+		}
+	}
+	return nil, ErrTokenNotFound
+}
+
+// List returns metadata for all grants
+func (m *Manager) List() ([]Info, error) {
+	idx, err := m.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	return idx.Tokens, nil
+}
+
+// Revoke removes a grant's metadata so its token can no longer be
+// redeemed, along with the sealed grant older versions committed
+func (m *Manager) Revoke(id string) error {
+	idx, err := m.loadIndex()
+	if err != nil {
+		return err
+	}
+
+	var kept []Info
+	found := false
+	for _, info := range idx.Tokens {
+		if info.ID == id {
+			found = true
+			continue
+		}
+		kept = append(kept, info)
+	}
+	if !found {
+		return ErrTokenNotFound
+	}
+
+	if err := os.Remove(filepath.Join(m.storePath, Dir, id+age.Ext)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	idx.Tokens = kept
+	return m.saveIndex(idx)
+}
+
+// PruneExpired revokes the expired grants allowed picks and returns their
+// IDs
+func (m *Manager) PruneExpired(allowed func(Info) bool) ([]string, error) {
+	idx, err := m.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, info := range idx.Tokens {
+		if info.Expired() && allowed(info) {
+			if err := m.Revoke(info.ID); err != nil {
+				return removed, err
+			}
+			removed = append(removed, info.ID)
+		}
+	}
+	return removed, nil
+}
+
+// IDFromToken extracts the grant ID from a token without validating it
+func IDFromToken(token string) string {
+	id, _, _, err := parse(readTokenFile(token))
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+// parse splits a token into grant ID, secret key and sealed grant. Tokens
+// from older versions have no sealed grant.
+func parse(token string) (id, secretKey string, sealed []byte, err error) {
+	token = strings.TrimSpace(token)
+	if !strings.HasPrefix(token, Prefix) {
+		return "", "", nil, ErrInvalidToken
+	}
+	parts := strings.Split(strings.TrimPrefix(token, Prefix), ".")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return "", "", nil, ErrInvalidToken
+	}
+	if len(parts) == 3 {
+		if sealed, err = base64.RawURLEncoding.DecodeString(parts[2]); err != nil || len(sealed) == 0 {
+			return "", "", nil, ErrInvalidToken
+		}
+	}
+	return parts[0], parts[1], sealed, nil
+}
+
+// readTokenFile returns the contents of path if it names a file, else path
+// itself
+func readTokenFile(token string) string {
+	if strings.HasPrefix(strings.TrimSpace(token), Prefix) {
+		return token
+	}
+	data, err := os.ReadFile(token)
+	if err != nil {
+		return token
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// loadIndex loads the grant index
+func (m *Manager) loadIndex() (*index, error) {
+	data, err := os.ReadFile(filepath.Join(m.storePath, Dir, IndexFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &index{}, nil
+		}
+		return nil, err
+	}
+
+	var idx index
+	if err := yaml.Unmarshal(data, &idx); err != nil {
+		return nil, err
+	}
+	return &idx, nil
+}
+
+// saveIndex saves the grant index
+func (m *Manager) saveIndex(idx *index) error {
+	dir := filepath.Join(m.storePath, Dir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, IndexFile), data, 0600)
+}
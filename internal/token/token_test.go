@@ -0,0 +1,183 @@
+package token
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"passbook/internal/models"
+)
+
+// mint issues a token for web/prod that expires after ttl
+func mint(t *testing.T, m *Manager, ttl time.Duration) (string, *Grant) {
+	t.Helper()
+	now := time.Now()
+	grant := &Grant{
+		Info: Info{Project: "web", Stage: models.StageProd, IssuedBy: "admin@example.com", IssuedAt: now, ExpiresAt: now.Add(ttl)},
+		Vars: []models.EnvVar{{Key: "TOKEN", Value: "s3cret", IsSecret: true}},
+	}
+	tok, err := m.Mint(grant)
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+	return tok, grant
+}
+
+func TestRedeem(t *testing.T) {
+	m := NewManager(t.TempDir())
+	tok, grant := mint(t, m, time.Hour)
+
+	redeemed, err := m.Redeem(tok)
+	if err != nil {
+		t.Fatalf("Redeem: %v", err)
+	}
+	if redeemed.ID != grant.ID || len(redeemed.Vars) != 1 || redeemed.Vars[0].Value != "s3cret" {
+		t.Errorf("redeemed %+v, want the minted grant", redeemed)
+	}
+	if IDFromToken(tok) != grant.ID {
+		t.Errorf("IDFromToken = %q, want %q", IDFromToken(tok), grant.ID)
+	}
+
+	// A token can be passed as a file holding it
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte(tok+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Redeem(path); err != nil {
+		t.Errorf("Redeem from file: %v", err)
+	}
+}
+
+func TestMintCommitsNothingRedeemable(t *testing.T) {
+	storePath := t.TempDir()
+	m := NewManager(storePath)
+	mint(t, m, time.Hour)
+
+	entries, err := os.ReadDir(filepath.Join(storePath, Dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != IndexFile {
+			t.Errorf("store holds %s", e.Name())
+		}
+	}
+	index, err := os.ReadFile(filepath.Join(storePath, Dir, IndexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(index), "s3cret") {
+		t.Error("index holds a variable's value")
+	}
+}
+
+func TestRedeemExpired(t *testing.T) {
+	m := NewManager(t.TempDir())
+	tok, _ := mint(t, m, -time.Minute)
+
+	if _, err := m.Redeem(tok); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("Redeem = %v, want ErrTokenExpired", err)
+	}
+}
+
+func TestRedeemUsesTheStoresExpiry(t *testing.T) {
+	m := NewManager(t.TempDir())
+	tok, grant := mint(t, m, time.Hour)
+
+	// Expiring the grant in the store ends the token, whatever it carries
+	idx, err := m.loadIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.Tokens[0].ExpiresAt = time.Now().Add(-time.Second)
+	if err := m.saveIndex(idx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Redeem(tok); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("Redeem = %v, want ErrTokenExpired for %s", err, grant.ID)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	m := NewManager(t.TempDir())
+	tok, grant := mint(t, m, time.Hour)
+	other, _ := mint(t, m, time.Hour)
+
+	if err := m.Revoke(grant.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, err := m.Redeem(tok); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("Redeem revoked = %v, want ErrTokenNotFound", err)
+	}
+	if _, err := m.Redeem(other); err != nil {
+		t.Errorf("Redeem other token: %v", err)
+	}
+	if err := m.Revoke(grant.ID); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("Revoke twice = %v, want ErrTokenNotFound", err)
+	}
+}
+
+func TestPruneExpired(t *testing.T) {
+	m := NewManager(t.TempDir())
+	_, expired := mint(t, m, -time.Minute)
+	_, kept := mint(t, m, -time.Minute)
+	_, active := mint(t, m, time.Hour)
+
+	removed, err := m.PruneExpired(func(info Info) bool { return info.ID != kept.ID })
+	if err != nil {
+		t.Fatalf("PruneExpired: %v", err)
+	}
+	if len(removed) != 1 || removed[0] != expired.ID {
+		t.Errorf("removed %v, want only %s", removed, expired.ID)
+	}
+
+	infos, err := m.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, info := range infos {
+		ids = append(ids, info.ID)
+	}
+	if len(ids) != 2 || ids[0] != kept.ID || ids[1] != active.ID {
+		t.Errorf("left %v, want %s and %s", ids, kept.ID, active.ID)
+	}
+}
+
+func TestRedeemRejectsBadTokens(t *testing.T) {
+	m := NewManager(t.TempDir())
+	tok, _ := mint(t, m, time.Hour)
+	id, key, _, err := parse(tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := mint(t, m, time.Hour)
+	_, _, otherSealed, err := parse(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"no prefix", strings.TrimPrefix(tok, Prefix), ErrInvalidToken},
+		{"missing key", Prefix + id, ErrInvalidToken},
+		{"unknown id", Prefix + "0000000000000000." + key + ".AAAA", ErrTokenNotFound},
+		{"legacy token", Prefix + id + "." + key, ErrLegacyToken},
+		{"another grant under this id", Prefix + id + "." + key + "." + base64.RawURLEncoding.EncodeToString(otherSealed), ErrInvalidToken},
+		{"corrupt grant", tok[:len(tok)-8] + "AAAAAAAA", ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.Redeem(tt.token); !errors.Is(err, tt.want) {
+				t.Errorf("Redeem = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
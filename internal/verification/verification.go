@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
@@ -23,6 +26,17 @@ const (
 	ChallengeTTL = 24 * time.Hour
 	// PendingVerificationsFile is the file storing pending verifications
 	PendingVerificationsFile = ".passbook-pending-verifications"
+	// AttemptsFile records failed attempts in the verifier's own config
+	// directory, not the store, so members who can edit or revert the store
+	// can't reset the count
+	AttemptsFile = "verification-attempts.yaml"
+	// MaxAttempts is how many wrong responses are allowed before lockout
+	MaxAttempts = 5
+	// LockoutDuration is how long verification is blocked after MaxAttempts
+	LockoutDuration = 15 * time.Minute
+
+	// challengeContext prefixes the encrypted payload so it can't be confused with other data
+	challengeContext = "passbook-verify-v1"
 )
 
 var (
@@ -34,18 +48,51 @@ var (
 	ErrChallengeMismatch = errors.New("verification response does not match challenge")
 	// ErrAlreadyVerified is returned when key is already verified
 	ErrAlreadyVerified = errors.New("public key is already verified")
+	// ErrTooManyAttempts is returned while verification is locked out
+	ErrTooManyAttempts = errors.New("too many failed verification attempts")
 )
 
 // PendingVerification represents a pending key ownership verification
 type PendingVerification struct {
 	Email              string    `yaml:"email"`
 	PublicKey          string    `yaml:"public_key"`
-	Challenge          string    `yaml:"challenge"`           // Base64 encoded random bytes
-	EncryptedChallenge string    `yaml:"encrypted_challenge"` // Base64 encoded age-encrypted challenge
+	Challenge          string    `yaml:"challenge,omitempty"` // Legacy: plaintext challenge, readable by anyone with the repo
+	Nonce              string    `yaml:"nonce,omitempty"`
+	ResponseHash       string    `yaml:"response_hash,omitempty"` // SHA-256 of the expected response
+	EncryptedChallenge string    `yaml:"encrypted_challenge"`     // Base64 encoded age-encrypted challenge
 	CreatedAt          time.Time `yaml:"created_at"`
 	ExpiresAt          time.Time `yaml:"expires_at"`
 }
 
+// Attempts counts an email's failed verification attempts
+type Attempts struct {
+	Failed      int       `yaml:"failed,omitempty"`
+	LockedUntil time.Time `yaml:"locked_until,omitempty"`
+}
+
+// IsLocked reports whether verification is currently locked out
+func (at Attempts) IsLocked() bool {
+	return time.Now().Before(at.LockedUntil)
+}
+
+// VerifyError describes a failed verification attempt
+type VerifyError struct {
+	Err         error
+	Attempts    int       // Failed attempts so far
+	LockedUntil time.Time // Set once the email is locked out
+}
+
+func (e *VerifyError) Error() string {
+	if !e.LockedUntil.IsZero() {
+		return fmt.Sprintf("%v (locked until %s)", e.Err, e.LockedUntil.Format("15:04"))
+	}
+	return fmt.Sprintf("%v (%d of %d attempts used)", e.Err, e.Attempts, MaxAttempts)
+}
+
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
 // PendingVerifications holds all pending verifications
 type PendingVerifications struct {
 	Verifications []PendingVerification `yaml:"verifications"`
@@ -54,11 +101,13 @@ type PendingVerifications struct {
 // Verifier handles key ownership verification
 type Verifier struct {
 	storePath string
+	stateDir  string // Holds AttemptsFile
 }
 
-// NewVerifier creates a new verifier
-func NewVerifier(storePath string) *Verifier {
-	return &Verifier{storePath: storePath}
+// NewVerifier creates a verifier for the challenges in a store, keeping
+// failed attempts in stateDir
+func NewVerifier(storePath, stateDir string) *Verifier {
+	return &Verifier{storePath: storePath, stateDir: stateDir}
 }
 
 // CreateChallenge creates a new verification challenge for a public key
@@ -68,27 +117,41 @@ func (v *Verifier) CreateChallenge(email, publicKey string) (*PendingVerificatio
 		return nil, fmt.Errorf("invalid public key format")
 	}
 
-	// Generate random challenge
+	// Generate random challenge and nonce
 	challengeBytes := make([]byte, ChallengeLength)
 	if _, err := rand.Read(challengeBytes); err != nil {
 		return nil, fmt.Errorf("failed to generate challenge: %w", err)
 	}
-	challenge := base64.StdEncoding.EncodeToString(challengeBytes)
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	nonce := hex.EncodeToString(nonceBytes)
+
+	// Bind the challenge to this email and nonce so a response can't be
+	// replayed for another user or a later challenge
+	payload := bindChallenge(email, nonce, challengeBytes)
+	age.ZeroBytes(challengeBytes)
 
 	// Encrypt challenge with the claimed public key
 	// Only the holder of the private key can decrypt this
 	crypto := age.NewWithoutIdentity()
-	encrypted, err := crypto.Encrypt(context.Background(), challengeBytes, []string{publicKey})
+	encrypted, err := crypto.Encrypt(context.Background(), payload, []string{publicKey})
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt challenge: %w", err)
 	}
 	encryptedChallenge := base64.StdEncoding.EncodeToString(encrypted)
 
+	// Only a hash of the expected response is stored in the repository
+	responseHash := hashResponse(base64.StdEncoding.EncodeToString(payload))
+	age.ZeroBytes(payload)
+
 	// Create pending verification
 	pv := &PendingVerification{
 		Email:              email,
 		PublicKey:          publicKey,
-		Challenge:          challenge,
+		Nonce:              nonce,
+		ResponseHash:       responseHash,
 		EncryptedChallenge: encryptedChallenge,
 		CreatedAt:          time.Now(),
 		ExpiresAt:          time.Now().Add(ChallengeTTL),
@@ -125,6 +188,16 @@ func (v *Verifier) VerifyResponse(email, response string) error {
 		return ErrChallengeNotFound
 	}
 
+	// Check lockout
+	attempts, err := v.loadAttempts()
+	if err != nil {
+		return err
+	}
+	at := attempts[email]
+	if at.IsLocked() {
+		return &VerifyError{Err: ErrTooManyAttempts, Attempts: at.Failed, LockedUntil: at.LockedUntil}
+	}
+
 	// Check expiration
 	if time.Now().After(found.ExpiresAt) {
 		// Remove expired challenge
@@ -132,19 +205,96 @@ func (v *Verifier) VerifyResponse(email, response string) error {
 		return ErrChallengeExpired
 	}
 
-	// Compare response with original challenge
-	if response != found.Challenge {
-		return ErrChallengeMismatch
+	// Compare response with the expected one in constant time
+	if !found.matches(response) {
+		at.Failed++
+		verr := &VerifyError{Err: ErrChallengeMismatch, Attempts: at.Failed}
+		if at.Failed >= MaxAttempts {
+			at.LockedUntil = time.Now().Add(LockoutDuration)
+			at.Failed = 0
+			verr.LockedUntil = at.LockedUntil
+		}
+		attempts[email] = at
+		if err := v.saveAttempts(attempts); err != nil {
+			return fmt.Errorf("failed to record attempt: %w", err)
+		}
+		return verr
 	}
 
 	// Verification successful - remove the pending verification
 	if err := v.removePendingVerification(foundIdx); err != nil {
 		return fmt.Errorf("failed to remove pending verification: %w", err)
 	}
+	if _, ok := attempts[email]; ok {
+		delete(attempts, email)
+		if err := v.saveAttempts(attempts); err != nil {
+			return fmt.Errorf("failed to clear attempts: %w", err)
+		}
+	}
 
 	return nil
 }
 
+// Attempts returns the failed attempts recorded for an email
+func (v *Verifier) Attempts(email string) (Attempts, error) {
+	attempts, err := v.loadAttempts()
+	if err != nil {
+		return Attempts{}, err
+	}
+	return attempts[email], nil
+}
+
+// loadAttempts loads the failed attempts file
+func (v *Verifier) loadAttempts() (map[string]Attempts, error) {
+	attempts := make(map[string]Attempts)
+	data, err := os.ReadFile(filepath.Join(v.stateDir, AttemptsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return attempts, nil
+		}
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &attempts); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// saveAttempts saves the failed attempts file
+func (v *Verifier) saveAttempts(attempts map[string]Attempts) error {
+	data, err := yaml.Marshal(attempts)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(v.stateDir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(v.stateDir, AttemptsFile), data, 0600)
+}
+
+// matches checks a response against the stored hash (or a legacy plaintext challenge)
+func (pv *PendingVerification) matches(response string) bool {
+	if pv.ResponseHash != "" {
+		got := hashResponse(response)
+		return subtle.ConstantTimeCompare([]byte(got), []byte(pv.ResponseHash)) == 1
+	}
+	return pv.Challenge != "" && subtle.ConstantTimeCompare([]byte(response), []byte(pv.Challenge)) == 1
+}
+
+// bindChallenge builds the encrypted payload from the email, nonce and random challenge
+func bindChallenge(email, nonce string, challenge []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(challengeContext + "\n" + email + "\n" + nonce + "\n")
+	buf.WriteString(base64.StdEncoding.EncodeToString(challenge))
+	return buf.Bytes()
+}
+
+// hashResponse hashes a response for storage and comparison
+func hashResponse(response string) string {
+	sum := sha256.Sum256([]byte(response))
+	return hex.EncodeToString(sum[:])
+}
+
 // GetPendingVerification returns the pending verification for an email
 func (v *Verifier) GetPendingVerification(email string) (*PendingVerification, error) {
 	pending, err := v.loadPendingVerifications()
@@ -173,23 +323,31 @@ func (v *Verifier) GetEncryptedChallenge(email string) (string, error) {
 	return pv.EncryptedChallenge, nil
 }
 
-// CleanupExpired removes all expired pending verifications
-func (v *Verifier) CleanupExpired() error {
+// CleanupExpired removes all expired pending verifications and returns
+// the emails whose challenges lapsed
+func (v *Verifier) CleanupExpired() ([]string, error) {
 	pending, err := v.loadPendingVerifications()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var active []PendingVerification
+	var expired []string
 	now := time.Now()
 	for _, pv := range pending.Verifications {
 		if now.Before(pv.ExpiresAt) {
 			active = append(active, pv)
+		} else {
+			expired = append(expired, pv.Email)
 		}
 	}
 
+	if len(expired) == 0 {
+		return nil, nil
+	}
+
 	pending.Verifications = active
-	return v.savePendingVerifications(pending)
+	return expired, v.savePendingVerifications(pending)
 }
 
 // loadPendingVerifications loads the pending verifications file
@@ -283,7 +441,7 @@ func DecryptChallenge(identityPath, encryptedChallengeB64 string) (string, error
 // VerifyKeyOwnership is a helper that combines challenge creation and verification
 // Returns the encrypted challenge that needs to be decrypted by the key owner
 func VerifyKeyOwnership(storePath, email, publicKey string) (encryptedChallenge string, err error) {
-	verifier := NewVerifier(storePath)
+	verifier := NewVerifier(storePath, "")
 
 	// Create challenge
 	pv, err := verifier.CreateChallenge(email, publicKey)
@@ -294,9 +452,10 @@ func VerifyKeyOwnership(storePath, email, publicKey string) (encryptedChallenge
 	return pv.EncryptedChallenge, nil
 }
 
-// CompleteVerification completes the verification process
-func CompleteVerification(storePath, email, response string) error {
-	verifier := NewVerifier(storePath)
+// CompleteVerification completes the verification process, keeping failed
+// attempts in stateDir
+func CompleteVerification(storePath, stateDir, email, response string) error {
+	verifier := NewVerifier(storePath, stateDir)
 	return verifier.VerifyResponse(email, response)
 }
 
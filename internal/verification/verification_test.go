@@ -0,0 +1,100 @@
+package verification
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"passbook/internal/backend/crypto/age"
+)
+
+// newChallenge creates a verifier and a challenge for a fresh key, and
+// returns the correct response to it
+func newChallenge(t *testing.T, email string) (*Verifier, string) {
+	t.Helper()
+	identityPath := filepath.Join(t.TempDir(), "identity")
+	publicKey, err := age.GenerateIdentity(identityPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewVerifier(t.TempDir(), t.TempDir())
+	pv, err := v.CreateChallenge(email, publicKey)
+	if err != nil {
+		t.Fatalf("CreateChallenge: %v", err)
+	}
+	response, err := DecryptChallenge(identityPath, pv.EncryptedChallenge)
+	if err != nil {
+		t.Fatalf("DecryptChallenge: %v", err)
+	}
+	return v, response
+}
+
+func TestVerifyResponse(t *testing.T) {
+	v, response := newChallenge(t, "new@example.com")
+
+	if err := v.VerifyResponse("new@example.com", response); err != nil {
+		t.Fatalf("VerifyResponse: %v", err)
+	}
+	if err := v.VerifyResponse("new@example.com", response); !errors.Is(err, ErrChallengeNotFound) {
+		t.Errorf("second VerifyResponse = %v, want ErrChallengeNotFound", err)
+	}
+}
+
+func TestVerifyResponseLocksOut(t *testing.T) {
+	v, response := newChallenge(t, "new@example.com")
+
+	for i := 1; i < MaxAttempts; i++ {
+		err := v.VerifyResponse("new@example.com", "wrong")
+		var verr *VerifyError
+		if !errors.As(err, &verr) || !errors.Is(err, ErrChallengeMismatch) || verr.Attempts != i {
+			t.Fatalf("attempt %d = %v, want a mismatch counting %d", i, err, i)
+		}
+	}
+	var verr *VerifyError
+	if err := v.VerifyResponse("new@example.com", "wrong"); !errors.As(err, &verr) || verr.LockedUntil.IsZero() {
+		t.Fatalf("attempt %d = %v, want a lockout", MaxAttempts, err)
+	}
+
+	// Even the right response is refused while locked out
+	if err := v.VerifyResponse("new@example.com", response); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("VerifyResponse while locked = %v, want ErrTooManyAttempts", err)
+	}
+	if at, err := v.Attempts("new@example.com"); err != nil || !at.IsLocked() {
+		t.Errorf("Attempts = %+v, %v, want locked", at, err)
+	}
+}
+
+func TestLockoutSurvivesStoreEdits(t *testing.T) {
+	v, response := newChallenge(t, "new@example.com")
+	pending, err := os.ReadFile(filepath.Join(v.storePath, PendingVerificationsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < MaxAttempts; i++ {
+		_ = v.VerifyResponse("new@example.com", "wrong")
+	}
+
+	// Reverting the store's file to before the attempts doesn't reset them
+	if err := os.WriteFile(filepath.Join(v.storePath, PendingVerificationsFile), pending, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.VerifyResponse("new@example.com", response); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("VerifyResponse after revert = %v, want ErrTooManyAttempts", err)
+	}
+}
+
+func TestVerifyResponseClearsAttempts(t *testing.T) {
+	v, response := newChallenge(t, "new@example.com")
+
+	if err := v.VerifyResponse("new@example.com", "wrong"); !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("VerifyResponse = %v, want ErrChallengeMismatch", err)
+	}
+	if err := v.VerifyResponse("new@example.com", response); err != nil {
+		t.Fatalf("VerifyResponse: %v", err)
+	}
+	if at, err := v.Attempts("new@example.com"); err != nil || at.Failed != 0 {
+		t.Errorf("Attempts = %+v, %v, want none", at, err)
+	}
+}
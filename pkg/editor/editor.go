@@ -0,0 +1,85 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"passbook/pkg/securetmp"
+)
+
+// ErrNoEditor is returned when no editor is configured or installed
+var ErrNoEditor = errors.New("no editor found; set $EDITOR or run 'passbook config set preferences.editor EDITOR'")
+
+// fallbacks are tried in order when no editor is configured
+var fallbacks = []string{"editor", "nano", "vim", "vi"}
+
+func init() {
+	if runtime.GOOS == "windows" {
+		fallbacks = []string{"notepad"}
+	}
+}
+
+// Command resolves the editor to run: the preferred one, then $VISUAL, then
+// $EDITOR, then the first common editor found on PATH. The result is split
+// into arguments so values like "code --wait" work, unless it names an
+// existing file (e.g. a Windows path with spaces).
+func Command(preferred string) ([]string, error) {
+	for _, candidate := range []string{preferred, os.Getenv("VISUAL"), os.Getenv("EDITOR")} {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return []string{candidate}, nil
+		}
+		if args := strings.Fields(candidate); len(args) > 0 {
+			return args, nil
+		}
+	}
+
+	for _, name := range fallbacks {
+		if path, err := exec.LookPath(name); err == nil {
+			return []string{path}, nil
+		}
+	}
+	return nil, ErrNoEditor
+}
+
+// Edit writes content to a private temp file, opens it in the editor and
+// returns what was saved. The temp file is overwritten before it is removed.
+// suffix (e.g. ".yaml") lets the editor pick syntax highlighting.
+func Edit(preferred string, content []byte, suffix string) ([]byte, error) {
+	args, err := Command(preferred)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := securetmp.Create("secret" + suffix)
+	if err != nil {
+		return nil, err
+	}
+	path := f.Name()
+	defer securetmp.Remove(path)
+
+	_, err = f.Write(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("editor %s failed: %w", args[0], err)
+	}
+
+	edited, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read edited file: %w", err)
+	}
+	return edited, nil
+}
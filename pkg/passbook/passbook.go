@@ -0,0 +1,359 @@
+// Package passbook lets Go programs such as internal tools and bots use a
+// passbook store directly, without the CLI. A Client reads and writes the
+// store with the local identity and enforces the same role-based access as
+// the CLI. It never prints or prompts, so the identity must not be
+// passphrase-protected.
+//
+// Writes are committed to the store's git repository and pushed when the
+// store has autopush enabled; a failed push returns ErrPushFailed. Project
+// schemas are not checked and the integrity manifest is not re-signed; the
+// next admin command does that.
+package passbook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"passbook/internal/audit"
+	"passbook/internal/config"
+	"passbook/internal/models"
+	"passbook/internal/notify"
+	"passbook/internal/rbac"
+	reencrypt_pkg "passbook/internal/reencrypt"
+	"passbook/internal/store"
+)
+
+var (
+	// ErrNotFound is returned when a credential, project or stage doesn't exist
+	ErrNotFound = store.ErrNotFound
+
+	// ErrAccessDenied is returned when the local identity lacks a permission
+	ErrAccessDenied = store.ErrAccessDenied
+
+	// ErrInvalidInput is returned for invalid arguments
+	ErrInvalidInput = store.ErrInvalidInput
+
+	// ErrReadOnly is returned for writes while PASSBOOK_READ_ONLY is set
+	ErrReadOnly = errors.New("read-only: writes are disabled")
+
+	// ErrPushFailed is returned when a write was committed locally but
+	// couldn't be pushed; 'passbook sync' pushes it later
+	ErrPushFailed = errors.New("committed but not pushed")
+
+	// ErrReviewRequired is returned for writes the store requires another
+	// member to approve; propose them with the CLI instead
+	ErrReviewRequired = errors.New("changes to prod need review: propose them with 'passbook env set'")
+)
+
+// holdsFile lists the store paths under legal hold
+const holdsFile = ".passbook-holds"
+
+// Options configures OpenStore. Empty fields fall back to the passbook
+// configuration, as the CLI would use it.
+type Options struct {
+	StorePath    string // Store directory (default: PASSBOOK_STORE or ~/.passbook)
+	IdentityPath string // age identity file (default: from the user config)
+}
+
+// Client accesses one passbook store as one team member
+type Client struct {
+	cfg   *config.Config
+	store *store.Store
+	user  *models.User
+}
+
+// CredentialSummary describes a credential without its secret fields
+type CredentialSummary struct {
+	Website   string
+	Name      string
+	Username  string
+	Tags      []string
+	UpdatedAt time.Time
+}
+
+// ReencryptResult reports what Reencrypt did
+type ReencryptResult struct {
+	Total      int
+	Successful int
+	Unchanged  int // Already encrypted for the members
+	Failed     int
+	Errors     []string
+}
+
+// OpenStore opens the store with the local identity, which must belong to
+// a member of the team
+func OpenStore(opts Options) (*Client, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if opts.StorePath != "" {
+		cfg.StorePath = opts.StorePath
+	}
+	if opts.IdentityPath != "" {
+		cfg.Identity.PrivateKeyPath = opts.IdentityPath
+	}
+	if !cfg.IsInitialized() {
+		return nil, fmt.Errorf("no passbook store at %s", cfg.StorePath)
+	}
+
+	s, err := store.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+	cfg.Identity.PublicKey = s.Crypto().PublicKey()
+
+	user, err := s.GetUserByPublicKey(cfg.Identity.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: this identity is not a member of the team", ErrAccessDenied)
+	}
+
+	return &Client{cfg: cfg, store: s, user: user}, nil
+}
+
+// User returns the email of the member the client acts as
+func (c *Client) User() string {
+	return c.user.Email
+}
+
+// ListCredentials returns the credentials the client can decrypt
+func (c *Client) ListCredentials(ctx context.Context) ([]CredentialSummary, error) {
+	if err := c.authorize(rbac.PermCredentialsRead); err != nil {
+		return nil, err
+	}
+
+	summaries, err := c.store.ListCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]CredentialSummary, 0, len(summaries))
+	for _, s := range summaries {
+		result = append(result, CredentialSummary{
+			Website:   s.Website,
+			Name:      s.Name,
+			Username:  s.Username,
+			Tags:      s.Tags,
+			UpdatedAt: s.UpdatedAt,
+		})
+	}
+	return result, nil
+}
+
+// GetEnv returns the variables of a project stage
+func (c *Client) GetEnv(ctx context.Context, project, stage string) (map[string]string, error) {
+	st := models.Stage(stage)
+	if !st.IsValid() {
+		return nil, fmt.Errorf("%w: invalid stage %q", ErrInvalidInput, stage)
+	}
+	if err := c.authorize(rbac.GetStagePermission(st, false)); err != nil {
+		return nil, err
+	}
+
+	envFile, err := c.store.GetEnvFile(ctx, project, st)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("environment %s/%s %w", project, stage, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.logAudit(audit.EventEnvAccess, fmt.Sprintf("%s/%s", project, stage), "action", "sdk")
+
+	vars := make(map[string]string, len(envFile.Vars))
+	for _, v := range envFile.Vars {
+		vars[v.Key] = v.Value
+	}
+	return vars, nil
+}
+
+// SetEnv sets variables in a project stage, creating the stage if needed,
+// and commits the change. New variables are marked secret. Setting values
+// the stage already has changes nothing. Writes to prod return
+// ErrReviewRequired when the store has review.prod set.
+func (c *Client) SetEnv(ctx context.Context, project, stage string, vars map[string]string) error {
+	st := models.Stage(stage)
+	if !st.IsValid() {
+		return fmt.Errorf("%w: invalid stage %q", ErrInvalidInput, stage)
+	}
+	if err := c.requireWritable(rbac.GetStagePermission(st, true)); err != nil {
+		return err
+	}
+	if c.cfg.Review.Prod && st == models.StageProd {
+		return ErrReviewRequired
+	}
+	if len(vars) == 0 {
+		return nil
+	}
+
+	envFile, err := c.store.GetEnvFile(ctx, project, st)
+	if errors.Is(err, os.ErrNotExist) {
+		envFile = &models.EnvFile{
+			Project:   project,
+			Stage:     st,
+			Vars:      []models.EnvVar{},
+			CreatedBy: c.user.Email,
+		}
+	} else if err != nil {
+		return err
+	}
+
+	changed := false
+	for key, value := range vars {
+		if key == "" {
+			return fmt.Errorf("%w: empty variable name", ErrInvalidInput)
+		}
+		isSecret := true
+		for _, v := range envFile.Vars {
+			if v.Key == key {
+				isSecret = v.IsSecret
+			}
+		}
+		if envFile.Update(key, value, isSecret, c.user.Email) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	envFile.UpdatedBy = c.user.Email
+	envFile.UpdatedAt = time.Now()
+
+	if err := c.store.SaveEnvFile(ctx, envFile); err != nil {
+		return fmt.Errorf("failed to save environment: %w", err)
+	}
+
+	c.logAudit(audit.EventEnvUpdated, fmt.Sprintf("%s/%s", project, stage), "action", "sdk", "count", fmt.Sprintf("%d", len(vars)))
+	return c.commit(ctx, fmt.Sprintf("Set %d variable(s) in %s/%s", len(vars), project, stage))
+}
+
+// Reencrypt re-encrypts every secret for the current verified members and
+// commits the result. Files that fail are reported, not fatal.
+func (c *Client) Reencrypt(ctx context.Context) (*ReencryptResult, error) {
+	if err := c.requireWritable(rbac.PermStoreReencrypt); err != nil {
+		return nil, err
+	}
+
+	// Files under legal hold need recorded approvals, which only the CLI
+	// checks and consumes
+	held, err := c.hasLegalHolds()
+	if err != nil {
+		return nil, err
+	}
+	if held {
+		return nil, fmt.Errorf("%w: the store has legal holds; re-encrypt with 'passbook reencrypt'", ErrAccessDenied)
+	}
+
+	users, err := c.store.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	var recipients []string
+	for _, u := range users {
+		if u.CanReceiveSecrets() {
+			recipients = append(recipients, u.PublicKey)
+		}
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no verified recipients found")
+	}
+
+	stats, err := reencrypt_pkg.NewReEncryptor(c.cfg.StorePath, c.store.Crypto()).ReEncryptAll(ctx, c.store)
+	if err != nil {
+		return nil, fmt.Errorf("re-encryption failed: %w", err)
+	}
+
+	c.logAudit(audit.EventReEncrypt, "all",
+		"total", fmt.Sprintf("%d", stats.TotalFiles),
+		"successful", fmt.Sprintf("%d", stats.SuccessfulFiles),
+		"unchanged", fmt.Sprintf("%d", stats.UnchangedFiles),
+		"failed", fmt.Sprintf("%d", stats.FailedFiles))
+
+	result := &ReencryptResult{
+		Total:      stats.TotalFiles,
+		Successful: stats.SuccessfulFiles,
+		Unchanged:  stats.UnchangedFiles,
+		Failed:     stats.FailedFiles,
+		Errors:     stats.Errors,
+	}
+	if stats.SuccessfulFiles == 0 {
+		return result, nil
+	}
+	return result, c.commit(ctx, "Re-encrypt all secrets")
+}
+
+// hasLegalHolds reports whether any path in the store is under legal hold
+func (c *Client) hasLegalHolds() (bool, error) {
+	data, err := os.ReadFile(filepath.Join(c.cfg.StorePath, holdsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read legal holds: %w", err)
+	}
+
+	var holds struct {
+		Holds []struct {
+			Path string `yaml:"path"`
+		} `yaml:"holds"`
+	}
+	if err := yaml.Unmarshal(data, &holds); err != nil {
+		return false, fmt.Errorf("failed to parse legal holds: %w", err)
+	}
+	return len(holds.Holds) > 0, nil
+}
+
+// authorize checks that the client's member holds perm
+func (c *Client) authorize(perm rbac.Permission) error {
+	if d := c.store.RBAC().Explain(c.user, perm); !d.Allowed {
+		return fmt.Errorf("%w: %s", ErrAccessDenied, d.Reason)
+	}
+	return nil
+}
+
+// requireWritable checks perm and that writes are enabled
+func (c *Client) requireWritable(perm rbac.Permission) error {
+	if c.cfg.ReadOnly {
+		return ErrReadOnly
+	}
+	return c.authorize(perm)
+}
+
+// commit commits all changes, pushing when autopush is on
+func (c *Client) commit(ctx context.Context, message string) error {
+	if err := c.store.Commit(ctx, message); err != nil {
+		return fmt.Errorf("commit failed: %w", err)
+	}
+	if c.cfg.Git.AutoPush {
+		if err := c.store.Push(ctx); err != nil {
+			return fmt.Errorf("%w: %w", ErrPushFailed, err)
+		}
+	}
+	return nil
+}
+
+// logAudit records an event as the client's member. Audit failures don't
+// fail the operation, matching the CLI.
+func (c *Client) logAudit(eventType audit.EventType, target string, details ...string) {
+	logger := audit.NewLogger(c.cfg.StorePath, c.user.AuditName())
+	logger.OnEvent(c.emitEvent)
+	_ = logger.LogWithDetails(eventType, target, details...)
+}
+
+// emitEvent sends a change to the store to the configured event sinks.
+// Like audit failures, delivery failures don't fail the operation.
+func (c *Client) emitEvent(e audit.Event) {
+	if !e.Type.IsMutation() {
+		return
+	}
+	sinks := notify.Sinks{URL: c.cfg.Notify.EventsURL, Command: c.cfg.Events.Command, File: c.cfg.EventsFilePath()}
+	if !sinks.Empty() {
+		_ = sinks.Deliver(context.Background(), notify.EventMessage(e))
+	}
+}
@@ -0,0 +1,105 @@
+package pwgen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Profile holds a site's password rules, so generated passwords are ones
+// the site accepts
+type Profile struct {
+	MinLength int    `yaml:"min_length,omitempty"`
+	MaxLength int    `yaml:"max_length,omitempty"`
+	NoSymbols bool   `yaml:"no_symbols,omitempty"`
+	Symbols   string `yaml:"symbols,omitempty"` // The only symbols the site allows; empty for any
+	Exclude   string `yaml:"exclude,omitempty"` // Characters the site rejects
+}
+
+// builtinProfiles are the known rules of sites that reject the default
+// generator's output. Config overrides take precedence.
+var builtinProfiles = map[string]Profile{
+	"americanexpress.com": {MaxLength: 20, Symbols: "%&_?#="},
+	"bankofamerica.com":   {MaxLength: 20},
+	"chase.com":           {MaxLength: 32, Symbols: "!#$%+/=@~"},
+	"paypal.com":          {MaxLength: 20},
+	"wellsfargo.com":      {MaxLength: 32},
+	"google.com":          {MaxLength: 100},
+	"aws.amazon.com":      {MaxLength: 128},
+}
+
+// ProfileFor returns the profile for a site and the domain it was found
+// under. A site matches its own profile or that of a parent domain, so
+// accounts.google.com uses google.com's. overrides are checked first.
+func ProfileFor(site string, overrides map[string]Profile) (Profile, string, bool) {
+	site = strings.ToLower(strings.TrimSpace(site))
+	for domain := site; domain != ""; {
+		if p, ok := overrides[domain]; ok {
+			return p, domain, true
+		}
+		if p, ok := builtinProfiles[domain]; ok {
+			return p, domain, true
+		}
+		_, parent, found := strings.Cut(domain, ".")
+		if !found || !strings.Contains(parent, ".") {
+			break
+		}
+		domain = parent
+	}
+	return Profile{}, "", false
+}
+
+// Options returns generator options that satisfy the profile, using length
+// unless the profile's limits rule it out
+func (p Profile) Options(length int) Options {
+	opts := DefaultOptions()
+	opts.Length = length
+	if p.MaxLength > 0 && opts.Length > p.MaxLength {
+		opts.Length = p.MaxLength
+	}
+	if p.MinLength > 0 && opts.Length < p.MinLength {
+		opts.Length = p.MinLength
+	}
+
+	opts.Symbols = !p.NoSymbols
+	opts.Exclude = p.Exclude
+	if p.Symbols != "" {
+		for _, c := range symbols {
+			if !strings.ContainsRune(p.Symbols, c) {
+				opts.Exclude += string(c)
+			}
+		}
+	}
+
+	// Sites with rules usually require one of each class too
+	opts.MinUpper, opts.MinLower, opts.MinDigits = 1, 1, 1
+	if opts.Symbols {
+		opts.MinSymbols = 1
+	}
+	return opts
+}
+
+// String describes the profile's rules
+func (p Profile) String() string {
+	var rules []string
+	switch {
+	case p.MinLength > 0 && p.MaxLength > 0:
+		rules = append(rules, fmt.Sprintf("%d-%d characters", p.MinLength, p.MaxLength))
+	case p.MaxLength > 0:
+		rules = append(rules, fmt.Sprintf("at most %d characters", p.MaxLength))
+	case p.MinLength > 0:
+		rules = append(rules, fmt.Sprintf("at least %d characters", p.MinLength))
+	}
+	switch {
+	case p.NoSymbols:
+		rules = append(rules, "no symbols")
+	case p.Symbols != "":
+		rules = append(rules, "symbols "+p.Symbols)
+	}
+	if p.Exclude != "" {
+		rules = append(rules, "no "+p.Exclude)
+	}
+	if len(rules) == 0 {
+		return "no restrictions"
+	}
+	return strings.Join(rules, ", ")
+}
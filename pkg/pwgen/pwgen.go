@@ -1,7 +1,9 @@
 package pwgen
 
 import (
+	"context"
 	"crypto/rand"
+	"errors"
 	"math"
 	"math/big"
 	"strings"
@@ -30,6 +32,15 @@ type Options struct {
 	MinLower   int    // Minimum lowercase characters
 	MinDigits  int    // Minimum digit characters
 	MinSymbols int    // Minimum symbol characters
+
+	// MinEntropy lengthens the password, or adds words to a memorable one,
+	// until it has at least this many bits
+	MinEntropy float64
+
+	// Memorable generates words from the word list instead of characters
+	Memorable bool
+	Words     int    // Number of words; DefaultWords if zero
+	Separator string // Between words; "-" if empty
 }
 
 // DefaultOptions returns sensible defaults
@@ -56,54 +67,151 @@ func AlphanumericOptions() Options {
 
 // Generate generates a random password with the given options
 func Generate(opts Options) (string, error) {
-	if opts.Length <= 0 {
-		opts.Length = DefaultLength
+	return GenerateContext(context.Background(), opts)
+}
+
+// GenerateContext generates a random password with the given options,
+// stopping if ctx is cancelled
+func GenerateContext(ctx context.Context, opts Options) (string, error) {
+	if opts.Memorable {
+		return generateWords(ctx, opts)
 	}
 
-	// Build character set
-	var charset strings.Builder
-	if opts.Lowercase {
-		charset.WriteString(lowercase)
+	opts = opts.normalize()
+	charset := opts.charset()
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		password := make([]byte, opts.Length)
+		for i := range password {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+			c, err := randomChar(charset)
+			if err != nil {
+				return "", err
+			}
+			password[i] = c
+		}
+
+		// Rejecting passwords that miss a minimum keeps every valid
+		// password equally likely
+		if opts.meetsMinimums(string(password)) {
+			return string(password), nil
+		}
+	}
+
+	// Minimums that are rarely met by chance: place the required characters,
+	// fill the rest from the whole set, and shuffle
+	return placeMinimums(ctx, opts, charset)
+}
+
+// Bits returns the entropy of passwords generated with opts
+func (o Options) Bits() float64 {
+	if o.Memorable {
+		return float64(o.wordCount()) * math.Log2(float64(len(wordList)))
 	}
-	if opts.Uppercase {
-		charset.WriteString(uppercase)
+	o = o.normalize()
+	return float64(o.Length) * math.Log2(float64(len(o.charset())))
+}
+
+// maxAttempts bounds rejection sampling before falling back to placing the
+// required characters directly
+const maxAttempts = 1000
+
+// normalize fills in the default length and lengthens the password to meet
+// its minimums and target entropy
+func (o Options) normalize() Options {
+	if o.Length <= 0 {
+		o.Length = DefaultLength
 	}
-	if opts.Digits {
-		charset.WriteString(digits)
+	if required := o.MinUpper + o.MinLower + o.MinDigits + o.MinSymbols; o.Length < required {
+		o.Length = required
 	}
-	if opts.Symbols {
-		charset.WriteString(symbols)
+	if o.MinEntropy > 0 {
+		perChar := math.Log2(float64(len(o.charset())))
+		if perChar > 0 {
+			if n := int(math.Ceil(o.MinEntropy / perChar)); n > o.Length {
+				o.Length = n
+			}
+		}
+	}
+	return o
+}
+
+// charClass is one kind of character a password needs a minimum of
+type charClass struct {
+	set string
+	min int
+}
+
+// classes returns each enabled character class with its minimum, without
+// the excluded characters
+func (o Options) classes() []charClass {
+	enabled := []bool{o.Lowercase, o.Uppercase, o.Digits, o.Symbols}
+	all := []charClass{
+		{lowercase, o.MinLower},
+		{uppercase, o.MinUpper},
+		{digits, o.MinDigits},
+		{symbols, o.MinSymbols},
 	}
 
-	// Remove excluded characters
-	charsetStr := charset.String()
-	if opts.Exclude != "" {
-		for _, c := range opts.Exclude {
-			charsetStr = strings.ReplaceAll(charsetStr, string(c), "")
+	var classes []charClass
+	for i, c := range all {
+		if set := without(c.set, o.Exclude); enabled[i] && set != "" {
+			classes = append(classes, charClass{set, c.min})
 		}
 	}
+	return classes
+}
+
+// charset returns every character a password may contain
+func (o Options) charset() string {
+	var charset strings.Builder
+	for _, c := range o.classes() {
+		charset.WriteString(c.set)
+	}
+	if charset.Len() == 0 {
+		return lowercase + uppercase + digits // Fallback
+	}
+	return charset.String()
+}
 
-	if len(charsetStr) == 0 {
-		charsetStr = lowercase + uppercase + digits // Fallback
+// meetsMinimums reports whether password has enough of each class
+func (o Options) meetsMinimums(password string) bool {
+	for _, c := range o.classes() {
+		if countChars(password, c.set) < c.min {
+			return false
+		}
 	}
+	return true
+}
 
-	// Generate password
-	password := make([]byte, opts.Length)
-	for i := range password {
-		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charsetStr))))
+// placeMinimums builds a password from its required characters and fills
+// the rest from the whole set, in random order
+func placeMinimums(ctx context.Context, opts Options, charset string) (string, error) {
+	var password []byte
+	for _, c := range opts.classes() {
+		for i := 0; i < c.min; i++ {
+			ch, err := randomChar(c.set)
+			if err != nil {
+				return "", err
+			}
+			password = append(password, ch)
+		}
+	}
+	for len(password) < opts.Length {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		ch, err := randomChar(charset)
 		if err != nil {
 			return "", err
 		}
-		password[i] = charsetStr[n.Int64()]
+		password = append(password, ch)
 	}
-
-	// Ensure minimum requirements
-	result := string(password)
-	if opts.MinUpper > 0 || opts.MinLower > 0 || opts.MinDigits > 0 || opts.MinSymbols > 0 {
-		result = ensureMinimums(result, opts)
+	if err := shuffle(password); err != nil {
+		return "", err
 	}
-
-	return result, nil
+	return string(password), nil
 }
 
 // GenerateSimple generates a password with default options
@@ -129,51 +237,14 @@ func GeneratePin(length int) (string, error) {
 	return Generate(opts)
 }
 
-// ensureMinimums ensures minimum character type requirements
-func ensureMinimums(password string, opts Options) string {
-	chars := []byte(password)
-
-	// Count current character types
-	upperCount := countChars(password, uppercase)
-	lowerCount := countChars(password, lowercase)
-	digitCount := countChars(password, digits)
-	symbolCount := countChars(password, symbols)
-
-	// Replace characters as needed
-	pos := 0
-	if opts.MinUpper > upperCount {
-		for i := 0; i < opts.MinUpper-upperCount && pos < len(chars); i++ {
-			c, _ := randomChar(uppercase)
-			chars[pos] = c
-			pos++
-		}
-	}
-	if opts.MinLower > lowerCount {
-		for i := 0; i < opts.MinLower-lowerCount && pos < len(chars); i++ {
-			c, _ := randomChar(lowercase)
-			chars[pos] = c
-			pos++
-		}
-	}
-	if opts.MinDigits > digitCount {
-		for i := 0; i < opts.MinDigits-digitCount && pos < len(chars); i++ {
-			c, _ := randomChar(digits)
-			chars[pos] = c
-			pos++
+// without returns charset with the excluded characters removed
+func without(charset, exclude string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(exclude, r) {
+			return -1
 		}
-	}
-	if opts.MinSymbols > symbolCount {
-		for i := 0; i < opts.MinSymbols-symbolCount && pos < len(chars); i++ {
-			c, _ := randomChar(symbols)
-			chars[pos] = c
-			pos++
-		}
-	}
-
-	// Shuffle
-	shuffle(chars)
-
-	return string(chars)
+		return r
+	}, charset)
 }
 
 // countChars counts characters from a set in the string
@@ -189,6 +260,9 @@ func countChars(s, charset string) int {
 
 // randomChar returns a random character from the charset
 func randomChar(charset string) (byte, error) {
+	if charset == "" {
+		return 0, errors.New("empty character set")
+	}
 	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
 	if err != nil {
 		return 0, err
@@ -197,15 +271,16 @@ func randomChar(charset string) (byte, error) {
 }
 
 // shuffle shuffles a byte slice in place
-func shuffle(chars []byte) {
+func shuffle(chars []byte) error {
 	for i := len(chars) - 1; i > 0; i-- {
 		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
 		if err != nil {
-			continue
+			return err
 		}
 		j := n.Int64()
 		chars[i], chars[j] = chars[j], chars[i]
 	}
+	return nil
 }
 
 // Entropy calculates the entropy of a password in bits
@@ -254,3 +329,17 @@ func Entropy(password string) float64 {
 	// Entropy = log2(poolSize^length) = length * log2(poolSize)
 	return float64(len(password)) * math.Log2(float64(poolSize))
 }
+
+// Rating describes how strong a password with the given entropy is
+func Rating(bits float64) string {
+	switch {
+	case bits < 40:
+		return "weak"
+	case bits < 60:
+		return "fair"
+	case bits < 80:
+		return "good"
+	default:
+		return "strong"
+	}
+}
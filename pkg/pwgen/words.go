@@ -0,0 +1,58 @@
+package pwgen
+
+import (
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// DefaultWords is the number of words in a memorable password, about 66
+// bits
+const DefaultWords = 6
+
+// words is the BIP39 English word list: 2048 short, distinct words, so each
+// adds 11 bits
+//
+//go:embed words.txt
+var words string
+
+var wordList = strings.Fields(words)
+
+// wordCount returns how many words a memorable password needs
+func (o Options) wordCount() int {
+	n := o.Words
+	if n <= 0 {
+		n = DefaultWords
+	}
+	if o.MinEntropy > 0 {
+		perWord := math.Log2(float64(len(wordList)))
+		if need := int(math.Ceil(o.MinEntropy / perWord)); need > n {
+			n = need
+		}
+	}
+	return n
+}
+
+// generateWords generates a memorable password of random words
+func generateWords(ctx context.Context, opts Options) (string, error) {
+	separator := opts.Separator
+	if separator == "" {
+		separator = "-"
+	}
+
+	chosen := make([]string, opts.wordCount())
+	for i := range chosen {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(wordList))))
+		if err != nil {
+			return "", err
+		}
+		chosen[i] = wordList[n.Int64()]
+	}
+	return strings.Join(chosen, separator), nil
+}
@@ -0,0 +1,481 @@
+// Package qr renders short strings (such as age public keys) as QR codes
+// for display in a terminal. It only implements byte mode at error
+// correction level M, versions 1-10, which is enough for keys and
+// fingerprints.
+package qr
+
+import (
+	"errors"
+	"strings"
+)
+
+// MaxVersion is the largest symbol version supported
+const MaxVersion = 10
+
+// ErrTooLong is returned when data doesn't fit in the largest supported version
+var ErrTooLong = errors.New("data too long for QR code")
+
+// blockSpec describes error correction for one version at level M
+type blockSpec struct {
+	ecPerBlock int
+	groups     [][2]int // {block count, data codewords per block}
+}
+
+var specs = [MaxVersion + 1]blockSpec{
+	1:  {10, [][2]int{{1, 16}}},
+	2:  {16, [][2]int{{1, 28}}},
+	3:  {26, [][2]int{{1, 44}}},
+	4:  {18, [][2]int{{2, 32}}},
+	5:  {24, [][2]int{{2, 43}}},
+	6:  {16, [][2]int{{4, 27}}},
+	7:  {18, [][2]int{{4, 31}}},
+	8:  {22, [][2]int{{2, 38}, {2, 39}}},
+	9:  {22, [][2]int{{3, 36}, {2, 37}}},
+	10: {26, [][2]int{{4, 43}, {1, 44}}},
+}
+
+var alignment = [MaxVersion + 1][]int{
+	2:  {6, 18},
+	3:  {6, 22},
+	4:  {6, 26},
+	5:  {6, 30},
+	6:  {6, 34},
+	7:  {6, 22, 38},
+	8:  {6, 24, 42},
+	9:  {6, 26, 46},
+	10: {6, 28, 50},
+}
+
+// Code is an encoded QR symbol
+type Code struct {
+	Version  int
+	Size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// Encode encodes data in byte mode using the smallest version that fits
+func Encode(data []byte) (*Code, error) {
+	version := 0
+	for v := 1; v <= MaxVersion; v++ {
+		if len(data) <= capacity(v) {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	size := version*4 + 17
+	c := &Code{Version: version, Size: size}
+	c.modules = make([][]bool, size)
+	c.function = make([][]bool, size)
+	for i := range c.modules {
+		c.modules[i] = make([]bool, size)
+		c.function[i] = make([]bool, size)
+	}
+
+	c.drawFunctionPatterns()
+	c.drawCodewords(interleave(version, dataCodewords(version, data)))
+
+	// Pick the mask with the lowest penalty
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		c.applyMask(mask) // Masking is its own inverse
+	}
+	c.applyMask(best)
+	c.drawFormatBits(best)
+
+	return c, nil
+}
+
+// Black reports whether the module at column x, row y is dark
+func (c *Code) Black(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// Terminal renders the code with half-block characters, two rows per line,
+// surrounded by a quiet zone. Light modules are drawn filled so the code
+// scans on the usual dark terminal background; invert for light backgrounds.
+func (c *Code) Terminal(invert bool) string {
+	const quiet = 4
+	light := func(x, y int) bool {
+		if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+			return !invert
+		}
+		return c.modules[y][x] == invert
+	}
+
+	var b strings.Builder
+	for y := -quiet; y < c.Size+quiet; y += 2 {
+		for x := -quiet; x < c.Size+quiet; x++ {
+			top, bottom := light(x, y), light(x, y+1)
+			if y+1 >= c.Size+quiet {
+				bottom = false
+			}
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// capacity returns how many bytes fit in a version
+func capacity(version int) int {
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	return (totalData(version)*8 - 4 - countBits) / 8
+}
+
+// totalData returns the number of data codewords in a version
+func totalData(version int) int {
+	n := 0
+	for _, g := range specs[version].groups {
+		n += g[0] * g[1]
+	}
+	return n
+}
+
+// dataCodewords builds the padded data codeword sequence
+func dataCodewords(version int, data []byte) []byte {
+	var bits []bool
+	put := func(v, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, v>>uint(i)&1 == 1)
+		}
+	}
+
+	put(0x4, 4) // Byte mode
+	if version >= 10 {
+		put(len(data), 16)
+	} else {
+		put(len(data), 8)
+	}
+	for _, d := range data {
+		put(int(d), 8)
+	}
+
+	// Terminator and byte alignment
+	capBits := totalData(version) * 8
+	for i := 0; i < 4 && len(bits) < capBits; i++ {
+		bits = append(bits, false)
+	}
+	for len(bits)%8 != 0 {
+		bits = append(bits, false)
+	}
+
+	out := make([]byte, 0, totalData(version))
+	for i := 0; i < len(bits); i += 8 {
+		var v byte
+		for j := 0; j < 8; j++ {
+			if bits[i+j] {
+				v |= 1 << uint(7-j)
+			}
+		}
+		out = append(out, v)
+	}
+
+	// Pad bytes
+	for pad := byte(0xEC); len(out) < totalData(version); pad ^= 0xEC ^ 0x11 {
+		out = append(out, pad)
+	}
+	return out
+}
+
+// interleave splits data into blocks, appends error correction and interleaves them
+func interleave(version int, data []byte) []byte {
+	spec := specs[version]
+	divisor := rsDivisor(spec.ecPerBlock)
+
+	var blocks, ecc [][]byte
+	offset := 0
+	for _, g := range spec.groups {
+		for i := 0; i < g[0]; i++ {
+			block := data[offset : offset+g[1]]
+			offset += g[1]
+			blocks = append(blocks, block)
+			ecc = append(ecc, rsRemainder(block, divisor))
+		}
+	}
+
+	var out []byte
+	longest := spec.groups[len(spec.groups)-1][1]
+	for i := 0; i < longest; i++ {
+		for _, b := range blocks {
+			if i < len(b) {
+				out = append(out, b[i])
+			}
+		}
+	}
+	for i := 0; i < spec.ecPerBlock; i++ {
+		for _, e := range ecc {
+			out = append(out, e[i])
+		}
+	}
+	return out
+}
+
+// rsDivisor computes the Reed-Solomon generator polynomial of a degree
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder computes the error correction codewords for a block
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range divisor {
+			result[i] ^= gfMultiply(coef, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func gfMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int(y>>uint(i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+// set marks a function module
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+// drawFunctionPatterns draws finders, timing, alignment and reserves format areas
+func (c *Code) drawFunctionPatterns() {
+	// Timing patterns
+	for i := 0; i < c.Size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+
+	// Finder patterns with separators
+	for _, p := range [][2]int{{3, 3}, {c.Size - 4, 3}, {3, c.Size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := p[0]+dx, p[1]+dy
+				if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+					continue
+				}
+				d := max(abs(dx), abs(dy))
+				c.set(x, y, d != 2 && d != 4)
+			}
+		}
+	}
+
+	// Alignment patterns, skipping those that overlap finders
+	pos := alignment[c.Version]
+	for i, cx := range pos {
+		for j, cy := range pos {
+			if (i == 0 && j == 0) || (i == 0 && j == len(pos)-1) || (i == len(pos)-1 && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve format areas (filled in per mask)
+	c.drawFormatBits(0)
+
+	// Version information
+	if c.Version >= 7 {
+		rem := c.Version
+		for i := 0; i < 12; i++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		bits := c.Version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := bits>>uint(i)&1 == 1
+			a, b := c.Size-11+i%3, i/3
+			c.set(a, b, dark)
+			c.set(b, a, dark)
+		}
+	}
+}
+
+// drawFormatBits draws both copies of the format information for a mask
+func (c *Code) drawFormatBits(mask int) {
+	data := 0<<3 | mask // Level M has format bits 00
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>uint(i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(i))
+	}
+	c.set(8, c.Size-8, true) // Dark module
+}
+
+// drawCodewords places data in the zig-zag pattern over non-function modules
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if c.function[y][x] || i >= len(data)*8 {
+					continue
+				}
+				c.modules[y][x] = data[i>>3]>>uint(7-i&7)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask XORs a mask pattern over the data modules
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.function[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores a masked symbol on runs, 2x2 blocks and dark balance
+func (c *Code) penalty() int {
+	score := 0
+
+	// Runs of five or more same-colored modules in rows and columns
+	for y := 0; y < c.Size; y++ {
+		for _, horizontal := range []bool{true, false} {
+			run := 1
+			for x := 1; x < c.Size; x++ {
+				cur, prev := c.modules[y][x], c.modules[y][x-1]
+				if !horizontal {
+					cur, prev = c.modules[x][y], c.modules[x-1][y]
+				}
+				if cur == prev {
+					run++
+					continue
+				}
+				if run >= 5 {
+					score += run - 2
+				}
+				run = 1
+			}
+			if run >= 5 {
+				score += run - 2
+			}
+		}
+	}
+
+	// 2x2 blocks of the same color
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x+1 < c.Size && y+1 < c.Size {
+				v := c.modules[y][x]
+				if v == c.modules[y][x+1] && v == c.modules[y+1][x] && v == c.modules[y+1][x+1] {
+					score += 3
+				}
+			}
+		}
+	}
+
+	// Deviation from 50% dark modules
+	total := c.Size * c.Size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	score += k * 10
+
+	return score
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,52 @@
+package redact
+
+import "sync"
+
+var (
+	globalMu sync.RWMutex
+	global   []string
+	seen     = make(map[string]bool)
+	disabled bool
+)
+
+// Register records decrypted values so Scrub masks them in passbook's own
+// messages: errors, warnings and anything quoted from git or a parser.
+// Output a command prints on purpose, such as a revealed value, doesn't go
+// through Scrub.
+func Register(values ...string) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	for _, v := range values {
+		if len(v) < MinLength || seen[v] {
+			continue
+		}
+		seen[v] = true
+		global = append(global, v)
+	}
+}
+
+// SetEnabled turns Scrub on or off; --show-secrets turns it off
+func SetEnabled(enabled bool) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	disabled = !enabled
+}
+
+// Enabled reports whether Scrub masks anything
+func Enabled() bool {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return !disabled
+}
+
+// Scrub masks every registered value in s
+func Scrub(s string) string {
+	globalMu.RLock()
+	if disabled || len(global) == 0 {
+		globalMu.RUnlock()
+		return s
+	}
+	secrets := append([]string(nil), global...)
+	globalMu.RUnlock()
+	return String(s, secrets)
+}
@@ -0,0 +1,23 @@
+package redact
+
+import "testing"
+
+func TestScrub(t *testing.T) {
+	Register("registered-secret", "ab")
+	defer SetEnabled(true)
+
+	if got, want := Scrub("failed to parse registered-secret"), "failed to parse "+Mask; got != want {
+		t.Errorf("Scrub = %q, want %q", got, want)
+	}
+	if got := Scrub("ab is too short to mask"); got != "ab is too short to mask" {
+		t.Errorf("Scrub masked a short value: %q", got)
+	}
+
+	SetEnabled(false)
+	if Enabled() {
+		t.Error("Enabled after SetEnabled(false)")
+	}
+	if got := Scrub("registered-secret"); got != "registered-secret" {
+		t.Errorf("Scrub while disabled = %q", got)
+	}
+}
@@ -0,0 +1,107 @@
+package redact
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Mask replaces every secret value in redacted output
+const Mask = "********"
+
+// MinLength is the shortest value that gets masked. Shorter values such as
+// "1" or "on" would mask ordinary text.
+const MinLength = 4
+
+// Writer masks secret values in everything written through it. Output is
+// passed on as it arrives, except for a trailing fragment that could be the
+// start of a secret split across writes; Flush emits it once the stream ends.
+type Writer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	secrets [][]byte
+	pending []byte
+}
+
+// NewWriter returns a Writer that masks secrets before writing to w
+func NewWriter(w io.Writer, secrets []string) *Writer {
+	seen := make(map[string]bool)
+	var list [][]byte
+	for _, s := range secrets {
+		if len(s) < MinLength || seen[s] {
+			continue
+		}
+		seen[s] = true
+		list = append(list, []byte(s))
+	}
+	// Longest first, so a secret containing another is masked whole
+	sort.Slice(list, func(i, j int) bool { return len(list[i]) > len(list[j]) })
+
+	return &Writer{w: w, secrets: list}
+}
+
+// Write masks p and writes it on, holding back a possible partial secret
+func (r *Writer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pending = append(r.pending, p...)
+	out, rest := r.mask(r.pending)
+	r.pending = append(r.pending[:0], rest...)
+
+	if len(out) > 0 {
+		if _, err := r.w.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes anything held back. A trailing fragment is only a prefix of
+// a secret, never the whole value.
+func (r *Writer) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.pending) == 0 {
+		return nil
+	}
+	_, err := r.w.Write(r.pending)
+	r.pending = r.pending[:0]
+	return err
+}
+
+// String masks secrets in s
+func String(s string, secrets []string) string {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, secrets)
+	w.Write([]byte(s))
+	w.Flush()
+	return buf.String()
+}
+
+// mask returns the masked output that is safe to emit and the tail that
+// must wait for more input
+func (r *Writer) mask(buf []byte) ([]byte, []byte) {
+	var out []byte
+	i := 0
+scan:
+	for i < len(buf) {
+		for _, s := range r.secrets {
+			if bytes.HasPrefix(buf[i:], s) {
+				out = append(out, Mask...)
+				i += len(s)
+				continue scan
+			}
+		}
+		for _, s := range r.secrets {
+			if len(buf)-i < len(s) && bytes.HasPrefix(s, buf[i:]) {
+				break scan
+			}
+		}
+		out = append(out, buf[i])
+		i++
+	}
+	return out, buf[i:]
+}
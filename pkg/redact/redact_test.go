@@ -0,0 +1,65 @@
+package redact
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	secrets := []string{"hunter22", "hunter2233", "on", "s3cr3t"}
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"no secrets", "nothing to hide", "nothing to hide"},
+		{"one secret", "password=s3cr3t", "password=" + Mask},
+		{"repeated", "s3cr3t and s3cr3t", Mask + " and " + Mask},
+		{"longest first", "token hunter2233", "token " + Mask},
+		{"short values stay", "turn it on", "turn it on"},
+		{"prefix of a secret at the end", "prefix s3cr", "prefix s3cr"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := String(tt.in, secrets); got != tt.want {
+				t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriterMasksSecretsSplitAcrossWrites(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, []string{"s3cr3t-value"})
+
+	for _, chunk := range []string{"key=s3c", "r3t-", "value\nnext"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(buf.Bytes(), []byte("s3c")) {
+			t.Fatalf("part of the secret was written early: %q", buf.String())
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "key=" + Mask + "\nnext"; buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriterReportsBytesWritten(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, []string{"s3cr3t"})
+
+	n, err := w.Write([]byte("a s3cr"))
+	if err != nil || n != 6 {
+		t.Errorf("Write = %d, %v, want 6, nil", n, err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "a s3cr" {
+		t.Errorf("flushed %q, want the held back fragment", buf.String())
+	}
+}
@@ -0,0 +1,189 @@
+// Package securetmp creates private temporary files for plaintext secrets.
+// Files are 0600 in 0700 directories, on a memory-backed filesystem where
+// one is available, and are overwritten before removal: when the caller is
+// done, when passbook exits, or when it's killed by a signal.
+package securetmp
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+var (
+	mu      sync.Mutex
+	tracked = make(map[string]bool) // Paths to shred, files or directories
+	signals chan os.Signal
+)
+
+// Dir returns where temporary files are created: a memory-backed
+// filesystem so plaintext never reaches disk, falling back to the system
+// temp directory
+func Dir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	if info, err := os.Stat("/dev/shm"); err == nil && info.IsDir() {
+		return "/dev/shm"
+	}
+	return os.TempDir()
+}
+
+// MkdirTemp creates a private directory and tracks it until RemoveAll
+func MkdirTemp(pattern string) (string, error) {
+	dir, err := os.MkdirTemp(Dir(), pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		os.Remove(dir)
+		return "", err
+	}
+	track(dir)
+	return dir, nil
+}
+
+// Create creates a private file in its own directory and tracks it until
+// Remove. The file is named name, so editors can tell its type.
+func Create(name string) (*os.File, error) {
+	dir, err := MkdirTemp("passbook-")
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		RemoveAll(dir)
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	return f, nil
+}
+
+// Remove shreds a file made by Create, along with its directory
+func Remove(path string) {
+	RemoveAll(filepath.Dir(path))
+}
+
+// RemoveAll shreds every file under a directory made by MkdirTemp and
+// removes it
+func RemoveAll(dir string) {
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			shred(path)
+		}
+		return nil
+	})
+	os.RemoveAll(dir)
+	untrack(dir)
+}
+
+// Cleanup shreds everything still tracked. Run it before exiting.
+func Cleanup() {
+	mu.Lock()
+	dirs := make([]string, 0, len(tracked))
+	for dir := range tracked {
+		dirs = append(dirs, dir)
+	}
+	mu.Unlock()
+
+	for _, dir := range dirs {
+		RemoveAll(dir)
+	}
+}
+
+// WriteFile writes data to path, readable only by the owner, through a
+// temporary file beside it that's renamed into place. An interrupted write
+// leaves neither partial plaintext nor a file with looser permissions,
+// which os.WriteFile keeps when overwriting.
+func WriteFile(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	track(tmp)
+	defer untrack(tmp)
+
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		shred(tmp)
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		shred(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		shred(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		shred(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		shred(tmp)
+		return err
+	}
+	return nil
+}
+
+// track records a path to shred and, while anything is tracked, watches
+// for signals that would end passbook before it cleans up
+func track(path string) {
+	mu.Lock()
+	defer mu.Unlock()
+	tracked[path] = true
+	if signals == nil {
+		signals = make(chan os.Signal, 2)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+		go watch(signals)
+	}
+}
+
+// untrack stops tracking a path, and stops watching for signals once
+// nothing is tracked so their default handling returns
+func untrack(path string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(tracked, path)
+	if len(tracked) == 0 && signals != nil {
+		signal.Stop(signals)
+		close(signals)
+		signals = nil
+	}
+}
+
+// watch shreds tracked files when passbook is about to die. A first
+// interrupt or SIGTERM cancels the running command, which cleans up itself;
+// a second one, or a hangup, would otherwise kill passbook on the spot.
+func watch(signals chan os.Signal) {
+	count := 0
+	for sig := range signals {
+		count++
+		if sig != syscall.SIGHUP && count < 2 {
+			continue
+		}
+		Cleanup()
+		os.Exit(1)
+	}
+}
+
+// shred overwrites a file with zeros before removing it. Editors that save
+// by renaming leave the old inode behind, so this is best effort.
+func shred(path string) {
+	if info, err := os.Stat(path); err == nil {
+		if f, err := os.OpenFile(path, os.O_WRONLY, 0); err == nil {
+			f.Write(make([]byte, info.Size()))
+			f.Sync()
+			f.Close()
+		}
+	}
+	os.Remove(path)
+}
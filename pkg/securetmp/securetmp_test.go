@@ -0,0 +1,75 @@
+package securetmp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateIsPrivate(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	f, err := Create("secret.env")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer f.Close()
+
+	if filepath.Base(f.Name()) != "secret.env" {
+		t.Errorf("name = %s, want secret.env", f.Name())
+	}
+	if info, err := os.Stat(f.Name()); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("file mode = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+	if info, err := os.Stat(filepath.Dir(f.Name())); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("directory mode = %v, %v, want 0700", info.Mode().Perm(), err)
+	}
+
+	Remove(f.Name())
+	if _, err := os.Stat(filepath.Dir(f.Name())); !os.IsNotExist(err) {
+		t.Errorf("directory still exists after Remove: %v", err)
+	}
+}
+
+func TestCleanupRemovesTrackedDirectories(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	dir, err := MkdirTemp("passbook-test-")
+	if err != nil {
+		t.Fatalf("MkdirTemp: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "plaintext"), []byte("s3cret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	Cleanup()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("directory still exists after Cleanup: %v", err)
+	}
+}
+
+func TestWriteFileTightensPermissions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.env")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFile(path, []byte("KEY=value\n")); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "KEY=value\n" {
+		t.Errorf("contents = %q, %v", data, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("left %d files beside the export, want none", len(entries)-1)
+	}
+}
@@ -2,14 +2,18 @@ package termio
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
-	"syscall"
+	"unicode/utf8"
 
 	"golang.org/x/term"
 )
 
+// ErrInterrupted is returned when the user presses Ctrl-C at a prompt
+var ErrInterrupted = errors.New("interrupted")
+
 // Reader reads input from the terminal
 type Reader struct {
 	in  *os.File
@@ -60,7 +64,7 @@ func (r *Reader) PromptPassword(prompt string) (string, error) {
 	fmt.Fprint(r.out, prompt)
 
 	// Read password without echo
-	password, err := term.ReadPassword(int(syscall.Stdin))
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
 	if err != nil {
 		return "", err
 	}
@@ -69,6 +73,56 @@ func (r *Reader) PromptPassword(prompt string) (string, error) {
 	return string(password), nil
 }
 
+// PromptPasswordMeter prompts for a password without echoing, showing
+// meter's verdict on what's been typed so far after the prompt. Without a
+// terminal it reads a plain password.
+func (r *Reader) PromptPasswordMeter(prompt string, meter func(password string) string) (string, error) {
+	fd := int(r.in.Fd())
+	if !term.IsTerminal(fd) {
+		return r.PromptPassword(prompt)
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return r.PromptPassword(prompt)
+	}
+	defer term.Restore(fd, state)
+
+	var password []byte
+	redraw := func() {
+		fmt.Fprintf(r.out, "\r\033[K%s%s", prompt, meter(string(password)))
+	}
+	redraw()
+
+	buf := make([]byte, 1)
+	for {
+		if _, err := r.in.Read(buf); err != nil {
+			fmt.Fprint(r.out, "\r\n")
+			return "", err
+		}
+		switch c := buf[0]; {
+		case c == '\r' || c == '\n':
+			fmt.Fprint(r.out, "\r\n")
+			return string(password), nil
+		case c == 3: // Ctrl-C
+			fmt.Fprint(r.out, "\r\n")
+			return "", ErrInterrupted
+		case c == 127 || c == 8: // Backspace
+			if len(password) > 0 {
+				_, size := utf8.DecodeLastRune(password)
+				password = password[:len(password)-size]
+			}
+		case c == 21: // Ctrl-U
+			password = password[:0]
+		case c < 32:
+			continue
+		default:
+			password = append(password, c)
+		}
+		redraw()
+	}
+}
+
 // Confirm asks for yes/no confirmation
 func (r *Reader) Confirm(prompt string, defaultYes bool) (bool, error) {
 	suffix := "[y/N]"
@@ -182,6 +236,11 @@ func PromptPassword(prompt string) (string, error) {
 	return New().PromptPassword(prompt)
 }
 
+// PromptPasswordMeter prompts for a password with a live verdict
+func PromptPasswordMeter(prompt string, meter func(password string) string) (string, error) {
+	return New().PromptPasswordMeter(prompt, meter)
+}
+
 // Confirm asks for confirmation
 func Confirm(prompt string, defaultYes bool) (bool, error) {
 	return New().Confirm(prompt, defaultYes)
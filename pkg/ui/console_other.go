@@ -0,0 +1,8 @@
+//go:build !windows
+
+package ui
+
+// enableVirtualTerminal is a no-op; other terminals understand ANSI escapes
+func enableVirtualTerminal() bool {
+	return true
+}
@@ -0,0 +1,99 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// interactive is true when stdout is a terminal that can redraw a line
+var interactive = term.IsTerminal(int(os.Stdout.Fd())) && os.Getenv("TERM") != "dumb"
+
+// spinnerFrames are drawn in place after a "Doing something... " prompt
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+// Spin runs fn while drawing a spinner after the current line, for steps
+// whose progress can't be measured. Nothing is drawn when stdout isn't a
+// terminal, so output stays clean in logs and pipes.
+func Spin(fn func() error) error {
+	if !interactive {
+		return fn()
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			fmt.Print(Muted(spinnerFrames[i%len(spinnerFrames)]) + "\b")
+			select {
+			case <-done:
+				fmt.Print(" \b")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	err := fn()
+	close(done)
+	wg.Wait()
+	return err
+}
+
+// Progress draws a progress bar for work with a known number of steps
+type Progress struct {
+	label string
+	total int
+	done  int
+	width int
+}
+
+// NewProgress starts a progress bar. It draws nothing when stdout isn't a terminal.
+func NewProgress(label string, total int) *Progress {
+	p := &Progress{label: label, total: total, width: 30}
+	p.draw()
+	return p
+}
+
+// Add advances the bar by n steps
+func (p *Progress) Add(n int) {
+	p.done += n
+	p.draw()
+}
+
+// Finish clears the bar so the caller can print a summary in its place
+func (p *Progress) Finish() {
+	if !interactive {
+		return
+	}
+	fmt.Print("\r" + strings.Repeat(" ", Width(p.line())) + "\r")
+}
+
+// draw redraws the bar in place
+func (p *Progress) draw() {
+	if !interactive {
+		return
+	}
+	fmt.Print("\r" + p.line())
+}
+
+// line renders the bar
+func (p *Progress) line() string {
+	filled := p.width
+	if p.total > 0 {
+		filled = p.width * p.done / p.total
+	}
+	if filled > p.width {
+		filled = p.width
+	}
+	bar := Success(strings.Repeat("#", filled)) + Muted(strings.Repeat(".", p.width-filled))
+	return fmt.Sprintf("%s [%s] %d/%d", p.label, bar, p.done, p.total)
+}
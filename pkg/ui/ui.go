@@ -0,0 +1,167 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"passbook/pkg/redact"
+)
+
+// ANSI styles used by the theme
+const (
+	styleBold   = "1"
+	styleDim    = "2"
+	styleRed    = "31"
+	styleGreen  = "32"
+	styleYellow = "33"
+	styleCyan   = "36"
+)
+
+// colorEnabled is decided once at startup and can only be turned off later
+var colorEnabled = detectColor()
+
+// ansiPattern matches escape sequences so they don't count towards column widths
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// detectColor honors NO_COLOR (https://no-color.org) and only colors
+// output that goes to a real terminal
+func detectColor() bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return term.IsTerminal(int(os.Stdout.Fd())) && enableVirtualTerminal()
+}
+
+// SetColor applies the user's color preference. It can't force color on
+// when NO_COLOR is set or output isn't a terminal.
+func SetColor(enabled bool) {
+	colorEnabled = enabled && detectColor()
+}
+
+// ColorEnabled reports whether output is colored
+func ColorEnabled() bool {
+	return colorEnabled
+}
+
+// paint wraps s in an ANSI style when color is enabled
+func paint(style, s string) string {
+	if !colorEnabled || s == "" {
+		return s
+	}
+	return "\x1b[" + style + "m" + s + "\x1b[0m"
+}
+
+// Success styles text that reports something worked
+func Success(s string) string { return paint(styleGreen, s) }
+
+// Warn styles text that needs attention
+func Warn(s string) string { return paint(styleYellow, s) }
+
+// Fail styles text that reports an error
+func Fail(s string) string { return paint(styleRed, s) }
+
+// Bold styles text for emphasis
+func Bold(s string) string { return paint(styleBold, s) }
+
+// Highlight styles values the user has to act on, like URLs and codes
+func Highlight(s string) string { return paint(styleCyan, s) }
+
+// Muted styles secondary text
+func Muted(s string) string { return paint(styleDim, s) }
+
+// Heading prints a section title underlined with '='
+func Heading(title string) {
+	fmt.Println(Bold(title))
+	fmt.Println(strings.Repeat("=", Width(title)))
+}
+
+// Successf prints a line starting with a check mark
+func Successf(format string, args ...interface{}) {
+	fmt.Printf("%s %s\n", Success("✓"), fmt.Sprintf(format, args...))
+}
+
+// Warningf prints a warning line. Warnings often quote errors, so any
+// decrypted value in one is masked.
+func Warningf(format string, args ...interface{}) {
+	fmt.Printf("%s %s\n", Warn("Warning:"), redact.Scrub(fmt.Sprintf(format, args...)))
+}
+
+// Width returns the number of columns s takes up, ignoring color codes
+func Width(s string) int {
+	return utf8.RuneCountInString(ansiPattern.ReplaceAllString(s, ""))
+}
+
+// Table renders rows in aligned columns
+type Table struct {
+	headers []string
+	rows    [][]string
+	Indent  string
+}
+
+// NewTable creates a table; with no headers only the rows are printed
+func NewTable(headers ...string) *Table {
+	return &Table{headers: headers, Indent: "  "}
+}
+
+// Row adds a row
+func (t *Table) Row(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// Len returns the number of rows
+func (t *Table) Len() int {
+	return len(t.rows)
+}
+
+// Print renders the table to stdout
+func (t *Table) Print() {
+	t.Render(os.Stdout)
+}
+
+// Render writes the table to w. The last column isn't padded so long
+// values like descriptions don't leave trailing spaces.
+func (t *Table) Render(w io.Writer) {
+	var widths []int
+	measure := func(cells []string) {
+		for i, cell := range cells {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := Width(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	measure(t.headers)
+	for _, row := range t.rows {
+		measure(row)
+	}
+
+	write := func(cells []string, style func(string) string) {
+		var line strings.Builder
+		line.WriteString(t.Indent)
+		for i, cell := range cells {
+			if i > 0 {
+				line.WriteString("  ")
+			}
+			line.WriteString(style(cell))
+			if i < len(cells)-1 {
+				line.WriteString(strings.Repeat(" ", widths[i]-Width(cell)))
+			}
+		}
+		fmt.Fprintln(w, line.String())
+	}
+
+	if len(t.headers) > 0 {
+		write(t.headers, Bold)
+	}
+	for _, row := range t.rows {
+		write(row, func(s string) string { return s })
+	}
+}
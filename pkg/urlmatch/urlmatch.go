@@ -0,0 +1,143 @@
+// Package urlmatch decides which saved logins belong to a page. URLs are
+// normalized the same way on both sides, so http and https, a www. prefix,
+// ports and letter case don't keep a login from being found.
+package urlmatch
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Level is how closely a login fits a page, from no match to a login saved
+// for that page's path
+type Level int
+
+const (
+	// None means the login is for another site
+	None Level = iota
+	// Subdomain means the page is on a subdomain of the login's site, such
+	// as app.github.com for github.com
+	Subdomain
+	// Host means the page is on the login's own host
+	Host
+	// Path means the login's URL is on the page's host and its path is a
+	// prefix of the page's
+	Path
+)
+
+// String names a level for display
+func (l Level) String() string {
+	switch l {
+	case Subdomain:
+		return "subdomain"
+	case Host:
+		return "host"
+	case Path:
+		return "path"
+	}
+	return "none"
+}
+
+// Target is a normalized URL: a lowercase host without www. or a port, and
+// a clean path that starts with /
+type Target struct {
+	Host string
+	Path string
+}
+
+// Parse normalizes a URL. A bare host such as github.com or
+// github.com/login is read as https.
+func Parse(raw string) (Target, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return Target{}, fmt.Errorf("empty URL")
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Target{}, fmt.Errorf("invalid URL %q: %w", raw, err)
+	}
+	host := normalizeHost(u.Hostname())
+	if host == "" {
+		return Target{}, fmt.Errorf("invalid URL %q: no host", raw)
+	}
+
+	p := path.Clean("/" + u.Path)
+	return Target{Host: host, Path: p}, nil
+}
+
+// normalizeHost lowercases a host and drops a trailing dot and a leading
+// www., which serve the same site
+func normalizeHost(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if h, ok := strings.CutPrefix(host, "www."); ok && strings.Contains(h, ".") {
+		host = h
+	}
+	return host
+}
+
+// Match is how well one login fits a page. PathLen breaks ties between
+// path matches, so the login saved for the longest prefix wins.
+type Match struct {
+	Level   Level
+	PathLen int
+}
+
+// Better reports whether m is a closer match than other
+func (m Match) Better(other Match) bool {
+	if m.Level != other.Level {
+		return m.Level > other.Level
+	}
+	return m.PathLen > other.PathLen
+}
+
+// Credential matches a page against a login's website, such as github.com,
+// and its optional full URL. Either may match; the closer match counts.
+func Credential(page Target, website, loginURL string) Match {
+	var best Match
+	if site, err := Parse(website); err == nil {
+		best = hostMatch(page.Host, site.Host)
+	}
+	if loginURL == "" {
+		return best
+	}
+	saved, err := Parse(loginURL)
+	if err != nil {
+		return best
+	}
+
+	m := hostMatch(page.Host, saved.Host)
+	if m.Level == Host && saved.Path != "/" && hasPathPrefix(page.Path, saved.Path) {
+		m = Match{Level: Path, PathLen: len(saved.Path)}
+	}
+	if m.Better(best) {
+		best = m
+	}
+	return best
+}
+
+// hostMatch matches a page's host against a login's. Only names match as
+// subdomains; an IP address must match exactly.
+func hostMatch(page, login string) Match {
+	switch {
+	case page == login:
+		return Match{Level: Host}
+	case net.ParseIP(page) == nil && strings.Contains(login, ".") && strings.HasSuffix(page, "."+login):
+		return Match{Level: Subdomain}
+	}
+	return Match{}
+}
+
+// hasPathPrefix reports whether p is prefix or a path below it, at a segment
+// boundary so /admin doesn't match /administrator
+func hasPathPrefix(p, prefix string) bool {
+	if p == prefix {
+		return true
+	}
+	return strings.HasPrefix(p, strings.TrimSuffix(prefix, "/")+"/")
+}
@@ -0,0 +1,78 @@
+package urlmatch
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Target
+	}{
+		{"github.com", Target{Host: "github.com", Path: "/"}},
+		{"https://www.GitHub.com/login", Target{Host: "github.com", Path: "/login"}},
+		{"http://github.com:8080/a/../b/", Target{Host: "github.com", Path: "/b"}},
+		{"github.com./login?next=/", Target{Host: "github.com", Path: "/login"}},
+		{"www.localhost", Target{Host: "www.localhost", Path: "/"}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "  ", "https://"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestCredential(t *testing.T) {
+	tests := []struct {
+		name     string
+		page     string
+		website  string
+		loginURL string
+		want     Level
+	}{
+		{"same host", "https://github.com/settings", "github.com", "", Host},
+		{"www and scheme ignored", "http://www.github.com", "github.com", "", Host},
+		{"subdomain", "https://gist.github.com", "github.com", "", Subdomain},
+		{"other site", "https://github.com.evil.com", "github.com", "", None},
+		{"suffix isn't a subdomain", "https://notgithub.com", "github.com", "", None},
+		{"path below login URL", "https://github.com/org/repo", "github.com", "https://github.com/org", Path},
+		{"path on a segment boundary", "https://github.com/organization", "github.com", "https://github.com/org", Host},
+		{"login URL on another host", "https://github.com/org", "example.com", "https://github.com/org", Path},
+		{"IP addresses match exactly", "http://10.0.0.1", "0.0.1", "", None},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := Parse(tt.page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Credential(page, tt.website, tt.loginURL); got.Level != tt.want {
+				t.Errorf("Credential = %v, want %v", got.Level, tt.want)
+			}
+		})
+	}
+}
+
+func TestBetterPrefersLongerPaths(t *testing.T) {
+	page, err := Parse("https://github.com/org/repo/settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	org := Credential(page, "github.com", "https://github.com/org")
+	repo := Credential(page, "github.com", "https://github.com/org/repo")
+	if !repo.Better(org) || org.Better(repo) {
+		t.Errorf("%+v should beat %+v", repo, org)
+	}
+	if host := Credential(page, "github.com", ""); !org.Better(host) {
+		t.Errorf("a path match should beat %+v", host)
+	}
+}